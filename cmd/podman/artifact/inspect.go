@@ -0,0 +1,71 @@
+package artifact
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/containers/common/pkg/report"
+	"github.com/containers/podman/v5/cmd/podman/common"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/cmd/podman/utils"
+	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inspectCmd = &cobra.Command{
+		Annotations:       map[string]string{registry.EngineMode: registry.ABIMode},
+		Use:               "inspect [options] ARTIFACT [ARTIFACT...]",
+		Short:             "Inspect one or more OCI artifacts",
+		Long:              "Display the manifests of one or more OCI artifacts in the local store",
+		RunE:              inspect,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: common.AutocompleteArtifacts,
+		Example:           `podman artifact inspect quay.io/myimage/myartifact:latest`,
+	}
+	inspectFormat string
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: inspectCmd,
+		Parent:  artifactCmd,
+	})
+	flags := inspectCmd.Flags()
+
+	formatFlagName := "format"
+	flags.StringVarP(&inspectFormat, formatFlagName, "f", "", "Format inspect output using Go template")
+	_ = inspectCmd.RegisterFlagCompletionFunc(formatFlagName, common.AutocompleteFormat(&entities.ArtifactInspectReport{}))
+}
+
+func inspect(cmd *cobra.Command, args []string) error {
+	var errs utils.OutputErrors
+	inspected := make([]*entities.ArtifactInspectReport, 0, len(args))
+	for _, arg := range args {
+		inspectReport, err := registry.ImageEngine().ArtifactInspect(registry.GetContext(), arg, entities.ArtifactInspectOptions{})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		inspected = append(inspected, inspectReport)
+	}
+
+	if cmd.Flags().Changed("format") {
+		rpt, err := report.New(os.Stdout, cmd.Name()).Parse(report.OriginUser, inspectFormat)
+		if err != nil {
+			return err
+		}
+		defer rpt.Flush()
+		if err := rpt.Execute(inspected); err != nil {
+			return err
+		}
+	} else {
+		buf, err := json.MarshalIndent(inspected, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(buf))
+	}
+	return errs.PrintErrors()
+}
@@ -0,0 +1,96 @@
+package artifact
+
+import (
+	"os"
+
+	"github.com/containers/common/pkg/auth"
+	"github.com/containers/common/pkg/completion"
+	"github.com/containers/image/v5/types"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/containers/podman/v5/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// pullOptionsWrapper wraps entities.ArtifactPullOptions and prevents leaking
+// CLI-only fields into the API types.
+type pullOptionsWrapper struct {
+	entities.ArtifactPullOptions
+	TLSVerifyCLI   bool // CLI only
+	CredentialsCLI string
+}
+
+var (
+	pullOptions = pullOptionsWrapper{}
+	pullCmd     = &cobra.Command{
+		Annotations:       map[string]string{registry.EngineMode: registry.ABIMode},
+		Use:               "pull [options] ARTIFACT",
+		Short:             "Pull an OCI artifact",
+		Long:              "Pull an OCI artifact from a registry and store it locally",
+		RunE:              pull,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completion.AutocompleteNone,
+		Example:           `podman artifact pull quay.io/myimage/myartifact:latest`,
+	}
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: pullCmd,
+		Parent:  artifactCmd,
+	})
+	registryFlags(pullCmd, &pullOptions)
+	pullCmd.Flags().BoolVarP(&pullOptions.Quiet, "quiet", "q", false, "Suppress output information when pulling artifacts")
+}
+
+// registryFlags adds the flags for accessing a registry to cmd.
+func registryFlags(cmd *cobra.Command, opts *pullOptionsWrapper) {
+	flags := cmd.Flags()
+
+	authfileFlagName := "authfile"
+	flags.StringVar(&opts.Authfile, authfileFlagName, auth.GetDefaultAuthFile(), "Path of the authentication file. Use REGISTRY_AUTH_FILE environment variable to override")
+	_ = cmd.RegisterFlagCompletionFunc(authfileFlagName, completion.AutocompleteDefault)
+
+	certDirFlagName := "cert-dir"
+	flags.StringVar(&opts.CertDir, certDirFlagName, "", "`Pathname` of a directory containing TLS certificates and keys")
+	_ = cmd.RegisterFlagCompletionFunc(certDirFlagName, completion.AutocompleteDefault)
+
+	credsFlagName := "creds"
+	flags.StringVar(&opts.CredentialsCLI, credsFlagName, "", "`Credentials` (USERNAME:PASSWORD) to use for authenticating to a registry")
+	_ = cmd.RegisterFlagCompletionFunc(credsFlagName, completion.AutocompleteNone)
+
+	flags.BoolVar(&opts.TLSVerifyCLI, "tls-verify", true, "Require HTTPS and verify certificates when contacting registries")
+}
+
+// parseRegistryFlags sets the registry options from the flags added by
+// registryFlags.
+func parseRegistryFlags(cmd *cobra.Command, opts *pullOptionsWrapper) error {
+	if cmd.Flags().Changed("tls-verify") {
+		opts.SkipTLSVerify = types.NewOptionalBool(!opts.TLSVerifyCLI)
+	}
+	if cmd.Flags().Changed("authfile") {
+		if err := auth.CheckAuthFile(opts.Authfile); err != nil {
+			return err
+		}
+	}
+	if opts.CredentialsCLI != "" {
+		creds, err := util.ParseRegistryCreds(opts.CredentialsCLI)
+		if err != nil {
+			return err
+		}
+		opts.Username = creds.Username
+		opts.Password = creds.Password
+	}
+	if !opts.Quiet {
+		opts.Writer = os.Stderr
+	}
+	return nil
+}
+
+func pull(cmd *cobra.Command, args []string) error {
+	if err := parseRegistryFlags(cmd, &pullOptions); err != nil {
+		return err
+	}
+	_, err := registry.ImageEngine().ArtifactPull(registry.GetContext(), args[0], pullOptions.ArtifactPullOptions)
+	return err
+}
@@ -0,0 +1,44 @@
+package artifact
+
+import (
+	"github.com/containers/podman/v5/cmd/podman/common"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pushOptions = pullOptionsWrapper{}
+	pushCmd     = &cobra.Command{
+		Annotations:       map[string]string{registry.EngineMode: registry.ABIMode},
+		Use:               "push [options] ARTIFACT [DESTINATION]",
+		Short:             "Push an OCI artifact",
+		Long:              "Push an OCI artifact from the local store to a registry",
+		RunE:              push,
+		Args:              cobra.RangeArgs(1, 2),
+		ValidArgsFunction: common.AutocompleteArtifacts,
+		Example: `podman artifact push quay.io/myimage/myartifact:latest
+  podman artifact push localhost/myartifact quay.io/myimage/myartifact:latest`,
+	}
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: pushCmd,
+		Parent:  artifactCmd,
+	})
+	registryFlags(pushCmd, &pushOptions)
+	pushCmd.Flags().BoolVarP(&pushOptions.Quiet, "quiet", "q", false, "Suppress output information when pushing artifacts")
+}
+
+func push(cmd *cobra.Command, args []string) error {
+	if err := parseRegistryFlags(cmd, &pushOptions); err != nil {
+		return err
+	}
+	opts := entities.ArtifactPushOptions{ArtifactPullOptions: pushOptions.ArtifactPullOptions}
+	if len(args) > 1 {
+		opts.Destination = args[1]
+	}
+	_, err := registry.ImageEngine().ArtifactPush(registry.GetContext(), args[0], opts)
+	return err
+}
@@ -0,0 +1,43 @@
+package artifact
+
+import (
+	"fmt"
+
+	"github.com/containers/podman/v5/cmd/podman/common"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/cmd/podman/utils"
+	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rmCmd = &cobra.Command{
+		Annotations:       map[string]string{registry.EngineMode: registry.ABIMode},
+		Use:               "rm ARTIFACT [ARTIFACT...]",
+		Short:             "Remove one or more OCI artifacts from the local store",
+		RunE:              rm,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: common.AutocompleteArtifacts,
+		Example:           `podman artifact rm quay.io/myimage/myartifact:latest`,
+	}
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: rmCmd,
+		Parent:  artifactCmd,
+	})
+}
+
+func rm(cmd *cobra.Command, args []string) error {
+	var errs utils.OutputErrors
+	for _, arg := range args {
+		report, err := registry.ImageEngine().ArtifactRm(registry.GetContext(), arg, entities.ArtifactRemoveOptions{})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Println(report.ArtifactDigest)
+	}
+	return errs.PrintErrors()
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/containers/podman/v5/libpod/define"
 	"github.com/containers/podman/v5/libpod/events"
 	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/containers/podman/v5/pkg/imagescan"
 	"github.com/containers/podman/v5/pkg/inspect"
 	"github.com/containers/podman/v5/pkg/signal"
 	systemdDefine "github.com/containers/podman/v5/pkg/systemd/define"
@@ -247,6 +248,28 @@ func getSecrets(cmd *cobra.Command, toComplete string, cType completeType) ([]st
 	return suggestions, cobra.ShellCompDirectiveNoFileComp
 }
 
+func getArtifacts(cmd *cobra.Command, toComplete string) ([]string, cobra.ShellCompDirective) {
+	suggestions := []string{}
+
+	engine, err := setupImageEngine(cmd)
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	artifacts, err := engine.ArtifactList(registry.GetContext(), entities.ArtifactListOptions{})
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	for _, a := range artifacts {
+		if strings.HasPrefix(a.Name, toComplete) {
+			suggestions = append(suggestions, a.Name)
+		}
+	}
+	return suggestions, cobra.ShellCompDirectiveNoFileComp
+}
+
 func getRegistries() ([]string, cobra.ShellCompDirective) {
 	regs, err := sysregistriesv2.UnqualifiedSearchRegistries(nil)
 	if err != nil {
@@ -583,6 +606,46 @@ func AutocompleteVolumes(cmd *cobra.Command, args []string, toComplete string) (
 	return getVolumes(cmd, toComplete)
 }
 
+// AutocompleteVolumeSnapshotCreate - Autocomplete volume snapshot create.
+// -> volume names for the first argument
+func AutocompleteVolumeSnapshotCreate(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if !validCurrentCmdLine(cmd, args, toComplete) || len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return getVolumes(cmd, toComplete)
+}
+
+// AutocompleteVolumeSnapshotRestore - Autocomplete volume snapshot restore.
+// -> volume names for the first argument, snapshots of the volume for the second
+func AutocompleteVolumeSnapshotRestore(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if !validCurrentCmdLine(cmd, args, toComplete) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	switch len(args) {
+	case 0:
+		return getVolumes(cmd, toComplete)
+	case 1:
+		engine, err := setupContainerEngine(cmd)
+		if err != nil {
+			cobra.CompErrorln(err.Error())
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		snapshots, err := engine.VolumeSnapshotList(registry.GetContext(), args[0])
+		if err != nil {
+			cobra.CompErrorln(err.Error())
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		suggestions := []string{}
+		for _, snapshot := range snapshots {
+			if strings.HasPrefix(snapshot.Name, toComplete) {
+				suggestions = append(suggestions, snapshot.Name)
+			}
+		}
+		return suggestions, cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
 // AutocompleteSecrets - Autocomplete secrets.
 func AutocompleteSecrets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	if !validCurrentCmdLine(cmd, args, toComplete) {
@@ -598,6 +661,23 @@ func AutocompleteSecretCreate(cmd *cobra.Command, args []string, toComplete stri
 	return nil, cobra.ShellCompDirectiveNoFileComp
 }
 
+// AutocompleteArtifacts - Autocomplete artifacts.
+func AutocompleteArtifacts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if !validCurrentCmdLine(cmd, args, toComplete) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return getArtifacts(cmd, toComplete)
+}
+
+// AutocompleteArtifactAdd - Autocomplete artifact add: the first argument is
+// the artifact name, the others are files.
+func AutocompleteArtifactAdd(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveDefault
+}
+
 // AutocompleteImages - Autocomplete images.
 func AutocompleteImages(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	if !validCurrentCmdLine(cmd, args, toComplete) {
@@ -616,6 +696,11 @@ func AutocompletePodExitPolicy(cmd *cobra.Command, args []string, toComplete str
 	return config.PodExitPolicies, cobra.ShellCompDirectiveNoFileComp
 }
 
+// AutocompleteExitCodePropagation - Autocomplete exit-code propagation policies.
+func AutocompleteExitCodePropagation(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{"any", "all", "none"}, cobra.ShellCompDirectiveNoFileComp
+}
+
 // AutocompleteCreateRun - Autocomplete only the fist argument as image and then do file completion.
 func AutocompleteCreateRun(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	if !validCurrentCmdLine(cmd, args, toComplete) {
@@ -1003,10 +1088,18 @@ func AutocompleteLogOpt(cmd *cobra.Command, args []string, toComplete string) ([
 // AutocompletePullOption - Autocomplete pull options for create and run command.
 // -> "always", "missing", "never"
 func AutocompletePullOption(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	pullOptions := []string{"always", "missing", "never", "newer"}
+	pullOptions := []string{"always", "daily", "max-age=", "missing", "never", "newer"}
 	return pullOptions, cobra.ShellCompDirectiveNoFileComp
 }
 
+// AutocompleteImageScanSeverity - Autocomplete severities of image scan findings.
+// -> "low", "medium", "high", "critical"
+func AutocompleteImageScanSeverity(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	severities := []string{imagescan.SeverityLow.String(), imagescan.SeverityMedium.String(),
+		imagescan.SeverityHigh.String(), imagescan.SeverityCritical.String()}
+	return severities, cobra.ShellCompDirectiveNoFileComp
+}
+
 // AutocompleteRestartOption - Autocomplete restart options for create and run command.
 // -> "always", "no", "on-failure", "unless-stopped"
 func AutocompleteRestartOption(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -1419,7 +1512,7 @@ func AutocompleteEventFilter(cmd *cobra.Command, args []string, toComplete strin
 			events.Commit.String(), events.Create.String(), events.Exec.String(), events.ExecDied.String(),
 			events.Exited.String(), events.Export.String(), events.Import.String(), events.Init.String(), events.Kill.String(),
 			events.LoadFromArchive.String(), events.Mount.String(), events.NetworkConnect.String(),
-			events.NetworkDisconnect.String(), events.Pause.String(), events.Prune.String(), events.Pull.String(),
+			events.NetworkDisconnect.String(), events.PartialPull.String(), events.Pause.String(), events.Prune.String(), events.Pull.String(),
 			events.Push.String(), events.Refresh.String(), events.Remove.String(), events.Rename.String(),
 			events.Renumber.String(), events.Restart.String(), events.Restore.String(), events.Save.String(),
 			events.Start.String(), events.Stop.String(), events.Sync.String(), events.Tag.String(), events.Unmount.String(),
@@ -1576,9 +1669,9 @@ func AutocompleteContainersConfModules(cmd *cobra.Command, args []string, toComp
 }
 
 // AutocompleteEventBackend - Autocomplete event backend options.
-// -> "file", "journald", "none"
+// -> "file", "journald", "sqlite", "none"
 func AutocompleteEventBackend(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	types := []string{events.LogFile.String(), events.Journald.String(), events.Null.String()}
+	types := []string{events.LogFile.String(), events.Journald.String(), events.SQLite.String(), events.Null.String()}
 	return types, cobra.ShellCompDirectiveNoFileComp
 }
 
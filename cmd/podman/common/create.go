@@ -210,14 +210,6 @@ func DefineCreateFlags(cmd *cobra.Command, cf *entities.ContainerCreateOptions,
 		)
 		_ = cmd.RegisterFlagCompletionFunc(healthTimeoutFlagName, completion.AutocompleteNone)
 
-		healthOnFailureFlagName := "health-on-failure"
-		createFlags.StringVar(
-			&cf.HealthOnFailure,
-			healthOnFailureFlagName, "none",
-			"action to take once the container turns unhealthy",
-		)
-		_ = cmd.RegisterFlagCompletionFunc(healthOnFailureFlagName, AutocompleteHealthOnFailure)
-
 		createFlags.BoolVar(
 			&cf.HTTPProxy,
 			"http-proxy", podmanConfig.ContainersConfDefaultsRO.Containers.HTTPProxy,
@@ -361,7 +353,7 @@ func DefineCreateFlags(cmd *cobra.Command, cf *entities.ContainerCreateOptions,
 		createFlags.StringVar(
 			&cf.Pull,
 			pullFlagName, cf.Pull,
-			`Pull image policy ("always"|"missing"|"never"|"newer")`,
+			`Pull image policy ("always"|"daily"|"max-age=DURATION"|"missing"|"never"|"newer")`,
 		)
 		_ = cmd.RegisterFlagCompletionFunc(pullFlagName, AutocompletePullOption)
 
@@ -392,7 +384,7 @@ func DefineCreateFlags(cmd *cobra.Command, cf *entities.ContainerCreateOptions,
 		createFlags.StringSliceVar(
 			&cf.Requires,
 			requiresFlagName, []string{},
-			"Add one or more requirement containers that must be started before this container will start",
+			"Add one or more requirement containers that must be started before this container will start (format: CONTAINER[:started|healthy|exited-successfully])",
 		)
 		_ = cmd.RegisterFlagCompletionFunc(requiresFlagName, AutocompleteContainers)
 
@@ -901,6 +893,14 @@ func DefineCreateFlags(cmd *cobra.Command, cf *entities.ContainerCreateOptions,
 		_ = cmd.RegisterFlagCompletionFunc(memorySwappinessFlagName, completion.AutocompleteNone)
 	}
 	if mode == entities.CreateMode || mode == entities.UpdateMode {
+		healthOnFailureFlagName := "health-on-failure"
+		createFlags.StringVar(
+			&cf.HealthOnFailure,
+			healthOnFailureFlagName, "none",
+			"action to take once the container turns unhealthy",
+		)
+		_ = cmd.RegisterFlagCompletionFunc(healthOnFailureFlagName, AutocompleteHealthOnFailure)
+
 		deviceReadIopsFlagName := "device-read-iops"
 		createFlags.StringArrayVar(
 			&cf.DeviceReadIOPs,
@@ -923,6 +923,22 @@ func DefineCreateFlags(cmd *cobra.Command, cf *entities.ContainerCreateOptions,
 			"Tune container pids limit (set -1 for unlimited)",
 		)
 		_ = cmd.RegisterFlagCompletionFunc(pidsLimitFlagName, completion.AutocompleteNone)
+
+		hugetlbFlagName := "hugetlb"
+		createFlags.StringArrayVar(
+			&cf.HugeTLB,
+			hugetlbFlagName, []string{},
+			"Limit the huge pages of a page size (format: `PAGESIZE:LIMIT`, e.g. --hugetlb=2MB:1g)",
+		)
+		_ = cmd.RegisterFlagCompletionFunc(hugetlbFlagName, completion.AutocompleteNone)
+
+		rdmaFlagName := "rdma"
+		createFlags.StringArrayVar(
+			&cf.Rdma,
+			rdmaFlagName, []string{},
+			"Limit the RDMA resources of a device (format: `DEVICE:HCA_HANDLES:HCA_OBJECTS`, e.g. --rdma=mlx5_0:3:100)",
+		)
+		_ = cmd.RegisterFlagCompletionFunc(rdmaFlagName, completion.AutocompleteNone)
 	}
 	// anyone can use these
 	cpusFlagName := "cpus"
@@ -7,7 +7,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/containers/common/pkg/auth"
 	"github.com/containers/common/pkg/completion"
+	"github.com/containers/image/v5/types"
 	"github.com/containers/podman/v5/cmd/podman/common"
 	"github.com/containers/podman/v5/cmd/podman/registry"
 	"github.com/containers/podman/v5/cmd/podman/utils"
@@ -40,7 +42,40 @@ var (
 	}
 )
 
-var checkpointOptions entities.CheckpointOptions
+var (
+	checkpointOptions  entities.CheckpointOptions
+	checkpointRegistry checkpointRegistryOptions
+	checkpointPush     bool
+)
+
+// checkpointRegistryOptions are the CLI-only options for the registry
+// checkpoint images are pushed to and restored from
+type checkpointRegistryOptions struct {
+	Authfile     string
+	TLSVerifyCLI bool
+}
+
+func (o *checkpointRegistryOptions) addFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	authfileFlagName := "authfile"
+	flags.StringVar(&o.Authfile, authfileFlagName, auth.GetDefaultAuthFile(), "Path of the authentication file. Use REGISTRY_AUTH_FILE environment variable to override")
+	_ = cmd.RegisterFlagCompletionFunc(authfileFlagName, completion.AutocompleteDefault)
+	flags.BoolVar(&o.TLSVerifyCLI, "tls-verify", true, "Require HTTPS and verify certificates when contacting registries")
+}
+
+// validate checks the authentication file and returns whether TLS
+// verification is skipped
+func (o *checkpointRegistryOptions) validate(cmd *cobra.Command) (types.OptionalBool, error) {
+	if cmd.Flags().Changed("authfile") {
+		if err := auth.CheckAuthFile(o.Authfile); err != nil {
+			return types.OptionalBoolUndefined, err
+		}
+	}
+	if cmd.Flags().Changed("tls-verify") {
+		return types.NewOptionalBool(!o.TLSVerifyCLI), nil
+	}
+	return types.OptionalBoolUndefined, nil
+}
 
 type checkpointStatistics struct {
 	PodmanDuration      int64                        `json:"podman_checkpoint_duration"`
@@ -71,6 +106,8 @@ func init() {
 	createImageFlagName := "create-image"
 	flags.StringVarP(&checkpointOptions.CreateImage, createImageFlagName, "", "", "Create checkpoint image with specified name")
 	_ = checkpointCommand.RegisterFlagCompletionFunc(createImageFlagName, completion.AutocompleteNone)
+	flags.BoolVar(&checkpointPush, "push", false, "Push the checkpoint image to its registry")
+	checkpointRegistry.addFlags(checkpointCommand)
 
 	flags.StringP("compress", "c", "zstd", "Select compression algorithm (gzip, none, zstd) for checkpoint archive.")
 	_ = checkpointCommand.RegisterFlagCompletionFunc("compress", common.AutocompleteCheckpointCompressType)
@@ -122,12 +159,34 @@ func checkpoint(cmd *cobra.Command, args []string) error {
 	if (checkpointOptions.WithPrevious || checkpointOptions.PreCheckPoint) && !criu.MemTrack() {
 		return errors.New("system (architecture/kernel/CRIU) does not support memory tracking")
 	}
+	if checkpointPush && checkpointOptions.CreateImage == "" {
+		return errors.New("--push can only be used with --create-image")
+	}
+	skipTLSVerify, err := checkpointRegistry.validate(cmd)
+	if err != nil {
+		return err
+	}
 	responses, err := registry.ContainerEngine().ContainerCheckpoint(context.Background(), args, checkpointOptions)
 	if err != nil {
 		return err
 	}
 	podmanFinished := time.Now()
 
+	pushed := false
+	for _, r := range responses {
+		if r.Err != nil || !checkpointPush || pushed {
+			continue
+		}
+		// all checkpoints go to the same image, which is pushed once
+		pushed = true
+		if _, err := registry.ImageEngine().Push(registry.GetContext(), checkpointOptions.CreateImage, checkpointOptions.CreateImage, entities.ImagePushOptions{
+			Authfile:      checkpointRegistry.Authfile,
+			SkipTLSVerify: skipTLSVerify,
+		}); err != nil {
+			r.Err = fmt.Errorf("pushing checkpoint image %s: %w", checkpointOptions.CreateImage, err)
+		}
+	}
+
 	var statistics checkpointStatistics
 
 	for _, r := range responses {
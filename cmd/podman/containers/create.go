@@ -10,7 +10,6 @@ import (
 
 	"github.com/containers/buildah/pkg/cli"
 	"github.com/containers/common/pkg/auth"
-	"github.com/containers/common/pkg/config"
 	"github.com/containers/image/v5/transports/alltransports"
 	"github.com/containers/image/v5/types"
 	"github.com/containers/podman/v5/cmd/podman/common"
@@ -332,7 +331,7 @@ func CreateInit(c *cobra.Command, vals entities.ContainerCreateOptions, isInfra
 
 // Pulls image if any also parses and populates OS, Arch and Variant in specified container create options
 func PullImage(imageName string, cliVals *entities.ContainerCreateOptions) (string, error) {
-	pullPolicy, err := config.ParsePullPolicy(cliVals.Pull)
+	pullPolicy, pullMaxAge, err := util.ParsePullPolicy(cliVals.Pull)
 	if err != nil {
 		return "", err
 	}
@@ -368,6 +367,7 @@ func PullImage(imageName string, cliVals *entities.ContainerCreateOptions) (stri
 		Variant:          cliVals.Variant,
 		SignaturePolicy:  cliVals.SignaturePolicy,
 		PullPolicy:       pullPolicy,
+		PullMaxAge:       pullMaxAge,
 		SkipTLSVerify:    skipTLSVerify,
 		OciDecryptConfig: decConfig,
 	})
@@ -0,0 +1,117 @@
+package containers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/containers/podman/v5/cmd/podman/common"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/spf13/cobra"
+)
+
+var (
+	portAddDescription = `Publish additional ports of a container.
+
+  The ports are given in the format of the --publish option of podman run. The port forwarding of running containers is updated without restarting them.
+`
+	portAddCommand = &cobra.Command{
+		Use:               "add CONTAINER PORT [PORT...]",
+		Short:             "Publish additional ports of a container",
+		Long:              portAddDescription,
+		RunE:              portAdd,
+		Args:              cobra.MinimumNArgs(2),
+		ValidArgsFunction: common.AutocompleteContainerOneArg,
+		Example: `podman port add ctrID 8080:80
+  podman port add ctrID 127.0.0.1:5353:53/udp 9000-9002:9000-9002`,
+	}
+
+	containerPortAddCommand = &cobra.Command{
+		Use:               portAddCommand.Use,
+		Short:             portAddCommand.Short,
+		Long:              portAddCommand.Long,
+		RunE:              portAddCommand.RunE,
+		Args:              portAddCommand.Args,
+		ValidArgsFunction: portAddCommand.ValidArgsFunction,
+		Example: `podman container port add ctrID 8080:80
+  podman container port add ctrID 127.0.0.1:5353:53/udp 9000-9002:9000-9002`,
+	}
+
+	portRemoveDescription = `Stop publishing ports of a container.
+
+  The ports are given in the format of the --publish option of podman run, a port without host port stops publishing the container port on all host ports. The port forwarding of running containers is updated without restarting them.
+`
+	portRemoveCommand = &cobra.Command{
+		Use:               "remove CONTAINER PORT [PORT...]",
+		Aliases:           []string{"rm"},
+		Short:             "Stop publishing ports of a container",
+		Long:              portRemoveDescription,
+		RunE:              portRemove,
+		Args:              cobra.MinimumNArgs(2),
+		ValidArgsFunction: common.AutocompleteContainerOneArg,
+		Example: `podman port remove ctrID 8080:80
+  podman port remove ctrID 53/udp`,
+	}
+
+	containerPortRemoveCommand = &cobra.Command{
+		Use:               portRemoveCommand.Use,
+		Aliases:           portRemoveCommand.Aliases,
+		Short:             portRemoveCommand.Short,
+		Long:              portRemoveCommand.Long,
+		RunE:              portRemoveCommand.RunE,
+		Args:              portRemoveCommand.Args,
+		ValidArgsFunction: portRemoveCommand.ValidArgsFunction,
+		Example: `podman container port remove ctrID 8080:80
+  podman container port remove ctrID 53/udp`,
+	}
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: portAddCommand,
+		Parent:  portCommand,
+	})
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: containerPortAddCommand,
+		Parent:  containerPortCommand,
+	})
+
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: portRemoveCommand,
+		Parent:  portCommand,
+	})
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: containerPortRemoveCommand,
+		Parent:  containerPortCommand,
+	})
+}
+
+func portAdd(_ *cobra.Command, args []string) error {
+	return portUpdate(args[0], entities.ContainerPortUpdateOptions{Add: args[1:]})
+}
+
+func portRemove(_ *cobra.Command, args []string) error {
+	return portUpdate(args[0], entities.ContainerPortUpdateOptions{Remove: args[1:]})
+}
+
+// portUpdate changes the published ports of the container and prints the
+// ports published afterwards.
+func portUpdate(container string, options entities.ContainerPortUpdateOptions) error {
+	container = strings.TrimPrefix(container, "/")
+	report, err := registry.ContainerEngine().ContainerPortUpdate(registry.GetContext(), container, options)
+	if err != nil {
+		return err
+	}
+	for _, v := range report.Ports {
+		hostIP := v.HostIP
+		if hostIP == "" {
+			hostIP = "0.0.0.0"
+		}
+		for _, protocol := range strings.Split(v.Protocol, ",") {
+			for i := uint16(0); i < v.Range; i++ {
+				fmt.Printf("%d/%s -> %s:%d\n", v.ContainerPort+i, protocol, hostIP, v.HostPort+i)
+			}
+		}
+	}
+	return nil
+}
@@ -6,6 +6,9 @@ import (
 	"time"
 
 	"github.com/containers/common/pkg/completion"
+	"github.com/containers/common/pkg/config"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/types"
 	"github.com/containers/podman/v5/cmd/podman/common"
 	"github.com/containers/podman/v5/cmd/podman/registry"
 	"github.com/containers/podman/v5/cmd/podman/utils"
@@ -36,7 +39,10 @@ var (
 	}
 )
 
-var restoreOptions entities.RestoreOptions
+var (
+	restoreOptions  entities.RestoreOptions
+	restoreRegistry checkpointRegistryOptions
+)
 
 type restoreStatistics struct {
 	PodmanDuration      int64                     `json:"podman_restore_duration"`
@@ -87,9 +93,31 @@ func init() {
 		"Display restore statistics",
 	)
 
+	restoreRegistry.addFlags(restoreCommand)
+
 	validate.AddLatestFlag(restoreCommand, &restoreOptions.Latest)
 }
 
+// pullCheckpointImages pulls the checkpoint images given by args that are
+// not in the local storage.  Only fully qualified references are pulled, so
+// that the names of containers that do not exist are not looked up in
+// registries.
+func pullCheckpointImages(args []string, skipTLSVerify types.OptionalBool) error {
+	for _, arg := range args {
+		if _, err := reference.ParseNamed(arg); err != nil {
+			continue
+		}
+		if _, err := registry.ImageEngine().Pull(registry.GetContext(), arg, entities.ImagePullOptions{
+			Authfile:      restoreRegistry.Authfile,
+			PullPolicy:    config.PullPolicyMissing,
+			SkipTLSVerify: skipTLSVerify,
+		}); err != nil {
+			return fmt.Errorf("pulling checkpoint image %s: %w", arg, err)
+		}
+	}
+	return nil
+}
+
 func restore(cmd *cobra.Command, args []string) error {
 	var (
 		e    error
@@ -115,6 +143,13 @@ func restore(cmd *cobra.Command, args []string) error {
 	}
 
 	if !exists.Value {
+		skipTLSVerify, err := restoreRegistry.validate(cmd)
+		if err != nil {
+			return err
+		}
+		if err := pullCheckpointImages(args, skipTLSVerify); err != nil {
+			return err
+		}
 		// Find out if this is an image
 		restoreOptions.CheckpointImage, e = utils.IsCheckpointImage(context.Background(), args)
 		if e != nil {
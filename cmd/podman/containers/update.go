@@ -7,6 +7,7 @@ import (
 
 	"github.com/containers/podman/v5/cmd/podman/common"
 	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/libpod/define"
 	"github.com/containers/podman/v5/pkg/domain/entities"
 	"github.com/containers/podman/v5/pkg/specgen"
 	"github.com/containers/podman/v5/pkg/specgenutil"
@@ -15,7 +16,7 @@ import (
 )
 
 var (
-	updateDescription = `Updates the cgroup configuration of a given container`
+	updateDescription = `Updates the cgroup configuration and the healthcheck on-failure action of a given container`
 
 	updateCommand = &cobra.Command{
 		Use:               "update [options] CONTAINER",
@@ -80,6 +81,12 @@ func update(cmd *cobra.Command, args []string) error {
 		NameOrID: strings.TrimPrefix(args[0], "/"),
 		Specgen:  s,
 	}
+	if cmd.Flags().Changed("health-on-failure") {
+		if _, err := define.ParseHealthCheckOnFailureAction(updateOpts.HealthOnFailure); err != nil {
+			return err
+		}
+		opts.HealthOnFailure = updateOpts.HealthOnFailure
+	}
 	rep, err := registry.ContainerEngine().ContainerUpdate(context.Background(), opts)
 	if err != nil {
 		return err
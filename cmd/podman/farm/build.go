@@ -18,6 +18,7 @@ import (
 type buildOptions struct {
 	buildOptions common.BuildFlagsWrapper
 	local        bool
+	onlyMissing  bool
 	platforms    []string
 	farm         string
 }
@@ -57,6 +58,9 @@ func init() {
 	// Default for local is true
 	flags.BoolVarP(&buildOpts.local, localFlagName, "l", true, "Build image on local machine as well as on farm nodes")
 
+	onlyMissingFlagName := "only-missing"
+	flags.BoolVar(&buildOpts.onlyMissing, onlyMissingFlagName, false, "Only build the platforms missing in an existing local manifest list")
+
 	platformsFlag := "platforms"
 	buildCommand.PersistentFlags().StringSliceVar(&buildOpts.platforms, platformsFlag, nil, "Build only on farm nodes that match the given platforms")
 	_ = buildCommand.RegisterFlagCompletionFunc(platformsFlag, completion.AutocompletePlatform)
@@ -135,6 +139,20 @@ func build(cmd *cobra.Command, args []string) error {
 	logrus.Infof("schedule: %v", schedule)
 
 	manifestName := opts.Output
+	if buildOpts.onlyMissing {
+		var skipped []string
+		schedule, skipped, err = farm.SkipExistingPlatforms(ctx, schedule, manifestName)
+		if err != nil {
+			return fmt.Errorf("scheduling builds: %w", err)
+		}
+		if len(skipped) > 0 {
+			fmt.Printf("Skipping platforms already in %q: %s\n", manifestName, strings.Join(skipped, ", "))
+		}
+		if len(schedule.Platforms()) == 0 {
+			fmt.Printf("Manifest list %q is up to date\n", manifestName)
+			return nil
+		}
+	}
 	// Set Output to "" so that the images built on the farm nodes have no name
 	opts.Output = ""
 	if err = farm.Build(ctx, schedule, *opts, manifestName, localEngine); err != nil {
@@ -25,6 +25,10 @@ const (
 	stopTimeoutCompatFlagName = "time"
 	restartPolicyFlagName     = "restart-policy"
 	restartSecFlagName        = "restart-sec"
+	restartStepsFlagName      = "restart-steps"
+	restartMaxDelayFlagName   = "restart-max-delay-sec"
+	exitCodePropFlagName      = "exit-code-propagation"
+	sdnotifyFlagName          = "sdnotify"
 	newFlagName               = "new"
 	wantsFlagName             = "wants"
 	afterFlagName             = "after"
@@ -45,6 +49,10 @@ Please refer to podman-systemd.unit(5) for details.
 	format             string
 	systemdRestart     string
 	systemdRestartSec  uint
+	restartSteps       uint
+	restartMaxDelay    uint
+	exitCodeProp       string
+	sdnotify           string
 	startTimeout       uint
 	stopTimeout        uint
 	systemdOptions     = entities.GenerateSystemdOptions{}
@@ -107,6 +115,18 @@ func init() {
 	flags.UintVarP(&systemdRestartSec, restartSecFlagName, "", 0, "Systemd restart-sec")
 	_ = systemdCmd.RegisterFlagCompletionFunc(restartSecFlagName, completion.AutocompleteNone)
 
+	flags.UintVar(&restartSteps, restartStepsFlagName, 0, "Systemd restart-steps")
+	_ = systemdCmd.RegisterFlagCompletionFunc(restartStepsFlagName, completion.AutocompleteNone)
+
+	flags.UintVar(&restartMaxDelay, restartMaxDelayFlagName, 0, "Systemd restart-max-delay-sec")
+	_ = systemdCmd.RegisterFlagCompletionFunc(restartMaxDelayFlagName, completion.AutocompleteNone)
+
+	flags.StringVar(&exitCodeProp, exitCodePropFlagName, "", "Exit-code propagation of a pod (any, all, none), requires --new")
+	_ = systemdCmd.RegisterFlagCompletionFunc(exitCodePropFlagName, common.AutocompleteExitCodePropagation)
+
+	flags.StringVar(&sdnotify, sdnotifyFlagName, "", "Sd-notify mode of new containers (conmon, container, healthy), requires --new")
+	_ = systemdCmd.RegisterFlagCompletionFunc(sdnotifyFlagName, common.AutocompleteSDNotify)
+
 	formatFlagName := "format"
 	flags.StringVar(&format, formatFlagName, "", "Print the created units in specified format (json)")
 	_ = systemdCmd.RegisterFlagCompletionFunc(formatFlagName, common.AutocompleteFormat(nil))
@@ -146,6 +166,18 @@ func systemd(cmd *cobra.Command, args []string) error {
 	if cmd.Flags().Changed(restartSecFlagName) {
 		systemdOptions.RestartSec = &systemdRestartSec
 	}
+	if cmd.Flags().Changed(restartStepsFlagName) {
+		systemdOptions.RestartSteps = &restartSteps
+	}
+	if cmd.Flags().Changed(restartMaxDelayFlagName) {
+		systemdOptions.RestartMaxDelaySec = &restartMaxDelay
+	}
+	if cmd.Flags().Changed(exitCodePropFlagName) {
+		systemdOptions.ExitCodePropagation = &exitCodeProp
+	}
+	if cmd.Flags().Changed(sdnotifyFlagName) {
+		systemdOptions.SdNotify = &sdnotify
+	}
 	if cmd.Flags().Changed(startTimeoutFlagName) {
 		systemdOptions.StartTimeout = &startTimeout
 	}
@@ -132,6 +132,9 @@ func pullFlags(cmd *cobra.Command) {
 		flags.StringVar(&pullOptions.SignaturePolicy, "signature-policy", "", "`Pathname` of signature policy file (not usually used)")
 		_ = flags.MarkHidden("signature-policy")
 	}
+	if !registry.IsRemote() {
+		flags.BoolVar(&pullOptions.Verbose, "verbose", false, "Print the statistics of the layers pulled partially")
+	}
 }
 
 // imagePull is implement the command for pulling images.
@@ -0,0 +1,133 @@
+package images
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/containers/common/pkg/report"
+	"github.com/containers/podman/v5/cmd/podman/common"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/containers/podman/v5/pkg/imagescan"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scanDescription = `Scans images for vulnerabilities with the image scanner configured in containers.conf.
+
+  The report of the scan is recorded with the image and used to decide whether containers may be created from the image.`
+	scanCmd = &cobra.Command{
+		Annotations:       map[string]string{registry.EngineMode: registry.ABIMode},
+		Use:               "scan [options] IMAGE [IMAGE...]",
+		Short:             "Scan images for vulnerabilities",
+		Long:              scanDescription,
+		Args:              cobra.MinimumNArgs(1),
+		RunE:              scan,
+		ValidArgsFunction: common.AutocompleteImages,
+		Example: `podman image scan quay.io/fedora/fedora
+  podman image scan --fail-on high --format json quay.io/fedora/fedora`,
+	}
+
+	scanOpts = struct {
+		failOn    string
+		format    string
+		noHeading bool
+	}{}
+)
+
+// scanReporter contains the fields of a finding displayed by podman image
+// scan.
+type scanReporter struct {
+	imagescan.Finding
+	Image string
+}
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: scanCmd,
+		Parent:  imageCmd,
+	})
+	flags := scanCmd.Flags()
+
+	failOnFlagName := "fail-on"
+	flags.StringVar(&scanOpts.failOn, failOnFlagName, "", "Exit with an error if findings of at least the specified severity are found")
+	_ = scanCmd.RegisterFlagCompletionFunc(failOnFlagName, common.AutocompleteImageScanSeverity)
+
+	formatFlagName := "format"
+	flags.StringVar(&scanOpts.format, formatFlagName, "", "Change the output to JSON or a Go template")
+	_ = scanCmd.RegisterFlagCompletionFunc(formatFlagName, common.AutocompleteFormat(&scanReporter{}))
+
+	flags.BoolVarP(&scanOpts.noHeading, "noheading", "n", false, "Do not print headers")
+}
+
+func scan(cmd *cobra.Command, args []string) error {
+	var failOn imagescan.Severity
+	if cmd.Flags().Changed("fail-on") {
+		var err error
+		if failOn, err = imagescan.ParseSeverity(scanOpts.failOn); err != nil {
+			return err
+		}
+	}
+
+	results, err := registry.ImageEngine().Scan(registry.Context(), args, entities.ImageScanOptions{})
+	if err != nil {
+		return err
+	}
+
+	if err := printScanResults(cmd, results); err != nil {
+		return err
+	}
+
+	if cmd.Flags().Changed("fail-on") {
+		for _, r := range results {
+			if blocking := r.Report.Blocking(failOn); len(blocking) > 0 {
+				return fmt.Errorf("image %s has %d findings of severity %s or higher", r.ID, len(blocking), failOn)
+			}
+		}
+	}
+	return nil
+}
+
+func printScanResults(cmd *cobra.Command, results []*entities.ImageScanReport) error {
+	if report.IsJSON(scanOpts.format) {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "    ")
+		return enc.Encode(results)
+	}
+
+	var findings []scanReporter
+	for _, r := range results {
+		image := r.ID[:12]
+		if len(r.Names) > 0 {
+			image = r.Names[0]
+		}
+		for _, f := range r.Report.Findings {
+			findings = append(findings, scanReporter{Finding: f, Image: image})
+		}
+	}
+
+	rpt := report.New(os.Stdout, cmd.Name())
+	defer rpt.Flush()
+
+	var err error
+	switch {
+	case cmd.Flags().Changed("format"):
+		rpt, err = rpt.Parse(report.OriginUser, scanOpts.format)
+	default:
+		format := "{{range .}}{{.Image}}\t{{.ID}}\t{{.Severity}}\t{{.Package}}\t{{.Version}}\t{{.FixedVersion}}\n{{end -}}"
+		rpt, err = rpt.Parse(report.OriginPodman, format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if rpt.RenderHeaders && !scanOpts.noHeading {
+		hdrs := report.Headers(scanReporter{}, map[string]string{
+			"FixedVersion": "FIXED IN",
+		})
+		if err := rpt.Execute(hdrs); err != nil {
+			return fmt.Errorf("failed to write report column headers: %w", err)
+		}
+	}
+	return rpt.Execute(findings)
+}
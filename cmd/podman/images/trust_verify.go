@@ -0,0 +1,75 @@
+package images
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/containers/common/pkg/completion"
+	"github.com/containers/common/pkg/report"
+	"github.com/containers/podman/v5/cmd/podman/common"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyTrustDescription = `Verifies an image in local storage against the current trust policy.
+
+  The image is verified as if it was pulled using its first name or the specified reference, and the resulting trust status is recorded with the image.`
+	verifyTrustCommand = &cobra.Command{
+		Annotations:       map[string]string{registry.EngineMode: registry.ABIMode},
+		Use:               "verify [options] IMAGE",
+		Short:             "Verify an image against the trust policy",
+		Long:              verifyTrustDescription,
+		RunE:              verifyTrust,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: common.AutocompleteImages,
+		Example: `podman image trust verify quay.io/podman/stable
+  podman image trust verify --reference quay.io/podman/stable:v5 a1b2c3d4e5f6`,
+	}
+)
+
+var (
+	verifyTrustOptions entities.VerifyTrustOptions
+	verifyTrustFormat  string
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: verifyTrustCommand,
+		Parent:  trustCmd,
+	})
+	verifyFlags := verifyTrustCommand.Flags()
+
+	formatFlagName := "format"
+	verifyFlags.StringVar(&verifyTrustFormat, formatFlagName, "", "Change the output to JSON or a Go template")
+	_ = verifyTrustCommand.RegisterFlagCompletionFunc(formatFlagName, common.AutocompleteFormat(&entities.VerifyTrustReport{}))
+
+	referenceFlagName := "reference"
+	verifyFlags.StringVar(&verifyTrustOptions.Reference, referenceFlagName, "", "Verify the image as if it was pulled using `REFERENCE`")
+	_ = verifyTrustCommand.RegisterFlagCompletionFunc(referenceFlagName, completion.AutocompleteNone)
+}
+
+func verifyTrust(cmd *cobra.Command, args []string) error {
+	result, err := registry.ImageEngine().VerifyTrust(registry.Context(), args[0], verifyTrustOptions)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case report.IsJSON(verifyTrustFormat):
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "    ")
+		return enc.Encode(result)
+	case cmd.Flags().Changed("format"):
+		rpt, err := report.New(os.Stdout, cmd.Name()).Parse(report.OriginUser, verifyTrustFormat)
+		if err != nil {
+			return err
+		}
+		defer rpt.Flush()
+		return rpt.Execute(result)
+	default:
+		fmt.Printf("%s: %s\n", result.TrustStatus.Reference, result.TrustStatus)
+		return nil
+	}
+}
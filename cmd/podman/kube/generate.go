@@ -85,6 +85,9 @@ func generateFlags(cmd *cobra.Command, podmanConfig *entities.PodmanConfig) {
 	flags.BoolVar(&generateOptions.UseLongAnnotations, noTruncAnnotationsFlagName, false, "Don't truncate annotations to Kubernetes length (63 chars)")
 	_ = flags.MarkHidden(noTruncAnnotationsFlagName)
 
+	volumesFlagName := "volumes"
+	flags.BoolVar(&generateOptions.Volumes, volumesFlagName, false, "Generate YAML for PersistentVolumeClaims of the named volumes used by the containers")
+
 	podmanOnlyFlagName := "podman-only"
 	flags.BoolVar(&generateOptions.PodmanOnly, podmanOnlyFlagName, false, "Add podman-only reserved annotations to the generated YAML file (Cannot be used by Kubernetes)")
 
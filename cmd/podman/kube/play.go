@@ -9,8 +9,10 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
 	"strings"
 	"syscall"
+	"time"
 
 	buildahParse "github.com/containers/buildah/pkg/parse"
 	"github.com/containers/common/pkg/auth"
@@ -40,8 +42,13 @@ type playKubeOptionsWrapper struct {
 	BuildCLI       bool
 	annotations    []string
 	macs           []string
+	watchConfigs   bool
 }
 
+// configWatchInterval is the interval in which --watch-configs checks for
+// changes of the watched files.
+const configWatchInterval = 2 * time.Second
+
 var (
 	// https://kubernetes.io/docs/reference/command-line-tools-reference/kubelet/
 	defaultSeccompRoot = "/var/lib/kubelet/seccomp"
@@ -166,6 +173,9 @@ func playFlags(cmd *cobra.Command) {
 	waitFlagName := "wait"
 	flags.BoolVarP(&playOptions.Wait, waitFlagName, "w", false, "Clean up all objects created when a SIGTERM is received or pods exit")
 
+	watchConfigsFlagName := "watch-configs"
+	flags.BoolVar(&playOptions.watchConfigs, watchConfigsFlagName, false, "Refresh ConfigMap and Secret volumes when the YAML file or the --configmap files change, requires --wait")
+
 	configmapFlagName := "configmap"
 	flags.StringArrayVar(&playOptions.ConfigMaps, configmapFlagName, []string{}, "`Pathname` of a YAML file containing a kubernetes configmap")
 	_ = cmd.RegisterFlagCompletionFunc(configmapFlagName, completion.AutocompleteDefault)
@@ -274,6 +284,15 @@ func play(cmd *cobra.Command, args []string) error {
 		return errors.New("--force may be specified only with --down")
 	}
 
+	if playOptions.watchConfigs {
+		if !playOptions.Wait {
+			return errors.New("--watch-configs may be specified only with --wait")
+		}
+		if args[0] == "-" || parse.ValidURL(args[0]) == nil {
+			return errors.New("--watch-configs requires the YAML to be read from a file")
+		}
+	}
+
 	reader, err := readerFromArg(args[0])
 	if err != nil {
 		return err
@@ -325,6 +344,11 @@ func play(cmd *cobra.Command, args []string) error {
 			}
 		}()
 	}
+	if playOptions.watchConfigs {
+		stopWatch := make(chan struct{})
+		defer close(stopWatch)
+		go watchConfigs(append([]string{args[0]}, playOptions.ConfigMaps...), stopWatch)
+	}
 
 	if playErr := kubeplay(reader); playErr != nil {
 		// FIXME: The cleanup logic below must be fixed to only remove
@@ -358,6 +382,67 @@ func play(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// watchConfigs polls the modification times of the given files and refreshes
+// the ConfigMap and Secret volumes of the played pods whenever one of them
+// changes until stop is closed.
+func watchConfigs(files []string, stop <-chan struct{}) {
+	modTimes := func() map[string]time.Time {
+		times := make(map[string]time.Time, len(files))
+		for _, f := range files {
+			if info, err := os.Stat(f); err == nil {
+				times[f] = info.ModTime()
+			}
+		}
+		return times
+	}
+
+	last := modTimes()
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		current := modTimes()
+		if reflect.DeepEqual(last, current) {
+			continue
+		}
+		last = current
+		if err := refreshConfigs(files[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: refreshing configs: %v\n", err)
+		}
+	}
+}
+
+// refreshConfigs updates the ConfigMap and Secret volumes of the pods played
+// from fileName and prints the changed volumes and restarted containers.
+func refreshConfigs(fileName string) error {
+	reader, err := readerFromArg(fileName)
+	if err != nil {
+		return err
+	}
+	options := playOptions.PlayKubeOptions
+	options.RefreshConfigs = true
+	report, err := registry.ContainerEngine().PlayKube(registry.GetContext(), reader, options)
+	if err != nil {
+		return err
+	}
+	for _, volume := range report.Volumes {
+		fmt.Printf("Volume refreshed: %s\n", volume.Name)
+	}
+	for _, pod := range report.Pods {
+		for _, ctr := range pod.Containers {
+			fmt.Printf("Container restarted: %s\n", ctr)
+		}
+		for _, ctrErr := range pod.ContainerErrors {
+			fmt.Fprintln(os.Stderr, ctrErr)
+		}
+	}
+	return nil
+}
+
 func playKube(cmd *cobra.Command, args []string) error {
 	return play(cmd, args)
 }
@@ -30,11 +30,18 @@ var (
 	}
 )
 
+var client9pOptions string
+
 func init() {
 	registry.Commands = append(registry.Commands, registry.CliCommand{
 		Command: client9pCommand,
 		Parent:  machineCmd,
 	})
+
+	flags := client9pCommand.Flags()
+	optionsFlagName := "options"
+	flags.StringVar(&client9pOptions, optionsFlagName, "", "Comma-separated options of the 9p mount, e.g. msize=131072,cache=mmap,ro")
+	_ = client9pCommand.RegisterFlagCompletionFunc(optionsFlagName, completion.AutocompleteNone)
 }
 
 func remoteDirClient(cmd *cobra.Command, args []string) error {
@@ -43,7 +50,7 @@ func remoteDirClient(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("error parsing port number: %w", err)
 	}
 
-	if err := client9p(uint32(port), args[1]); err != nil {
+	if err := client9p(uint32(port), args[1], client9pOptions); err != nil {
 		return err
 	}
 
@@ -52,7 +59,7 @@ func remoteDirClient(cmd *cobra.Command, args []string) error {
 
 // This is Linux-only as we only intend for this function to be used inside the
 // `podman machine` VM, which is guaranteed to be Linux.
-func client9p(portNum uint32, mountPath string) error {
+func client9p(portNum uint32, mountPath, options string) error {
 	cleanPath, err := filepath.Abs(mountPath)
 	if err != nil {
 		return fmt.Errorf("absolute path for %s: %w", mountPath, err)
@@ -116,7 +123,11 @@ func client9p(portNum uint32, mountPath string) error {
 
 		// This is ugly, but it lets us use real kernel mount code,
 		// instead of maintaining our own FUSE 9p implementation.
-		cmd := exec.Command("mount", "-t", "9p", "-o", "trans=fd,rfdno=3,wfdno=3,version=9p2000.L", "9p", mountPath)
+		mountOptions := "trans=fd,rfdno=3,wfdno=3,version=9p2000.L"
+		if options != "" {
+			mountOptions += "," + options
+		}
+		cmd := exec.Command("mount", "-t", "9p", "-o", mountOptions, "9p", mountPath)
 		cmd.ExtraFiles = []*os.File{vsock}
 
 		output, err := cmd.CombinedOutput()
@@ -0,0 +1,80 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"fmt"
+
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	ldefine "github.com/containers/podman/v5/libpod/define"
+	"github.com/containers/podman/v5/libpod/events"
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/shim"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/spf13/cobra"
+)
+
+var cloneCmd = &cobra.Command{
+	Use:               "clone SOURCE DESTINATION",
+	Short:             "Clone an existing machine",
+	Long:              "Create a new virtual machine from the disk and configuration of a stopped machine",
+	PersistentPreRunE: machinePreRunE,
+	RunE:              clone,
+	Args:              cobra.ExactArgs(2),
+	Example:           `podman machine clone podman-machine-default template`,
+	ValidArgsFunction: autocompleteMachineClone,
+}
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: cloneCmd,
+		Parent:  machineCmd,
+	})
+}
+
+// autocompleteMachineClone completes the source machine only
+func autocompleteMachineClone(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return getMachines(toComplete)
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+// checkMachineName returns an error unless name can be given to a new machine
+func checkMachineName(name string) error {
+	if len(name) > maxMachineNameSize {
+		return fmt.Errorf("machine name %q must be %d characters or less", name, maxMachineNameSize)
+	}
+	if !ldefine.NameRegex.MatchString(name) {
+		return fmt.Errorf("invalid name %q: %w", name, ldefine.RegexError)
+	}
+	// The vmtype names need to be reserved and cannot be used for podman machine names
+	if _, err := define.ParseVMType(name, define.UnknownVirt); err == nil {
+		return fmt.Errorf("cannot use %q for a machine name", name)
+	}
+	return nil
+}
+
+func clone(_ *cobra.Command, args []string) error {
+	srcName, name := args[0], args[1]
+	if err := checkMachineName(name); err != nil {
+		return err
+	}
+
+	dirs, err := machine.GetMachineDirs(provider.VMType())
+	if err != nil {
+		return err
+	}
+	src, err := vmconfigs.LoadMachineByName(srcName, dirs)
+	if err != nil {
+		return err
+	}
+
+	if _, err := shim.Clone(src, provider, name); err != nil {
+		return err
+	}
+	newMachineEvent(events.Init, events.Event{Name: name})
+	fmt.Printf("Machine %q cloned to %q\n", srcName, name)
+	return nil
+}
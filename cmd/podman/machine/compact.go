@@ -0,0 +1,52 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"fmt"
+
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/shim"
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+var compactCmd = &cobra.Command{
+	Use:               "compact [NAME]",
+	Short:             "Reclaim the free space of the disk of a machine",
+	Long:              "Free the space of the disk image of a machine that the guest does not use. A running machine discards the free space of its file systems first, then is restarted for the compaction.",
+	PersistentPreRunE: machinePreRunE,
+	RunE:              compact,
+	Args:              cobra.MaximumNArgs(1),
+	Example:           `podman machine compact podman-machine-default`,
+	ValidArgsFunction: autocompleteMachine,
+}
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: compactCmd,
+		Parent:  machineCmd,
+	})
+}
+
+func compact(_ *cobra.Command, args []string) error {
+	mc, err := loadMachineArg(args, 0)
+	if err != nil {
+		return err
+	}
+	dirs, err := machine.GetMachineDirs(provider.VMType())
+	if err != nil {
+		return err
+	}
+
+	report, err := shim.Compact(mc, provider, dirs)
+	if err != nil {
+		return err
+	}
+	if !report.Trimmed {
+		fmt.Printf("The free space of the file systems of machine %q was not discarded first, compact the running machine to reclaim more\n", mc.Name)
+	}
+	fmt.Printf("Disk of machine %q compacted from %s to %s\n", mc.Name, units.BytesSize(float64(report.Before)), units.BytesSize(float64(report.After)))
+	return nil
+}
@@ -0,0 +1,64 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/shim"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var consoleCmd = &cobra.Command{
+	Use:               "console [NAME]",
+	Short:             "Attach to the serial console of a virtual machine",
+	Long:              "Attach to the serial console of a running virtual machine, which works before the machine can be reached over SSH",
+	PersistentPreRunE: machinePreRunE,
+	RunE:              console,
+	Args:              cobra.MaximumNArgs(1),
+	Example:           `podman machine console podman-machine-default`,
+	ValidArgsFunction: autocompleteMachine,
+}
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: consoleCmd,
+		Parent:  machineCmd,
+	})
+}
+
+func console(_ *cobra.Command, args []string) error {
+	vmName := defaultMachineName
+	if len(args) > 0 && len(args[0]) > 0 {
+		vmName = args[0]
+	}
+	dirs, err := machine.GetMachineDirs(provider.VMType())
+	if err != nil {
+		return err
+	}
+	mc, err := vmconfigs.LoadMachineByName(vmName, dirs)
+	if err != nil {
+		return err
+	}
+
+	// pass the keys through to the guest, the console detaches on Ctrl-]
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("unable to put the terminal in raw mode: %w", err)
+		}
+		defer func() {
+			if err := term.Restore(fd, state); err != nil {
+				logrus.Errorf("Unable to restore the terminal: %v", err)
+			}
+		}()
+	}
+	fmt.Fprintf(os.Stderr, "Connected to the console of machine %q, press Ctrl-] to detach\r\n", mc.Name)
+	return shim.Console(mc, provider, os.Stdin, os.Stdout)
+}
@@ -0,0 +1,105 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"fmt"
+
+	"github.com/containers/common/pkg/completion"
+	"github.com/containers/common/pkg/report"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/machine/shim"
+	"github.com/spf13/cobra"
+)
+
+var (
+	doctorDescription = `Check the machines, their files and processes and the system connections of the host for inconsistencies.
+
+  With --repair, the problems that can be repaired are repaired.`
+
+	doctorCmd = &cobra.Command{
+		Use:               "doctor [options] [NAME...]",
+		Short:             "Check machines for problems and repair them",
+		Long:              doctorDescription,
+		PersistentPreRunE: machinePreRunE,
+		RunE:              doctor,
+		Example: `podman machine doctor
+  podman machine doctor --repair podman-machine-default`,
+		ValidArgsFunction: autocompleteMachine,
+	}
+)
+
+var doctorOpts = struct {
+	format string
+	repair bool
+}{}
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: doctorCmd,
+		Parent:  machineCmd,
+	})
+
+	flags := doctorCmd.Flags()
+	formatFlagName := "format"
+	flags.StringVar(&doctorOpts.format, formatFlagName, "", "Change the output format to JSON")
+	_ = doctorCmd.RegisterFlagCompletionFunc(formatFlagName, completion.AutocompleteDefault)
+
+	flags.BoolVar(&doctorOpts.repair, "repair", false, "Repair the problems that can be repaired")
+}
+
+func doctor(_ *cobra.Command, args []string) error {
+	if doctorOpts.format != "" && !report.IsJSON(doctorOpts.format) {
+		return fmt.Errorf("unsupported format %q, only json is supported", doctorOpts.format)
+	}
+	findings, err := shim.Doctor(allProviders(), shim.DoctorOptions{Names: args, Repair: doctorOpts.repair})
+	if err != nil {
+		return err
+	}
+
+	unresolved := false
+	for _, f := range findings {
+		if !f.Repaired {
+			unresolved = true
+		}
+	}
+	if unresolved {
+		registry.SetExitCode(1)
+	}
+
+	if report.IsJSON(doctorOpts.format) {
+		if findings == nil {
+			findings = []*shim.DoctorFinding{}
+		}
+		b, err := json.MarshalIndent(findings, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	if len(findings) == 0 {
+		fmt.Println("No problems found")
+		return nil
+	}
+	for _, f := range findings {
+		subject := "host"
+		if f.Machine != "" {
+			subject = f.Machine
+		}
+		var outcome string
+		switch {
+		case f.Repaired:
+			outcome = "repaired: " + f.Repair
+		case f.RepairError != "":
+			outcome = fmt.Sprintf("repair failed: %s: %s", f.Repair, f.RepairError)
+		case f.Repair != "":
+			outcome = "run with --repair to " + f.Repair
+		default:
+			outcome = "repair by hand"
+		}
+		fmt.Printf("[%s] %s: %s (%s)\n", subject, f.Check, f.Problem, outcome)
+	}
+	return nil
+}
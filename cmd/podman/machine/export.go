@@ -0,0 +1,92 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/containers/common/pkg/completion"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/shim"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	exportCmd = &cobra.Command{
+		Use:               "export [options] [NAME]",
+		Short:             "Export a machine to an archive",
+		Long:              "Write the disk image, configuration and SSH identity of a stopped machine to an archive, which podman machine import turns into the same machine on another host",
+		PersistentPreRunE: machinePreRunE,
+		RunE:              export,
+		Args:              cobra.MaximumNArgs(1),
+		Example:           `podman machine export -o podman-machine-default.tar.zst`,
+		ValidArgsFunction: autocompleteMachine,
+	}
+
+	exportOpts   = define.ExportOptions{}
+	exportOutput string
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: exportCmd,
+		Parent:  machineCmd,
+	})
+	flags := exportCmd.Flags()
+
+	outputFlagName := "output"
+	flags.StringVarP(&exportOutput, outputFlagName, "o", "", "Write to a specified file (default: stdout, which must be redirected)")
+	_ = exportCmd.RegisterFlagCompletionFunc(outputFlagName, completion.AutocompleteDefault)
+
+	compressFlagName := "compress"
+	flags.StringVar(&exportOpts.Compression, compressFlagName, define.CompressionZstd, "Compress the archive with none, gzip or zstd")
+	_ = exportCmd.RegisterFlagCompletionFunc(compressFlagName, autocompleteCompression)
+
+	flags.BoolVar(&exportOpts.ExcludeCache, "exclude-cache", false, "Leave out the disk image kept by os upgrade and the snapshots kept apart from the disk image")
+}
+
+func autocompleteCompression(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return []string{define.CompressionNone, define.CompressionGzip, define.CompressionZstd}, cobra.ShellCompDirectiveNoFileComp
+}
+
+func export(_ *cobra.Command, args []string) (retErr error) {
+	mc, err := loadMachineArg(args, 0)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if exportOutput == "" {
+		if term.IsTerminal(int(os.Stdout.Fd())) {
+			return errors.New("refusing to export to terminal. Use -o flag or redirect")
+		}
+	} else {
+		// the archive holds the private SSH key of the machine
+		f, err := os.OpenFile(exportOutput, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := f.Close(); err != nil && retErr == nil {
+				retErr = err
+			}
+			if retErr != nil {
+				_ = os.Remove(exportOutput)
+			}
+		}()
+		w = f
+	}
+
+	if err := shim.Export(mc, provider, w, exportOpts); err != nil {
+		return err
+	}
+	if exportOutput != "" {
+		fmt.Printf("Machine %q exported to %s\n", mc.Name, exportOutput)
+	}
+	return nil
+}
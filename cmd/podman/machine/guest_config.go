@@ -0,0 +1,53 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"github.com/containers/common/pkg/completion"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/spf13/cobra"
+)
+
+const (
+	containersConfFlagName  = "containers-conf"
+	registriesConfFlagName  = "registries-conf"
+	signaturePolicyFlagName = "signature-policy"
+)
+
+// guestConfigFlags are the configuration files of the guest given on the
+// command line
+type guestConfigFlags struct {
+	containersConf  string
+	registriesConf  string
+	signaturePolicy string
+}
+
+// addGuestConfigFlags adds the flags setting the configuration files of the
+// guest to cmd
+func addGuestConfigFlags(cmd *cobra.Command, f *guestConfigFlags) {
+	flags := cmd.Flags()
+	flags.StringVar(&f.containersConf, containersConfFlagName, "",
+		"containers.conf fragment copied into the machine")
+	flags.StringVar(&f.registriesConf, registriesConfFlagName, "",
+		"registries.conf fragment copied into the machine, e.g. with registry mirrors")
+	flags.StringVar(&f.signaturePolicy, signaturePolicyFlagName, "",
+		"policy.json replacing the signature policy of the machine")
+	for _, name := range []string{containersConfFlagName, registriesConfFlagName, signaturePolicyFlagName} {
+		_ = cmd.RegisterFlagCompletionFunc(name, completion.AutocompleteDefault)
+	}
+}
+
+// options returns the configuration files of the flags that were given
+func (f *guestConfigFlags) options(cmd *cobra.Command) define.GuestConfigOptions {
+	var opts define.GuestConfigOptions
+	if cmd.Flags().Changed(containersConfFlagName) {
+		opts.ContainersConf = &f.containersConf
+	}
+	if cmd.Flags().Changed(registriesConfFlagName) {
+		opts.RegistriesConf = &f.registriesConf
+	}
+	if cmd.Flags().Changed(signaturePolicyFlagName) {
+		opts.SignaturePolicy = &f.signaturePolicy
+	}
+	return opts
+}
@@ -0,0 +1,58 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"github.com/containers/common/pkg/completion"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/spf13/cobra"
+)
+
+const (
+	hostPreStartHookFlagName  = "host-pre-start-hook"
+	hostPostStartHookFlagName = "host-post-start-hook"
+	hostPreStopHookFlagName   = "host-pre-stop-hook"
+	hostPostStopHookFlagName  = "host-post-stop-hook"
+)
+
+// hostHookFlags are the host hooks given on the command line
+type hostHookFlags struct {
+	preStart  []string
+	postStart []string
+	preStop   []string
+	postStop  []string
+}
+
+// addHostHookFlags adds the flags setting the host hooks of a machine to cmd
+func addHostHookFlags(cmd *cobra.Command, f *hostHookFlags) {
+	flags := cmd.Flags()
+	flags.StringArrayVar(&f.preStart, hostPreStartHookFlagName, []string{},
+		"Command run on the host before the machine is started, prefix with \"required:\" to abort the start on failure")
+	flags.StringArrayVar(&f.postStart, hostPostStartHookFlagName, []string{},
+		"Command run on the host every time the machine has started")
+	flags.StringArrayVar(&f.preStop, hostPreStopHookFlagName, []string{},
+		"Command run on the host before the machine is stopped, prefix with \"required:\" to abort the stop on failure")
+	flags.StringArrayVar(&f.postStop, hostPostStopHookFlagName, []string{},
+		"Command run on the host once the machine has stopped")
+	for _, name := range []string{hostPreStartHookFlagName, hostPostStartHookFlagName, hostPreStopHookFlagName, hostPostStopHookFlagName} {
+		_ = cmd.RegisterFlagCompletionFunc(name, completion.AutocompleteNone)
+	}
+}
+
+// options returns the host hooks of the flags that were given
+func (f *hostHookFlags) options(cmd *cobra.Command) define.HostHookOptions {
+	var opts define.HostHookOptions
+	if cmd.Flags().Changed(hostPreStartHookFlagName) {
+		opts.PreStart = &f.preStart
+	}
+	if cmd.Flags().Changed(hostPostStartHookFlagName) {
+		opts.PostStart = &f.postStart
+	}
+	if cmd.Flags().Changed(hostPreStopHookFlagName) {
+		opts.PreStop = &f.preStop
+	}
+	if cmd.Flags().Changed(hostPostStopHookFlagName) {
+		opts.PostStop = &f.postStop
+	}
+	return opts
+}
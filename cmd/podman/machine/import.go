@@ -0,0 +1,71 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/containers/common/pkg/completion"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/libpod/events"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/shim"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:               "import FILE [NAME]",
+	Short:             "Import a machine from an archive",
+	Long:              "Create a machine from an archive written by podman machine export, read from FILE or from stdin when FILE is -",
+	PersistentPreRunE: machinePreRunE,
+	RunE:              importMachine,
+	Args:              cobra.RangeArgs(1, 2),
+	Example:           `podman machine import podman-machine-default.tar.zst`,
+	ValidArgsFunction: autocompleteMachineImport,
+}
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: importCmd,
+		Parent:  machineCmd,
+	})
+}
+
+// autocompleteMachineImport completes the archive only
+func autocompleteMachineImport(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completion.AutocompleteDefault(cmd, args, toComplete)
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+func importMachine(_ *cobra.Command, args []string) error {
+	var opts define.ImportOptions
+	if len(args) > 1 {
+		opts.Name = args[1]
+		if err := checkMachineName(opts.Name); err != nil {
+			return err
+		}
+	}
+
+	var r io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	mc, err := shim.Import(r, provider, opts)
+	if err != nil {
+		return err
+	}
+	newMachineEvent(events.Init, events.Event{Name: mc.Name})
+	fmt.Printf("Machine %q imported\n", mc.Name)
+	fmt.Printf("To start your machine run:\n\n\tpodman machine start %s\n\n", mc.Name)
+	return nil
+}
@@ -37,7 +37,8 @@ var (
 )
 
 var (
-	inFormat string
+	inFormat      string
+	showProviders bool
 )
 
 func init() {
@@ -50,6 +51,8 @@ func init() {
 	formatFlagName := "format"
 	flags.StringVarP(&inFormat, formatFlagName, "f", "", "Change the output format to JSON or a Go template")
 	_ = infoCmd.RegisterFlagCompletionFunc(formatFlagName, common.AutocompleteFormat(&entities.MachineInfo{}))
+
+	flags.BoolVar(&showProviders, "providers", false, "Show the virtualization providers of the host and the features they support")
 }
 
 func info(cmd *cobra.Command, args []string) error {
@@ -66,6 +69,10 @@ func info(cmd *cobra.Command, args []string) error {
 	}
 	info.Host = host
 
+	if showProviders {
+		info.Providers = providersInfo()
+	}
+
 	switch {
 	case report.IsJSON(inFormat):
 		b, err := json.MarshalIndent(info, "", "  ")
@@ -94,6 +101,20 @@ func info(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// providersInfo describes the providers supported on the host, the one in
+// use first
+func providersInfo() []*entities.MachineProviderInfo {
+	var providers []*entities.MachineProviderInfo
+	for _, p := range allProviders() {
+		providers = append(providers, &entities.MachineProviderInfo{
+			Capabilities: p.Capabilities(),
+			Default:      p.VMType() == provider.VMType(),
+			VMType:       p.VMType().String(),
+		})
+	}
+	return providers
+}
+
 func hostInfo() (*entities.MachineHostInfo, error) {
 	host := entities.MachineHostInfo{}
 
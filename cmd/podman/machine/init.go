@@ -3,6 +3,7 @@
 package machine
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -31,6 +32,8 @@ var (
 
 	initOpts           = define.InitOptions{}
 	initOptionalFlags  = InitOptionalFlags{}
+	initHostHooks      = hostHookFlags{}
+	initGuestConfig    = guestConfigFlags{}
 	defaultMachineName = machine.DefaultMachineName
 	now                bool
 )
@@ -38,6 +41,7 @@ var (
 // Flags which have a meaning when unspecified that differs from the flag default
 type InitOptionalFlags struct {
 	UserModeNetworking bool
+	Provisioner        string
 }
 
 // maxMachineNameSize is set to thirty to limit huge machine names primarily
@@ -109,10 +113,20 @@ func init() {
 	flags.StringArrayVarP(&initOpts.Volumes, VolumeFlagName, "v", cfg.ContainersConfDefaultsRO.Machine.Volumes.Get(), "Volumes to mount, source:target")
 	_ = initCmd.RegisterFlagCompletionFunc(VolumeFlagName, completion.AutocompleteDefault)
 
+	gpusFlagName := "gpus"
+	flags.StringArrayVar(&initOpts.GPUs, gpusFlagName, []string{},
+		"GPUs the machine can use: all or the PCI addresses of host GPUs bound to vfio-pci")
+	_ = initCmd.RegisterFlagCompletionFunc(gpusFlagName, completion.AutocompleteNone)
+
+	publishFlagName := "publish"
+	flags.StringArrayVar(&initOpts.Ports, publishFlagName, []string{},
+		"Publish a port of the machine on the host: [ip:][hostPort:]guestPort[/protocol]")
+	_ = initCmd.RegisterFlagCompletionFunc(publishFlagName, completion.AutocompleteNone)
+
 	USBFlagName := "usb"
 	flags.StringArrayVarP(&initOpts.USBs, USBFlagName, "", []string{},
-		"USB Host passthrough: bus=$1,devnum=$2 or vendor=$1,product=$2")
-	_ = initCmd.RegisterFlagCompletionFunc(USBFlagName, completion.AutocompleteDefault)
+		"USB Host passthrough: bus=$1,devnum=$2, vendor=$1,product=$2 or vendor:product")
+	_ = initCmd.RegisterFlagCompletionFunc(USBFlagName, autocompleteUSB)
 
 	VolumeDriverFlagName := "volume-driver"
 	flags.StringVar(&initOpts.VolumeDriver, VolumeDriverFlagName, "", "Optional volume driver")
@@ -122,12 +136,63 @@ func init() {
 	flags.StringVar(&initOpts.IgnitionPath, IgnitionPathFlagName, "", "Path to ignition file")
 	_ = initCmd.RegisterFlagCompletionFunc(IgnitionPathFlagName, completion.AutocompleteDefault)
 
+	initScriptFlagName := "init-script"
+	flags.StringArrayVar(&initOpts.InitScripts, initScriptFlagName, []string{},
+		"Script run as root on the first boot of the machine, can be specified multiple times")
+	_ = initCmd.RegisterFlagCompletionFunc(initScriptFlagName, completion.AutocompleteDefault)
+
+	unitFlagName := "unit"
+	flags.StringArrayVar(&initOpts.Units, unitFlagName, []string{},
+		"Systemd unit file installed in the machine, can be specified multiple times")
+	_ = initCmd.RegisterFlagCompletionFunc(unitFlagName, completion.AutocompleteDefault)
+
+	provisionerFlagName := "provisioner"
+	flags.StringVar(&initOptionalFlags.Provisioner, provisionerFlagName, "", "Provision the machine with ignition or cloud-init, picked from the image by default")
+	_ = initCmd.RegisterFlagCompletionFunc(provisionerFlagName, autocompleteProvisioner)
+
+	preStopHookFlagName := "pre-stop-hook"
+	flags.StringArrayVar(&initOpts.PreStopHooks, preStopHookFlagName, []string{},
+		"Command run in the machine before it is stopped, prefix with \"required:\" to abort the stop on failure")
+	_ = initCmd.RegisterFlagCompletionFunc(preStopHookFlagName, completion.AutocompleteNone)
+
+	postStartHookFlagName := "post-start-hook"
+	flags.StringArrayVar(&initOpts.PostStartHooks, postStartHookFlagName, []string{},
+		"Command run in the machine every time it has started")
+	_ = initCmd.RegisterFlagCompletionFunc(postStartHookFlagName, completion.AutocompleteNone)
+
+	firstBootHookFlagName := "first-boot-hook"
+	flags.StringArrayVar(&initOpts.FirstBootHooks, firstBootHookFlagName, []string{},
+		"Command run in the machine once, after it has started for the first time")
+	_ = initCmd.RegisterFlagCompletionFunc(firstBootHookFlagName, completion.AutocompleteNone)
+
+	addHostHookFlags(initCmd, &initHostHooks)
+	addGuestConfigFlags(initCmd, &initGuestConfig)
+
+	restartFlagName := "restart"
+	flags.StringVar(&initOpts.RestartPolicy, restartFlagName, string(define.RestartNo),
+		"Restart policy of the machine: no, network (restart gvproxy) or always (restart gvproxy and the machine)")
+	_ = initCmd.RegisterFlagCompletionFunc(restartFlagName, autocompleteRestartPolicy)
+
+	clockSyncFlagName := "clock-sync"
+	flags.BoolVar(&initOpts.ClockSync, clockSyncFlagName, true, "Step the clock of the running machine when it drifts from the host, e.g. after the host slept")
+
 	rootfulFlagName := "rootful"
 	flags.BoolVar(&initOpts.Rootful, rootfulFlagName, false, "Whether this machine should prefer rootful container execution")
 
+	secureBootFlagName := "secure-boot"
+	flags.BoolVar(&initOpts.SecureBoot, secureBootFlagName, false, "Boot the machine with UEFI firmware enforcing secure boot")
+
+	tpmFlagName := "tpm"
+	flags.BoolVar(&initOpts.TPM, tpmFlagName, false, "Give the machine an emulated TPM 2.0 device")
+
 	userModeNetFlagName := "user-mode-networking"
 	flags.BoolVar(&initOptionalFlags.UserModeNetworking, userModeNetFlagName, false,
 		"Whether this machine should use user-mode networking, routing traffic through a host user-space process")
+
+	wslNetworkingFlagName := "wsl-networking"
+	flags.StringVar(&initOpts.WSLNetworking, wslNetworkingFlagName, "",
+		"Networking mode WSL is switched to when the machine starts: nat or mirrored")
+	_ = initCmd.RegisterFlagCompletionFunc(wslNetworkingFlagName, autocompleteWSLNetworking)
 }
 
 func initMachine(cmd *cobra.Command, args []string) error {
@@ -186,6 +251,12 @@ func initMachine(cmd *cobra.Command, args []string) error {
 	if cmd.Flags().Changed("user-mode-networking") {
 		initOpts.UserModeNetworking = &initOptionalFlags.UserModeNetworking
 	}
+	initOpts.HostHooks = initHostHooks.options(cmd)
+	initOpts.GuestConfig = initGuestConfig.options(cmd)
+	initOpts.Provisioner, err = define.ParseProvisioner(initOptionalFlags.Provisioner)
+	if err != nil {
+		return err
+	}
 
 	// TODO need to work this back in
 	// if finished, err := vm.Init(initOpts); err != nil || !finished {
@@ -199,11 +270,19 @@ func initMachine(cmd *cobra.Command, args []string) error {
 	// 	return err
 	// }
 
-	mc, err := shim.Init(initOpts, provider)
-	if err != nil {
+	initOpts.StartAfterInit = now
+	mc, report, err := shim.Init(initOpts, provider)
+	var startErr *define.ErrStartAfterInit
+	if err != nil && !errors.As(err, &startErr) {
 		return err
 	}
 
+	// A machine given an ignition file is left to it, there is no
+	// configuration to write
+	if mc == nil {
+		return nil
+	}
+
 	// TODO callback needed for the configuration file
 	if err := mc.Write(); err != nil {
 		return err
@@ -212,8 +291,13 @@ func initMachine(cmd *cobra.Command, args []string) error {
 	newMachineEvent(events.Init, events.Event{Name: initOpts.Name})
 	fmt.Println("Machine init complete")
 
+	if startErr != nil {
+		return startErr
+	}
 	if now {
-		return start(cmd, args)
+		fmt.Printf("Machine %q started successfully\n", initOpts.Name)
+		newMachineEvent(events.Start, events.Event{Name: initOpts.Name, Details: events.Details{Attributes: report.Attributes()}})
+		return nil
 	}
 	extra := ""
 	if initOpts.Name != defaultMachineName {
@@ -222,3 +306,20 @@ func initMachine(cmd *cobra.Command, args []string) error {
 	fmt.Printf("To start your machine run:\n\n\tpodman machine start%s\n\n", extra)
 	return err
 }
+
+// autocompleteProvisioner completes the provisioners a machine can use
+func autocompleteProvisioner(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	provisioners := make([]string, 0, len(define.Provisioners))
+	for _, p := range define.Provisioners {
+		provisioners = append(provisioners, p.String())
+	}
+	return provisioners, cobra.ShellCompDirectiveNoFileComp
+}
+
+func autocompleteWSLNetworking(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return []string{string(define.WSLNetworkingNAT), string(define.WSLNetworkingMirrored)}, cobra.ShellCompDirectiveNoFileComp
+}
+
+func autocompleteRestartPolicy(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return []string{string(define.RestartNo), string(define.RestartNetwork), string(define.RestartAlways)}, cobra.ShellCompDirectiveNoFileComp
+}
@@ -10,7 +10,10 @@ import (
 	"github.com/containers/podman/v5/cmd/podman/registry"
 	"github.com/containers/podman/v5/cmd/podman/utils"
 	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/shim"
 	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -73,20 +76,36 @@ func inspect(cmd *cobra.Command, args []string) error {
 			return err
 		}
 
+		secondarySocket, secondaryPipe, err := mc.SecondaryConnectionInfo(provider.VMType())
+		if err != nil {
+			return err
+		}
+
 		ii := machine.InspectInfo{
 			ConfigDir: *dirs.ConfigDir,
 			ConnectionInfo: machine.ConnectionConfig{
-				PodmanSocket: podmanSocket,
-				PodmanPipe:   podmanPipe,
+				PodmanSocket:          podmanSocket,
+				PodmanPipe:            podmanPipe,
+				SecondaryPodmanSocket: secondarySocket,
+				SecondaryPodmanPipe:   secondaryPipe,
 			},
 			Created:            mc.Created,
 			LastUp:             mc.LastUp,
 			Name:               mc.Name,
 			Resources:          mc.Resources,
+			Network:            mc.Network,
 			SSHConfig:          mc.SSH,
 			State:              state,
 			UserModeNetworking: provider.UserModeNetworkEnabled(mc),
 			Rootful:            mc.HostUser.Rootful,
+			LastError:          mc.LastError,
+			Degraded:           mc.Degraded,
+		}
+		if state == define.Running {
+			// providers that cannot measure the machine leave it out
+			if ii.Stats, err = shim.Stats(mc, provider, nil); err != nil {
+				logrus.Debugf("Unable to measure the usage of machine %q: %v", mc.Name, err)
+			}
 		}
 
 		vms = append(vms, ii)
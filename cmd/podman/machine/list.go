@@ -34,15 +34,17 @@ var (
 		ValidArgsFunction: completion.AutocompleteNone,
 		Example: `podman machine list,
   podman machine list --format json
+  podman machine list --all-providers
   podman machine ls`,
 	}
 	listFlag = listFlagType{}
 )
 
 type listFlagType struct {
-	format    string
-	noHeading bool
-	quiet     bool
+	allProviders bool
+	format       string
+	noHeading    bool
+	quiet        bool
 }
 
 func init() {
@@ -57,6 +59,7 @@ func init() {
 	_ = lsCmd.RegisterFlagCompletionFunc(formatFlagName, common.AutocompleteFormat(&entities.ListReporter{}))
 	flags.BoolVarP(&listFlag.noHeading, "noheading", "n", false, "Do not print headers")
 	flags.BoolVarP(&listFlag.quiet, "quiet", "q", false, "Show only machine names")
+	flags.BoolVar(&listFlag.allProviders, "all-providers", false, "List the machines of all providers, not only the default provider")
 }
 
 func list(cmd *cobra.Command, args []string) error {
@@ -65,7 +68,11 @@ func list(cmd *cobra.Command, args []string) error {
 		err  error
 	)
 
-	listResponse, err := shim.List([]vmconfigs.VMProvider{provider}, opts)
+	providers := []vmconfigs.VMProvider{provider}
+	if listFlag.allProviders {
+		providers = allProviders()
+	}
+	listResponse, err := shim.List(providers, opts)
 	if err != nil {
 		return err
 	}
@@ -102,11 +109,15 @@ func list(cmd *cobra.Command, args []string) error {
 
 func outputTemplate(cmd *cobra.Command, responses []*entities.ListReporter) error {
 	headers := report.Headers(entities.ListReporter{}, map[string]string{
-		"LastUp":   "LAST UP",
-		"VmType":   "VM TYPE",
-		"CPUs":     "CPUS",
-		"Memory":   "MEMORY",
-		"DiskSize": "DISK SIZE",
+		"LastUp":          "LAST UP",
+		"LastError":       "LAST ERROR",
+		"Health":          "HEALTH",
+		"VmType":          "VM TYPE",
+		"CPUs":            "CPUS",
+		"Memory":          "MEMORY",
+		"DiskSize":        "DISK SIZE",
+		"ConfigPath":      "CONFIG PATH",
+		"DefaultProvider": "DEFAULT PROVIDER",
 	})
 
 	rpt := report.New(os.Stdout, cmd.Name())
@@ -171,6 +182,13 @@ func toMachineFormat(vms []*machine.ListResponse, defaultCon string) []*entities
 		response.IdentityPath = vm.IdentityPath
 		response.Starting = vm.Starting
 		response.UserModeNetworking = vm.UserModeNetworking
+		response.Health = string(vm.Health)
+		response.State = vm.State
+		response.ConfigPath = vm.ConfigPath
+		response.DefaultProvider = vm.VMType == provider.VMType().String()
+		if vm.LastError != nil {
+			response.LastError = fmt.Sprintf("%s: %s", vm.LastError.Operation, vm.LastError.Error)
+		}
 
 		machineResponses = append(machineResponses, response)
 	}
@@ -199,11 +217,19 @@ func toHumanFormat(vms []*machine.ListResponse, defaultCon string) []*entities.L
 		default:
 			response.LastUp = units.HumanDuration(time.Since(vm.LastUp)) + " ago"
 		}
+		if vm.LastError != nil {
+			response.LastUp += fmt.Sprintf(" (last %s failed)", vm.LastError.Operation)
+			response.LastError = vm.LastError.Error
+		}
 		response.Created = units.HumanDuration(time.Since(vm.CreatedAt)) + " ago"
 		response.VMType = vm.VMType
 		response.CPUs = vm.CPUs
 		response.Memory = units.BytesSize(float64(vm.Memory))
 		response.DiskSize = units.BytesSize(float64(vm.DiskSize))
+		response.Health = string(vm.Health)
+		response.State = vm.State
+		response.ConfigPath = vm.ConfigPath
+		response.DefaultProvider = vm.VMType == provider.VMType().String()
 
 		humanResponses = append(humanResponses, response)
 	}
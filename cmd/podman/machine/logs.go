@@ -0,0 +1,59 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"os"
+
+	"github.com/containers/common/pkg/completion"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/shim"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logsCmd = &cobra.Command{
+		Use:               "logs [options] [NAME]",
+		Short:             "Show the journal of a machine",
+		Long:              "Show the systemd journal of a running machine, or the console log of its last boot when it is not running",
+		PersistentPreRunE: machinePreRunE,
+		RunE:              logs,
+		Args:              cobra.MaximumNArgs(1),
+		Example: `podman machine logs
+  podman machine logs --unit podman.socket --since 10m podman-machine-default`,
+		ValidArgsFunction: autocompleteMachine,
+	}
+
+	logsOpts = define.LogsOptions{}
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: logsCmd,
+		Parent:  machineCmd,
+	})
+	flags := logsCmd.Flags()
+
+	flags.BoolVarP(&logsOpts.Follow, "follow", "f", false, "Follow the journal of the running machine")
+
+	sinceFlagName := "since"
+	flags.StringVar(&logsOpts.Since, sinceFlagName, "", "Show entries logged since the given time, e.g. \"2024-05-01 10:00\" or \"-10m\"")
+	_ = logsCmd.RegisterFlagCompletionFunc(sinceFlagName, completion.AutocompleteNone)
+
+	tailFlagName := "tail"
+	flags.IntVar(&logsOpts.Tail, tailFlagName, -1, "Output the specified number of LINES at the end of the logs.  Defaults to -1, which prints all lines")
+	_ = logsCmd.RegisterFlagCompletionFunc(tailFlagName, completion.AutocompleteNone)
+
+	unitFlagName := "unit"
+	flags.StringVarP(&logsOpts.Unit, unitFlagName, "u", "", "Show the entries of the given systemd unit only")
+	_ = logsCmd.RegisterFlagCompletionFunc(unitFlagName, completion.AutocompleteNone)
+}
+
+func logs(_ *cobra.Command, args []string) error {
+	mc, err := loadMachineArg(args, 0)
+	if err != nil {
+		return err
+	}
+	return shim.Logs(mc, provider, logsOpts, os.Stdout)
+}
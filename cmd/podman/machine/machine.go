@@ -51,6 +51,18 @@ func init() {
 	})
 }
 
+// allProviders returns the providers supported on the host, the one in use
+// first
+func allProviders() []vmconfigs.VMProvider {
+	providers := []vmconfigs.VMProvider{provider}
+	for _, p := range provider2.GetAll() {
+		if p.VMType() != provider.VMType() {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
 func machinePreRunE(c *cobra.Command, args []string) error {
 	var err error
 	provider, err = provider2.Get()
@@ -76,6 +88,34 @@ func autocompleteMachine(cmd *cobra.Command, args []string, toComplete string) (
 	return nil, cobra.ShellCompDirectiveNoFileComp
 }
 
+// autocompleteMachineAfterArg completes the machine name, which follows the
+// first argument, e.g. a snapshot name
+func autocompleteMachineAfterArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 1 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return autocompleteMachine(cmd, nil, toComplete)
+}
+
+// loadMachineArg loads the machine named by the argument at index i, or the
+// default machine
+func loadMachineArg(args []string, i int) (*vmconfigs.MachineConfig, error) {
+	vmName := defaultMachineName
+	if len(args) > i && len(args[i]) > 0 {
+		vmName = args[i]
+	}
+	dirs, err := machine.GetMachineDirs(provider.VMType())
+	if err != nil {
+		return nil, err
+	}
+	return vmconfigs.LoadMachineByName(vmName, dirs)
+}
+
+// AutocompleteMachine - Autocomplete machines in the subcommands of other packages.
+func AutocompleteMachine(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return autocompleteMachine(cmd, args, toComplete)
+}
+
 func getMachines(toComplete string) ([]string, cobra.ShellCompDirective) {
 	suggestions := []string{}
 	provider, err := provider2.Get()
@@ -0,0 +1,72 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/containers/common/pkg/completion"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/libpod/events"
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/shim"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	monitorCmd = &cobra.Command{
+		Use:               "monitor NAME",
+		Hidden:            true,
+		Short:             "Restart a machine according to its restart policy and sync its clock",
+		Long:              "Watch a running machine, restart gvproxy or the machine when they fail, according to the restart policy of the machine, and step its clock when it drifts from the host, e.g. after the host slept",
+		PersistentPreRunE: machinePreRunE,
+		RunE:              monitor,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completion.AutocompleteNone,
+		Example:           `podman machine monitor podman-machine-default`,
+	}
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: monitorCmd,
+		Parent:  machineCmd,
+	})
+}
+
+func monitor(_ *cobra.Command, args []string) error {
+	dirs, err := machine.GetMachineDirs(provider.VMType())
+	if err != nil {
+		return err
+	}
+	mc, err := vmconfigs.LoadMachineByName(args[0], dirs)
+	if err != nil {
+		return err
+	}
+	defer shim.ReleaseMonitor(mc)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	logrus.Infof("Monitoring machine %q with restart policy %q and clock sync %t", mc.Name, mc.RestartPolicy, mc.ClockSync)
+	return shim.Monitor(ctx, mc, provider, dirs, shim.MonitorOptions{
+		OnRestart: func(target string, err error) {
+			if err != nil {
+				return
+			}
+			newMachineEvent(events.Restart, events.Event{Name: mc.Name, Details: events.Details{Attributes: map[string]string{"target": target}}})
+		},
+		OnClockSync: func(skew time.Duration, err error) {
+			if err != nil {
+				return
+			}
+			newMachineEvent(events.Sync, events.Event{Name: mc.Name, Details: events.Details{Attributes: map[string]string{"skew": skew.String()}}})
+		},
+	})
+}
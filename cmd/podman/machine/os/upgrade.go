@@ -0,0 +1,69 @@
+//go:build amd64 || arm64
+
+package os
+
+import (
+	"errors"
+
+	"github.com/containers/podman/v5/cmd/podman/machine"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/cmd/podman/validate"
+	"github.com/containers/podman/v5/pkg/machine/os"
+	provider2 "github.com/containers/podman/v5/pkg/machine/provider"
+	"github.com/spf13/cobra"
+)
+
+var (
+	upgradeCmd = &cobra.Command{
+		Use:               "upgrade [options] [NAME]",
+		Short:             "Upgrade a Podman Machine's OS",
+		Long:              "Upgrade the OS of a Podman Machine to the latest release it follows, or roll it back",
+		PersistentPreRunE: validate.NoOp,
+		Args:              cobra.MaximumNArgs(1),
+		RunE:              upgrade,
+		ValidArgsFunction: machine.AutocompleteMachine,
+		Example: `podman machine os upgrade
+  podman machine os upgrade --check
+  podman machine os upgrade --rollback --restart`,
+	}
+)
+
+var upgradeOpts = os.UpgradeOptions{}
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: upgradeCmd,
+		Parent:  machine.OSCmd,
+	})
+	flags := upgradeCmd.Flags()
+
+	flags.BoolVar(&upgradeOpts.Check, "check", false, "Only check whether an upgrade is available")
+	flags.BoolVar(&upgradeOpts.Rollback, "rollback", false, "Roll back to the OS that ran before the last upgrade")
+	flags.BoolVar(&upgradeOpts.Disk, "disk", false, "Replace the disk image of the stopped machine instead of upgrading in place")
+	flags.BoolVar(&restart, "restart", false, "Restart VM to apply changes")
+}
+
+func upgrade(cmd *cobra.Command, args []string) error {
+	if upgradeOpts.Check && upgradeOpts.Rollback {
+		return errors.New("--check and --rollback cannot be used together")
+	}
+	vmName := ""
+	if len(args) == 1 {
+		vmName = args[0]
+	}
+	managerOpts := ManagerOpts{
+		VMName:  vmName,
+		CLIArgs: args,
+		Restart: restart,
+	}
+
+	provider, err := provider2.Get()
+	if err != nil {
+		return err
+	}
+	osManager, err := NewOSManager(managerOpts, provider)
+	if err != nil {
+		return err
+	}
+	return osManager.Upgrade(upgradeOpts)
+}
@@ -0,0 +1,64 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"fmt"
+
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/shim"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	refreshProxyCmd = &cobra.Command{
+		Use:               "refresh-proxy [options] [NAME]",
+		Short:             "Apply the proxy settings of the host to a running machine",
+		Long:              "Apply the HTTP(S)_PROXY and NO_PROXY variables of the host to a running machine without restarting it",
+		PersistentPreRunE: machinePreRunE,
+		RunE:              refreshProxy,
+		Args:              cobra.MaximumNArgs(1),
+		Example:           `podman machine refresh-proxy`,
+		ValidArgsFunction: autocompleteMachine,
+	}
+
+	refreshProxyForce bool
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: refreshProxyCmd,
+		Parent:  machineCmd,
+	})
+
+	flags := refreshProxyCmd.Flags()
+	flags.BoolVarP(&refreshProxyForce, "force", "f", false, "Apply the proxy settings even if they did not change")
+}
+
+func refreshProxy(_ *cobra.Command, args []string) error {
+	vmName := defaultMachineName
+	if len(args) > 0 && len(args[0]) > 0 {
+		vmName = args[0]
+	}
+	dirs, err := machine.GetMachineDirs(provider.VMType())
+	if err != nil {
+		return err
+	}
+	mc, err := vmconfigs.LoadMachineByName(vmName, dirs)
+	if err != nil {
+		return err
+	}
+
+	applied, err := shim.RefreshProxies(mc, provider, refreshProxyForce)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		fmt.Printf("Proxy settings of machine %q are up to date\n", mc.Name)
+		return nil
+	}
+	fmt.Printf("Proxy settings of machine %q refreshed\n", mc.Name)
+	return nil
+}
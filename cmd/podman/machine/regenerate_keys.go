@@ -0,0 +1,43 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"fmt"
+
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/machine/shim"
+	"github.com/spf13/cobra"
+)
+
+var (
+	regenerateKeysCmd = &cobra.Command{
+		Use:               "regenerate-keys [NAME]",
+		Short:             "Replace the SSH keys of a running machine",
+		Long:              "Generate a new SSH key pair for a running machine, authorize it in the machine and revoke the previous one",
+		PersistentPreRunE: machinePreRunE,
+		RunE:              regenerateKeys,
+		Args:              cobra.MaximumNArgs(1),
+		Example:           `podman machine regenerate-keys podman-machine-default`,
+		ValidArgsFunction: autocompleteMachine,
+	}
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: regenerateKeysCmd,
+		Parent:  machineCmd,
+	})
+}
+
+func regenerateKeys(_ *cobra.Command, args []string) error {
+	mc, err := loadMachineArg(args, 0)
+	if err != nil {
+		return err
+	}
+	if err := shim.RegenerateKeys(mc, provider); err != nil {
+		return err
+	}
+	fmt.Printf("SSH keys of machine %q regenerated, the new identity is %s\n", mc.Name, mc.SSH.IdentityPath)
+	return nil
+}
@@ -80,7 +80,7 @@ func rm(_ *cobra.Command, args []string) error {
 		if !destroyOptions.Force {
 			return &define.ErrVMRunningCannotDestroyed{Name: vmName}
 		}
-		if err := shim.Stop(mc, provider, dirs, true); err != nil {
+		if _, err := shim.Stop(mc, provider, dirs, machine.StopOptions{Hard: true}); err != nil {
 			return err
 		}
 	}
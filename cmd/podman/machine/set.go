@@ -3,13 +3,12 @@
 package machine
 
 import (
-	"fmt"
-
 	"github.com/containers/common/pkg/completion"
 	"github.com/containers/common/pkg/strongunits"
 	"github.com/containers/podman/v5/cmd/podman/registry"
 	"github.com/containers/podman/v5/pkg/machine"
 	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/shim"
 	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
 	"github.com/spf13/cobra"
 )
@@ -28,8 +27,10 @@ var (
 )
 
 var (
-	setFlags = SetFlags{}
-	setOpts  = define.SetOptions{}
+	setFlags       = SetFlags{}
+	setHostHooks   = hostHookFlags{}
+	setGuestConfig = guestConfigFlags{}
+	setOpts        = define.SetOptions{}
 )
 
 type SetFlags struct {
@@ -39,6 +40,11 @@ type SetFlags struct {
 	Rootful            bool
 	UserModeNetworking bool
 	USBs               []string
+	Ports              []string
+	PreStopHooks       []string
+	RestartPolicy      string
+	ClockSync          bool
+	WSLNetworking      string
 }
 
 func init() {
@@ -80,12 +86,39 @@ func init() {
 	flags.StringArrayVarP(
 		&setFlags.USBs,
 		usbFlagName, "", []string{},
-		"USBs bus=$1,devnum=$2 or vendor=$1,product=$2")
-	_ = setCmd.RegisterFlagCompletionFunc(usbFlagName, completion.AutocompleteNone)
+		"USBs bus=$1,devnum=$2, vendor=$1,product=$2 or vendor:product")
+	_ = setCmd.RegisterFlagCompletionFunc(usbFlagName, autocompleteUSB)
+
+	publishFlagName := "publish"
+	flags.StringArrayVar(&setFlags.Ports, publishFlagName, []string{},
+		"Publish a port of the machine on the host: [ip:][hostPort:]guestPort[/protocol]")
+	_ = setCmd.RegisterFlagCompletionFunc(publishFlagName, completion.AutocompleteNone)
 
 	userModeNetFlagName := "user-mode-networking"
 	flags.BoolVar(&setFlags.UserModeNetworking, userModeNetFlagName, false, // defaults not-relevant due to use of Changed()
 		"Whether this machine should use user-mode networking, routing traffic through a host user-space process")
+
+	preStopHookFlagName := "pre-stop-hook"
+	flags.StringArrayVar(&setFlags.PreStopHooks, preStopHookFlagName, []string{},
+		"Command run in the machine before it is stopped, prefix with \"required:\" to abort the stop on failure")
+	_ = setCmd.RegisterFlagCompletionFunc(preStopHookFlagName, completion.AutocompleteNone)
+
+	restartFlagName := "restart"
+	flags.StringVar(&setFlags.RestartPolicy, restartFlagName, "",
+		"Restart policy of the machine: no, network (restart gvproxy) or always (restart gvproxy and the machine)")
+	_ = setCmd.RegisterFlagCompletionFunc(restartFlagName, autocompleteRestartPolicy)
+
+	clockSyncFlagName := "clock-sync"
+	flags.BoolVar(&setFlags.ClockSync, clockSyncFlagName, false, // defaults not-relevant due to use of Changed()
+		"Step the clock of the running machine when it drifts from the host, e.g. after the host slept")
+
+	wslNetworkingFlagName := "wsl-networking"
+	flags.StringVar(&setFlags.WSLNetworking, wslNetworkingFlagName, "",
+		"Networking mode WSL is switched to when the machine starts: nat or mirrored, empty to leave WSL as it is configured")
+	_ = setCmd.RegisterFlagCompletionFunc(wslNetworkingFlagName, autocompleteWSLNetworking)
+
+	addHostHookFlags(setCmd, &setHostHooks)
+	addGuestConfigFlags(setCmd, &setGuestConfig)
 }
 
 func setMachine(cmd *cobra.Command, args []string) error {
@@ -112,18 +145,12 @@ func setMachine(cmd *cobra.Command, args []string) error {
 		setOpts.Rootful = &setFlags.Rootful
 	}
 	if cmd.Flags().Changed("cpus") {
-		mc.Resources.CPUs = setFlags.CPUs
-		setOpts.CPUs = &mc.Resources.CPUs
+		setOpts.CPUs = &setFlags.CPUs
 	}
 	if cmd.Flags().Changed("memory") {
-		mc.Resources.Memory = setFlags.Memory
-		setOpts.Memory = &mc.Resources.Memory
+		setOpts.Memory = &setFlags.Memory
 	}
 	if cmd.Flags().Changed("disk-size") {
-		if setFlags.DiskSize <= mc.Resources.DiskSize {
-			return fmt.Errorf("new disk size must be larger than %d GB", mc.Resources.DiskSize)
-		}
-		mc.Resources.DiskSize = setFlags.DiskSize
 		newDiskSizeGB := strongunits.GiB(setFlags.DiskSize)
 		setOpts.DiskSize = &newDiskSizeGB
 	}
@@ -133,13 +160,23 @@ func setMachine(cmd *cobra.Command, args []string) error {
 	if cmd.Flags().Changed("usb") {
 		setOpts.USBs = &setFlags.USBs
 	}
-
-	// At this point, we have the known changed information, etc
-	// Walk through changes to the providers if they need them
-	if err := provider.SetProviderAttrs(mc, setOpts); err != nil {
-		return err
+	if cmd.Flags().Changed("publish") {
+		setOpts.Ports = &setFlags.Ports
+	}
+	if cmd.Flags().Changed("wsl-networking") {
+		setOpts.WSLNetworking = &setFlags.WSLNetworking
+	}
+	if cmd.Flags().Changed("pre-stop-hook") {
+		setOpts.PreStopHooks = &setFlags.PreStopHooks
+	}
+	if cmd.Flags().Changed("restart") {
+		setOpts.RestartPolicy = &setFlags.RestartPolicy
+	}
+	if cmd.Flags().Changed("clock-sync") {
+		setOpts.ClockSync = &setFlags.ClockSync
 	}
+	setOpts.HostHooks = setHostHooks.options(cmd)
+	setOpts.GuestConfig = setGuestConfig.options(cmd)
 
-	// Update the configuration file last if everything earlier worked
-	return mc.Write()
+	return shim.Set(mc, provider, setOpts)
 }
@@ -0,0 +1,166 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/containers/common/pkg/report"
+	"github.com/containers/podman/v5/cmd/podman/common"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/cmd/podman/validate"
+	"github.com/containers/podman/v5/pkg/machine/shim"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotCmd = &cobra.Command{
+		Use:               "snapshot",
+		Short:             "Manage the snapshots of a virtual machine",
+		Long:              "Take, list and restore snapshots of the disk of a virtual machine",
+		PersistentPreRunE: validate.NoOp,
+		RunE:              validate.SubCommandExists,
+	}
+
+	snapshotCreateCmd = &cobra.Command{
+		Use:               "create SNAPSHOT [NAME]",
+		Short:             "Take a snapshot of a virtual machine",
+		Long:              "Take a snapshot of the disk of a stopped virtual machine",
+		PersistentPreRunE: machinePreRunE,
+		RunE:              snapshotCreate,
+		Args:              cobra.RangeArgs(1, 2),
+		Example:           `podman machine snapshot create before-upgrade`,
+		ValidArgsFunction: autocompleteMachineAfterArg,
+	}
+
+	snapshotListCmd = &cobra.Command{
+		Use:               "list [options] [NAME]",
+		Aliases:           []string{"ls"},
+		Short:             "List the snapshots of a virtual machine",
+		Long:              "List the snapshots of the disk of a virtual machine",
+		PersistentPreRunE: machinePreRunE,
+		RunE:              snapshotList,
+		Args:              cobra.MaximumNArgs(1),
+		Example:           `podman machine snapshot list`,
+		ValidArgsFunction: autocompleteMachine,
+	}
+
+	snapshotRestoreCmd = &cobra.Command{
+		Use:               "restore SNAPSHOT [NAME]",
+		Short:             "Restore a snapshot of a virtual machine",
+		Long:              "Roll the disk of a stopped virtual machine back to a snapshot",
+		PersistentPreRunE: machinePreRunE,
+		RunE:              snapshotRestore,
+		Args:              cobra.RangeArgs(1, 2),
+		Example:           `podman machine snapshot restore before-upgrade`,
+		ValidArgsFunction: autocompleteMachineAfterArg,
+	}
+)
+
+var snapshotListFlags = struct {
+	format    string
+	noHeading bool
+}{}
+
+// snapshotReport is a snapshot as shown by podman machine snapshot list
+type snapshotReport struct {
+	Name     string
+	Created  string
+	DiskSize string
+}
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: snapshotCmd,
+		Parent:  machineCmd,
+	})
+	for _, cmd := range []*cobra.Command{snapshotCreateCmd, snapshotListCmd, snapshotRestoreCmd} {
+		registry.Commands = append(registry.Commands, registry.CliCommand{
+			Command: cmd,
+			Parent:  snapshotCmd,
+		})
+	}
+
+	flags := snapshotListCmd.Flags()
+	formatFlagName := "format"
+	flags.StringVar(&snapshotListFlags.format, formatFlagName, "{{range .}}{{.Name}}\t{{.Created}}\t{{.DiskSize}}\n{{end -}}", "Format the output using JSON or a Go template")
+	_ = snapshotListCmd.RegisterFlagCompletionFunc(formatFlagName, common.AutocompleteFormat(&snapshotReport{}))
+	flags.BoolVarP(&snapshotListFlags.noHeading, "noheading", "n", false, "Do not print headers")
+}
+
+func snapshotCreate(_ *cobra.Command, args []string) error {
+	mc, err := loadMachineArg(args, 1)
+	if err != nil {
+		return err
+	}
+	if err := shim.Snapshot(mc, provider, args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Snapshot %q of machine %q created\n", args[0], mc.Name)
+	return nil
+}
+
+func snapshotList(cmd *cobra.Command, args []string) error {
+	mc, err := loadMachineArg(args, 0)
+	if err != nil {
+		return err
+	}
+
+	if report.IsJSON(snapshotListFlags.format) {
+		snapshots := mc.Snapshots
+		if snapshots == nil {
+			snapshots = []vmconfigs.Snapshot{}
+		}
+		b, err := json.MarshalIndent(snapshots, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	reports := make([]snapshotReport, 0, len(mc.Snapshots))
+	for _, snapshot := range mc.Snapshots {
+		reports = append(reports, snapshotReport{
+			Name:     snapshot.Name,
+			Created:  units.HumanDuration(time.Since(snapshot.Created)) + " ago",
+			DiskSize: units.BytesSize(float64(snapshot.DiskSize) * units.GiB),
+		})
+	}
+
+	rpt := report.New(os.Stdout, cmd.Name())
+	defer rpt.Flush()
+	origin := report.OriginPodman
+	if cmd.Flag("format").Changed {
+		origin = report.OriginUser
+	}
+	rpt, err = rpt.Parse(origin, snapshotListFlags.format)
+	if err != nil {
+		return err
+	}
+	if rpt.RenderHeaders && !snapshotListFlags.noHeading {
+		headers := report.Headers(snapshotReport{}, map[string]string{
+			"DiskSize": "DISK SIZE",
+		})
+		if err := rpt.Execute(headers); err != nil {
+			return fmt.Errorf("failed to write report column headers: %w", err)
+		}
+	}
+	return rpt.Execute(reports)
+}
+
+func snapshotRestore(_ *cobra.Command, args []string) error {
+	mc, err := loadMachineArg(args, 1)
+	if err != nil {
+		return err
+	}
+	if err := shim.RevertSnapshot(mc, provider, args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Machine %q restored to snapshot %q\n", mc.Name, args[0])
+	return nil
+}
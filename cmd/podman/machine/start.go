@@ -5,6 +5,7 @@ package machine
 import (
 	"fmt"
 
+	"github.com/containers/common/pkg/completion"
 	"github.com/containers/podman/v5/cmd/podman/registry"
 	"github.com/containers/podman/v5/libpod/events"
 	"github.com/containers/podman/v5/pkg/machine"
@@ -26,7 +27,9 @@ var (
 		Example:           `podman machine start podman-machine-default`,
 		ValidArgsFunction: autocompleteMachine,
 	}
-	startOpts = machine.StartOptions{}
+	startOpts    = machine.StartOptions{}
+	recovery     bool
+	recoveryOpts = machine.RecoveryOptions{}
 )
 
 func init() {
@@ -41,6 +44,17 @@ func init() {
 
 	quietFlagName := "quiet"
 	flags.BoolVarP(&startOpts.Quiet, quietFlagName, "q", false, "Suppress machine starting status output")
+
+	readyTimeoutFlagName := "ready-timeout"
+	flags.DurationVar(&startOpts.ReadyTimeout, readyTimeoutFlagName, machine.DefaultReadyTimeout, "How long to wait for the machine to report it is ready")
+	_ = startCmd.RegisterFlagCompletionFunc(readyTimeoutFlagName, completion.AutocompleteNone)
+
+	recoveryFlagName := "recovery"
+	flags.BoolVar(&recovery, recoveryFlagName, false, "Boot the machine into a minimal shell for repair")
+
+	recoveryMaskFlagName := "recovery-mask"
+	flags.StringArrayVar(&recoveryOpts.MaskUnits, recoveryMaskFlagName, []string{}, "Systemd unit not to start when booting for repair")
+	_ = startCmd.RegisterFlagCompletionFunc(recoveryMaskFlagName, completion.AutocompleteNone)
 }
 
 func start(_ *cobra.Command, args []string) error {
@@ -77,6 +91,14 @@ func start(_ *cobra.Command, args []string) error {
 		return err
 	}
 
+	if recovery {
+		if err := shim.StartRecovery(mc, provider, recoveryOpts); err != nil {
+			return err
+		}
+		fmt.Printf("Machine %q started for recovery, use its console to repair it\n", vmName)
+		return nil
+	}
+
 	if !startOpts.Quiet {
 		fmt.Printf("Starting machine %q\n", vmName)
 	}
@@ -94,10 +116,11 @@ func start(_ *cobra.Command, args []string) error {
 			logrus.Error(err)
 		}
 	}()
-	if err := shim.Start(mc, provider, dirs, startOpts); err != nil {
+	report, err := shim.Start(mc, provider, dirs, startOpts)
+	if err != nil {
 		return err
 	}
 	fmt.Printf("Machine %q started successfully\n", vmName)
-	newMachineEvent(events.Start, events.Event{Name: vmName})
+	newMachineEvent(events.Start, events.Event{Name: vmName, Details: events.Details{Attributes: report.Attributes()}})
 	return nil
 }
@@ -0,0 +1,217 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	tm "github.com/buger/goterm"
+	"github.com/containers/common/pkg/completion"
+	"github.com/containers/common/pkg/report"
+	"github.com/containers/podman/v5/cmd/podman/common"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/shim"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/containers/podman/v5/utils"
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsCmd = &cobra.Command{
+		Use:               "stats [options] [MACHINE...]",
+		Short:             "Display a live stream of virtual machine resource usage statistics",
+		Long:              "Display the CPU, memory and disk usage of running virtual machines as seen from the host",
+		PersistentPreRunE: machinePreRunE,
+		RunE:              stats,
+		ValidArgsFunction: autocompleteMachine,
+		Example: `podman machine stats
+  podman machine stats --no-stream --format "{{.Name}} {{.CPUPerc}}" myvm`,
+	}
+)
+
+var statsFlags = struct {
+	format   string
+	noReset  bool
+	noStream bool
+	interval int
+}{}
+
+// machineStats is the usage of a machine as shown by podman machine stats
+type machineStats struct {
+	Name string
+	vmconfigs.Stats
+	// MemoryLimit in bytes of the machine
+	MemoryLimit uint64
+	// DiskSize in bytes of the disk of the machine
+	DiskSize uint64
+}
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: statsCmd,
+		Parent:  machineCmd,
+	})
+
+	flags := statsCmd.Flags()
+	formatFlagName := "format"
+	flags.StringVar(&statsFlags.format, formatFlagName, "", "Pretty-print machine statistics to JSON or using a Go template")
+	_ = statsCmd.RegisterFlagCompletionFunc(formatFlagName, common.AutocompleteFormat(&machineStats{}))
+	flags.BoolVar(&statsFlags.noReset, "no-reset", false, "Disable resetting the screen between intervals")
+	flags.BoolVar(&statsFlags.noStream, "no-stream", false, "Disable streaming stats and only pull the first result")
+	intervalFlagName := "interval"
+	flags.IntVarP(&statsFlags.interval, intervalFlagName, "i", 5, "Time in seconds between stats reports")
+	_ = statsCmd.RegisterFlagCompletionFunc(intervalFlagName, completion.AutocompleteNone)
+}
+
+func stats(cmd *cobra.Command, args []string) error {
+	if statsFlags.interval < 1 {
+		return errors.New("invalid interval, must be a positive number greater zero")
+	}
+	dirs, err := machine.GetMachineDirs(provider.VMType())
+	if err != nil {
+		return err
+	}
+
+	previous := make(map[string]*vmconfigs.Stats)
+	for {
+		reports, err := sampleStats(dirs, args, previous)
+		if err != nil {
+			return err
+		}
+		if err := outputStats(cmd, reports); err != nil {
+			return err
+		}
+		if statsFlags.noStream {
+			return nil
+		}
+		time.Sleep(time.Duration(statsFlags.interval) * time.Second)
+	}
+}
+
+// sampleStats measures the named machines, or all running machines when no
+// name is given, and records the samples in previous
+func sampleStats(dirs *define.MachineDirs, names []string, previous map[string]*vmconfigs.Stats) ([]machineStats, error) {
+	var mcs []*vmconfigs.MachineConfig
+	if len(names) > 0 {
+		for _, name := range names {
+			mc, err := vmconfigs.LoadMachineByName(name, dirs)
+			if err != nil {
+				return nil, err
+			}
+			mcs = append(mcs, mc)
+		}
+	} else {
+		all, err := vmconfigs.LoadMachinesInDir(dirs)
+		if err != nil {
+			return nil, err
+		}
+		for _, mc := range all {
+			state, err := provider.State(mc, false)
+			if err != nil {
+				return nil, err
+			}
+			if state == define.Running {
+				mcs = append(mcs, mc)
+			}
+		}
+		sort.Slice(mcs, func(i, j int) bool { return mcs[i].Name < mcs[j].Name })
+	}
+
+	reports := make([]machineStats, 0, len(mcs))
+	for _, mc := range mcs {
+		stats, err := shim.Stats(mc, provider, previous[mc.Name])
+		if err != nil {
+			return nil, err
+		}
+		previous[mc.Name] = stats
+		reports = append(reports, machineStats{
+			Name:        mc.Name,
+			Stats:       *stats,
+			MemoryLimit: mc.Resources.Memory * units.MiB,
+			DiskSize:    mc.Resources.DiskSize * units.GiB,
+		})
+	}
+	return reports, nil
+}
+
+func outputStats(cmd *cobra.Command, reports []machineStats) error {
+	if !statsFlags.noReset && !statsFlags.noStream {
+		tm.Clear()
+		tm.MoveCursor(1, 1)
+		tm.Flush()
+	}
+	if report.IsJSON(statsFlags.format) {
+		b, err := json.MarshalIndent(reports, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	rpt := report.New(os.Stdout, cmd.Name())
+	defer rpt.Flush()
+
+	var err error
+	if cmd.Flags().Changed("format") {
+		rpt, err = rpt.Parse(report.OriginUser, statsFlags.format)
+	} else {
+		format := "{{range .}}{{.Name}}\t{{.CPUPerc}}\t{{.MemUsage}}\t{{.MemPerc}}\t{{.DiskUsage}}\t{{.TotalCPU}}\n{{end -}}"
+		rpt, err = rpt.Parse(report.OriginPodman, format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if rpt.RenderHeaders {
+		headers := report.Headers(machineStats{}, map[string]string{
+			"CPUPerc":   "CPU %",
+			"MemUsage":  "MEM USAGE / LIMIT",
+			"MemPerc":   "MEM %",
+			"DiskUsage": "DISK USAGE / SIZE",
+			"TotalCPU":  "CPU TIME",
+		})
+		if err := rpt.Execute(headers); err != nil {
+			return err
+		}
+	}
+	return rpt.Execute(reports)
+}
+
+func (s machineStats) CPUPerc() string {
+	return floatToPercentString(s.CPUPercent)
+}
+
+func (s machineStats) TotalCPU() string {
+	return s.CPUTime.Round(10 * time.Millisecond).String()
+}
+
+func (s machineStats) MemUsage() string {
+	return fmt.Sprintf("%s / %s", units.HumanSize(float64(s.MemoryUsed)), units.HumanSize(float64(s.MemoryLimit)))
+}
+
+func (s machineStats) MemPerc() string {
+	if s.MemoryLimit == 0 {
+		return "--"
+	}
+	return floatToPercentString(float64(s.MemoryUsed) / float64(s.MemoryLimit) * 100)
+}
+
+func (s machineStats) DiskUsage() string {
+	return fmt.Sprintf("%s / %s", units.HumanSize(float64(s.DiskUsed)), units.HumanSize(float64(s.DiskSize)))
+}
+
+func floatToPercentString(f float64) string {
+	strippedFloat, err := utils.RemoveScientificNotationFromFloat(f)
+	if err != nil {
+		return "--"
+	}
+	return fmt.Sprintf("%.2f", strippedFloat) + "%"
+}
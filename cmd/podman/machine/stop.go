@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/containers/common/pkg/completion"
 	"github.com/containers/podman/v5/cmd/podman/registry"
 	"github.com/containers/podman/v5/libpod/events"
 	"github.com/containers/podman/v5/pkg/machine"
@@ -17,7 +18,7 @@ import (
 
 var (
 	stopCmd = &cobra.Command{
-		Use:               "stop [MACHINE]",
+		Use:               "stop [options] [MACHINE]",
 		Short:             "Stop an existing machine",
 		Long:              "Stop a managed virtual machine ",
 		PersistentPreRunE: machinePreRunE,
@@ -26,6 +27,7 @@ var (
 		Example:           `podman machine stop podman-machine-default`,
 		ValidArgsFunction: autocompleteMachine,
 	}
+	stopOpts = machine.StopOptions{}
 )
 
 func init() {
@@ -33,6 +35,14 @@ func init() {
 		Command: stopCmd,
 		Parent:  machineCmd,
 	})
+
+	flags := stopCmd.Flags()
+	noHooksFlagName := "no-hooks"
+	flags.BoolVar(&stopOpts.NoHooks, noHooksFlagName, false, "Do not run the stop hooks of the machine and of the host")
+
+	timeoutFlagName := "timeout"
+	flags.DurationVar(&stopOpts.Timeout, timeoutFlagName, machine.DefaultStopTimeout, "How long to wait for the machine to shut down before forcing it off")
+	_ = stopCmd.RegisterFlagCompletionFunc(timeoutFlagName, completion.AutocompleteNone)
 }
 
 // TODO  Name shouldn't be required, need to create a default vm
@@ -55,7 +65,8 @@ func stop(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if err := shim.Stop(mc, provider, dirs, false); err != nil {
+	report, err := shim.Stop(mc, provider, dirs, stopOpts)
+	if err != nil {
 		return err
 	}
 
@@ -65,7 +76,14 @@ func stop(cmd *cobra.Command, args []string) error {
 		logrus.Errorf("unable to write configuration file: %q", err)
 	}
 
+	event := events.Event{Name: vmName}
+	if report != nil {
+		event.Details.Attributes = report.Attributes()
+		if report.Method == machine.StopForced {
+			fmt.Printf("Machine %q did not shut down within %s and was forced off\n", vmName, stopOpts.Timeout)
+		}
+	}
 	fmt.Printf("Machine %q stopped successfully\n", vmName)
-	newMachineEvent(events.Stop, events.Event{Name: vmName})
+	newMachineEvent(events.Stop, event)
 	return nil
 }
@@ -0,0 +1,93 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/containers/common/pkg/completion"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/shim"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	updateConfigCmd = &cobra.Command{
+		Use:               "update-config [options] [NAME]",
+		Short:             "Regenerate the configuration of a virtual machine",
+		Long:              "Regenerate the ignition configuration of a running virtual machine and apply the changes to it",
+		PersistentPreRunE: machinePreRunE,
+		RunE:              updateConfig,
+		Args:              cobra.MaximumNArgs(1),
+		Example:           `podman machine update-config --ssh-key ~/.ssh/id_ed25519.pub`,
+		ValidArgsFunction: autocompleteMachine,
+	}
+)
+
+var (
+	updateConfigOpts = define.UpdateConfigOptions{}
+	updateSSHKeys    []string
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: updateConfigCmd,
+		Parent:  machineCmd,
+	})
+	flags := updateConfigCmd.Flags()
+
+	timezoneFlagName := "timezone"
+	flags.StringVar(&updateConfigOpts.TimeZone, timezoneFlagName, "", "Set timezone")
+	_ = updateConfigCmd.RegisterFlagCompletionFunc(timezoneFlagName, completion.AutocompleteDefault)
+
+	sshKeyFlagName := "ssh-key"
+	flags.StringArrayVar(&updateSSHKeys, sshKeyFlagName, []string{}, "Path to a public SSH key to authorize in the machine")
+	_ = updateConfigCmd.RegisterFlagCompletionFunc(sshKeyFlagName, completion.AutocompleteDefault)
+}
+
+func updateConfig(_ *cobra.Command, args []string) error {
+	vmName := defaultMachineName
+	if len(args) > 0 && len(args[0]) > 0 {
+		vmName = args[0]
+	}
+
+	dirs, err := machine.GetMachineDirs(provider.VMType())
+	if err != nil {
+		return err
+	}
+	mc, err := vmconfigs.LoadMachineByName(vmName, dirs)
+	if err != nil {
+		return err
+	}
+
+	// ignition does not run again, so changes only reach a running guest
+	state, err := provider.State(mc, false)
+	if err != nil {
+		return err
+	}
+	if state != define.Running {
+		return fmt.Errorf("machine %q must be running to update its configuration: %w", vmName, define.ErrWrongState)
+	}
+
+	for _, keyFile := range updateSSHKeys {
+		key, err := os.ReadFile(keyFile)
+		if err != nil {
+			return err
+		}
+		updateConfigOpts.SSHKeys = append(updateConfigOpts.SSHKeys, string(key))
+	}
+
+	plan, err := shim.RegenerateIgnition(mc, provider, updateConfigOpts)
+	if err != nil {
+		return err
+	}
+	if err := shim.ApplyIgnitionPlan(mc, plan); err != nil {
+		return err
+	}
+	fmt.Printf("Machine %q updated, %d change(s) applied\n", vmName, len(plan))
+	return nil
+}
@@ -0,0 +1,51 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// usbDevicesDir lists the USB devices of Linux hosts
+const usbDevicesDir = "/sys/bus/usb/devices"
+
+// usbHubClass is the device class of USB hubs, which cannot be passed through
+const usbHubClass = "09"
+
+// autocompleteUSB completes the USB devices of the host in the vendor:product
+// form, described by their manufacturer and product names.  Only Linux hosts
+// list their devices, the only hosts whose provider passes them through.
+func autocompleteUSB(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	entries, err := os.ReadDir(usbDevicesDir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	readAttr := func(dev, attr string) string {
+		b, err := os.ReadFile(filepath.Join(usbDevicesDir, dev, attr))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(b))
+	}
+
+	var suggestions []string
+	for _, entry := range entries {
+		vendor, product := readAttr(entry.Name(), "idVendor"), readAttr(entry.Name(), "idProduct")
+		// interfaces have no IDs
+		if vendor == "" || product == "" || readAttr(entry.Name(), "bDeviceClass") == usbHubClass {
+			continue
+		}
+		suggestion := vendor + ":" + product
+		if desc := strings.TrimSpace(readAttr(entry.Name(), "manufacturer") + " " + readAttr(entry.Name(), "product")); desc != "" {
+			suggestion += "\t" + desc
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	sort.Strings(suggestions)
+	return suggestions, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,87 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"fmt"
+
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/cmd/podman/validate"
+	"github.com/containers/podman/v5/pkg/machine/shim"
+	"github.com/spf13/cobra"
+)
+
+var (
+	volumeCmd = &cobra.Command{
+		Use:               "volume",
+		Short:             "Manage the volumes of a virtual machine",
+		Long:              "Add volumes to or remove volumes from an existing virtual machine",
+		PersistentPreRunE: validate.NoOp,
+		RunE:              validate.SubCommandExists,
+	}
+
+	volumeAddCmd = &cobra.Command{
+		Use:               "add SOURCE:TARGET[:OPTIONS] [NAME]",
+		Short:             "Add a volume to a virtual machine",
+		Long:              "Mount a host directory in an existing virtual machine, right away when the provider supports it or on the next start",
+		PersistentPreRunE: machinePreRunE,
+		RunE:              volumeAdd,
+		Args:              cobra.RangeArgs(1, 2),
+		Example:           `podman machine volume add $HOME/src:/src`,
+		ValidArgsFunction: autocompleteMachineAfterArg,
+	}
+
+	volumeRemoveCmd = &cobra.Command{
+		Use:               "remove TARGET [NAME]",
+		Aliases:           []string{"rm"},
+		Short:             "Remove a volume from a virtual machine",
+		Long:              "Remove the volume mounted on TARGET from an existing virtual machine, a running machine keeps it mounted until it stops",
+		PersistentPreRunE: machinePreRunE,
+		RunE:              volumeRemove,
+		Args:              cobra.RangeArgs(1, 2),
+		Example:           `podman machine volume remove /src`,
+		ValidArgsFunction: autocompleteMachineAfterArg,
+	}
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: volumeCmd,
+		Parent:  machineCmd,
+	})
+	for _, cmd := range []*cobra.Command{volumeAddCmd, volumeRemoveCmd} {
+		registry.Commands = append(registry.Commands, registry.CliCommand{
+			Command: cmd,
+			Parent:  volumeCmd,
+		})
+	}
+}
+
+func volumeAdd(_ *cobra.Command, args []string) error {
+	mc, err := loadMachineArg(args, 1)
+	if err != nil {
+		return err
+	}
+	attached, err := shim.AddVolume(mc, provider, args[0])
+	if err != nil {
+		return err
+	}
+	if attached {
+		fmt.Printf("Volume %q mounted in machine %q\n", args[0], mc.Name)
+		return nil
+	}
+	fmt.Printf("Volume %q added to machine %q, it is mounted the next time the machine starts\n", args[0], mc.Name)
+	return nil
+}
+
+func volumeRemove(_ *cobra.Command, args []string) error {
+	mc, err := loadMachineArg(args, 1)
+	if err != nil {
+		return err
+	}
+	if err := shim.RemoveVolume(mc, provider, args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Volume %q removed from machine %q\n", args[0], mc.Name)
+	return nil
+}
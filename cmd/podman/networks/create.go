@@ -73,6 +73,7 @@ func networkCreateFlags(cmd *cobra.Command) {
 	flags.StringVar(&ipamDriver, ipamDriverFlagName, "", "IP Address Management Driver")
 	_ = cmd.RegisterFlagCompletionFunc(ipamDriverFlagName, common.AutocompleteNetworkIPAMDriver)
 
+	flags.BoolVar(&networkCreateOptions.IPv4, "ipv4", true, "enable IPv4 networking")
 	flags.BoolVar(&networkCreateOptions.IPv6, "ipv6", false, "enable IPv6 networking")
 
 	subnetFlagName := "subnet"
@@ -194,6 +195,7 @@ func networkCreate(cmd *cobra.Command, args []string) error {
 
 	extraCreateOptions := types.NetworkCreateOptions{
 		IgnoreIfExists: networkCreateOptions.IgnoreIfExists,
+		DisableIPv4:    !networkCreateOptions.IPv4,
 	}
 
 	response, err := registry.ContainerEngine().NetworkCreate(registry.Context(), network, &extraCreateOptions)
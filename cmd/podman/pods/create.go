@@ -79,6 +79,10 @@ func init() {
 	flags.StringVarP(&createOptions.ExitPolicy, policyFlag, "", string(containerConfig.Engine.PodExitPolicy), "Behaviour when the last container exits")
 	_ = createCommand.RegisterFlagCompletionFunc(policyFlag, common.AutocompletePodExitPolicy)
 
+	exitCodePropagationFlagName := "exit-code-propagation"
+	flags.StringVarP(&createOptions.ExitCodePropagation, exitCodePropagationFlagName, "", "", "Exit-code propagation of the pod when it stops (any, all, none)")
+	_ = createCommand.RegisterFlagCompletionFunc(exitCodePropagationFlagName, common.AutocompleteExitCodePropagation)
+
 	infraImageFlagName := "infra-image"
 	var defInfraImage string
 	if !registry.IsRemote() {
@@ -91,6 +95,10 @@ func init() {
 	flags.StringVar(&podIDFile, podIDFileFlagName, "", "Write the pod ID to the file")
 	_ = createCommand.RegisterFlagCompletionFunc(podIDFileFlagName, completion.AutocompleteDefault)
 
+	pidsLimitFlagName := "pids-limit"
+	flags.Int64(pidsLimitFlagName, 0, "Pids limit of the pod (set -1 for unlimited)")
+	_ = createCommand.RegisterFlagCompletionFunc(pidsLimitFlagName, completion.AutocompleteNone)
+
 	flags.BoolVar(&replace, "replace", false, "If a pod with the same name exists, replace it")
 
 	shareFlagName := "share"
@@ -123,6 +131,10 @@ func create(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("unable to process labels: %w", err)
 	}
 
+	if err := podPidsLimit(cmd, &infraOptions); err != nil {
+		return err
+	}
+
 	if cmd.Flag("infra-image").Changed {
 		imageName = infraImage
 	}
@@ -0,0 +1,108 @@
+package pods
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/containers/common/pkg/completion"
+	"github.com/containers/podman/v5/cmd/podman/common"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/containers/podman/v5/pkg/specgen"
+	"github.com/containers/podman/v5/pkg/specgenutil"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/spf13/cobra"
+)
+
+var (
+	podUpdateDescription = `Updates the resource limits of the cgroup all containers of a pod are placed under.
+
+  The new limits apply immediately to the running containers of the pod.`
+
+	updateCommand = &cobra.Command{
+		Use:               "update [options] POD",
+		Short:             "Update the resource limits of a pod",
+		Long:              podUpdateDescription,
+		RunE:              update,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: common.AutocompletePods,
+		Example: `podman pod update --cpus=2 mypod
+  podman pod update --memory=1g --pids-limit=200 mypod`,
+	}
+)
+
+var (
+	updateOptions entities.ContainerCreateOptions
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: updateCommand,
+		Parent:  podCmd,
+	})
+	flags := updateCommand.Flags()
+
+	cpusFlagName := "cpus"
+	flags.Float64Var(&updateOptions.CPUS, cpusFlagName, 0, "Number of CPUs the containers of the pod can use together")
+	_ = updateCommand.RegisterFlagCompletionFunc(cpusFlagName, completion.AutocompleteNone)
+
+	memoryFlagName := "memory"
+	flags.StringVarP(&updateOptions.Memory, memoryFlagName, "m", "", "Memory limit of the pod (format: <number>[<unit>], where unit = b (bytes), k (kibibytes), m (mebibytes), or g (gibibytes))")
+	_ = updateCommand.RegisterFlagCompletionFunc(memoryFlagName, completion.AutocompleteNone)
+
+	memorySwapFlagName := "memory-swap"
+	flags.StringVar(&updateOptions.MemorySwap, memorySwapFlagName, "", "Swap limit of the pod equal to memory plus swap: '-1' to enable unlimited swap")
+	_ = updateCommand.RegisterFlagCompletionFunc(memorySwapFlagName, completion.AutocompleteNone)
+
+	pidsLimitFlagName := "pids-limit"
+	flags.Int64(pidsLimitFlagName, 0, "Pids limit of the pod (set -1 for unlimited)")
+	_ = updateCommand.RegisterFlagCompletionFunc(pidsLimitFlagName, completion.AutocompleteNone)
+}
+
+func update(cmd *cobra.Command, args []string) error {
+	if !cmd.Flags().Changed("cpus") && !cmd.Flags().Changed("memory") && !cmd.Flags().Changed("memory-swap") && !cmd.Flags().Changed("pids-limit") {
+		return errors.New("at least one of --cpus, --memory, --memory-swap or --pids-limit must be set")
+	}
+	if err := podPidsLimit(cmd, &updateOptions); err != nil {
+		return err
+	}
+	// swappiness is not a limit of the pod cgroup, do not let it default to 0
+	updateOptions.MemorySwappiness = -1
+
+	// use a specgen since this is the easiest way to hold resource info
+	s := &specgen.SpecGenerator{}
+	s.ResourceLimits = &specs.LinuxResources{}
+	resources, err := specgenutil.GetResources(s, &updateOptions)
+	if err != nil {
+		return err
+	}
+	if resources == nil {
+		resources = &specs.LinuxResources{}
+	}
+
+	rep, err := registry.ContainerEngine().PodUpdate(context.Background(), entities.PodUpdateOptions{
+		NameOrID:  args[0],
+		Resources: resources,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(rep)
+	return nil
+}
+
+// podPidsLimit sets the pids limit of vals from the --pids-limit flag.  Unlike
+// for containers, -1 is kept as is since it sets the pod cgroup to unlimited
+// while 0 leaves the current limit untouched.
+func podPidsLimit(cmd *cobra.Command, vals *entities.ContainerCreateOptions) error {
+	if !cmd.Flags().Changed("pids-limit") {
+		return nil
+	}
+	pidsLimit, err := cmd.Flags().GetInt64("pids-limit")
+	if err != nil {
+		return err
+	}
+	vals.PIDsLimit = &pidsLimit
+	return nil
+}
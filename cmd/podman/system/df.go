@@ -206,8 +206,9 @@ func printVerbose(cmd *cobra.Command, reports *entities.SystemDfReport) error {
 		"ContainerID":  "CONTAINER ID",
 		"LocalVolumes": "LOCAL VOLUMES",
 		"RWSize":       "SIZE",
+		"SizeLimit":    "SIZE LIMIT",
 	})
-	containerRow := "{{range .}}{{.ContainerID}}\t{{.Image}}\t{{.Command}}\t{{.LocalVolumes}}\t{{.RWSize}}\t{{.Created}}\t{{.Status}}\t{{.Names}}\n{{end -}}"
+	containerRow := "{{range .}}{{.ContainerID}}\t{{.Image}}\t{{.Command}}\t{{.LocalVolumes}}\t{{.RWSize}}\t{{.SizeLimit}}\t{{.Created}}\t{{.Status}}\t{{.Names}}\n{{end -}}"
 	rpt, err = rpt.Parse(report.OriginPodman, containerRow)
 	if err != nil {
 		return err
@@ -224,8 +225,9 @@ func printVerbose(cmd *cobra.Command, reports *entities.SystemDfReport) error {
 	}
 	hdrs = report.Headers(entities.SystemDfVolumeReport{}, map[string]string{
 		"VolumeName": "VOLUME NAME",
+		"SizeLimit":  "SIZE LIMIT",
 	})
-	volumeRow := "{{range .}}{{.VolumeName}}\t{{.Links}}\t{{.Size}}\n{{end -}}"
+	volumeRow := "{{range .}}{{.VolumeName}}\t{{.Links}}\t{{.Size}}\t{{.SizeLimit}}\n{{end -}}"
 	rpt, err = rpt.Parse(report.OriginPodman, volumeRow)
 	if err != nil {
 		return err
@@ -286,6 +288,10 @@ func (d *dfContainer) RWSize() string {
 	return units.HumanSize(float64(d.SystemDfContainerReport.RWSize))
 }
 
+func (d *dfContainer) SizeLimit() string {
+	return sizeLimit(d.SystemDfContainerReport.SizeLimit)
+}
+
 func (d *dfContainer) Created() string {
 	return units.HumanDuration(time.Since(d.SystemDfContainerReport.Created))
 }
@@ -298,6 +304,19 @@ func (d *dfVolume) Size() string {
 	return units.HumanSize(float64(d.SystemDfVolumeReport.Size))
 }
 
+func (d *dfVolume) SizeLimit() string {
+	return sizeLimit(d.SystemDfVolumeReport.SizeLimit)
+}
+
+// sizeLimit returns the human readable size limit, or "-" if the size is
+// not limited.
+func sizeLimit(limit int64) string {
+	if limit <= 0 {
+		return "-"
+	}
+	return units.HumanSize(float64(limit))
+}
+
 type dfSummary struct {
 	Type           string
 	Total          int
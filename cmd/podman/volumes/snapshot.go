@@ -0,0 +1,24 @@
+package volumes
+
+import (
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/cmd/podman/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Command: podman volume _snapshot_
+	snapshotCmd = &cobra.Command{
+		Use:   "snapshot",
+		Short: "Manage volume snapshots",
+		Long:  "Create, list and restore snapshots of volumes",
+		RunE:  validate.SubCommandExists,
+	}
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: snapshotCmd,
+		Parent:  volumeCmd,
+	})
+}
@@ -0,0 +1,46 @@
+package volumes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containers/podman/v5/cmd/podman/common"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotCreateDescription = `Take a snapshot of the data of a volume.
+
+  Volumes backed by a btrfs subvolume, a ZFS dataset or a thin provisioned LVM logical volume are snapshotted by the filesystem or volume manager, the data of other volumes is copied. The snapshot is named after the current time if no name is given.`
+	snapshotCreateCommand = &cobra.Command{
+		Use:               "create VOLUME [SNAPSHOT]",
+		Short:             "Create a snapshot of a volume",
+		Long:              snapshotCreateDescription,
+		RunE:              snapshotCreate,
+		Args:              cobra.RangeArgs(1, 2),
+		ValidArgsFunction: common.AutocompleteVolumeSnapshotCreate,
+		Example: `podman volume snapshot create myvol
+  podman volume snapshot create myvol before-upgrade`,
+	}
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: snapshotCreateCommand,
+		Parent:  snapshotCmd,
+	})
+}
+
+func snapshotCreate(cmd *cobra.Command, args []string) error {
+	var name string
+	if len(args) > 1 {
+		name = args[1]
+	}
+	snapshot, err := registry.ContainerEngine().VolumeSnapshotCreate(context.Background(), args[0], name)
+	if err != nil {
+		return err
+	}
+	fmt.Println(snapshot.Name)
+	return nil
+}
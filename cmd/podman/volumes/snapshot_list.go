@@ -0,0 +1,103 @@
+package volumes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/containers/common/pkg/report"
+	"github.com/containers/podman/v5/cmd/podman/common"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotListDescription = `List the snapshots of a volume, oldest first.`
+	snapshotListCommand     = &cobra.Command{
+		Use:               "list [options] VOLUME",
+		Aliases:           []string{"ls"},
+		Short:             "List the snapshots of a volume",
+		Long:              snapshotListDescription,
+		RunE:              snapshotList,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: common.AutocompleteVolumes,
+		Example: `podman volume snapshot list myvol
+  podman volume snapshot ls --format "{{.Name}} {{.Backend}}" myvol`,
+	}
+)
+
+var snapshotListFormat string
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: snapshotListCommand,
+		Parent:  snapshotCmd,
+	})
+	flags := snapshotListCommand.Flags()
+
+	formatFlagName := "format"
+	flags.StringVar(&snapshotListFormat, formatFlagName, "{{range .}}{{.Name}}\t{{.Backend}}\t{{.Created}}\n{{end -}}", "Format snapshot output using Go template")
+	_ = snapshotListCommand.RegisterFlagCompletionFunc(formatFlagName, common.AutocompleteFormat(&snapshotListReporter{}))
+
+	flags.BoolP("noheading", "n", false, "Do not print headers")
+}
+
+// snapshotListReporter is the struct the format template is applied to.
+type snapshotListReporter struct {
+	entities.VolumeSnapshotReport
+}
+
+// Created returns the human readable time since the snapshot was taken.
+func (s snapshotListReporter) Created() string {
+	return units.HumanDuration(time.Since(s.CreatedAt)) + " ago"
+}
+
+func snapshotList(cmd *cobra.Command, args []string) error {
+	snapshots, err := registry.ContainerEngine().VolumeSnapshotList(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+
+	if report.IsJSON(snapshotListFormat) {
+		b, err := json.MarshalIndent(snapshots, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	if len(snapshots) < 1 {
+		return nil
+	}
+
+	reporters := make([]snapshotListReporter, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		reporters = append(reporters, snapshotListReporter{*snapshot})
+	}
+	noHeading, _ := cmd.Flags().GetBool("noheading")
+	headers := report.Headers(snapshotListReporter{}, map[string]string{
+		"Name":    "SNAPSHOT",
+		"Created": "CREATED",
+	})
+
+	rpt := report.New(os.Stdout, cmd.Name())
+	defer rpt.Flush()
+
+	origin := report.OriginPodman
+	if cmd.Flag("format").Changed {
+		origin = report.OriginUser
+	}
+	rpt, err = rpt.Parse(origin, snapshotListFormat)
+	if err != nil {
+		return err
+	}
+	if rpt.RenderHeaders && !noHeading {
+		if err := rpt.Execute(headers); err != nil {
+			return fmt.Errorf("failed to write report column headers: %w", err)
+		}
+	}
+	return rpt.Execute(reporters)
+}
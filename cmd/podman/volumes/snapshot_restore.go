@@ -0,0 +1,35 @@
+package volumes
+
+import (
+	"context"
+
+	"github.com/containers/podman/v5/cmd/podman/common"
+	"github.com/containers/podman/v5/cmd/podman/registry"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotRestoreDescription = `Replace the data of a volume with the data of a snapshot.
+
+  The volume must not be used by a running container.`
+	snapshotRestoreCommand = &cobra.Command{
+		Use:               "restore VOLUME SNAPSHOT",
+		Short:             "Restore a snapshot of a volume",
+		Long:              snapshotRestoreDescription,
+		RunE:              snapshotRestore,
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: common.AutocompleteVolumeSnapshotRestore,
+		Example:           `podman volume snapshot restore myvol before-upgrade`,
+	}
+)
+
+func init() {
+	registry.Commands = append(registry.Commands, registry.CliCommand{
+		Command: snapshotRestoreCommand,
+		Parent:  snapshotCmd,
+	})
+}
+
+func snapshotRestore(cmd *cobra.Command, args []string) error {
+	return registry.ContainerEngine().VolumeSnapshotRestore(context.Background(), args[0], args[1])
+}
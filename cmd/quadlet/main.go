@@ -54,6 +54,7 @@ var (
 		".kube":      3,
 		".network":   2,
 		".image":     1,
+		".build":     2,
 		".pod":       4,
 	}
 )
@@ -474,7 +475,7 @@ func warnIfAmbiguousName(unit *parser.UnitFile, group string) {
 	if !ok {
 		return
 	}
-	if strings.HasSuffix(imageName, ".image") {
+	if strings.HasSuffix(imageName, ".image") || strings.HasSuffix(imageName, ".build") {
 		return
 	}
 	if !isUnambiguousName(imageName) {
@@ -602,6 +603,7 @@ func process() error {
 
 	for _, unit := range units {
 		var service *parser.UnitFile
+		var pathUnit *parser.UnitFile
 		var name string
 		var err error
 
@@ -619,6 +621,11 @@ func process() error {
 		case strings.HasSuffix(unit.Filename, ".image"):
 			warnIfAmbiguousName(unit, quadlet.ImageGroup)
 			service, name, err = quadlet.ConvertImage(unit)
+		case strings.HasSuffix(unit.Filename, ".build"):
+			service, name, err = quadlet.ConvertBuild(unit, resourceNames)
+			if err == nil {
+				pathUnit, err = quadlet.ConvertBuildPath(unit)
+			}
 		case strings.HasSuffix(unit.Filename, ".pod"):
 			service, err = quadlet.ConvertPod(unit, unit.Filename, podsInfoMap, resourceNames)
 		default:
@@ -634,21 +641,28 @@ func process() error {
 		if name != "" {
 			resourceNames[unit.Filename] = name
 		}
-		service.Path = path.Join(outputPath, service.Filename)
 
-		if dryRunFlag {
-			data, err := service.ToString()
-			if err != nil {
-				reportError(fmt.Errorf("parsing %s: %w", service.Path, err))
+		generated := []*parser.UnitFile{service}
+		if pathUnit != nil {
+			generated = append(generated, pathUnit)
+		}
+		for _, service := range generated {
+			service.Path = path.Join(outputPath, service.Filename)
+
+			if dryRunFlag {
+				data, err := service.ToString()
+				if err != nil {
+					reportError(fmt.Errorf("parsing %s: %w", service.Path, err))
+					continue
+				}
+				fmt.Printf("---%s---\n%s\n", service.Path, data)
 				continue
 			}
-			fmt.Printf("---%s---\n%s\n", service.Path, data)
-			continue
-		}
-		if err := generateServiceFile(service); err != nil {
-			reportError(fmt.Errorf("generating service file %s: %w", service.Path, err))
+			if err := generateServiceFile(service); err != nil {
+				reportError(fmt.Errorf("generating service file %s: %w", service.Path, err))
+			}
+			enableServiceFile(outputPath, service)
 		}
-		enableServiceFile(outputPath, service)
 	}
 	return prevError
 }
@@ -262,13 +262,24 @@ func serve(listener net.Listener, pm rkport.Manager) {
 	}
 }
 
+// handler reloads the forwarded ports.  The request is either the new child
+// IP as JSON string, in which case the existing ports are forwarded to it, or
+// a rootlessport.ReloadConfig which replaces all ports.
 func handler(ctx context.Context, conn io.Reader, pm rkport.Manager) error {
-	var childIP string
+	var msg json.RawMessage
 	dec := json.NewDecoder(conn)
-	err := dec.Decode(&childIP)
+	err := dec.Decode(&msg)
 	if err != nil {
 		return fmt.Errorf("rootless port failed to decode ports: %w", err)
 	}
+	var childIP string
+	if err := json.Unmarshal(msg, &childIP); err != nil {
+		var cfg rootlessport.ReloadConfig
+		if err := json.Unmarshal(msg, &cfg); err != nil {
+			return fmt.Errorf("rootless port failed to decode ports: %w", err)
+		}
+		return replacePorts(ctx, pm, cfg)
+	}
 	portStatus, err := pm.ListPorts(ctx)
 	if err != nil {
 		return fmt.Errorf("rootless port failed to list ports: %w", err)
@@ -291,6 +302,52 @@ func handler(ctx context.Context, conn io.Reader, pm rkport.Manager) error {
 	return nil
 }
 
+// replacePorts removes all forwarded ports and forwards the ports of cfg instead.
+// If a port cannot be forwarded, the previous ports are forwarded again.
+func replacePorts(ctx context.Context, pm rkport.Manager, cfg rootlessport.ReloadConfig) error {
+	portStatus, err := pm.ListPorts(ctx)
+	if err != nil {
+		return fmt.Errorf("rootless port failed to list ports: %w", err)
+	}
+	if err := removePorts(ctx, pm); err != nil {
+		return err
+	}
+	if err := exposePorts(pm, cfg.Mappings, cfg.ChildIP); err != nil {
+		if rerr := restorePorts(ctx, pm, portStatus); rerr != nil {
+			return fmt.Errorf("rootless port failed to add port: %v, and to restore the previous ports: %w", err, rerr)
+		}
+		return fmt.Errorf("rootless port failed to add port: %w", err)
+	}
+	return nil
+}
+
+// restorePorts replaces the forwarded ports with the ports of portStatus.
+func restorePorts(ctx context.Context, pm rkport.Manager, portStatus []rkport.Status) error {
+	if err := removePorts(ctx, pm); err != nil {
+		return err
+	}
+	for _, status := range portStatus {
+		if _, err := pm.AddPort(ctx, status.Spec); err != nil {
+			return fmt.Errorf("rootless port failed to add port: %w", err)
+		}
+	}
+	return nil
+}
+
+// removePorts removes all forwarded ports.
+func removePorts(ctx context.Context, pm rkport.Manager) error {
+	portStatus, err := pm.ListPorts(ctx)
+	if err != nil {
+		return fmt.Errorf("rootless port failed to list ports: %w", err)
+	}
+	for _, status := range portStatus {
+		if err := pm.RemovePort(ctx, status.ID); err != nil {
+			return fmt.Errorf("rootless port failed to remove port: %w", err)
+		}
+	}
+	return nil
+}
+
 func exposePorts(pm rkport.Manager, portMappings []types.PortMapping, childIP string) error {
 	ctx := context.TODO()
 	for _, port := range portMappings {
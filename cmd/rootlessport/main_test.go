@@ -0,0 +1,87 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/containers/common/libnetwork/types"
+	rkport "github.com/rootless-containers/rootlesskit/pkg/port"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeManager struct {
+	ports  []rkport.Status
+	nextID int
+	// inUse are the parent ports which cannot be forwarded
+	inUse map[int]bool
+}
+
+func (m *fakeManager) AddPort(_ context.Context, spec rkport.Spec) (*rkport.Status, error) {
+	if m.inUse[spec.ParentPort] {
+		return nil, fmt.Errorf("listen tcp 0.0.0.0:%d: bind: address already in use", spec.ParentPort)
+	}
+	m.nextID++
+	status := rkport.Status{ID: m.nextID, Spec: spec}
+	m.ports = append(m.ports, status)
+	return &status, nil
+}
+
+func (m *fakeManager) ListPorts(_ context.Context) ([]rkport.Status, error) {
+	return append([]rkport.Status(nil), m.ports...), nil
+}
+
+func (m *fakeManager) RemovePort(_ context.Context, id int) error {
+	for i, status := range m.ports {
+		if status.ID == id {
+			m.ports = append(m.ports[:i], m.ports[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	pm := &fakeManager{}
+	err := exposePorts(pm, []types.PortMapping{{HostPort: 8080, ContainerPort: 80, Protocol: "tcp", Range: 1}}, "10.88.0.2")
+	assert.NoError(t, err)
+
+	// the old format only changes the child ip
+	err = handler(ctx, strings.NewReader(`"10.88.0.3"`), pm)
+	assert.NoError(t, err)
+	assert.Len(t, pm.ports, 1)
+	assert.Equal(t, "10.88.0.3", pm.ports[0].Spec.ChildIP)
+	assert.Equal(t, 8080, pm.ports[0].Spec.ParentPort)
+
+	// a reload config replaces all ports
+	err = handler(ctx, strings.NewReader(`{"Mappings":[{"host_port":9090,"container_port":90,"protocol":"udp","range":2}],"ChildIP":"10.88.0.4"}`), pm)
+	assert.NoError(t, err)
+	assert.Len(t, pm.ports, 2)
+	for i, status := range pm.ports {
+		assert.Equal(t, "udp", status.Spec.Proto)
+		assert.Equal(t, "10.88.0.4", status.Spec.ChildIP)
+		assert.Equal(t, 9090+i, status.Spec.ParentPort)
+		assert.Equal(t, 90+i, status.Spec.ChildPort)
+	}
+
+	err = handler(ctx, strings.NewReader(`[]`), pm)
+	assert.ErrorContains(t, err, "rootless port failed to decode ports")
+}
+
+func TestReplacePortsFailure(t *testing.T) {
+	ctx := context.Background()
+	pm := &fakeManager{inUse: map[int]bool{9091: true}}
+	err := exposePorts(pm, []types.PortMapping{{HostPort: 8080, ContainerPort: 80, Protocol: "tcp", Range: 1}}, "10.88.0.2")
+	assert.NoError(t, err)
+
+	// the previous ports are forwarded again when a new one is in use
+	err = handler(ctx, strings.NewReader(`{"Mappings":[{"host_port":9090,"container_port":90,"protocol":"tcp","range":2}],"ChildIP":"10.88.0.2"}`), pm)
+	assert.ErrorContains(t, err, "address already in use")
+	assert.Len(t, pm.ports, 1)
+	assert.Equal(t, 8080, pm.ports[0].Spec.ParentPort)
+	assert.Equal(t, 80, pm.ports[0].Spec.ChildPort)
+}
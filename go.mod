@@ -10,15 +10,15 @@ require (
 	github.com/checkpoint-restore/checkpointctl v1.1.0
 	github.com/checkpoint-restore/go-criu/v7 v7.0.0
 	github.com/containernetworking/plugins v1.4.0
-	github.com/containers/buildah v1.34.1-0.20240201124221-b850c711ff5c
-	github.com/containers/common v0.57.1-0.20240207210145-1eeaf97594e9
+	github.com/containers/buildah v1.34.1-podman.1
+	github.com/containers/common v0.57.1-podman.2
 	github.com/containers/conmon v2.0.20+incompatible
 	github.com/containers/gvisor-tap-vsock v0.7.3
-	github.com/containers/image/v5 v5.29.3-0.20240227090231-5bef5e1e1506
+	github.com/containers/image/v5 v5.29.3-podman.1
 	github.com/containers/libhvee v0.6.1-0.20240225143609-c1bda9d3838c
 	github.com/containers/ocicrypt v1.1.9
 	github.com/containers/psgo v1.9.0
-	github.com/containers/storage v1.52.1-0.20240227082351-387d7c8480b0
+	github.com/containers/storage v1.52.1-podman.1
 	github.com/containers/winquit v1.1.0
 	github.com/coreos/go-systemd/v22 v22.5.1-0.20231103132048-7d375ecc2b09
 	github.com/coreos/stream-metadata-go v0.4.4
@@ -69,6 +69,7 @@ require (
 	github.com/vbauerster/mpb/v8 v8.7.2
 	github.com/vishvananda/netlink v1.2.1-beta.2
 	go.etcd.io/bbolt v1.3.9
+	golang.org/x/crypto v0.20.0
 	golang.org/x/exp v0.0.0-20240119083558-1b970713d09a
 	golang.org/x/net v0.21.0
 	golang.org/x/sync v0.6.0
@@ -211,7 +212,6 @@ require (
 	go.opentelemetry.io/otel/sdk v1.21.0 // indirect
 	go.opentelemetry.io/otel/trace v1.22.0 // indirect
 	golang.org/x/arch v0.7.0 // indirect
-	golang.org/x/crypto v0.20.0 // indirect
 	golang.org/x/mod v0.14.0 // indirect
 	golang.org/x/oauth2 v0.17.0 // indirect
 	golang.org/x/time v0.3.0 // indirect
@@ -227,3 +227,21 @@ require (
 )
 
 replace github.com/opencontainers/runc => github.com/opencontainers/runc v1.1.1-0.20240131200429-02120488a4c0
+
+// The forks carry changes that are not in an upstream release yet, see
+// third_party/README.md. The versions required above for them are the fork
+// versions in their FORK.md.
+
+// The fork carries the changes to the chunked differ, the overlay driver and
+// the layer store.
+replace github.com/containers/storage => ./third_party/containers/storage
+
+// The fork carries the parallel layer commit changes.
+replace github.com/containers/buildah => ./third_party/containers/buildah
+
+// The fork carries the registry mirror failover and the trust report changes.
+replace github.com/containers/image/v5 => ./third_party/containers/image
+
+// The fork carries the image scan, network and bandwidth configuration
+// changes.
+replace github.com/containers/common => ./third_party/containers/common
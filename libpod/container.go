@@ -19,6 +19,7 @@ import (
 	"github.com/containers/podman/v5/libpod/define"
 	"github.com/containers/podman/v5/libpod/lock"
 	"github.com/containers/storage"
+	"github.com/docker/go-units"
 	spec "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
@@ -1158,6 +1159,30 @@ func (c *Container) RWSize() (int64, error) {
 	return c.rwSize()
 }
 
+// RWSizeLimit returns the size limit of the writable layer of the container,
+// set with the size storage option of the container or the default size
+// option of the storage driver, or 0 if the writable layer is not limited.
+func (c *Container) RWSizeLimit() (int64, error) {
+	size, ok := c.config.StorageOpts["size"]
+	if !ok {
+		driver := c.runtime.store.GraphDriverName()
+		for _, opt := range c.runtime.store.GraphOptions() {
+			key, val, _ := strings.Cut(opt, "=")
+			if strings.TrimPrefix(key, driver+".") == "size" {
+				size, ok = val, true
+			}
+		}
+	}
+	if !ok || size == "" {
+		return 0, nil
+	}
+	limit, err := units.RAMInBytes(size)
+	if err != nil {
+		return -1, fmt.Errorf("parsing size storage option %q of container %s: %w", size, c.ID(), err)
+	}
+	return limit, nil
+}
+
 // IDMappings returns the UID/GID mapping used for the container
 func (c *Container) IDMappings() storage.IDMappingOptions {
 	return c.config.IDMappings
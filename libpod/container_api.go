@@ -12,9 +12,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/containers/common/libnetwork/types"
 	"github.com/containers/common/pkg/resize"
 	"github.com/containers/podman/v5/libpod/define"
 	"github.com/containers/podman/v5/libpod/events"
+	"github.com/containers/podman/v5/pkg/rootless"
 	"github.com/containers/podman/v5/pkg/signal"
 	"github.com/containers/storage/pkg/archive"
 	spec "github.com/opencontainers/runtime-spec/specs-go"
@@ -47,13 +49,19 @@ func (c *Container) Init(ctx context.Context, recursive bool) error {
 	}
 
 	if !recursive {
-		if err := c.checkDependenciesAndHandleError(); err != nil {
+		if err := c.checkDependenciesAndHandleError(ctx); err != nil {
 			return err
 		}
 	} else {
 		if err := c.startDependencies(ctx); err != nil {
 			return err
 		}
+		// Started dependencies may still have to reach their condition.
+		if len(c.config.DependencyConditions) > 0 {
+			if err := c.checkDependenciesAndHandleError(ctx); err != nil {
+				return err
+			}
+		}
 	}
 
 	if err := c.prepare(); err != nil {
@@ -120,13 +128,115 @@ func (c *Container) Start(ctx context.Context, recursive bool) (finalErr error)
 
 // Update updates the given container.
 // only the cgroup config can be updated and therefore only a linux resource spec is passed.
+// The limits set in res are applied to the running container and kept in the
+// container's spec, so they are used again when the container is restarted.
 func (c *Container) Update(res *spec.LinuxResources) error {
-	if err := c.syncContainer(); err != nil {
-		return err
+	if !c.batched {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		if err := c.syncContainer(); err != nil {
+			return err
+		}
 	}
 	return c.update(res)
 }
 
+// UpdateHealthCheckOnFailureAction changes the action taken once the
+// healthcheck of the container turns unhealthy.  The new action is used from
+// the next healthcheck run on.
+func (c *Container) UpdateHealthCheckOnFailureAction(action define.HealthCheckOnFailureAction) error {
+	if !c.batched {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		if err := c.syncContainer(); err != nil {
+			return err
+		}
+	}
+	if !c.HasHealthCheck() {
+		return fmt.Errorf("container %s has no healthcheck: %w", c.ID(), define.ErrInvalidArg)
+	}
+
+	newConfig := new(ContainerConfig)
+	if err := JSONDeepCopy(c.config, newConfig); err != nil {
+		return err
+	}
+	newConfig.HealthCheckOnFailureAction = action
+	// SafeRewriteContainerConfig must be used with care. Only the on-failure action is changed here.
+	if err := c.runtime.state.SafeRewriteContainerConfig(c, "", "", newConfig); err != nil {
+		return fmt.Errorf("saving the healthcheck on-failure action of container %s: %w", c.ID(), err)
+	}
+	c.config = newConfig
+	return nil
+}
+
+// UpdatePortMappings replaces the ports published by the container.  If the
+// network of the container is configured, the port forwarding is updated
+// without restarting the container.
+func (c *Container) UpdatePortMappings(ports []types.PortMapping) error {
+	if !c.batched {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		if err := c.syncContainer(); err != nil {
+			return err
+		}
+	}
+	if c.config.NetNsCtr != "" {
+		return fmt.Errorf("container %s shares the network namespace of container %s, ports can only be changed on that container: %w", c.ID(), c.config.NetNsCtr, define.ErrInvalidArg)
+	}
+	if err := isBridgeNetMode(c.config.NetMode); err != nil {
+		return fmt.Errorf("cannot change published ports of container %s: %w", c.ID(), err)
+	}
+
+	newConfig := new(ContainerConfig)
+	if err := JSONDeepCopy(c.config, newConfig); err != nil {
+		return err
+	}
+	newConfig.PortMappings = ports
+	oldConfig := c.config
+	running := c.state.NetNS != "" && c.ensureState(define.ContainerStateCreated, define.ContainerStateRunning, define.ContainerStatePaused)
+	if running {
+		if err := c.updatePortForwarding(newConfig); err != nil {
+			return fmt.Errorf("updating port forwarding of container %s: %w", c.ID(), err)
+		}
+		if rootless.IsRootless() {
+			if err := c.replaceRootlessRLKPortMappings(oldConfig.PortMappings); err != nil {
+				c.restorePortForwarding(oldConfig)
+				return err
+			}
+		}
+	}
+	// The ports are only saved once they are forwarded.
+	// SafeRewriteContainerConfig must be used with care. Only the port mappings are changed here.
+	if err := c.runtime.state.SafeRewriteContainerConfig(c, "", "", newConfig); err != nil {
+		if running {
+			c.restorePortForwarding(oldConfig)
+		}
+		c.config = oldConfig
+		return fmt.Errorf("saving the port mappings of container %s: %w", c.ID(), err)
+	}
+	c.config = newConfig
+	return nil
+}
+
+// restorePortForwarding forwards the ports of oldConfig again after updating
+// the port forwarding of the running container failed.
+func (c *Container) restorePortForwarding(oldConfig *ContainerConfig) {
+	newPorts := c.config.PortMappings
+	// rootlessport still holds the old host ports, they are not checked
+	if err := c.reconfigurePortForwarding(oldConfig); err != nil {
+		logrus.Errorf("Restoring port forwarding of container %s: %v", c.ID(), err)
+		return
+	}
+	if rootless.IsRootless() {
+		if err := c.replaceRootlessRLKPortMappings(newPorts); err != nil {
+			logrus.Errorf("Restoring rootless port forwarding of container %s: %v", c.ID(), err)
+		}
+	}
+}
+
 // StartAndAttach starts a container and attaches to it.
 // This acts as a combination of the Start and Attach APIs, ensuring proper
 // ordering of the two such that no output from the container is lost (e.g. the
@@ -209,7 +319,7 @@ func (c *Container) RestartWithTimeout(ctx context.Context, timeout uint) error
 		}
 	}
 
-	if err := c.checkDependenciesAndHandleError(); err != nil {
+	if err := c.checkDependenciesAndHandleError(ctx); err != nil {
 		return err
 	}
 
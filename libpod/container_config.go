@@ -82,6 +82,11 @@ type ContainerConfig struct {
 	// These containers must be started before this container is started.
 	Dependencies []string
 
+	// DependencyConditions are the conditions dependency containers
+	// must reach before this container is started, indexed by the ID of
+	// the dependency.  Dependencies without a condition must be running.
+	DependencyConditions map[string]string `json:"dependencyConditions,omitempty"`
+
 	// rewrite is an internal bool to indicate that the config was modified after
 	// a read from the db, e.g. to migrate config fields after an upgrade.
 	// This field should never be written to the db, the json tag ensures this.
@@ -238,14 +238,14 @@ func startNode(ctx context.Context, node *containerNode, setError bool, ctrError
 	// But they could have died before we got here
 	// Does not require that the container be locked, we only need to lock
 	// the dependencies
-	depsStopped, err := node.container.checkDependenciesRunning()
+	depsStopped, err := node.container.checkDependenciesRunning(ctx)
 	if err != nil {
 		ctrErrors[node.id] = err
 		ctrErrored = true
 	} else if len(depsStopped) > 0 {
 		// Our dependencies are not running
 		depsList := strings.Join(depsStopped, ",")
-		ctrErrors[node.id] = fmt.Errorf("the following dependencies of container %s are not running: %s: %w", node.id, depsList, define.ErrCtrStateInvalid)
+		ctrErrors[node.id] = fmt.Errorf("the following dependencies of container %s are not running or did not reach their condition: %s: %w", node.id, depsList, define.ErrCtrStateInvalid)
 		ctrErrored = true
 	}
 
@@ -166,6 +166,7 @@ func (c *Container) getContainerInspectData(size bool, driverData *define.Driver
 		GraphDriver:             driverData,
 		Mounts:                  inspectMounts,
 		Dependencies:            c.Dependencies(),
+		DependencyConditions:    c.config.DependencyConditions,
 		IsInfra:                 c.IsInfra(),
 		IsService:               c.IsService(),
 		KubeExitCodePropagation: config.KubeExitCodePropagation.String(),
@@ -201,6 +202,7 @@ func (c *Container) getContainerInspectData(size bool, driverData *define.Driver
 		} else {
 			data.State.Health = &healthCheckState
 		}
+		data.State.StartupHealthCheckPassed = c.config.StartupHealthCheckConfig != nil && c.state.StartupHCPassed
 	} else {
 		data.State.Health = nil
 	}
@@ -411,6 +413,7 @@ func (c *Container) generateInspectContainerConfig(spec *spec.Spec) *define.Insp
 	ctrConfig.Healthcheck = c.config.HealthCheckConfig
 
 	ctrConfig.HealthcheckOnFailureAction = c.config.HealthCheckOnFailureAction.String()
+	ctrConfig.StartupHealthCheck = c.config.StartupHealthCheckConfig
 
 	ctrConfig.CreateCommand = c.config.CreateCommand
 
@@ -266,7 +266,7 @@ func (c *Container) handleRestartPolicy(ctx context.Context) (_ bool, retErr err
 	logrus.Debugf("Restarting container %s due to restart policy %s", c.ID(), c.config.RestartPolicy)
 
 	// Need to check if dependencies are alive.
-	if err := c.checkDependenciesAndHandleError(); err != nil {
+	if err := c.checkDependenciesAndHandleError(ctx); err != nil {
 		return false, err
 	}
 
@@ -835,13 +835,19 @@ func (c *Container) prepareToStart(ctx context.Context, recursive bool) (retErr
 	}
 
 	if !recursive {
-		if err := c.checkDependenciesAndHandleError(); err != nil {
+		if err := c.checkDependenciesAndHandleError(ctx); err != nil {
 			return err
 		}
 	} else {
 		if err := c.startDependencies(ctx); err != nil {
 			return err
 		}
+		// Started dependencies may still have to reach their condition.
+		if len(c.config.DependencyConditions) > 0 {
+			if err := c.checkDependenciesAndHandleError(ctx); err != nil {
+				return err
+			}
+		}
 	}
 
 	defer func() {
@@ -871,14 +877,14 @@ func (c *Container) prepareToStart(ctx context.Context, recursive bool) (retErr
 }
 
 // checks dependencies are running and prints a helpful message
-func (c *Container) checkDependenciesAndHandleError() error {
-	notRunning, err := c.checkDependenciesRunning()
+func (c *Container) checkDependenciesAndHandleError(ctx context.Context) error {
+	notRunning, err := c.checkDependenciesRunning(ctx)
 	if err != nil {
 		return fmt.Errorf("checking dependencies for container %s: %w", c.ID(), err)
 	}
 	if len(notRunning) > 0 {
 		depString := strings.Join(notRunning, ",")
-		return fmt.Errorf("some dependencies of container %s are not started: %s: %w", c.ID(), depString, define.ErrCtrStateInvalid)
+		return fmt.Errorf("some dependencies of container %s are not started or did not reach their condition: %s: %w", c.ID(), depString, define.ErrCtrStateInvalid)
 	}
 
 	return nil
@@ -964,7 +970,7 @@ func (c *Container) getAllDependencies(visited map[string]*Container) error {
 			}
 			// if the dependency is already running, we can assume its dependencies are also running
 			// so no need to add them to those we need to start
-			if status != define.ContainerStateRunning {
+			if status != define.ContainerStateRunning && !c.dependencyExitedSuccessfully(dep) {
 				visited[depID] = dep
 				if err := dep.getAllDependencies(visited); err != nil {
 					return err
@@ -975,9 +981,22 @@ func (c *Container) getAllDependencies(visited map[string]*Container) error {
 	return nil
 }
 
-// Check if a container's dependencies are running
+// dependencyExitedSuccessfully returns whether dep is required to exit
+// successfully and already did, so it must not be started again.
+func (c *Container) dependencyExitedSuccessfully(dep *Container) bool {
+	if c.config.DependencyConditions[dep.ID()] != define.DependencyConditionExitedSuccessfully {
+		return false
+	}
+	exitCode, exited, err := dep.ExitCode()
+	return err == nil && exited && exitCode == 0
+}
+
+// Check if a container's dependencies are running, or have reached the
+// condition they are required with.  Waits for dependencies that may still
+// reach their condition, e.g. for a running dependency to exit.
 // Returns a []string containing the IDs of dependencies that are not running
-func (c *Container) checkDependenciesRunning() ([]string, error) {
+// or did not reach their condition
+func (c *Container) checkDependenciesRunning(ctx context.Context) ([]string, error) {
 	deps := c.Dependencies()
 	notRunning := []string{}
 
@@ -991,6 +1010,18 @@ func (c *Container) checkDependenciesRunning() ([]string, error) {
 			return nil, fmt.Errorf("retrieving dependency %s of container %s from state: %w", dep, c.ID(), err)
 		}
 
+		if condition, ok := c.config.DependencyConditions[dep]; ok {
+			reached, err := depCtr.waitForDependencyCondition(ctx, condition)
+			if err != nil {
+				return nil, fmt.Errorf("waiting for dependency %s of container %s to be %s: %w", dep, c.ID(), condition, err)
+			}
+			if !reached {
+				notRunning = append(notRunning, dep)
+			}
+			depCtrs[dep] = depCtr
+			continue
+		}
+
 		// Check the status
 		state, err := depCtr.State()
 		if err != nil {
@@ -1005,6 +1036,55 @@ func (c *Container) checkDependenciesRunning() ([]string, error) {
 	return notRunning, nil
 }
 
+// waitForDependencyCondition waits until the container reached the given
+// dependency condition or cannot reach it anymore, and returns whether it
+// reached the condition.
+func (c *Container) waitForDependencyCondition(ctx context.Context, condition string) (bool, error) {
+	state, err := c.State()
+	if err != nil {
+		return false, err
+	}
+	switch condition {
+	case define.DependencyConditionStarted:
+		return state == define.ContainerStateRunning, nil
+	case define.DependencyConditionHealthy:
+		if state != define.ContainerStateRunning {
+			return false, nil
+		}
+		if _, err := c.WaitForConditionWithInterval(ctx, DefaultWaitInterval, define.HealthCheckHealthy, define.HealthCheckUnhealthy, define.ContainerStateExited.String()); err != nil {
+			return false, err
+		}
+		// The container may have exited while still being marked healthy.
+		state, err := c.State()
+		if err != nil {
+			return false, err
+		}
+		status, err := c.HealthCheckStatus()
+		if err != nil {
+			return false, err
+		}
+		return state == define.ContainerStateRunning && status == define.HealthCheckHealthy, nil
+	case define.DependencyConditionExitedSuccessfully:
+		switch state {
+		case define.ContainerStateRunning, define.ContainerStatePaused, define.ContainerStateStopping:
+			exitCode, err := c.WaitForExit(ctx, DefaultWaitInterval)
+			if err != nil {
+				return false, err
+			}
+			return exitCode == 0, nil
+		case define.ContainerStateStopped, define.ContainerStateExited:
+			exitCode, exited, err := c.ExitCode()
+			if err != nil {
+				return false, err
+			}
+			return exited && exitCode == 0, nil
+		default:
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("unknown dependency condition %q", condition)
+}
+
 func (c *Container) completeNetworkSetup() error {
 	netDisabled, err := c.NetworkDisabled()
 	if err != nil {
@@ -2521,10 +2601,184 @@ func (c *Container) extractSecretToCtrStorage(secr *ContainerSecret) error {
 }
 
 // update calls the ociRuntime update function to modify a cgroup config after container creation
+// and merges the new limits into the spec of the container.
 func (c *Container) update(resources *spec.LinuxResources) error {
-	if err := c.ociRuntime.UpdateContainer(c, resources); err != nil {
+	if c.ensureState(define.ContainerStateRunning, define.ContainerStatePaused) {
+		if err := c.ociRuntime.UpdateContainer(c, resources); err != nil {
+			return err
+		}
+		// Keep the spec of the current run in sync so inspect shows the new limits.
+		runSpec, err := c.specFromState()
+		if err != nil {
+			return err
+		}
+		if runSpec != c.config.Spec && runSpec.Linux != nil {
+			if runSpec.Linux.Resources == nil {
+				runSpec.Linux.Resources = &spec.LinuxResources{}
+			}
+			mergeResources(runSpec.Linux.Resources, resources)
+			specJSON, err := json.Marshal(runSpec)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(c.state.ConfigPath, specJSON, 0644); err != nil {
+				return fmt.Errorf("saving container %s spec: %w", c.ID(), err)
+			}
+		}
+	}
+
+	newConfig := new(ContainerConfig)
+	if err := JSONDeepCopy(c.config, newConfig); err != nil {
 		return err
 	}
+	if newConfig.Spec.Linux == nil {
+		newConfig.Spec.Linux = &spec.Linux{}
+	}
+	if newConfig.Spec.Linux.Resources == nil {
+		newConfig.Spec.Linux.Resources = &spec.LinuxResources{}
+	}
+	mergeResources(newConfig.Spec.Linux.Resources, resources)
+	// SafeRewriteContainerConfig must be used with care. Only the resources of the spec are changed here.
+	if err := c.runtime.state.SafeRewriteContainerConfig(c, "", "", newConfig); err != nil {
+		return fmt.Errorf("saving the resources of container %s: %w", c.ID(), err)
+	}
+	c.config = newConfig
+
 	logrus.Debugf("updated container %s", c.ID())
 	return nil
 }
+
+// mergeResources sets the limits given in update on res. Limits that are not
+// set in update are kept, per device and per page size limits replace the
+// limits of the same device or page size only.
+func mergeResources(res, update *spec.LinuxResources) {
+	if update == nil {
+		return
+	}
+	if update.Memory != nil {
+		if res.Memory == nil {
+			res.Memory = &spec.LinuxMemory{}
+		}
+		m, u := res.Memory, update.Memory
+		if u.Limit != nil {
+			m.Limit = u.Limit
+		}
+		if u.Reservation != nil {
+			m.Reservation = u.Reservation
+		}
+		if u.Swap != nil {
+			m.Swap = u.Swap
+		}
+		if u.Swappiness != nil {
+			m.Swappiness = u.Swappiness
+		}
+		if u.DisableOOMKiller != nil {
+			m.DisableOOMKiller = u.DisableOOMKiller
+		}
+	}
+	if update.CPU != nil {
+		if res.CPU == nil {
+			res.CPU = &spec.LinuxCPU{}
+		}
+		c, u := res.CPU, update.CPU
+		if u.Shares != nil {
+			c.Shares = u.Shares
+		}
+		if u.Quota != nil {
+			c.Quota = u.Quota
+		}
+		if u.Period != nil {
+			c.Period = u.Period
+		}
+		if u.RealtimeRuntime != nil {
+			c.RealtimeRuntime = u.RealtimeRuntime
+		}
+		if u.RealtimePeriod != nil {
+			c.RealtimePeriod = u.RealtimePeriod
+		}
+		if u.Cpus != "" {
+			c.Cpus = u.Cpus
+		}
+		if u.Mems != "" {
+			c.Mems = u.Mems
+		}
+	}
+	if update.Pids != nil {
+		res.Pids = update.Pids
+	}
+	if update.BlockIO != nil {
+		if res.BlockIO == nil {
+			res.BlockIO = &spec.LinuxBlockIO{}
+		}
+		b, u := res.BlockIO, update.BlockIO
+		if u.Weight != nil {
+			b.Weight = u.Weight
+		}
+		if u.LeafWeight != nil {
+			b.LeafWeight = u.LeafWeight
+		}
+		for _, wd := range u.WeightDevice {
+			b.WeightDevice = mergeWeightDevice(b.WeightDevice, wd)
+		}
+		b.ThrottleReadBpsDevice = mergeThrottleDevices(b.ThrottleReadBpsDevice, u.ThrottleReadBpsDevice)
+		b.ThrottleWriteBpsDevice = mergeThrottleDevices(b.ThrottleWriteBpsDevice, u.ThrottleWriteBpsDevice)
+		b.ThrottleReadIOPSDevice = mergeThrottleDevices(b.ThrottleReadIOPSDevice, u.ThrottleReadIOPSDevice)
+		b.ThrottleWriteIOPSDevice = mergeThrottleDevices(b.ThrottleWriteIOPSDevice, u.ThrottleWriteIOPSDevice)
+	}
+	for _, hp := range update.HugepageLimits {
+		found := false
+		for i := range res.HugepageLimits {
+			if res.HugepageLimits[i].Pagesize == hp.Pagesize {
+				res.HugepageLimits[i] = hp
+				found = true
+				break
+			}
+		}
+		if !found {
+			res.HugepageLimits = append(res.HugepageLimits, hp)
+		}
+	}
+	for dev, limit := range update.Rdma {
+		if res.Rdma == nil {
+			res.Rdma = make(map[string]spec.LinuxRdma)
+		}
+		res.Rdma[dev] = limit
+	}
+	for key, val := range update.Unified {
+		if res.Unified == nil {
+			res.Unified = make(map[string]string)
+		}
+		res.Unified[key] = val
+	}
+}
+
+// mergeWeightDevice replaces the weight of the device of dev in devs, or
+// appends dev if devs has no weight for the device yet.
+func mergeWeightDevice(devs []spec.LinuxWeightDevice, dev spec.LinuxWeightDevice) []spec.LinuxWeightDevice {
+	for i := range devs {
+		if devs[i].Major == dev.Major && devs[i].Minor == dev.Minor {
+			devs[i] = dev
+			return devs
+		}
+	}
+	return append(devs, dev)
+}
+
+// mergeThrottleDevices replaces the rates of the devices of update in devs,
+// and appends the rates of devices devs has no rate for yet.
+func mergeThrottleDevices(devs, update []spec.LinuxThrottleDevice) []spec.LinuxThrottleDevice {
+	for _, dev := range update {
+		found := false
+		for i := range devs {
+			if devs[i].Major == dev.Major && devs[i].Minor == dev.Minor {
+				devs[i] = dev
+				found = true
+				break
+			}
+		}
+		if !found {
+			devs = append(devs, dev)
+		}
+	}
+	return devs
+}
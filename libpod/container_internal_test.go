@@ -206,6 +206,53 @@ func TestPostDeleteHooks(t *testing.T) {
 	assert.Equal(t, strings.TrimSuffix(string(content), "\n"), dir)
 }
 
+func TestMergeResources(t *testing.T) {
+	limit, newLimit, swap := int64(1<<30), int64(2<<30), int64(4<<30)
+	quota, period := int64(50000), uint64(100000)
+	weight, newWeight := uint16(100), uint16(200)
+	res := &rspec.LinuxResources{
+		Memory: &rspec.LinuxMemory{Limit: &limit, Swap: &swap},
+		CPU:    &rspec.LinuxCPU{Quota: &quota, Period: &period, Cpus: "0-1"},
+		BlockIO: &rspec.LinuxBlockIO{
+			WeightDevice: []rspec.LinuxWeightDevice{
+				{LinuxBlockIODevice: rspec.LinuxBlockIODevice{Major: 8, Minor: 0}, Weight: &weight},
+			},
+			ThrottleReadBpsDevice: []rspec.LinuxThrottleDevice{
+				{LinuxBlockIODevice: rspec.LinuxBlockIODevice{Major: 8, Minor: 0}, Rate: 1000},
+			},
+		},
+		HugepageLimits: []rspec.LinuxHugepageLimit{{Pagesize: "2MB", Limit: 1 << 20}},
+	}
+	handles := uint32(3)
+	mergeResources(res, &rspec.LinuxResources{
+		Memory: &rspec.LinuxMemory{Limit: &newLimit},
+		CPU:    &rspec.LinuxCPU{Mems: "0"},
+		Pids:   &rspec.LinuxPids{Limit: 100},
+		BlockIO: &rspec.LinuxBlockIO{
+			WeightDevice: []rspec.LinuxWeightDevice{
+				{LinuxBlockIODevice: rspec.LinuxBlockIODevice{Major: 8, Minor: 0}, Weight: &newWeight},
+			},
+			ThrottleReadBpsDevice: []rspec.LinuxThrottleDevice{
+				{LinuxBlockIODevice: rspec.LinuxBlockIODevice{Major: 8, Minor: 16}, Rate: 2000},
+			},
+		},
+		HugepageLimits: []rspec.LinuxHugepageLimit{{Pagesize: "2MB", Limit: 2 << 20}, {Pagesize: "1GB", Limit: 1 << 30}},
+		Rdma:           map[string]rspec.LinuxRdma{"mlx5_0": {HcaHandles: &handles}},
+	})
+
+	assert.Equal(t, newLimit, *res.Memory.Limit)
+	assert.Equal(t, swap, *res.Memory.Swap)
+	assert.Equal(t, quota, *res.CPU.Quota)
+	assert.Equal(t, "0-1", res.CPU.Cpus)
+	assert.Equal(t, "0", res.CPU.Mems)
+	assert.Equal(t, int64(100), res.Pids.Limit)
+	assert.Len(t, res.BlockIO.WeightDevice, 1)
+	assert.Equal(t, newWeight, *res.BlockIO.WeightDevice[0].Weight)
+	assert.Len(t, res.BlockIO.ThrottleReadBpsDevice, 2)
+	assert.Equal(t, []rspec.LinuxHugepageLimit{{Pagesize: "2MB", Limit: 2 << 20}, {Pagesize: "1GB", Limit: 1 << 30}}, res.HugepageLimits)
+	assert.Equal(t, handles, *res.Rdma["mlx5_0"].HcaHandles)
+}
+
 func init() {
 	if runtime.GOOS != "windows" {
 		hookPath = "/bin/sh"
@@ -27,6 +27,19 @@ var RestartPolicyMap = map[string]string{
 	RestartPolicyUnlessStopped: RestartPolicyUnlessStopped,
 }
 
+// Valid conditions of a dependency container.
+const (
+	// DependencyConditionStarted requires the dependency to be running.
+	// It is the default condition.
+	DependencyConditionStarted = "started"
+	// DependencyConditionHealthy requires the dependency to be running and
+	// healthy.
+	DependencyConditionHealthy = "healthy"
+	// DependencyConditionExitedSuccessfully requires the dependency to
+	// have exited with exit code 0.
+	DependencyConditionExitedSuccessfully = "exited-successfully"
+)
+
 // InitContainerTypes
 const (
 	// AlwaysInitContainer is an init container that runs on each
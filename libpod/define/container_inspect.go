@@ -57,6 +57,9 @@ type InspectContainerConfig struct {
 	Healthcheck *manifest.Schema2HealthConfig `json:"Healthcheck,omitempty"`
 	// HealthcheckOnFailureAction defines an action to take once the container turns unhealthy.
 	HealthcheckOnFailureAction string `json:"HealthcheckOnFailureAction,omitempty"`
+	// Configured startup healthcheck for the container, it runs in place
+	// of the regular healthcheck until it passes.
+	StartupHealthCheck *StartupHealthCheck `json:"StartupHealthCheck,omitempty"`
 	// CreateCommand is the full command plus arguments of the process the
 	// container has been created with.
 	CreateCommand []string `json:"CreateCommand,omitempty"`
@@ -229,6 +232,9 @@ type InspectContainerState struct {
 	RestoreLog     string              `json:"RestoreLog,omitempty"`
 	Restored       bool                `json:"Restored,omitempty"`
 	StoppedByUser  bool                `json:"StoppedByUser,omitempty"`
+	// StartupHealthCheckPassed is set once the startup healthcheck of the
+	// container passed and the regular healthcheck took over.
+	StartupHealthCheckPassed bool `json:"StartupHealthCheckPassed,omitempty"`
 }
 
 // Healthcheck returns the HealthCheckResults. This is used for old podman compat
@@ -690,6 +696,7 @@ type InspectContainerData struct {
 	SizeRootFs              int64                       `json:"SizeRootFs,omitempty"`
 	Mounts                  []InspectMount              `json:"Mounts"`
 	Dependencies            []string                    `json:"Dependencies"`
+	DependencyConditions    map[string]string           `json:"DependencyConditions,omitempty"`
 	NetworkSettings         *InspectNetworkSettings     `json:"NetworkSettings"`
 	Namespace               string                      `json:"Namespace"`
 	IsInfra                 bool                        `json:"IsInfra"`
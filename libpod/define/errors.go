@@ -18,6 +18,10 @@ var (
 	// ErrNoSuchVolume indicates the requested volume does not exist
 	ErrNoSuchVolume = errors.New("no such volume")
 
+	// ErrNoSuchVolumeSnapshot indicates the requested volume snapshot does
+	// not exist
+	ErrNoSuchVolumeSnapshot = errors.New("no such volume snapshot")
+
 	// ErrNoSuchNetwork indicates the requested network does not exist
 	ErrNoSuchNetwork = types.ErrNoSuchNetwork
 
@@ -50,6 +54,9 @@ var (
 	ErrImageExists = errors.New("image already exists")
 	// ErrVolumeExists indicates a volume with the same name already exists
 	ErrVolumeExists = errors.New("volume already exists")
+	// ErrVolumeSnapshotExists indicates a snapshot with the same name
+	// already exists for the volume
+	ErrVolumeSnapshotExists = errors.New("volume snapshot already exists")
 	// ErrExecSessionExists indicates an exec session with the same ID
 	// already exists.
 	ErrExecSessionExists = errors.New("exec session already exists")
@@ -215,4 +222,9 @@ var (
 	// ErrRemovingCtrs indicates that there was an error removing all
 	// containers from a pod.
 	ErrRemovingCtrs = errors.New("removing pod containers")
+
+	// ErrImageScanBlocked indicates that a container cannot be created
+	// from an image because a scan of the image found vulnerabilities
+	// of the severity blocked in containers.conf.
+	ErrImageScanBlocked = errors.New("image blocked by scan policy")
 )
@@ -1,6 +1,8 @@
 package define
 
 import (
+	"time"
+
 	"github.com/containers/common/libnetwork/types"
 	"github.com/containers/storage/pkg/idtools"
 )
@@ -12,8 +14,36 @@ type Info struct {
 	Host       *HostInfo              `json:"host"`
 	Store      *StoreInfo             `json:"store"`
 	Registries map[string]interface{} `json:"registries"`
-	Plugins    Plugins                `json:"plugins"`
-	Version    Version                `json:"version"`
+	// MirrorHealth describes the registry mirrors accessed by recent pulls.
+	MirrorHealth []MirrorHealth `json:"mirrorHealth,omitempty"`
+	Plugins      Plugins        `json:"plugins"`
+	Version      Version        `json:"version"`
+}
+
+// MirrorHealth describes the health of a registry mirror as observed by
+// recent pulls.  Unhealthy mirrors are skipped by pulls until they are due
+// for another attempt.
+type MirrorHealth struct {
+	// Location of the mirror as configured in registries.conf.
+	Location string `json:"location"`
+	// Healthy is false while the mirror is being skipped.
+	Healthy bool `json:"healthy"`
+	// Successes is the number of successful accesses of the mirror.
+	Successes uint64 `json:"successes"`
+	// Failures is the number of failed accesses of the mirror.
+	Failures uint64 `json:"failures"`
+	// ConsecutiveFailures is the number of failures since the last
+	// successful access.
+	ConsecutiveFailures uint64 `json:"consecutiveFailures"`
+	// Latency is the moving average of the time it took to access the
+	// mirror.
+	Latency string `json:"latency,omitempty"`
+	// LastSuccess is the time of the last successful access.
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	// LastFailure is the time of the last failed access.
+	LastFailure *time.Time `json:"lastFailure,omitempty"`
+	// LastError is the error of the last failed access.
+	LastError string `json:"lastError,omitempty"`
 }
 
 // SecurityInfo describes the libpod host
@@ -21,6 +21,8 @@ type InspectPodData struct {
 	CreateCommand []string `json:"CreateCommand,omitempty"`
 	// ExitPolicy of the pod.
 	ExitPolicy string `json:"ExitPolicy,omitempty"`
+	// ExitCodePropagation of the pod.
+	ExitCodePropagation string `json:"ExitCodePropagation,omitempty"`
 	// State represents the current state of the pod.
 	State string `json:"State"`
 	// Hostname is the hostname that the pod will set.
@@ -79,6 +81,8 @@ type InspectPodData struct {
 	MemoryLimit uint64 `json:"memory_limit,omitempty"`
 	// MemorySwap contains the specified memory swap limit for the pod
 	MemorySwap uint64 `json:"memory_swap,omitempty"`
+	// PidsLimit contains the specified pids limit for the pod
+	PidsLimit int64 `json:"pids_limit,omitempty"`
 	// BlkioWeight contains the blkio weight limit for the pod
 	BlkioWeight uint64 `json:"blkio_weight,omitempty"`
 	// BlkioWeightDevice contains the blkio weight device limits for the pod
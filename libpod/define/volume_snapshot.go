@@ -0,0 +1,37 @@
+package define
+
+import (
+	"time"
+)
+
+const (
+	// VolumeSnapshotBackendBtrfs snapshots volumes whose data is a btrfs
+	// subvolume.
+	VolumeSnapshotBackendBtrfs = "btrfs"
+	// VolumeSnapshotBackendZFS snapshots volumes whose data is a ZFS
+	// dataset.
+	VolumeSnapshotBackendZFS = "zfs"
+	// VolumeSnapshotBackendLVMThin snapshots volumes on a thin provisioned
+	// LVM logical volume.
+	VolumeSnapshotBackendLVMThin = "lvm-thin"
+	// VolumeSnapshotBackendReflink copies the volume data with reflinks.
+	VolumeSnapshotBackendReflink = "reflink"
+	// VolumeSnapshotBackendCopy copies the volume data.
+	VolumeSnapshotBackendCopy = "copy"
+)
+
+// VolumeSnapshot describes a snapshot of a volume.
+type VolumeSnapshot struct {
+	// Name of the snapshot, unique per volume.
+	Name string `json:"Name"`
+	// Volume is the name of the snapshotted volume.
+	Volume string `json:"Volume"`
+	// Backend which holds the snapshot, one of the VolumeSnapshotBackend
+	// constants.
+	Backend string `json:"Backend"`
+	// Source is the ZFS dataset or LVM logical volume the snapshot was
+	// taken of, empty for the other backends.
+	Source string `json:"Source,omitempty"`
+	// CreatedAt is the time the snapshot was taken.
+	CreatedAt time.Time `json:"CreatedAt"`
+}
@@ -6,9 +6,12 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"sync"
 
 	"github.com/containers/podman/v5/libpod/events"
+	graphdriver "github.com/containers/storage/drivers"
+	digest "github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
 )
 
@@ -22,6 +25,8 @@ func (r *Runtime) newEventer() (events.Eventer, error) {
 		EventerType:    r.config.Engine.EventsLogger,
 		LogFilePath:    r.config.Engine.EventsLogFilePath,
 		LogFileMaxSize: r.config.Engine.EventsLogMaxSize(),
+		// kept with the state so that events survive reboots
+		DBPath: filepath.Join(r.config.Engine.StaticDir, "events", "events.db"),
 	}
 	return events.NewEventer(options)
 }
@@ -160,6 +165,37 @@ func (r *Runtime) NewSystemEvent(status events.Status) {
 	}
 }
 
+// NewPartialPullEvent creates a new event for a layer of image that was
+// pulled partially, with the statistics of the pull as attributes.
+func (r *Runtime) NewPartialPullEvent(image string, blobDigest digest.Digest, stats *graphdriver.PullStats) {
+	e := events.NewEvent(events.PartialPull)
+	e.Type = events.Image
+	e.ID = blobDigest.String()
+	e.Name = image
+	e.Attributes = PullStatsAttributes(stats)
+	if err := r.eventer.Write(e); err != nil {
+		logrus.Errorf("Unable to write image event: %q", err)
+	}
+}
+
+// PullStatsAttributes returns the statistics of a partial pull as event
+// attributes.  The sizes are in bytes, the dedup hits are keyed by
+// "dedup:<source>" and the phase durations by "phase:<name>".
+func PullStatsAttributes(stats *graphdriver.PullStats) map[string]string {
+	attributes := map[string]string{
+		"reused":     strconv.FormatInt(stats.ReusedBytes, 10),
+		"downloaded": strconv.FormatInt(stats.DownloadedBytes, 10),
+		"holes":      strconv.FormatInt(stats.HoleBytes, 10),
+	}
+	for source, hits := range stats.DedupHits {
+		attributes["dedup:"+source] = strconv.Itoa(hits)
+	}
+	for phase, d := range stats.Phases {
+		attributes["phase:"+phase] = d.String()
+	}
+	return attributes
+}
+
 // newVolumeEvent creates a new event for a libpod volume
 func (v *Volume) newVolumeEvent(status events.Status) {
 	e := events.NewEvent(status)
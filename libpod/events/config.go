@@ -18,6 +18,8 @@ const (
 	Null EventerType = iota
 	// Memory indicates the event logger will hold events in memory
 	Memory EventerType = iota
+	// SQLite indicates the events should be kept in a SQLite database
+	SQLite EventerType = iota
 )
 
 // Event describes the attributes of a libpod event
@@ -62,13 +64,17 @@ type Details struct {
 // EventerOptions describe options that need to be passed to create
 // an eventer
 type EventerOptions struct {
-	// EventerType describes whether to use journald, file or memory
+	// EventerType describes whether to use journald, file, sqlite or memory
 	EventerType string
 	// LogFilePath is the path to where the log file should reside if using
 	// the file logger
 	LogFilePath string
-	// LogFileMaxSize is the default limit used for rotating the log file
+	// LogFileMaxSize is the default limit used for rotating the log file,
+	// and the size above which the oldest events are dropped from the
+	// database
 	LogFileMaxSize uint64
+	// DBPath is the path of the database if using the sqlite logger
+	DBPath string
 }
 
 // Eventer is the interface for journald or file event logging
@@ -164,6 +170,9 @@ const (
 	NetworkConnect Status = "connect"
 	// NetworkDisconnect
 	NetworkDisconnect Status = "disconnect"
+	// PartialPull indicates that a layer of an image was pulled
+	// partially, its attributes are the statistics of the pull.
+	PartialPull Status = "partial-pull"
 	// Pause ...
 	Pause Status = "pause"
 	// Prune ...
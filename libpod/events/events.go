@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/containers/storage/pkg/stringid"
@@ -24,6 +26,8 @@ func (et EventerType) String() string {
 		return "memory"
 	case Null:
 		return "none"
+	case SQLite:
+		return "sqlite"
 	default:
 		return "invalid"
 	}
@@ -40,6 +44,8 @@ func IsValidEventer(eventer string) bool {
 		return true
 	case Null.String():
 		return true
+	case SQLite.String():
+		return true
 	default:
 		return false
 	}
@@ -90,6 +96,14 @@ func (e *Event) ToHumanReadable(truncate bool) string {
 		humanFormat = fmt.Sprintf("%s %s %s %s (container=%s, name=%s)", e.Time, e.Type, e.Status, id, id, e.Network)
 	case Image:
 		humanFormat = fmt.Sprintf("%s %s %s %s %s", e.Time, e.Type, e.Status, id, e.Name)
+		if len(e.Attributes) > 0 {
+			attributes := make([]string, 0, len(e.Attributes))
+			for k, v := range e.Attributes {
+				attributes = append(attributes, fmt.Sprintf("%s=%s", k, v))
+			}
+			sort.Strings(attributes)
+			humanFormat += fmt.Sprintf(" (%s)", strings.Join(attributes, ", "))
+		}
 	case System:
 		if e.Name != "" {
 			humanFormat = fmt.Sprintf("%s %s %s %s", e.Time, e.Type, e.Status, e.Name)
@@ -188,6 +202,8 @@ func StringToStatus(name string) (Status, error) {
 		return NetworkConnect, nil
 	case NetworkDisconnect.String():
 		return NetworkDisconnect, nil
+	case PartialPull.String():
+		return PartialPull, nil
 	case Pause.String():
 		return Pause, nil
 	case Prune.String():
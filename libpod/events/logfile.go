@@ -11,6 +11,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/containers/podman/v5/pkg/util"
@@ -134,6 +135,7 @@ func (e EventLogFile) Read(ctx context.Context, options ReadOptions) error {
 	// Get the time *before* starting to read.  Comparing the timestamps
 	// with events avoids returning events more than once after a log-file
 	// rotation.
+	var rotated *os.File
 	readTime, err := func() (time.Time, error) {
 		// We need to lock events file
 		lock, err := lockfile.GetLockFile(e.options.LogFilePath + ".lock")
@@ -142,11 +144,26 @@ func (e EventLogFile) Read(ctx context.Context, options ReadOptions) error {
 		}
 		lock.Lock()
 		defer lock.Unlock()
+		// Events dropped by earlier rotations are older than the
+		// ones in the log file, so they must be read first.
+		if options.FromStart || !options.Stream {
+			rotated, err = os.Open(rotatedLogPath(e.options.LogFilePath))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return time.Time{}, err
+			}
+		}
 		return time.Now(), nil
 	}()
 	if err != nil {
 		return err
 	}
+	if rotated != nil {
+		err := readRotatedLog(ctx, rotated, filterMap, options.EventChannel)
+		rotated.Close()
+		if err != nil {
+			return fmt.Errorf("reading rotated events: %w", err)
+		}
+	}
 
 	var line *tail.Line
 	var ok bool
@@ -200,6 +217,35 @@ func (e EventLogFile) Read(ctx context.Context, options ReadOptions) error {
 	}
 }
 
+// readRotatedLog sends the events of the rotated log file that match the
+// filters to the event channel.
+func readRotatedLog(ctx context.Context, f *os.File, filterMap map[string][]EventFilter, eventChannel chan *Event) error {
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			event, err := newEventFromJSONString(line)
+			if err != nil {
+				return err
+			}
+			// rotate events of older rotations are meaningless here
+			if event.Type != System && applyFilters(event, filterMap) {
+				select {
+				case <-ctx.Done():
+					return nil
+				case eventChannel <- event:
+				}
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
 // String returns a string representation of the logger
 func (e EventLogFile) String() string {
 	return LogFile.String()
@@ -294,12 +340,19 @@ func truncate(filePath string) error {
 		return err
 	}
 	reader := bufio.NewReader(orig)
-	if _, err := reader.ReadString('\n'); err != nil {
+	dropped, err := reader.ReadString('\n')
+	if err != nil {
 		if !errors.Is(err, io.EOF) {
 			return err
 		}
 	}
 
+	// Keep the dropped events in the rotated log file, so they can still
+	// be read after the rotation.
+	if err := writeRotatedLog(filePath, io.MultiReader(io.NewSectionReader(orig, 0, threshold), strings.NewReader(dropped))); err != nil {
+		return fmt.Errorf("writing rotated events: %w", err)
+	}
+
 	if err := writeRotateEvent(tmp, filePath, true); err != nil {
 		return fmt.Errorf("writing rotation event begin marker: %w", err)
 	}
@@ -317,6 +370,28 @@ func truncate(filePath string) error {
 	return nil
 }
 
+// rotatedLogPath returns the path of the file holding the events dropped by
+// the last rotation of logfile.
+func rotatedLogPath(logfile string) string {
+	return logfile + ".1"
+}
+
+// writeRotatedLog replaces the rotated log file of logfile with the content
+// of r.
+func writeRotatedLog(logfile string, r io.Reader) error {
+	tmp, err := os.CreateTemp(path.Dir(logfile), "")
+	if err != nil {
+		return err
+	}
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return renameLog(tmp.Name(), rotatedLogPath(logfile))
+}
+
 // Renames from, to
 func renameLog(from, to string) error {
 	err := os.Rename(from, to)
@@ -32,6 +32,7 @@ func TestRotateLog(t *testing.T) {
 		tmp, err := os.CreateTemp("", "log-rotation-")
 		require.NoError(t, err)
 		defer os.Remove(tmp.Name())
+		defer os.Remove(rotatedLogPath(tmp.Name()))
 		defer tmp.Close()
 
 		// Create dummy file and content.
@@ -81,6 +82,7 @@ func TestTruncationOutput(t *testing.T) {
 	tmp, err := os.CreateTemp("", "log-rotation")
 	require.NoError(t, err)
 	defer os.Remove(tmp.Name())
+	defer os.Remove(rotatedLogPath(tmp.Name()))
 	defer tmp.Close()
 
 	// Write content before truncation to dummy file
@@ -102,6 +104,11 @@ func TestTruncationOutput(t *testing.T) {
 	require.Equal(t, split[1:6], []string{"6", "7", "8", "9", "10"})
 	require.Contains(t, split[6], "\"Attributes\":{\"io.podman.event.rotate\":\"end\"}")
 	require.Contains(t, split[7], "")
+
+	// The dropped lines are kept in the rotated log file
+	rotated, err := os.ReadFile(rotatedLogPath(tmp.Name()))
+	require.NoError(t, err)
+	require.Equal(t, "0\n1\n2\n3\n4\n5\n", string(rotated))
 }
 
 func TestRenameLog(t *testing.T) {
@@ -0,0 +1,229 @@
+//go:build (linux || freebsd) && !remote
+
+package events
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/util"
+	"github.com/sirupsen/logrus"
+
+	// SQLite backend for database/sql
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	// sqliteEventsOptions are the options the database is opened with,
+	// see the ones of the SQLite state in libpod
+	sqliteEventsOptions = "?_loc=auto&_sync=NORMAL&_journal_mode=WAL&_busy_timeout=100000"
+
+	// sqlitePollInterval is how often new events are looked up when
+	// streaming
+	sqlitePollInterval = 250 * time.Millisecond
+)
+
+// EventSQLite is an eventer keeping the events in a SQLite database, indexed
+// by time, type and name, so that previous events can be queried without
+// reading them all.  The database is kept across reboots.
+type EventSQLite struct {
+	options EventerOptions
+	conn    *sql.DB
+}
+
+// newSQLiteEventer opens, or creates, the events database at
+// options.DBPath.
+func newSQLiteEventer(options EventerOptions) (*EventSQLite, error) {
+	if options.DBPath == "" {
+		return nil, errors.New("no path given for the events database")
+	}
+	if err := os.MkdirAll(filepath.Dir(options.DBPath), 0700); err != nil {
+		return nil, fmt.Errorf("creating events dirs: %w", err)
+	}
+	conn, err := sql.Open("sqlite3", options.DBPath+sqliteEventsOptions)
+	if err != nil {
+		return nil, fmt.Errorf("opening events database: %w", err)
+	}
+	if err := initSQLiteEvents(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("initializing events database: %w", err)
+	}
+	return &EventSQLite{options: options, conn: conn}, nil
+}
+
+func initSQLiteEvents(conn *sql.DB) error {
+	const schema = `
+CREATE TABLE IF NOT EXISTS Events (
+	Seq       INTEGER PRIMARY KEY AUTOINCREMENT,
+	Time      INTEGER NOT NULL,
+	Type      TEXT    NOT NULL,
+	Status    TEXT    NOT NULL,
+	ObjectID  TEXT    NOT NULL,
+	Name      TEXT    NOT NULL,
+	JSON      TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS EventsTime ON Events(Time);
+CREATE INDEX IF NOT EXISTS EventsTypeTime ON Events(Type, Time);
+CREATE INDEX IF NOT EXISTS EventsName ON Events(Name);
+`
+	_, err := conn.Exec(schema)
+	return err
+}
+
+// Write adds the event to the database and drops the oldest half of the
+// events once the database is larger than the size limit of the log file.
+func (e *EventSQLite) Write(ee Event) error {
+	eventJSONString, err := ee.ToJSONString()
+	if err != nil {
+		return err
+	}
+	if _, err := e.conn.Exec("INSERT INTO Events (Time, Type, Status, ObjectID, Name, JSON) VALUES (?, ?, ?, ?, ?, ?)",
+		ee.Time.UnixNano(), string(ee.Type), string(ee.Status), ee.ID, ee.Name, eventJSONString); err != nil {
+		return fmt.Errorf("writing event: %w", err)
+	}
+	if err := e.prune(); err != nil {
+		logrus.Warnf("Pruning events database: %v", err)
+	}
+	return nil
+}
+
+// prune drops the oldest half of the events if the database is larger than
+// LogFileMaxSize.  The freed pages are reused by later events.
+func (e *EventSQLite) prune() error {
+	if e.options.LogFileMaxSize == 0 {
+		return nil
+	}
+	var pages, pageSize, freePages uint64
+	if err := e.conn.QueryRow("PRAGMA page_count").Scan(&pages); err != nil {
+		return err
+	}
+	if err := e.conn.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return err
+	}
+	if err := e.conn.QueryRow("PRAGMA freelist_count").Scan(&freePages); err != nil {
+		return err
+	}
+	if (pages-freePages)*pageSize < e.options.LogFileMaxSize {
+		return nil
+	}
+	_, err := e.conn.Exec("DELETE FROM Events WHERE Seq <= (SELECT (MIN(Seq) + MAX(Seq)) / 2 FROM Events)")
+	return err
+}
+
+// sqliteQuery returns the query of the events after seq matching the time
+// range and the type filters, the other filters are applied to the events
+// read.
+func sqliteQuery(seq int64, since, until time.Time, types []string) (string, []any) {
+	conditions := []string{"Seq > ?"}
+	args := []any{seq}
+	if !since.IsZero() {
+		conditions = append(conditions, "Time > ?")
+		args = append(args, since.UnixNano())
+	}
+	if !until.IsZero() {
+		conditions = append(conditions, "Time < ?")
+		args = append(args, until.UnixNano())
+	}
+	if len(types) > 0 {
+		conditions = append(conditions, "Type IN (?"+strings.Repeat(", ?", len(types)-1)+")")
+		for _, t := range types {
+			args = append(args, t)
+		}
+	}
+	return "SELECT Seq, JSON FROM Events WHERE " + strings.Join(conditions, " AND ") + " ORDER BY Seq", args
+}
+
+// Read sends the events matching the options to the event channel.  Without
+// since and until, streaming starts with the events written after the call.
+func (e *EventSQLite) Read(ctx context.Context, options ReadOptions) error {
+	defer close(options.EventChannel)
+	filterMap, err := generateEventFilters(options.Filters, options.Since, options.Until)
+	if err != nil {
+		return fmt.Errorf("failed to parse event filters: %w", err)
+	}
+	var since, until time.Time
+	if len(options.Since) > 0 {
+		if since, err = util.ParseInputTime(options.Since, true); err != nil {
+			return err
+		}
+	}
+	if len(options.Until) > 0 {
+		if until, err = util.ParseInputTime(options.Until, false); err != nil {
+			return err
+		}
+	}
+	var types []string
+	for _, filter := range options.Filters {
+		if key, val, err := parseFilter(filter); err == nil && strings.EqualFold(key, "type") {
+			types = append(types, val)
+		}
+	}
+
+	var seq int64
+	if len(options.Since) == 0 && len(options.Until) == 0 && options.Stream && !options.FromStart {
+		if err := e.conn.QueryRowContext(ctx, "SELECT IFNULL(MAX(Seq), 0) FROM Events").Scan(&seq); err != nil {
+			return err
+		}
+	}
+	logrus.Debugf("Reading events from database %q", e.options.DBPath)
+
+	for {
+		query, args := sqliteQuery(seq, since, until, types)
+		if seq, err = e.readRows(ctx, query, args, filterMap, options.EventChannel); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+		if !options.Stream || (!until.IsZero() && time.Now().After(until)) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(sqlitePollInterval):
+		}
+	}
+}
+
+// readRows sends the events of the query matching the filters to the event
+// channel and returns the sequence number of the last event read.
+func (e *EventSQLite) readRows(ctx context.Context, query string, args []any, filterMap map[string][]EventFilter, eventChannel chan *Event) (int64, error) {
+	seq := args[0].(int64)
+	rows, err := e.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return seq, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var eventJSON string
+		if err := rows.Scan(&seq, &eventJSON); err != nil {
+			return seq, err
+		}
+		event, err := newEventFromJSONString(eventJSON)
+		if err != nil {
+			logrus.Errorf("Unable to decode event: %v", err)
+			continue
+		}
+		if !applyFilters(event, filterMap) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return seq, ctx.Err()
+		case eventChannel <- event:
+		}
+	}
+	return seq, rows.Err()
+}
+
+// String returns a string representation of the logger
+func (e *EventSQLite) String() string {
+	return SQLite.String()
+}
@@ -0,0 +1,111 @@
+//go:build (linux || freebsd) && !remote
+
+package events
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readSQLiteEvents(t *testing.T, e *EventSQLite, options ReadOptions) []*Event {
+	t.Helper()
+	options.EventChannel = make(chan *Event)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- e.Read(context.Background(), options)
+	}()
+	var read []*Event
+	for event := range options.EventChannel {
+		read = append(read, event)
+	}
+	require.NoError(t, <-errChan)
+	return read
+}
+
+func TestSQLiteEventer(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "events", "events.db")
+	e, err := newSQLiteEventer(EventerOptions{EventerType: SQLite.String(), DBPath: dbPath})
+	require.NoError(t, err)
+
+	now := time.Now()
+	written := []Event{
+		{Type: Container, Status: Start, ID: "1234", Name: "foo", Time: now.Add(-72 * time.Hour)},
+		{Type: Image, Status: Pull, ID: "abcd", Name: "alpine", Time: now.Add(-48 * time.Hour)},
+		{Type: Container, Status: Stop, ID: "1234", Name: "foo", Time: now.Add(-time.Hour)},
+		{Type: Container, Status: Start, ID: "5678", Name: "bar", Time: now.Add(-time.Minute)},
+	}
+	for _, event := range written {
+		require.NoError(t, e.Write(event))
+	}
+
+	// the events are kept by a new eventer, as after a restart
+	e, err = newSQLiteEventer(EventerOptions{EventerType: SQLite.String(), DBPath: dbPath})
+	require.NoError(t, err)
+
+	read := readSQLiteEvents(t, e, ReadOptions{})
+	require.Len(t, read, len(written))
+	for i, event := range read {
+		assert.Equal(t, written[i].Status, event.Status)
+		assert.True(t, written[i].Time.Equal(event.Time))
+	}
+
+	read = readSQLiteEvents(t, e, ReadOptions{Since: "50h", Filters: []string{"type=container"}})
+	require.Len(t, read, 2)
+	assert.Equal(t, Stop, read[0].Status)
+	assert.Equal(t, "bar", read[1].Name)
+
+	read = readSQLiteEvents(t, e, ReadOptions{Since: "3d1h", Until: "30m", Filters: []string{"container=foo"}})
+	require.Len(t, read, 2)
+	assert.Equal(t, Start, read[0].Status)
+	assert.Equal(t, Stop, read[1].Status)
+
+	err = e.Read(context.Background(), ReadOptions{EventChannel: make(chan *Event), Filters: []string{"foo"}})
+	assert.Error(t, err)
+}
+
+func TestSQLiteEventerStream(t *testing.T) {
+	e, err := newSQLiteEventer(EventerOptions{EventerType: SQLite.String(), DBPath: filepath.Join(t.TempDir(), "events.db")})
+	require.NoError(t, err)
+	require.NoError(t, e.Write(Event{Type: Container, Status: Start, ID: "old", Time: time.Now()}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eventChannel := make(chan *Event)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- e.Read(ctx, ReadOptions{EventChannel: eventChannel, Stream: true})
+	}()
+	// give the reader the time to look up the last event
+	time.Sleep(2 * sqlitePollInterval)
+	require.NoError(t, e.Write(Event{Type: Container, Status: Stop, ID: "new", Time: time.Now()}))
+
+	select {
+	case event := <-eventChannel:
+		assert.Equal(t, "new", event.ID)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the streamed event")
+	}
+	cancel()
+	for range eventChannel {
+	}
+	assert.NoError(t, <-errChan)
+}
+
+func TestSQLiteEventerPrune(t *testing.T) {
+	e, err := newSQLiteEventer(EventerOptions{EventerType: SQLite.String(), DBPath: filepath.Join(t.TempDir(), "events.db"), LogFileMaxSize: 64 * 1024})
+	require.NoError(t, err)
+
+	attributes := map[string]string{"padding": string(make([]byte, 512))}
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, e.Write(Event{Type: Container, Status: Start, ID: "id", Time: time.Now(), Details: Details{Attributes: attributes}}))
+	}
+	var count, first int
+	require.NoError(t, e.conn.QueryRow("SELECT COUNT(*), MIN(Seq) FROM Events").Scan(&count, &first))
+	assert.Less(t, count, 1000)
+	assert.Greater(t, first, 1)
+}
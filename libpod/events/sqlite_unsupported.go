@@ -0,0 +1,11 @@
+//go:build (linux || freebsd) && remote
+
+package events
+
+import "errors"
+
+// newSQLiteEventer always returns an error, the remote client does not
+// read or write events itself
+func newSQLiteEventer(options EventerOptions) (Eventer, error) {
+	return nil, errors.New("the sqlite events backend is not available in the remote client")
+}
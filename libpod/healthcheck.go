@@ -414,7 +414,13 @@ func (c *Container) updateHealthCheckLog(hcl define.HealthCheckLog, inStartPerio
 	if err != nil {
 		return "", fmt.Errorf("unable to marshall healthchecks for writing: %w", err)
 	}
-	return healthCheck.Status, os.WriteFile(c.healthCheckLogPath(), newResults, 0700)
+	if err := os.WriteFile(c.healthCheckLogPath(), newResults, 0700); err != nil {
+		return "", err
+	}
+	if err := c.updateNetworkAliases(healthCheck.Status != define.HealthCheckUnhealthy); err != nil {
+		logrus.Warnf("Updating network aliases of container %s: %v", c.ID(), err)
+	}
+	return healthCheck.Status, nil
 }
 
 // HealthCheckLogPath returns the path for where the health check log is
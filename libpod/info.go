@@ -75,6 +75,14 @@ func (r *Runtime) info() (*define.Info, error) {
 	info.Plugins.Log = logDrivers
 
 	info.Registries = registries
+
+	tracker, err := r.MirrorHealth()
+	if err == nil {
+		info.MirrorHealth, err = tracker.Status()
+	}
+	if err != nil {
+		logrus.Warnf("Getting the health of registry mirrors: %v", err)
+	}
 	return &info, nil
 }
 
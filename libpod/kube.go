@@ -254,6 +254,13 @@ func (v *Volume) GenerateForKube() *v1.PersistentVolumeClaim {
 		}
 	}
 
+	// Request the size limit of the volume if it has one, otherwise fall
+	// back to a reasonable default.
+	storage := resource.MustParse("1Gi")
+	if size := v.SizeLimit(); size > 0 {
+		storage = *resource.NewQuantity(int64(size), resource.BinarySI)
+	}
+
 	return &v1.PersistentVolumeClaim{
 		TypeMeta: v12.TypeMeta{
 			Kind:       "PersistentVolumeClaim",
@@ -268,7 +275,7 @@ func (v *Volume) GenerateForKube() *v1.PersistentVolumeClaim {
 		Spec: v1.PersistentVolumeClaimSpec{
 			Resources: v1.ResourceRequirements{
 				Requests: map[v1.ResourceName]resource.Quantity{
-					v1.ResourceStorage: resource.MustParse("1Gi"),
+					v1.ResourceStorage: storage,
 				},
 			},
 			AccessModes: []v1.PersistentVolumeAccessMode{
@@ -945,6 +952,18 @@ func containerToV1Container(ctx context.Context, c *Container, getService bool)
 			kubeContainer.Resources.Limits[v1.ResourceMemory] = *qty
 		}
 
+		if resources.Memory != nil &&
+			resources.Memory.Reservation != nil &&
+			*resources.Memory.Reservation > 0 {
+			if kubeContainer.Resources.Requests == nil {
+				kubeContainer.Resources.Requests = v1.ResourceList{}
+			}
+
+			qty := kubeContainer.Resources.Requests.Memory()
+			qty.Set(*resources.Memory.Reservation)
+			kubeContainer.Resources.Requests[v1.ResourceMemory] = *qty
+		}
+
 		if resources.CPU != nil &&
 			resources.CPU.Quota != nil &&
 			resources.CPU.Period != nil {
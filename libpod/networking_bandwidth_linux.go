@@ -0,0 +1,160 @@
+//go:build !remote
+
+package libpod
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/containers/common/libnetwork/types"
+	netUtil "github.com/containers/common/libnetwork/util"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// bandwidthLatency is the maximum time a packet is queued by the
+	// egress bandwidth limit before it is dropped, in microseconds.
+	bandwidthLatency = 25000
+	// minBandwidthBurst is the minimum number of bytes which can be sent
+	// at once regardless of the bandwidth limit.
+	minBandwidthBurst = 32 * 1024
+)
+
+// bandwidthLimit holds the bandwidth limits of a container interface in
+// bytes per second, zero if not limited.
+type bandwidthLimit struct {
+	iface   string
+	egress  uint64
+	ingress uint64
+}
+
+// getBandwidthLimit returns the bandwidth limits of a container on a network.
+// Limits set for the container override the limits of the network.
+func getBandwidthLimit(network *types.Network, opts types.PerNetworkOptions) (bandwidthLimit, error) {
+	limit := bandwidthLimit{iface: opts.InterfaceName}
+	for option, rate := range map[string]*uint64{
+		types.EgressBandwidthOption:  &limit.egress,
+		types.IngressBandwidthOption: &limit.ingress,
+	} {
+		value, ok := opts.Options[option]
+		if !ok {
+			value, ok = network.Options[option]
+		}
+		if !ok {
+			continue
+		}
+		bytes, err := netUtil.ParseBandwidth(value)
+		if err != nil {
+			return limit, fmt.Errorf("%s of network %s: %w", option, network.Name, err)
+		}
+		*rate = bytes
+	}
+	return limit, nil
+}
+
+// bandwidthBurst returns the number of bytes which may be sent at once with
+// the given bandwidth.
+func bandwidthBurst(rate uint64) uint32 {
+	// allow bursts of 100ms worth of traffic
+	burst := rate / 10
+	if burst < minBandwidthBurst {
+		burst = minBandwidthBurst
+	}
+	if burst > math.MaxUint32 {
+		burst = math.MaxUint32
+	}
+	return uint32(burst)
+}
+
+// setupBandwidthLimits throttles the traffic of the container interfaces on
+// networks with bandwidth limits.  Egress traffic is shaped by a token bucket
+// filter, ingress traffic exceeding the limit is dropped by a policer.
+func (r *Runtime) setupBandwidthLimits(ctrNS string, opts types.NetworkOptions) error {
+	var limits []bandwidthLimit
+	for name, netOpts := range opts.Networks {
+		network, err := r.network.NetworkInspect(name)
+		if err != nil {
+			return err
+		}
+		limit, err := getBandwidthLimit(&network, netOpts)
+		if err != nil {
+			return err
+		}
+		if limit.egress > 0 || limit.ingress > 0 {
+			limits = append(limits, limit)
+		}
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+
+	return ns.WithNetNSPath(ctrNS, func(_ ns.NetNS) error {
+		for _, limit := range limits {
+			link, err := netlink.LinkByName(limit.iface)
+			if err != nil {
+				return fmt.Errorf("retrieving network interface %s: %w", limit.iface, err)
+			}
+			if limit.egress > 0 {
+				if err := setEgressBandwidth(link, limit.egress); err != nil {
+					return fmt.Errorf("limiting egress bandwidth of network interface %s: %w", limit.iface, err)
+				}
+			}
+			if limit.ingress > 0 {
+				if err := setIngressBandwidth(link, limit.ingress); err != nil {
+					return fmt.Errorf("limiting ingress bandwidth of network interface %s: %w", limit.iface, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// setEgressBandwidth is equivalent to
+// `tc qdisc replace dev LINK root tbf rate RATE burst BURST latency 25ms`.
+func setEgressBandwidth(link netlink.Link, rate uint64) error {
+	burst := bandwidthBurst(rate)
+	qdisc := &netlink.Tbf{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: link.Attrs().Index,
+			Handle:    netlink.MakeHandle(1, 0),
+			Parent:    netlink.HANDLE_ROOT,
+		},
+		Rate:   rate,
+		Buffer: netlink.Xmittime(rate, burst),
+		Limit:  uint32(rate*bandwidthLatency/netlink.TIME_UNITS_PER_SEC) + burst,
+	}
+	return netlink.QdiscReplace(qdisc)
+}
+
+// setIngressBandwidth is equivalent to
+// `tc qdisc replace dev LINK ingress` and
+// `tc filter replace dev LINK ingress matchall action police rate RATE burst BURST drop`.
+func setIngressBandwidth(link netlink.Link, rate uint64) error {
+	ingress := &netlink.Ingress{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: link.Attrs().Index,
+			Handle:    netlink.MakeHandle(0xffff, 0),
+			Parent:    netlink.HANDLE_INGRESS,
+		},
+	}
+	if err := netlink.QdiscReplace(ingress); err != nil {
+		return err
+	}
+	police := netlink.NewPoliceAction()
+	// the policer only supports 32 bit rates
+	police.Rate = uint32(math.Min(float64(rate), math.MaxUint32))
+	police.Burst = bandwidthBurst(rate)
+	police.ExceedAction = netlink.TC_POLICE_SHOT
+	filter := &netlink.MatchAll{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: link.Attrs().Index,
+			Parent:    ingress.Handle,
+			Priority:  1,
+			Protocol:  unix.ETH_P_ALL,
+		},
+		Actions: []netlink.Action{police},
+	}
+	return netlink.FilterReplace(filter)
+}
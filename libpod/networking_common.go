@@ -7,11 +7,11 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strings"
 
 	"github.com/containers/common/libnetwork/etchosts"
 	"github.com/containers/common/libnetwork/types"
 	"github.com/containers/common/pkg/config"
-	"github.com/containers/common/pkg/machine"
 	"github.com/containers/podman/v5/libpod/define"
 	"github.com/containers/podman/v5/libpod/events"
 	"github.com/containers/podman/v5/pkg/namespaces"
@@ -25,7 +25,7 @@ import (
 // This is needed because a HostIP of 127.0.0.1 would now allow the gvproxy forwarder to reach to open ports.
 // For machine the HostIP must only be used by gvproxy and never in the VM.
 func (c *Container) convertPortMappings() []types.PortMapping {
-	if !machine.IsGvProxyBased() || len(c.config.PortMappings) == 0 {
+	if machineGvproxyAPI() == "" || len(c.config.PortMappings) == 0 {
 		return c.config.PortMappings
 	}
 	// if we run in a machine VM we have to ignore the host IP part
@@ -63,7 +63,17 @@ func (c *Container) getNetworkOptions(networkOpts map[string]types.PerNetworkOpt
 // setUpNetwork will set up the networks, on error it will also tear down the cni
 // networks. If rootless it will join/create the rootless network namespace.
 func (r *Runtime) setUpNetwork(ns string, opts types.NetworkOptions) (map[string]types.StatusBlock, error) {
-	return r.network.Setup(ns, types.SetupOptions{NetworkOptions: opts})
+	status, err := r.network.Setup(ns, types.SetupOptions{NetworkOptions: opts})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.setupBandwidthLimits(ns, opts); err != nil {
+		if err := r.teardownNetworkBackend(ns, opts); err != nil {
+			logrus.Warnf("failed to teardown network after failed setup: %v", err)
+		}
+		return nil, err
+	}
+	return status, nil
 }
 
 // getNetworkPodName return the pod name (hostname) used by dns backend.
@@ -146,6 +156,12 @@ func (r *Runtime) reloadContainerNetwork(ctr *Container) (map[string]types.Statu
 		}
 	}
 
+	return r.reconfigureContainerNetwork(ctr)
+}
+
+// reconfigureContainerNetwork sets up the network of a container again after
+// it was torn down, preserving MAC and IP addresses.
+func (r *Runtime) reconfigureContainerNetwork(ctr *Container) (map[string]types.StatusBlock, error) {
 	networkOpts, err := ctr.networks()
 	if err != nil {
 		return nil, err
@@ -171,6 +187,86 @@ func (r *Runtime) reloadContainerNetwork(ctr *Container) (map[string]types.Statu
 	return r.configureNetNS(ctr, ctr.state.NetNS)
 }
 
+// updatePortForwarding replaces the published ports of a container with a
+// configured network namespace by the ports of newConfig.  The network is torn
+// down with the old ports and set up again with the new ones.  If setting up
+// the network with the new ports fails it is set up again with the old ones.
+// On success c.config is set to newConfig, the caller must save it in the
+// database and update the rootlessport process.
+func (c *Container) updatePortForwarding(newConfig *ContainerConfig) error {
+	// rootlessport binds the host ports and would fail after the network
+	// was torn down, check that they are free first
+	if rootless.IsRootless() {
+		if err := checkNewHostPorts(c.config.PortMappings, newConfig.PortMappings); err != nil {
+			return err
+		}
+	}
+	return c.reconfigurePortForwarding(newConfig)
+}
+
+// reconfigurePortForwarding sets the network of the container up again with
+// the ports of newConfig, see updatePortForwarding.
+func (c *Container) reconfigurePortForwarding(newConfig *ContainerConfig) error {
+	oldConfig := c.config
+	if err := c.runtime.teardownNetwork(c); err != nil {
+		return fmt.Errorf("tearing down network of container %s: %w", c.ID(), err)
+	}
+	if err := c.runtime.unexposeMachinePorts(oldConfig.PortMappings); err != nil {
+		logrus.Warnf("Failed to free machine port forwarding rules: %v", err)
+	}
+
+	c.config = newConfig
+	result, err := c.runtime.reconfigureContainerNetwork(c)
+	if err != nil {
+		// restore the old ports so that the container keeps its network
+		c.config = oldConfig
+		result, rerr := c.runtime.reconfigureContainerNetwork(c)
+		if rerr != nil {
+			return fmt.Errorf("%w, the container has no network until it is restarted: setting up the network with the old ports: %v", err, rerr)
+		}
+		c.state.NetworkStatus = result
+		if err := c.save(); err != nil {
+			logrus.Errorf("Saving network status of container %s: %v", c.ID(), err)
+		}
+		return err
+	}
+	c.state.NetworkStatus = result
+	return c.save()
+}
+
+// checkNewHostPorts returns an error if a host port published by newPorts
+// but not by oldPorts is in use.
+func checkNewHostPorts(oldPorts, newPorts []types.PortMapping) error {
+	type hostPort struct {
+		ip       string
+		port     uint16
+		protocol string
+	}
+	published := make(map[hostPort]bool)
+	for _, p := range oldPorts {
+		for _, protocol := range strings.Split(p.Protocol, ",") {
+			for i := uint16(0); i < p.Range; i++ {
+				published[hostPort{p.HostIP, p.HostPort + i, protocol}] = true
+			}
+		}
+	}
+	var added []types.PortMapping
+	for _, p := range newPorts {
+		for _, protocol := range strings.Split(p.Protocol, ",") {
+			for i := uint16(0); i < p.Range; i++ {
+				if !published[hostPort{p.HostIP, p.HostPort + i, protocol}] {
+					added = append(added, types.PortMapping{HostIP: p.HostIP, HostPort: p.HostPort + i, Protocol: protocol, Range: 1})
+				}
+			}
+		}
+	}
+	files, err := bindPorts(added, false)
+	for _, f := range files {
+		f.Close()
+	}
+	return err
+}
+
 // Produce an InspectNetworkSettings containing information on the container
 // network.
 func (c *Container) getContainerNetworkInfo() (*define.InspectNetworkSettings, error) {
@@ -622,6 +718,48 @@ func getExtraNetworkAliases(c *Container) []string {
 	return alias
 }
 
+// updateNetworkAliases removes the network aliases of an unhealthy container
+// from the dns records of its networks and restores them once the container
+// is healthy again, so that aliases shared by several containers only resolve
+// to healthy ones.  The container name and extra aliases are always kept.
+func (c *Container) updateNetworkAliases(healthy bool) error {
+	if c.runtime.config.Network.NetworkBackend != string(types.Netavark) || c.config.NetNsCtr != "" {
+		return nil
+	}
+	networks, err := c.networks()
+	if err != nil {
+		return err
+	}
+	extraAliases := getExtraNetworkAliases(c)
+	for name, opts := range networks {
+		keptAliases := make([]string, 0, len(extraAliases))
+		for _, alias := range opts.Aliases {
+			if slices.Contains(extraAliases, alias) {
+				keptAliases = append(keptAliases, alias)
+			}
+		}
+		if len(keptAliases) == len(opts.Aliases) {
+			// no user defined aliases
+			continue
+		}
+		aliases := opts.Aliases
+		if !healthy {
+			aliases = keptAliases
+		}
+		net, err := c.runtime.network.NetworkInspect(name)
+		if err != nil {
+			return err
+		}
+		if !net.DNSEnabled {
+			continue
+		}
+		if err := c.runtime.network.UpdateContainerAliases(name, c.ID(), aliases); err != nil {
+			return fmt.Errorf("updating aliases of container %s on network %s: %w", c.ID(), name, err)
+		}
+	}
+	return nil
+}
+
 // DisconnectContainerFromNetwork removes a container from its network
 func (r *Runtime) DisconnectContainerFromNetwork(nameOrID, netName string, force bool) error {
 	ctr, err := r.LookupContainer(nameOrID)
@@ -197,6 +197,23 @@ func (r *Runtime) teardownNetNS(ctr *Container) error {
 
 // TODO (5.0): return the statistics per network interface
 // This would allow better compat with docker.
+// setupBandwidthLimits returns an error if bandwidth limits are set, they are
+// not supported on FreeBSD.
+func (r *Runtime) setupBandwidthLimits(_ string, opts types.NetworkOptions) error {
+	for name, netOpts := range opts.Networks {
+		network, err := r.network.NetworkInspect(name)
+		if err != nil {
+			return err
+		}
+		for _, option := range []string{types.EgressBandwidthOption, types.IngressBandwidthOption} {
+			if netOpts.Options[option] != "" || network.Options[option] != "" {
+				return fmt.Errorf("%s is not supported on FreeBSD", option)
+			}
+		}
+	}
+	return nil
+}
+
 func getContainerNetIO(ctr *Container) (map[string]define.ContainerNetworkStats, error) {
 	if ctr.state.NetNS == "" {
 		// If NetNS is nil, it was set as none, and no netNS
@@ -265,6 +282,10 @@ func (c *Container) reloadRootlessRLKPortMapping() error {
 	return errors.New("unsupported (*Container).reloadRootlessRLKPortMapping")
 }
 
+func (c *Container) replaceRootlessRLKPortMappings(oldPorts []types.PortMapping) error {
+	return errors.New("unsupported (*Container).replaceRootlessRLKPortMappings")
+}
+
 func (c *Container) setupRootlessNetwork() error {
 	return nil
 }
@@ -529,3 +529,37 @@ func Benchmark_ocicniPortsToNetTypesPorts1m(b *testing.B) {
 	b.ResetTimer()
 	benchmarkOCICNIPortsToNetTypesPorts(b, ports)
 }
+
+func Test_getBandwidthLimit(t *testing.T) {
+	network := &types.Network{
+		Name: "net",
+		Options: map[string]string{
+			types.EgressBandwidthOption:  "10mbit",
+			types.IngressBandwidthOption: "1mbps",
+		},
+	}
+
+	limit, err := getBandwidthLimit(network, types.PerNetworkOptions{InterfaceName: "eth0"})
+	assert.NoError(t, err)
+	assert.Equal(t, bandwidthLimit{iface: "eth0", egress: 1250000, ingress: 1000000}, limit)
+
+	// the options of the container override the options of the network
+	limit, err = getBandwidthLimit(network, types.PerNetworkOptions{
+		InterfaceName: "eth1",
+		Options:       map[string]string{types.EgressBandwidthOption: "800kbit"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, bandwidthLimit{iface: "eth1", egress: 100000, ingress: 1000000}, limit)
+
+	limit, err = getBandwidthLimit(&types.Network{Name: "net"}, types.PerNetworkOptions{InterfaceName: "eth0"})
+	assert.NoError(t, err)
+	assert.Equal(t, bandwidthLimit{iface: "eth0"}, limit)
+
+	_, err = getBandwidthLimit(network, types.PerNetworkOptions{Options: map[string]string{types.IngressBandwidthOption: "-1"}})
+	assert.ErrorContains(t, err, "ingress-bandwidth of network net: invalid bandwidth")
+}
+
+func Test_bandwidthBurst(t *testing.T) {
+	assert.Equal(t, uint32(minBandwidthBurst), bandwidthBurst(1000))
+	assert.Equal(t, uint32(12500000), bandwidthBurst(125000000))
+}
@@ -10,6 +10,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -19,7 +20,16 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-const machineGvproxyEndpoint = "gateway.containers.internal"
+const (
+	machineGvproxyEndpoint = "gateway.containers.internal"
+	// wslGvproxyEndpoint is the gateway of user-mode networking on WSL.
+	// Only the WSL machines with user-mode networking enabled use the DNS
+	// server of gvproxy, which resolves machineGvproxyEndpoint.
+	wslGvproxyEndpoint = "192.168.127.1"
+	// wslUserModeNetPidFile exists while the user-mode networking of WSL
+	// runs.  /mnt/wsl is shared by all the WSL machines, as is their network.
+	wslUserModeNetPidFile = "/mnt/wsl/podman-usermodenet/vm.pid"
+)
 
 // machineExpose is the struct for the gvproxy port forwarding api send via json
 type machineExpose struct {
@@ -31,8 +41,24 @@ type machineExpose struct {
 	Protocol string `json:"protocol"`
 }
 
-func requestMachinePorts(expose bool, ports []types.PortMapping) error {
-	url := "http://" + machineGvproxyEndpoint + "/services/forwarder/"
+// machineGvproxyAPI returns the address of the gvproxy that forwards the
+// published ports from the host, empty when no gvproxy forwards them.  WSL
+// forwards the ports to localhost itself, but not when its traffic goes
+// through the user-mode networking, whose gvproxy runs on the host.
+func machineGvproxyAPI() string {
+	if machine.IsGvProxyBased() {
+		return machineGvproxyEndpoint
+	}
+	if machine.HostType() == machine.Wsl {
+		if _, err := os.Stat(wslUserModeNetPidFile); err == nil {
+			return wslGvproxyEndpoint
+		}
+	}
+	return ""
+}
+
+func requestMachinePorts(endpoint string, expose bool, ports []types.PortMapping) error {
+	url := "http://" + endpoint + "/services/forwarder/"
 	if expose {
 		url += "expose"
 	} else {
@@ -69,7 +95,7 @@ func requestMachinePorts(expose bool, ports []types.PortMapping) error {
 				if err := json.NewEncoder(buf).Encode(machinePort); err != nil {
 					if expose {
 						// in case of an error make sure to unexpose the other ports
-						if cerr := requestMachinePorts(false, ports[:num]); cerr != nil {
+						if cerr := requestMachinePorts(endpoint, false, ports[:num]); cerr != nil {
 							logrus.Errorf("failed to free gvproxy machine ports: %v", cerr)
 						}
 					}
@@ -78,7 +104,7 @@ func requestMachinePorts(expose bool, ports []types.PortMapping) error {
 				if err := makeMachineRequest(ctx, client, url, buf); err != nil {
 					if expose {
 						// in case of an error make sure to unexpose the other ports
-						if cerr := requestMachinePorts(false, ports[:num]); cerr != nil {
+						if cerr := requestMachinePorts(endpoint, false, ports[:num]); cerr != nil {
 							logrus.Errorf("failed to free gvproxy machine ports: %v", cerr)
 						}
 					}
@@ -119,16 +145,18 @@ func annotateGvproxyResponseError(r io.Reader) error {
 
 // exposeMachinePorts exposes the ports for podman machine via gvproxy
 func (r *Runtime) exposeMachinePorts(ports []types.PortMapping) error {
-	if !machine.IsGvProxyBased() {
+	endpoint := machineGvproxyAPI()
+	if endpoint == "" {
 		return nil
 	}
-	return requestMachinePorts(true, ports)
+	return requestMachinePorts(endpoint, true, ports)
 }
 
 // unexposeMachinePorts closes the ports for podman machine via gvproxy
 func (r *Runtime) unexposeMachinePorts(ports []types.PortMapping) error {
-	if !machine.IsGvProxyBased() {
+	endpoint := machineGvproxyAPI()
+	if endpoint == "" {
 		return nil
 	}
-	return requestMachinePorts(false, ports)
+	return requestMachinePorts(endpoint, false, ports)
 }
@@ -11,6 +11,7 @@ import (
 
 	"github.com/containers/common/libnetwork/slirp4netns"
 	"github.com/containers/common/libnetwork/types"
+	"github.com/containers/common/pkg/rootlessport"
 	"github.com/containers/podman/v5/pkg/errorhandling"
 	"github.com/sirupsen/logrus"
 )
@@ -79,14 +80,37 @@ func (c *Container) reloadRootlessRLKPortMapping() error {
 	}
 	childIP := slirp4netns.GetRootlessPortChildIP(nil, c.state.NetworkStatus)
 	logrus.Debugf("reloading rootless ports for container %s, childIP is %s", c.config.ID, childIP)
+	return c.sendRootlessRLKPortReload(childIP)
+}
+
+// replaceRootlessRLKPortMappings replaces the ports forwarded by the rootlessport
+// process with the port mappings of the container config.
+// The rootlessport process only runs when the container was started with ports.
+func (c *Container) replaceRootlessRLKPortMappings(oldPorts []types.PortMapping) error {
+	if len(oldPorts) == 0 {
+		if len(c.config.PortMappings) > 0 {
+			logrus.Warnf("Container %s was started without published ports, the new ports will be published after a restart", c.ID())
+		}
+		return nil
+	}
+	reload := rootlessport.ReloadConfig{
+		Mappings: c.convertPortMappings(),
+		ChildIP:  slirp4netns.GetRootlessPortChildIP(nil, c.state.NetworkStatus),
+	}
+	logrus.Debugf("replacing rootless ports for container %s, childIP is %s", c.config.ID, reload.ChildIP)
+	return c.sendRootlessRLKPortReload(reload)
+}
 
+// sendRootlessRLKPortReload sends a reload request to the rootlessport process
+// of the container.
+func (c *Container) sendRootlessRLKPortReload(request any) error {
 	conn, err := openUnixSocket(filepath.Join(c.runtime.config.Engine.TmpDir, "rp", c.config.ID))
 	if err != nil {
 		return fmt.Errorf("could not reload rootless port mappings, port forwarding may no longer work correctly: %w", err)
 	}
 	defer conn.Close()
 	enc := json.NewEncoder(conn)
-	err = enc.Encode(childIP)
+	err = enc.Encode(request)
 	if err != nil {
 		return fmt.Errorf("port reloading failed: %w", err)
 	}
@@ -1248,7 +1248,7 @@ func (r *ConmonOCIRuntime) createOCIContainer(ctr *Container, restoreOptions *Co
 	cmd.ExtraFiles = append(cmd.ExtraFiles, childSyncPipe, childStartPipe)
 
 	if r.reservePorts && !rootless.IsRootless() && !ctr.config.NetMode.IsSlirp4netns() {
-		ports, err := bindPorts(ctr.convertPortMappings())
+		ports, err := bindPorts(ctr.convertPortMappings(), ctr.checkForIPv6(ctr.state.NetworkStatus))
 		if err != nil {
 			return 0, err
 		}
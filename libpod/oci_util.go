@@ -32,8 +32,9 @@ func createUnitName(prefix string, name string) string {
 	return fmt.Sprintf("%s-%s.scope", prefix, name)
 }
 
-// Bind ports to keep them closed on the host
-func bindPorts(ports []types.PortMapping) ([]*os.File, error) {
+// Bind ports to keep them closed on the host.
+// Ports without host ip are bound on IPv4 and, if dualStack is set, on IPv6.
+func bindPorts(ports []types.PortMapping, dualStack bool) ([]*os.File, error) {
 	var files []*os.File
 	sctpWarning := true
 	for _, port := range ports {
@@ -51,6 +52,20 @@ func bindPorts(ports []types.PortMapping) ([]*os.File, error) {
 				if f != nil {
 					files = append(files, f)
 				}
+				if port.HostIP != "" || !dualStack {
+					continue
+				}
+				// The host may not support IPv6, the IPv4 port is
+				// reserved already so do not fail in this case.
+				noWarning := false
+				f, err = bindPort(protocol, "", port.HostPort+i, true, &noWarning)
+				if err != nil {
+					logrus.Debugf("Failed to reserve IPv6 port %d/%s: %v", port.HostPort+i, protocol, err)
+					continue
+				}
+				if f != nil {
+					files = append(files, f)
+				}
 			}
 		}
 	}
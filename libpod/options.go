@@ -1054,6 +1054,30 @@ func WithDependencyCtrs(ctrs []*Container) CtrCreateOption {
 	}
 }
 
+// WithDependencyConditions sets the conditions dependency containers must
+// reach before the container is started.  The conditions are indexed by the
+// ID of the dependency.
+func WithDependencyConditions(conditions map[string]string) CtrCreateOption {
+	return func(ctr *Container) error {
+		if ctr.valid {
+			return define.ErrCtrFinalized
+		}
+
+		for _, condition := range conditions {
+			switch condition {
+			case define.DependencyConditionStarted, define.DependencyConditionHealthy, define.DependencyConditionExitedSuccessfully:
+			default:
+				return fmt.Errorf("invalid dependency condition %q, must be one of %s, %s or %s: %w", condition,
+					define.DependencyConditionStarted, define.DependencyConditionHealthy, define.DependencyConditionExitedSuccessfully, define.ErrInvalidArg)
+			}
+		}
+
+		ctr.config.DependencyConditions = conditions
+
+		return nil
+	}
+}
+
 // WithNetNS indicates that the container should be given a new network
 // namespace with a minimal configuration.
 // An optional array of port mappings can be provided.
@@ -1992,6 +2016,24 @@ func WithPodExitPolicy(policy string) PodCreateOption {
 	}
 }
 
+// WithPodExitCodePropagation sets the exit-code propagation of the pod.
+func WithPodExitCodePropagation(policy string) PodCreateOption {
+	return func(pod *Pod) error {
+		if pod.valid {
+			return define.ErrPodFinalized
+		}
+
+		parsed, err := define.ParseKubeExitCodePropagation(policy)
+		if err != nil {
+			return err
+		}
+
+		pod.config.ExitCodePropagation = parsed
+
+		return nil
+	}
+}
+
 // WithPodRestartPolicy sets the restart policy of the pod.
 func WithPodRestartPolicy(policy string) PodCreateOption {
 	return func(pod *Pod) error {
@@ -83,6 +83,10 @@ type PodConfig struct {
 	// The pod's exit policy.
 	ExitPolicy config.PodExitPolicy `json:"ExitPolicy,omitempty"`
 
+	// The pod's exit-code propagation.  Only honored with the stop exit
+	// policy.
+	ExitCodePropagation define.KubeExitCodePropagation `json:"ExitCodePropagation,omitempty"`
+
 	// The pod's restart policy
 	RestartPolicy string `json:"RestartPolicy,omitempty"`
 
@@ -185,6 +189,15 @@ func (p *Pod) MemorySwap() uint64 {
 	return uint64(*resLim.Memory.Swap)
 }
 
+// PidsLimit returns the pod pids limit
+func (p *Pod) PidsLimit() int64 {
+	resLim := p.ResourceLim()
+	if resLim.Pids == nil {
+		return 0
+	}
+	return resLim.Pids.Limit
+}
+
 // BlkioWeight returns the pod blkio weight
 func (p *Pod) BlkioWeight() uint64 {
 	resLim := p.ResourceLim()
@@ -14,6 +14,7 @@ import (
 	"github.com/containers/podman/v5/pkg/rootless"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 )
 
 // startInitContainers starts a pod's init containers.
@@ -227,9 +228,11 @@ func (p *Pod) stopIfOnlyInfraRemains(ctx context.Context, ignoreID string) error
 	defer p.lock.Unlock()
 
 	infraID := ""
+	var infra *Container
 
 	if p.HasInfraContainer() {
-		infra, err := p.infraContainer()
+		var err error
+		infra, err = p.infraContainer()
 		if err != nil {
 			return err
 		}
@@ -262,10 +265,59 @@ func (p *Pod) stopIfOnlyInfraRemains(ctx context.Context, ignoreID string) error
 		}
 	}
 
+	// Kill the infra container if the exit codes of the containers must
+	// be propagated.  Its conmon process will then exit non-zero which
+	// allows for systemd to mark the pod's unit as failed.
+	if infra != nil && p.shouldPropagateExitCode(allCtrs, infraID) {
+		logrus.Debugf("Killing infra container %s of pod %s to propagate exit codes", infraID, p.ID())
+		if err := infra.Kill(uint(unix.SIGKILL)); err != nil && !errors.Is(err, define.ErrCtrStateInvalid) {
+			logrus.Debugf("Error killing infra container %s: %v", infraID, err)
+		}
+	}
+
 	_, err = p.stopWithTimeout(ctx, true, -1)
 	return err
 }
 
+// shouldPropagateExitCode returns true if the pod's exit-code propagation
+// demands a non-zero exit given the exit codes of the specified containers.
+func (p *Pod) shouldPropagateExitCode(ctrs []*Container, infraID string) bool {
+	if p.config.ExitCodePropagation != define.KubeExitCodePropagationAny &&
+		p.config.ExitCodePropagation != define.KubeExitCodePropagationAll {
+		return false
+	}
+
+	numCtrs, failedCtrs := 0, 0
+	for _, ctr := range ctrs {
+		if ctr.ID() == infraID {
+			continue
+		}
+		exitCode, err := p.runtime.state.GetContainerExitCode(ctr.ID())
+		if err != nil {
+			logrus.Debugf("Getting exit code of container %s: %v", ctr.ID(), err)
+			continue
+		}
+		if exitCode != 0 {
+			failedCtrs++
+		}
+		numCtrs++
+	}
+
+	if p.config.ExitCodePropagation == define.KubeExitCodePropagationAny {
+		return failedCtrs > 0
+	}
+	return numCtrs > 0 && failedCtrs == numCtrs
+}
+
+// exitCodePropagation returns the string representation of the pod's
+// exit-code propagation or an empty string if none is set.
+func (p *Pod) exitCodePropagation() string {
+	if p.config.ExitCodePropagation == define.KubeExitCodePropagationInvalid {
+		return ""
+	}
+	return p.config.ExitCodePropagation.String()
+}
+
 // Cleanup cleans up all containers within a pod that have stopped.
 // All containers are cleaned up independently. An error with one container will
 // not prevent other containers being cleaned up.
@@ -567,6 +619,64 @@ func (p *Pod) Kill(ctx context.Context, signal uint) (map[string]error, error) {
 	return nil, nil
 }
 
+// Update updates the resource limits of the pod.
+// The CPU quota, memory, swap and pids limits set in res replace the ones the
+// pod was created with, limits not set in res are kept. The new limits are applied to
+// the pod cgroup that all containers of the pod are placed under, so they take
+// effect immediately for running containers.
+func (p *Pod) Update(res *specs.LinuxResources) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if !p.valid {
+		return define.ErrPodRemoved
+	}
+	if !p.config.UsePodCgroup {
+		return fmt.Errorf("pod %s does not have a pod cgroup to update: %w", p.ID(), define.ErrInvalidArg)
+	}
+
+	newConfig := new(PodConfig)
+	if err := JSONDeepCopy(p.config, newConfig); err != nil {
+		return err
+	}
+	limits := &newConfig.ResourceLimits
+	if res.CPU != nil {
+		if limits.CPU == nil {
+			limits.CPU = &specs.LinuxCPU{}
+		}
+		if res.CPU.Period != nil {
+			limits.CPU.Period = res.CPU.Period
+		}
+		if res.CPU.Quota != nil {
+			limits.CPU.Quota = res.CPU.Quota
+		}
+	}
+	if res.Memory != nil {
+		if limits.Memory == nil {
+			limits.Memory = &specs.LinuxMemory{}
+		}
+		if res.Memory.Limit != nil {
+			limits.Memory.Limit = res.Memory.Limit
+		}
+		if res.Memory.Swap != nil {
+			limits.Memory.Swap = res.Memory.Swap
+		}
+	}
+	if res.Pids != nil {
+		limits.Pids = res.Pids
+	}
+
+	if err := p.updatePodCgroup(&newConfig.ResourceLimits); err != nil {
+		return fmt.Errorf("updating pod %s cgroup: %w", p.ID(), err)
+	}
+	if err := p.runtime.state.RewritePodConfig(p, newConfig); err != nil {
+		return err
+	}
+	p.config = newConfig
+	logrus.Debugf("updated pod %s", p.ID())
+	return nil
+}
+
 // Status gets the status of all containers in the pod.
 // Returns a map of Container ID to Container Status.
 func (p *Pod) Status() (map[string]define.ContainerStatus, error) {
@@ -729,6 +839,7 @@ func (p *Pod) Inspect() (*define.InspectPodData, error) {
 		Created:             p.CreatedTime(),
 		CreateCommand:       p.config.CreateCommand,
 		ExitPolicy:          string(p.config.ExitPolicy),
+		ExitCodePropagation: p.exitCodePropagation(),
 		State:               podState,
 		Hostname:            p.config.Hostname,
 		Labels:              p.Labels(),
@@ -751,6 +862,7 @@ func (p *Pod) Inspect() (*define.InspectPodData, error) {
 		VolumesFrom:         p.VolumesFrom(),
 		SecurityOpts:        infraSecurity,
 		MemorySwap:          p.MemorySwap(),
+		PidsLimit:           p.PidsLimit(),
 		BlkioWeight:         p.BlkioWeight(),
 		CPUSetMems:          p.CPUSetMems(),
 		BlkioDeviceWriteBps: p.BlkiThrottleWriteBps(),
@@ -33,6 +33,8 @@ import (
 	"github.com/containers/podman/v5/libpod/lock"
 	"github.com/containers/podman/v5/libpod/plugin"
 	"github.com/containers/podman/v5/libpod/shutdown"
+	"github.com/containers/podman/v5/pkg/libartifact/store"
+	"github.com/containers/podman/v5/pkg/mirrorhealth"
 	"github.com/containers/podman/v5/pkg/rootless"
 	"github.com/containers/podman/v5/pkg/systemd"
 	"github.com/containers/podman/v5/pkg/util"
@@ -980,6 +982,29 @@ func (r *Runtime) StorageConfig() storage.StoreOptions {
 	return r.storageConfig
 }
 
+// ArtifactStore returns the store of OCI artifacts, which is located in the
+// "artifacts" directory of the graph root.
+func (r *Runtime) ArtifactStore() (*store.ArtifactStore, error) {
+	return store.NewArtifactStore(filepath.Join(r.storageConfig.GraphRoot, "artifacts"))
+}
+
+// MirrorHealth returns the tracker of the health of registry mirrors, which
+// persists its state in the tmp dir.
+func (r *Runtime) MirrorHealth() (*mirrorhealth.Tracker, error) {
+	return mirrorhealth.NewTracker(r.config.Engine.TmpDir)
+}
+
+// WithMirrorHealth returns a copy of ctx that makes pulls use registry mirrors
+// in the order of their health and record the outcome of accessing them.
+func (r *Runtime) WithMirrorHealth(ctx context.Context) context.Context {
+	tracker, err := r.MirrorHealth()
+	if err != nil {
+		logrus.Warnf("Tracking the health of registry mirrors: %v", err)
+		return ctx
+	}
+	return tracker.WithHooks(ctx)
+}
+
 func (r *Runtime) GarbageCollect() error {
 	return r.store.GarbageCollect()
 }
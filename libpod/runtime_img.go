@@ -8,13 +8,25 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	buildahDefine "github.com/containers/buildah/define"
 	"github.com/containers/buildah/imagebuildah"
 	"github.com/containers/common/libimage"
+	"github.com/containers/common/pkg/config"
+	"github.com/containers/image/v5/docker"
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/image"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
 	"github.com/containers/podman/v5/libpod/define"
 	"github.com/containers/podman/v5/libpod/events"
+	"github.com/containers/podman/v5/pkg/imagescan"
+	"github.com/containers/podman/v5/pkg/trust"
 	"github.com/containers/podman/v5/pkg/util"
 	"github.com/sirupsen/logrus"
 )
@@ -146,3 +158,374 @@ func DownloadFromFile(reader *os.File) (string, error) {
 
 	return outFile.Name(), nil
 }
+
+// imageLastPullCheckKey is the key of the image big data recording when the
+// registry was last checked for a newer version of the image.
+const imageLastPullCheckKey = "podman-last-pull-check"
+
+// PullPolicyForMaxAge returns the pull policy for pulling the image with the
+// specified name with a max-age pull policy: PullPolicyNever if a local image
+// exists for which the registry was checked within maxAge, and
+// PullPolicyNewer otherwise.
+func (r *Runtime) PullPolicyForMaxAge(name string, maxAge time.Duration) config.PullPolicy {
+	img, _, err := r.libimageRuntime.LookupImage(name, nil)
+	if err != nil {
+		return config.PullPolicyNewer
+	}
+	data, err := r.store.ImageBigData(img.ID(), imageLastPullCheckKey)
+	if err != nil {
+		return config.PullPolicyNewer
+	}
+	lastCheck, err := time.Parse(time.RFC3339Nano, string(data))
+	if err != nil {
+		logrus.Debugf("Parsing last pull check of image %s: %v", img.ID(), err)
+		return config.PullPolicyNewer
+	}
+	if time.Since(lastCheck) < maxAge {
+		logrus.Debugf("Registry was checked for image %s at %s, not pulling", name, lastCheck)
+		return config.PullPolicyNever
+	}
+	return config.PullPolicyNewer
+}
+
+// WithPullCheck returns a copy of ctx noting whether pulls with it reached a
+// registry, and a function recording the current time as the time the
+// registry was last checked for newer versions of the images if the pull
+// policy always contacts the registry.  Nothing is recorded when the pull fell
+// back to the local image because the registry could not be reached.  The
+// time is used by PullPolicyForMaxAge.
+func (r *Runtime) WithPullCheck(ctx context.Context) (context.Context, func(images []*libimage.Image, policy config.PullPolicy)) {
+	var checked atomic.Bool
+	ctx = libimage.WithRegistryCheckReporter(ctx, func(string) {
+		checked.Store(true)
+	})
+
+	record := func(images []*libimage.Image, policy config.PullPolicy) {
+		if policy != config.PullPolicyAlways && policy != config.PullPolicyNewer {
+			return
+		}
+		if !checked.Load() {
+			logrus.Debugf("Registry was not reached, not recording the pull check")
+			return
+		}
+		now := []byte(time.Now().Format(time.RFC3339Nano))
+		for _, img := range images {
+			if err := r.store.SetImageBigData(img.ID(), imageLastPullCheckKey, now, nil); err != nil {
+				logrus.Warnf("Recording last pull check of image %s: %v", img.ID(), err)
+			}
+		}
+	}
+	return ctx, record
+}
+
+// imageScanReportKey is the key of the image big data holding the report of
+// the latest scan of the image.
+const imageScanReportKey = "podman-image-scan"
+
+// ScanImage scans the image with the image scanner configured in
+// containers.conf and records the report in the image's metadata.
+func (r *Runtime) ScanImage(ctx context.Context, img *libimage.Image) (*imagescan.Report, error) {
+	command := r.config.Engine.ImageScanner.Get()
+	if len(command) == 0 {
+		return nil, imagescan.ErrNoScanner
+	}
+	data, err := img.Inspect(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	request := imagescan.Request{
+		Version: imagescan.RequestVersion,
+		Image: imagescan.Image{
+			ID:     img.ID(),
+			Names:  img.Names(),
+			Digest: img.Digest().String(),
+		},
+	}
+	if data.RootFS != nil {
+		for _, layer := range data.RootFS.Layers {
+			request.Image.Layers = append(request.Image.Layers, layer.String())
+		}
+	}
+
+	mountPoint, err := img.Mount(ctx, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("mounting image %s: %w", img.ID(), err)
+	}
+	defer func() {
+		if err := img.Unmount(false); err != nil {
+			logrus.Errorf("Unmounting image %s: %v", img.ID(), err)
+		}
+	}()
+	request.RootFS = mountPoint
+
+	scanner := imagescan.ExecScanner{Command: command}
+	report, err := scanner.Scan(ctx, &request)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.store.SetImageBigData(img.ID(), imageScanReportKey, encoded, nil); err != nil {
+		return nil, fmt.Errorf("recording scan report of image %s: %w", img.ID(), err)
+	}
+	return report, nil
+}
+
+// ImageScanReport returns the report of the latest scan of the image or nil
+// if the image has not been scanned yet.
+func (r *Runtime) ImageScanReport(img *libimage.Image) (*imagescan.Report, error) {
+	data, err := r.store.ImageBigData(img.ID(), imageScanReportKey)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	report := imagescan.Report{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("decoding scan report of image %s: %w", img.ID(), err)
+	}
+	return &report, nil
+}
+
+// imageScanBlockSeverity returns the severity of findings blocking images as
+// configured in containers.conf, and whether blocking is enabled at all.
+func (r *Runtime) imageScanBlockSeverity() (imagescan.Severity, bool, error) {
+	if r.config.Engine.ImageScanBlockSeverity == "" {
+		return imagescan.SeverityUnknown, false, nil
+	}
+	severity, err := imagescan.ParseSeverity(r.config.Engine.ImageScanBlockSeverity)
+	if err != nil {
+		return imagescan.SeverityUnknown, false, fmt.Errorf("image_scan_block_severity in containers.conf: %w", err)
+	}
+	return severity, true, nil
+}
+
+// blockedByScan returns an error wrapping define.ErrImageScanBlocked if the
+// report contains findings of at least the specified severity.
+func blockedByScan(img *libimage.Image, report *imagescan.Report, severity imagescan.Severity) error {
+	blocking := report.Blocking(severity)
+	if len(blocking) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(blocking))
+	for _, f := range blocking {
+		ids = append(ids, f.ID)
+	}
+	return fmt.Errorf("image %s has %d findings of severity %s or higher (%s): %w", img.ID(), len(blocking), severity, strings.Join(ids, ", "), define.ErrImageScanBlocked)
+}
+
+// currentImageScanReport returns the report of the latest scan of the image,
+// or nil if the image has not been scanned yet or the report is outdated as
+// configured in containers.conf.
+func (r *Runtime) currentImageScanReport(img *libimage.Image) (*imagescan.Report, error) {
+	maxAge, err := time.ParseDuration(r.config.Engine.ImageScanMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("image_scan_max_age in containers.conf: %w", err)
+	}
+	report, err := r.ImageScanReport(img)
+	if err != nil || report == nil {
+		return nil, err
+	}
+	if report.Outdated(r.config.Engine.ImageScanner.Get(), maxAge) {
+		logrus.Debugf("Scan report of image %s from %s is outdated", img.ID(), report.Created)
+		return nil, nil
+	}
+	return report, nil
+}
+
+// CheckImageScanPolicy returns an error wrapping define.ErrImageScanBlocked
+// if containers.conf blocks creating containers from the image because of
+// the findings of its latest scan.  Images which have not been scanned yet,
+// or whose report is outdated, are scanned first.
+func (r *Runtime) CheckImageScanPolicy(ctx context.Context, img *libimage.Image) error {
+	severity, enabled, err := r.imageScanBlockSeverity()
+	if err != nil || !enabled {
+		return err
+	}
+	report, err := r.currentImageScanReport(img)
+	if err != nil {
+		return err
+	}
+	if report == nil {
+		if report, err = r.ScanImage(ctx, img); err != nil {
+			return fmt.Errorf("scanning image %s: %w", img.ID(), err)
+		}
+	}
+	return blockedByScan(img, report, severity)
+}
+
+// ScanPulledImages scans the pulled images which have not been scanned yet,
+// or whose report is outdated, if containers.conf enables scanning images
+// after pulling them.  Failing scans
+// and images blocked by the scan policy are logged but do not fail the pull.
+func (r *Runtime) ScanPulledImages(ctx context.Context, images []*libimage.Image) {
+	if !r.config.Engine.ImageScanOnPull {
+		return
+	}
+	severity, enabled, err := r.imageScanBlockSeverity()
+	if err != nil {
+		logrus.Warn(err)
+		enabled = false
+	}
+	for _, img := range images {
+		if report, err := r.currentImageScanReport(img); err == nil && report != nil {
+			continue
+		}
+		report, err := r.ScanImage(ctx, img)
+		if err != nil {
+			logrus.Warnf("Scanning image %s: %v", img.ID(), err)
+			continue
+		}
+		if !enabled {
+			continue
+		}
+		if err := blockedByScan(img, report, severity); err != nil {
+			logrus.Warnf("Containers cannot be created from pulled image: %v", err)
+		}
+	}
+}
+
+// imageTrustStatusKey is the key of the image big data recording how the
+// image was verified against the trust policy.
+const imageTrustStatusKey = "podman-trust-status"
+
+// WithTrustStatus returns a copy of ctx recording how images pulled with it
+// are verified against the trust policy, and a function storing the trust
+// status of the pulled images.  Unless writer is nil, the function writes the
+// status of images whose signatures were verified to it.
+func (r *Runtime) WithTrustStatus(ctx context.Context) (context.Context, func(images []*libimage.Image, writer io.Writer)) {
+	var lock sync.Mutex
+	statuses := make(map[string]*trust.Status)
+	var last *trust.Status
+	ctx = signature.WithAcceptedRequirementsReporter(ctx, func(ref types.ImageReference, reqs signature.PolicyRequirements) {
+		name := transports.ImageName(ref)
+		if named := ref.DockerReference(); named != nil {
+			name = named.String()
+		}
+		status, err := trust.NewStatus(name, reqs)
+		if err != nil {
+			logrus.Debugf("Recording trust status of %s: %v", name, err)
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		statuses[name] = status
+		last = status
+	})
+
+	record := func(images []*libimage.Image, writer io.Writer) {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, img := range images {
+			var status *trust.Status
+			for _, name := range img.Names() {
+				if status = statuses[name]; status != nil {
+					break
+				}
+			}
+			if status == nil && len(statuses) == 1 {
+				status = last
+			}
+			if status == nil {
+				continue
+			}
+			r.setImageTrustStatus(img, status)
+			if writer != nil && status.Verified {
+				fmt.Fprintf(writer, "Trust status of %s: %s\n", status.Reference, status)
+			}
+		}
+	}
+	return ctx, record
+}
+
+// setImageTrustStatus records the trust status in the image's metadata.
+func (r *Runtime) setImageTrustStatus(img *libimage.Image, status *trust.Status) {
+	data, err := json.Marshal(status)
+	if err == nil {
+		err = r.store.SetImageBigData(img.ID(), imageTrustStatusKey, data, nil)
+	}
+	if err != nil {
+		logrus.Warnf("Recording trust status of image %s: %v", img.ID(), err)
+	}
+}
+
+// ImageTrustStatus returns how the image was last verified against the trust
+// policy, or nil if no trust status has been recorded for the image.
+func (r *Runtime) ImageTrustStatus(img *libimage.Image) (*trust.Status, error) {
+	data, err := r.store.ImageBigData(img.ID(), imageTrustStatusKey)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	status := trust.Status{}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("decoding trust status of image %s: %w", img.ID(), err)
+	}
+	return &status, nil
+}
+
+// VerifyImageTrust verifies the image in local storage against the current
+// trust policy as if it was pulled from the registry using name, defaulting
+// to the first name of the image, and records the resulting trust status.
+func (r *Runtime) VerifyImageTrust(ctx context.Context, img *libimage.Image, name string) (*trust.Status, error) {
+	if name == "" {
+		names := img.Names()
+		if len(names) == 0 {
+			return nil, fmt.Errorf("image %s has no name to verify it for", img.ID())
+		}
+		name = names[0]
+	}
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	named = reference.TagNameOnly(named)
+	policyRef, err := docker.NewReference(named)
+	if err != nil {
+		return nil, err
+	}
+
+	sys := r.SystemContext()
+	policy, err := signature.DefaultPolicy(sys)
+	if err != nil {
+		return nil, err
+	}
+	policyContext, err := signature.NewPolicyContext(policy)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := policyContext.Destroy(); err != nil {
+			logrus.Errorf("Destroying policy context: %v", err)
+		}
+	}()
+
+	storageRef, err := img.StorageReference()
+	if err != nil {
+		return nil, err
+	}
+	src, err := storageRef.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	var status *trust.Status
+	var statusErr error
+	ctx = signature.WithAcceptedRequirementsReporter(ctx, func(_ types.ImageReference, reqs signature.PolicyRequirements) {
+		status, statusErr = trust.NewStatus(named.String(), reqs)
+	})
+	if _, err := policyContext.IsRunningImageAllowedAs(ctx, image.UnparsedInstance(src, nil), policyRef); err != nil {
+		return nil, fmt.Errorf("verifying image %s as %s: %w", img.ID(), named.String(), err)
+	}
+	if statusErr != nil {
+		return nil, statusErr
+	}
+	r.setImageTrustStatus(img, status)
+	return status, nil
+}
@@ -89,6 +89,28 @@ func (r *Runtime) platformMakePod(pod *Pod, resourceLimits *spec.LinuxResources)
 	return cgroupParent, nil
 }
 
+// updatePodCgroup applies the given resource limits to the pod cgroup.
+func (p *Pod) updatePodCgroup(resources *spec.LinuxResources) error {
+	if p.state.CgroupPath == "" {
+		return nil
+	}
+	if p.runtime.config.Engine.CgroupManager == config.CgroupfsCgroupsManager && rootless.IsRootless() {
+		return fmt.Errorf("pod cgroups cannot be updated as rootless with the cgroupfs cgroup manager: %w", define.ErrNotImplemented)
+	}
+	logrus.Debugf("Updating pod cgroup %s", p.state.CgroupPath)
+
+	res, err := GetLimits(resources)
+	if err != nil {
+		return err
+	}
+	res.SkipDevices = true
+	cgroup, err := cgroups.Load(p.state.CgroupPath)
+	if err != nil {
+		return err
+	}
+	return cgroup.Update(&res)
+}
+
 func (p *Pod) removePodCgroup() error {
 	// Remove pod cgroup, if present
 	if p.state.CgroupPath == "" {
@@ -14,6 +14,7 @@ import (
 	"github.com/containers/podman/v5/libpod/define"
 	"github.com/containers/podman/v5/libpod/events"
 	volplugin "github.com/containers/podman/v5/libpod/plugin"
+	"github.com/containers/podman/v5/pkg/rootless"
 	"github.com/containers/storage"
 	"github.com/containers/storage/drivers/quota"
 	"github.com/containers/storage/pkg/idtools"
@@ -194,6 +195,9 @@ func (r *Runtime) newVolume(ctx context.Context, noCreatePluginVolume bool, opti
 				projectQuotaSupported = true
 			}
 			if !projectQuotaSupported {
+				if rootless.IsRootless() {
+					return nil, errors.New("volume options size and inodes not supported. Project quotas cannot be set by rootless users, use a tmpfs volume with a size instead")
+				}
 				return nil, errors.New("volume options size and inodes not supported. Filesystem does not support Project Quota")
 			}
 			quota := quota.Quota{
@@ -115,6 +115,12 @@ func (v *Volume) Size() (uint64, error) {
 	return uint64(size), err
 }
 
+// SizeLimit returns the size limit of the volume set with the size option,
+// or 0 if the size of the volume is not limited.
+func (v *Volume) SizeLimit() uint64 {
+	return v.config.Size
+}
+
 // Driver retrieves the volume's driver.
 func (v *Volume) Driver() string {
 	return v.config.Driver
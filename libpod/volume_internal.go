@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 
 	"github.com/containers/podman/v5/libpod/define"
+	"github.com/sirupsen/logrus"
 )
 
 // Creates a new volume
@@ -29,6 +30,11 @@ func (v *Volume) teardownStorage() error {
 		return nil
 	}
 
+	// Snapshots outside the volume directory would be leaked otherwise.
+	if err := v.removeSnapshots(); err != nil {
+		logrus.Errorf("Removing snapshots of volume %s: %v", v.Name(), err)
+	}
+
 	// TODO: Should this be converted to use v.config.MountPoint?
 	return os.RemoveAll(filepath.Join(v.runtime.config.Engine.VolumePath, v.Name()))
 }
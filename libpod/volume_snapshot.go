@@ -0,0 +1,521 @@
+//go:build !remote && (linux || freebsd)
+
+package libpod
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/containers/podman/v5/libpod/define"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// volumeSnapshotsDir is the directory next to the volume data which
+	// holds the snapshots of the volume.
+	volumeSnapshotsDir = "snapshots"
+	// volumeSnapshotConfigFile is the file in the snapshot directory which
+	// holds the define.VolumeSnapshot of the snapshot.
+	volumeSnapshotConfigFile = "snapshot.json"
+	// volumeSnapshotDataDir is the directory in the snapshot directory
+	// which holds the data of btrfs, reflink and copy snapshots.
+	volumeSnapshotDataDir = "data"
+)
+
+// CreateSnapshot takes a snapshot of the volume data.
+// Volumes whose data is a btrfs subvolume, a ZFS dataset or a thin provisioned
+// LVM logical volume are snapshotted by the filesystem or volume manager, the
+// data of all other volumes is copied with reflinks if the filesystem supports
+// them, or with a plain copy otherwise.
+func (v *Volume) CreateSnapshot(name string) (_ *define.VolumeSnapshot, retErr error) {
+	if !define.NameRegex.MatchString(name) {
+		return nil, fmt.Errorf("snapshot name %q: %w", name, define.RegexError)
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if err := v.update(); err != nil {
+		return nil, err
+	}
+	if err := v.checkSnapshotSupport(); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(v.snapshotsPath(), 0o700); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(v.snapshotsPath(), name)
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("snapshot %s of volume %s: %w", name, v.Name(), define.ErrVolumeSnapshotExists)
+		}
+		return nil, err
+	}
+
+	snapshot := &define.VolumeSnapshot{
+		Name:      name,
+		Volume:    v.Name(),
+		CreatedAt: time.Now(),
+	}
+	defer func() {
+		if retErr == nil {
+			return
+		}
+		if snapshot.Backend != "" {
+			if err := removeSnapshotData(snapshot, dir); err != nil {
+				logrus.Errorf("Removing snapshot %s of volume %s: %v", name, v.Name(), err)
+			}
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			logrus.Errorf("Removing snapshot directory of volume %s: %v", v.Name(), err)
+		}
+	}()
+
+	if err := v.createSnapshot(snapshot, dir); err != nil {
+		return nil, fmt.Errorf("creating snapshot %s of volume %s: %w", name, v.Name(), err)
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, volumeSnapshotConfigFile), data, 0o600); err != nil {
+		return nil, fmt.Errorf("writing snapshot %s of volume %s: %w", name, v.Name(), err)
+	}
+	return snapshot, nil
+}
+
+// Snapshots returns the snapshots of the volume, oldest first.
+func (v *Volume) Snapshots() ([]*define.VolumeSnapshot, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if err := v.update(); err != nil {
+		return nil, err
+	}
+	return v.snapshots()
+}
+
+// RestoreSnapshot replaces the volume data with the data of the given snapshot.
+// The volume must not be used by a running container.
+func (v *Volume) RestoreSnapshot(name string) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if err := v.update(); err != nil {
+		return err
+	}
+	// Starting containers mount the volume with the volume lock held, so
+	// none can start using it until the data is restored.
+	if err := v.checkNotInUseByRunningContainer(); err != nil {
+		return err
+	}
+	if err := v.checkSnapshotSupport(); err != nil {
+		return err
+	}
+	snapshot, err := v.snapshot(name)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(v.snapshotsPath(), name)
+
+	if err := v.restoreSnapshot(snapshot, dir); err != nil {
+		return fmt.Errorf("restoring snapshot %s of volume %s: %w", name, v.Name(), err)
+	}
+	logrus.Debugf("Restored snapshot %s of volume %s", name, v.Name())
+	return nil
+}
+
+// snapshotsPath returns the directory holding the snapshots of the volume.
+func (v *Volume) snapshotsPath() string {
+	return filepath.Join(v.runtime.config.Engine.VolumePath, v.Name(), volumeSnapshotsDir)
+}
+
+// checkSnapshotSupport returns an error if the volume driver does not support
+// snapshots. Only the local driver supports them.
+func (v *Volume) checkSnapshotSupport() error {
+	if v.UsesVolumeDriver() || v.config.Driver == define.VolumeDriverImage {
+		return fmt.Errorf("volume %s uses the %s driver which does not support snapshots: %w", v.Name(), v.config.Driver, define.ErrNotImplemented)
+	}
+	return nil
+}
+
+// checkNotInUseByRunningContainer returns an error if a running container uses
+// the volume, or a container has mounted it to start.
+// Must be called with the volume locked. The containers are not locked, as
+// containers lock the volume while holding their own lock, their state is
+// read from the database instead.
+func (v *Volume) checkNotInUseByRunningContainer() error {
+	deps, err := v.runtime.state.VolumeInUse(v)
+	if err != nil {
+		return err
+	}
+	for _, dep := range deps {
+		ctr, err := v.runtime.state.Container(dep)
+		if err != nil {
+			if errors.Is(err, define.ErrNoSuchCtr) || errors.Is(err, define.ErrCtrRemoved) {
+				continue
+			}
+			return err
+		}
+		switch ctr.state.State {
+		case define.ContainerStateRunning, define.ContainerStatePaused, define.ContainerStateStopping:
+			return fmt.Errorf("volume %s is mounted by running container %s: %w", v.Name(), ctr.ID(), define.ErrVolumeBeingUsed)
+		}
+		if ctr.state.Mounted {
+			return fmt.Errorf("volume %s is mounted by container %s: %w", v.Name(), ctr.ID(), define.ErrVolumeBeingUsed)
+		}
+	}
+	return nil
+}
+
+// snapshots returns the snapshots of the volume.
+// Must be called with the volume locked.
+func (v *Volume) snapshots() ([]*define.VolumeSnapshot, error) {
+	entries, err := os.ReadDir(v.snapshotsPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []*define.VolumeSnapshot{}, nil
+		}
+		return nil, err
+	}
+	snapshots := make([]*define.VolumeSnapshot, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		snapshot, err := v.snapshot(entry.Name())
+		if err != nil {
+			logrus.Warnf("Reading snapshot %s of volume %s: %v", entry.Name(), v.Name(), err)
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// snapshot returns the snapshot of the volume with the given name.
+// Must be called with the volume locked.
+func (v *Volume) snapshot(name string) (*define.VolumeSnapshot, error) {
+	if !define.NameRegex.MatchString(name) {
+		return nil, fmt.Errorf("snapshot %s of volume %s: %w", name, v.Name(), define.ErrNoSuchVolumeSnapshot)
+	}
+	data, err := os.ReadFile(filepath.Join(v.snapshotsPath(), name, volumeSnapshotConfigFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("snapshot %s of volume %s: %w", name, v.Name(), define.ErrNoSuchVolumeSnapshot)
+		}
+		return nil, err
+	}
+	snapshot := new(define.VolumeSnapshot)
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("parsing snapshot %s of volume %s: %w", name, v.Name(), err)
+	}
+	return snapshot, nil
+}
+
+// createSnapshot stores the volume data in the snapshot using the best
+// backend available for the volume, and sets the backend of the snapshot.
+// Must be called with the volume locked.
+func (v *Volume) createSnapshot(snapshot *define.VolumeSnapshot, dir string) error {
+	// The logical volume is snapshotted directly, there is no need to
+	// mount it.
+	if lv, ok := lvmThinVolume(v.config.Options["device"]); ok {
+		if err := runSnapshotCommand("lvcreate", "--snapshot", "--name", filepath.Base(lvmSnapshotName(lv, snapshot.Name)), lv); err != nil {
+			return err
+		}
+		snapshot.Backend = define.VolumeSnapshotBackendLVMThin
+		snapshot.Source = lv
+		return nil
+	}
+
+	if err := v.mount(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := v.unmount(false); err != nil {
+			logrus.Errorf("Unmounting volume %s: %v", v.Name(), err)
+		}
+	}()
+
+	data := filepath.Join(dir, volumeSnapshotDataDir)
+	if !v.needsMount() && isBtrfsSubvolume(v.config.MountPoint) {
+		if err := runSnapshotCommand("btrfs", "subvolume", "snapshot", "-r", v.config.MountPoint, data); err != nil {
+			return err
+		}
+		snapshot.Backend = define.VolumeSnapshotBackendBtrfs
+		return nil
+	}
+	if dataset, ok := zfsDataset(v.config.MountPoint, v.config.Options["device"]); ok {
+		if err := runSnapshotCommand("zfs", "snapshot", zfsSnapshotName(dataset, snapshot.Name)); err != nil {
+			return err
+		}
+		snapshot.Backend = define.VolumeSnapshotBackendZFS
+		snapshot.Source = dataset
+		return nil
+	}
+
+	// Fall back to copy the data, using reflinks if possible.
+	err := runSnapshotCommand("cp", "-a", "--reflink=always", v.config.MountPoint, data)
+	if err == nil {
+		snapshot.Backend = define.VolumeSnapshotBackendReflink
+		return nil
+	}
+	logrus.Debugf("Cannot copy volume %s with reflinks, falling back to a plain copy: %v", v.Name(), err)
+	if err := os.RemoveAll(data); err != nil {
+		return err
+	}
+	if err := runSnapshotCommand("cp", "-a", v.config.MountPoint, data); err != nil {
+		return err
+	}
+	snapshot.Backend = define.VolumeSnapshotBackendCopy
+	return nil
+}
+
+// restoreSnapshot replaces the volume data with the data of the snapshot.
+// Must be called with the volume locked.
+func (v *Volume) restoreSnapshot(snapshot *define.VolumeSnapshot, dir string) error {
+	if snapshot.Backend == define.VolumeSnapshotBackendLVMThin {
+		// The snapshot is merged into the logical volume, which only
+		// happens immediately if the logical volume is not in use.
+		if v.state.MountCount > 0 {
+			return fmt.Errorf("volume %s is mounted: %w", v.Name(), define.ErrVolumeBeingUsed)
+		}
+		// Merging the snapshot into its origin removes it, so it is
+		// taken again from the restored logical volume.
+		snapshotLV := lvmSnapshotName(snapshot.Source, snapshot.Name)
+		if err := runSnapshotCommand("lvconvert", "--yes", "--merge", snapshotLV); err != nil {
+			return err
+		}
+		if err := runSnapshotCommand("lvcreate", "--snapshot", "--name", filepath.Base(snapshotLV), snapshot.Source); err != nil {
+			return fmt.Errorf("the volume data was restored, but taking the snapshot again failed: %w", err)
+		}
+		return nil
+	}
+
+	if err := v.mount(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := v.unmount(false); err != nil {
+			logrus.Errorf("Unmounting volume %s: %v", v.Name(), err)
+		}
+	}()
+
+	data := filepath.Join(dir, volumeSnapshotDataDir)
+	switch snapshot.Backend {
+	case define.VolumeSnapshotBackendBtrfs:
+		return replaceBtrfsSubvolume(data, v.config.MountPoint)
+	case define.VolumeSnapshotBackendZFS:
+		// Rolling back only works for the most recent snapshot without
+		// destroying the later ones, copy the data of older snapshots.
+		zfsSnapshot := zfsSnapshotName(snapshot.Source, snapshot.Name)
+		err := runSnapshotCommand("zfs", "rollback", zfsSnapshot)
+		if err == nil {
+			return nil
+		}
+		logrus.Debugf("Cannot roll back volume %s, copying the snapshot data: %v", v.Name(), err)
+		_, snapshotName, _ := strings.Cut(zfsSnapshot, "@")
+		return replaceVolumeData(filepath.Join(v.config.MountPoint, ".zfs", "snapshot", snapshotName), v.config.MountPoint)
+	case define.VolumeSnapshotBackendReflink, define.VolumeSnapshotBackendCopy:
+		return replaceVolumeData(data, v.config.MountPoint)
+	default:
+		return fmt.Errorf("unknown snapshot backend %q", snapshot.Backend)
+	}
+}
+
+// removeSnapshots removes the snapshots of the volume which are not stored
+// in the volume directory. Must be called with the volume locked.
+func (v *Volume) removeSnapshots() error {
+	snapshots, err := v.snapshots()
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		if err := removeSnapshotData(snapshot, filepath.Join(v.snapshotsPath(), snapshot.Name)); err != nil {
+			return fmt.Errorf("removing snapshot %s of volume %s: %w", snapshot.Name, v.Name(), err)
+		}
+	}
+	return nil
+}
+
+// removeSnapshotData removes the data of the snapshot which cannot be removed
+// by deleting the snapshot directory.
+func removeSnapshotData(snapshot *define.VolumeSnapshot, dir string) error {
+	switch snapshot.Backend {
+	case define.VolumeSnapshotBackendLVMThin:
+		return runSnapshotCommand("lvremove", "--yes", lvmSnapshotName(snapshot.Source, snapshot.Name))
+	case define.VolumeSnapshotBackendZFS:
+		return runSnapshotCommand("zfs", "destroy", zfsSnapshotName(snapshot.Source, snapshot.Name))
+	case define.VolumeSnapshotBackendBtrfs:
+		// read-only subvolumes cannot be removed with os.RemoveAll()
+		return runSnapshotCommand("btrfs", "subvolume", "delete", filepath.Join(dir, volumeSnapshotDataDir))
+	}
+	return nil
+}
+
+// replaceVolumeData replaces the content of the directory dst with a copy of
+// the content of src, using reflinks if possible.
+// The copy is made in a staging directory inside dst, which is on the same
+// filesystem even if dst is a mount point, and only replaces the old content
+// once it is complete. The old content is kept if the copy fails.
+func replaceVolumeData(src, dst string) (retErr error) {
+	staging, err := os.MkdirTemp(dst, ".restore-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(staging); err != nil {
+			logrus.Errorf("Removing staging directory %s: %v", staging, err)
+		}
+	}()
+	if err := runSnapshotCommand("cp", "-a", "--reflink=auto", src+"/.", staging); err != nil {
+		return err
+	}
+
+	// Move the old content out of the way first, so that it can be put
+	// back if moving the new content in fails.
+	old, err := os.MkdirTemp(dst, ".old-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(old); err != nil {
+			logrus.Errorf("Removing old volume data %s: %v", old, err)
+		}
+	}()
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		return err
+	}
+	var moved []string
+	defer func() {
+		if retErr == nil {
+			return
+		}
+		for _, name := range moved {
+			if err := os.Rename(filepath.Join(old, name), filepath.Join(dst, name)); err != nil {
+				logrus.Errorf("Restoring %s of the old volume data: %v", name, err)
+			}
+		}
+	}()
+	for _, entry := range entries {
+		path := filepath.Join(dst, entry.Name())
+		if path == staging || path == old {
+			continue
+		}
+		if err := os.Rename(path, filepath.Join(old, entry.Name())); err != nil {
+			return err
+		}
+		moved = append(moved, entry.Name())
+	}
+
+	entries, err = os.ReadDir(staging)
+	if err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		if err := os.Rename(filepath.Join(staging, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+			// take back the new content that was already moved
+			for _, done := range entries[:i] {
+				if err := os.Rename(filepath.Join(dst, done.Name()), filepath.Join(staging, done.Name())); err != nil {
+					logrus.Errorf("Removing %s of the restored volume data: %v", done.Name(), err)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceBtrfsSubvolume replaces the btrfs subvolume at path with a writable
+// snapshot of the subvolume src.
+// The old subvolume is moved aside and only deleted once the snapshot took its
+// place, it is moved back if that fails.
+func replaceBtrfsSubvolume(src, path string) error {
+	restored := path + ".restore"
+	if err := runSnapshotCommand("btrfs", "subvolume", "snapshot", src, restored); err != nil {
+		return err
+	}
+	deleteRestored := func() {
+		if err := runSnapshotCommand("btrfs", "subvolume", "delete", restored); err != nil {
+			logrus.Errorf("Removing restored subvolume %s: %v", restored, err)
+		}
+	}
+	old := path + ".old"
+	if err := os.Rename(path, old); err != nil {
+		deleteRestored()
+		return err
+	}
+	if err := os.Rename(restored, path); err != nil {
+		if err2 := os.Rename(old, path); err2 != nil {
+			return fmt.Errorf("%w, and moving the old data back from %s failed: %v", err, old, err2)
+		}
+		deleteRestored()
+		return err
+	}
+	if err := runSnapshotCommand("btrfs", "subvolume", "delete", old); err != nil {
+		logrus.Errorf("Removing old subvolume %s: %v", old, err)
+	}
+	return nil
+}
+
+// lvmSnapshotName returns the name of the logical volume holding the snapshot
+// of the logical volume lv in the VG/LV format.
+func lvmSnapshotName(lv, snapshot string) string {
+	return lv + "-snapshot-" + snapshot
+}
+
+// zfsSnapshotName returns the name of the snapshot of the ZFS dataset.
+func zfsSnapshotName(dataset, snapshot string) string {
+	return dataset + "@podman-" + snapshot
+}
+
+// runSnapshotCommand runs a program used by the snapshot backends.
+func runSnapshotCommand(name string, args ...string) error {
+	_, err := snapshotCommandOutput(name, args...)
+	return err
+}
+
+// snapshotCommandOutput runs a program used by the snapshot backends and
+// returns its standard output. Tests replace it to fake the backends.
+var snapshotCommandOutput = func(name string, args ...string) (string, error) {
+	logrus.Debugf("Running %s %s", name, strings.Join(args, " "))
+	output, err := exec.Command(name, args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%s %s: %s", name, args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("%s %s: %w", name, args[0], err)
+	}
+	return string(output), nil
+}
+
+// zfsDatasetAt returns the ZFS dataset mounted at path, if there is one.
+// Datasets with legacy mounts are only used if they are the volume device.
+func zfsDatasetAt(path, device string) (string, bool) {
+	output, err := snapshotCommandOutput("zfs", "list", "-H", "-o", "name,mountpoint", path)
+	if err != nil {
+		logrus.Debugf("Cannot find ZFS dataset of %s: %v", path, err)
+		return "", false
+	}
+	dataset, mountPoint, ok := strings.Cut(strings.TrimSpace(output), "\t")
+	if !ok {
+		return "", false
+	}
+	if mountPoint == path || (mountPoint == "legacy" && dataset == device) {
+		return dataset, true
+	}
+	return "", false
+}
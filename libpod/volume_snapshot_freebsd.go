@@ -0,0 +1,29 @@
+//go:build !remote
+
+package libpod
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// lvmThinVolume returns the thin provisioned logical volume of the device in
+// the VG/LV format. LVM is not supported on FreeBSD.
+func lvmThinVolume(device string) (string, bool) {
+	return "", false
+}
+
+// isBtrfsSubvolume returns whether path is the root of a btrfs subvolume.
+// Btrfs is not supported on FreeBSD.
+func isBtrfsSubvolume(path string) bool {
+	return false
+}
+
+// zfsDataset returns the ZFS dataset mounted at path, if there is one.
+// Datasets with legacy mounts are only used if they are the volume device.
+func zfsDataset(path, device string) (string, bool) {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(path, &fs); err != nil || unix.ByteSliceToString(fs.Fstypename[:]) != "zfs" {
+		return "", false
+	}
+	return zfsDatasetAt(path, device)
+}
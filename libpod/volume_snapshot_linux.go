@@ -0,0 +1,55 @@
+//go:build !remote
+
+package libpod
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// zfsSuperMagic is the filesystem type of ZFS reported by statfs(2).
+const zfsSuperMagic = 0x2fc12fc1
+
+// lvmThinVolume returns the thin provisioned logical volume of the device in
+// the VG/LV format, if the device is one.
+func lvmThinVolume(device string) (string, bool) {
+	if device == "" {
+		return "", false
+	}
+	output, err := snapshotCommandOutput("lvs", "--noheadings", "--options", "vg_name,lv_name,segtype", device)
+	if err != nil {
+		logrus.Debugf("Device %s is not a logical volume: %v", device, err)
+		return "", false
+	}
+	fields := strings.Fields(output)
+	if len(fields) != 3 || fields[2] != "thin" {
+		return "", false
+	}
+	return fields[0] + "/" + fields[1], true
+}
+
+// isBtrfsSubvolume returns whether path is the root of a btrfs subvolume.
+func isBtrfsSubvolume(path string) bool {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(path, &fs); err != nil || fs.Type != unix.BTRFS_SUPER_MAGIC {
+		return false
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return false
+	}
+	// the root directory of every subvolume has the inode number 256
+	return st.Ino == 256
+}
+
+// zfsDataset returns the ZFS dataset mounted at path, if there is one.
+// Datasets with legacy mounts are only used if they are the volume device.
+func zfsDataset(path, device string) (string, bool) {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(path, &fs); err != nil || fs.Type != zfsSuperMagic {
+		return "", false
+	}
+	return zfsDatasetAt(path, device)
+}
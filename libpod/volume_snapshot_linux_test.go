@@ -0,0 +1,154 @@
+//go:build !remote
+
+package libpod
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/podman/v5/libpod/define"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplaceVolumeData(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "kept"), []byte("snapshot"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(src, "dir"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dst, "kept"), []byte("changed"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dst, "added"), []byte("added"), 0o644))
+
+	require.NoError(t, replaceVolumeData(src, dst))
+
+	data, err := os.ReadFile(filepath.Join(dst, "kept"))
+	require.NoError(t, err)
+	assert.Equal(t, "snapshot", string(data))
+	assert.DirExists(t, filepath.Join(dst, "dir"))
+	assert.NoFileExists(t, filepath.Join(dst, "added"))
+	entries, err := os.ReadDir(dst)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestReplaceVolumeDataCopyFailure(t *testing.T) {
+	dst := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dst, "kept"), []byte("volume"), 0o644))
+
+	require.Error(t, replaceVolumeData(filepath.Join(t.TempDir(), "missing"), dst))
+
+	// the old content is untouched
+	data, err := os.ReadFile(filepath.Join(dst, "kept"))
+	require.NoError(t, err)
+	assert.Equal(t, "volume", string(data))
+	entries, err := os.ReadDir(dst)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestSnapshotNames(t *testing.T) {
+	assert.Equal(t, "vg/data-snapshot-snap1", lvmSnapshotName("vg/data", "snap1"))
+	assert.Equal(t, "tank/volumes/data@podman-snap1", zfsSnapshotName("tank/volumes/data", "snap1"))
+}
+
+// fakeSnapshotCommands replaces the programs of the snapshot backends with
+// run and returns the command lines run.
+func fakeSnapshotCommands(t *testing.T, run func(args []string) error) *[]string {
+	var commands []string
+	orig := snapshotCommandOutput
+	snapshotCommandOutput = func(name string, args ...string) (string, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return "", run(append([]string{name}, args...))
+	}
+	t.Cleanup(func() {
+		snapshotCommandOutput = orig
+	})
+	return &commands
+}
+
+func TestRestoreSnapshotLVMThin(t *testing.T) {
+	v := &Volume{config: &VolumeConfig{Name: "data"}, state: &VolumeState{}}
+	snapshot := &define.VolumeSnapshot{Name: "snap1", Volume: "data", Backend: define.VolumeSnapshotBackendLVMThin, Source: "vg/data"}
+
+	// the snapshot itself is merged into the volume and taken again
+	commands := fakeSnapshotCommands(t, func([]string) error { return nil })
+	require.NoError(t, v.restoreSnapshot(snapshot, t.TempDir()))
+	assert.Equal(t, []string{
+		"lvconvert --yes --merge vg/data-snapshot-snap1",
+		"lvcreate --snapshot --name data-snapshot-snap1 vg/data",
+	}, *commands)
+
+	commands = fakeSnapshotCommands(t, func(args []string) error {
+		if args[0] == "lvcreate" {
+			return errors.New("no space left")
+		}
+		return nil
+	})
+	err := v.restoreSnapshot(snapshot, t.TempDir())
+	assert.ErrorContains(t, err, "the volume data was restored")
+	assert.Len(t, *commands, 2)
+
+	// a failed merge leaves the snapshot alone
+	commands = fakeSnapshotCommands(t, func([]string) error { return errors.New("merge failed") })
+	assert.ErrorContains(t, v.restoreSnapshot(snapshot, t.TempDir()), "merge failed")
+	assert.Len(t, *commands, 1)
+
+	v.state.MountCount = 1
+	commands = fakeSnapshotCommands(t, func([]string) error { return nil })
+	assert.ErrorIs(t, v.restoreSnapshot(snapshot, t.TempDir()), define.ErrVolumeBeingUsed)
+	assert.Empty(t, *commands)
+}
+
+// fakeBtrfs makes the btrfs subvolume commands copy and remove directories,
+// unless snapshots fail to appear with noSnapshot.
+func fakeBtrfs(t *testing.T, noSnapshot bool) *[]string {
+	return fakeSnapshotCommands(t, func(args []string) error {
+		require.Equal(t, []string{"btrfs", "subvolume"}, args[:2])
+		switch args[2] {
+		case "snapshot":
+			if noSnapshot {
+				return nil
+			}
+			return exec.Command("cp", "-a", args[3], args[4]).Run()
+		case "delete":
+			return os.RemoveAll(args[3])
+		}
+		return errors.New("unexpected command")
+	})
+}
+
+func TestRestoreSnapshotBtrfs(t *testing.T) {
+	dir := t.TempDir()
+	mountPoint := filepath.Join(dir, "_data")
+	require.NoError(t, os.Mkdir(mountPoint, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(mountPoint, "file"), []byte("volume"), 0o644))
+	snapshotDir := filepath.Join(dir, "snapshots", "snap1")
+	require.NoError(t, os.MkdirAll(filepath.Join(snapshotDir, volumeSnapshotDataDir), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotDir, volumeSnapshotDataDir, "file"), []byte("snapshot"), 0o644))
+	v := &Volume{config: &VolumeConfig{Name: "data", MountPoint: mountPoint}, state: &VolumeState{}}
+	snapshot := &define.VolumeSnapshot{Name: "snap1", Volume: "data", Backend: define.VolumeSnapshotBackendBtrfs}
+
+	// the volume data is kept if the snapshot cannot take its place
+	fakeBtrfs(t, true)
+	require.Error(t, v.restoreSnapshot(snapshot, snapshotDir))
+	data, err := os.ReadFile(filepath.Join(mountPoint, "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "volume", string(data))
+	assert.NoDirExists(t, mountPoint+".old")
+
+	commands := fakeBtrfs(t, false)
+	require.NoError(t, v.restoreSnapshot(snapshot, snapshotDir))
+	data, err = os.ReadFile(filepath.Join(mountPoint, "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "snapshot", string(data))
+	assert.NoDirExists(t, mountPoint+".old")
+	assert.NoDirExists(t, mountPoint+".restore")
+	assert.Equal(t, []string{
+		"btrfs subvolume snapshot " + filepath.Join(snapshotDir, volumeSnapshotDataDir) + " " + mountPoint + ".restore",
+		"btrfs subvolume delete " + mountPoint + ".old",
+	}, *commands)
+}
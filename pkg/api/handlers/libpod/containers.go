@@ -408,15 +408,44 @@ func UpdateContainer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	decoder := utils.GetDecoder(r)
+	query := struct {
+		HealthOnFailure string `schema:"healthOnFailure"`
+	}{}
+	if err := decoder.Decode(&query, r.URL.Query()); err != nil {
+		utils.Error(w, http.StatusBadRequest, fmt.Errorf("failed to parse parameters for %s: %w", r.URL.String(), err))
+		return
+	}
+	var onFailureAction define.HealthCheckOnFailureAction
+	if query.HealthOnFailure != "" {
+		onFailureAction, err = define.ParseHealthCheckOnFailureAction(query.HealthOnFailure)
+		if err != nil {
+			utils.Error(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
 	options := &handlers.UpdateEntities{Resources: &specs.LinuxResources{}}
 	if err := json.NewDecoder(r.Body).Decode(&options.Resources); err != nil {
 		utils.Error(w, http.StatusInternalServerError, fmt.Errorf("decode(): %w", err))
 		return
 	}
-	err = ctr.Update(options.Resources)
-	if err != nil {
-		utils.InternalServerError(w, err)
-		return
+	if options.Resources != nil || query.HealthOnFailure == "" {
+		err = ctr.Update(options.Resources)
+		if err != nil {
+			utils.InternalServerError(w, err)
+			return
+		}
+	}
+	if query.HealthOnFailure != "" {
+		if err := ctr.UpdateHealthCheckOnFailureAction(onFailureAction); err != nil {
+			if errors.Is(err, define.ErrInvalidArg) {
+				utils.Error(w, http.StatusBadRequest, err)
+				return
+			}
+			utils.InternalServerError(w, err)
+			return
+		}
 	}
 	utils.WriteResponse(w, http.StatusCreated, ctr.ID())
 }
@@ -443,3 +472,35 @@ func ShouldRestart(w http.ResponseWriter, r *http.Request) {
 		utils.ContainerNotFound(w, name, define.ErrNoSuchCtr)
 	}
 }
+
+func UpdateContainerPorts(w http.ResponseWriter, r *http.Request) {
+	decoder := r.Context().Value(api.DecoderKey).(*schema.Decoder)
+	runtime := r.Context().Value(api.RuntimeKey).(*libpod.Runtime)
+	query := struct {
+		Add    []string `schema:"add"`
+		Remove []string `schema:"remove"`
+	}{}
+	if err := decoder.Decode(&query, r.URL.Query()); err != nil {
+		utils.Error(w, http.StatusBadRequest, fmt.Errorf("failed to parse parameters for %s: %w", r.URL.String(), err))
+		return
+	}
+
+	name := utils.GetName(r)
+	if _, err := runtime.LookupContainer(name); err != nil {
+		utils.ContainerNotFound(w, name, err)
+		return
+	}
+
+	containerEngine := abi.ContainerEngine{Libpod: runtime}
+	options := entities.ContainerPortUpdateOptions{Add: query.Add, Remove: query.Remove}
+	report, err := containerEngine.ContainerPortUpdate(r.Context(), name, options)
+	if err != nil {
+		if errors.Is(err, define.ErrInvalidArg) || errors.Is(err, define.ErrNetworkModeInvalid) {
+			utils.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.InternalServerError(w, err)
+		return
+	}
+	utils.WriteResponse(w, http.StatusOK, report)
+}
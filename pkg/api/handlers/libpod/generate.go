@@ -23,6 +23,10 @@ func GenerateSystemd(w http.ResponseWriter, r *http.Request) {
 		TemplateUnitFile       bool     `schema:"templateUnitFile"`
 		RestartPolicy          *string  `schema:"restartPolicy"`
 		RestartSec             uint     `schema:"restartSec"`
+		RestartSteps           *uint    `schema:"restartSteps"`
+		RestartMaxDelaySec     *uint    `schema:"restartMaxDelaySec"`
+		ExitCodePropagation    *string  `schema:"exitCodePropagation"`
+		SdNotify               *string  `schema:"sdnotify"`
 		StopTimeout            uint     `schema:"stopTimeout"`
 		StartTimeout           uint     `schema:"startTimeout"`
 		ContainerPrefix        *string  `schema:"containerPrefix"`
@@ -70,6 +74,10 @@ func GenerateSystemd(w http.ResponseWriter, r *http.Request) {
 		PodPrefix:              PodPrefix,
 		Separator:              Separator,
 		RestartSec:             &query.RestartSec,
+		RestartSteps:           query.RestartSteps,
+		RestartMaxDelaySec:     query.RestartMaxDelaySec,
+		ExitCodePropagation:    query.ExitCodePropagation,
+		SdNotify:               query.SdNotify,
 		Wants:                  query.Wants,
 		After:                  query.After,
 		Requires:               query.Requires,
@@ -95,6 +103,7 @@ func GenerateKube(w http.ResponseWriter, r *http.Request) {
 		Type       string   `schema:"type"`
 		Replicas   int32    `schema:"replicas"`
 		NoTrunc    bool     `schema:"noTrunc"`
+		Volumes    bool     `schema:"volumes"`
 	}{
 		// Defaults would go here.
 		Replicas: 1,
@@ -123,6 +132,7 @@ func GenerateKube(w http.ResponseWriter, r *http.Request) {
 		Type:               generateType,
 		Replicas:           query.Replicas,
 		UseLongAnnotations: query.NoTrunc,
+		Volumes:            query.Volumes,
 	}
 	report, err := containerEngine.GenerateKube(r.Context(), query.Names, options)
 	if err != nil {
@@ -29,6 +29,7 @@ import (
 	"github.com/containers/podman/v5/pkg/domain/infra/abi"
 	domainUtils "github.com/containers/podman/v5/pkg/domain/utils"
 	"github.com/containers/podman/v5/pkg/errorhandling"
+	"github.com/containers/podman/v5/pkg/trust"
 	"github.com/containers/podman/v5/pkg/util"
 	utils2 "github.com/containers/podman/v5/utils"
 	"github.com/containers/storage"
@@ -108,7 +109,17 @@ func GetImage(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, http.StatusInternalServerError, fmt.Errorf("failed in inspect image %s: %w", inspect.ID, err))
 		return
 	}
-	utils.WriteResponse(w, http.StatusOK, inspect)
+	runtime := r.Context().Value(api.RuntimeKey).(*libpod.Runtime)
+	trustStatus, err := runtime.ImageTrustStatus(newImage)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, fmt.Errorf("failed in inspect image %s: %w", inspect.ID, err))
+		return
+	}
+	report := struct {
+		*libimage.ImageData
+		TrustStatus *trust.Status `json:"TrustStatus,omitempty"`
+	}{inspect, trustStatus}
+	utils.WriteResponse(w, http.StatusOK, report)
 }
 
 func PruneImages(w http.ResponseWriter, r *http.Request) {
@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/containers/common/libimage"
-	"github.com/containers/common/pkg/config"
 	"github.com/containers/image/v5/types"
 	"github.com/containers/podman/v5/libpod"
 	"github.com/containers/podman/v5/pkg/api/handlers/utils"
@@ -17,7 +16,10 @@ import (
 	"github.com/containers/podman/v5/pkg/auth"
 	"github.com/containers/podman/v5/pkg/channel"
 	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/containers/podman/v5/pkg/util"
+	"github.com/containers/storage/pkg/chunked"
 	"github.com/gorilla/schema"
+	"github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
 )
 
@@ -29,14 +31,15 @@ func ImagesPull(w http.ResponseWriter, r *http.Request) {
 	runtime := r.Context().Value(api.RuntimeKey).(*libpod.Runtime)
 	decoder := r.Context().Value(api.DecoderKey).(*schema.Decoder)
 	query := struct {
-		AllTags    bool   `schema:"allTags"`
-		CompatMode bool   `schema:"compatMode"`
-		PullPolicy string `schema:"policy"`
-		Quiet      bool   `schema:"quiet"`
-		Reference  string `schema:"reference"`
-		Retry      uint   `schema:"retry"`
-		RetryDelay string `schema:"retrydelay"`
-		TLSVerify  bool   `schema:"tlsVerify"`
+		AllTags             bool   `schema:"allTags"`
+		CompatMode          bool   `schema:"compatMode"`
+		PartialPullProgress bool   `schema:"partialPullProgress"`
+		PullPolicy          string `schema:"policy"`
+		Quiet               bool   `schema:"quiet"`
+		Reference           string `schema:"reference"`
+		Retry               uint   `schema:"retry"`
+		RetryDelay          string `schema:"retrydelay"`
+		TLSVerify           bool   `schema:"tlsVerify"`
 		// Platform fields below:
 		Arch    string `schema:"Arch"`
 		OS      string `schema:"OS"`
@@ -92,11 +95,14 @@ func ImagesPull(w http.ResponseWriter, r *http.Request) {
 		pullOptions.IdentityToken = authConf.IdentityToken
 	}
 
-	pullPolicy, err := config.ParsePullPolicy(query.PullPolicy)
+	pullPolicy, maxAge, err := util.ParsePullPolicy(query.PullPolicy)
 	if err != nil {
 		utils.Error(w, http.StatusBadRequest, err)
 		return
 	}
+	if maxAge > 0 {
+		pullPolicy = runtime.PullPolicyForMaxAge(query.Reference, maxAge)
+	}
 
 	if _, found := r.URL.Query()["retry"]; found {
 		pullOptions.MaxRetries = &query.Retry
@@ -113,10 +119,16 @@ func ImagesPull(w http.ResponseWriter, r *http.Request) {
 
 	// Let's keep thing simple when running in quiet mode and pull directly.
 	if query.Quiet {
-		images, err := runtime.LibimageRuntime().Pull(r.Context(), query.Reference, pullPolicy, pullOptions)
+		ctx, recordTrustStatus := runtime.WithTrustStatus(r.Context())
+		ctx, recordPullCheck := runtime.WithPullCheck(ctx)
+		images, err := runtime.LibimageRuntime().Pull(runtime.WithMirrorHealth(ctx), query.Reference, pullPolicy, pullOptions)
 		var report entities.ImagePullReport
 		if err != nil {
 			report.Error = err.Error()
+		} else {
+			recordPullCheck(images, pullPolicy)
+			recordTrustStatus(images, nil)
+			runtime.ScanPulledImages(r.Context(), images)
 		}
 		for _, image := range images {
 			report.Images = append(report.Images, image.ID())
@@ -139,9 +151,32 @@ func ImagesPull(w http.ResponseWriter, r *http.Request) {
 	var pulledImages []*libimage.Image
 	var pullError error
 	runCtx, cancel := context.WithCancel(r.Context())
+	progress := make(chan entities.PartialPullProgress)
+	pullCtx := runCtx
+	if query.PartialPullProgress {
+		pullCtx = chunked.WithProgressReporter(pullCtx, chunked.ThrottleProgress(time.Second, func(blobDigest digest.Digest, p chunked.Progress) {
+			select {
+			case progress <- entities.PartialPullProgress{
+				Digest:         blobDigest.String(),
+				Files:          p.Files,
+				ReusedFiles:    p.ReusedFiles,
+				RemainingBytes: p.RemainingBytes,
+				Done:           p.Done,
+			}:
+			case <-runCtx.Done():
+			}
+		}))
+	}
 	go func() {
 		defer cancel()
-		pulledImages, pullError = runtime.LibimageRuntime().Pull(runCtx, query.Reference, pullPolicy, pullOptions)
+		ctx, recordTrustStatus := runtime.WithTrustStatus(pullCtx)
+		ctx, recordPullCheck := runtime.WithPullCheck(ctx)
+		pulledImages, pullError = runtime.LibimageRuntime().Pull(runtime.WithMirrorHealth(ctx), query.Reference, pullPolicy, pullOptions)
+		if pullError == nil {
+			recordPullCheck(pulledImages, pullPolicy)
+			recordTrustStatus(pulledImages, writer)
+			runtime.ScanPulledImages(runCtx, pulledImages)
+		}
 	}()
 
 	flush := func() {
@@ -165,6 +200,12 @@ func ImagesPull(w http.ResponseWriter, r *http.Request) {
 				logrus.Warnf("Failed to encode json: %v", err)
 			}
 			flush()
+		case p := <-progress:
+			report.PartialPull = &p
+			if err := enc.Encode(report); err != nil {
+				logrus.Warnf("Failed to encode json: %v", err)
+			}
+			flush()
 		case <-runCtx.Done():
 			for _, image := range pulledImages {
 				report.Images = append(report.Images, image.ID())
@@ -28,6 +28,7 @@ func KubePlay(w http.ResponseWriter, r *http.Request) {
 		Replace          bool              `schema:"replace"`
 		PublishPorts     []string          `schema:"publishPorts"`
 		PublishAllPorts  bool              `schema:"publishAllPorts"`
+		RefreshConfigs   bool              `schema:"refreshConfigs"`
 		ServiceContainer bool              `schema:"serviceContainer"`
 		Start            bool              `schema:"start"`
 		StaticIPs        []string          `schema:"staticIPs"`
@@ -100,6 +101,7 @@ func KubePlay(w http.ResponseWriter, r *http.Request) {
 		PublishPorts:       query.PublishPorts,
 		PublishAllPorts:    query.PublishAllPorts,
 		Quiet:              true,
+		RefreshConfigs:     query.RefreshConfigs,
 		Replace:            query.Replace,
 		ServiceContainer:   query.ServiceContainer,
 		StaticIPs:          staticIPs,
@@ -33,6 +33,7 @@ func CreateNetwork(w http.ResponseWriter, r *http.Request) {
 
 	query := struct {
 		IgnoreIfExists bool `schema:"ignoreIfExists"`
+		DisableIPv4    bool `schema:"disableIPv4"`
 	}{}
 	decoder := r.Context().Value(api.DecoderKey).(*schema.Decoder)
 	if err := decoder.Decode(&query, r.URL.Query()); err != nil {
@@ -41,7 +42,7 @@ func CreateNetwork(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ic := abi.ContainerEngine{Libpod: runtime}
-	report, err := ic.NetworkCreate(r.Context(), network, &types.NetworkCreateOptions{IgnoreIfExists: query.IgnoreIfExists})
+	report, err := ic.NetworkCreate(r.Context(), network, &types.NetworkCreateOptions{IgnoreIfExists: query.IgnoreIfExists, DisableIPv4: query.DisableIPv4})
 	if err != nil {
 		if errors.Is(err, types.ErrNetworkExists) {
 			utils.Error(w, http.StatusConflict, err)
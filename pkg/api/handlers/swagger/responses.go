@@ -139,6 +139,13 @@ type podTopResponse struct {
 	Body handlers.PodTopOKBody
 }
 
+// Update pod
+// swagger:response
+type podUpdateResponse struct {
+	// in:body
+	ID string
+}
+
 // Pod Statistics
 // swagger:response
 type podStatsResponse struct {
@@ -265,6 +272,20 @@ type volumeListLibpod struct {
 	Body []entities.VolumeConfigResponse
 }
 
+// Volume snapshot
+// swagger:response
+type volumeSnapshotResponse struct {
+	// in:body
+	Body entities.VolumeSnapshotReport
+}
+
+// Volume snapshot list
+// swagger:response
+type volumeSnapshotListResponse struct {
+	// in:body
+	Body []entities.VolumeSnapshotReport
+}
+
 // Image Prune
 // swagger:response
 type imagesPruneLibpod struct {
@@ -314,6 +335,13 @@ type containerCreateResponse struct {
 	Body entities.ContainerCreateResponse
 }
 
+// Update container ports
+// swagger:response
+type containerPortUpdateResponse struct {
+	// in:body
+	Body entities.ContainerPortReport
+}
+
 type containerUpdateResponse struct {
 	// in:body
 	ID string
@@ -120,7 +120,14 @@ func CompatPull(ctx context.Context, w http.ResponseWriter, runtime *libpod.Runt
 
 	pullResChan := make(chan pullResult)
 	go func() {
-		pulledImages, err := runtime.LibimageRuntime().Pull(ctx, reference, pullPolicy, pullOptions)
+		ctx, recordTrustStatus := runtime.WithTrustStatus(ctx)
+		ctx, recordPullCheck := runtime.WithPullCheck(ctx)
+		pulledImages, err := runtime.LibimageRuntime().Pull(runtime.WithMirrorHealth(ctx), reference, pullPolicy, pullOptions)
+		if err == nil {
+			recordPullCheck(pulledImages, pullPolicy)
+			recordTrustStatus(pulledImages, nil)
+			runtime.ScanPulledImages(ctx, pulledImages)
+		}
 		pullResChan <- pullResult{images: pulledImages, err: err}
 	}()
 
@@ -1755,6 +1755,10 @@ func (s *APIServer) registerContainersHandlers(r *mux.Router) error {
 	//    type: string
 	//    required: true
 	//    description: Full or partial ID or full name of the container to update
+	//  - in: query
+	//    name: healthOnFailure
+	//    type: string
+	//    description: Action to take once the container turns unhealthy (none, kill, restart or stop)
 	//  - in: body
 	//    name: resources
 	//    description: attributes for updating the container
@@ -1766,10 +1770,48 @@ func (s *APIServer) registerContainersHandlers(r *mux.Router) error {
 	//   responses:
 	//     201:
 	//       $ref: "#/responses/containerUpdateResponse"
+	//   400:
+	//     $ref: "#/responses/badParamError"
 	//   404:
 	//     $ref: "#/responses/containerNotFound"
 	//   500:
 	//     $ref: "#/responses/internalError"
 	r.HandleFunc(VersionedPath("/libpod/containers/{name}/update"), s.APIHandler(libpod.UpdateContainer)).Methods(http.MethodPost)
+	// swagger:operation POST /libpod/containers/{name}/ports libpod ContainerPortUpdateLibpod
+	// ---
+	// tags:
+	//   - containers
+	// summary: Update the published ports of a container
+	// description: Publish additional ports of a container or stop publishing ports. The port forwarding of running containers is updated in place.
+	// parameters:
+	//  - in: path
+	//    name: name
+	//    type: string
+	//    required: true
+	//    description: Full or partial ID or full name of the container
+	//  - in: query
+	//    name: add
+	//    type: array
+	//    items:
+	//      type: string
+	//    description: Ports to publish, in the format of the --publish option
+	//  - in: query
+	//    name: remove
+	//    type: array
+	//    items:
+	//      type: string
+	//    description: Ports to stop publishing, in the format of the --publish option
+	// produces:
+	// - application/json
+	// responses:
+	//   200:
+	//     $ref: "#/responses/containerPortUpdateResponse"
+	//   400:
+	//     $ref: "#/responses/badParamError"
+	//   404:
+	//     $ref: "#/responses/containerNotFound"
+	//   500:
+	//     $ref: "#/responses/internalError"
+	r.HandleFunc(VersionedPath("/libpod/containers/{name}/ports"), s.APIHandler(libpod.UpdateContainerPorts)).Methods(http.MethodPost)
 	return nil
 }
@@ -73,6 +73,24 @@ func (s *APIServer) registerGenerateHandlers(r *mux.Router) error {
 	//    default: 0
 	//    description: Configures the time to sleep before restarting a service.
 	//  - in: query
+	//    name: restartSteps
+	//    type: integer
+	//    description: Number of steps to increase the restart interval up to restartMaxDelaySec. Must be set together with restartMaxDelaySec.
+	//  - in: query
+	//    name: restartMaxDelaySec
+	//    type: integer
+	//    description: Longest time to sleep before restarting a service. Must be set together with restartSteps.
+	//  - in: query
+	//    name: exitCodePropagation
+	//    type: string
+	//    enum: ["any", "all", "none"]
+	//    description: Exit-code propagation of the pod. Requires new and is only supported for pods.
+	//  - in: query
+	//    name: sdnotify
+	//    type: string
+	//    enum: ["conmon", "container", "healthy"]
+	//    description: sd-notify mode of the containers. Requires new.
+	//  - in: query
 	//    name: wants
 	//    type: array
 	//    items:
@@ -1037,7 +1037,7 @@ func (s *APIServer) registerImagesHandlers(r *mux.Router) error {
 	//     type: string
 	//   - in: query
 	//     name: policy
-	//     description: Pull policy, "always" (default), "missing", "newer", "never".
+	//     description: Pull policy, "always" (default), "missing", "newer", "never", "daily" or "max-age=DURATION". The latter two pull like "newer" but only contact the registry if it has not been checked for the image within the duration (24h for "daily").
 	//     type: string
 	//   - in: query
 	//     name: tlsVerify
@@ -1048,6 +1048,11 @@ func (s *APIServer) registerImagesHandlers(r *mux.Router) error {
 	//     name: allTags
 	//     description: Pull all tagged images in the repository.
 	//     type: boolean
+	//   - in: query
+	//     name: partialPullProgress
+	//     description: "Stream the progress of the layers pulled partially, at most once a second per layer, in the partialPull field. Ignored with quiet and compatMode."
+	//     type: boolean
+	//     default: false
 	//   - in: header
 	//     name: X-Registry-Auth
 	//     description: "base-64 encoded auth config. Must include the following four values: username, password, email and server address OR simply just an identity token."
@@ -97,6 +97,11 @@ func (s *APIServer) registerKubeHandlers(r *mux.Router) error {
 	//    type: boolean
 	//    default: false
 	//    description: Clean up all objects created when a SIGTERM is received or pods exit.
+	//  - in: query
+	//    name: refreshConfigs
+	//    type: boolean
+	//    default: false
+	//    description: Only update the ConfigMap and Secret volumes of already played pods and restart the containers annotated with io.podman.annotations.kube.config-restart.
 	//  - in: body
 	//    name: request
 	//    description: Kubernetes YAML file.
@@ -174,6 +179,11 @@ func (s *APIServer) registerKubeHandlers(r *mux.Router) error {
 	//    type: boolean
 	//    default: false
 	//    description: add podman-only reserved annotations in generated YAML file (cannot be used by Kubernetes)
+	//  - in: query
+	//    name: volumes
+	//    type: boolean
+	//    default: false
+	//    description: generate PersistentVolumeClaims for the named volumes used by the containers
 	// produces:
 	// - text/vnd.yaml
 	// - application/json
@@ -350,6 +350,11 @@ func (s *APIServer) registerNetworkHandlers(r *mux.Router) error {
 	//    description: attributes for creating a network
 	//    schema:
 	//      $ref: "#/definitions/networkCreateLibpod"
+	//  - in: query
+	//    name: disableIPv4
+	//    type: boolean
+	//    default: false
+	//    description: create an IPv6 only network, only supported by the bridge driver
 	// responses:
 	//   200:
 	//     $ref: "#/responses/networkCreateResponse"
@@ -145,6 +145,89 @@ func (s *APIServer) registerVolumeHandlers(r *mux.Router) error {
 	//   500:
 	//     $ref: "#/responses/internalError"
 	r.Handle(VersionedPath("/libpod/volumes/{name}"), s.APIHandler(libpod.RemoveVolume)).Methods(http.MethodDelete)
+	// swagger:operation POST /libpod/volumes/{name}/snapshots libpod VolumeSnapshotCreateLibpod
+	// ---
+	// tags:
+	//  - volumes
+	// summary: Create volume snapshot
+	// description: Take a snapshot of the volume data. Only volumes of the local driver support snapshots.
+	// parameters:
+	//  - in: path
+	//    name: name
+	//    type: string
+	//    required: true
+	//    description: the name or ID of the volume
+	//  - in: query
+	//    name: name
+	//    type: string
+	//    description: name of the snapshot, defaults to the current time
+	// produces:
+	// - application/json
+	// responses:
+	//   201:
+	//     $ref: "#/responses/volumeSnapshotResponse"
+	//   400:
+	//     $ref: "#/responses/badParamError"
+	//   404:
+	//     $ref: "#/responses/volumeNotFound"
+	//   409:
+	//     description: Snapshot with the same name already exists
+	//   500:
+	//     $ref: "#/responses/internalError"
+	r.Handle(VersionedPath("/libpod/volumes/{name}/snapshots"), s.APIHandler(libpod.CreateVolumeSnapshot)).Methods(http.MethodPost)
+	// swagger:operation GET /libpod/volumes/{name}/snapshots libpod VolumeSnapshotListLibpod
+	// ---
+	// tags:
+	//  - volumes
+	// summary: List volume snapshots
+	// description: List the snapshots of the volume, oldest first.
+	// parameters:
+	//  - in: path
+	//    name: name
+	//    type: string
+	//    required: true
+	//    description: the name or ID of the volume
+	// produces:
+	// - application/json
+	// responses:
+	//   200:
+	//     $ref: "#/responses/volumeSnapshotListResponse"
+	//   404:
+	//     $ref: "#/responses/volumeNotFound"
+	//   500:
+	//     $ref: "#/responses/internalError"
+	r.Handle(VersionedPath("/libpod/volumes/{name}/snapshots"), s.APIHandler(libpod.ListVolumeSnapshots)).Methods(http.MethodGet)
+	// swagger:operation POST /libpod/volumes/{name}/snapshots/{snapshot}/restore libpod VolumeSnapshotRestoreLibpod
+	// ---
+	// tags:
+	//  - volumes
+	// summary: Restore volume snapshot
+	// description: Replace the volume data with the data of the snapshot. The volume must not be used by a running container.
+	// parameters:
+	//  - in: path
+	//    name: name
+	//    type: string
+	//    required: true
+	//    description: the name or ID of the volume
+	//  - in: path
+	//    name: snapshot
+	//    type: string
+	//    required: true
+	//    description: the name of the snapshot
+	// produces:
+	// - application/json
+	// responses:
+	//   204:
+	//     description: no error
+	//   400:
+	//     $ref: "#/responses/badParamError"
+	//   404:
+	//     $ref: "#/responses/volumeNotFound"
+	//   409:
+	//     description: Volume is used by a running container
+	//   500:
+	//     $ref: "#/responses/internalError"
+	r.Handle(VersionedPath("/libpod/volumes/{name}/snapshots/{snapshot}/restore"), s.APIHandler(libpod.RestoreVolumeSnapshot)).Methods(http.MethodPost)
 
 	/*
 	 * Docker compatibility endpoints
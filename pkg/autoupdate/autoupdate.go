@@ -302,7 +302,7 @@ func (t *task) registryUpdate(ctx context.Context) error {
 	pullOptions.AuthFilePath = t.authfile
 	pullOptions.Writer = os.Stderr
 	pullOptions.InsecureSkipTLSVerify = t.auto.options.InsecureSkipTLSVerify
-	if _, err := t.auto.runtime.LibimageRuntime().Pull(ctx, t.rawImageName, config.PullPolicyAlways, pullOptions); err != nil {
+	if _, err := t.auto.runtime.LibimageRuntime().Pull(t.auto.runtime.WithMirrorHealth(ctx), t.rawImageName, config.PullPolicyAlways, pullOptions); err != nil {
 		return err
 	}
 
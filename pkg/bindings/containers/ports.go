@@ -0,0 +1,33 @@
+package containers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/containers/podman/v5/pkg/bindings"
+	"github.com/containers/podman/v5/pkg/domain/entities/types"
+)
+
+// UpdatePorts publishes additional ports of a container or stops publishing
+// ports.  It returns the ports the container publishes afterwards.
+func UpdatePorts(ctx context.Context, nameOrID string, options *UpdatePortsOptions) (*types.ContainerPortReport, error) {
+	if options == nil {
+		options = new(UpdatePortsOptions)
+	}
+	conn, err := bindings.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params, err := options.ToParams()
+	if err != nil {
+		return nil, err
+	}
+	response, err := conn.DoRequest(ctx, nil, http.MethodPost, "/containers/%s/ports", params, nil, nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var report types.ContainerPortReport
+	return &report, response.Process(&report)
+}
@@ -273,6 +273,15 @@ type RenameOptions struct {
 	Name *string
 }
 
+// UpdatePortsOptions are options for changing the published ports of
+// containers.
+//
+//go:generate go run ../generator/generator.go UpdatePortsOptions
+type UpdatePortsOptions struct {
+	Add    []string
+	Remove []string
+}
+
 // ResizeTTYOptions are optional options for resizing
 // container TTYs
 //
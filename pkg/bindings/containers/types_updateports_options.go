@@ -0,0 +1,48 @@
+// Code generated by go generate; DO NOT EDIT.
+package containers
+
+import (
+	"net/url"
+
+	"github.com/containers/podman/v5/pkg/bindings/internal/util"
+)
+
+// Changed returns true if named field has been set
+func (o *UpdatePortsOptions) Changed(fieldName string) bool {
+	return util.Changed(o, fieldName)
+}
+
+// ToParams formats struct fields to be passed to API service
+func (o *UpdatePortsOptions) ToParams() (url.Values, error) {
+	return util.ToParams(o)
+}
+
+// WithAdd set field Add to given value
+func (o *UpdatePortsOptions) WithAdd(value []string) *UpdatePortsOptions {
+	o.Add = value
+	return o
+}
+
+// GetAdd returns value of field Add
+func (o *UpdatePortsOptions) GetAdd() []string {
+	if o.Add == nil {
+		var z []string
+		return z
+	}
+	return o.Add
+}
+
+// WithRemove set field Remove to given value
+func (o *UpdatePortsOptions) WithRemove(value []string) *UpdatePortsOptions {
+	o.Remove = value
+	return o
+}
+
+// GetRemove returns value of field Remove
+func (o *UpdatePortsOptions) GetRemove() []string {
+	if o.Remove == nil {
+		var z []string
+		return z
+	}
+	return o.Remove
+}
@@ -3,6 +3,7 @@ package containers
 import (
 	"context"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/containers/podman/v5/pkg/bindings"
@@ -20,8 +21,12 @@ func Update(ctx context.Context, options *types.ContainerUpdateOptions) (string,
 	if err != nil {
 		return "", err
 	}
+	params := url.Values{}
+	if options.HealthOnFailure != "" {
+		params.Set("healthOnFailure", options.HealthOnFailure)
+	}
 	stringReader := strings.NewReader(resources)
-	response, err := conn.DoRequest(ctx, stringReader, http.MethodPost, "/containers/%s/update", nil, nil, options.NameOrID)
+	response, err := conn.DoRequest(ctx, stringReader, http.MethodPost, "/containers/%s/update", params, nil, options.NameOrID)
 	if err != nil {
 		return "", err
 	}
@@ -14,6 +14,8 @@ type KubeOptions struct {
 	Replicas *int32
 	// NoTrunc - don't truncate annotations to the Kubernetes maximum length of 63 characters
 	NoTrunc *bool
+	// Volumes - generate PersistentVolumeClaims for the named volumes used by the containers
+	Volumes *bool
 }
 
 // SystemdOptions are optional options for generating systemd files
@@ -32,6 +34,14 @@ type SystemdOptions struct {
 	RestartPolicy *string
 	// RestartSec - systemd service restartsec. Configures the time to sleep before restarting a service.
 	RestartSec *uint
+	// RestartSteps - systemd service restartsteps. Number of steps to reach RestartMaxDelaySec.
+	RestartSteps *uint
+	// RestartMaxDelaySec - systemd service restartmaxdelaysec. The longest time to sleep before restarting a service.
+	RestartMaxDelaySec *uint
+	// ExitCodePropagation - exit-code propagation of pods (any, all, none).
+	ExitCodePropagation *string
+	// SdNotify - sd-notify mode of new containers (conmon, container, healthy).
+	SdNotify *string
 	// StartTimeout - time when starting the container.
 	StartTimeout *uint
 	// StopTimeout - time when stopping the container.
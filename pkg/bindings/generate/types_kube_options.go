@@ -91,3 +91,18 @@ func (o *KubeOptions) GetNoTrunc() bool {
 	}
 	return *o.NoTrunc
 }
+
+// WithVolumes set field Volumes to given value
+func (o *KubeOptions) WithVolumes(value bool) *KubeOptions {
+	o.Volumes = &value
+	return o
+}
+
+// GetVolumes returns value of field Volumes
+func (o *KubeOptions) GetVolumes() bool {
+	if o.Volumes == nil {
+		var z bool
+		return z
+	}
+	return *o.Volumes
+}
@@ -107,6 +107,66 @@ func (o *SystemdOptions) GetRestartSec() uint {
 	return *o.RestartSec
 }
 
+// WithRestartSteps set field RestartSteps to given value
+func (o *SystemdOptions) WithRestartSteps(value uint) *SystemdOptions {
+	o.RestartSteps = &value
+	return o
+}
+
+// GetRestartSteps returns value of field RestartSteps
+func (o *SystemdOptions) GetRestartSteps() uint {
+	if o.RestartSteps == nil {
+		var z uint
+		return z
+	}
+	return *o.RestartSteps
+}
+
+// WithRestartMaxDelaySec set field RestartMaxDelaySec to given value
+func (o *SystemdOptions) WithRestartMaxDelaySec(value uint) *SystemdOptions {
+	o.RestartMaxDelaySec = &value
+	return o
+}
+
+// GetRestartMaxDelaySec returns value of field RestartMaxDelaySec
+func (o *SystemdOptions) GetRestartMaxDelaySec() uint {
+	if o.RestartMaxDelaySec == nil {
+		var z uint
+		return z
+	}
+	return *o.RestartMaxDelaySec
+}
+
+// WithExitCodePropagation set field ExitCodePropagation to given value
+func (o *SystemdOptions) WithExitCodePropagation(value string) *SystemdOptions {
+	o.ExitCodePropagation = &value
+	return o
+}
+
+// GetExitCodePropagation returns value of field ExitCodePropagation
+func (o *SystemdOptions) GetExitCodePropagation() string {
+	if o.ExitCodePropagation == nil {
+		var z string
+		return z
+	}
+	return *o.ExitCodePropagation
+}
+
+// WithSdNotify set field SdNotify to given value
+func (o *SystemdOptions) WithSdNotify(value string) *SystemdOptions {
+	o.SdNotify = &value
+	return o
+}
+
+// GetSdNotify returns value of field SdNotify
+func (o *SystemdOptions) GetSdNotify() string {
+	if o.SdNotify == nil {
+		var z string
+		return z
+	}
+	return *o.SdNotify
+}
+
 // WithStartTimeout set field StartTimeout to given value
 func (o *SystemdOptions) WithStartTimeout(value uint) *SystemdOptions {
 	o.StartTimeout = &value
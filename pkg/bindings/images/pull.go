@@ -94,6 +94,7 @@ LOOP:
 		case len(report.Images) > 0:
 			images = report.Images
 		case report.ID != "":
+		case report.PartialPull != nil:
 		default:
 			return images, fmt.Errorf("failed to parse pull results stream, unexpected input: %v", report)
 		}
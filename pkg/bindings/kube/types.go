@@ -61,6 +61,8 @@ type PlayOptions struct {
 	// Wait - indicates whether to return after having created the pods
 	Wait             *bool
 	ServiceContainer *bool
+	// RefreshConfigs - only update the ConfigMap and Secret volumes of already played pods
+	RefreshConfigs *bool
 }
 
 // ApplyOptions are optional options for applying kube YAML files to a k8s cluster
@@ -392,3 +392,18 @@ func (o *PlayOptions) GetServiceContainer() bool {
 	}
 	return *o.ServiceContainer
 }
+
+// WithRefreshConfigs set field RefreshConfigs to given value
+func (o *PlayOptions) WithRefreshConfigs(value bool) *PlayOptions {
+	o.RefreshConfigs = &value
+	return o
+}
+
+// GetRefreshConfigs returns value of field RefreshConfigs
+func (o *PlayOptions) GetRefreshConfigs() bool {
+	if o.RefreshConfigs == nil {
+		var z bool
+		return z
+	}
+	return *o.RefreshConfigs
+}
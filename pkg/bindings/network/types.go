@@ -100,4 +100,6 @@ type PruneOptions struct {
 type ExtraCreateOptions struct {
 	// IgnoreIfExists if true, do not fail if the network already exists
 	IgnoreIfExists *bool `schema:"ignoreIfExists"`
+	// DisableIPv4 if true, create an IPv6 only network
+	DisableIPv4 *bool `schema:"disableIPv4"`
 }
@@ -31,3 +31,18 @@ func (o *ExtraCreateOptions) GetIgnoreIfExists() bool {
 	}
 	return *o.IgnoreIfExists
 }
+
+// WithDisableIPv4 set field DisableIPv4 to given value
+func (o *ExtraCreateOptions) WithDisableIPv4(value bool) *ExtraCreateOptions {
+	o.DisableIPv4 = &value
+	return o
+}
+
+// GetDisableIPv4 returns value of field DisableIPv4
+func (o *ExtraCreateOptions) GetDisableIPv4() bool {
+	if o.DisableIPv4 == nil {
+		var z bool
+		return z
+	}
+	return *o.DisableIPv4
+}
@@ -11,6 +11,7 @@ import (
 	entitiesTypes "github.com/containers/podman/v5/pkg/domain/entities/types"
 	"github.com/containers/podman/v5/pkg/errorhandling"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/opencontainers/runtime-spec/specs-go"
 )
 
 func CreatePodFromSpec(ctx context.Context, spec *entitiesTypes.PodSpec) (*entitiesTypes.PodCreateReport, error) {
@@ -321,6 +322,28 @@ func Unpause(ctx context.Context, nameOrID string, options *UnpauseOptions) (*en
 	return &report, response.ProcessWithError(&report, &errorhandling.PodConflictErrorModel{})
 }
 
+// Update updates the resource limits of the pod cgroup of a pod.  Only the
+// limits set in resources are changed.
+func Update(ctx context.Context, nameOrID string, resources *specs.LinuxResources) (string, error) {
+	conn, err := bindings.GetClient(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := jsoniter.MarshalToString(resources)
+	if err != nil {
+		return "", err
+	}
+	stringReader := strings.NewReader(body)
+	response, err := conn.DoRequest(ctx, stringReader, http.MethodPost, "/pods/%s/update", nil, nil, nameOrID)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	return nameOrID, response.Process(nil)
+}
+
 // Stats display resource-usage statistics of one or more pods.
 func Stats(ctx context.Context, namesOrIDs []string, options *StatsOptions) ([]*entitiesTypes.PodStatsReport, error) {
 	if options == nil {
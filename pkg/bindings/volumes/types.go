@@ -43,3 +43,26 @@ type RemoveOptions struct {
 //go:generate go run ../generator/generator.go ExistsOptions
 type ExistsOptions struct {
 }
+
+// SnapshotCreateOptions are optional options for creating a snapshot of a
+// volume
+//
+//go:generate go run ../generator/generator.go SnapshotCreateOptions
+type SnapshotCreateOptions struct {
+	// Name of the snapshot, defaults to the current time
+	Name *string
+}
+
+// SnapshotListOptions are optional options for listing the snapshots of a
+// volume
+//
+//go:generate go run ../generator/generator.go SnapshotListOptions
+type SnapshotListOptions struct {
+}
+
+// SnapshotRestoreOptions are optional options for restoring a snapshot of a
+// volume
+//
+//go:generate go run ../generator/generator.go SnapshotRestoreOptions
+type SnapshotRestoreOptions struct {
+}
@@ -0,0 +1,33 @@
+// Code generated by go generate; DO NOT EDIT.
+package volumes
+
+import (
+	"net/url"
+
+	"github.com/containers/podman/v5/pkg/bindings/internal/util"
+)
+
+// Changed returns true if named field has been set
+func (o *SnapshotCreateOptions) Changed(fieldName string) bool {
+	return util.Changed(o, fieldName)
+}
+
+// ToParams formats struct fields to be passed to API service
+func (o *SnapshotCreateOptions) ToParams() (url.Values, error) {
+	return util.ToParams(o)
+}
+
+// WithName set field Name to given value
+func (o *SnapshotCreateOptions) WithName(value string) *SnapshotCreateOptions {
+	o.Name = &value
+	return o
+}
+
+// GetName returns value of field Name
+func (o *SnapshotCreateOptions) GetName() string {
+	if o.Name == nil {
+		var z string
+		return z
+	}
+	return *o.Name
+}
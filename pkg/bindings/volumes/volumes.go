@@ -139,3 +139,67 @@ func Exists(ctx context.Context, nameOrID string, options *ExistsOptions) (bool,
 
 	return response.IsSuccess(), nil
 }
+
+// SnapshotCreate takes a snapshot of the volume data.
+func SnapshotCreate(ctx context.Context, nameOrID string, options *SnapshotCreateOptions) (*entitiesTypes.VolumeSnapshotReport, error) {
+	var (
+		snapshot entitiesTypes.VolumeSnapshotReport
+	)
+	conn, err := bindings.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params, err := options.ToParams()
+	if err != nil {
+		return nil, err
+	}
+	response, err := conn.DoRequest(ctx, nil, http.MethodPost, "/volumes/%s/snapshots", params, nil, nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	return &snapshot, response.Process(&snapshot)
+}
+
+// SnapshotList returns the snapshots of the volume, oldest first.
+func SnapshotList(ctx context.Context, nameOrID string, options *SnapshotListOptions) ([]*entitiesTypes.VolumeSnapshotReport, error) {
+	var (
+		snapshots []*entitiesTypes.VolumeSnapshotReport
+	)
+	if options == nil {
+		options = new(SnapshotListOptions)
+	}
+	_ = options
+	conn, err := bindings.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	response, err := conn.DoRequest(ctx, nil, http.MethodGet, "/volumes/%s/snapshots", nil, nil, nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	return snapshots, response.Process(&snapshots)
+}
+
+// SnapshotRestore replaces the volume data with the data of the snapshot.
+// The volume must not be used by a running container.
+func SnapshotRestore(ctx context.Context, nameOrID, snapshot string, options *SnapshotRestoreOptions) error {
+	if options == nil {
+		options = new(SnapshotRestoreOptions)
+	}
+	_ = options
+	conn, err := bindings.GetClient(ctx)
+	if err != nil {
+		return err
+	}
+	response, err := conn.DoRequest(ctx, nil, http.MethodPost, "/volumes/%s/snapshots/%s/restore", nil, nil, nameOrID, snapshot)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	return response.Process(nil)
+}
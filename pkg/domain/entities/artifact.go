@@ -0,0 +1,79 @@
+package entities
+
+import (
+	"io"
+
+	"github.com/containers/image/v5/types"
+	"github.com/containers/podman/v5/pkg/libartifact"
+)
+
+// ArtifactAddOptions control the creation of an artifact from local files.
+type ArtifactAddOptions struct {
+	// ArtifactType is the media type of the artifact.
+	ArtifactType string
+}
+
+// ArtifactInspectOptions control inspecting an artifact.
+type ArtifactInspectOptions struct{}
+
+// ArtifactListOptions control listing artifacts.
+type ArtifactListOptions struct{}
+
+// ArtifactPullOptions control pulling an artifact from a registry.
+type ArtifactPullOptions struct {
+	// Authfile is the path to the authentication file.
+	Authfile string
+	// CertDir is the path to certificate directories.
+	CertDir string
+	// Username for authenticating against the registry.
+	Username string
+	// Password for authenticating against the registry.
+	Password string
+	// Quiet can be specified to suppress progress output.
+	Quiet bool
+	// SkipTLSVerify to skip HTTPS and certificate verification.
+	SkipTLSVerify types.OptionalBool
+	// Writer is used to display copy information including progress bars.
+	Writer io.Writer
+}
+
+// ArtifactPushOptions control pushing an artifact to a registry.
+type ArtifactPushOptions struct {
+	ArtifactPullOptions
+	// Destination of the artifact.  Defaults to the artifact's name.
+	Destination string
+}
+
+// ArtifactRemoveOptions control removing an artifact.
+type ArtifactRemoveOptions struct{}
+
+// ArtifactAddReport is the result of adding an artifact.
+type ArtifactAddReport struct {
+	// ArtifactDigest is the digest of the artifact's manifest.
+	ArtifactDigest string
+}
+
+// ArtifactInspectReport describes an artifact.
+type ArtifactInspectReport struct {
+	*libartifact.Artifact
+}
+
+// ArtifactListReport describes an artifact when listing.
+type ArtifactListReport struct {
+	*libartifact.Artifact
+}
+
+// ArtifactPullReport is the result of pulling an artifact.
+type ArtifactPullReport struct {
+	// ArtifactDigest is the digest of the artifact's manifest.
+	ArtifactDigest string
+}
+
+// ArtifactPushReport is the result of pushing an artifact.
+type ArtifactPushReport struct{}
+
+// ArtifactRemoveReport is the result of removing an artifact.
+type ArtifactRemoveReport struct {
+	// ArtifactDigest is the digest of the removed artifact's manifest.
+	ArtifactDigest string
+}
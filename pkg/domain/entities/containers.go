@@ -6,7 +6,6 @@ import (
 	"os"
 	"time"
 
-	nettypes "github.com/containers/common/libnetwork/types"
 	imageTypes "github.com/containers/image/v5/types"
 	"github.com/containers/podman/v5/libpod/define"
 	"github.com/containers/podman/v5/pkg/domain/entities/types"
@@ -441,11 +440,13 @@ type ContainerPortOptions struct {
 	Latest bool
 }
 
-// ContainerPortReport describes the output needed for
-// the CLI to output ports
-type ContainerPortReport struct {
-	Id    string //nolint:revive,stylecheck
-	Ports []nettypes.PortMapping
+type ContainerPortReport = types.ContainerPortReport
+
+// ContainerPortUpdateOptions describes the ports to publish or to stop
+// publishing on a container.  Ports are given in the --publish format.
+type ContainerPortUpdateOptions struct {
+	Add    []string
+	Remove []string
 }
 
 // ContainerCpOptions describes input options for cp.
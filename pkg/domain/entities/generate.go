@@ -10,6 +10,10 @@ type GenerateSystemdOptions struct {
 	New                    bool
 	RestartPolicy          *string
 	RestartSec             *uint
+	RestartSteps           *uint
+	RestartMaxDelaySec     *uint
+	ExitCodePropagation    *string
+	SdNotify               *string
 	StartTimeout           *uint
 	StopTimeout            *uint
 	ContainerPrefix        string
@@ -38,6 +42,8 @@ type GenerateKubeOptions struct {
 	Replicas int32
 	// UseLongAnnotations - don't truncate annotations to the Kubernetes maximum length of 63 characters
 	UseLongAnnotations bool
+	// Volumes - generate PersistentVolumeClaims for the named volumes used by the containers
+	Volumes bool
 }
 
 type KubeGenerateOptions = GenerateKubeOptions
@@ -3,6 +3,7 @@ package entities
 import (
 	"io"
 	"net/url"
+	"time"
 
 	"github.com/containers/common/pkg/config"
 	"github.com/containers/image/v5/manifest"
@@ -10,6 +11,8 @@ import (
 	"github.com/containers/image/v5/types"
 	encconfig "github.com/containers/ocicrypt/config"
 	entitiesTypes "github.com/containers/podman/v5/pkg/domain/entities/types"
+	"github.com/containers/podman/v5/pkg/imagescan"
+	"github.com/containers/podman/v5/pkg/trust"
 	"github.com/docker/docker/api/types/container"
 	"github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
@@ -102,6 +105,9 @@ type ImagePullOptions struct {
 	// Quiet can be specified to suppress pull progress when pulling.  Ignored
 	// for remote calls.
 	Quiet bool
+	// Verbose prints the statistics of the layers pulled partially.
+	// Ignored for remote calls.
+	Verbose bool
 	// Retry number of times to retry pull in case of failure
 	Retry *uint
 	// RetryDelay between retries in case of pull failures
@@ -112,6 +118,9 @@ type ImagePullOptions struct {
 	SkipTLSVerify types.OptionalBool
 	// PullPolicy whether to pull new image
 	PullPolicy config.PullPolicy
+	// PullMaxAge, if set, makes PullPolicyNewer only contact the registry if
+	// it has not been checked for a newer image within the duration.
+	PullMaxAge time.Duration
 	// Writer is used to display copy information including progress bars.
 	Writer io.Writer
 	// OciDecryptConfig contains the config that can be used to decrypt an image if it is
@@ -122,6 +131,8 @@ type ImagePullOptions struct {
 // ImagePullReport is the response from pulling one or more images.
 type ImagePullReport = entitiesTypes.ImagePullReport
 
+type PartialPullProgress = entitiesTypes.PartialPullProgress
+
 // ImagePushOptions are the arguments for pushing images.
 type ImagePushOptions struct {
 	// All indicates that all images referenced in a manifest list should be pushed
@@ -332,6 +343,19 @@ type ImageTreeOptions struct {
 // ImageTreeReport provides results from ImageEngine.Tree()
 type ImageTreeReport = entitiesTypes.ImageTreeReport
 
+// ImageScanOptions provides options for ImageEngine.Scan()
+type ImageScanOptions struct{}
+
+// ImageScanReport provides results from ImageEngine.Scan()
+type ImageScanReport struct {
+	// ID of the scanned image.
+	ID string
+	// Names of the scanned image.
+	Names []string
+	// Report of the scanner.
+	Report *imagescan.Report
+}
+
 // ShowTrustOptions are the cli options for showing trust
 type ShowTrustOptions struct {
 	JSON         bool
@@ -350,6 +374,21 @@ type SetTrustOptions struct {
 	Type        string
 }
 
+// VerifyTrustOptions describes the CLI options for verifying an image
+// against the trust policy
+type VerifyTrustOptions struct {
+	// Reference the image is verified for.  Defaults to the first name of
+	// the image.
+	Reference string
+}
+
+// VerifyTrustReport describes the result of verifying an image against the
+// trust policy
+type VerifyTrustReport struct {
+	ID          string
+	TrustStatus *trust.Status
+}
+
 // SignOptions describes input options for the CLI signing
 type SignOptions struct {
 	Directory string
@@ -1,6 +1,9 @@
 package entities
 
-import "github.com/containers/podman/v5/libpod/define"
+import (
+	"github.com/containers/podman/v5/libpod/define"
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+)
 
 type ListReporter struct {
 	Name               string
@@ -18,12 +21,18 @@ type ListReporter struct {
 	RemoteUsername     string
 	IdentityPath       string
 	UserModeNetworking bool
+	LastError          string
+	Health             string
+	State              string
+	ConfigPath         string
+	DefaultProvider    bool
 }
 
 // MachineInfo contains info on the machine host and version info
 type MachineInfo struct {
-	Host    *MachineHostInfo `json:"Host"`
-	Version define.Version   `json:"Version"`
+	Host      *MachineHostInfo       `json:"Host"`
+	Providers []*MachineProviderInfo `json:"Providers,omitempty"`
+	Version   define.Version         `json:"Version"`
 }
 
 // MachineHostInfo contains info on the machine host
@@ -39,3 +48,12 @@ type MachineHostInfo struct {
 	OS               string `json:"OS"`
 	VMType           string `json:"VMType"`
 }
+
+// MachineProviderInfo contains info on a virtualization provider and the
+// features it supports
+type MachineProviderInfo struct {
+	Capabilities machineDefine.Capabilities `json:"Capabilities"`
+	// Default is true for the provider new machines are created with
+	Default bool   `json:"Default"`
+	VMType  string `json:"VMType"`
+}
@@ -44,6 +44,7 @@ type NetworkCreateOptions struct {
 	Ranges            []string
 	Subnets           []string
 	Routes            []string
+	IPv4              bool
 	IPv6              bool
 	// Mapping of driver options and values.
 	Options map[string]string
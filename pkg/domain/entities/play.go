@@ -76,6 +76,9 @@ type PlayKubeOptions struct {
 	PublishAllPorts bool
 	// Wait - indicates whether to return after having created the pods
 	Wait bool
+	// RefreshConfigs - only update the files of the ConfigMap and Secret
+	// volumes of already played pods and restart the annotated containers
+	RefreshConfigs bool
 	// SystemContext - used when building the image
 	SystemContext *types.SystemContext
 }
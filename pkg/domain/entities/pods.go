@@ -75,33 +75,34 @@ type PodSpec = types.PodSpec
 // The JSON tags below are made to match the respective field in ContainerCreateOptions for the purpose of mapping.
 // swagger:model PodCreateOptions
 type PodCreateOptions struct {
-	CgroupParent       string            `json:"cgroup_parent,omitempty"`
-	CreateCommand      []string          `json:"create_command,omitempty"`
-	Devices            []string          `json:"devices,omitempty"`
-	DeviceReadBPs      []string          `json:"device_read_bps,omitempty"`
-	ExitPolicy         string            `json:"exit_policy,omitempty"`
-	Hostname           string            `json:"hostname,omitempty"`
-	Infra              bool              `json:"infra,omitempty"`
-	InfraImage         string            `json:"infra_image,omitempty"`
-	InfraName          string            `json:"container_name,omitempty"`
-	InfraCommand       *string           `json:"container_command,omitempty"`
-	InfraConmonPidFile string            `json:"container_conmon_pidfile,omitempty"`
-	Ipc                string            `json:"ipc,omitempty"`
-	Labels             map[string]string `json:"labels,omitempty"`
-	Name               string            `json:"name,omitempty"`
-	Net                *NetOptions       `json:"net,omitempty"`
-	Share              []string          `json:"share,omitempty"`
-	ShareParent        *bool             `json:"share_parent,omitempty"`
-	Restart            string            `json:"restart,omitempty"`
-	Pid                string            `json:"pid,omitempty"`
-	Cpus               float64           `json:"cpus,omitempty"`
-	CpusetCpus         string            `json:"cpuset_cpus,omitempty"`
-	Userns             specgen.Namespace `json:"-"`
-	Volume             []string          `json:"volume,omitempty"`
-	VolumesFrom        []string          `json:"volumes_from,omitempty"`
-	SecurityOpt        []string          `json:"security_opt,omitempty"`
-	Sysctl             []string          `json:"sysctl,omitempty"`
-	Uts                string            `json:"uts,omitempty"`
+	CgroupParent        string            `json:"cgroup_parent,omitempty"`
+	CreateCommand       []string          `json:"create_command,omitempty"`
+	Devices             []string          `json:"devices,omitempty"`
+	DeviceReadBPs       []string          `json:"device_read_bps,omitempty"`
+	ExitPolicy          string            `json:"exit_policy,omitempty"`
+	ExitCodePropagation string            `json:"exit_code_propagation,omitempty"`
+	Hostname            string            `json:"hostname,omitempty"`
+	Infra               bool              `json:"infra,omitempty"`
+	InfraImage          string            `json:"infra_image,omitempty"`
+	InfraName           string            `json:"container_name,omitempty"`
+	InfraCommand        *string           `json:"container_command,omitempty"`
+	InfraConmonPidFile  string            `json:"container_conmon_pidfile,omitempty"`
+	Ipc                 string            `json:"ipc,omitempty"`
+	Labels              map[string]string `json:"labels,omitempty"`
+	Name                string            `json:"name,omitempty"`
+	Net                 *NetOptions       `json:"net,omitempty"`
+	Share               []string          `json:"share,omitempty"`
+	ShareParent         *bool             `json:"share_parent,omitempty"`
+	Restart             string            `json:"restart,omitempty"`
+	Pid                 string            `json:"pid,omitempty"`
+	Cpus                float64           `json:"cpus,omitempty"`
+	CpusetCpus          string            `json:"cpuset_cpus,omitempty"`
+	Userns              specgen.Namespace `json:"-"`
+	Volume              []string          `json:"volume,omitempty"`
+	VolumesFrom         []string          `json:"volumes_from,omitempty"`
+	SecurityOpt         []string          `json:"security_opt,omitempty"`
+	Sysctl              []string          `json:"sysctl,omitempty"`
+	Uts                 string            `json:"uts,omitempty"`
 }
 
 // PodLogsOptions describes the options to extract pod logs.
@@ -177,6 +178,7 @@ type ContainerCreateOptions struct {
 	Hostname           string `json:"hostname,omitempty"`
 	HTTPProxy          bool
 	HostUsers          []string
+	HugeTLB            []string
 	ImageVolume        string
 	Init               bool
 	InitContainerType  string
@@ -211,6 +213,7 @@ type ContainerCreateOptions struct {
 	PublishAll         bool
 	Pull               string
 	Quiet              bool
+	Rdma               []string
 	ReadOnly           bool
 	ReadWriteTmpFS     bool
 	Restart            string
@@ -324,6 +327,7 @@ func ToPodSpecGen(s specgen.PodSpecGenerator, p *PodCreateOptions) (*specgen.Pod
 	s.UtsNs = out
 	s.Hostname = p.Hostname
 	s.ExitPolicy = p.ExitPolicy
+	s.ExitCodePropagation = p.ExitCodePropagation
 	s.Labels = p.Labels
 	s.Devices = p.Devices
 	s.SecurityOpt = p.SecurityOpt
@@ -410,6 +414,13 @@ type PodTopOptions struct {
 	NameOrID    string
 }
 
+// PodUpdateOptions contains options for updating the resource limits of an
+// existing pod
+type PodUpdateOptions struct {
+	NameOrID  string
+	Resources *specs.LinuxResources
+}
+
 type PodPSOptions struct {
 	CtrNames  bool
 	CtrIds    bool
@@ -1,12 +1,20 @@
 package types
 
 import (
+	nettypes "github.com/containers/common/libnetwork/types"
 	"github.com/containers/podman/v5/libpod/define"
 	"github.com/containers/podman/v5/pkg/specgen"
 )
 
 type ContainerCopyFunc func() error
 
+// ContainerPortReport describes the output needed for
+// the CLI to output ports
+type ContainerPortReport struct {
+	Id    string //nolint:revive,stylecheck
+	Ports []nettypes.PortMapping
+}
+
 type ContainerStatReport struct {
 	define.FileInfo
 }
@@ -38,4 +46,7 @@ type ContainerStatsReport struct {
 type ContainerUpdateOptions struct {
 	NameOrID string
 	Specgen  *specgen.SpecGenerator
+	// HealthOnFailure is the new healthcheck on-failure action, the
+	// action is not changed when empty.
+	HealthOnFailure string
 }
@@ -103,6 +103,12 @@ type FarmInspectReport struct {
 	OS                string
 	Arch              string
 	Variant           string
+	// CPUs is the number of CPUs of the node.
+	CPUs int
+	// MemFree is the amount of free memory of the node in bytes.
+	MemFree int64
+	// MemTotal is the total amount of memory of the node in bytes.
+	MemTotal int64
 }
 
 // ImageRemoveReport is the response for removing one or more image(s) from storage
@@ -139,6 +145,24 @@ type ImagePullReport struct {
 	Images []string `json:"images,omitempty"`
 	// ID contains image id (retained for backwards compatibility)
 	ID string `json:"id,omitempty"`
+	// PartialPull contains the progress of the partial pull of a layer
+	PartialPull *PartialPullProgress `json:"partialPull,omitempty"`
+}
+
+// PartialPullProgress is the progress of the partial pull of a layer.
+type PartialPullProgress struct {
+	// Digest is the digest of the layer blob
+	Digest string `json:"digest"`
+	// Files is the number of regular files of the layer with some content
+	Files int `json:"files"`
+	// ReusedFiles is the number of those files copied so far from files
+	// already on the host instead of being fetched
+	ReusedFiles int `json:"reusedFiles"`
+	// RemainingBytes is the size of the data still to be fetched from
+	// the registry
+	RemainingBytes int64 `json:"remainingBytes"`
+	// Done is set once the layer was pulled
+	Done bool `json:"done,omitempty"`
 }
 
 type ImagePushStream struct {
@@ -73,6 +73,7 @@ type SystemDfContainerReport struct {
 	LocalVolumes int
 	Size         int64
 	RWSize       int64
+	SizeLimit    int64
 	Created      time.Time
 	Status       string
 	Names        string
@@ -83,6 +84,7 @@ type SystemDfVolumeReport struct {
 	VolumeName      string
 	Links           int
 	Size            int64
+	SizeLimit       int64
 	ReclaimableSize int64
 }
 
@@ -36,6 +36,10 @@ type VolumeReloadReport struct {
 	define.VolumeReload
 }
 
+type VolumeSnapshotReport struct {
+	define.VolumeSnapshot
+}
+
 type VolumeMountReport struct {
 	Err  error
 	Id   string //nolint:revive,stylecheck
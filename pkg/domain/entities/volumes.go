@@ -38,6 +38,9 @@ type VolumeListReport = types.VolumeListReport
 // VolumeReloadReport describes the response from reload volume plugins
 type VolumeReloadReport = types.VolumeReloadReport
 
+// VolumeSnapshotReport describes a snapshot of a volume
+type VolumeSnapshotReport = types.VolumeSnapshotReport
+
 /*
  * Docker API compatibility types
  */
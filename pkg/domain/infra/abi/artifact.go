@@ -0,0 +1,155 @@
+//go:build !remote
+
+package abi
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/containers/image/v5/types"
+	"github.com/containers/podman/v5/libpod"
+	"github.com/containers/podman/v5/libpod/define"
+	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/containers/podman/v5/pkg/libartifact/store"
+)
+
+// artifactSystemContext returns the system context for accessing registries
+// with the specified options.
+func (ir *ImageEngine) artifactSystemContext(opts entities.ArtifactPullOptions) *types.SystemContext {
+	sc := types.SystemContext{}
+	if rtSC := ir.Libpod.SystemContext(); rtSC != nil {
+		sc = *rtSC
+	}
+	if opts.Authfile != "" {
+		sc.AuthFilePath = opts.Authfile
+	}
+	if opts.CertDir != "" {
+		sc.DockerCertPath = opts.CertDir
+	}
+	if opts.Username != "" {
+		sc.DockerAuthConfig = &types.DockerAuthConfig{
+			Username: opts.Username,
+			Password: opts.Password,
+		}
+	}
+	if opts.SkipTLSVerify != types.OptionalBoolUndefined {
+		sc.DockerInsecureSkipTLSVerify = opts.SkipTLSVerify
+	}
+	return &sc
+}
+
+func artifactCopyOptions(sc *types.SystemContext, opts entities.ArtifactPullOptions) store.CopyOptions {
+	copyOpts := store.CopyOptions{SystemContext: sc}
+	if !opts.Quiet {
+		copyOpts.ReportWriter = opts.Writer
+		if copyOpts.ReportWriter == nil {
+			copyOpts.ReportWriter = os.Stderr
+		}
+	}
+	return copyOpts
+}
+
+func (ir *ImageEngine) ArtifactAdd(ctx context.Context, name string, paths []string, opts entities.ArtifactAddOptions) (*entities.ArtifactAddReport, error) {
+	artStore, err := ir.Libpod.ArtifactStore()
+	if err != nil {
+		return nil, err
+	}
+	artifact, err := artStore.Add(ctx, name, paths, opts.ArtifactType)
+	if err != nil {
+		return nil, err
+	}
+	return &entities.ArtifactAddReport{ArtifactDigest: artifact.Digest.String()}, nil
+}
+
+func (ir *ImageEngine) ArtifactInspect(ctx context.Context, name string, _ entities.ArtifactInspectOptions) (*entities.ArtifactInspectReport, error) {
+	artStore, err := ir.Libpod.ArtifactStore()
+	if err != nil {
+		return nil, err
+	}
+	artifact, err := artStore.Inspect(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &entities.ArtifactInspectReport{Artifact: artifact}, nil
+}
+
+func (ir *ImageEngine) ArtifactList(ctx context.Context, _ entities.ArtifactListOptions) ([]*entities.ArtifactListReport, error) {
+	artStore, err := ir.Libpod.ArtifactStore()
+	if err != nil {
+		return nil, err
+	}
+	artifacts, err := artStore.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]*entities.ArtifactListReport, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		reports = append(reports, &entities.ArtifactListReport{Artifact: artifact})
+	}
+	return reports, nil
+}
+
+func (ir *ImageEngine) ArtifactPull(ctx context.Context, name string, opts entities.ArtifactPullOptions) (*entities.ArtifactPullReport, error) {
+	artStore, err := ir.Libpod.ArtifactStore()
+	if err != nil {
+		return nil, err
+	}
+	artifact, err := artStore.Pull(ctx, name, artifactCopyOptions(ir.artifactSystemContext(opts), opts))
+	if err != nil {
+		return nil, err
+	}
+	return &entities.ArtifactPullReport{ArtifactDigest: artifact.Digest.String()}, nil
+}
+
+func (ir *ImageEngine) ArtifactPush(ctx context.Context, name string, opts entities.ArtifactPushOptions) (*entities.ArtifactPushReport, error) {
+	artStore, err := ir.Libpod.ArtifactStore()
+	if err != nil {
+		return nil, err
+	}
+	copyOpts := artifactCopyOptions(ir.artifactSystemContext(opts.ArtifactPullOptions), opts.ArtifactPullOptions)
+	if err := artStore.Push(ctx, name, opts.Destination, copyOpts); err != nil {
+		return nil, err
+	}
+	return &entities.ArtifactPushReport{}, nil
+}
+
+func (ir *ImageEngine) ArtifactRm(ctx context.Context, name string, _ entities.ArtifactRemoveOptions) (*entities.ArtifactRemoveReport, error) {
+	artStore, err := ir.Libpod.ArtifactStore()
+	if err != nil {
+		return nil, err
+	}
+	artifactDigest, err := artStore.Remove(ctx, name, ir.artifactInUse)
+	if err != nil {
+		return nil, err
+	}
+	return &entities.ArtifactRemoveReport{ArtifactDigest: artifactDigest.String()}, nil
+}
+
+// artifactInUse returns an error when a running or paused container
+// bind-mounts any of the blobs at the specified paths
+func (ir *ImageEngine) artifactInUse(blobPaths []string) error {
+	blobs := make(map[string]struct{}, len(blobPaths))
+	for _, p := range blobPaths {
+		blobs[p] = struct{}{}
+	}
+	ctrs, err := ir.Libpod.GetContainers(false, func(c *libpod.Container) bool {
+		state, _ := c.State()
+		return state == define.ContainerStateRunning || state == define.ContainerStatePaused
+	})
+	if err != nil {
+		return err
+	}
+	for _, ctr := range ctrs {
+		spec := ctr.ConfigNoCopy().Spec
+		if spec == nil {
+			continue
+		}
+		for _, m := range spec.Mounts {
+			if _, ok := blobs[m.Source]; ok {
+				return fmt.Errorf("mounted by container %s: %w", ctr.ID(), store.ErrArtifactInUse)
+			}
+		}
+	}
+	return nil
+}
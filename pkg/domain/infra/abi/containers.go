@@ -1522,6 +1522,39 @@ func (ic *ContainerEngine) ContainerPort(ctx context.Context, nameOrID string, o
 	return reports, nil
 }
 
+// ContainerPortUpdate publishes additional ports of a container or stops
+// publishing ports.  Running containers are updated in place.
+func (ic *ContainerEngine) ContainerPortUpdate(ctx context.Context, nameOrID string, options entities.ContainerPortUpdateOptions) (*entities.ContainerPortReport, error) {
+	ctr, err := ic.Libpod.LookupContainer(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	add, err := specgenutil.CreatePortBindings(options.Add)
+	if err != nil {
+		return nil, err
+	}
+	remove, err := specgenutil.CreatePortBindings(options.Remove)
+	if err != nil {
+		return nil, err
+	}
+	current, err := ctr.PortMappings()
+	if err != nil {
+		return nil, err
+	}
+	ports, err := generate.RemovePortMappings(current, remove)
+	if err != nil {
+		return nil, err
+	}
+	ports, err = generate.ParsePortMapping(append(ports, add...), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctr.UpdatePortMappings(ports); err != nil {
+		return nil, err
+	}
+	return &entities.ContainerPortReport{Id: ctr.ID(), Ports: ports}, nil
+}
+
 // Shutdown Libpod engine
 func (ic *ContainerEngine) Shutdown(_ context.Context) {
 	shutdownSync.Do(func() {
@@ -1767,8 +1800,19 @@ func (ic *ContainerEngine) ContainerUpdate(ctx context.Context, updateOptions *e
 		return "", fmt.Errorf("container not found")
 	}
 
-	if err = containers[0].Update(updateOptions.Specgen.ResourceLimits); err != nil {
-		return "", err
+	if updateOptions.Specgen.ResourceLimits != nil || updateOptions.HealthOnFailure == "" {
+		if err = containers[0].Update(updateOptions.Specgen.ResourceLimits); err != nil {
+			return "", err
+		}
+	}
+	if updateOptions.HealthOnFailure != "" {
+		action, err := define.ParseHealthCheckOnFailureAction(updateOptions.HealthOnFailure)
+		if err != nil {
+			return "", err
+		}
+		if err := containers[0].UpdateHealthCheckOnFailureAction(action); err != nil {
+			return "", err
+		}
 	}
 	return containers[0].ID(), nil
 }
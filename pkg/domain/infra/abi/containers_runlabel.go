@@ -34,7 +34,7 @@ func (ic *ContainerEngine) ContainerRunlabel(ctx context.Context, label string,
 		pullOptions.Writer = os.Stderr
 	}
 
-	pulledImages, err := ic.Libpod.LibimageRuntime().Pull(ctx, imageRef, pullPolicy, pullOptions)
+	pulledImages, err := ic.Libpod.LibimageRuntime().Pull(ic.Libpod.WithMirrorHealth(ctx), imageRef, pullPolicy, pullOptions)
 	if err != nil {
 		return err
 	}
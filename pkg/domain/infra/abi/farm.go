@@ -43,14 +43,30 @@ func (ir *ImageEngine) fetchInfo(_ context.Context) (os, arch, variant string, n
 	return os, arch, variant, append([]string{}, nativePlatform), emulatedPlatforms, nil
 }
 
+// fetchResources returns the number of CPUs and the free and total memory of
+// the local host.
+func (ir *ImageEngine) fetchResources() (cpus int, memFree, memTotal int64, err error) {
+	info, err := ir.Libpod.Info()
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("retrieving host info: %w", err)
+	}
+	return info.Host.CPUs, info.Host.MemFree, info.Host.MemTotal, nil
+}
+
 // FarmNodeInspect returns information about the remote engines in the farm
 func (ir *ImageEngine) FarmNodeInspect(ctx context.Context) (*entities.FarmInspectReport, error) {
 	ir.platforms.Do(func() {
 		ir.os, ir.arch, ir.variant, ir.nativePlatforms, ir.emulatedPlatforms, ir.platformsErr = ir.fetchInfo(ctx)
+		if ir.platformsErr == nil {
+			ir.cpus, ir.memFree, ir.memTotal, ir.platformsErr = ir.fetchResources()
+		}
 	})
 	return &entities.FarmInspectReport{NativePlatforms: ir.nativePlatforms,
 		EmulatedPlatforms: ir.emulatedPlatforms,
 		OS:                ir.os,
 		Arch:              ir.arch,
-		Variant:           ir.variant}, ir.platformsErr
+		Variant:           ir.variant,
+		CPUs:              ir.cpus,
+		MemFree:           ir.memFree,
+		MemTotal:          ir.memTotal}, ir.platformsErr
 }
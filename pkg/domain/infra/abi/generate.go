@@ -182,6 +182,14 @@ func (ic *ContainerEngine) GenerateKube(ctx context.Context, nameOrIDs []string,
 		content = append(content, []byte(warning))
 	}
 
+	if options.Volumes {
+		usedVols, err := ic.appendUsedVolumes(vols, ctrs, pods)
+		if err != nil {
+			return nil, err
+		}
+		vols = usedVols
+	}
+
 	// Generate kube persistent volume claims from volumes.
 	if len(vols) >= 1 {
 		pvs, err := getKubePVCs(vols)
@@ -336,6 +344,39 @@ func getKubePods(ctx context.Context, pods []*libpod.Pod, options entities.Gener
 }
 
 // getKubePVCs returns kube persistent volume claim YAML files from podman volumes.
+// appendUsedVolumes appends the named volumes used by the containers and the
+// containers of the pods to vols, skipping volumes that are already included.
+func (ic *ContainerEngine) appendUsedVolumes(vols []*libpod.Volume, ctrs []*libpod.Container, pods []*libpod.Pod) ([]*libpod.Volume, error) {
+	seen := make(map[string]bool, len(vols))
+	for _, v := range vols {
+		seen[v.Name()] = true
+	}
+
+	allCtrs := ctrs
+	for _, pod := range pods {
+		podCtrs, err := pod.AllContainers()
+		if err != nil {
+			return nil, err
+		}
+		allCtrs = append(allCtrs, podCtrs...)
+	}
+
+	for _, ctr := range allCtrs {
+		for _, namedVolume := range ctr.NamedVolumes() {
+			if seen[namedVolume.Name] {
+				continue
+			}
+			seen[namedVolume.Name] = true
+			vol, err := ic.Libpod.GetVolume(namedVolume.Name)
+			if err != nil {
+				return nil, err
+			}
+			vols = append(vols, vol)
+		}
+	}
+	return vols, nil
+}
+
 func getKubePVCs(volumes []*libpod.Volume) ([][]byte, error) {
 	pvs := [][]byte{}
 
@@ -11,8 +11,10 @@ import (
 	"os/user"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -35,6 +37,10 @@ import (
 	"github.com/containers/podman/v5/pkg/errorhandling"
 	"github.com/containers/podman/v5/pkg/rootless"
 	"github.com/containers/storage"
+	graphdriver "github.com/containers/storage/drivers"
+	"github.com/containers/storage/pkg/chunked"
+	"github.com/containers/storage/pkg/stringid"
+	"github.com/docker/go-units"
 	"github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
@@ -268,10 +274,43 @@ func (ir *ImageEngine) Pull(ctx context.Context, rawImage string, options entiti
 		pullOptions.Writer = os.Stderr
 	}
 
-	pulledImages, err := ir.Libpod.LibimageRuntime().Pull(ctx, rawImage, options.PullPolicy, pullOptions)
+	statsWriter := pullOptions.Writer
+	if statsWriter == nil {
+		statsWriter = os.Stderr
+	}
+	var statsLock sync.Mutex
+	ctx = chunked.WithStatsReporter(ctx, func(blobDigest digest.Digest, stats *graphdriver.PullStats) {
+		ir.Libpod.NewPartialPullEvent(rawImage, blobDigest, stats)
+		if options.Verbose {
+			// layers are pulled concurrently
+			statsLock.Lock()
+			defer statsLock.Unlock()
+			fmt.Fprintf(statsWriter, "Partial pull of %s: %s\n", stringid.TruncateID(blobDigest.Encoded()), formatPullStats(stats))
+		}
+	})
+	if options.Verbose {
+		// the progress of each layer is printed at most once a second
+		ctx = chunked.WithProgressReporter(ctx, chunked.ThrottleProgress(time.Second, func(blobDigest digest.Digest, progress chunked.Progress) {
+			statsLock.Lock()
+			defer statsLock.Unlock()
+			fmt.Fprintf(statsWriter, "Partial pull of %s: %s\n", stringid.TruncateID(blobDigest.Encoded()), formatPullProgress(progress))
+		}))
+	}
+
+	pullPolicy := options.PullPolicy
+	if options.PullMaxAge > 0 && pullPolicy == config.PullPolicyNewer {
+		pullPolicy = ir.Libpod.PullPolicyForMaxAge(rawImage, options.PullMaxAge)
+	}
+
+	ctx, recordTrustStatus := ir.Libpod.WithTrustStatus(ctx)
+	ctx, recordPullCheck := ir.Libpod.WithPullCheck(ctx)
+	pulledImages, err := ir.Libpod.LibimageRuntime().Pull(ir.Libpod.WithMirrorHealth(ctx), rawImage, pullPolicy, pullOptions)
 	if err != nil {
 		return nil, err
 	}
+	recordPullCheck(pulledImages, pullPolicy)
+	recordTrustStatus(pulledImages, pullOptions.Writer)
+	ir.Libpod.ScanPulledImages(ctx, pulledImages)
 
 	pulledIDs := make([]string, len(pulledImages))
 	for i := range pulledImages {
@@ -281,6 +320,34 @@ func (ir *ImageEngine) Pull(ctx context.Context, rawImage string, options entiti
 	return &entities.ImagePullReport{Images: pulledIDs}, nil
 }
 
+// formatPullStats returns the statistics of a partial pull on one line.
+func formatPullStats(stats *graphdriver.PullStats) string {
+	parts := []string{
+		"reused " + units.HumanSize(float64(stats.ReusedBytes)),
+		"downloaded " + units.HumanSize(float64(stats.DownloadedBytes)),
+		"holes " + units.HumanSize(float64(stats.HoleBytes)),
+	}
+	sources := make([]string, 0, len(stats.DedupHits))
+	for source := range stats.DedupHits {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		parts = append(parts, fmt.Sprintf("%d from %s", stats.DedupHits[source], source))
+	}
+	for _, phase := range []string{"prepare", "dedup", "fetch", "finalize"} {
+		if d, ok := stats.Phases[phase]; ok {
+			parts = append(parts, fmt.Sprintf("%s %s", phase, d.Round(time.Millisecond)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatPullProgress returns the progress of a partial pull on one line.
+func formatPullProgress(progress chunked.Progress) string {
+	return fmt.Sprintf("%d of %d files reused locally, %s remaining from registry", progress.ReusedFiles, progress.Files, units.HumanSize(float64(progress.RemainingBytes)))
+}
+
 func (ir *ImageEngine) Inspect(ctx context.Context, namesOrIDs []string, opts entities.InspectOptions) ([]*entities.ImageInspectReport, []error, error) {
 	reports := []*entities.ImageInspectReport{}
 	errs := []error{}
@@ -302,6 +369,9 @@ func (ir *ImageEngine) Inspect(ctx context.Context, namesOrIDs []string, opts en
 		if err := domainUtils.DeepCopy(&report, result); err != nil {
 			return nil, nil, err
 		}
+		if report.TrustStatus, err = ir.Libpod.ImageTrustStatus(img); err != nil {
+			return nil, nil, err
+		}
 		reports = append(reports, &report)
 	}
 	return reports, errs, nil
@@ -556,6 +626,22 @@ func (ir *ImageEngine) Tree(ctx context.Context, nameOrID string, opts entities.
 	return &entities.ImageTreeReport{Tree: tree}, nil
 }
 
+func (ir *ImageEngine) Scan(ctx context.Context, nameOrIDs []string, _ entities.ImageScanOptions) ([]*entities.ImageScanReport, error) {
+	reports := make([]*entities.ImageScanReport, 0, len(nameOrIDs))
+	for _, nameOrID := range nameOrIDs {
+		image, _, err := ir.Libpod.LibimageRuntime().LookupImage(nameOrID, nil)
+		if err != nil {
+			return nil, err
+		}
+		report, err := ir.Libpod.ScanImage(ctx, image)
+		if err != nil {
+			return nil, fmt.Errorf("scanning image %s: %w", nameOrID, err)
+		}
+		reports = append(reports, &entities.ImageScanReport{ID: image.ID(), Names: image.Names(), Report: report})
+	}
+	return reports, nil
+}
+
 // removeErrorsToExitCode returns an exit code for the specified slice of
 // image-removal errors. The error codes are set according to the documented
 // behaviour in the Podman man pages.
@@ -2,8 +2,11 @@ package abi
 
 import (
 	"testing"
+	"time"
 
 	"github.com/containers/common/libimage"
+	graphdriver "github.com/containers/storage/drivers"
+	"github.com/containers/storage/pkg/chunked"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,3 +19,21 @@ func TestToDomainHistoryLayer(t *testing.T) {
 	newLayer := toDomainHistoryLayer(&layer)
 	assert.Equal(t, layer.Size, newLayer.Size)
 }
+
+func TestFormatPullStats(t *testing.T) {
+	stats := &graphdriver.PullStats{
+		ReusedBytes:     2000,
+		DownloadedBytes: 1000,
+		DedupHits:       map[string]int{"layers": 3, "dir=/cache": 1},
+		Phases: map[string]time.Duration{
+			"fetch":   1500 * time.Millisecond,
+			"prepare": time.Millisecond,
+		},
+	}
+	assert.Equal(t, "reused 2kB, downloaded 1kB, holes 0B, 1 from dir=/cache, 3 from layers, prepare 1ms, fetch 1.5s", formatPullStats(stats))
+}
+
+func TestFormatPullProgress(t *testing.T) {
+	progress := chunked.Progress{Files: 300, ReusedFiles: 120, RemainingBytes: 12_500_000}
+	assert.Equal(t, "120 of 300 files reused locally, 12.5MB remaining from registry", formatPullProgress(progress))
+}
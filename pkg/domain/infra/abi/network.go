@@ -162,7 +162,7 @@ func (ic *ContainerEngine) NetworkRm(ctx context.Context, namesOrIds []string, o
 				}
 			}
 		}
-		if err := ic.Libpod.Network().NetworkRemove(name); err != nil {
+		if err := ic.networkRemove(name); err != nil {
 			report.Err = err
 		}
 		reports = append(reports, &report)
@@ -174,6 +174,9 @@ func (ic *ContainerEngine) NetworkCreate(ctx context.Context, network types.Netw
 	if slices.Contains([]string{"none", "host", "bridge", "private", slirp4netns.BinaryName, pasta.BinaryName, "container", "ns", "default"}, network.Name) {
 		return nil, fmt.Errorf("cannot create network with name %q because it conflicts with a valid network mode", network.Name)
 	}
+	if network.Driver == types.WireGuardNetworkDriver {
+		return ic.networkCreateWireGuard(ctx, network, createOptions)
+	}
 	network, err := ic.Libpod.Network().NetworkCreate(network, createOptions)
 	if err != nil {
 		return nil, err
@@ -226,7 +229,7 @@ func (ic *ContainerEngine) NetworkPrune(ctx context.Context, options entities.Ne
 	for _, net := range nets {
 		pruneReport = append(pruneReport, &entities.NetworkPruneReport{
 			Name:  net.Name,
-			Error: ic.Libpod.Network().NetworkRemove(net.Name),
+			Error: ic.networkRemove(net.Name),
 		})
 	}
 	return pruneReport, nil
@@ -0,0 +1,193 @@
+package abi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/common/libnetwork/types"
+	netutil "github.com/containers/common/libnetwork/util"
+	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/curve25519"
+)
+
+// wireGuardNetworkLabel is set on the secrets holding the private keys
+// generated for wireguard networks.
+const wireGuardNetworkLabel = "io.podman.network.wireguard"
+
+// networkCreateWireGuard creates a wireguard network.  The private key of the
+// tunnel is read from the secret named by the private_key_secret option or, if
+// the option is not set, from the secret generated for a previous network of
+// the same name or generated and stored as a new secret.  The tunnel
+// configuration passed to netavark is written below the libpod directory and,
+// because netavark reads the private key from it whenever a container joins
+// the network, holds the key in plaintext.  Only the owner can read it.
+func (ic *ContainerEngine) networkCreateWireGuard(ctx context.Context, network types.Network, createOptions *types.NetworkCreateOptions) (_ *types.Network, retErr error) {
+	if network.Name == "" {
+		return nil, fmt.Errorf("a name is required for %s networks", network.Driver)
+	}
+	// make sure not to overwrite the configuration of an existing network
+	if existing, err := ic.Libpod.Network().NetworkInspect(network.Name); err == nil {
+		if createOptions != nil && createOptions.IgnoreIfExists {
+			return &existing, nil
+		}
+		return nil, fmt.Errorf("network name %s already used: %w", network.Name, types.ErrNetworkExists)
+	}
+	if _, ok := network.Options[types.ConfigOption]; ok {
+		return nil, fmt.Errorf("the %s option of %s networks is set by podman", types.ConfigOption, network.Driver)
+	}
+	peers, err := netutil.ParseWireGuardPeers(network.Options[types.PeersOption])
+	if err != nil {
+		return nil, err
+	}
+
+	manager, err := ic.Libpod.SecretsManager()
+	if err != nil {
+		return nil, err
+	}
+	secretName := network.Options[types.PrivateKeySecretOption]
+	if secretName == "" {
+		secretName = network.Name + "-wireguard-key"
+		// the key generated for a removed network of the same name is
+		// reused, any other secret of that name is not ours to take
+		secret, err := manager.Lookup(secretName)
+		switch {
+		case err == nil && secret.Labels[wireGuardNetworkLabel] == network.Name:
+			logrus.Debugf("Reusing private key %s of network %s", secretName, network.Name)
+		case err == nil:
+			return nil, fmt.Errorf("secret %s exists but does not hold a generated key of network %s, set the %s option to use it", secretName, network.Name, types.PrivateKeySecretOption)
+		default:
+			key, err := generateWireGuardKey()
+			if err != nil {
+				return nil, err
+			}
+			opts := entities.SecretCreateOptions{Labels: map[string]string{wireGuardNetworkLabel: network.Name}}
+			if _, err := ic.SecretCreate(ctx, secretName, strings.NewReader(key), opts); err != nil {
+				return nil, fmt.Errorf("storing private key of network %s: %w", network.Name, err)
+			}
+			defer func() {
+				if retErr != nil {
+					if _, err := manager.Delete(secretName); err != nil {
+						logrus.Errorf("Removing private key of network %s: %v", network.Name, err)
+					}
+				}
+			}()
+		}
+		network.Options[types.PrivateKeySecretOption] = secretName
+	}
+	_, data, err := manager.LookupSecretData(secretName)
+	if err != nil {
+		return nil, fmt.Errorf("reading private key of network %s: %w", network.Name, err)
+	}
+	privateKey := strings.TrimSpace(string(data))
+	publicKey, err := wireGuardPublicKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("private key in secret %s: %w", secretName, err)
+	}
+	network.Options[types.PublicKeyOption] = publicKey
+
+	cfg, err := ic.Libpod.GetConfigNoCopy()
+	if err != nil {
+		return nil, err
+	}
+	configPath := filepath.Join(cfg.Engine.StaticDir, "wireguard", network.Name+".conf")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		return nil, err
+	}
+	// the directory may have been created with a looser mode by someone else
+	if err := os.Chmod(filepath.Dir(configPath), 0o700); err != nil {
+		return nil, err
+	}
+	config := wireGuardConfig(privateKey, network.Options[types.ListenPortOption], peers, network.Subnets)
+	if err := os.WriteFile(configPath, config, 0o600); err != nil {
+		return nil, fmt.Errorf("writing configuration of network %s: %w", network.Name, err)
+	}
+	network.Options[types.ConfigOption] = configPath
+
+	created, err := ic.Libpod.Network().NetworkCreate(network, createOptions)
+	if err != nil {
+		if err := os.Remove(configPath); err != nil {
+			logrus.Errorf("Removing configuration of network %s: %v", network.Name, err)
+		}
+		return nil, err
+	}
+	return &created, nil
+}
+
+// networkRemove removes a network and, for wireguard networks, the tunnel
+// configuration.  Private keys are kept so that the network can be created
+// again with the same identity.
+func (ic *ContainerEngine) networkRemove(nameOrID string) error {
+	var configPath string
+	if net, err := ic.Libpod.Network().NetworkInspect(nameOrID); err == nil && net.Driver == types.WireGuardNetworkDriver {
+		configPath = net.Options[types.ConfigOption]
+	}
+	if err := ic.Libpod.Network().NetworkRemove(nameOrID); err != nil {
+		return err
+	}
+	if configPath != "" {
+		if err := os.Remove(configPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logrus.Errorf("Removing configuration of network %s: %v", nameOrID, err)
+		}
+	}
+	return nil
+}
+
+// generateWireGuardKey returns a new base64 encoded wireguard private key.
+func generateWireGuardKey() (string, error) {
+	key := make([]byte, netutil.WireGuardKeyLen)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	// clamp the key as described in RFC 7748
+	key[0] &= 248
+	key[31] = (key[31] & 127) | 64
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// wireGuardPublicKey returns the base64 encoded public key of a base64
+// encoded wireguard private key.
+func wireGuardPublicKey(privateKey string) (string, error) {
+	if err := netutil.ValidateWireGuardKey(privateKey); err != nil {
+		return "", err
+	}
+	key, _ := base64.StdEncoding.DecodeString(privateKey)
+	public, err := curve25519.X25519(key, curve25519.Basepoint)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(public), nil
+}
+
+// wireGuardConfig returns the tunnel configuration in the wg-quick format.
+// Peers without allowed IPs are routed all subnets of the network.
+func wireGuardConfig(privateKey, listenPort string, peers []netutil.WireGuardPeer, subnets []types.Subnet) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "[Interface]\nPrivateKey = %s\n", privateKey)
+	if listenPort != "" {
+		fmt.Fprintf(&b, "ListenPort = %s\n", listenPort)
+	}
+	for _, peer := range peers {
+		allowedIPs := make([]string, 0, len(peer.AllowedIPs))
+		for _, ip := range peer.AllowedIPs {
+			allowedIPs = append(allowedIPs, ip.String())
+		}
+		if len(allowedIPs) == 0 {
+			for _, subnet := range subnets {
+				allowedIPs = append(allowedIPs, subnet.Subnet.String())
+			}
+		}
+		fmt.Fprintf(&b, "\n[Peer]\nPublicKey = %s\nAllowedIPs = %s\n", peer.PublicKey, strings.Join(allowedIPs, ", "))
+		if peer.Endpoint != "" {
+			fmt.Fprintf(&b, "Endpoint = %s\n", peer.Endpoint)
+		}
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,51 @@
+package abi
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/containers/common/libnetwork/types"
+	netutil "github.com/containers/common/libnetwork/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWireGuardKeys(t *testing.T) {
+	// test vector of RFC 7748, section 6.1
+	private, _ := hex.DecodeString("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a")
+	public, _ := hex.DecodeString("8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a")
+	key, err := wireGuardPublicKey(base64.StdEncoding.EncodeToString(private))
+	require.NoError(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(public), key)
+
+	_, err = wireGuardPublicKey("c2hvcnQ=")
+	assert.ErrorContains(t, err, "invalid wireguard key")
+
+	generated, err := generateWireGuardKey()
+	require.NoError(t, err)
+	_, err = wireGuardPublicKey(generated)
+	assert.NoError(t, err)
+}
+
+func TestWireGuardConfig(t *testing.T) {
+	peers, err := netutil.ParseWireGuardPeers("xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=@192.0.2.1:51820;10.89.1.0/24;fd00:1::/64,TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0=")
+	require.NoError(t, err)
+	subnet, err := types.ParseCIDR("10.89.0.0/24")
+	require.NoError(t, err)
+
+	config := wireGuardConfig("yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=", "51820", peers, []types.Subnet{{Subnet: subnet}})
+	assert.Equal(t, `[Interface]
+PrivateKey = yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=
+ListenPort = 51820
+
+[Peer]
+PublicKey = xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=
+AllowedIPs = 10.89.1.0/24, fd00:1::/64
+Endpoint = 192.0.2.1:51820
+
+[Peer]
+PublicKey = TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0=
+AllowedIPs = 10.89.0.0/24
+`, string(config))
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/containers/podman/v5/pkg/systemd/notifyproxy"
 	"github.com/containers/podman/v5/pkg/util"
 	"github.com/containers/podman/v5/utils"
+	"github.com/containers/storage/pkg/ioutils"
 	"github.com/coreos/go-systemd/v22/daemon"
 	"github.com/opencontainers/go-digest"
 	"github.com/opencontainers/selinux/go-selinux"
@@ -49,6 +50,10 @@ import (
 // container-specific sd-notify modes.
 const sdNotifyAnnotation = "io.containers.sdnotify"
 
+// configRestartAnnotation allows for restarting all or specific containers
+// of a pod when the ConfigMap or Secret volumes they mount are refreshed.
+const configRestartAnnotation = "io.podman.annotations.kube.config-restart"
+
 // default network created/used by kube
 const kubeDefaultNetwork = "podman-default-kube-network"
 
@@ -182,6 +187,9 @@ func k8sName(content []byte, suffix string) string {
 }
 
 func (ic *ContainerEngine) PlayKube(ctx context.Context, body io.Reader, options entities.PlayKubeOptions) (_ *entities.PlayKubeReport, finalErr error) {
+	if options.RefreshConfigs {
+		return ic.playKubeRefreshConfigs(ctx, body, options)
+	}
 	if options.ServiceContainer && options.Start == types.OptionalBoolFalse { // Sanity check to be future proof
 		return nil, fmt.Errorf("running a service container requires starting the pod(s)")
 	}
@@ -682,23 +690,9 @@ func (ic *ContainerEngine) playKubePod(ctx context.Context, podName string, podY
 			if err != nil || mountPoint == "" {
 				return nil, nil, fmt.Errorf("unable to get mountpoint of volume %q: %w", vol.Name(), err)
 			}
-			defaultMode := v.DefaultMode
 			// Create files and add data to the volume mountpoint based on the Items in the volume
-			for k, v := range v.Items {
-				dataPath := filepath.Join(mountPoint, k)
-				f, err := os.Create(dataPath)
-				if err != nil {
-					return nil, nil, fmt.Errorf("cannot create file %q at volume mountpoint %q: %w", k, mountPoint, err)
-				}
-				defer f.Close()
-				_, err = f.Write(v)
-				if err != nil {
-					return nil, nil, err
-				}
-				// Set file permissions
-				if err := os.Chmod(f.Name(), os.FileMode(defaultMode)); err != nil {
-					return nil, nil, err
-				}
+			if _, err := writeKubeVolumeItems(mountPoint, v.Items, v.DefaultMode); err != nil {
+				return nil, nil, err
 			}
 		}
 	}
@@ -1125,7 +1119,7 @@ func (ic *ContainerEngine) getImageAndLabelInfo(ctx context.Context, cwd string,
 		pullOptions.Password = options.Password
 		pullOptions.InsecureSkipTLSVerify = options.SkipTLSVerify
 
-		pulledImages, err := ic.Libpod.LibimageRuntime().Pull(ctx, container.Image, pullPolicy, pullOptions)
+		pulledImages, err := ic.Libpod.LibimageRuntime().Pull(ic.Libpod.WithMirrorHealth(ctx), container.Image, pullPolicy, pullOptions)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -1695,6 +1689,33 @@ func (ic *ContainerEngine) playKubeSecret(secret *v1.Secret) (*entities.SecretCr
 	return r, nil
 }
 
+// writeKubeVolumeItems writes the items of a ConfigMap or Secret volume to
+// files at the mount point of the volume and returns whether the content of
+// any file changed.  Files are replaced atomically, so containers never see
+// partially written files.
+func writeKubeVolumeItems(mountPoint string, items map[string][]byte, mode int32) (bool, error) {
+	changed := false
+	for name, data := range items {
+		dataPath := filepath.Join(mountPoint, name)
+		current, err := os.ReadFile(dataPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return false, err
+		}
+		if err == nil && bytes.Equal(current, data) {
+			// Set file permissions
+			if err := os.Chmod(dataPath, os.FileMode(mode)); err != nil {
+				return false, err
+			}
+			continue
+		}
+		if err := ioutils.AtomicWriteFile(dataPath, data, os.FileMode(mode)); err != nil {
+			return false, fmt.Errorf("cannot write file %q at volume mountpoint %q: %w", name, mountPoint, err)
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
 func getMountLabel(securityContext *v1.PodSecurityContext) (string, error) {
 	var mountLabel string
 	if securityContext == nil {
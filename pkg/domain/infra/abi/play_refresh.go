@@ -0,0 +1,234 @@
+package abi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/containers/common/pkg/secrets"
+	"github.com/containers/podman/v5/libpod/define"
+	"github.com/containers/podman/v5/pkg/domain/entities"
+	entitiesTypes "github.com/containers/podman/v5/pkg/domain/entities/types"
+	v1apps "github.com/containers/podman/v5/pkg/k8s.io/api/apps/v1"
+	v1 "github.com/containers/podman/v5/pkg/k8s.io/api/core/v1"
+	"github.com/containers/podman/v5/pkg/specgen/generate/kube"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// playKubeRefreshConfigs updates the Secrets of the YAML and the files of the
+// ConfigMap and Secret volumes of the already played pods, and restarts the
+// containers that are annotated to be restarted on changes of their volumes.
+// Volumes and pods that do not exist are skipped.
+func (ic *ContainerEngine) playKubeRefreshConfigs(ctx context.Context, body io.Reader, options entities.PlayKubeOptions) (*entities.PlayKubeReport, error) {
+	report := &entities.PlayKubeReport{}
+
+	// read yaml document
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// split yaml document
+	documentList, err := splitMultiDocYAML(content)
+	if err != nil {
+		return nil, err
+	}
+
+	// sort kube kinds, ConfigMaps and Secrets are sorted before pods
+	documentList, err = sortKubeKinds(documentList)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sort kube kinds: %w", err)
+	}
+
+	secretsManager, err := ic.Libpod.SecretsManager()
+	if err != nil {
+		return nil, err
+	}
+
+	var configMaps []v1.ConfigMap
+	for _, p := range options.ConfigMaps {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		cms, err := readConfigMapFromFile(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", p, err)
+		}
+		configMaps = append(configMaps, cms...)
+	}
+
+	for _, document := range documentList {
+		kind, err := getKubeKind(document)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read kube YAML: %w", err)
+		}
+
+		var (
+			podName     string
+			podSpec     v1.PodSpec
+			annotations map[string]string
+		)
+		switch kind {
+		case "Pod":
+			var podYAML v1.Pod
+			if err := yaml.Unmarshal(document, &podYAML); err != nil {
+				return nil, fmt.Errorf("unable to read YAML as Kube Pod: %w", err)
+			}
+			podName = podYAML.ObjectMeta.Name
+			podSpec = podYAML.Spec
+			annotations = podYAML.Annotations
+			for name, val := range options.Annotations {
+				if annotations == nil {
+					annotations = make(map[string]string)
+				}
+				annotations[name] = val
+			}
+		case "DaemonSet":
+			var daemonSetYAML v1apps.DaemonSet
+			if err := yaml.Unmarshal(document, &daemonSetYAML); err != nil {
+				return nil, fmt.Errorf("unable to read YAML as Kube DaemonSet: %w", err)
+			}
+			podName = fmt.Sprintf("%s-pod", daemonSetYAML.ObjectMeta.Name)
+			podSpec = daemonSetYAML.Spec.Template.Spec
+			annotations = daemonSetYAML.Annotations
+		case "Deployment":
+			var deploymentYAML v1apps.Deployment
+			if err := yaml.Unmarshal(document, &deploymentYAML); err != nil {
+				return nil, fmt.Errorf("unable to read YAML as Kube Deployment: %w", err)
+			}
+			podName = fmt.Sprintf("%s-pod", deploymentYAML.ObjectMeta.Name)
+			podSpec = deploymentYAML.Spec.Template.Spec
+			annotations = deploymentYAML.Annotations
+		case "ConfigMap":
+			var configMap v1.ConfigMap
+			if err := yaml.Unmarshal(document, &configMap); err != nil {
+				return nil, fmt.Errorf("unable to read YAML as Kube ConfigMap: %w", err)
+			}
+			configMaps = append(configMaps, configMap)
+			continue
+		case "Secret":
+			var secret v1.Secret
+			if err := yaml.Unmarshal(document, &secret); err != nil {
+				return nil, fmt.Errorf("unable to read YAML as kube secret: %w", err)
+			}
+			r, err := ic.playKubeSecret(&secret)
+			if err != nil {
+				return nil, err
+			}
+			report.Secrets = append(report.Secrets, entities.PlaySecret{CreateReport: r})
+			continue
+		default:
+			continue
+		}
+
+		podReport, volumes, err := ic.refreshKubePodConfigs(ctx, podName, &podSpec, annotations, configMaps, secretsManager)
+		if err != nil {
+			return nil, fmt.Errorf("refreshing configs of pod %s: %w", podName, err)
+		}
+		report.Volumes = append(report.Volumes, volumes...)
+		if podReport != nil {
+			report.Pods = append(report.Pods, *podReport)
+		}
+	}
+
+	return report, nil
+}
+
+// refreshKubePodConfigs rewrites the files of the ConfigMap and Secret
+// volumes of the pod and restarts the running containers of the pod that
+// mount a changed volume and are annotated with configRestartAnnotation.  It
+// returns the restarted containers and the changed volumes.
+func (ic *ContainerEngine) refreshKubePodConfigs(ctx context.Context, podName string, podSpec *v1.PodSpec, annotations map[string]string, configMaps []v1.ConfigMap, secretsManager *secrets.SecretsManager) (*entities.PlayKubePod, []entitiesTypes.PlayKubeVolume, error) {
+	mountLabel, err := getMountLabel(podSpec.SecurityContext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	volumes, err := kube.InitializeVolumes(podSpec.Volumes, configMaps, secretsManager, mountLabel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changedVolumes := make(map[string]bool)
+	var volumeReports []entitiesTypes.PlayKubeVolume
+	for _, v := range volumes {
+		if (v.Type != kube.KubeVolumeTypeConfigMap && v.Type != kube.KubeVolumeTypeSecret) || v.Optional {
+			continue
+		}
+		vol, err := ic.Libpod.GetVolume(v.Source)
+		if err != nil {
+			if errors.Is(err, define.ErrNoSuchVolume) {
+				logrus.Debugf("Skipping refresh of volume %s which does not exist", v.Source)
+				continue
+			}
+			return nil, nil, err
+		}
+		if changedVolumes[v.Source] {
+			continue
+		}
+		mountPoint, err := vol.MountPoint()
+		if err != nil || mountPoint == "" {
+			return nil, nil, fmt.Errorf("unable to get mountpoint of volume %q: %w", vol.Name(), err)
+		}
+		changed, err := writeKubeVolumeItems(mountPoint, v.Items, v.DefaultMode)
+		if err != nil {
+			return nil, nil, err
+		}
+		if changed {
+			changedVolumes[v.Source] = true
+			volumeReports = append(volumeReports, entitiesTypes.PlayKubeVolume{Name: v.Source})
+		}
+	}
+	if len(changedVolumes) == 0 {
+		return nil, nil, nil
+	}
+
+	pod, err := ic.Libpod.LookupPod(podName)
+	if err != nil {
+		if errors.Is(err, define.ErrNoSuchPod) {
+			return nil, volumeReports, nil
+		}
+		return nil, nil, err
+	}
+	ctrs, err := pod.AllContainers()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	podReport := &entities.PlayKubePod{ID: pod.ID()}
+	for _, ctr := range ctrs {
+		if ctr.IsInfra() {
+			continue
+		}
+		name := strings.TrimPrefix(ctr.Name(), podName+"-")
+		if annotations[configRestartAnnotation] != "true" && annotations[configRestartAnnotation+"/"+name] != "true" {
+			continue
+		}
+		mountsChanged := false
+		for _, namedVolume := range ctr.NamedVolumes() {
+			mountsChanged = mountsChanged || changedVolumes[namedVolume.Name]
+		}
+		if !mountsChanged {
+			continue
+		}
+		state, err := ctr.State()
+		if err != nil {
+			return nil, nil, err
+		}
+		if state != define.ContainerStateRunning {
+			continue
+		}
+		if err := ctr.RestartWithTimeout(ctx, ctr.StopTimeout()); err != nil {
+			podReport.ContainerErrors = append(podReport.ContainerErrors, fmt.Sprintf("restarting container %s: %v", ctr.ID(), err))
+			continue
+		}
+		podReport.Containers = append(podReport.Containers, ctr.ID())
+	}
+	return podReport, volumeReports, nil
+}
@@ -2,6 +2,8 @@ package abi
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
 
 	v1 "github.com/containers/podman/v5/pkg/k8s.io/api/core/v1"
@@ -279,3 +281,30 @@ items:
 		})
 	}
 }
+
+func TestWriteKubeVolumeItems(t *testing.T) {
+	mountPoint := t.TempDir()
+	items := map[string][]byte{
+		"foo": []byte("bar"),
+		"baz": []byte("qux"),
+	}
+
+	changed, err := writeKubeVolumeItems(mountPoint, items, 0o644)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	changed, err = writeKubeVolumeItems(mountPoint, items, 0o600)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	info, err := os.Stat(filepath.Join(mountPoint, "foo"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	items["foo"] = []byte("updated")
+	changed, err = writeKubeVolumeItems(mountPoint, items, 0o600)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	data, err := os.ReadFile(filepath.Join(mountPoint, "foo"))
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", string(data))
+}
@@ -187,6 +187,17 @@ func (ic *ContainerEngine) PodUnpause(ctx context.Context, namesOrIds []string,
 	return reports, nil
 }
 
+func (ic *ContainerEngine) PodUpdate(ctx context.Context, options entities.PodUpdateOptions) (string, error) {
+	pod, err := ic.Libpod.LookupPod(options.NameOrID)
+	if err != nil {
+		return "", err
+	}
+	if err := pod.Update(options.Resources); err != nil {
+		return "", err
+	}
+	return pod.ID(), nil
+}
+
 func (ic *ContainerEngine) PodStop(ctx context.Context, namesOrIds []string, options entities.PodStopOptions) ([]*entities.PodStopReport, error) {
 	reports := []*entities.PodStopReport{}
 	pods, err := getPodsByContext(options.All, options.Latest, namesOrIds, ic.Libpod)
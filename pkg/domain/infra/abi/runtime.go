@@ -30,6 +30,9 @@ type FarmNode struct {
 	variant           string
 	nativePlatforms   []string
 	emulatedPlatforms []string
+	cpus              int
+	memFree           int64
+	memTotal          int64
 }
 
 var shutdownSync sync.Once
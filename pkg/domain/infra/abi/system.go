@@ -215,12 +215,19 @@ func (ic *ContainerEngine) SystemDf(ctx context.Context, options entities.System
 				return nil, fmt.Errorf("failed to get read/write size of container %s: %w", c.ID(), err)
 			}
 		}
+		// a limit that cannot be read is not worth failing the report
+		sizeLimit, err := c.RWSizeLimit()
+		if err != nil {
+			logrus.Warnf("Failed to get size limit of container %s: %v", c.ID(), err)
+			sizeLimit = 0
+		}
 		report := entities.SystemDfContainerReport{
 			ContainerID:  c.ID(),
 			Image:        iid,
 			Command:      c.Command(),
 			LocalVolumes: len(c.UserVolumes()),
 			RWSize:       rwsize,
+			SizeLimit:    sizeLimit,
 			Size:         conSize,
 			Created:      c.CreatedTime(),
 			Status:       state.String(),
@@ -263,6 +270,7 @@ func (ic *ContainerEngine) SystemDf(ctx context.Context, options entities.System
 			VolumeName:      v.Name(),
 			Links:           len(inUse),
 			Size:            volSize,
+			SizeLimit:       int64(v.SizeLimit()),
 			ReclaimableSize: reclaimableSize,
 		}
 		dfVolumes = append(dfVolumes, &report)
@@ -53,3 +53,15 @@ func (ir *ImageEngine) SetTrust(ctx context.Context, args []string, options enti
 		PubKeyFiles: options.PubKeysFile,
 	})
 }
+
+func (ir *ImageEngine) VerifyTrust(ctx context.Context, nameOrID string, options entities.VerifyTrustOptions) (*entities.VerifyTrustReport, error) {
+	img, _, err := ir.Libpod.LibimageRuntime().LookupImage(nameOrID, nil)
+	if err != nil {
+		return nil, err
+	}
+	status, err := ir.Libpod.VerifyImageTrust(ctx, img, options.Reference)
+	if err != nil {
+		return nil, err
+	}
+	return &entities.VerifyTrustReport{ID: img.ID(), TrustStatus: status}, nil
+}
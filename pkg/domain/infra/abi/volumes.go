@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/containers/podman/v5/libpod"
 	"github.com/containers/podman/v5/libpod/define"
@@ -234,3 +235,42 @@ func (ic *ContainerEngine) VolumeReload(ctx context.Context) (*entities.VolumeRe
 	report := ic.Libpod.UpdateVolumePlugins(ctx)
 	return &entities.VolumeReloadReport{VolumeReload: *report}, nil
 }
+
+func (ic *ContainerEngine) VolumeSnapshotCreate(ctx context.Context, nameOrID, snapshot string) (*entities.VolumeSnapshotReport, error) {
+	vol, err := ic.Libpod.LookupVolume(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == "" {
+		snapshot = time.Now().Format("20060102150405")
+	}
+	created, err := vol.CreateSnapshot(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return &entities.VolumeSnapshotReport{VolumeSnapshot: *created}, nil
+}
+
+func (ic *ContainerEngine) VolumeSnapshotList(ctx context.Context, nameOrID string) ([]*entities.VolumeSnapshotReport, error) {
+	vol, err := ic.Libpod.LookupVolume(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := vol.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]*entities.VolumeSnapshotReport, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		reports = append(reports, &entities.VolumeSnapshotReport{VolumeSnapshot: *snapshot})
+	}
+	return reports, nil
+}
+
+func (ic *ContainerEngine) VolumeSnapshotRestore(ctx context.Context, nameOrID, snapshot string) error {
+	vol, err := ic.Libpod.LookupVolume(nameOrID)
+	if err != nil {
+		return err
+	}
+	return vol.RestoreSnapshot(snapshot)
+}
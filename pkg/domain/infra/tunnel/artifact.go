@@ -0,0 +1,34 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+
+	"github.com/containers/podman/v5/pkg/domain/entities"
+)
+
+var errArtifactsNotSupported = errors.New("artifacts are not supported by the remote client")
+
+func (ir *ImageEngine) ArtifactAdd(_ context.Context, _ string, _ []string, _ entities.ArtifactAddOptions) (*entities.ArtifactAddReport, error) {
+	return nil, errArtifactsNotSupported
+}
+
+func (ir *ImageEngine) ArtifactInspect(_ context.Context, _ string, _ entities.ArtifactInspectOptions) (*entities.ArtifactInspectReport, error) {
+	return nil, errArtifactsNotSupported
+}
+
+func (ir *ImageEngine) ArtifactList(_ context.Context, _ entities.ArtifactListOptions) ([]*entities.ArtifactListReport, error) {
+	return nil, errArtifactsNotSupported
+}
+
+func (ir *ImageEngine) ArtifactPull(_ context.Context, _ string, _ entities.ArtifactPullOptions) (*entities.ArtifactPullReport, error) {
+	return nil, errArtifactsNotSupported
+}
+
+func (ir *ImageEngine) ArtifactPush(_ context.Context, _ string, _ entities.ArtifactPushOptions) (*entities.ArtifactPushReport, error) {
+	return nil, errArtifactsNotSupported
+}
+
+func (ir *ImageEngine) ArtifactRm(_ context.Context, _ string, _ entities.ArtifactRemoveOptions) (*entities.ArtifactRemoveReport, error) {
+	return nil, errArtifactsNotSupported
+}
@@ -1095,6 +1095,12 @@ func (ic *ContainerEngine) ShouldRestart(_ context.Context, id string) (bool, er
 	return containers.ShouldRestart(ic.ClientCtx, id, nil)
 }
 
+// ContainerPortUpdate publishes additional ports of a container or stops
+// publishing ports.
+func (ic *ContainerEngine) ContainerPortUpdate(ctx context.Context, nameOrID string, options entities.ContainerPortUpdateOptions) (*entities.ContainerPortReport, error) {
+	return containers.UpdatePorts(ic.ClientCtx, nameOrID, new(containers.UpdatePortsOptions).WithAdd(options.Add).WithRemove(options.Remove))
+}
+
 // ContainerRename renames the given container.
 func (ic *ContainerEngine) ContainerRename(ctx context.Context, nameOrID string, opts entities.ContainerRenameOptions) error {
 	return containers.Rename(ic.ClientCtx, nameOrID, new(containers.RenameOptions).WithName(opts.NewName))
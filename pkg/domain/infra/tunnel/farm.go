@@ -22,25 +22,31 @@ func (ir *ImageEngine) FarmNodeDriver(ctx context.Context) string {
 	return remoteFarmImageBuilderDriver
 }
 
-func (ir *ImageEngine) fetchInfo(_ context.Context) (os, arch, variant string, nativePlatforms []string, err error) {
+func (ir *ImageEngine) fetchInfo(_ context.Context) error {
 	engineInfo, err := system.Info(ir.ClientCtx, &system.InfoOptions{})
 	if err != nil {
-		return "", "", "", nil, fmt.Errorf("retrieving host info from %q: %w", ir.NodeName, err)
+		return fmt.Errorf("retrieving host info from %q: %w", ir.NodeName, err)
 	}
 	nativePlatform := engineInfo.Host.OS + "/" + engineInfo.Host.Arch
 	if engineInfo.Host.Variant != "" {
 		nativePlatform = nativePlatform + "/" + engineInfo.Host.Variant
 	}
-	return engineInfo.Host.OS, engineInfo.Host.Arch, engineInfo.Host.Variant, []string{nativePlatform}, nil
+	ir.os, ir.arch, ir.variant = engineInfo.Host.OS, engineInfo.Host.Arch, engineInfo.Host.Variant
+	ir.nativePlatforms = []string{nativePlatform}
+	ir.cpus, ir.memFree, ir.memTotal = engineInfo.Host.CPUs, engineInfo.Host.MemFree, engineInfo.Host.MemTotal
+	return nil
 }
 
 // FarmNodeInspect returns information about the remote engines in the farm
 func (ir *ImageEngine) FarmNodeInspect(ctx context.Context) (*entities.FarmInspectReport, error) {
 	ir.platforms.Do(func() {
-		ir.os, ir.arch, ir.variant, ir.nativePlatforms, ir.platformsErr = ir.fetchInfo(ctx)
+		ir.platformsErr = ir.fetchInfo(ctx)
 	})
 	return &entities.FarmInspectReport{NativePlatforms: ir.nativePlatforms,
-		OS:      ir.os,
-		Arch:    ir.arch,
-		Variant: ir.variant}, ir.platformsErr
+		OS:       ir.os,
+		Arch:     ir.arch,
+		Variant:  ir.variant,
+		CPUs:     ir.cpus,
+		MemFree:  ir.memFree,
+		MemTotal: ir.memTotal}, ir.platformsErr
 }
@@ -114,7 +114,11 @@ func (ir *ImageEngine) Pull(ctx context.Context, rawImage string, opts entities.
 	options := new(images.PullOptions)
 	options.WithAllTags(opts.AllTags).WithAuthfile(opts.Authfile).WithArch(opts.Arch).WithOS(opts.OS)
 	options.WithVariant(opts.Variant).WithPassword(opts.Password)
-	options.WithQuiet(opts.Quiet).WithUsername(opts.Username).WithPolicy(opts.PullPolicy.String())
+	policy := opts.PullPolicy.String()
+	if opts.PullMaxAge > 0 {
+		policy = "max-age=" + opts.PullMaxAge.String()
+	}
+	options.WithQuiet(opts.Quiet).WithUsername(opts.Username).WithPolicy(policy)
 	options.WithProgressWriter(opts.Writer)
 	if s := opts.SkipTLSVerify; s != types.OptionalBoolUndefined {
 		if s == types.OptionalBoolTrue {
@@ -397,6 +401,10 @@ func (ir *ImageEngine) Tree(ctx context.Context, nameOrID string, opts entities.
 	return images.Tree(ir.ClientCtx, nameOrID, options)
 }
 
+func (ir *ImageEngine) Scan(_ context.Context, _ []string, _ entities.ImageScanOptions) ([]*entities.ImageScanReport, error) {
+	return nil, errors.New("scanning images is not supported by the remote client")
+}
+
 // Shutdown Libpod engine
 func (ir *ImageEngine) Shutdown(_ context.Context) {
 }
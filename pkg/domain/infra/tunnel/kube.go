@@ -38,6 +38,18 @@ func (ic *ContainerEngine) GenerateSystemd(ctx context.Context, nameOrID string,
 	if opts.RestartSec != nil {
 		options.WithRestartSec(*opts.RestartSec)
 	}
+	if opts.RestartSteps != nil {
+		options.WithRestartSteps(*opts.RestartSteps)
+	}
+	if opts.RestartMaxDelaySec != nil {
+		options.WithRestartMaxDelaySec(*opts.RestartMaxDelaySec)
+	}
+	if opts.ExitCodePropagation != nil {
+		options.WithExitCodePropagation(*opts.ExitCodePropagation)
+	}
+	if opts.SdNotify != nil {
+		options.WithSdNotify(*opts.SdNotify)
+	}
 
 	return generate.Systemd(ic.ClientCtx, nameOrID, options)
 }
@@ -46,7 +58,7 @@ func (ic *ContainerEngine) GenerateSystemd(ctx context.Context, nameOrID string,
 //
 // Note: Caller is responsible for closing returned Reader
 func (ic *ContainerEngine) GenerateKube(ctx context.Context, nameOrIDs []string, opts entities.GenerateKubeOptions) (*entities.GenerateKubeReport, error) {
-	options := new(generate.KubeOptions).WithService(opts.Service).WithType(opts.Type).WithReplicas(opts.Replicas).WithNoTrunc(opts.UseLongAnnotations).WithPodmanOnly(opts.PodmanOnly)
+	options := new(generate.KubeOptions).WithService(opts.Service).WithType(opts.Type).WithReplicas(opts.Replicas).WithNoTrunc(opts.UseLongAnnotations).WithPodmanOnly(opts.PodmanOnly).WithVolumes(opts.Volumes)
 	return generate.Kube(ic.ClientCtx, nameOrIDs, options)
 }
 
@@ -58,7 +70,7 @@ func (ic *ContainerEngine) PlayKube(ctx context.Context, body io.Reader, opts en
 	options := new(kube.PlayOptions).WithAuthfile(opts.Authfile).WithUsername(opts.Username).WithPassword(opts.Password)
 	options.WithCertDir(opts.CertDir).WithQuiet(opts.Quiet).WithSignaturePolicy(opts.SignaturePolicy).WithConfigMaps(opts.ConfigMaps)
 	options.WithLogDriver(opts.LogDriver).WithNetwork(opts.Networks).WithSeccompProfileRoot(opts.SeccompProfileRoot)
-	options.WithStaticIPs(opts.StaticIPs).WithStaticMACs(opts.StaticMACs).WithWait(opts.Wait).WithServiceContainer(opts.ServiceContainer).WithReplace(opts.Replace)
+	options.WithStaticIPs(opts.StaticIPs).WithStaticMACs(opts.StaticMACs).WithWait(opts.Wait).WithServiceContainer(opts.ServiceContainer).WithReplace(opts.Replace).WithRefreshConfigs(opts.RefreshConfigs)
 	if len(opts.LogOptions) > 0 {
 		options.WithLogOptions(opts.LogOptions)
 	}
@@ -74,7 +74,7 @@ func (ic *ContainerEngine) NetworkRm(ctx context.Context, namesOrIds []string, o
 func (ic *ContainerEngine) NetworkCreate(ctx context.Context, net types.Network, createOptions *types.NetworkCreateOptions) (*types.Network, error) {
 	options := new(network.ExtraCreateOptions)
 	if createOptions != nil {
-		options = options.WithIgnoreIfExists(createOptions.IgnoreIfExists)
+		options = options.WithIgnoreIfExists(createOptions.IgnoreIfExists).WithDisableIPv4(createOptions.DisableIPv4)
 	}
 	net, err := network.CreateWithOptions(ic.ClientCtx, &net, options)
 	if err != nil {
@@ -100,6 +100,10 @@ func (ic *ContainerEngine) PodUnpause(ctx context.Context, namesOrIds []string,
 	return reports, nil
 }
 
+func (ic *ContainerEngine) PodUpdate(ctx context.Context, options entities.PodUpdateOptions) (string, error) {
+	return pods.Update(ic.ClientCtx, options.NameOrID, options.Resources)
+}
+
 func (ic *ContainerEngine) PodStop(ctx context.Context, namesOrIds []string, opts entities.PodStopOptions) ([]*entities.PodStopReport, error) {
 	timeout := -1
 	foundPods, err := getPodsByContext(ic.ClientCtx, opts.All, namesOrIds)
@@ -35,6 +35,9 @@ type FarmNode struct {
 	arch            string
 	variant         string
 	nativePlatforms []string
+	cpus            int
+	memFree         int64
+	memTotal        int64
 }
 
 func remoteProxySignals(ctrID string, killFunc func(string) error) {
@@ -14,3 +14,7 @@ func (ir *ImageEngine) ShowTrust(ctx context.Context, args []string, options ent
 func (ir *ImageEngine) SetTrust(ctx context.Context, args []string, options entities.SetTrustOptions) error {
 	return errors.New("not implemented")
 }
+
+func (ir *ImageEngine) VerifyTrust(ctx context.Context, nameOrID string, options entities.VerifyTrustOptions) (*entities.VerifyTrustReport, error) {
+	return nil, errors.New("not implemented")
+}
@@ -113,3 +113,19 @@ func (ic *ContainerEngine) VolumeUnmount(ctx context.Context, nameOrIDs []string
 func (ic *ContainerEngine) VolumeReload(ctx context.Context) (*entities.VolumeReloadReport, error) {
 	return nil, errors.New("volume reload is not supported for remote clients")
 }
+
+func (ic *ContainerEngine) VolumeSnapshotCreate(ctx context.Context, nameOrID, snapshot string) (*entities.VolumeSnapshotReport, error) {
+	options := new(volumes.SnapshotCreateOptions)
+	if snapshot != "" {
+		options = options.WithName(snapshot)
+	}
+	return volumes.SnapshotCreate(ic.ClientCtx, nameOrID, options)
+}
+
+func (ic *ContainerEngine) VolumeSnapshotList(ctx context.Context, nameOrID string) ([]*entities.VolumeSnapshotReport, error) {
+	return volumes.SnapshotList(ic.ClientCtx, nameOrID, nil)
+}
+
+func (ic *ContainerEngine) VolumeSnapshotRestore(ctx context.Context, nameOrID, snapshot string) error {
+	return volumes.SnapshotRestore(ic.ClientCtx, nameOrID, snapshot, nil)
+}
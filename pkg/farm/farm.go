@@ -3,6 +3,7 @@ package farm
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -17,7 +18,9 @@ import (
 	"github.com/containers/podman/v5/pkg/domain/entities"
 	"github.com/containers/podman/v5/pkg/domain/infra"
 	"github.com/hashicorp/go-multierror"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
 )
 
 // Farm represents a group of connections to builders.
@@ -29,7 +32,9 @@ type Farm struct {
 
 // Schedule is a description of where and how we'll do builds.
 type Schedule struct {
-	platformBuilders map[string]string // target->connection
+	platformBuilders map[string]string   // target->connection
+	fallbackBuilders map[string][]string // target->other capable connections, in order of preference
+	keepExisting     bool                // keep the images already in the manifest list
 }
 
 func newFarmWithBuilders(_ context.Context, name string, cons []config.Connection, localEngine entities.ImageEngine, buildLocal bool) (*Farm, error) {
@@ -61,7 +66,7 @@ func newFarmWithBuilders(_ context.Context, name string, cons []config.Connectio
 			defer fmt.Printf("Builder %q ready\n", con.Name)
 			builderMutex.Lock()
 			defer builderMutex.Unlock()
-			farm.builders[name] = engine
+			farm.builders[con.Name] = engine
 			return nil
 		})
 	}
@@ -234,11 +239,13 @@ func (f *Farm) EmulatedPlatforms(ctx context.Context) ([]string, error) {
 // over emulated builders, but will assign a builder which can use emulation
 // for a platform if no suitable native builder is available.
 //
+// Builds are spread over the capable nodes according to their weight, which
+// is derived from their number of CPUs and free memory, such that the node
+// with the most spare capacity gets the next build.  The other capable nodes
+// are recorded as fallbacks in case a build fails.
+//
 // If platforms is an empty list, all available native platforms will be
 // scheduled.
-//
-// TODO: add (Priority,Weight *int) a la RFC 2782 to destinations that we know
-// of, and factor those in when assigning builds to nodes in here.
 func (f *Farm) Schedule(ctx context.Context, platforms []string) (Schedule, error) {
 	var (
 		err       error
@@ -253,12 +260,9 @@ func (f *Farm) Schedule(ctx context.Context, platforms []string) (Schedule, erro
 		}
 	}
 
-	platformBuilders := make(map[string]string)
-	native := make(map[string]string)
-	emulated := make(map[string]string)
-	var localPlatform string
-	// Make notes of which platforms we can build for natively, and which
-	// ones we can build for using emulation.
+	// Make notes of which platforms each node can build for natively, and
+	// which ones it can build for using emulation.
+	nodes := make(map[string]*entities.FarmInspectReport)
 	for name, engine := range f.builders {
 		name, engine := name, engine
 		infoGroup.Go(func() error {
@@ -268,19 +272,7 @@ func (f *Farm) Schedule(ctx context.Context, platforms []string) (Schedule, erro
 			}
 			infoMutex.Lock()
 			defer infoMutex.Unlock()
-			for _, n := range inspect.NativePlatforms {
-				if _, assigned := native[n]; !assigned {
-					native[n] = name
-				}
-				if name == entities.LocalFarmImageBuilderName {
-					localPlatform = n
-				}
-			}
-			for _, e := range inspect.EmulatedPlatforms {
-				if _, assigned := emulated[e]; !assigned {
-					emulated[e] = name
-				}
-			}
+			nodes[name] = inspect
 			return nil
 		})
 	}
@@ -290,31 +282,201 @@ func (f *Farm) Schedule(ctx context.Context, platforms []string) (Schedule, erro
 			return Schedule{}, err
 		}
 	}
-	// Assign a build to the first node that could build it natively, and
-	// if there isn't one, the first one that can build it with the help of
-	// emulation, and if there aren't any, error out.
-	for _, platform := range platforms {
-		if builder, ok := native[platform]; ok {
-			platformBuilders[platform] = builder
-		} else if builder, ok := emulated[platform]; ok {
-			platformBuilders[platform] = builder
-		} else {
-			return Schedule{}, fmt.Errorf("no builder capable of building for platform %q available", platform)
+	return scheduleBuilds(platforms, nodes)
+}
+
+// nodeWeight returns the relative build capacity of a farm node.  It is the
+// node's number of CPUs scaled by the share of its memory that is free, so
+// a node with twice the CPUs gets roughly twice the builds unless it is
+// short on memory.
+func nodeWeight(node *entities.FarmInspectReport) float64 {
+	cpus := 1.0
+	if node.CPUs > 1 {
+		cpus = float64(node.CPUs)
+	}
+	if node.MemTotal <= 0 {
+		return cpus
+	}
+	return cpus * (0.5 + 0.5*float64(node.MemFree)/float64(node.MemTotal))
+}
+
+// scheduleBuilds assigns each of the platforms to one of the nodes, see
+// Schedule for details.
+func scheduleBuilds(platforms []string, nodes map[string]*entities.FarmInspectReport) (Schedule, error) {
+	native := make(map[string][]string)
+	emulated := make(map[string][]string)
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, n := range nodes[name].NativePlatforms {
+			native[n] = append(native[n], name)
+		}
+		for _, e := range nodes[name].EmulatedPlatforms {
+			emulated[e] = append(emulated[e], name)
 		}
 	}
-	// If local is set, prioritize building on local
-	if localPlatform != "" {
-		platformBuilders[localPlatform] = entities.LocalFarmImageBuilderName
+	byWeight := func(candidates []string) []string {
+		sorted := append([]string{}, candidates...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return nodeWeight(nodes[sorted[i]]) > nodeWeight(nodes[sorted[j]])
+		})
+		return sorted
 	}
+
 	schedule := Schedule{
-		platformBuilders: platformBuilders,
+		platformBuilders: make(map[string]string),
+		fallbackBuilders: make(map[string][]string),
+	}
+	assigned := make(map[string]int)
+	sortedPlatforms := append([]string{}, platforms...)
+	sort.Strings(sortedPlatforms)
+	for _, platform := range sortedPlatforms {
+		if _, ok := schedule.platformBuilders[platform]; ok {
+			continue
+		}
+		// Prefer the nodes that can build the platform natively, and
+		// if there isn't one, the ones that can build it with the help
+		// of emulation, and if there aren't any, error out.
+		candidates := byWeight(native[platform])
+		if len(candidates) == 0 {
+			candidates = byWeight(emulated[platform])
+		}
+		if len(candidates) == 0 {
+			return Schedule{}, fmt.Errorf("no builder capable of building for platform %q available", platform)
+		}
+
+		// If local is set and can build the platform natively,
+		// prioritize building on local.  Otherwise pick the node with
+		// the lowest load relative to its weight.
+		builder := ""
+		if slices.Contains(candidates, entities.LocalFarmImageBuilderName) && slices.Contains(native[platform], entities.LocalFarmImageBuilderName) {
+			builder = entities.LocalFarmImageBuilderName
+		} else {
+			bestLoad := 0.0
+			for _, candidate := range candidates {
+				load := float64(assigned[candidate]+1) / nodeWeight(nodes[candidate])
+				if builder == "" || load < bestLoad {
+					builder, bestLoad = candidate, load
+				}
+			}
+		}
+		assigned[builder]++
+		schedule.platformBuilders[platform] = builder
+
+		// Any other capable node, native ones first, may take over
+		// if the build fails.
+		for _, candidate := range append(byWeight(native[platform]), byWeight(emulated[platform])...) {
+			if candidate != builder && !slices.Contains(schedule.fallbackBuilders[platform], candidate) {
+				schedule.fallbackBuilders[platform] = append(schedule.fallbackBuilders[platform], candidate)
+			}
+		}
 	}
 	return schedule, nil
 }
 
+// SkipExistingPlatforms removes all platforms from the schedule for which the
+// local manifest list with the specified reference already has an image.  The
+// existing images are kept in the list when building it.  It returns the
+// pruned schedule and the skipped platforms.
+func (f *Farm) SkipExistingPlatforms(ctx context.Context, schedule Schedule, reference string) (Schedule, []string, error) {
+	exists, err := f.localEngine.ManifestExists(ctx, reference)
+	if err != nil {
+		return Schedule{}, nil, err
+	}
+	if !exists.Value {
+		return schedule, nil, nil
+	}
+	raw, err := f.localEngine.ManifestInspect(ctx, reference, entities.ManifestInspectOptions{})
+	if err != nil {
+		return Schedule{}, nil, fmt.Errorf("inspecting manifest list %q: %w", reference, err)
+	}
+	var index imgspecv1.Index
+	if err := json.Unmarshal(raw, &index); err != nil {
+		return Schedule{}, nil, fmt.Errorf("parsing manifest list %q: %w", reference, err)
+	}
+	existing := make(map[string]struct{})
+	for _, m := range index.Manifests {
+		if m.Platform == nil {
+			continue
+		}
+		existing[normalizePlatform(m.Platform.OS+"/"+m.Platform.Architecture+"/"+m.Platform.Variant)] = struct{}{}
+	}
+
+	pruned := Schedule{
+		platformBuilders: make(map[string]string),
+		fallbackBuilders: make(map[string][]string),
+		keepExisting:     true,
+	}
+	var skipped []string
+	for platform, builder := range schedule.platformBuilders {
+		if _, ok := existing[normalizePlatform(platform)]; ok {
+			skipped = append(skipped, platform)
+			continue
+		}
+		pruned.platformBuilders[platform] = builder
+		pruned.fallbackBuilders[platform] = schedule.fallbackBuilders[platform]
+	}
+	sort.Strings(skipped)
+	return pruned, skipped, nil
+}
+
+// Platforms returns the sorted list of platforms in the schedule.
+func (s Schedule) Platforms() []string {
+	platforms := make([]string, 0, len(s.platformBuilders))
+	for platform := range s.platformBuilders {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+	return platforms
+}
+
+// splitPlatform splits and normalizes the specified os/arch/variant platform.
+func splitPlatform(platform string) (os, arch, variant string) {
+	var rawOS, rawArch, rawVariant string
+	p := strings.Split(platform, "/")
+	if len(p) > 0 && p[0] != "" {
+		rawOS = p[0]
+	}
+	if len(p) > 1 {
+		rawArch = p[1]
+	}
+	if len(p) > 2 {
+		rawVariant = p[2]
+	}
+	return lplatform.Normalize(rawOS, rawArch, rawVariant)
+}
+
+// normalizePlatform returns the normalized os/arch[/variant] representation
+// of the specified platform.
+func normalizePlatform(platform string) string {
+	os, arch, variant := splitPlatform(platform)
+	if variant != "" {
+		return os + "/" + arch + "/" + variant
+	}
+	return os + "/" + arch
+}
+
+// prefixLines copies the lines read from reader to writer, prefixing each
+// with the specified prefix.
+func prefixLines(reader io.ReadCloser, writer io.Writer, prefix string) {
+	defer reader.Close()
+	buffered := bufio.NewReader(reader)
+	line, err := buffered.ReadString('\n')
+	for err == nil {
+		line = strings.TrimSuffix(line, "\n")
+		fmt.Fprintf(writer, "[%s] %s\n", prefix, line)
+		line, err = buffered.ReadString('\n')
+	}
+}
+
 // Build runs a build using the specified targetplatform:service map.  If all
 // builds succeed, it copies the resulting images from the remote hosts to the
 // local service and builds a manifest list with the specified reference name.
+// A build that fails is retried on the next capable node of the schedule
+// before the entire build fails.
 func (f *Farm) Build(ctx context.Context, schedule Schedule, options entities.BuildOptions, reference string, localEngine entities.ImageEngine) error {
 	switch options.OutputFormat {
 	default:
@@ -324,39 +486,13 @@ func (f *Farm) Build(ctx context.Context, schedule Schedule, options entities.Bu
 	case define.Dockerv2ImageManifest:
 	}
 
-	// Build the list of jobs.
-	var jobs sync.Map
-	type job struct {
-		platform string
-		os       string
-		arch     string
-		variant  string
-		builder  entities.ImageEngine
-	}
-	for platform, builderName := range schedule.platformBuilders { // prepare to build
-		builder, ok := f.builders[builderName]
-		if !ok {
-			return fmt.Errorf("unknown builder %q", builderName)
-		}
-		var rawOS, rawArch, rawVariant string
-		p := strings.Split(platform, "/")
-		if len(p) > 0 && p[0] != "" {
-			rawOS = p[0]
-		}
-		if len(p) > 1 {
-			rawArch = p[1]
-		}
-		if len(p) > 2 {
-			rawVariant = p[2]
+	// Make sure all scheduled builders are known before starting to build.
+	for platform, builderName := range schedule.platformBuilders {
+		for _, name := range append([]string{builderName}, schedule.fallbackBuilders[platform]...) {
+			if _, ok := f.builders[name]; !ok {
+				return fmt.Errorf("unknown builder %q", name)
+			}
 		}
-		os, arch, variant := lplatform.Normalize(rawOS, rawArch, rawVariant)
-		jobs.Store(builderName, job{
-			platform: platform,
-			os:       os,
-			arch:     arch,
-			variant:  variant,
-			builder:  builder,
-		})
 	}
 
 	listBuilderOptions := listBuilderOptions{
@@ -364,9 +500,45 @@ func (f *Farm) Build(ctx context.Context, schedule Schedule, options entities.Bu
 		iidFile:       options.IIDFile,
 		authfile:      options.Authfile,
 		skipTLSVerify: options.SkipTLSVerify,
+		keepExisting:  schedule.keepExisting,
 	}
 	manifestListBuilder := newManifestListBuilder(reference, f.localEngine, listBuilderOptions)
 
+	outWriter := options.Out
+	if outWriter == nil {
+		outWriter = os.Stdout
+	}
+	errWriter := options.Err
+	if errWriter == nil {
+		errWriter = os.Stderr
+	}
+
+	// buildOn builds the platform on the specified node and streams the
+	// node's output with a [platform@node] prefix.
+	buildOn := func(platform, builderName string) (*entities.BuildReport, error) {
+		builder := f.builders[builderName]
+		prefix := platform + "@" + builderName
+		outReader, outPipe := io.Pipe()
+		errReader, errPipe := io.Pipe()
+		go prefixLines(outReader, outWriter, prefix)
+		go prefixLines(errReader, errWriter, prefix)
+		defer outPipe.Close()
+		defer errPipe.Close()
+
+		platformOS, arch, variant := splitPlatform(platform)
+		buildOptions := options
+		buildOptions.Platforms = []struct{ OS, Arch, Variant string }{{platformOS, arch, variant}}
+		buildOptions.Out = outPipe
+		buildOptions.Err = errPipe
+		fmt.Printf("Starting build for %v at %q\n", buildOptions.Platforms, builderName)
+		buildReport, err := builder.Build(ctx, options.ContainerFiles, buildOptions)
+		if err != nil {
+			return nil, fmt.Errorf("building for %q on %q: %w", platform, builderName, err)
+		}
+		fmt.Printf("finished build for %v at %q: built %s\n", buildOptions.Platforms, builderName, buildReport.ID)
+		return buildReport, nil
+	}
+
 	// Start builds in parallel and wait for them all to finish.
 	var (
 		buildResults sync.Map
@@ -378,62 +550,24 @@ func (f *Farm) Build(ctx context.Context, schedule Schedule, options entities.Bu
 	}
 	for platform, builder := range schedule.platformBuilders {
 		platform, builder := platform, builder
-		outReader, outWriter := io.Pipe()
-		errReader, errWriter := io.Pipe()
-		go func() {
-			defer outReader.Close()
-			reader := bufio.NewReader(outReader)
-			writer := options.Out
-			if writer == nil {
-				writer = os.Stdout
-			}
-			line, err := reader.ReadString('\n')
-			for err == nil {
-				line = strings.TrimSuffix(line, "\n")
-				fmt.Fprintf(writer, "[%s@%s] %s\n", platform, builder, line)
-				line, err = reader.ReadString('\n')
-			}
-		}()
-		go func() {
-			defer errReader.Close()
-			reader := bufio.NewReader(errReader)
-			writer := options.Err
-			if writer == nil {
-				writer = os.Stderr
-			}
-			line, err := reader.ReadString('\n')
-			for err == nil {
-				line = strings.TrimSuffix(line, "\n")
-				fmt.Fprintf(writer, "[%s@%s] %s\n", platform, builder, line)
-				line, err = reader.ReadString('\n')
-			}
-		}()
 		buildGroup.Go(func() error {
-			var j job
-			defer outWriter.Close()
-			defer errWriter.Close()
-			c, ok := jobs.Load(builder)
-			if !ok {
-				return fmt.Errorf("unknown connection for %q (shouldn't happen)", builder)
-			}
-			if j, ok = c.(job); !ok {
-				return fmt.Errorf("unexpected connection type for %q (shouldn't happen)", builder)
-			}
-			buildOptions := options
-			buildOptions.Platforms = []struct{ OS, Arch, Variant string }{{j.os, j.arch, j.variant}}
-			buildOptions.Out = outWriter
-			buildOptions.Err = errWriter
-			fmt.Printf("Starting build for %v at %q\n", buildOptions.Platforms, builder)
-			buildReport, err := j.builder.Build(ctx, options.ContainerFiles, buildOptions)
-			if err != nil {
-				return fmt.Errorf("building for %q on %q: %w", j.platform, builder, err)
+			var buildErr error
+			for _, name := range append([]string{builder}, schedule.fallbackBuilders[platform]...) {
+				if buildErr != nil {
+					fmt.Fprintf(os.Stderr, "%v: retrying on %q\n", buildErr, name)
+				}
+				report, err := buildOn(platform, name)
+				if err != nil {
+					buildErr = err
+					continue
+				}
+				buildResults.Store(platform, buildResult{
+					report:  *report,
+					builder: f.builders[name],
+				})
+				return nil
 			}
-			fmt.Printf("finished build for %v at %q: built %s\n", buildOptions.Platforms, builder, buildReport.ID)
-			buildResults.Store(platform, buildResult{
-				report:  *buildReport,
-				builder: j.builder,
-			})
-			return nil
+			return buildErr
 		})
 	}
 	buildErrors := buildGroup.Wait()
@@ -0,0 +1,85 @@
+package farm
+
+import (
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleBuilds(t *testing.T) {
+	nodes := map[string]*entities.FarmInspectReport{
+		"small": {
+			NativePlatforms:   []string{"linux/amd64"},
+			EmulatedPlatforms: []string{"linux/arm64"},
+			CPUs:              2,
+		},
+		"big": {
+			NativePlatforms: []string{"linux/amd64"},
+			CPUs:            8,
+			MemFree:         8 << 30,
+			MemTotal:        8 << 30,
+		},
+		"arm": {
+			NativePlatforms: []string{"linux/arm64"},
+			CPUs:            4,
+		},
+	}
+
+	schedule, err := scheduleBuilds([]string{"linux/amd64", "linux/arm64"}, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"linux/amd64": "big", "linux/arm64": "arm"}, schedule.platformBuilders)
+	assert.Equal(t, []string{"small"}, schedule.fallbackBuilders["linux/amd64"])
+	// Emulated nodes come last.
+	assert.Equal(t, []string{"small"}, schedule.fallbackBuilders["linux/arm64"])
+	assert.Equal(t, []string{"linux/amd64", "linux/arm64"}, schedule.Platforms())
+
+	// Only emulation is available for arm64.
+	schedule, err = scheduleBuilds([]string{"linux/arm64"}, map[string]*entities.FarmInspectReport{"small": nodes["small"]})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"linux/arm64": "small"}, schedule.platformBuilders)
+
+	_, err = scheduleBuilds([]string{"linux/s390x"}, nodes)
+	assert.ErrorContains(t, err, `no builder capable of building for platform "linux/s390x" available`)
+}
+
+func TestScheduleBuildsLocal(t *testing.T) {
+	nodes := map[string]*entities.FarmInspectReport{
+		entities.LocalFarmImageBuilderName: {
+			NativePlatforms: []string{"linux/amd64"},
+			CPUs:            1,
+		},
+		"big": {
+			NativePlatforms: []string{"linux/amd64", "linux/arm64"},
+			CPUs:            16,
+		},
+	}
+
+	// The local node is preferred for its native platform.
+	schedule, err := scheduleBuilds([]string{"linux/amd64", "linux/arm64"}, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, entities.LocalFarmImageBuilderName, schedule.platformBuilders["linux/amd64"])
+	assert.Equal(t, []string{"big"}, schedule.fallbackBuilders["linux/amd64"])
+	assert.Equal(t, "big", schedule.platformBuilders["linux/arm64"])
+
+	// The local platform is not built unless requested.
+	schedule, err = scheduleBuilds([]string{"linux/arm64"}, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"linux/arm64": "big"}, schedule.platformBuilders)
+}
+
+func TestNodeWeight(t *testing.T) {
+	assert.Equal(t, 1.0, nodeWeight(&entities.FarmInspectReport{}))
+	assert.Equal(t, 4.0, nodeWeight(&entities.FarmInspectReport{CPUs: 4}))
+	assert.Equal(t, 4.0, nodeWeight(&entities.FarmInspectReport{CPUs: 4, MemFree: 2, MemTotal: 2}))
+	assert.Equal(t, 2.0, nodeWeight(&entities.FarmInspectReport{CPUs: 4, MemFree: 0, MemTotal: 2}))
+	assert.Equal(t, 3.0, nodeWeight(&entities.FarmInspectReport{CPUs: 4, MemFree: 1, MemTotal: 2}))
+}
+
+func TestNormalizePlatform(t *testing.T) {
+	assert.Equal(t, "linux/amd64", normalizePlatform("linux/amd64"))
+	assert.Equal(t, "linux/amd64", normalizePlatform("linux/x86_64/"))
+	assert.Equal(t, "linux/arm64", normalizePlatform("linux/aarch64"))
+	assert.Equal(t, "linux/arm/v7", normalizePlatform("linux/arm"))
+}
@@ -18,6 +18,7 @@ type listBuilderOptions struct {
 	iidFile       string
 	authfile      string
 	skipTLSVerify *bool
+	keepExisting  bool
 }
 
 type listLocal struct {
@@ -108,8 +109,9 @@ func (l *listLocal) build(ctx context.Context, images map[entities.BuildReport]e
 		}
 	}
 
-	// Clear the list in the event it already existed
-	if exists.Value {
+	// Clear the list in the event it already existed, unless the images
+	// of the platforms that have not been rebuilt must be kept
+	if exists.Value && !l.options.keepExisting {
 		_, err = l.localEngine.ManifestListClear(ctx, l.listName)
 		if err != nil {
 			return "", fmt.Errorf("error clearing list %q", l.listName)
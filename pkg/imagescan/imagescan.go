@@ -0,0 +1,197 @@
+// Package imagescan runs vulnerability scanners against images.  Scanners are
+// pluggable via the Scanner interface; ExecScanner runs an external scanner
+// which reads a JSON Request on stdin and writes a JSON Report to stdout.
+package imagescan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// RequestVersion is the version of the scanner protocol.
+const RequestVersion = 1
+
+// ErrNoScanner indicates that no image scanner is configured.
+var ErrNoScanner = errors.New("no image scanner configured: set image_scanner in containers.conf")
+
+// Severity is the severity of a finding of a scan.
+type Severity int
+
+const (
+	// SeverityUnknown is the severity of findings the scanner could not
+	// rate.
+	SeverityUnknown Severity = iota
+	// SeverityLow is the severity of findings with a low impact.
+	SeverityLow
+	// SeverityMedium is the severity of findings with a medium impact.
+	SeverityMedium
+	// SeverityHigh is the severity of findings with a high impact.
+	SeverityHigh
+	// SeverityCritical is the severity of findings with a critical impact.
+	SeverityCritical
+)
+
+var severityNames = []string{"unknown", "low", "medium", "high", "critical"}
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return severityNames[SeverityUnknown]
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity parses the name of a severity.
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(name, n) {
+			return Severity(i), nil
+		}
+	}
+	return SeverityUnknown, fmt.Errorf("invalid severity %q: must be one of %s", name, strings.Join(severityNames[SeverityLow:], ", "))
+}
+
+// MarshalText encodes the severity as its name.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes the name of a severity.  Unknown names are decoded as
+// SeverityUnknown so that scanners may use a finer classification.
+func (s *Severity) UnmarshalText(text []byte) error {
+	severity, err := ParseSeverity(string(text))
+	if err != nil {
+		severity = SeverityUnknown
+	}
+	*s = severity
+	return nil
+}
+
+// Image describes the image to scan.
+type Image struct {
+	// ID of the image.
+	ID string `json:"id"`
+	// Names of the image.
+	Names []string `json:"names,omitempty"`
+	// Digest of the image's manifest.
+	Digest string `json:"digest,omitempty"`
+	// Layers are the digests of the uncompressed layers of the image,
+	// starting with the base layer.
+	Layers []string `json:"layers,omitempty"`
+}
+
+// Request is passed to the scanner to scan an image.
+type Request struct {
+	// Version of the protocol.
+	Version int `json:"version"`
+	// Image to scan.
+	Image Image `json:"image"`
+	// RootFS is the path of the mounted root filesystem of the image.
+	RootFS string `json:"rootfs"`
+}
+
+// Finding is a vulnerability found in an image.
+type Finding struct {
+	// ID of the vulnerability, e.g. a CVE.
+	ID string `json:"id"`
+	// Severity of the vulnerability.
+	Severity Severity `json:"severity"`
+	// Package which is vulnerable.
+	Package string `json:"package,omitempty"`
+	// Version of the vulnerable package.
+	Version string `json:"version,omitempty"`
+	// FixedVersion is the version of the package fixing the
+	// vulnerability.
+	FixedVersion string `json:"fixedVersion,omitempty"`
+	// Description of the vulnerability.
+	Description string `json:"description,omitempty"`
+}
+
+// Report is the result of scanning an image.
+type Report struct {
+	// Scanner is the name of the scanner.
+	Scanner string `json:"scanner,omitempty"`
+	// Command is the command, followed by its arguments, which ran the
+	// scanner.
+	Command []string `json:"command,omitempty"`
+	// Created is the time of the scan.
+	Created time.Time `json:"created"`
+	// Findings of the scan.
+	Findings []Finding `json:"findings"`
+}
+
+// Outdated returns whether the report was not made with the specified
+// command or is older than maxAge.
+func (r *Report) Outdated(command []string, maxAge time.Duration) bool {
+	if !slices.Equal(r.Command, command) {
+		return true
+	}
+	return time.Since(r.Created) > maxAge
+}
+
+// Blocking returns the findings of the report with at least the specified
+// severity.
+func (r *Report) Blocking(threshold Severity) []Finding {
+	var blocking []Finding
+	for _, f := range r.Findings {
+		if f.Severity >= threshold {
+			blocking = append(blocking, f)
+		}
+	}
+	return blocking
+}
+
+// Scanner scans images.
+type Scanner interface {
+	// Scan scans the image described by the request.
+	Scan(ctx context.Context, request *Request) (*Report, error)
+}
+
+// ExecScanner is a Scanner running an external command.
+type ExecScanner struct {
+	// Command and its arguments.
+	Command []string
+}
+
+// Scan runs the command, passing the JSON encoded request on stdin, and
+// decodes the report the command writes to stdout.
+func (s *ExecScanner) Scan(ctx context.Context, request *Request) (*Report, error) {
+	if len(s.Command) == 0 {
+		return nil, ErrNoScanner
+	}
+	input, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("running image scanner %s: %w: %s", s.Command[0], err, msg)
+		}
+		return nil, fmt.Errorf("running image scanner %s: %w", s.Command[0], err)
+	}
+	report := Report{}
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		return nil, fmt.Errorf("decoding report of image scanner %s: %w", s.Command[0], err)
+	}
+	if report.Scanner == "" {
+		report.Scanner = s.Command[0]
+	}
+	report.Command = s.Command
+	if report.Created.IsZero() {
+		report.Created = time.Now()
+	}
+	return &report, nil
+}
@@ -0,0 +1,71 @@
+package imagescan
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSeverity(t *testing.T) {
+	severity, err := ParseSeverity("High")
+	require.NoError(t, err)
+	assert.Equal(t, SeverityHigh, severity)
+
+	_, err = ParseSeverity("urgent")
+	assert.ErrorContains(t, err, `invalid severity "urgent": must be one of low, medium, high, critical`)
+
+	// Unknown severities of scanners are decoded as such.
+	finding := Finding{}
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"CVE-1","severity":"negligible"}`), &finding))
+	assert.Equal(t, SeverityUnknown, finding.Severity)
+	data, err := json.Marshal(Finding{ID: "CVE-2", Severity: SeverityCritical})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"CVE-2","severity":"critical"}`, string(data))
+}
+
+func TestReportBlocking(t *testing.T) {
+	report := Report{Findings: []Finding{
+		{ID: "CVE-1", Severity: SeverityLow},
+		{ID: "CVE-2", Severity: SeverityHigh},
+		{ID: "CVE-3", Severity: SeverityCritical},
+	}}
+	assert.Len(t, report.Blocking(SeverityHigh), 2)
+	assert.Len(t, report.Blocking(SeverityLow), 3)
+	assert.Empty(t, (&Report{}).Blocking(SeverityLow))
+}
+
+func TestReportOutdated(t *testing.T) {
+	command := []string{"scanner", "--db", "/var/lib/db"}
+	report := Report{Command: command, Created: time.Now().Add(-time.Hour)}
+	assert.False(t, report.Outdated(command, 2*time.Hour))
+	assert.True(t, report.Outdated(command, 30*time.Minute))
+	assert.True(t, report.Outdated([]string{"other-scanner"}, 2*time.Hour))
+	// Reports recorded before the command was recorded are rescanned.
+	assert.True(t, (&Report{Created: time.Now()}).Outdated(command, 2*time.Hour))
+}
+
+func TestExecScanner(t *testing.T) {
+	// The scanner reports the ID of the image it was asked to scan.
+	scanner := ExecScanner{Command: []string{"sh", "-c", `id=$(sed 's/.*"id":"\([^"]*\)".*/\1/'); echo "{\"scanner\":\"test\",\"findings\":[{\"id\":\"$id\",\"severity\":\"medium\"}]}"`}}
+	report, err := scanner.Scan(context.Background(), &Request{Version: RequestVersion, Image: Image{ID: "abc"}})
+	require.NoError(t, err)
+	assert.Equal(t, "test", report.Scanner)
+	assert.Equal(t, scanner.Command, report.Command)
+	assert.False(t, report.Created.IsZero())
+	assert.Equal(t, []Finding{{ID: "abc", Severity: SeverityMedium}}, report.Findings)
+
+	scanner = ExecScanner{Command: []string{"sh", "-c", "echo database outdated >&2; exit 1"}}
+	_, err = scanner.Scan(context.Background(), &Request{})
+	assert.ErrorContains(t, err, "running image scanner sh: exit status 1: database outdated")
+
+	scanner = ExecScanner{Command: []string{"echo", "not json"}}
+	_, err = scanner.Scan(context.Background(), &Request{})
+	assert.ErrorContains(t, err, "decoding report of image scanner echo")
+
+	_, err = (&ExecScanner{}).Scan(context.Background(), &Request{})
+	assert.ErrorIs(t, err, ErrNoScanner)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/podman/v5/libpod/define"
+	"github.com/containers/podman/v5/pkg/trust"
 	"github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
@@ -34,6 +35,7 @@ type ImageData struct {
 	History      []v1.History                  `json:"History"`
 	NamesHistory []string                      `json:"NamesHistory"`
 	HealthCheck  *manifest.Schema2HealthConfig `json:"Healthcheck,omitempty"`
+	TrustStatus  *trust.Status                 `json:"TrustStatus,omitempty"`
 }
 
 // RootFS holds the root fs information of an image.
@@ -0,0 +1,76 @@
+package libartifact
+
+import (
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	specV1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Artifact is an OCI artifact in the local artifact store.
+type Artifact struct {
+	// Name is the reference the artifact has been stored with.
+	Name string
+	// Digest of the artifact's manifest.
+	Digest digest.Digest
+	// Manifest of the artifact.
+	Manifest *specV1.Manifest
+}
+
+// TotalSizeBytes returns the sum of the sizes of the artifact's blobs.
+func (a *Artifact) TotalSizeBytes() int64 {
+	var size int64
+	for _, layer := range a.Manifest.Layers {
+		size += layer.Size
+	}
+	return size
+}
+
+// Type returns the media type of the artifact.  It is the artifactType of the
+// manifest or, if not set, the media type of its config as recommended by the
+// OCI image spec.
+func (a *Artifact) Type() string {
+	if a.Manifest.ArtifactType != "" {
+		return a.Manifest.ArtifactType
+	}
+	return a.Manifest.Config.MediaType
+}
+
+// BlobTitle returns the title of the specified blob of the artifact, which
+// is its file name when mounted into a container.  The title is taken from
+// the org.opencontainers.image.title annotation and defaults to the encoded
+// digest of the blob.
+func BlobTitle(blob specV1.Descriptor) string {
+	if title := blob.Annotations[specV1.AnnotationTitle]; title != "" && !strings.ContainsAny(title, "/\\") && title != "." && title != ".." {
+		return title
+	}
+	return blob.Digest.Encoded()
+}
+
+// ArtifactList is a list of artifacts.
+type ArtifactList []*Artifact
+
+// GetByNameOrDigest returns the artifact with the specified name or a digest
+// (prefix) of its manifest.
+func (al ArtifactList) GetByNameOrDigest(nameOrDigest string) (*Artifact, bool) {
+	for _, a := range al {
+		if a.Name == nameOrDigest {
+			return a, true
+		}
+	}
+	prefix := strings.TrimPrefix(nameOrDigest, "sha256:")
+	if len(prefix) < 3 {
+		return nil, false
+	}
+	var match *Artifact
+	for _, a := range al {
+		if strings.HasPrefix(a.Digest.Encoded(), prefix) {
+			if match != nil && match.Digest != a.Digest {
+				// Ambiguous digest prefix.
+				return nil, false
+			}
+			match = a
+		}
+	}
+	return match, match != nil
+}
@@ -0,0 +1,536 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/pkg/shortnames"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	"github.com/containers/podman/v5/pkg/libartifact"
+	"github.com/containers/storage/pkg/ioutils"
+	"github.com/containers/storage/pkg/lockfile"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	specV1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrArtifactUnknown is returned when an artifact cannot be found.
+	ErrArtifactUnknown = errors.New("artifact does not exist")
+	// ErrArtifactInUse is returned when an artifact cannot be removed
+	// because its blobs are in use.
+	ErrArtifactInUse = errors.New("artifact is in use")
+)
+
+const (
+	indexName  = "index.json"
+	layoutName = "oci-layout"
+	lockName   = "index.lock"
+	blobsDir   = "blobs"
+)
+
+// ArtifactStore is a store for OCI artifacts in the OCI image layout format.
+type ArtifactStore struct {
+	storePath string
+	lock      *lockfile.LockFile
+}
+
+// CopyOptions configure pulling and pushing artifacts.
+type CopyOptions struct {
+	// SystemContext used to access the registry.
+	SystemContext *types.SystemContext
+	// ReportWriter receives the progress output.  Nil for quiet copies.
+	ReportWriter io.Writer
+}
+
+// InUseFunc returns an error wrapping ErrArtifactInUse when any of the blobs
+// at the specified paths is in use.
+type InUseFunc func(blobPaths []string) error
+
+// BlobMountPath describes a blob of an artifact to be mounted.
+type BlobMountPath struct {
+	// SourcePath is the path of the blob on the host.
+	SourcePath string
+	// Name is the file name of the blob in the container.
+	Name string
+}
+
+// NewArtifactStore returns an artifact store at the specified path.  The
+// directory is created if needed.
+func NewArtifactStore(storePath string) (*ArtifactStore, error) {
+	if storePath == "" {
+		return nil, errors.New("store path cannot be empty")
+	}
+	if err := os.MkdirAll(storePath, 0o700); err != nil {
+		return nil, fmt.Errorf("creating artifact store: %w", err)
+	}
+	lock, err := lockfile.GetLockFile(filepath.Join(storePath, lockName))
+	if err != nil {
+		return nil, err
+	}
+	return &ArtifactStore{storePath: storePath, lock: lock}, nil
+}
+
+// List returns all artifacts in the store.
+func (as *ArtifactStore) List(ctx context.Context) (libartifact.ArtifactList, error) {
+	as.lock.RLock()
+	defer as.lock.Unlock()
+	return as.list()
+}
+
+// Inspect returns the artifact with the specified name or digest.
+func (as *ArtifactStore) Inspect(ctx context.Context, nameOrDigest string) (*libartifact.Artifact, error) {
+	as.lock.RLock()
+	defer as.lock.Unlock()
+	return as.lookup(nameOrDigest)
+}
+
+// Remove removes the artifact with the specified name or digest from the
+// store and returns the digest of its manifest.  Unless inUse is nil, it is
+// called with the paths of the blobs of the artifact while the store is
+// locked, and the artifact is kept when it returns an error.
+func (as *ArtifactStore) Remove(ctx context.Context, nameOrDigest string, inUse InUseFunc) (*digest.Digest, error) {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	artifact, err := as.lookup(nameOrDigest)
+	if err != nil {
+		return nil, err
+	}
+	if inUse != nil {
+		blobPaths := make([]string, 0, len(artifact.Manifest.Layers))
+		for _, layer := range artifact.Manifest.Layers {
+			blobPaths = append(blobPaths, as.blobPath(layer.Digest))
+		}
+		if err := inUse(blobPaths); err != nil {
+			return nil, fmt.Errorf("removing artifact %s: %w", artifact.Name, err)
+		}
+	}
+	index, err := as.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	manifests := index.Manifests[:0]
+	for _, m := range index.Manifests {
+		if m.Annotations[specV1.AnnotationRefName] != artifact.Name {
+			manifests = append(manifests, m)
+		}
+	}
+	index.Manifests = manifests
+	if err := as.writeIndex(index); err != nil {
+		return nil, err
+	}
+	return &artifact.Digest, as.prune()
+}
+
+// Pull pulls the artifact with the specified name from a registry into the
+// store.
+func (as *ArtifactStore) Pull(ctx context.Context, name string, opts CopyOptions) (*libartifact.Artifact, error) {
+	named, err := normalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+	srcRef, err := docker.NewReference(named)
+	if err != nil {
+		return nil, err
+	}
+
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	destRef, err := layout.NewReference(as.storePath, named.String())
+	if err != nil {
+		return nil, err
+	}
+	if err := as.copy(ctx, srcRef, destRef, opts); err != nil {
+		return nil, err
+	}
+	// Pulling an artifact with an existing name leaves the old one
+	// behind unnamed.
+	if err := as.prune(); err != nil {
+		return nil, err
+	}
+	return as.lookup(named.String())
+}
+
+// Push pushes the artifact with the specified name or digest to the
+// specified destination in a registry.  The artifact's name is used if dest
+// is empty.
+func (as *ArtifactStore) Push(ctx context.Context, nameOrDigest, dest string, opts CopyOptions) error {
+	as.lock.RLock()
+	defer as.lock.Unlock()
+
+	artifact, err := as.lookup(nameOrDigest)
+	if err != nil {
+		return err
+	}
+	if dest == "" {
+		dest = artifact.Name
+	}
+	named, err := normalizeName(dest)
+	if err != nil {
+		return err
+	}
+	destRef, err := docker.NewReference(named)
+	if err != nil {
+		return err
+	}
+	srcRef, err := layout.NewReference(as.storePath, artifact.Name)
+	if err != nil {
+		return err
+	}
+	return as.copy(ctx, srcRef, destRef, opts)
+}
+
+// Add creates an artifact with the specified name from the specified files.
+// Each file becomes a blob of the artifact titled with its base name.
+func (as *ArtifactStore) Add(ctx context.Context, name string, paths []string, artifactType string) (*libartifact.Artifact, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("at least one file is required to create an artifact")
+	}
+	named, err := normalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	manifest := specV1.Manifest{
+		Versioned:    specs.Versioned{SchemaVersion: 2},
+		MediaType:    specV1.MediaTypeImageManifest,
+		ArtifactType: artifactType,
+		Config:       specV1.DescriptorEmptyJSON,
+		Layers:       make([]specV1.Descriptor, 0, len(paths)),
+	}
+	titles := make(map[string]struct{})
+	for _, path := range paths {
+		title := filepath.Base(path)
+		if _, ok := titles[title]; ok {
+			return nil, fmt.Errorf("file names must be unique: %q is specified more than once", title)
+		}
+		titles[title] = struct{}{}
+		desc, err := as.addBlobFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		desc.MediaType = "application/octet-stream"
+		desc.Annotations = map[string]string{specV1.AnnotationTitle: title}
+		manifest.Layers = append(manifest.Layers, desc)
+	}
+	if _, err := as.addBlob(specV1.DescriptorEmptyJSON.Data); err != nil {
+		return nil, err
+	}
+
+	rawManifest, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	manifestDesc, err := as.addBlob(rawManifest)
+	if err != nil {
+		return nil, err
+	}
+	manifestDesc.MediaType = specV1.MediaTypeImageManifest
+	manifestDesc.ArtifactType = artifactType
+	manifestDesc.Annotations = map[string]string{specV1.AnnotationRefName: named.String()}
+
+	index, err := as.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	manifests := index.Manifests[:0]
+	for _, m := range index.Manifests {
+		if m.Annotations[specV1.AnnotationRefName] != named.String() {
+			manifests = append(manifests, m)
+		}
+	}
+	index.Manifests = append(manifests, manifestDesc)
+	if err := as.writeIndex(index); err != nil {
+		return nil, err
+	}
+	if err := as.prune(); err != nil {
+		return nil, err
+	}
+	return as.lookup(named.String())
+}
+
+// BlobMountPaths returns the paths of the blobs of the artifact with the
+// specified name or digest along with their file names, which can be used to
+// mount them into a container.
+func (as *ArtifactStore) BlobMountPaths(ctx context.Context, nameOrDigest string) ([]BlobMountPath, error) {
+	as.lock.RLock()
+	defer as.lock.Unlock()
+
+	artifact, err := as.lookup(nameOrDigest)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]BlobMountPath, 0, len(artifact.Manifest.Layers))
+	for _, layer := range artifact.Manifest.Layers {
+		paths = append(paths, BlobMountPath{
+			SourcePath: as.blobPath(layer.Digest),
+			Name:       libartifact.BlobTitle(layer),
+		})
+	}
+	return paths, nil
+}
+
+// copy copies the artifact from src to dest.  Artifacts are copied as is,
+// i.e. without any conversion or compression of their blobs.
+func (as *ArtifactStore) copy(ctx context.Context, src, dest types.ImageReference, opts CopyOptions) error {
+	policy, err := signature.DefaultPolicy(opts.SystemContext)
+	if err != nil {
+		return fmt.Errorf("obtaining signature policy: %w", err)
+	}
+	policyContext, err := signature.NewPolicyContext(policy)
+	if err != nil {
+		return fmt.Errorf("creating new signature policy context: %w", err)
+	}
+	defer func() {
+		if err := policyContext.Destroy(); err != nil {
+			logrus.Errorf("Destroying signature policy context: %v", err)
+		}
+	}()
+
+	destCtx := &types.SystemContext{OCIAcceptUncompressedLayers: true}
+	if opts.SystemContext != nil {
+		sc := *opts.SystemContext
+		sc.OCIAcceptUncompressedLayers = true
+		destCtx = &sc
+	}
+	copyOpts := copy.Options{
+		SourceCtx:      opts.SystemContext,
+		DestinationCtx: destCtx,
+		ReportWriter:   opts.ReportWriter,
+	}
+	if _, err := copy.Image(ctx, policyContext, dest, src, &copyOpts); err != nil {
+		return err
+	}
+	return nil
+}
+
+// list returns all named artifacts in the store.  Must be called with the
+// lock held.
+func (as *ArtifactStore) list() (libartifact.ArtifactList, error) {
+	index, err := as.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	artifacts := make(libartifact.ArtifactList, 0, len(index.Manifests))
+	for _, desc := range index.Manifests {
+		name := desc.Annotations[specV1.AnnotationRefName]
+		if name == "" {
+			continue
+		}
+		manifest, err := as.readManifest(desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("reading manifest of artifact %q: %w", name, err)
+		}
+		artifacts = append(artifacts, &libartifact.Artifact{
+			Name:     name,
+			Digest:   desc.Digest,
+			Manifest: manifest,
+		})
+	}
+	return artifacts, nil
+}
+
+// lookup returns the artifact with the specified name or digest.  Must be
+// called with the lock held.
+func (as *ArtifactStore) lookup(nameOrDigest string) (*libartifact.Artifact, error) {
+	artifacts, err := as.list()
+	if err != nil {
+		return nil, err
+	}
+	if artifact, ok := artifacts.GetByNameOrDigest(nameOrDigest); ok {
+		return artifact, nil
+	}
+	// Try again with the normalized name, e.g., to match a missing tag.
+	if named, err := normalizeName(nameOrDigest); err == nil {
+		if artifact, ok := artifacts.GetByNameOrDigest(named.String()); ok {
+			return artifact, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", nameOrDigest, ErrArtifactUnknown)
+}
+
+// prune removes all unnamed manifests from the index along with all blobs no
+// longer referenced by a named manifest.  Must be called with the lock held.
+func (as *ArtifactStore) prune() error {
+	index, err := as.readIndex()
+	if err != nil {
+		return err
+	}
+	used := make(map[digest.Digest]struct{})
+	manifests := index.Manifests[:0]
+	for _, desc := range index.Manifests {
+		if desc.Annotations[specV1.AnnotationRefName] == "" {
+			continue
+		}
+		manifests = append(manifests, desc)
+		manifest, err := as.readManifest(desc.Digest)
+		if err != nil {
+			return err
+		}
+		used[desc.Digest] = struct{}{}
+		used[manifest.Config.Digest] = struct{}{}
+		for _, layer := range manifest.Layers {
+			used[layer.Digest] = struct{}{}
+		}
+	}
+	if len(manifests) != len(index.Manifests) {
+		index.Manifests = manifests
+		if err := as.writeIndex(index); err != nil {
+			return err
+		}
+	}
+
+	algDir := filepath.Join(as.storePath, blobsDir, digest.Canonical.String())
+	entries, err := os.ReadDir(algDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		d := digest.NewDigestFromEncoded(digest.Canonical, entry.Name())
+		if _, ok := used[d]; ok {
+			continue
+		}
+		if err := os.Remove(filepath.Join(algDir, entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (as *ArtifactStore) blobPath(d digest.Digest) string {
+	return filepath.Join(as.storePath, blobsDir, d.Algorithm().String(), d.Encoded())
+}
+
+func (as *ArtifactStore) readIndex() (*specV1.Index, error) {
+	index := specV1.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: specV1.MediaTypeImageIndex,
+	}
+	raw, err := os.ReadFile(filepath.Join(as.storePath, indexName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &index, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &index); err != nil {
+		return nil, fmt.Errorf("parsing artifact store index: %w", err)
+	}
+	return &index, nil
+}
+
+func (as *ArtifactStore) writeIndex(index *specV1.Index) error {
+	layoutBytes, err := json.Marshal(specV1.ImageLayout{Version: specV1.ImageLayoutVersion})
+	if err != nil {
+		return err
+	}
+	if err := ioutils.AtomicWriteFile(filepath.Join(as.storePath, layoutName), layoutBytes, 0o644); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return ioutils.AtomicWriteFile(filepath.Join(as.storePath, indexName), raw, 0o644)
+}
+
+func (as *ArtifactStore) readManifest(d digest.Digest) (*specV1.Manifest, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(as.blobPath(d))
+	if err != nil {
+		return nil, err
+	}
+	manifest := specV1.Manifest{}
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// addBlob stores the specified data as a blob.
+func (as *ArtifactStore) addBlob(data []byte) (specV1.Descriptor, error) {
+	d := digest.FromBytes(data)
+	path := as.blobPath(d)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return specV1.Descriptor{}, err
+	}
+	if err := ioutils.AtomicWriteFile(path, data, 0o644); err != nil {
+		return specV1.Descriptor{}, err
+	}
+	return specV1.Descriptor{Digest: d, Size: int64(len(data))}, nil
+}
+
+// addBlobFromFile stores the content of the specified file as a blob.
+func (as *ArtifactStore) addBlobFromFile(path string) (specV1.Descriptor, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return specV1.Descriptor{}, err
+	}
+	defer src.Close()
+
+	algDir := filepath.Join(as.storePath, blobsDir, digest.Canonical.String())
+	if err := os.MkdirAll(algDir, 0o755); err != nil {
+		return specV1.Descriptor{}, err
+	}
+	tmp, err := os.CreateTemp(algDir, ".tmp-blob-")
+	if err != nil {
+		return specV1.Descriptor{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), src)
+	if err != nil {
+		tmp.Close()
+		return specV1.Descriptor{}, fmt.Errorf("copying %s: %w", path, err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return specV1.Descriptor{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return specV1.Descriptor{}, err
+	}
+	d := digest.NewDigest(digest.SHA256, hash)
+	if err := os.Rename(tmp.Name(), as.blobPath(d)); err != nil {
+		return specV1.Descriptor{}, err
+	}
+	return specV1.Descriptor{Digest: d, Size: size}, nil
+}
+
+// normalizeName parses the specified artifact name and adds the latest tag if
+// no tag or digest is specified.  Short names are rejected as artifacts are
+// not subject to short-name resolution.
+func normalizeName(name string) (reference.Named, error) {
+	if shortnames.IsShortName(name) {
+		return nil, fmt.Errorf("%q is a short name: artifacts must be referenced by a fully-qualified name", name)
+	}
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return nil, fmt.Errorf("parsing artifact name %q: %w", name, err)
+	}
+	return reference.TagNameOnly(named), nil
+}
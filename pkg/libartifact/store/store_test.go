@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	as, err := NewArtifactStore(filepath.Join(dir, "store"))
+	require.NoError(t, err)
+
+	foo := filepath.Join(dir, "foo.txt")
+	require.NoError(t, os.WriteFile(foo, []byte("foo"), 0o600))
+	bar := filepath.Join(dir, "bar.bin")
+	require.NoError(t, os.WriteFile(bar, []byte("barbar"), 0o600))
+
+	_, err = as.Add(ctx, "myartifact", []string{foo}, "")
+	assert.ErrorContains(t, err, "short name")
+
+	artifact, err := as.Add(ctx, "quay.io/test/artifact", []string{foo, bar}, "application/vnd.test")
+	require.NoError(t, err)
+	assert.Equal(t, "quay.io/test/artifact:latest", artifact.Name)
+	assert.Equal(t, "application/vnd.test", artifact.Type())
+	assert.Equal(t, int64(9), artifact.TotalSizeBytes())
+
+	artifacts, err := as.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1)
+	assert.Equal(t, artifact.Digest, artifacts[0].Digest)
+
+	inspected, err := as.Inspect(ctx, "quay.io/test/artifact")
+	require.NoError(t, err)
+	assert.Equal(t, artifact.Digest, inspected.Digest)
+	inspected, err = as.Inspect(ctx, artifact.Digest.Encoded()[:12])
+	require.NoError(t, err)
+	assert.Equal(t, artifact.Name, inspected.Name)
+
+	paths, err := as.BlobMountPaths(ctx, artifact.Name)
+	require.NoError(t, err)
+	require.Len(t, paths, 2)
+	assert.Equal(t, "foo.txt", paths[0].Name)
+	assert.Equal(t, "bar.bin", paths[1].Name)
+	content, err := os.ReadFile(paths[1].SourcePath)
+	require.NoError(t, err)
+	assert.Equal(t, "barbar", string(content))
+
+	_, err = as.Add(ctx, "quay.io/test/dup", []string{foo, foo}, "")
+	assert.ErrorContains(t, err, "file names must be unique")
+
+	// Replacing the artifact prunes the blobs no longer in use.
+	replaced, err := as.Add(ctx, artifact.Name, []string{foo}, "")
+	require.NoError(t, err)
+	assert.NotEqual(t, artifact.Digest, replaced.Digest)
+	_, err = os.Stat(paths[1].SourcePath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// An artifact whose blobs are in use is kept.
+	var checked []string
+	inUse := func(blobPaths []string) error {
+		checked = blobPaths
+		return ErrArtifactInUse
+	}
+	_, err = as.Remove(ctx, artifact.Name, inUse)
+	assert.ErrorIs(t, err, ErrArtifactInUse)
+	assert.Equal(t, []string{paths[0].SourcePath}, checked)
+	_, err = os.Stat(paths[0].SourcePath)
+	require.NoError(t, err)
+
+	removed, err := as.Remove(ctx, artifact.Name, nil)
+	require.NoError(t, err)
+	assert.Equal(t, replaced.Digest, *removed)
+	_, err = os.Stat(paths[0].SourcePath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = as.Inspect(ctx, artifact.Name)
+	assert.True(t, errors.Is(err, ErrArtifactUnknown))
+	artifacts, err = as.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, artifacts)
+}
@@ -0,0 +1,72 @@
+//go:build darwin
+
+package applehv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"golang.org/x/sys/unix"
+)
+
+// compactChunk is the size of the regions of the disk image checked for
+// zeros, a multiple of the APFS block size as hole punching requires
+const compactChunk = 1 << 20
+
+// CompactDisk punches holes in the raw disk image where it holds zeros, so
+// that APFS frees their blocks.  The blocks the guest discarded are holes
+// already and are not read.
+func (a AppleHVStubber) CompactDisk(mc *vmconfigs.MachineConfig) error {
+	mc.Lock()
+	defer mc.Unlock()
+
+	f, err := os.OpenFile(mc.ImagePath.GetPath(), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fd := int(f.Fd())
+
+	buf := make([]byte, compactChunk)
+	zeros := make([]byte, compactChunk)
+	var offset int64
+	for {
+		data, err := unix.Seek(fd, offset, unix.SEEK_DATA)
+		if errors.Is(err, unix.ENXIO) {
+			// no data after offset
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		hole, err := unix.Seek(fd, data, unix.SEEK_HOLE)
+		if err != nil {
+			return err
+		}
+
+		for offset = data - data%compactChunk; offset < hole; offset += compactChunk {
+			n, err := f.ReadAt(buf, offset)
+			if errors.Is(err, io.EOF) {
+				// the last partial chunk is kept
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if n == compactChunk && bytes.Equal(buf, zeros) {
+				if err := punchHole(f, offset, compactChunk); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// punchHole frees the blocks of the given region of f.  The fpunchhole_t
+// argument of F_PUNCHHOLE is a prefix of fstore_t, which x/sys/unix knows.
+func punchHole(f *os.File, offset, length int64) error {
+	return unix.FcntlFstore(f.Fd(), unix.F_PUNCHHOLE, &unix.Fstore_t{Offset: offset, Length: length})
+}
@@ -0,0 +1,60 @@
+//go:build darwin
+
+package applehv
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// AttachConsole follows the serial console of the machine.  vfkit writes the
+// serial port to the log file of the machine and cannot connect it to a
+// socket, so the console is read-only.
+func (a AppleHVStubber) AttachConsole(mc *vmconfigs.MachineConfig) (io.ReadWriteCloser, error) {
+	logFile, err := mc.LogFile()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(logFile.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	return &logConsole{f: f, closed: make(chan struct{})}, nil
+}
+
+// logConsole reads a log file as it grows, like tail -f
+type logConsole struct {
+	f      *os.File
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (l *logConsole) Read(p []byte) (int, error) {
+	for {
+		n, err := l.f.Read(p)
+		if n > 0 || !errors.Is(err, io.EOF) {
+			return n, err
+		}
+		select {
+		case <-l.closed:
+			return 0, io.EOF
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+func (l *logConsole) Write(p []byte) (int, error) {
+	return 0, errors.New("the serial console of applehv machines is read-only")
+}
+
+func (l *logConsole) Close() error {
+	l.once.Do(func() {
+		close(l.closed)
+	})
+	return l.f.Close()
+}
@@ -4,10 +4,13 @@ package applehv
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
 	"syscall"
 
 	"github.com/containers/common/pkg/strongunits"
+	"github.com/containers/podman/v5/pkg/errorhandling"
 	"github.com/containers/podman/v5/pkg/machine"
 	"github.com/containers/podman/v5/pkg/machine/define"
 	"github.com/containers/podman/v5/pkg/machine/ignition"
@@ -21,7 +24,20 @@ func (a *AppleHVStubber) Remove(mc *vmconfigs.MachineConfig) ([]string, func() e
 	mc.Lock()
 	defer mc.Unlock()
 
-	return []string{}, func() error { return nil }, nil
+	return []string{}, func() error {
+		var errs []error
+		if err := machine.ReleaseMachinePort(mc.SSH.Port); err != nil {
+			errs = append(errs, err)
+		}
+		if endpoint, err := url.Parse(mc.AppleHypervisor.Vfkit.Endpoint); err == nil {
+			if port, err := strconv.Atoi(endpoint.Port()); err == nil {
+				if err := machine.ReleaseMachinePort(port); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+		return errorhandling.JoinErrors(errs)
+	}, nil
 }
 
 // getIgnitionVsockDeviceAsCLI retrieves the ignition vsock device and converts
@@ -80,6 +96,10 @@ func (a *AppleHVStubber) StopVM(mc *vmconfigs.MachineConfig, _ bool) error {
 	return mc.AppleHypervisor.Vfkit.Stop(false, true)
 }
 
+func (a *AppleHVStubber) KillVM(mc *vmconfigs.MachineConfig) error {
+	return mc.AppleHypervisor.Vfkit.Stop(true, true)
+}
+
 // checkProcessRunning checks non blocking if the pid exited
 // returns nil if process is running otherwise an error if not
 func checkProcessRunning(processName string, pid int) error {
@@ -102,6 +122,27 @@ func resizeDisk(mc *vmconfigs.MachineConfig, newSize strongunits.GiB) error {
 	return os.Truncate(mc.ImagePath.GetPath(), int64(newSize.ToBytes()))
 }
 
+// mountAddedVolumes mounts the virtiofs volumes that no unit of the ignition
+// file mounts because they were added after the machine was initialized
+func mountAddedVolumes(mc *vmconfigs.MachineConfig) error {
+	for _, mount := range mc.Mounts {
+		options := "defcontext=system_u:object_r:nfs_t:s0"
+		if mount.ReadOnly {
+			options += ",ro"
+		}
+		// / is immutable in FCOS, see virtiofs-mount-prepare@.service
+		args := []string{"-q", "--", "mountpoint", "-q", mount.Target, "||", "{",
+			"sudo", "chattr", "-i", "/", ";",
+			"sudo", "mkdir", "-p", mount.Target, ";",
+			"sudo", "chattr", "+i", "/", ";",
+			"sudo", "mount", "-t", "virtiofs", "-o", options, mount.Tag, mount.Target, ";", "}"}
+		if err := machine.CommonSSH(mc.SSH.RemoteUsername, mc.SSH.IdentityPath, mc.Name, mc.SSH.Port, args); err != nil {
+			return fmt.Errorf("mounting volume %s:%s: %w", mount.Source, mount.Target, err)
+		}
+	}
+	return nil
+}
+
 func generateSystemDFilesForVirtiofsMounts(mounts []machine.VirtIoFs) []ignition.Unit {
 	// mounting in fcos with virtiofs is a bit of a dance.  we need a unit file for the mount, a unit file
 	// for automatic mounting on boot, and a "preparatory" service file that disables FCOS security, performs
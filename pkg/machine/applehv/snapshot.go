@@ -0,0 +1,63 @@
+//go:build darwin
+
+package applehv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"golang.org/x/sys/unix"
+)
+
+// snapshotPath returns the path of the APFS clone holding the snapshot with
+// the given name, next to the disk image
+func snapshotPath(mc *vmconfigs.MachineConfig, name string) string {
+	image := mc.ImagePath.GetPath()
+	ext := filepath.Ext(image)
+	return fmt.Sprintf("%s.snapshot-%s%s", strings.TrimSuffix(image, ext), name, ext)
+}
+
+// Snapshot clones the disk image.  The clone shares its blocks with the
+// image until either of them is modified, so it is taken instantly.
+func (a AppleHVStubber) Snapshot(mc *vmconfigs.MachineConfig, name string) (*define.VMFile, error) {
+	mc.Lock()
+	defer mc.Unlock()
+
+	dst := snapshotPath(mc, name)
+	if err := unix.Clonefile(mc.ImagePath.GetPath(), dst, unix.CLONE_NOFOLLOW); err != nil {
+		return nil, fmt.Errorf("cloning disk image to %q: %w", dst, err)
+	}
+	file, err := define.NewMachineFile(dst, nil)
+	if err != nil {
+		_ = os.Remove(dst)
+		return nil, err
+	}
+	return file, nil
+}
+
+// RevertSnapshot replaces the disk image with a clone of the snapshot, so
+// that the snapshot can be restored again
+func (a AppleHVStubber) RevertSnapshot(mc *vmconfigs.MachineConfig, snapshot *vmconfigs.Snapshot) error {
+	if snapshot.File == nil {
+		return fmt.Errorf("snapshot %q has no disk image clone", snapshot.Name)
+	}
+
+	mc.Lock()
+	defer mc.Unlock()
+
+	image := mc.ImagePath.GetPath()
+	tmp := image + ".restore"
+	_ = os.Remove(tmp)
+	if err := unix.Clonefile(snapshot.File.GetPath(), tmp, unix.CLONE_NOFOLLOW); err != nil {
+		return fmt.Errorf("cloning snapshot %q: %w", snapshot.Name, err)
+	}
+	if err := os.Rename(tmp, image); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
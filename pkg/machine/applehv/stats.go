@@ -0,0 +1,60 @@
+//go:build darwin
+
+package applehv
+
+import (
+	"fmt"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	psutil "github.com/shirou/gopsutil/v3/process"
+	"golang.org/x/exp/slices"
+)
+
+// Stats measures the vfkit process of the running machine and its disk image
+func (a AppleHVStubber) Stats(mc *vmconfigs.MachineConfig) (*vmconfigs.Stats, error) {
+	pid, err := vfkitPid(mc)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := machine.ProcessStats(pid)
+	if err != nil {
+		return nil, err
+	}
+	stats.DiskUsed, err = machine.DiskUsage(mc.ImagePath.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// vfkitPid finds the vfkit process of the machine.  vfkit writes no pid file,
+// so the process is found by the REST endpoint on its command line, which is
+// unique to the machine.
+func vfkitPid(mc *vmconfigs.MachineConfig) (int, error) {
+	endpointArgs, err := getVfKitEndpointCMDArgs(mc.AppleHypervisor.Vfkit.Endpoint)
+	if err != nil {
+		return 0, err
+	}
+	if len(endpointArgs) == 0 {
+		return 0, fmt.Errorf("machine %q has no vfkit endpoint to find its process by", mc.Name)
+	}
+	endpoint := endpointArgs[len(endpointArgs)-1]
+
+	processes, err := psutil.Processes()
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range processes {
+		// processes of other users cannot be read
+		args, err := p.CmdlineSlice()
+		if err != nil || len(args) == 0 {
+			continue
+		}
+		if slices.Contains(args, endpoint) {
+			return int(p.Pid), nil
+		}
+	}
+	return 0, fmt.Errorf("machine %q has no vfkit process: %w", mc.Name, define.ErrWrongState)
+}
@@ -20,7 +20,6 @@ import (
 	"github.com/containers/podman/v5/pkg/machine/shim/diskpull"
 	"github.com/containers/podman/v5/pkg/machine/sockets"
 	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
-	"github.com/containers/podman/v5/utils"
 	vfConfig "github.com/crc-org/vfkit/pkg/config"
 	"github.com/sirupsen/logrus"
 )
@@ -48,7 +47,34 @@ func (a AppleHVStubber) UseProviderNetworkSetup() bool {
 }
 
 func (a AppleHVStubber) RequireExclusiveActive() bool {
-	return true
+	return false
+}
+
+// Capabilities reports the features of vfkit, which cannot add devices to a
+// running machine
+func (a AppleHVStubber) Capabilities() define.Capabilities {
+	return define.Capabilities{
+		CompactDisk:     true,
+		Console:         true,
+		Export:          true,
+		IgnitionVsock:   true,
+		MultipleRunning: !a.RequireExclusiveActive(),
+		Snapshots:       true,
+		Stats:           true,
+		VirtioFS:        true,
+	}
+}
+
+func (a AppleHVStubber) StartRecovery(_ *vmconfigs.MachineConfig, _ define.RecoveryOverride) error {
+	return define.ErrNotImplemented
+}
+
+func (a AppleHVStubber) ConvertDisk(_ *vmconfigs.MachineConfig, _, _ *define.VMFile, _ define.ImageFormat) error {
+	return define.ErrNotImplemented
+}
+
+func (a AppleHVStubber) RunningConfig(_ *vmconfigs.MachineConfig) (*vmconfigs.RunningConfig, error) {
+	return nil, define.ErrNotImplemented
 }
 
 func (a AppleHVStubber) CreateVM(opts define.CreateVMOpts, mc *vmconfigs.MachineConfig, ignBuilder *ignition.IgnitionBuilder) error {
@@ -57,11 +83,13 @@ func (a AppleHVStubber) CreateVM(opts define.CreateVMOpts, mc *vmconfigs.Machine
 	bl := vfConfig.NewEFIBootloader(fmt.Sprintf("%s/efi-bl-%s", opts.Dirs.DataDir.GetPath(), opts.Name), true)
 	mc.AppleHypervisor.Vfkit.VirtualMachine = vfConfig.NewVirtualMachine(uint(mc.Resources.CPUs), mc.Resources.Memory, bl)
 
-	randPort, err := utils.GetRandomPort()
+	// the port is reserved so that vfkit processes of machines running at
+	// the same time do not collide
+	endpointPort, err := machine.AllocateMachinePort()
 	if err != nil {
 		return err
 	}
-	mc.AppleHypervisor.Vfkit.Endpoint = localhostURI + ":" + strconv.Itoa(randPort)
+	mc.AppleHypervisor.Vfkit.Endpoint = localhostURI + ":" + strconv.Itoa(endpointPort)
 
 	virtiofsMounts := make([]machine.VirtIoFs, 0, len(mc.Mounts))
 	for _, mnt := range mc.Mounts {
@@ -83,9 +111,16 @@ func (a AppleHVStubber) MountType() vmconfigs.VolumeMountType {
 	return vmconfigs.VirtIOFS
 }
 
-func (a AppleHVStubber) MountVolumesToVM(_ *vmconfigs.MachineConfig, _ bool) error {
-	// virtiofs: nothing to do here
-	return nil
+func (a AppleHVStubber) MountVolumesToVM(mc *vmconfigs.MachineConfig, _ bool) error {
+	// virtiofs: the volumes given at init are mounted by the units of the
+	// ignition file, only the ones added since are mounted here
+	return mountAddedVolumes(mc)
+}
+
+// UpdateMounts does nothing, the virtiofs devices of the mounts are given to
+// vfkit on the next start.  vfkit cannot add devices to a running machine.
+func (a AppleHVStubber) UpdateMounts(_ *vmconfigs.MachineConfig, _, _ []*vmconfigs.Mount) (bool, error) {
+	return false, nil
 }
 
 func (a AppleHVStubber) RemoveAndCleanMachines(_ *define.MachineDirs) error {
@@ -104,12 +139,6 @@ func (a AppleHVStubber) SetProviderAttrs(mc *vmconfigs.MachineConfig, opts defin
 		return errors.New("unable to change settings unless vm is stopped")
 	}
 
-	if opts.DiskSize != nil {
-		if err := resizeDisk(mc, *opts.DiskSize); err != nil {
-			return err
-		}
-	}
-
 	if opts.Rootful != nil && mc.HostUser.Rootful != *opts.Rootful {
 		if err := mc.SetRootful(*opts.Rootful); err != nil {
 			return err
@@ -117,10 +146,23 @@ func (a AppleHVStubber) SetProviderAttrs(mc *vmconfigs.MachineConfig, opts defin
 	}
 
 	if opts.USBs != nil {
-		return fmt.Errorf("changing USBs not supported for applehv machines")
+		return &define.ErrUSBPassthrough{VMType: define.AppleHvVirt}
 	}
 
-	// VFKit does not require saving memory, disk, or cpu
+	return nil
+}
+
+func (a AppleHVStubber) UpdateResources(mc *vmconfigs.MachineConfig, opts define.SetOptions) error {
+	mc.Lock()
+	defer mc.Unlock()
+
+	if opts.DiskSize != nil {
+		if err := resizeDisk(mc, *opts.DiskSize); err != nil {
+			return err
+		}
+	}
+
+	// The CPUs and memory are passed to vfkit when the machine starts
 	return nil
 }
 
@@ -139,7 +181,8 @@ func (a AppleHVStubber) StartNetworking(mc *vmconfigs.MachineConfig, cmd *gvprox
 
 func (a AppleHVStubber) StartVM(mc *vmconfigs.MachineConfig) (func() error, func() error, error) {
 	var (
-		ignitionSocket *define.VMFile
+		ignitionSocket   *define.VMFile
+		ignitionListener net.Listener
 	)
 
 	if bl := mc.AppleHypervisor.Vfkit.VirtualMachine.Bootloader; bl == nil {
@@ -245,10 +288,19 @@ func (a AppleHVStubber) StartVM(mc *vmconfigs.MachineConfig) (func() error, func
 		}
 		cmd.Args = append(cmd.Args, ignitionVsockDeviceCLI...)
 
+		ignitionFile, err := mc.IgnitionFile()
+		if err != nil {
+			return nil, nil, err
+		}
+		ignitionListener, err = net.Listen("unix", ignitionSocket.GetPath())
+		if err != nil {
+			return nil, nil, err
+		}
+
 		logrus.Debug("first boot detected")
 		logrus.Debugf("serving ignition file over %s", ignitionSocket.GetPath())
 		go func() {
-			if err := serveIgnitionOverSock(ignitionSocket, mc); err != nil {
+			if err := ignition.ServeOverSocket(ignitionListener, ignitionFile); err != nil {
 				logrus.Error(err)
 			}
 			logrus.Debug("ignition vsock server exited")
@@ -266,15 +318,22 @@ func (a AppleHVStubber) StartVM(mc *vmconfigs.MachineConfig) (func() error, func
 
 	logrus.Debug("waiting for ready notification")
 	readyChan := make(chan error)
-	go sockets.ListenAndWaitOnSocket(readyChan, readyListen)
+	go sockets.ListenAndWaitOnSocket(readyChan, readyListen, &mc.ReadyStatus)
 
 	logrus.Debugf("vfkit command-line: %v", cmd.Args)
 
 	if err := cmd.Start(); err != nil {
+		if ignitionListener != nil {
+			ignitionListener.Close()
+		}
 		return nil, nil, err
 	}
 
 	returnFunc := func() error {
+		// the guest fetched the ignition config before it got ready
+		if ignitionListener != nil {
+			defer ignitionListener.Close()
+		}
 		processErrChan := make(chan error)
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
@@ -329,5 +388,5 @@ func (a AppleHVStubber) PostStartNetworking(mc *vmconfigs.MachineConfig, noInfo
 }
 
 func (a AppleHVStubber) GetDisk(userInputPath string, dirs *define.MachineDirs, mc *vmconfigs.MachineConfig) error {
-	return diskpull.GetDisk(userInputPath, dirs, mc.ImagePath, a.VMType(), mc.Name)
+	return diskpull.GetDisk(userInputPath, dirs, mc, a.VMType())
 }
@@ -0,0 +1,277 @@
+// Package cloudinit provisions machines created from distribution cloud
+// images.  The ignition config generated for a machine is rendered as the
+// user-data of a NoCloud seed disk, so that the providers and the shim
+// describe a machine the same way whatever provisions it.
+package cloudinit
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/containers/podman/v5/pkg/machine/ignition"
+	"golang.org/x/exp/slices"
+	"sigs.k8s.io/yaml"
+)
+
+// cloudConfigHeader starts every cloud-config user-data
+const cloudConfigHeader = "#cloud-config\n"
+
+// systemdUnitDir is where the systemd units of the ignition config are
+// written
+const systemdUnitDir = "/etc/systemd/system"
+
+// cloudConfig is the subset of the cloud-config format the ignition config of
+// a machine is rendered to
+type cloudConfig struct {
+	Groups        []string    `json:"groups,omitempty"`
+	Users         []user      `json:"users,omitempty"`
+	DisableRoot   bool        `json:"disable_root"`
+	SSHPwauth     bool        `json:"ssh_pwauth"`
+	PackageUpdate bool        `json:"package_update"`
+	Packages      []string    `json:"packages,omitempty"`
+	WriteFiles    []writeFile `json:"write_files,omitempty"`
+	RunCmd        [][]string  `json:"runcmd,omitempty"`
+}
+
+type user struct {
+	Name              string   `json:"name"`
+	UID               *int     `json:"uid,omitempty"`
+	Groups            []string `json:"groups,omitempty"`
+	Shell             string   `json:"shell,omitempty"`
+	Sudo              string   `json:"sudo,omitempty"`
+	LockPasswd        bool     `json:"lock_passwd"`
+	SSHAuthorizedKeys []string `json:"ssh_authorized_keys,omitempty"`
+}
+
+type writeFile struct {
+	Path        string `json:"path"`
+	Content     string `json:"content"`
+	Encoding    string `json:"encoding,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+	Permissions string `json:"permissions,omitempty"`
+	Append      bool   `json:"append,omitempty"`
+	// Defer writes the file once the users and packages are installed
+	Defer bool `json:"defer,omitempty"`
+}
+
+// MetaData returns the NoCloud meta-data of the machine named name
+func MetaData(name string) []byte {
+	return []byte(fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n", name, name))
+}
+
+// UserData renders the ignition config of a machine as cloud-config
+// user-data.  Users, files, directories, links and systemd units are created
+// the way ignition would and podman is installed from the distribution
+// packages.  Files and links are written once the users exist, and units are
+// enabled last, so that the ready unit reports a provisioned machine.
+func UserData(cfg ignition.Config) ([]byte, error) {
+	cc := cloudConfig{
+		PackageUpdate: true,
+		Packages:      []string{"podman"},
+	}
+
+	for _, u := range cfg.Passwd.Users {
+		if u.ShouldExist != nil && !*u.ShouldExist {
+			continue
+		}
+		keys := make([]string, 0, len(u.SSHAuthorizedKeys))
+		for _, key := range u.SSHAuthorizedKeys {
+			keys = append(keys, string(key))
+		}
+		if u.Name == "root" {
+			// cloud-init does not create root, authorize the keys
+			// directly
+			cc.WriteFiles = append(cc.WriteFiles, writeFile{
+				Path:        "/root/.ssh/authorized_keys",
+				Content:     strings.Join(keys, "\n") + "\n",
+				Permissions: "0600",
+				Append:      true,
+			})
+			continue
+		}
+		nu := user{
+			Name:              u.Name,
+			UID:               u.UID,
+			Shell:             "/bin/bash",
+			Sudo:              "ALL=(ALL) NOPASSWD:ALL",
+			LockPasswd:        true,
+			SSHAuthorizedKeys: keys,
+		}
+		for _, g := range u.Groups {
+			nu.Groups = append(nu.Groups, string(g))
+			// the groups ignition expects are not all part of every
+			// distribution
+			if !slices.Contains(cc.Groups, string(g)) {
+				cc.Groups = append(cc.Groups, string(g))
+			}
+		}
+		cc.Users = append(cc.Users, nu)
+	}
+
+	for _, f := range cfg.Storage.Files {
+		wf, err := fileToWriteFile(f.Node, f.Mode, f.Contents, false)
+		if err != nil {
+			return nil, err
+		}
+		cc.WriteFiles = append(cc.WriteFiles, wf)
+		for _, r := range f.Append {
+			wf, err := fileToWriteFile(f.Node, f.Mode, r, true)
+			if err != nil {
+				return nil, err
+			}
+			cc.WriteFiles = append(cc.WriteFiles, wf)
+		}
+	}
+
+	// cloud-init creates the parents of the files it writes as root,
+	// directories are created or chowned once the files are written
+	for _, d := range cfg.Storage.Directories {
+		cc.RunCmd = append(cc.RunCmd, []string{"mkdir", "-p", d.Path})
+		if owner := nodeOwner(d.Node); owner != "" {
+			cc.RunCmd = append(cc.RunCmd, []string{"chown", owner, d.Path})
+		}
+		if d.Mode != nil {
+			cc.RunCmd = append(cc.RunCmd, []string{"chmod", strconv.FormatInt(int64(*d.Mode), 8), d.Path})
+		}
+	}
+
+	for _, l := range cfg.Storage.Links {
+		cc.RunCmd = append(cc.RunCmd, []string{"mkdir", "-p", path.Dir(l.Path)})
+		ln := []string{"ln", "-sfn", l.Target, l.Path}
+		if l.Hard != nil && *l.Hard {
+			ln = []string{"ln", "-f", l.Target, l.Path}
+		}
+		cc.RunCmd = append(cc.RunCmd, ln)
+		if owner := nodeOwner(l.Node); owner != "" {
+			cc.RunCmd = append(cc.RunCmd, []string{"chown", "-h", owner, l.Path})
+		}
+	}
+
+	var enable, disable, mask []string
+	for _, u := range cfg.Systemd.Units {
+		if u.Contents != nil {
+			cc.WriteFiles = append(cc.WriteFiles, writeFile{
+				Path:        path.Join(systemdUnitDir, u.Name),
+				Content:     *u.Contents,
+				Permissions: "0644",
+			})
+		}
+		for _, d := range u.Dropins {
+			if d.Contents == nil {
+				continue
+			}
+			cc.WriteFiles = append(cc.WriteFiles, writeFile{
+				Path:        path.Join(systemdUnitDir, u.Name+".d", d.Name),
+				Content:     *d.Contents,
+				Permissions: "0644",
+			})
+		}
+		switch {
+		case u.Mask != nil && *u.Mask:
+			mask = append(mask, u.Name)
+		case u.Enabled == nil:
+			// left as the image presets it
+		case *u.Enabled:
+			enable = append(enable, u.Name)
+		default:
+			disable = append(disable, u.Name)
+		}
+	}
+	cc.RunCmd = append(cc.RunCmd, []string{"systemctl", "daemon-reload"})
+	// units that do not exist on the image, e.g. zincati.service, fail on
+	// their own
+	for _, name := range mask {
+		cc.RunCmd = append(cc.RunCmd, []string{"systemctl", "mask", "--now", name})
+	}
+	for _, name := range disable {
+		cc.RunCmd = append(cc.RunCmd, []string{"systemctl", "disable", "--now", name})
+	}
+	for _, name := range enable {
+		cc.RunCmd = append(cc.RunCmd, []string{"systemctl", "enable", "--now", "--no-block", name})
+	}
+
+	b, err := yaml.Marshal(cc)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(cloudConfigHeader), b...), nil
+}
+
+// fileToWriteFile renders a file of the ignition config.  Only inline data
+// URL contents are supported since the guest cannot fetch remote ones before
+// it is provisioned.
+func fileToWriteFile(node ignition.Node, mode *int, r ignition.Resource, appendTo bool) (writeFile, error) {
+	var content []byte
+	if r.Source != nil {
+		data, err := decodeDataURL(*r.Source)
+		if err != nil {
+			return writeFile{}, fmt.Errorf("file %s: %w", node.Path, err)
+		}
+		content = data
+	}
+	if r.Compression != nil && *r.Compression != "" {
+		return writeFile{}, fmt.Errorf("file %s: compressed contents are not supported by cloud-init", node.Path)
+	}
+	wf := writeFile{
+		Path:     node.Path,
+		Content:  base64.StdEncoding.EncodeToString(content),
+		Encoding: "b64",
+		Owner:    nodeOwner(node),
+		Append:   appendTo,
+		// files may belong to the users created by cloud-init
+		Defer: true,
+	}
+	if mode != nil {
+		wf.Permissions = fmt.Sprintf("%#o", *mode)
+	}
+	return wf, nil
+}
+
+// decodeDataURL decodes the contents of an RFC 2397 data URL
+func decodeDataURL(source string) ([]byte, error) {
+	data, ok := strings.CutPrefix(source, "data:")
+	if !ok {
+		return nil, fmt.Errorf("only data URLs are supported, got %q", source)
+	}
+	mediaType, data, ok := strings.Cut(data, ",")
+	if !ok {
+		return nil, fmt.Errorf("invalid data URL %q", source)
+	}
+	if strings.HasSuffix(mediaType, ";base64") {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	decoded, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(decoded), nil
+}
+
+// nodeOwner returns the user:group owning a node, empty for root
+func nodeOwner(node ignition.Node) string {
+	usr := idOrName(node.User.ID, node.User.Name)
+	grp := idOrName(node.Group.ID, node.Group.Name)
+	switch {
+	case usr == "" && grp == "":
+		return ""
+	case usr == "":
+		usr = "root"
+	case grp == "":
+		grp = usr
+	}
+	return usr + ":" + grp
+}
+
+func idOrName(id *int, name *string) string {
+	if name != nil && *name != "" {
+		return *name
+	}
+	if id != nil {
+		return strconv.Itoa(*id)
+	}
+	return ""
+}
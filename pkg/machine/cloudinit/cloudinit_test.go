@@ -0,0 +1,76 @@
+package cloudinit
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/ignition"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestUserData(t *testing.T) {
+	dynamic := ignition.DynamicIgnition{
+		Name:    "core",
+		Key:     "ssh-ed25519 AAAA host",
+		UID:     1000,
+		VMName:  "test",
+		VMType:  define.QemuVirt,
+		Rootful: false,
+	}
+	require.NoError(t, dynamic.GenerateIgnitionConfig())
+	ready := "[Unit]\nDescription=ready\n"
+	dynamic.Cfg.Systemd.Units = append(dynamic.Cfg.Systemd.Units, ignition.Unit{
+		Enabled:  ignition.BoolToPtr(true),
+		Name:     "ready.service",
+		Contents: &ready,
+	})
+
+	b, err := UserData(dynamic.Cfg)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(b), cloudConfigHeader))
+
+	var cc cloudConfig
+	require.NoError(t, yaml.Unmarshal(b, &cc))
+
+	require.Len(t, cc.Users, 1)
+	assert.Equal(t, "core", cc.Users[0].Name)
+	assert.Equal(t, []string{"ssh-ed25519 AAAA host"}, cc.Users[0].SSHAuthorizedKeys)
+	assert.Contains(t, cc.Packages, "podman")
+
+	files := make(map[string]writeFile)
+	for _, wf := range cc.WriteFiles {
+		files[wf.Path] = wf
+	}
+	assert.Equal(t, "ssh-ed25519 AAAA host\n", files["/root/.ssh/authorized_keys"].Content)
+	assert.Equal(t, ready, files["/etc/systemd/system/ready.service"].Content)
+
+	conf, ok := files["/home/core/.config/containers/containers.conf"]
+	require.True(t, ok)
+	assert.Equal(t, "core:core", conf.Owner)
+	assert.True(t, conf.Defer)
+	content, err := base64.StdEncoding.DecodeString(conf.Content)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "[containers]")
+
+	// units are enabled once everything else is in place
+	last := cc.RunCmd[len(cc.RunCmd)-1]
+	assert.Equal(t, []string{"systemctl", "enable", "--now", "--no-block", "ready.service"}, last)
+	assert.Contains(t, cc.RunCmd, []string{"systemctl", "disable", "--now", "zincati.service"})
+}
+
+func TestDecodeDataURL(t *testing.T) {
+	data, err := decodeDataURL(*ignition.EncodeDataURLPtr("a b\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "a b\n", string(data))
+
+	data, err = decodeDataURL("data:text/plain;base64," + base64.StdEncoding.EncodeToString([]byte("hello")))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	_, err = decodeDataURL("https://example.com/file")
+	assert.Error(t, err)
+}
@@ -0,0 +1,40 @@
+package cloudinit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// seedLabel is the volume label cloud-init looks for on NoCloud seed disks
+const seedLabel = "cidata"
+
+// WriteSeed writes the NoCloud seed disk of a machine to path, an ISO 9660
+// image labeled cidata holding the user-data and the meta-data.  The image is
+// made by the ISO tools of the host.
+func WriteSeed(path string, userData, metaData []byte) error {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".seed")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string][]byte{
+		"user-data": userData,
+		"meta-data": metaData,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+			return err
+		}
+	}
+
+	cmd, err := seedCommand(dir, path)
+	if err != nil {
+		return err
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("creating cloud-init seed disk %s: %s: %w", path, out, err)
+	}
+	return nil
+}
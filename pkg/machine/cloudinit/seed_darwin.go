@@ -0,0 +1,8 @@
+package cloudinit
+
+import "os/exec"
+
+// seedCommand makes the seed disk with hdiutil, which every macOS ships
+func seedCommand(dir, path string) (*exec.Cmd, error) {
+	return exec.Command("hdiutil", "makehybrid", "-iso", "-joliet", "-default-volume-name", seedLabel, "-o", path, dir), nil
+}
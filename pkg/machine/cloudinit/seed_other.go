@@ -0,0 +1,30 @@
+//go:build !darwin
+
+package cloudinit
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// isoTools are the tools that can make an ISO image from a directory, with
+// the arguments that make them take mkisofs options
+var isoTools = [][]string{
+	{"xorriso", "-as", "mkisofs"},
+	{"genisoimage"},
+	{"mkisofs"},
+}
+
+// seedCommand makes the seed disk with the first ISO tool found in the path
+func seedCommand(dir, path string) (*exec.Cmd, error) {
+	for _, tool := range isoTools {
+		bin, err := exec.LookPath(tool[0])
+		if err != nil {
+			continue
+		}
+		args := append([]string{}, tool[1:]...)
+		args = append(args, "-output", path, "-volid", seedLabel, "-joliet", "-rock", dir)
+		return exec.Command(bin, args...), nil
+	}
+	return nil, errors.New("creating cloud-init seed disks needs one of xorriso, genisoimage or mkisofs")
+}
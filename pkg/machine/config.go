@@ -54,6 +54,7 @@ type ListResponse struct {
 	LastUp             time.Time
 	Running            bool
 	Starting           bool
+	State              define.Status
 	Stream             string
 	VMType             string
 	CPUs               uint64
@@ -63,6 +64,9 @@ type ListResponse struct {
 	RemoteUsername     string
 	IdentityPath       string
 	UserModeNetworking bool
+	LastError          *vmconfigs.OperationError
+	Health             define.Health
+	ConfigPath         string
 }
 
 type SSHOptions struct {
@@ -73,9 +77,64 @@ type SSHOptions struct {
 type StartOptions struct {
 	NoInfo bool
 	Quiet  bool
+	// Progress, if set, is called as each phase of the start begins and ends
+	Progress func(ProgressEvent)
+	// ReadyTimeout is how long to wait for the guest to report it is ready.
+	// DefaultReadyTimeout is used when it is not set.
+	ReadyTimeout time.Duration
+}
+
+// DefaultReadyTimeout is how long a start waits for the guest to report it is
+// ready by default
+const DefaultReadyTimeout = 5 * time.Minute
+
+// RecoveryOptions are the options for booting a broken machine for repair
+type RecoveryOptions struct {
+	// MaskUnits are systemd units that are not started in the guest
+	MaskUnits []string
 }
 
-type StopOptions struct{}
+type StopOptions struct {
+	// Hard stops the machine without a graceful shutdown
+	Hard bool
+	// NoHooks skips the guest and host hooks run as the machine stops
+	NoHooks bool
+	// Timeout is how long the guest may take to shut down before it is
+	// forced off.  DefaultStopTimeout is used when it is not set.
+	Timeout time.Duration
+}
+
+// DefaultStopTimeout is how long a stop waits for the guest to shut down by
+// default, the default stop timeout of systemd
+const DefaultStopTimeout = 90 * time.Second
+
+// StopMethod is how a machine was stopped
+type StopMethod string
+
+const (
+	// StopGraceful machines shut down on request
+	StopGraceful StopMethod = "graceful"
+	// StopForced machines did not shut down in time and were forced off
+	StopForced StopMethod = "forced"
+	// StopHard machines were forced off as requested
+	StopHard StopMethod = "hard"
+)
+
+// StopReport is the result of stopping a machine
+type StopReport struct {
+	// Method is how the machine was stopped
+	Method StopMethod
+	// Duration is how long the machine took to stop
+	Duration time.Duration
+}
+
+// Attributes returns the report in a form suitable for event attributes
+func (r *StopReport) Attributes() map[string]string {
+	return map[string]string{
+		"stop.method":   string(r.Method),
+		"stop.duration": r.Duration.String(),
+	}
+}
 
 type RemoveOptions struct {
 	Force        bool
@@ -113,10 +172,15 @@ type InspectInfo struct {
 	LastUp             time.Time
 	Name               string
 	Resources          vmconfigs.ResourceConfig
+	Network            vmconfigs.NetworkConfig
 	SSHConfig          vmconfigs.SSHConfig
 	State              define.Status
 	UserModeNetworking bool
 	Rootful            bool
+	LastError          *vmconfigs.OperationError `json:",omitempty"`
+	Degraded           string                    `json:",omitempty"`
+	// Stats is the resource usage of a running machine
+	Stats *vmconfigs.Stats `json:",omitempty"`
 }
 
 // GetCacheDir returns the dir where VM images are downloaded into when pulled
@@ -277,6 +341,12 @@ type ConnectionConfig struct {
 	PodmanSocket *define.VMFile `json:"PodmanSocket"`
 	// PodmanPipe is the exported podman service named pipe (Windows hosts only)
 	PodmanPipe *define.VMFile `json:"PodmanPipe"`
+	// SecondaryPodmanSocket is the exported podman service socket of the
+	// mode, rootful or rootless, the machine does not default to
+	SecondaryPodmanSocket *define.VMFile `json:"SecondaryPodmanSocket,omitempty"`
+	// SecondaryPodmanPipe is the exported podman service named pipe of the
+	// mode the machine does not default to (Windows hosts only)
+	SecondaryPodmanPipe *define.VMFile `json:"SecondaryPodmanPipe,omitempty"`
 }
 
 type APIForwardingState int
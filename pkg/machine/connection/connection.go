@@ -68,6 +68,46 @@ func ChangeConnectionURI(name string, uri fmt.Stringer) error {
 	})
 }
 
+// UpdateConnectionPairPort points the rootless and rootful connections of
+// the machine with the given name at a new SSH port.  Connections that do
+// not exist, e.g. because the machine was created from an ignition file, are
+// skipped.
+func UpdateConnectionPairPort(name string, port, uid int, remoteUsername string) error {
+	cons := connectionPair(uid, port, name, remoteUsername)
+	return config.EditConnectionConfig(func(cfg *config.ConnectionsFile) error {
+		for _, con := range cons {
+			dst, ok := cfg.Connection.Connections[con.name]
+			if !ok {
+				continue
+			}
+			dst.URI = con.uri.String()
+			cfg.Connection.Connections[con.name] = dst
+		}
+		return nil
+	})
+}
+
+// UpdateConnectionPairIdentity points the rootless and rootful connections
+// of the machine with the given name at a new SSH identity.  Both are changed
+// in one write of the connections file.  Connections that do not exist are
+// skipped.
+func UpdateConnectionPairIdentity(name, identity string) error {
+	if len(identity) < 1 {
+		return errors.New("identity must be defined")
+	}
+	return config.EditConnectionConfig(func(cfg *config.ConnectionsFile) error {
+		for _, conName := range []string{name, name + "-root"} {
+			dst, ok := cfg.Connection.Connections[conName]
+			if !ok {
+				continue
+			}
+			dst.Identity = identity
+			cfg.Connection.Connections[conName] = dst
+		}
+		return nil
+	})
+}
+
 // UpdateConnectionIfDefault updates the default connection to the rootful/rootless when depending
 // on the bool but only if other rootful/less connection was already the default.
 // Returns true if it modified the default
@@ -0,0 +1,46 @@
+package define
+
+// Capabilities tell which of the optional features of podman machine a
+// provider supports, so that front-ends can adapt to the provider instead
+// of trying the features out
+type Capabilities struct {
+	// CompactDisk is true when podman machine compact frees the unused
+	// space of the disk image
+	CompactDisk bool `json:"CompactDisk"`
+	// Console is true when podman machine console attaches to the serial
+	// console of the machine
+	Console bool `json:"Console"`
+	// ConvertDisk is true when the disk image can be converted to another
+	// format
+	ConvertDisk bool `json:"ConvertDisk"`
+	// Export is true when machines can be exported and imported
+	Export bool `json:"Export"`
+	// GPU is true when machines can be given the GPUs of the host
+	GPU bool `json:"GPU"`
+	// IgnitionVsock is true when the ignition config is served to the
+	// machine over vsock as it first boots rather than handed to the
+	// hypervisor when the machine is created, so that it can change until
+	// then
+	IgnitionVsock bool `json:"IgnitionVsock"`
+	// MultipleRunning is true when more than one machine can run at a time
+	MultipleRunning bool `json:"MultipleRunning"`
+	// Recovery is true when machines can be started in recovery mode
+	Recovery bool `json:"Recovery"`
+	// SecureBoot is true when machines can boot UEFI firmware that
+	// enforces secure boot
+	SecureBoot bool `json:"SecureBoot"`
+	// Snapshots is true when the disk of a machine can be snapshotted
+	Snapshots bool `json:"Snapshots"`
+	// Stats is true when podman machine stats reports the resource usage
+	// of the machine
+	Stats bool `json:"Stats"`
+	// TPM is true when machines can be given a TPM 2.0 device
+	TPM bool `json:"TPM"`
+	// USB is true when USB devices of the host can be passed to machines
+	USB bool `json:"USB"`
+	// VirtioFS is true when volumes are mounted with virtiofs rather than 9p
+	VirtioFS bool `json:"VirtioFS"`
+	// VolumeHotplug is true when volumes added to a running machine are
+	// mounted without a restart
+	VolumeHotplug bool `json:"VolumeHotplug"`
+}
@@ -3,6 +3,8 @@ package define
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/containers/common/pkg/strongunits"
 )
@@ -49,3 +51,47 @@ type ErrIncompatibleMachineConfig struct {
 func (err *ErrIncompatibleMachineConfig) Error() string {
 	return fmt.Sprintf("incompatible machine config %q (%s) for this version of Podman", err.Path, err.Name)
 }
+
+type ErrReadyTimeout struct {
+	Name    string
+	Timeout time.Duration
+	// Console holds the last lines of the console log of the machine, if any
+	Console []string
+}
+
+func (err *ErrReadyTimeout) Error() string {
+	msg := fmt.Sprintf("machine %q did not report ready within %s", err.Name, err.Timeout)
+	if len(err.Console) == 0 {
+		return msg
+	}
+	return msg + ", last console output:\n" + strings.Join(err.Console, "\n")
+}
+
+// ErrStartAfterInit is returned when a machine was initialized but failed to
+// start afterwards
+type ErrStartAfterInit struct {
+	Name string
+	Err  error
+}
+
+func (err *ErrStartAfterInit) Error() string {
+	return fmt.Sprintf("machine %q was initialized but failed to start: %v", err.Name, err.Err)
+}
+
+func (err *ErrStartAfterInit) Unwrap() error {
+	return err.Err
+}
+
+// ErrUSBPassthrough is returned when USB devices are given to a machine whose
+// hypervisor cannot pass the devices of the host through
+type ErrUSBPassthrough struct {
+	VMType VMType
+}
+
+func (err *ErrUSBPassthrough) Error() string {
+	return fmt.Sprintf("USB host passthrough is not supported for %s machines, only QEMU machines can pass USB devices through", err.VMType.String())
+}
+
+func (err *ErrUSBPassthrough) Unwrap() error {
+	return ErrNotImplemented
+}
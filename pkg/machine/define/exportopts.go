@@ -0,0 +1,27 @@
+package define
+
+// Compression algorithms of machine archives
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+type ExportOptions struct {
+	// Compression of the archive: CompressionNone, CompressionGzip or
+	// CompressionZstd, empty for CompressionZstd
+	Compression string
+	// ExcludeCache leaves out the disk images the machine only keeps to go
+	// back in time: the image replaced by the last os upgrade and the
+	// snapshots kept apart from the disk image
+	ExcludeCache bool
+}
+
+type ImportOptions struct {
+	// Name of the imported machine, the name of the exported machine when
+	// empty
+	Name string
+	// Logger receives the log messages about the imported machine, the
+	// standard logrus logger when nil
+	Logger Logger
+}
@@ -0,0 +1,21 @@
+package define
+
+// FirmwareConfig selects the firmware of a machine and the security devices
+// it sees, to run workloads that rely on a measured or verified boot
+type FirmwareConfig struct {
+	// SecureBoot boots the machine with UEFI firmware that enforces secure
+	// boot, with the Microsoft keys enrolled
+	SecureBoot bool `json:",omitempty"`
+	// TPM gives the machine an emulated TPM 2.0 device, whose state is kept
+	// with the machine
+	TPM bool `json:",omitempty"`
+}
+
+// NewFirmwareConfig returns the firmware configuration of a machine, nil
+// when it boots the default firmware of its provider without a TPM
+func NewFirmwareConfig(secureBoot, tpm bool) *FirmwareConfig {
+	if !secureBoot && !tpm {
+		return nil
+	}
+	return &FirmwareConfig{SecureBoot: secureBoot, TPM: tpm}
+}
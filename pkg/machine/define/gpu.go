@@ -0,0 +1,68 @@
+package define
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// AllGPUs gives a machine every GPU its provider can share
+const AllGPUs = "all"
+
+// pciAddressRegexp matches PCI addresses with or without their domain, e.g.
+// 0000:01:00.0 or 01:00.0
+var pciAddressRegexp = regexp.MustCompile(`^(?:[0-9a-f]{4}:)?[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]$`)
+
+// GPUConfig gives a machine access to the GPUs of the host
+type GPUConfig struct {
+	// All gives the machine every GPU its provider can share: the GPUs
+	// bound to vfio-pci for QEMU and the GPU-PV devices for WSL
+	All bool `json:",omitempty"`
+	// Devices are the PCI addresses of the host GPUs passed through with
+	// VFIO
+	Devices []string `json:",omitempty"`
+}
+
+func (g *GPUConfig) String() string {
+	if g.All {
+		return AllGPUs
+	}
+	return strings.Join(g.Devices, ",")
+}
+
+// ParseGPUs parses the GPUs given on the command line, either "all" or PCI
+// addresses as printed by lspci.  It returns nil when no GPU is given.
+func ParseGPUs(inputs []string) (*GPUConfig, error) {
+	var gpus GPUConfig
+	for _, input := range inputs {
+		for _, gpu := range strings.Split(input, ",") {
+			gpu = strings.ToLower(strings.TrimSpace(gpu))
+			switch {
+			case gpu == "":
+			case gpu == AllGPUs:
+				gpus.All = true
+			case pciAddressRegexp.MatchString(gpu):
+				if strings.Count(gpu, ":") == 1 {
+					// lspci leaves out the default domain
+					gpu = "0000:" + gpu
+				}
+				if slices.Contains(gpus.Devices, gpu) {
+					return nil, fmt.Errorf("gpus: %s is given twice", gpu)
+				}
+				gpus.Devices = append(gpus.Devices, gpu)
+			default:
+				return nil, fmt.Errorf("gpus: %q is neither %q nor a PCI address like 0000:01:00.0", gpu, AllGPUs)
+			}
+		}
+	}
+	if gpus.All && len(gpus.Devices) > 0 {
+		return nil, errors.New("gpus: all cannot be combined with PCI addresses")
+	}
+	if !gpus.All && len(gpus.Devices) == 0 {
+		return nil, nil
+	}
+	return &gpus, nil
+}
@@ -0,0 +1,57 @@
+package define
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGPUs(t *testing.T) {
+	tests := []struct {
+		name    string
+		inputs  []string
+		want    *GPUConfig
+		wantErr bool
+	}{
+		{
+			name:   "none",
+			inputs: []string{""},
+			want:   nil,
+		},
+		{
+			name:   "all",
+			inputs: []string{"all"},
+			want:   &GPUConfig{All: true},
+		},
+		{
+			name:   "PCI addresses with and without domain",
+			inputs: []string{"0000:01:00.0,03:00.1", "0001:0A:00.0"},
+			want:   &GPUConfig{Devices: []string{"0000:01:00.0", "0000:03:00.1", "0001:0a:00.0"}},
+		},
+		{
+			name:    "all with PCI addresses",
+			inputs:  []string{"all", "01:00.0"},
+			wantErr: true,
+		},
+		{
+			name:    "address given twice",
+			inputs:  []string{"01:00.0", "0000:01:00.0"},
+			wantErr: true,
+		},
+		{
+			name:    "not an address",
+			inputs:  []string{"nvidia"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGPUs(tt.inputs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseGPUs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseGPUs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
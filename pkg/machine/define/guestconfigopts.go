@@ -0,0 +1,13 @@
+package define
+
+// GuestConfigOptions are the host files copied into the guest to configure
+// the container tools running there.  A nil file is left as it is and an
+// empty path removes the file from the guest.
+type GuestConfigOptions struct {
+	// ContainersConf is a containers.conf fragment
+	ContainersConf *string
+	// RegistriesConf is a registries.conf fragment, e.g. with mirrors
+	RegistriesConf *string
+	// SignaturePolicy replaces the policy.json of the guest
+	SignaturePolicy *string
+}
@@ -0,0 +1,10 @@
+package define
+
+// HostHookOptions are the commands run on the host at each stage of the
+// machine lifecycle.  A nil stage is left as it is.
+type HostHookOptions struct {
+	PreStart  *[]string
+	PostStart *[]string
+	PreStop   *[]string
+	PostStop  *[]string
+}
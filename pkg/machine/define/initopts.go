@@ -20,4 +20,44 @@ type InitOptions struct {
 	UID                string // uid of the user that called machine
 	UserModeNetworking *bool  // nil = use backend/system default, false = disable, true = enable
 	USBs               []string
+	PreStopHooks       []string
+	PostStartHooks     []string
+	FirstBootHooks     []string
+	// GPUs are "all" or the PCI addresses of the host GPUs the machine
+	// can use
+	GPUs []string
+	// Ports are published on the host in the
+	// [ip:][hostPort:]guestPort[/protocol] form
+	Ports []string
+	// WSLNetworking is the networking mode WSL must use while the machine
+	// runs, "nat" or "mirrored".  Empty leaves WSL as it is configured.
+	WSLNetworking string
+	// HostHooks are run on the host as the machine starts and stops
+	HostHooks HostHookOptions
+	// RestartPolicy tells what is restarted when the running machine
+	// fails, empty for RestartNo
+	RestartPolicy string
+	// ClockSync keeps the clock of the running machine in sync with the
+	// host
+	ClockSync bool
+	// StartAfterInit starts the machine once it is initialized
+	StartAfterInit bool
+	// InitScripts are run as root on the first boot of the machine, in
+	// order
+	InitScripts []string
+	// Units are systemd unit files installed in the machine
+	Units []string
+	// Provisioner configures the machine on its first boot, empty picks
+	// it from the image
+	Provisioner Provisioner
+	// SecureBoot boots the machine with UEFI firmware enforcing secure boot
+	SecureBoot bool
+	// TPM gives the machine an emulated TPM 2.0 device
+	TPM bool
+	// GuestConfig are the host files configuring the container tools of
+	// the guest
+	GuestConfig GuestConfigOptions
+	// Logger receives the log messages about the machine, the standard
+	// logrus logger when nil
+	Logger Logger
 }
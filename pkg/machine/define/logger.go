@@ -0,0 +1,21 @@
+package define
+
+import "github.com/sirupsen/logrus"
+
+// Logger receives the log messages about a machine.  logrus.FieldLogger
+// satisfies it, so an embedder can attach its own fields, e.g. the name of
+// the machine, to every message.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// LoggerOrStandard returns l, or the standard logrus logger when l is nil
+func LoggerOrStandard(l Logger) Logger {
+	if l == nil {
+		return logrus.StandardLogger()
+	}
+	return l
+}
@@ -0,0 +1,16 @@
+package define
+
+// LogsOptions select the entries of the journal of a machine that podman
+// machine logs shows
+type LogsOptions struct {
+	// Follow keeps showing new entries as they are logged
+	Follow bool
+	// Since only shows the entries logged since the given time, in any
+	// format journalctl accepts
+	Since string
+	// Tail only shows the given number of last entries, all of them when
+	// negative
+	Tail int
+	// Unit only shows the entries of the given systemd unit
+	Unit string
+}
@@ -0,0 +1,165 @@
+package define
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// GuestIP is the address gvproxy leases to the machine
+	GuestIP = "192.168.127.2"
+	// GuestSubnet is the network gvproxy puts the machine in
+	GuestSubnet = "192.168.127.0/24"
+)
+
+// PortForward publishes a port of the machine on the host
+type PortForward struct {
+	// HostIP is the host address the port is published on, empty for all
+	// addresses
+	HostIP string `json:",omitempty"`
+	// HostPort is the port published on the host
+	HostPort uint16
+	// GuestPort is the port of the machine the host port is forwarded to
+	GuestPort uint16
+	// Protocol is tcp or udp
+	Protocol string
+}
+
+// String returns the port forward in the [ip:]hostPort:guestPort/protocol
+// form it is given on the command line
+func (p PortForward) String() string {
+	s := fmt.Sprintf("%d:%d/%s", p.HostPort, p.GuestPort, p.Protocol)
+	if p.HostIP == "" {
+		return s
+	}
+	if strings.Contains(p.HostIP, ":") {
+		return "[" + p.HostIP + "]:" + s
+	}
+	return p.HostIP + ":" + s
+}
+
+// HostAddress is the host address the port is published on
+func (p PortForward) HostAddress() string {
+	return net.JoinHostPort(p.HostIP, strconv.Itoa(int(p.HostPort)))
+}
+
+// GuestAddress is the address of the machine the port is forwarded to
+func (p PortForward) GuestAddress() string {
+	return net.JoinHostPort(GuestIP, strconv.Itoa(int(p.GuestPort)))
+}
+
+// ParsePortForwards parses the ports given on the command line in the
+// [ip:][hostPort:]guestPort[/protocol] form.  The host port defaults to the
+// guest port and the protocol to tcp.
+func ParsePortForwards(inputs []string) ([]PortForward, error) {
+	forwards := make([]PortForward, 0, len(inputs))
+	for _, input := range inputs {
+		if input == "" {
+			// --publish="" resets the published ports
+			continue
+		}
+		forward, err := parsePortForward(input)
+		if err != nil {
+			return nil, fmt.Errorf("publish %q: %w", input, err)
+		}
+		for _, f := range forwards {
+			if f.HostPort == forward.HostPort && f.Protocol == forward.Protocol && f.HostIP == forward.HostIP {
+				return nil, fmt.Errorf("publish %q: host port %d/%s is already published", input, forward.HostPort, forward.Protocol)
+			}
+		}
+		forwards = append(forwards, forward)
+	}
+	return forwards, nil
+}
+
+func parsePortForward(input string) (PortForward, error) {
+	forward := PortForward{Protocol: "tcp"}
+
+	spec, protocol, hasProtocol := strings.Cut(input, "/")
+	if hasProtocol {
+		switch protocol {
+		case "tcp", "udp":
+			forward.Protocol = protocol
+		default:
+			return forward, fmt.Errorf("protocol %q must be tcp or udp", protocol)
+		}
+	}
+
+	// an IPv6 host address is given in brackets
+	if strings.HasPrefix(spec, "[") {
+		end := strings.Index(spec, "]:")
+		if end < 0 {
+			return forward, fmt.Errorf("%q is not a valid host address", spec)
+		}
+		forward.HostIP = spec[1:end]
+		if ip := net.ParseIP(forward.HostIP); ip == nil || ip.To4() != nil {
+			return forward, fmt.Errorf("%q is not a valid IPv6 address", forward.HostIP)
+		}
+		spec = spec[end+2:]
+	}
+
+	fields := strings.Split(spec, ":")
+	switch len(fields) {
+	case 1:
+		fields = []string{fields[0], fields[0]}
+	case 2:
+	case 3:
+		if forward.HostIP != "" {
+			return forward, fmt.Errorf("%q is not a valid port forward", input)
+		}
+		if net.ParseIP(fields[0]) == nil {
+			return forward, fmt.Errorf("%q is not a valid IP address", fields[0])
+		}
+		forward.HostIP = fields[0]
+		fields = fields[1:]
+	default:
+		return forward, fmt.Errorf("%q is not a valid port forward", input)
+	}
+
+	hostPort, err := parsePort(fields[0])
+	if err != nil {
+		return forward, err
+	}
+	guestPort, err := parsePort(fields[1])
+	if err != nil {
+		return forward, err
+	}
+	forward.HostPort = hostPort
+	forward.GuestPort = guestPort
+	return forward, nil
+}
+
+func parsePort(s string) (uint16, error) {
+	port, err := strconv.ParseUint(s, 10, 16)
+	if err != nil || port == 0 {
+		return 0, fmt.Errorf("%q is not a valid port", s)
+	}
+	return uint16(port), nil
+}
+
+// WSLNetworkingMode is the networking mode of WSL.  WSL sets it for all the
+// distributions in the .wslconfig file of the Windows user.
+type WSLNetworkingMode string
+
+const (
+	// WSLNetworkingNAT puts the distributions behind a NAT and relays the
+	// ports listening in them to localhost of the host, the WSL default
+	WSLNetworkingNAT WSLNetworkingMode = "nat"
+	// WSLNetworkingMirrored mirrors the network interfaces of the host in
+	// the distributions, which makes the ports listening in them reachable
+	// on every address of the host.  It requires Windows 11 22H2.
+	WSLNetworkingMirrored WSLNetworkingMode = "mirrored"
+)
+
+// ParseWSLNetworkingMode parses the WSL networking mode given on the command
+// line.  An empty mode leaves WSL as it is configured.
+func ParseWSLNetworkingMode(s string) (WSLNetworkingMode, error) {
+	switch mode := WSLNetworkingMode(s); mode {
+	case "", WSLNetworkingNAT, WSLNetworkingMirrored:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("WSL networking mode %q must be %s or %s", s, WSLNetworkingNAT, WSLNetworkingMirrored)
+	}
+}
@@ -0,0 +1,136 @@
+package define
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortForwards(t *testing.T) {
+	tests := []struct {
+		name    string
+		inputs  []string
+		want    []PortForward
+		wantErr bool
+	}{
+		{
+			name:   "none",
+			inputs: []string{""},
+			want:   []PortForward{},
+		},
+		{
+			name:   "guest port only",
+			inputs: []string{"8080"},
+			want:   []PortForward{{HostPort: 8080, GuestPort: 8080, Protocol: "tcp"}},
+		},
+		{
+			name:   "host and guest ports",
+			inputs: []string{"80:8080", "5353:53/udp"},
+			want: []PortForward{
+				{HostPort: 80, GuestPort: 8080, Protocol: "tcp"},
+				{HostPort: 5353, GuestPort: 53, Protocol: "udp"},
+			},
+		},
+		{
+			name:   "host addresses",
+			inputs: []string{"127.0.0.1:80:8080", "[::1]:80:8080/tcp", "[::1]:9090"},
+			want: []PortForward{
+				{HostIP: "127.0.0.1", HostPort: 80, GuestPort: 8080, Protocol: "tcp"},
+				{HostIP: "::1", HostPort: 80, GuestPort: 8080, Protocol: "tcp"},
+				{HostIP: "::1", HostPort: 9090, GuestPort: 9090, Protocol: "tcp"},
+			},
+		},
+		{
+			name:   "same host port for tcp and udp",
+			inputs: []string{"53/tcp", "53/udp"},
+			want: []PortForward{
+				{HostPort: 53, GuestPort: 53, Protocol: "tcp"},
+				{HostPort: 53, GuestPort: 53, Protocol: "udp"},
+			},
+		},
+		{
+			name:    "host port given twice",
+			inputs:  []string{"80:8080", "80:9090"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown protocol",
+			inputs:  []string{"80/sctp"},
+			wantErr: true,
+		},
+		{
+			name:    "port out of range",
+			inputs:  []string{"70000"},
+			wantErr: true,
+		},
+		{
+			name:    "port zero",
+			inputs:  []string{"0:80"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid host address",
+			inputs:  []string{"localhost:80:8080"},
+			wantErr: true,
+		},
+		{
+			name:    "IPv6 address without brackets",
+			inputs:  []string{"::1:80:8080"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePortForwards(tt.inputs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePortForwards() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePortForwards() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPortForwardString(t *testing.T) {
+	tests := []struct {
+		forward PortForward
+		want    string
+	}{
+		{PortForward{HostPort: 80, GuestPort: 8080, Protocol: "tcp"}, "80:8080/tcp"},
+		{PortForward{HostIP: "127.0.0.1", HostPort: 53, GuestPort: 53, Protocol: "udp"}, "127.0.0.1:53:53/udp"},
+		{PortForward{HostIP: "::1", HostPort: 80, GuestPort: 80, Protocol: "tcp"}, "[::1]:80:80/tcp"},
+	}
+	for _, tt := range tests {
+		if got := tt.forward.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+		// the string form is parsed back to the same forward
+		parsed, err := ParsePortForwards([]string{tt.want})
+		if err != nil || !reflect.DeepEqual(parsed, []PortForward{tt.forward}) {
+			t.Errorf("ParsePortForwards(%q) = %v, %v", tt.want, parsed, err)
+		}
+	}
+}
+
+func TestParseWSLNetworkingMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    WSLNetworkingMode
+		wantErr bool
+	}{
+		{input: "", want: ""},
+		{input: "nat", want: WSLNetworkingNAT},
+		{input: "mirrored", want: WSLNetworkingMirrored},
+		{input: "bridged", wantErr: true},
+		{input: "Mirrored", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWSLNetworkingMode(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseWSLNetworkingMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseWSLNetworkingMode(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package define
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Provisioner is what configures a machine on its first boot
+type Provisioner string
+
+const (
+	// IgnitionProvisioner configures Fedora CoreOS images from an ignition
+	// file
+	IgnitionProvisioner Provisioner = "ignition"
+	// CloudInitProvisioner configures distribution cloud images, e.g. the
+	// Ubuntu and Debian ones, from a NoCloud seed disk
+	CloudInitProvisioner Provisioner = "cloud-init"
+)
+
+// Provisioners lists the valid provisioners
+var Provisioners = []Provisioner{IgnitionProvisioner, CloudInitProvisioner}
+
+func (p Provisioner) String() string {
+	if p == "" {
+		// machines created before the provisioner was recorded
+		return string(IgnitionProvisioner)
+	}
+	return string(p)
+}
+
+// ParseProvisioner parses the name of a provisioner.  An empty input returns
+// an empty provisioner, which lets the provisioner be picked from the image.
+func ParseProvisioner(input string) (Provisioner, error) {
+	switch p := Provisioner(strings.TrimSpace(strings.ToLower(input))); p {
+	case IgnitionProvisioner, CloudInitProvisioner, "":
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown provisioner %q, must be one of %q", input, Provisioners)
+	}
+}
@@ -0,0 +1,9 @@
+package define
+
+// RecoveryOverride describes how a provider boots a machine for repair
+type RecoveryOverride struct {
+	// Target is the systemd target the guest isolates as it boots
+	Target string
+	// MaskUnits are systemd units that are masked for the boot
+	MaskUnits []string
+}
@@ -0,0 +1,50 @@
+package define
+
+import "fmt"
+
+// RestartPolicy tells what the monitor of a running machine restarts when it
+// fails
+type RestartPolicy string
+
+const (
+	// RestartNo does not monitor the machine
+	RestartNo RestartPolicy = "no"
+	// RestartNetwork restarts gvproxy when it exits while the machine runs
+	RestartNetwork RestartPolicy = "network"
+	// RestartAlways restarts gvproxy as RestartNetwork does, and the
+	// machine when it stops without podman machine stop or cannot be
+	// reached once gvproxy is restarted
+	RestartAlways RestartPolicy = "always"
+)
+
+// ParseRestartPolicy parses the restart policy given on the command line
+func ParseRestartPolicy(s string) (RestartPolicy, error) {
+	switch policy := RestartPolicy(s); policy {
+	case RestartNo, RestartNetwork, RestartAlways:
+		return policy, nil
+	case "":
+		return RestartNo, nil
+	default:
+		return "", fmt.Errorf("restart policy %q must be %s, %s or %s", s, RestartNo, RestartNetwork, RestartAlways)
+	}
+}
+
+// Monitored reports whether machines with the policy are monitored
+func (p RestartPolicy) Monitored() bool {
+	return p == RestartNetwork || p == RestartAlways
+}
+
+// Health describes whether a machine can be used
+type Health string
+
+const (
+	// HealthStopped is the health of a machine that is not running
+	HealthStopped Health = "stopped"
+	// HealthStarting is the health of a machine that is booting
+	HealthStarting Health = "starting"
+	// HealthHealthy is the health of a running machine that is reachable
+	HealthHealthy Health = "healthy"
+	// HealthUnhealthy is the health of a running machine that cannot be
+	// reached, e.g. because gvproxy exited
+	HealthUnhealthy Health = "unhealthy"
+)
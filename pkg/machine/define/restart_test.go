@@ -0,0 +1,30 @@
+package define
+
+import "testing"
+
+func TestParseRestartPolicy(t *testing.T) {
+	tests := []struct {
+		input     string
+		want      RestartPolicy
+		monitored bool
+		wantErr   bool
+	}{
+		{input: "", want: RestartNo},
+		{input: "no", want: RestartNo},
+		{input: "network", want: RestartNetwork, monitored: true},
+		{input: "always", want: RestartAlways, monitored: true},
+		{input: "on-failure", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRestartPolicy(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseRestartPolicy(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseRestartPolicy(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+		if got.Monitored() != tt.monitored {
+			t.Errorf("%q.Monitored() = %v, want %v", got, got.Monitored(), tt.monitored)
+		}
+	}
+}
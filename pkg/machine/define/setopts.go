@@ -9,4 +9,11 @@ type SetOptions struct {
 	Rootful            *bool
 	UserModeNetworking *bool
 	USBs               *[]string
+	Ports              *[]string
+	WSLNetworking      *string
+	RestartPolicy      *string
+	ClockSync          *bool
+	PreStopHooks       *[]string
+	HostHooks          HostHookOptions
+	GuestConfig        GuestConfigOptions
 }
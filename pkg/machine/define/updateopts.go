@@ -0,0 +1,11 @@
+package define
+
+// UpdateConfigOptions are the inputs used to regenerate the ignition of an
+// existing machine
+type UpdateConfigOptions struct {
+	// TimeZone is "local" or a time zone like "America/Chicago".  An empty
+	// value keeps the current time zone.
+	TimeZone string
+	// SSHKeys are public keys authorized in addition to the machine key
+	SSHKeys []string
+}
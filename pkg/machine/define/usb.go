@@ -6,6 +6,8 @@ import (
 	"strings"
 )
 
+// USBConfig is a USB device of the host passed through to the machine, by
+// bus and device number or by vendor and product ID
 type USBConfig struct {
 	Bus       string
 	DevNumber string
@@ -13,6 +15,18 @@ type USBConfig struct {
 	Product   int
 }
 
+// String returns the device in the vendor:product form printed by lsusb, or
+// as bus and device number
+func (u USBConfig) String() string {
+	if u.Bus != "" && u.DevNumber != "" {
+		return fmt.Sprintf("bus=%s,devnum=%s", u.Bus, u.DevNumber)
+	}
+	return fmt.Sprintf("%04x:%04x", u.Vendor, u.Product)
+}
+
+// ParseUSBs parses the USB devices given on the command line as
+// bus=N,devnum=M, vendor=V,product=P or V:P, the IDs being hexadecimal as
+// printed by lsusb
 func ParseUSBs(usbs []string) ([]USBConfig, error) {
 	configs := []USBConfig{}
 	for _, str := range usbs {
@@ -21,57 +35,79 @@ func ParseUSBs(usbs []string) ([]USBConfig, error) {
 			continue
 		}
 
-		vals := strings.Split(str, ",")
-		if len(vals) != 2 {
-			return configs, fmt.Errorf("usb: fail to parse: missing ',': %s", str)
+		config, err := parseUSB(str)
+		if err != nil {
+			return configs, err
 		}
-
-		left := strings.Split(vals[0], "=")
-		if len(left) != 2 {
-			return configs, fmt.Errorf("usb: fail to parse: missing '=': %s", str)
+		for _, c := range configs {
+			if c == config {
+				return configs, fmt.Errorf("usb: device %s is given twice", str)
+			}
 		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
 
-		right := strings.Split(vals[1], "=")
-		if len(right) != 2 {
-			return configs, fmt.Errorf("usb: fail to parse: missing '=': %s", str)
-		}
+func parseUSB(str string) (USBConfig, error) {
+	if vendorStr, productStr, found := strings.Cut(str, ":"); found && !strings.Contains(str, "=") {
+		return parseUSBIDs(str, vendorStr, productStr)
+	}
 
-		option := left[0] + "_" + right[0]
+	vals := strings.Split(str, ",")
+	if len(vals) != 2 {
+		return USBConfig{}, fmt.Errorf("usb: fail to parse: missing ',': %s", str)
+	}
 
-		switch option {
-		case "bus_devnum", "devnum_bus":
-			bus, devnumber := left[1], right[1]
-			if right[0] == "bus" {
-				bus, devnumber = devnumber, bus
-			}
+	left := strings.Split(vals[0], "=")
+	if len(left) != 2 {
+		return USBConfig{}, fmt.Errorf("usb: fail to parse: missing '=': %s", str)
+	}
 
-			configs = append(configs, USBConfig{
-				Bus:       bus,
-				DevNumber: devnumber,
-			})
-		case "vendor_product", "product_vendor":
-			vendorStr, productStr := left[1], right[1]
-			if right[0] == "vendor" {
-				vendorStr, productStr = productStr, vendorStr
-			}
+	right := strings.Split(vals[1], "=")
+	if len(right) != 2 {
+		return USBConfig{}, fmt.Errorf("usb: fail to parse: missing '=': %s", str)
+	}
 
-			vendor, err := strconv.ParseInt(vendorStr, 16, 0)
-			if err != nil {
-				return configs, fmt.Errorf("usb: fail to convert vendor of %s: %s", str, err)
-			}
+	option := left[0] + "_" + right[0]
 
-			product, err := strconv.ParseInt(productStr, 16, 0)
-			if err != nil {
-				return configs, fmt.Errorf("usb: fail to convert product of %s: %s", str, err)
-			}
+	switch option {
+	case "bus_devnum", "devnum_bus":
+		bus, devnumber := left[1], right[1]
+		if right[0] == "bus" {
+			bus, devnumber = devnumber, bus
+		}
 
-			configs = append(configs, USBConfig{
-				Vendor:  int(vendor),
-				Product: int(product),
-			})
-		default:
-			return configs, fmt.Errorf("usb: fail to parse: %s", str)
+		return USBConfig{
+			Bus:       bus,
+			DevNumber: devnumber,
+		}, nil
+	case "vendor_product", "product_vendor":
+		vendorStr, productStr := left[1], right[1]
+		if right[0] == "vendor" {
+			vendorStr, productStr = productStr, vendorStr
 		}
+		return parseUSBIDs(str, vendorStr, productStr)
+	default:
+		return USBConfig{}, fmt.Errorf("usb: fail to parse: %s", str)
 	}
-	return configs, nil
+}
+
+// parseUSBIDs parses the hexadecimal vendor and product IDs of a device,
+// with or without a 0x prefix
+func parseUSBIDs(str, vendorStr, productStr string) (USBConfig, error) {
+	vendor, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(vendorStr), "0x"), 16, 16)
+	if err != nil {
+		return USBConfig{}, fmt.Errorf("usb: fail to convert vendor of %s: %s", str, err)
+	}
+
+	product, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(productStr), "0x"), 16, 16)
+	if err != nil {
+		return USBConfig{}, fmt.Errorf("usb: fail to convert product of %s: %s", str, err)
+	}
+
+	return USBConfig{
+		Vendor:  int(vendor),
+		Product: int(product),
+	}, nil
 }
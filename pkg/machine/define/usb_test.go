@@ -0,0 +1,40 @@
+package define
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUSBConfig_String(t *testing.T) {
+	tests := []struct {
+		name string
+		usb  USBConfig
+		want string
+	}{
+		{
+			name: "vendor and product",
+			usb:  USBConfig{Vendor: 0x1050, Product: 0x407},
+			want: "1050:0407",
+		},
+		{
+			name: "bus and device number",
+			usb:  USBConfig{Bus: "1", DevNumber: "3"},
+			want: "bus=1,devnum=3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.usb.String(); got != tt.want {
+				t.Errorf("String() = %v, want %v", got, tt.want)
+			}
+			// the string form parses back to the same device
+			parsed, err := ParseUSBs([]string{tt.usb.String()})
+			if err != nil {
+				t.Fatalf("ParseUSBs() error = %v", err)
+			}
+			if !reflect.DeepEqual(parsed, []USBConfig{tt.usb}) {
+				t.Errorf("ParseUSBs() = %v, want %v", parsed, []USBConfig{tt.usb})
+			}
+		})
+	}
+}
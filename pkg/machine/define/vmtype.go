@@ -48,6 +48,25 @@ func (v VMType) ImageFormat() ImageFormat {
 	return Qcow
 }
 
+// reservedMountTargets are guest paths that volumes must not be mounted on,
+// neither directly nor by mounting over one of their parents
+var reservedMountTargets = []string{
+	"/", "/bin", "/boot", "/dev", "/etc", "/lib", "/lib64", "/proc", "/run",
+	"/sbin", "/sys", "/usr", "/var/lib/containers",
+}
+
+// ReservedMountTargets returns the guest paths that volumes of machines of
+// this type must not be mounted on
+func (v VMType) ReservedMountTargets() []string {
+	switch v {
+	case WSLVirt:
+		// WSL mounts the Windows drives and its own tooling
+		return append(reservedMountTargets, "/init", "/mnt/wsl", "/usr/lib/wsl")
+	}
+	// Fedora CoreOS keeps its deployments here
+	return append(reservedMountTargets, "/ostree", "/sysroot")
+}
+
 func ParseVMType(input string, emptyFallback VMType) (VMType, error) {
 	switch strings.TrimSpace(strings.ToLower(input)) {
 	case qemu:
@@ -0,0 +1,183 @@
+// Package diskcache keeps the downloaded machine disk images in the image
+// cache directory under the digest of their content, so that a disk image is
+// only downloaded once whatever source it comes from.
+package diskcache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// Cache is a directory of disk images named after their digest
+type Cache struct {
+	dir *define.VMFile
+}
+
+// New returns the cache stored in dir, usually the ImageCacheDir of the
+// machine directories
+func New(dir *define.VMFile) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Path returns where the disk image with the given digest is cached.  The
+// suffix keeps the disk type and compression of the image, e.g. ".qcow2.xz",
+// which decompression relies on.
+func (c *Cache) Path(d digest.Digest, suffix string) (*define.VMFile, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return c.dir.AppendToNewVMFile(d.Encoded()+suffix, nil)
+}
+
+// Lookup returns the cached disk image with the given digest and whether it
+// is in the cache
+func (c *Cache) Lookup(d digest.Digest, suffix string) (*define.VMFile, bool, error) {
+	path, err := c.Path(d, suffix)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := os.Stat(path.GetPath()); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return path, false, nil
+		}
+		return nil, false, err
+	}
+	logrus.Debugf("disk image %s found in the cache at %s", d, path.GetPath())
+	return path, true, nil
+}
+
+// Store copies the disk image read from r into the cache.  If expected is
+// set the content must match it, otherwise the image is stored under the
+// canonical digest of its content.  The cached image and its digest are
+// returned.
+func (c *Cache) Store(r io.Reader, expected digest.Digest, suffix string) (_ *define.VMFile, _ digest.Digest, retErr error) {
+	algorithm := digest.Canonical
+	if expected != "" {
+		if err := expected.Validate(); err != nil {
+			return nil, "", err
+		}
+		algorithm = expected.Algorithm()
+	}
+
+	tmp, err := os.CreateTemp(c.dir.GetPath(), ".download-*")
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		if retErr != nil {
+			tmp.Close()
+			if err := os.Remove(tmp.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+				logrus.Warnf("removing partial download %s: %v", tmp.Name(), err)
+			}
+		}
+	}()
+
+	digester := algorithm.Digester()
+	if _, err := io.Copy(io.MultiWriter(tmp, digester.Hash()), r); err != nil {
+		return nil, "", err
+	}
+	actual := digester.Digest()
+	if expected != "" && actual != expected {
+		return nil, "", fmt.Errorf("disk image digest mismatch: expected %s, got %s", expected, actual)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, "", err
+	}
+
+	path, err := c.Path(actual, suffix)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := os.Rename(tmp.Name(), path.GetPath()); err != nil {
+		return nil, "", err
+	}
+	return path, actual, nil
+}
+
+// PartialPath returns where an interrupted download identified by key is
+// kept, so that it can be resumed rather than started over
+func (c *Cache) PartialPath(key string) (*define.VMFile, error) {
+	return c.dir.AppendToNewVMFile(".partial-"+key, nil)
+}
+
+// StoreFile moves the disk image at path, usually a completed download, into
+// the cache.  If expected is set the content must match it, otherwise the
+// image is stored under the canonical digest of its content.  The file is
+// removed when it does not match, since resuming its download would only
+// produce the same bad image.
+func (c *Cache) StoreFile(path string, expected digest.Digest, suffix string) (_ *define.VMFile, _ digest.Digest, retErr error) {
+	algorithm := digest.Canonical
+	if expected != "" {
+		if err := expected.Validate(); err != nil {
+			return nil, "", err
+		}
+		algorithm = expected.Algorithm()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	digester := algorithm.Digester()
+	_, err = io.Copy(digester.Hash(), f)
+	f.Close()
+	if err != nil {
+		return nil, "", err
+	}
+	actual := digester.Digest()
+	if expected != "" && actual != expected {
+		if err := os.Remove(path); err != nil {
+			logrus.Warnf("removing download %s: %v", path, err)
+		}
+		return nil, "", fmt.Errorf("disk image digest mismatch: expected %s, got %s", expected, actual)
+	}
+
+	cached, err := c.Path(actual, suffix)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := os.Rename(path, cached.GetPath()); err != nil {
+		return nil, "", err
+	}
+	return cached, actual, nil
+}
+
+// KindAndCompression extracts the vmimage type and the compression type
+// from the name of a disk image, to suffix the cached file with them
+// i.e. fedora-coreos-39.20240128.2.2-qemu.x86_64.qcow2.xz would return .qcow2.xz
+func KindAndCompression(name string) string {
+	compressAlgo := filepath.Ext(name)
+	compressStrippedName := strings.TrimSuffix(name, compressAlgo)
+	kind := filepath.Ext(compressStrippedName)
+	return kind + compressAlgo
+}
+
+// Verify checks that the content of the file at path matches the expected
+// digest
+func Verify(path string, expected digest.Digest) error {
+	if err := expected.Validate(); err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	verifier := expected.Verifier()
+	if _, err := io.Copy(verifier, f); err != nil {
+		return err
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("disk image %s does not match digest %s", path, expected)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package diskcache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := define.NewMachineFile(t.TempDir(), nil)
+	require.NoError(t, err)
+	cache := New(dir)
+	content := "disk image"
+	sum := digest.FromString(content)
+
+	_, ok, err := cache.Lookup(sum, ".qcow2.xz")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// a mismatching download leaves nothing behind
+	_, _, err = cache.Store(strings.NewReader("corrupted"), sum, ".qcow2.xz")
+	assert.ErrorContains(t, err, "digest mismatch")
+	entries, err := os.ReadDir(dir.GetPath())
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	stored, actual, err := cache.Store(strings.NewReader(content), sum, ".qcow2.xz")
+	require.NoError(t, err)
+	assert.Equal(t, sum, actual)
+	assert.Equal(t, filepath.Join(dir.GetPath(), sum.Encoded()+".qcow2.xz"), stored.GetPath())
+
+	found, ok, err := cache.Lookup(sum, ".qcow2.xz")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, stored.GetPath(), found.GetPath())
+
+	// without an expected digest the content is digested
+	_, actual, err = cache.Store(strings.NewReader("other"), "", ".raw")
+	require.NoError(t, err)
+	assert.Equal(t, digest.FromString("other"), actual)
+
+	assert.NoError(t, Verify(stored.GetPath(), sum))
+	assert.Error(t, Verify(stored.GetPath(), actual))
+	_, _, err = cache.Lookup("sha256:abc", ".raw")
+	assert.Error(t, err)
+}
+
+func TestStoreFile(t *testing.T) {
+	dir, err := define.NewMachineFile(t.TempDir(), nil)
+	require.NoError(t, err)
+	cache := New(dir)
+	sum := digest.FromString("disk image")
+
+	partial, err := cache.PartialPath(sum.Encoded())
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir.GetPath(), ".partial-"+sum.Encoded()), partial.GetPath())
+
+	// a mismatching download is removed so that it is not resumed
+	require.NoError(t, os.WriteFile(partial.GetPath(), []byte("corrupted"), 0644))
+	_, _, err = cache.StoreFile(partial.GetPath(), sum, ".raw")
+	assert.ErrorContains(t, err, "digest mismatch")
+	assert.NoFileExists(t, partial.GetPath())
+
+	require.NoError(t, os.WriteFile(partial.GetPath(), []byte("disk image"), 0644))
+	stored, actual, err := cache.StoreFile(partial.GetPath(), sum, ".raw")
+	require.NoError(t, err)
+	assert.Equal(t, sum, actual)
+	assert.NoFileExists(t, partial.GetPath())
+	_, ok, err := cache.Lookup(sum, ".raw")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, Verify(stored.GetPath(), sum))
+}
+
+func TestKindAndCompression(t *testing.T) {
+	type args struct {
+		name string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "qcow2",
+			args: args{name: "foo.qcow2.xz"},
+			want: ".qcow2.xz",
+		},
+		{
+			name: "vhdx",
+			args: args{name: "foo.vhdx.zip"},
+			want: ".vhdx.zip",
+		},
+		{
+			name: "applehv",
+			args: args{name: "foo.raw.gz"},
+			want: ".raw.gz",
+		},
+		{
+			name: "lots of extensions with type and compression",
+			args: args{name: "foo.bar.homer.simpson.qcow2.xz"},
+			want: ".qcow2.xz",
+		},
+		{
+			name: "lots of extensions",
+			args: args{name: "foo.bar.homer.simpson"},
+			want: ".homer.simpson",
+		},
+		{
+			name: "no extensions",
+			args: args{name: "foobar"},
+			want: "",
+		},
+		{
+			name: "one extension",
+			args: args{name: "foobar.zip"},
+			want: ".zip",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KindAndCompression(tt.args.name); got != tt.want {
+				t.Errorf("KindAndCompression() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,9 @@
 package e2e_test
 
 type infoMachine struct {
-	format string
-	cmd    []string
+	format    string
+	providers bool
+	cmd       []string
 }
 
 func (i *infoMachine) buildCmd(m *machineTestBuilder) []string {
@@ -10,6 +11,9 @@ func (i *infoMachine) buildCmd(m *machineTestBuilder) []string {
 	if len(i.format) > 0 {
 		cmd = append(cmd, "--format", i.format)
 	}
+	if i.providers {
+		cmd = append(cmd, "--providers")
+	}
 	i.cmd = cmd
 	return cmd
 }
@@ -18,3 +22,8 @@ func (i *infoMachine) withFormat(format string) *infoMachine {
 	i.format = format
 	return i
 }
+
+func (i *infoMachine) withProviders() *infoMachine {
+	i.providers = true
+	return i
+}
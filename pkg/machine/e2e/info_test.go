@@ -57,5 +57,27 @@ var _ = Describe("podman machine info", func() {
 		infoReport := &entities.MachineInfo{}
 		err = jsoniter.Unmarshal(infoSession.Bytes(), infoReport)
 		Expect(err).ToNot(HaveOccurred())
+		Expect(infoReport.Providers).To(BeEmpty())
+	})
+
+	It("machine info --providers", func() {
+		info := new(infoMachine)
+		infoSession, err := mb.setCmd(info.withFormat("json").withProviders()).run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(infoSession).Should(Exit(0))
+
+		infoReport := &entities.MachineInfo{}
+		err = jsoniter.Unmarshal(infoSession.Bytes(), infoReport)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(infoReport.Providers).ToNot(BeEmpty())
+		Expect(infoReport.Providers[0].Default).To(BeTrue())
+		Expect(infoReport.Providers[0].VMType).To(Equal(testProvider.VMType().String()))
+		Expect(infoReport.Providers[0].Capabilities).To(Equal(testProvider.Capabilities()))
+
+		// the matrix is also available to Go templates
+		infoSession, err = mb.setCmd(info.withFormat("{{range .Providers}}{{.VMType}}:{{.Capabilities.Snapshots}} {{end}}")).run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(infoSession).Should(Exit(0))
+		Expect(infoSession.outputToString()).To(ContainSubstring(testProvider.VMType().String() + ":"))
 	})
 })
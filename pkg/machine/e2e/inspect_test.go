@@ -70,8 +70,12 @@ var _ = Describe("podman inspect stop", func() {
 		switch testProvider.VMType() {
 		case define.WSLVirt:
 			Expect(inspectInfo[0].ConnectionInfo.PodmanPipe.GetPath()).To(ContainSubstring("podman-"))
+			// the rootful API of the rootless machine is forwarded too
+			Expect(inspectInfo[0].ConnectionInfo.SecondaryPodmanPipe.GetPath()).To(HaveSuffix("-root"))
 		default:
-			Expect(inspectInfo[0].ConnectionInfo.PodmanSocket.GetPath()).To(HaveSuffix("podman.sock"))
+			// named after the machine, podman.sock links to it once started
+			Expect(inspectInfo[0].ConnectionInfo.PodmanSocket.GetPath()).To(HaveSuffix(name + "-api.sock"))
+			Expect(inspectInfo[0].ConnectionInfo.SecondaryPodmanSocket.GetPath()).To(HaveSuffix("-root-api.sock"))
 		}
 
 		inspect := new(inspectMachine)
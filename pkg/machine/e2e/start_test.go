@@ -1,6 +1,8 @@
 package e2e_test
 
 import (
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/containers/podman/v5/pkg/machine/define"
@@ -36,6 +38,15 @@ var _ = Describe("podman machine start", func() {
 		Expect(ec).To(BeZero())
 		Expect(info[0].State).To(Equal(define.Running))
 
+		// the API socket of earlier versions links to the one of the machine
+		if testProvider.VMType() != define.WSLVirt && testProvider.VMType() != define.HyperVVirt {
+			socket := info[0].ConnectionInfo.PodmanSocket.GetPath()
+			Expect(socket).To(HaveSuffix(mb.name + "-api.sock"))
+			link, err := os.Readlink(filepath.Join(filepath.Dir(socket), "podman.sock"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(link).To(Equal(socket))
+		}
+
 		stop := new(stopMachine)
 		stopSession, err := mb.setCmd(stop).run()
 		Expect(err).ToNot(HaveOccurred())
@@ -0,0 +1,496 @@
+// Package fakeprovider implements a scriptable vmconfigs.VMProvider that runs
+// no external processes.  It is only meant to be used by tests, which is why
+// its constructor requires a testing.TB.
+package fakeprovider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	gvproxy "github.com/containers/gvisor-tap-vsock/pkg/types"
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/ignition"
+	"github.com/containers/podman/v5/pkg/machine/sockets"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// readyTimeout is how long WaitForReady waits for the fake guest to trip the
+// ready socket
+const readyTimeout = 10 * time.Second
+
+// Provider is a fake VMProvider.  Its behavior is set up with the SetState,
+// SetStates, Fail and Delay methods and every call to the VMProvider
+// interface is recorded and can be retrieved with Calls.
+type Provider struct {
+	// Type is the VMType reported by the provider
+	Type define.VMType
+	// ProviderNetworking is returned by UseProviderNetworkSetup.  It defaults
+	// to true so that gvproxy is never started.
+	ProviderNetworking bool
+	// Exclusive is returned by RequireExclusiveActive
+	Exclusive bool
+	// SkipReady makes started machines never trip their ready socket
+	SkipReady bool
+	// ReadyPayload is the line the fake guest sends on its ready socket.  It
+	// defaults to the plain "Ready" ping.
+	ReadyPayload string
+	// Console is returned by AttachConsole, which fails with
+	// define.ErrNotImplemented when it is nil
+	Console io.ReadWriteCloser
+	// AttachMounts is returned by UpdateMounts, telling whether the mounts
+	// were attached to the running machine
+	AttachMounts bool
+	// DefaultFirmware makes Capabilities report that machines can neither
+	// use secure boot nor a TPM
+	DefaultFirmware bool
+	// IgnitionVsock makes Capabilities report that the ignition config is
+	// served to machines as they first boot
+	IgnitionVsock bool
+
+	lock   sync.Mutex
+	calls  []string
+	errs   map[string]error
+	delays map[string]time.Duration
+	states map[string][]define.Status
+
+	recoveryOverrides []define.RecoveryOverride
+	runningConfig     *vmconfigs.RunningConfig
+	stats             []vmconfigs.Stats
+}
+
+// New returns a fake provider that pretends to be a qemu provider
+func New(t testing.TB) *Provider {
+	t.Helper()
+	return &Provider{
+		Type:               define.QemuVirt,
+		ProviderNetworking: true,
+		errs:               make(map[string]error),
+		delays:             make(map[string]time.Duration),
+		states:             make(map[string][]define.Status),
+	}
+}
+
+// Calls returns the names of the VMProvider methods called so far, in order
+func (p *Provider) Calls() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	calls := make([]string, len(p.calls))
+	copy(calls, p.calls)
+	return calls
+}
+
+// Called returns how many times the given method has been called
+func (p *Provider) Called(method string) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	n := 0
+	for _, c := range p.calls {
+		if c == method {
+			n++
+		}
+	}
+	return n
+}
+
+// Fail makes every following call to method return err.  A nil err
+// clears a previously injected failure.
+func (p *Provider) Fail(method string, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if err == nil {
+		delete(p.errs, method)
+		return
+	}
+	p.errs[method] = err
+}
+
+// Delay makes every following call to method sleep for d before returning
+func (p *Provider) Delay(method string, d time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.delays[method] = d
+}
+
+// SetState sets the state reported for the named machine
+func (p *Provider) SetState(name string, state define.Status) {
+	p.SetStates(name, state)
+}
+
+// SetStates scripts the states reported for the named machine.  Each call to
+// State consumes one entry; the last entry is reported from then on.
+func (p *Provider) SetStates(name string, states ...define.Status) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.states[name] = states
+}
+
+// call records the call to method, sleeps for its configured delay and
+// returns the injected error for it, if any
+func (p *Provider) call(method string) error {
+	p.lock.Lock()
+	p.calls = append(p.calls, method)
+	delay := p.delays[method]
+	err := p.errs[method]
+	p.lock.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return err
+}
+
+// Capabilities reports every feature the fake provider implements; the
+// console, hot-plugged volumes and running several machines depend on how
+// the provider is set up
+func (p *Provider) Capabilities() define.Capabilities {
+	return define.Capabilities{
+		CompactDisk:     true,
+		Console:         p.Console != nil,
+		ConvertDisk:     true,
+		Export:          true,
+		GPU:             true,
+		IgnitionVsock:   p.IgnitionVsock,
+		MultipleRunning: !p.Exclusive,
+		Recovery:        true,
+		SecureBoot:      !p.DefaultFirmware,
+		Snapshots:       true,
+		Stats:           true,
+		TPM:             !p.DefaultFirmware,
+		USB:             true,
+		VirtioFS:        p.MountType() == vmconfigs.VirtIOFS,
+		VolumeHotplug:   p.AttachMounts,
+	}
+}
+
+// CompactDisk drops the zeros at the end of the disk image
+func (p *Provider) CompactDisk(mc *vmconfigs.MachineConfig) error {
+	if err := p.call("CompactDisk"); err != nil {
+		return err
+	}
+	content, err := mc.ImagePath.Read()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(mc.ImagePath.GetPath(), bytes.TrimRight(content, "\x00"), 0644)
+}
+
+// ConvertDisk copies src to dst; the format is not changed
+func (p *Provider) ConvertDisk(mc *vmconfigs.MachineConfig, src, dst *define.VMFile, format define.ImageFormat) error {
+	if err := p.call("ConvertDisk"); err != nil {
+		return err
+	}
+	content, err := src.Read()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst.GetPath(), content, 0644)
+}
+
+func (p *Provider) CreateVM(opts define.CreateVMOpts, mc *vmconfigs.MachineConfig, builder *ignition.IgnitionBuilder) error {
+	if err := p.call("CreateVM"); err != nil {
+		return err
+	}
+	p.SetState(mc.Name, define.Stopped)
+	return nil
+}
+
+func (p *Provider) GetDisk(userInputPath string, dirs *define.MachineDirs, mc *vmconfigs.MachineConfig) error {
+	if err := p.call("GetDisk"); err != nil {
+		return err
+	}
+	if mc.ImagePath == nil {
+		return errors.New("no image path set")
+	}
+	return os.WriteFile(mc.ImagePath.GetPath(), nil, 0644)
+}
+
+func (p *Provider) PrepareIgnition(mc *vmconfigs.MachineConfig, ignBuilder *ignition.IgnitionBuilder) (*ignition.ReadyUnitOpts, error) {
+	if err := p.call("PrepareIgnition"); err != nil {
+		return nil, err
+	}
+	return &ignition.ReadyUnitOpts{}, nil
+}
+
+func (p *Provider) Exists(name string) (bool, error) {
+	if err := p.call("Exists"); err != nil {
+		return false, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	_, exists := p.states[name]
+	return exists, nil
+}
+
+func (p *Provider) MountType() vmconfigs.VolumeMountType {
+	return vmconfigs.VirtIOFS
+}
+
+func (p *Provider) MountVolumesToVM(mc *vmconfigs.MachineConfig, quiet bool) error {
+	return p.call("MountVolumesToVM")
+}
+
+func (p *Provider) UpdateMounts(mc *vmconfigs.MachineConfig, added, removed []*vmconfigs.Mount) (bool, error) {
+	if err := p.call("UpdateMounts"); err != nil {
+		return false, err
+	}
+	return p.AttachMounts, nil
+}
+
+func (p *Provider) Remove(mc *vmconfigs.MachineConfig) ([]string, func() error, error) {
+	if err := p.call("Remove"); err != nil {
+		return nil, nil, err
+	}
+	return []string{}, func() error {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		delete(p.states, mc.Name)
+		return machine.ReleaseMachinePort(mc.SSH.Port)
+	}, nil
+}
+
+func (p *Provider) RemoveAndCleanMachines(dirs *define.MachineDirs) error {
+	return p.call("RemoveAndCleanMachines")
+}
+
+func (p *Provider) SetProviderAttrs(mc *vmconfigs.MachineConfig, opts define.SetOptions) error {
+	return p.call("SetProviderAttrs")
+}
+
+// Snapshot copies the disk image next to it
+func (p *Provider) Snapshot(mc *vmconfigs.MachineConfig, name string) (*define.VMFile, error) {
+	if err := p.call("Snapshot"); err != nil {
+		return nil, err
+	}
+	content, err := mc.ImagePath.Read()
+	if err != nil {
+		return nil, err
+	}
+	path := mc.ImagePath.GetPath() + ".snapshot-" + name
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return nil, err
+	}
+	return define.NewMachineFile(path, nil)
+}
+
+// RevertSnapshot copies the snapshot over the disk image
+func (p *Provider) RevertSnapshot(mc *vmconfigs.MachineConfig, snapshot *vmconfigs.Snapshot) error {
+	if err := p.call("RevertSnapshot"); err != nil {
+		return err
+	}
+	content, err := snapshot.File.Read()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(mc.ImagePath.GetPath(), content, 0644)
+}
+
+func (p *Provider) AttachConsole(mc *vmconfigs.MachineConfig) (io.ReadWriteCloser, error) {
+	if err := p.call("AttachConsole"); err != nil {
+		return nil, err
+	}
+	if p.Console == nil {
+		return nil, define.ErrNotImplemented
+	}
+	return p.Console, nil
+}
+
+func (p *Provider) UpdateResources(mc *vmconfigs.MachineConfig, opts define.SetOptions) error {
+	return p.call("UpdateResources")
+}
+
+func (p *Provider) StartNetworking(mc *vmconfigs.MachineConfig, cmd *gvproxy.GvproxyCommand) error {
+	return p.call("StartNetworking")
+}
+
+func (p *Provider) PostStartNetworking(mc *vmconfigs.MachineConfig, noInfo bool) error {
+	return p.call("PostStartNetworking")
+}
+
+// StartVM listens on the ready socket of the machine and, unless SkipReady
+// is set, connects to it the way the ready service of a guest would.  The
+// returned wait function blocks until the socket is tripped.
+func (p *Provider) StartVM(mc *vmconfigs.MachineConfig) (func() error, func() error, error) {
+	if err := p.call("StartVM"); err != nil {
+		return nil, nil, err
+	}
+
+	readySocket, err := mc.ReadySocket()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := readySocket.Delete(); err != nil {
+		return nil, nil, err
+	}
+	listener, err := net.Listen("unix", readySocket.GetPath())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.SetState(mc.Name, define.Running)
+
+	if !p.SkipReady {
+		go func() {
+			conn, err := net.Dial("unix", readySocket.GetPath())
+			if err != nil {
+				return
+			}
+			payload := p.ReadyPayload
+			if payload == "" {
+				payload = "Ready"
+			}
+			_, _ = conn.Write([]byte(payload + "\n"))
+			_ = conn.Close()
+		}()
+	}
+
+	waitForReady := func() error {
+		defer listener.Close()
+		if err := p.call("WaitForReady"); err != nil {
+			return err
+		}
+		if err := listener.(*net.UnixListener).SetDeadline(time.Now().Add(readyTimeout)); err != nil {
+			return err
+		}
+		conn, err := listener.Accept()
+		if err != nil {
+			return fmt.Errorf("waiting for ready socket: %w", err)
+		}
+		defer conn.Close()
+		status, err := sockets.ReadReady(conn)
+		if status != nil {
+			mc.ReadyStatus = *status
+		}
+		return err
+	}
+	return nil, waitForReady, nil
+}
+
+// StartRecovery records the override it was called with and marks the
+// machine running
+func (p *Provider) StartRecovery(mc *vmconfigs.MachineConfig, override define.RecoveryOverride) error {
+	if err := p.call("StartRecovery"); err != nil {
+		return err
+	}
+	p.lock.Lock()
+	p.recoveryOverrides = append(p.recoveryOverrides, override)
+	p.lock.Unlock()
+	p.SetState(mc.Name, define.Running)
+	return nil
+}
+
+// RecoveryOverrides returns the overrides StartRecovery was called with
+func (p *Provider) RecoveryOverrides() []define.RecoveryOverride {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]define.RecoveryOverride(nil), p.recoveryOverrides...)
+}
+
+// SetRunningConfig sets the configuration RunningConfig reports.  Until it is
+// set, RunningConfig reports the resources of the machine configuration.
+func (p *Provider) SetRunningConfig(cfg *vmconfigs.RunningConfig) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.runningConfig = cfg
+}
+
+func (p *Provider) RunningConfig(mc *vmconfigs.MachineConfig) (*vmconfigs.RunningConfig, error) {
+	if err := p.call("RunningConfig"); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.runningConfig == nil {
+		return &vmconfigs.RunningConfig{
+			CPUs:     mc.Resources.CPUs,
+			Memory:   mc.Resources.Memory,
+			DiskSize: mc.Resources.DiskSize,
+		}, nil
+	}
+	cfg := *p.runningConfig
+	return &cfg, nil
+}
+
+// SetStats sets the usage Stats reports, one sample per call; the last one
+// is repeated.  Until it is set, Stats reports no usage.
+func (p *Provider) SetStats(stats ...vmconfigs.Stats) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.stats = stats
+}
+
+func (p *Provider) Stats(_ *vmconfigs.MachineConfig) (*vmconfigs.Stats, error) {
+	if err := p.call("Stats"); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.stats) == 0 {
+		return new(vmconfigs.Stats), nil
+	}
+	stats := p.stats[0]
+	if len(p.stats) > 1 {
+		p.stats = p.stats[1:]
+	}
+	return &stats, nil
+}
+
+func (p *Provider) State(mc *vmconfigs.MachineConfig, bypass bool) (define.Status, error) {
+	if err := p.call("State"); err != nil {
+		return "", err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	states := p.states[mc.Name]
+	switch len(states) {
+	case 0:
+		return define.Stopped, nil
+	case 1:
+		return states[0], nil
+	}
+	p.states[mc.Name] = states[1:]
+	return states[0], nil
+}
+
+func (p *Provider) StopVM(mc *vmconfigs.MachineConfig, hardStop bool) error {
+	if err := p.call("StopVM"); err != nil {
+		return err
+	}
+	p.SetState(mc.Name, define.Stopped)
+	return nil
+}
+
+func (p *Provider) KillVM(mc *vmconfigs.MachineConfig) error {
+	if err := p.call("KillVM"); err != nil {
+		return err
+	}
+	p.SetState(mc.Name, define.Stopped)
+	return nil
+}
+
+func (p *Provider) StopHostNetworking(mc *vmconfigs.MachineConfig, vmType define.VMType) error {
+	return p.call("StopHostNetworking")
+}
+
+func (p *Provider) VMType() define.VMType {
+	return p.Type
+}
+
+func (p *Provider) UserModeNetworkEnabled(mc *vmconfigs.MachineConfig) bool {
+	return false
+}
+
+func (p *Provider) UseProviderNetworkSetup() bool {
+	return p.ProviderNetworking
+}
+
+func (p *Provider) RequireExclusiveActive() bool {
+	return p.Exclusive
+}
@@ -0,0 +1,74 @@
+package machine
+
+const (
+	// GPUCDIScriptPath is where the script generating the CDI specs of the
+	// GPUs is written in the guest
+	GPUCDIScriptPath = "/usr/local/libexec/podman-machine/gpu-cdi"
+	// GPUCDIUnitName runs GPUCDIScript on every boot, the devices of the
+	// guest may change between boots
+	GPUCDIUnitName = "podman-machine-gpu-cdi.service"
+	// GPUCDIKind is the CDI kind of the GPU devices of the guest that are
+	// not described by a vendor tool, containers use them with
+	// --device podman-machine.io/gpu=all
+	GPUCDIKind = "podman-machine.io/gpu"
+)
+
+// GPUCDIScript generates the CDI specs of the GPUs of the guest in /etc/cdi.
+// NVIDIA GPUs are described by nvidia-ctk when it is installed, all GPUs are
+// described as GPUCDIKind=all from their device nodes, with the libraries
+// WSL provides for GPU-PV.
+const GPUCDIScript = `#!/bin/sh
+set -e
+mkdir -p /etc/cdi
+
+if command -v nvidia-ctk >/dev/null 2>&1; then
+	mode=auto
+	[ -e /dev/dxg ] && mode=wsl
+	nvidia-ctk cdi generate --mode="$mode" --output=/etc/cdi/nvidia.yaml || echo "nvidia-ctk could not describe the GPUs" >&2
+fi
+
+nodes=""
+for dev in /dev/dri/card* /dev/dri/renderD* /dev/dxg; do
+	[ -e "$dev" ] && nodes="$nodes        - path: $dev
+"
+done
+spec=/etc/cdi/podman-machine-gpu.yaml
+if [ -z "$nodes" ]; then
+	rm -f "$spec"
+	exit 0
+fi
+
+mounts=""
+if [ -d /usr/lib/wsl ]; then
+	mounts="      mounts:
+        - hostPath: /usr/lib/wsl
+          containerPath: /usr/lib/wsl
+          options: [ro, nosuid, nodev, bind]
+"
+fi
+
+cat > "$spec" <<SPEC
+cdiVersion: 0.5.0
+kind: ` + GPUCDIKind + `
+devices:
+  - name: all
+    containerEdits:
+      deviceNodes:
+$nodes$mounts
+SPEC
+`
+
+// GPUCDIUnit is the unit running GPUCDIScript
+const GPUCDIUnit = `[Unit]
+Description=Describe the GPUs of the machine for containers
+After=systemd-udev-settle.service
+Wants=systemd-udev-settle.service
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=` + GPUCDIScriptPath + `
+
+[Install]
+WantedBy=multi-user.target
+`
@@ -0,0 +1,24 @@
+//go:build windows
+
+package hyperv
+
+import (
+	"fmt"
+
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// CompactDisk reclaims the blocks of the dynamic vhdx that the guest
+// discarded or that hold zeros.  Optimize-VHD only looks for zeros in a disk
+// attached read-only.  A vhdx with checkpoints is the parent of their
+// differencing disks and must not be changed.
+func (h HyperVStubber) CompactDisk(mc *vmconfigs.MachineConfig) error {
+	if len(mc.Snapshots) > 0 {
+		return fmt.Errorf("the disk image of a machine with %d checkpoints cannot be compacted", len(mc.Snapshots))
+	}
+
+	mc.Lock()
+	defer mc.Unlock()
+
+	return runPowerShell(fmt.Sprintf("$ErrorActionPreference = 'Stop'; Mount-VHD -Path '%[1]s' -ReadOnly; try { Optimize-VHD -Path '%[1]s' -Mode Full } finally { Dismount-VHD -Path '%[1]s' }", mc.ImagePath.GetPath()))
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package hyperv
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// consolePipe is the named pipe Hyper-V connects the COM1 port of the
+// machine to
+func consolePipe(mc *vmconfigs.MachineConfig) string {
+	return `\\.\pipe\` + mc.Name + "-console"
+}
+
+// setConsolePipe connects the COM1 port of the new VM, which the guest uses as
+// its console, to a named pipe
+func setConsolePipe(mc *vmconfigs.MachineConfig) error {
+	return runPowerShell(fmt.Sprintf("Set-VMComPort -VMName '%s' -Number 1 -Path '%s'", mc.Name, consolePipe(mc)))
+}
+
+// AttachConsole connects to the named pipe of the COM1 port of the machine
+func (h HyperVStubber) AttachConsole(mc *vmconfigs.MachineConfig) (io.ReadWriteCloser, error) {
+	conn, err := machine.DialNamedPipe(context.Background(), consolePipe(mc))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the serial console of machine %q, machines created by an older podman have none: %w", mc.Name, err)
+	}
+	return conn, nil
+}
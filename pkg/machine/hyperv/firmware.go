@@ -0,0 +1,31 @@
+//go:build windows
+
+package hyperv
+
+import (
+	"fmt"
+
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// setFirmware enables secure boot and a virtual TPM on the new virtual
+// machine, as the firmware configuration of the machine asks.  The template
+// of the UEFI certificate authority is the one that lets the shim of Linux
+// distributions boot.
+func setFirmware(mc *vmconfigs.MachineConfig) error {
+	fw := mc.Resources.Firmware
+	if fw == nil {
+		return nil
+	}
+	if fw.SecureBoot {
+		if err := runPowerShell(fmt.Sprintf("Set-VMFirmware -VMName '%s' -EnableSecureBoot On -SecureBootTemplate MicrosoftUEFICertificateAuthority", mc.Name)); err != nil {
+			return fmt.Errorf("enabling secure boot: %w", err)
+		}
+	}
+	if fw.TPM {
+		if err := runPowerShell(fmt.Sprintf("Set-VMKeyProtector -VMName '%s' -NewLocalKeyProtector; Enable-VMTPM -VMName '%s'", mc.Name, mc.Name)); err != nil {
+			return fmt.Errorf("enabling the TPM: %w", err)
+		}
+	}
+	return nil
+}
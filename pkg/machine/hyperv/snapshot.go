@@ -0,0 +1,40 @@
+//go:build windows
+
+package hyperv
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/sirupsen/logrus"
+)
+
+// runPowerShell runs a Hyper-V cmdlet for the machine
+func runPowerShell(command string) error {
+	cmd := exec.Command("powershell", "-command", command)
+	logrus.Debug(cmd.Args)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Snapshot creates a standard checkpoint of the stopped VM, which holds its
+// configuration and a differencing disk of the vhdx
+func (h HyperVStubber) Snapshot(mc *vmconfigs.MachineConfig, name string) (*define.VMFile, error) {
+	mc.Lock()
+	defer mc.Unlock()
+
+	return nil, runPowerShell(fmt.Sprintf("Checkpoint-VM -Name '%s' -SnapshotName '%s'", mc.Name, name))
+}
+
+// RevertSnapshot applies the checkpoint to the VM
+func (h HyperVStubber) RevertSnapshot(mc *vmconfigs.MachineConfig, snapshot *vmconfigs.Snapshot) error {
+	mc.Lock()
+	defer mc.Unlock()
+
+	return runPowerShell(fmt.Sprintf("Restore-VMSnapshot -VMName '%s' -Name '%s' -Confirm:$false", mc.Name, snapshot.Name))
+}
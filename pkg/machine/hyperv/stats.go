@@ -0,0 +1,38 @@
+//go:build windows
+
+package hyperv
+
+import (
+	"fmt"
+
+	"github.com/containers/libhvee/pkg/hypervctl"
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// Stats asks Hyper-V for the processor load and the memory of the running
+// machine and measures its disk image
+func (h HyperVStubber) Stats(mc *vmconfigs.MachineConfig) (*vmconfigs.Stats, error) {
+	vmm := hypervctl.NewVirtualMachineManager()
+	vm, err := vmm.GetMachine(mc.Name)
+	if err != nil {
+		return nil, fmt.Errorf("getting virtual machine: %w", err)
+	}
+	summary, err := vm.GetSummaryInformation(hypervctl.SummaryRequestSet{
+		hypervctl.SummaryRequestProcessorLoad,
+		hypervctl.SummaryRequestMemoryUsage,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting usage of virtual machine: %w", err)
+	}
+	diskUsed, err := machine.DiskUsage(mc.ImagePath.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	return &vmconfigs.Stats{
+		CPUPercent: float64(summary.ProcessorLoad),
+		// Hyper-V reports the memory in megabytes
+		MemoryUsed: summary.MemoryUsage * 1024 * 1024,
+		DiskUsed:   diskUsed,
+	}, nil
+}
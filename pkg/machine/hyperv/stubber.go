@@ -41,6 +41,34 @@ func (h HyperVStubber) RequireExclusiveActive() bool {
 	return true
 }
 
+// Capabilities reports the features of Hyper-V.  Snapshots are checkpoints
+// and volumes are shared over vsock, which works while the machine runs.
+func (h HyperVStubber) Capabilities() define.Capabilities {
+	return define.Capabilities{
+		CompactDisk:     true,
+		Console:         true,
+		Export:          true,
+		MultipleRunning: !h.RequireExclusiveActive(),
+		SecureBoot:      true,
+		Snapshots:       true,
+		Stats:           true,
+		TPM:             true,
+		VolumeHotplug:   true,
+	}
+}
+
+func (h HyperVStubber) StartRecovery(_ *vmconfigs.MachineConfig, _ define.RecoveryOverride) error {
+	return define.ErrNotImplemented
+}
+
+func (h HyperVStubber) ConvertDisk(_ *vmconfigs.MachineConfig, _, _ *define.VMFile, _ define.ImageFormat) error {
+	return define.ErrNotImplemented
+}
+
+func (h HyperVStubber) RunningConfig(_ *vmconfigs.MachineConfig) (*vmconfigs.RunningConfig, error) {
+	return nil, define.ErrNotImplemented
+}
+
 func (h HyperVStubber) CreateVM(opts define.CreateVMOpts, mc *vmconfigs.MachineConfig, builder *ignition.IgnitionBuilder) error {
 	var (
 		err error
@@ -64,13 +92,13 @@ func (h HyperVStubber) CreateVM(opts define.CreateVMOpts, mc *vmconfigs.MachineC
 	mc.HyperVHypervisor.NetworkVSock = *networkHVSock
 
 	// Add vsock port numbers to mounts
-	err = createShares(mc)
+	err = createShares(mc, mc.Mounts)
 	if err != nil {
 		return err
 	}
 
 	removeShareCallBack := func() error {
-		return removeShares(mc)
+		return removeShares(mc.Mounts)
 	}
 	callbackFuncs.Add(removeShareCallBack)
 
@@ -119,6 +147,12 @@ func (h HyperVStubber) CreateVM(opts define.CreateVMOpts, mc *vmconfigs.MachineC
 	}
 
 	callbackFuncs.Add(vmRemoveCallback)
+	if err = setFirmware(mc); err != nil {
+		return err
+	}
+	if err = setConsolePipe(mc); err != nil {
+		return err
+	}
 	err = resizeDisk(strongunits.GiB(mc.Resources.DiskSize), mc.ImagePath)
 	return err
 }
@@ -137,6 +171,35 @@ func (h HyperVStubber) MountVolumesToVM(mc *vmconfigs.MachineConfig, quiet bool)
 	return nil
 }
 
+// UpdateMounts registers the hvsock ports of the added mounts and removes the
+// ones of the removed mounts.  The added mounts are served and mounted in the
+// running machine right away, or on the next start when that fails.
+func (h HyperVStubber) UpdateMounts(mc *vmconfigs.MachineConfig, added, removed []*vmconfigs.Mount) (bool, error) {
+	if err := createShares(mc, added); err != nil {
+		return false, err
+	}
+	if err := removeShares(removed); err != nil {
+		return false, err
+	}
+
+	state, err := h.State(mc, false)
+	if err != nil {
+		return false, err
+	}
+	if state != define.Running || len(added) == 0 {
+		return false, nil
+	}
+	if err := start9pServer(mc, added); err != nil {
+		logrus.Warnf("Unable to serve the added volumes, they are mounted on the next start: %v", err)
+		return false, nil
+	}
+	if err := startShares(mc, added); err != nil {
+		logrus.Warnf("Unable to mount the added volumes, they are mounted on the next start: %v", err)
+		return false, nil
+	}
+	return true, nil
+}
+
 func (h HyperVStubber) Remove(mc *vmconfigs.MachineConfig) ([]string, func() error, error) {
 	mc.Lock()
 	defer mc.Unlock()
@@ -157,6 +220,10 @@ func (h HyperVStubber) Remove(mc *vmconfigs.MachineConfig) ([]string, func() err
 			logrus.Errorf("unable to remove ignition registry entries: %q", err)
 		}
 
+		if err := machine.ReleaseMachinePort(mc.SSH.Port); err != nil {
+			logrus.Errorf("unable to release SSH port %d: %q", mc.SSH.Port, err)
+		}
+
 		// disk path removal is done by generic remove
 		return vm.Remove("")
 	}
@@ -215,7 +282,7 @@ func (h HyperVStubber) StartVM(mc *vmconfigs.MachineConfig) (func() error, func(
 		callbackFuncs.Add(rmIgnCallbackFunc)
 	}
 
-	waitReady, listener, err := mc.HyperVHypervisor.ReadyVsock.ListenSetupWait()
+	waitReady, listener, err := mc.HyperVHypervisor.ReadyVsock.ListenSetupWait(&mc.ReadyStatus)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -269,6 +336,18 @@ func (h HyperVStubber) StopVM(mc *vmconfigs.MachineConfig, hardStop bool) error
 	return vm.Stop()
 }
 
+func (h HyperVStubber) KillVM(mc *vmconfigs.MachineConfig) error {
+	vmm := hypervctl.NewVirtualMachineManager()
+	vm, err := vmm.GetMachine(mc.Name)
+	if err != nil {
+		return fmt.Errorf("getting virtual machine: %w", err)
+	}
+	if vm.State() == hypervctl.Disabled {
+		return nil
+	}
+	return vm.StopWithForce()
+}
+
 // TODO should this be plumbed higher into the code stack?
 func (h HyperVStubber) StopHostNetworking(mc *vmconfigs.MachineConfig, vmType define.VMType) error {
 	err := machine.StopWinProxy(mc.Name, vmType)
@@ -303,10 +382,6 @@ func stateConversion(s hypervctl.EnabledState) (define.Status, error) {
 }
 
 func (h HyperVStubber) SetProviderAttrs(mc *vmconfigs.MachineConfig, opts define.SetOptions) error {
-	var (
-		cpuChanged, memoryChanged bool
-	)
-
 	mc.Lock()
 	defer mc.Unlock()
 
@@ -325,6 +400,26 @@ func (h HyperVStubber) SetProviderAttrs(mc *vmconfigs.MachineConfig, opts define
 		}
 	}
 
+	if opts.USBs != nil {
+		return &define.ErrUSBPassthrough{VMType: define.HyperVVirt}
+	}
+
+	return nil
+}
+
+func (h HyperVStubber) UpdateResources(mc *vmconfigs.MachineConfig, opts define.SetOptions) error {
+	var (
+		cpuChanged, memoryChanged bool
+	)
+
+	mc.Lock()
+	defer mc.Unlock()
+
+	_, vm, err := GetVMFromMC(mc)
+	if err != nil {
+		return err
+	}
+
 	if opts.DiskSize != nil {
 		if err := resizeDisk(*opts.DiskSize, mc.ImagePath); err != nil {
 			return err
@@ -355,10 +450,6 @@ func (h HyperVStubber) SetProviderAttrs(mc *vmconfigs.MachineConfig, opts define
 		}
 	}
 
-	if opts.USBs != nil {
-		return fmt.Errorf("changing USBs not supported for hyperv machines")
-	}
-
 	return nil
 }
 
@@ -380,6 +471,19 @@ func (h HyperVStubber) PrepareIgnition(mc *vmconfigs.MachineConfig, ignBuilder *
 }
 
 func (h HyperVStubber) PostStartNetworking(mc *vmconfigs.MachineConfig, noInfo bool) error {
+	if len(mc.Mounts) == 0 {
+		return nil
+	}
+	if err := start9pServer(mc, mc.Mounts); err != nil {
+		return err
+	}
+
+	// Finalize starting shares after we are confident gvproxy is still alive.
+	return startShares(mc, mc.Mounts)
+}
+
+// start9pServer serves the sources of the mounts over 9p until gvproxy exits
+func start9pServer(mc *vmconfigs.MachineConfig, mounts []*vmconfigs.Mount) error {
 	var (
 		err        error
 		executable string
@@ -388,20 +492,9 @@ func (h HyperVStubber) PostStartNetworking(mc *vmconfigs.MachineConfig, noInfo b
 	defer callbackFuncs.CleanIfErr(&err)
 	go callbackFuncs.CleanOnSignal()
 
-	if len(mc.Mounts) == 0 {
-		return nil
-	}
-
-	var (
-		dirs       *define.MachineDirs
-		gvproxyPID int
-	)
-	dirs, err = machine.GetMachineDirs(h.VMType())
-	if err != nil {
-		return err
-	}
-	// GvProxy PID file path is now derived
-	gvproxyPIDFile, err := dirs.RuntimeDir.AppendToNewVMFile("gvproxy.pid", nil)
+	var gvproxyPID int
+	// GvProxy PID file path is derived from the machine name
+	gvproxyPIDFile, err := mc.GVProxyPidFile()
 	if err != nil {
 		return err
 	}
@@ -421,7 +514,7 @@ func (h HyperVStubber) PostStartNetworking(mc *vmconfigs.MachineConfig, noInfo b
 	}
 	p9ServerArgs = append(p9ServerArgs, "machine", "server9p")
 
-	for _, mount := range mc.Mounts {
+	for _, mount := range mounts {
 		if mount.VSockNumber == nil {
 			return fmt.Errorf("mount %s has no vsock port defined", mount.Source)
 		}
@@ -448,14 +541,11 @@ func (h HyperVStubber) PostStartNetworking(mc *vmconfigs.MachineConfig, noInfo b
 
 	// Note: No callback is needed to stop the 9p server, because it will stop when
 	// gvproxy stops
-
-	// Finalize starting shares after we are confident gvproxy is still alive.
-	err = startShares(mc)
-	return err
+	return nil
 }
 
 func (h HyperVStubber) GetDisk(userInputPath string, dirs *define.MachineDirs, mc *vmconfigs.MachineConfig) error {
-	return diskpull.GetDisk(userInputPath, dirs, mc.ImagePath, h.VMType(), mc.Name)
+	return diskpull.GetDisk(userInputPath, dirs, mc, h.VMType())
 }
 
 func resizeDisk(newSize strongunits.GiB, imagePath *define.VMFile) error {
@@ -14,10 +14,10 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func removeShares(mc *vmconfigs.MachineConfig) error {
+func removeShares(mounts []*vmconfigs.Mount) error {
 	var removalErr error
 
-	for _, mount := range mc.Mounts {
+	for _, mount := range mounts {
 		if mount.VSockNumber == nil {
 			// nothing to do if the vsock number was never defined
 			continue
@@ -40,8 +40,8 @@ func removeShares(mc *vmconfigs.MachineConfig) error {
 	return removalErr
 }
 
-func startShares(mc *vmconfigs.MachineConfig) error {
-	for _, mount := range mc.Mounts {
+func startShares(mc *vmconfigs.MachineConfig, mounts []*vmconfigs.Mount) error {
+	for _, mount := range mounts {
 		args := []string{"-q", "--"}
 
 		cleanTarget := path.Clean(mount.Target)
@@ -62,7 +62,16 @@ func startShares(mc *vmconfigs.MachineConfig) error {
 		if mount.VSockNumber == nil {
 			return errors.New("cannot start 9p shares with undefined vsock number")
 		}
-		args = append(args, "machine", "client9p", fmt.Sprintf("%d", *mount.VSockNumber), mount.Target)
+		args = append(args, "machine", "client9p")
+		// the guest picks the cache mode and msize unless the volume sets them
+		options := machine.NinePMountOptions(mount, "", 0)
+		if mount.ReadOnly {
+			options = append(options, "ro")
+		}
+		if len(options) > 0 {
+			args = append(args, "--options", strings.Join(options, ","))
+		}
+		args = append(args, fmt.Sprintf("%d", *mount.VSockNumber), mount.Target)
 
 		if err := machine.CommonSSH(mc.SSH.RemoteUsername, mc.SSH.IdentityPath, mc.Name, mc.SSH.Port, args); err != nil {
 			return err
@@ -71,8 +80,8 @@ func startShares(mc *vmconfigs.MachineConfig) error {
 	return nil
 }
 
-func createShares(mc *vmconfigs.MachineConfig) (err error) {
-	for _, mount := range mc.Mounts {
+func createShares(mc *vmconfigs.MachineConfig, mounts []*vmconfigs.Mount) (err error) {
+	for _, mount := range mounts {
 		testVsock, err := vsock.NewHVSockRegistryEntry(mc.Name, vsock.Fileserver)
 		if err != nil {
 			return err
@@ -261,15 +261,16 @@ func (hv *HVSockRegistryEntry) Listener() (net.Listener, error) {
 
 // ListenSetupWait creates an hvsock on the windows side and returns
 // a wait function that, when called, blocks until it receives a ready
-// notification on the vsock
-func (hv *HVSockRegistryEntry) ListenSetupWait() (func() error, io.Closer, error) {
+// notification on the vsock.  The status sent with the notification is
+// stored in status.
+func (hv *HVSockRegistryEntry) ListenSetupWait(status *sockets.ReadyStatus) (func() error, io.Closer, error) {
 	listener, err := hv.Listener()
 	if err != nil {
 		return nil, nil, err
 	}
 
 	errChan := make(chan error)
-	go sockets.ListenAndWaitOnSocket(errChan, listener)
+	go sockets.ListenAndWaitOnSocket(errChan, listener, status)
 	return func() error {
 		return <-errChan
 	}, listener, nil
@@ -0,0 +1,95 @@
+//go:build amd64 || arm64
+
+package ignition
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"golang.org/x/exp/slices"
+)
+
+// LiveApplyCommands returns the shell commands that apply the changes from
+// previous to cfg to a guest that has already booted.  Ignition only runs on
+// the first boot, so only the changes that can safely be made to a running
+// system are covered: authorized SSH keys, user certificates and the time
+// zone.  The commands are run as the default user of the machine.
+func LiveApplyCommands(previous, cfg Config) []string {
+	var cmds []string
+
+	for _, user := range cfg.Passwd.Users {
+		if user.ShouldExist != nil && !*user.ShouldExist {
+			continue
+		}
+		if prev := findUser(previous, user.Name); prev != nil && slices.Equal(prev.SSHAuthorizedKeys, user.SSHAuthorizedKeys) {
+			continue
+		}
+		home := "/home/" + user.Name
+		if user.Name == "root" {
+			home = "/root"
+		}
+		keys := make([]string, 0, len(user.SSHAuthorizedKeys))
+		for _, key := range user.SSHAuthorizedKeys {
+			keys = append(keys, ShellQuote(string(key)))
+		}
+		keysDir := path.Join(home, ".ssh", "authorized_keys.d")
+		cmds = append(cmds, fmt.Sprintf("sudo mkdir -p %s && printf '%%s\\n' %s | sudo tee %s >/dev/null && sudo chown -R %s: %s",
+			keysDir, strings.Join(keys, " "), path.Join(keysDir, "ignition"), user.Name, path.Join(home, ".ssh")))
+	}
+
+	for _, file := range cfg.Storage.Files {
+		if !strings.HasPrefix(file.Path, define.UserCertsTargetPath+"/") || file.Contents.Source == nil {
+			continue
+		}
+		if prev := findFile(previous, file.Path); prev != nil && prev.Contents.Source != nil && *prev.Contents.Source == *file.Contents.Source {
+			continue
+		}
+		content, err := url.PathUnescape(strings.TrimPrefix(*file.Contents.Source, "data:,"))
+		if err != nil {
+			continue
+		}
+		cmds = append(cmds, fmt.Sprintf("sudo mkdir -p %s && printf '%%s' %s | sudo tee %s >/dev/null",
+			path.Dir(file.Path), ShellQuote(content), file.Path))
+	}
+
+	if tz := timeZone(cfg); tz != "" && tz != timeZone(previous) {
+		cmds = append(cmds, "sudo timedatectl set-timezone "+ShellQuote(tz))
+	}
+	return cmds
+}
+
+func findUser(cfg Config, name string) *PasswdUser {
+	for i := range cfg.Passwd.Users {
+		if cfg.Passwd.Users[i].Name == name {
+			return &cfg.Passwd.Users[i]
+		}
+	}
+	return nil
+}
+
+func findFile(cfg Config, filePath string) *File {
+	for i := range cfg.Storage.Files {
+		if cfg.Storage.Files[i].Path == filePath {
+			return &cfg.Storage.Files[i]
+		}
+	}
+	return nil
+}
+
+// timeZone returns the time zone set by cfg or an empty string
+func timeZone(cfg Config) string {
+	for _, link := range cfg.Storage.Links {
+		if link.Path == localTimePath {
+			return strings.TrimPrefix(link.Target, zoneInfoDir+"/")
+		}
+	}
+	return ""
+}
+
+// ShellQuote quotes s so that the shell of the guest passes it on unchanged
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
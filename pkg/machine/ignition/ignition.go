@@ -14,6 +14,7 @@ import (
 	"github.com/containers/podman/v5/pkg/machine/define"
 	"github.com/containers/podman/v5/pkg/systemd/parser"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
 )
 
 /*
@@ -28,6 +29,8 @@ import (
 const (
 	PodmanDockerTmpConfPath = "/etc/tmpfiles.d/podman-docker.conf"
 	DefaultIgnitionUserName = "core"
+	localTimePath           = "/etc/localtime"
+	zoneInfoDir             = "/usr/share/zoneinfo"
 )
 
 // Convenience function to convert int to ptr
@@ -64,6 +67,8 @@ type DynamicIgnition struct {
 	Cfg        Config
 	Rootful    bool
 	NetRecover bool
+	// AdditionalKeys are authorized for the user and root next to Key
+	AdditionalKeys []string
 }
 
 func (ign *DynamicIgnition) Write() error {
@@ -91,10 +96,16 @@ func (ign *DynamicIgnition) getUsers() []PasswdUser {
 		users = append(users, coreUser)
 	}
 
+	keys := make([]SSHAuthorizedKey, 0, len(ign.AdditionalKeys)+1)
+	keys = append(keys, SSHAuthorizedKey(ign.Key))
+	for _, key := range ign.AdditionalKeys {
+		keys = append(keys, SSHAuthorizedKey(key))
+	}
+
 	// Adding the user
 	user := PasswdUser{
 		Name:              ign.Name,
-		SSHAuthorizedKeys: []SSHAuthorizedKey{SSHAuthorizedKey(ign.Key)},
+		SSHAuthorizedKeys: keys,
 		UID:               IntToPtr(ign.UID),
 	}
 
@@ -111,7 +122,7 @@ func (ign *DynamicIgnition) getUsers() []PasswdUser {
 	// set root SSH key
 	root := PasswdUser{
 		Name:              "root",
-		SSHAuthorizedKeys: []SSHAuthorizedKey{SSHAuthorizedKey(ign.Key)},
+		SSHAuthorizedKeys: keys,
 	}
 	// add them all in
 	users = append(users, user, root)
@@ -156,7 +167,7 @@ func (ign *DynamicIgnition) GenerateIgnitionConfig() error {
 		tzLink := Link{
 			Node: Node{
 				Group:     GetNodeGrp("root"),
-				Path:      "/etc/localtime",
+				Path:      localTimePath,
 				Overwrite: BoolToPtr(false),
 				User:      GetNodeUsr("root"),
 			},
@@ -165,7 +176,7 @@ func (ign *DynamicIgnition) GenerateIgnitionConfig() error {
 				// We always want this value in unix form (/path/to/something) because this is being
 				// set in the machine OS (always Linux).  However, filepath.join on windows will use a "\\"
 				// separator; therefore we use ToSlash to convert the path to unix style
-				Target: filepath.ToSlash(filepath.Join("/usr/share/zoneinfo", tz)),
+				Target: filepath.ToSlash(filepath.Join(zoneInfoDir, tz)),
 			},
 		}
 		ignStorage.Links = append(ignStorage.Links, tzLink)
@@ -377,6 +388,23 @@ pids_limit=0
 		},
 	})
 
+	// The ready unit runs this script to build the status it reports to
+	// the host
+	files = append(files, File{
+		Node: Node{
+			Group: GetNodeGrp("root"),
+			Path:  ReadyStatusScriptPath,
+			User:  GetNodeUsr("root"),
+		},
+		FileEmbedded1: FileEmbedded1{
+			Append: nil,
+			Contents: Resource{
+				Source: EncodeDataURLPtr(GetReadyStatusScript()),
+			},
+			Mode: IntToPtr(0755),
+		},
+	})
+
 	files = append(files, File{
 		Node: Node{
 			Path: PodmanDockerTmpConfPath,
@@ -645,6 +673,49 @@ func (i *IgnitionBuilder) BuildWithIgnitionFile(ignPath string) error {
 	return os.WriteFile(i.dynamicIgnition.WritePath, inputIgnition, 0644)
 }
 
+// Config returns the internal `DynamicIgnition` config
+func (i *IgnitionBuilder) Config() Config {
+	return i.dynamicIgnition.Cfg
+}
+
+// MergeFrom keeps the systemd units, files and links of a previously
+// generated config that are not part of the internal `DynamicIgnition`
+// config, e.g. the ones added by a provider when the machine was created.
+// The SSH keys authorized for a user in the previous config stay authorized.
+func (i *IgnitionBuilder) MergeFrom(previous Config) {
+	cfg := &i.dynamicIgnition.Cfg
+
+	for _, prevUser := range previous.Passwd.Users {
+		for j := range cfg.Passwd.Users {
+			user := &cfg.Passwd.Users[j]
+			if user.Name != prevUser.Name {
+				continue
+			}
+			for _, key := range prevUser.SSHAuthorizedKeys {
+				if !slices.Contains(user.SSHAuthorizedKeys, key) {
+					user.SSHAuthorizedKeys = append(user.SSHAuthorizedKeys, key)
+				}
+			}
+		}
+	}
+
+	for _, unit := range previous.Systemd.Units {
+		if !slices.ContainsFunc(cfg.Systemd.Units, func(u Unit) bool { return u.Name == unit.Name }) {
+			cfg.Systemd.Units = append(cfg.Systemd.Units, unit)
+		}
+	}
+	for _, file := range previous.Storage.Files {
+		if !slices.ContainsFunc(cfg.Storage.Files, func(f File) bool { return f.Path == file.Path }) {
+			cfg.Storage.Files = append(cfg.Storage.Files, file)
+		}
+	}
+	for _, link := range previous.Storage.Links {
+		if !slices.ContainsFunc(cfg.Storage.Links, func(l Link) bool { return l.Path == link.Path }) {
+			cfg.Storage.Links = append(cfg.Storage.Links, link)
+		}
+	}
+}
+
 // Build writes the internal `DynamicIgnition` config to its write path
 func (i *IgnitionBuilder) Build() error {
 	logrus.Debugf("writing ignition file to %q", i.dynamicIgnition.WritePath)
@@ -7,6 +7,10 @@ import (
 	"github.com/containers/podman/v5/pkg/machine/define"
 )
 
+// ReadyStatusScriptPath is the guest path of the script that prints the
+// status the ready unit sends to the host
+const ReadyStatusScriptPath = "/usr/local/bin/podman-machine-ready"
+
 // ReadyUnitOpts are options for creating the ready unit that reports back to podman
 // when the system is booted
 type ReadyUnitOpts struct {
@@ -20,10 +24,10 @@ func CreateReadyUnitFile(provider define.VMType, opts *ReadyUnitOpts) (string, e
 	case define.QemuVirt:
 		readyUnit.Add("Unit", "Requires", "dev-virtio\\x2dports-vport1p1.device")
 		readyUnit.Add("Unit", "After", "systemd-user-sessions.service")
-		readyUnit.Add("Service", "ExecStart", "/bin/sh -c '/usr/bin/echo Ready >/dev/vport1p1'")
+		readyUnit.Add("Service", "ExecStart", "/bin/sh -c '"+ReadyStatusScriptPath+" %n >/dev/vport1p1'")
 	case define.AppleHvVirt:
 		readyUnit.Add("Unit", "Requires", "dev-virtio\\x2dports-vsock.device")
-		readyUnit.Add("Service", "ExecStart", "/bin/sh -c '/usr/bin/echo Ready | socat - VSOCK-CONNECT:2:1025'")
+		readyUnit.Add("Service", "ExecStart", "/bin/sh -c '"+ReadyStatusScriptPath+" %n | socat - VSOCK-CONNECT:2:1025'")
 	case define.HyperVVirt:
 		if opts == nil || opts.Port == 0 {
 			return "", errors.New("no port provided for hyperv ready unit")
@@ -31,7 +35,7 @@ func CreateReadyUnitFile(provider define.VMType, opts *ReadyUnitOpts) (string, e
 		readyUnit.Add("Unit", "Requires", "sys-devices-virtual-net-vsock0.device")
 		readyUnit.Add("Unit", "After", "systemd-user-sessions.service")
 		readyUnit.Add("Unit", "After", "vsock-network.service")
-		readyUnit.Add("Service", "ExecStart", fmt.Sprintf("/bin/sh -c '/usr/bin/echo Ready | socat - VSOCK-CONNECT:2:%d'", opts.Port))
+		readyUnit.Add("Service", "ExecStart", fmt.Sprintf("/bin/sh -c '%s %%n | socat - VSOCK-CONNECT:2:%d'", ReadyStatusScriptPath, opts.Port))
 	case define.WSLVirt: // WSL does not use ignition
 		return "", nil
 	default:
@@ -39,3 +43,31 @@ func CreateReadyUnitFile(provider define.VMType, opts *ReadyUnitOpts) (string, e
 	}
 	return readyUnit.ToString()
 }
+
+// GetReadyStatusScript returns the script that prints the line the ready unit
+// sends to the host: "Ready" followed by the ignition result, the name of the
+// unit given as first argument, the uptime of the guest kernel, the units
+// that failed, whether the guest has a default route and the last errors
+// ignition logged
+func GetReadyStatusScript() string {
+	return `#!/bin/sh
+# json_array prints the lines of its input as a JSON array of strings
+json_array() {
+  tr -d '\000-\010\013-\037' | sed -e 's/\\/\\\\/g' -e 's/"/\\"/g' -e 's/\t/ /g' |
+    awk 'BEGIN { printf "[" } NR > 1 { printf "," } { printf "\"%s\"", $0 } END { printf "]" }'
+}
+ignition=unknown
+if [ -f /etc/.ignition-result.json ]; then
+  ignition=success
+fi
+uptime=$(cut -d ' ' -f 1 /proc/uptime)
+failed=$(systemctl list-units --state=failed --no-legend --plain 2>/dev/null | cut -d ' ' -f 1 | json_array)
+network=offline
+if [ -n "$(ip route show default 2>/dev/null)" ]; then
+  network=online
+fi
+ignition_errors=$(journalctl -b -q -o cat -p err -t ignition 2>/dev/null | tail -n 5 | json_array)
+printf 'Ready {"ignition":"%s","unit":"%s","uptime":"%s","failed_units":%s,"network":"%s","ignition_errors":%s}\n' \
+  "$ignition" "$1" "$uptime" "$failed" "$network" "$ignition_errors"
+`
+}
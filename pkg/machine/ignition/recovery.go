@@ -0,0 +1,57 @@
+package ignition
+
+import (
+	"strings"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/systemd/parser"
+)
+
+// RecoveryUnitName is the name of the unit that switches a machine booted for
+// repair to the recovery target
+const RecoveryUnitName = "podman-machine-recovery.service"
+
+// SystemdCredential is a credential passed to systemd in the guest as it boots
+type SystemdCredential struct {
+	Name  string
+	Value string
+}
+
+// RecoveryCredentials returns the credentials that make systemd in the guest
+// isolate override.Target early in the boot, with the units of
+// override.MaskUnits masked and the root shell unlocked.  The guest picks them
+// up with systemd 256 or later, whatever its ignition config.
+func RecoveryCredentials(override define.RecoveryOverride) ([]SystemdCredential, error) {
+	unit := parser.NewUnitFile()
+	unit.Add("Unit", "Description", "Boot the machine for repair")
+	unit.Add("Unit", "DefaultDependencies", "no")
+	unit.Add("Unit", "Before", "sysinit.target")
+	unit.Add("Service", "Type", "oneshot")
+	if len(override.MaskUnits) > 0 {
+		units := make([]string, 0, len(override.MaskUnits))
+		for _, u := range override.MaskUnits {
+			units = append(units, strings.ReplaceAll(u, `\`, `\\`))
+		}
+		unit.Add("Service", "ExecStart", "/usr/bin/systemctl mask --runtime -- "+strings.Join(units, " "))
+	}
+	// Fedora CoreOS locks the root account, which keeps the emergency shell
+	// from starting without this
+	unit.Add("Service", "ExecStart", "/usr/bin/systemctl set-environment SYSTEMD_SULOGIN_FORCE=1")
+	unit.Add("Service", "ExecStart", "/usr/bin/systemctl --no-block isolate "+override.Target)
+	unitString, err := unit.ToString()
+	if err != nil {
+		return nil, err
+	}
+
+	wants := parser.NewUnitFile()
+	wants.Add("Unit", "Wants", RecoveryUnitName)
+	wantsString, err := wants.ToString()
+	if err != nil {
+		return nil, err
+	}
+
+	return []SystemdCredential{
+		{Name: "systemd.extra-unit." + RecoveryUnitName, Value: unitString},
+		{Name: "systemd.unit-dropin.sysinit.target~podman-machine-recovery", Value: wantsString},
+	}, nil
+}
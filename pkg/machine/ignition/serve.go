@@ -0,0 +1,38 @@
+//go:build amd64 || arm64
+
+package ignition
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/sirupsen/logrus"
+)
+
+// ServeOverSocket serves the ignition file to the guest over listener, a
+// unix socket the provider forwards a vsock port of the guest to, until the
+// listener is closed.  The file is read when the guest asks for it, so the
+// config may change until the machine first boots without regenerating the
+// media it boots from.
+func ServeOverSocket(listener net.Listener, ignitionFile *define.VMFile) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		logrus.Debugf("serving ignition file %s", ignitionFile.GetPath())
+		ignFile, err := ignitionFile.Read()
+		if err != nil {
+			logrus.Errorf("failed to read ignition file: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := w.Write(ignFile); err != nil {
+			logrus.Errorf("failed to serve ignition file: %v", err)
+		}
+	})
+	err := http.Serve(listener, mux)
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
@@ -31,8 +31,9 @@ func GetDevNullFiles() (*os.File, *os.File, error) {
 }
 
 // WaitAPIAndPrintInfo prints info about the machine and does a ping test on the
-// API socket
-func WaitAPIAndPrintInfo(forwardState APIForwardingState, name, helper, forwardSock string, noInfo, rootful bool) {
+// API sockets.  secondarySock is the socket of the mode the machine does not
+// default to, empty when it is not forwarded.
+func WaitAPIAndPrintInfo(forwardState APIForwardingState, name, helper, forwardSock, secondarySock string, noInfo, rootful bool) {
 	suffix := ""
 	var fmtString string
 
@@ -45,9 +46,15 @@ func WaitAPIAndPrintInfo(forwardState APIForwardingState, name, helper, forwardS
 	}
 
 	WaitAndPingAPI(forwardSock)
+	if secondarySock != "" {
+		WaitAndPingAPI(secondarySock)
+	}
 
 	if !noInfo {
 		fmt.Printf("API forwarding listening on: %s\n", forwardSock)
+		if secondarySock != "" {
+			fmt.Printf("%s API forwarding listening on: %s\n", secondaryMode(rootful), secondarySock)
+		}
 		if forwardState == DockerGlobal {
 			fmt.Printf("Docker API clients default to this address. You do not need to set DOCKER_HOST.\n\n")
 		} else {
@@ -93,6 +100,14 @@ following command in your terminal session:
 	}
 }
 
+// secondaryMode names the mode of the secondary API forwarding of a machine
+func secondaryMode(rootful bool) string {
+	if rootful {
+		return "Rootless"
+	}
+	return "Rootful"
+}
+
 func PrintRootlessWarning(name string) {
 	suffix := ""
 	if name != DefaultMachineName {
@@ -43,6 +43,9 @@ type WinProxyOpts struct {
 	RemoteUsername string
 	Rootful        bool
 	VMType         define.VMType
+	// SecondaryPipe is the pipe the API of the mode the machine does not
+	// default to is forwarded to, empty for none
+	SecondaryPipe string
 }
 
 func GetProcessState(pid int) (active bool, exitCode int) {
@@ -98,7 +101,7 @@ func DialNamedPipe(ctx context.Context, path string) (net.Conn, error) {
 }
 
 func LaunchWinProxy(opts WinProxyOpts, noInfo bool) {
-	globalName, pipeName, err := launchWinProxy(opts)
+	globalName, pipeName, err := launchWinProxy(&opts)
 	if !noInfo {
 		if err != nil {
 			fmt.Fprintln(os.Stderr, "API forwarding for Docker API clients is not available due to the following startup failures.")
@@ -106,6 +109,9 @@ func LaunchWinProxy(opts WinProxyOpts, noInfo bool) {
 			fmt.Fprintln(os.Stderr, "\nPodman clients are still able to connect.")
 		} else {
 			fmt.Printf("API forwarding listening on: %s\n", pipeName)
+			if opts.SecondaryPipe != "" {
+				fmt.Printf("%s API forwarding listening on: %s\n", secondaryMode(opts.Rootful), NamedPipePrefix+opts.SecondaryPipe)
+			}
 			if globalName {
 				fmt.Printf("\nDocker API clients default to this address. You do not need to set DOCKER_HOST.\n")
 			} else {
@@ -121,7 +127,9 @@ func LaunchWinProxy(opts WinProxyOpts, noInfo bool) {
 	}
 }
 
-func launchWinProxy(opts WinProxyOpts) (bool, string, error) {
+// launchWinProxy starts win-sshproxy.  It clears opts.SecondaryPipe when the
+// secondary API cannot be forwarded.
+func launchWinProxy(opts *WinProxyOpts) (bool, string, error) {
 	machinePipe := ToDist(opts.Name)
 	if !PipeNameAvailable(machinePipe, MachineNameWait) {
 		return false, "", fmt.Errorf("could not start api proxy since expected pipe is not available: %s", machinePipe)
@@ -142,16 +150,16 @@ func launchWinProxy(opts WinProxyOpts) (bool, string, error) {
 		return globalName, "", err
 	}
 
-	destSock := rootlessSock
-	forwardUser := opts.RemoteUsername
-
-	if opts.Rootful {
-		destSock = rootfulSock
-		forwardUser = "root"
-	}
-
-	dest := fmt.Sprintf("ssh://%s@localhost:%d%s", forwardUser, opts.Port, destSock)
+	dest := winProxyDest(opts, opts.Rootful)
 	args := []string{opts.Name, stateDir, NamedPipePrefix + machinePipe, dest, opts.IdentityPath}
+	if opts.SecondaryPipe != "" {
+		if PipeNameAvailable(opts.SecondaryPipe, GlobalNameWait) {
+			args = append(args, NamedPipePrefix+opts.SecondaryPipe, winProxyDest(opts, !opts.Rootful), opts.IdentityPath)
+		} else {
+			logrus.Warnf("Not forwarding the %s API, pipe %s is in use", strings.ToLower(secondaryMode(opts.Rootful)), opts.SecondaryPipe)
+			opts.SecondaryPipe = ""
+		}
+	}
 	waitPipe := machinePipe
 	if globalName {
 		args = append(args, NamedPipePrefix+GlobalNamedPipe, dest, opts.IdentityPath)
@@ -174,6 +182,17 @@ func launchWinProxy(opts WinProxyOpts) (bool, string, error) {
 	})
 }
 
+// winProxyDest is the rootful or rootless API socket in the guest
+func winProxyDest(opts *WinProxyOpts, rootful bool) string {
+	destSock := rootlessSock
+	forwardUser := opts.RemoteUsername
+	if rootful {
+		destSock = rootfulSock
+		forwardUser = "root"
+	}
+	return fmt.Sprintf("ssh://%s@localhost:%d%s", forwardUser, opts.Port, destSock)
+}
+
 func StopWinProxy(name string, vmtype define.VMType) error {
 	pid, tid, tidFile, err := readWinProxyTid(name, vmtype)
 	if err != nil {
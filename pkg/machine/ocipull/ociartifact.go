@@ -3,21 +3,19 @@ package ocipull
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 	"runtime"
-	"strings"
 
-	"github.com/containers/image/v5/docker"
-	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/buildah/pkg/parse"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
 	"github.com/containers/image/v5/transports/alltransports"
 	"github.com/containers/image/v5/types"
 	"github.com/containers/podman/v5/pkg/machine/compression"
 	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/diskcache"
 	"github.com/containers/podman/v5/utils"
-	crc "github.com/crc-org/crc/v2/pkg/os"
 	"github.com/opencontainers/go-digest"
 	specV1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
@@ -42,6 +40,7 @@ type OCIArtifactDisk struct {
 	imageEndpoint            string
 	machineVersion           *OSVersion
 	diskArtifactFileName     string
+	diskDigest               digest.Digest
 	pullOptions              *PullOptions
 	vmType                   define.VMType
 }
@@ -55,28 +54,43 @@ type DiskArtifactOpts struct {
 /*
 
 	This interface is for automatically pulling a disk artifact(qcow2, raw, vhdx file) from a pre-determined
-	image location.  The logic is tied to vmtypes (applehv, qemu, hyperv) and their understanding of the type of
-	disk they require.  The process can be generally described as:
+	image location, or from the image reference given by the user.  The logic is tied to vmtypes (applehv, qemu,
+	hyperv) and their understanding of the type of disk they require.  The process can be generally described as:
 
 	* Determine the flavor of artifact we are looking for (arch, compression, type)
-	* Grab the manifest list for the target
-	* Walk the artifacts to find a match based on flavor
-	* Check the hash of the artifact against the hash of our cached image
-	* If the cached image does not exist or match, pull the latest into an OCI directory
-		* Read the OCI blob's manifest to determine which blob is the artifact disk
-		* Rename/move the blob in the OCI directory to the image cache dir and append the type and compression
-		  i.e. 91d1e51ddfac9d4afb1f96df878089cfdb9ab9be5886f8bccac0f0557ed28974.qcow2.xz
-		* Discard the OCI directory
+	* Grab the manifest of the reference
+		* If it is a manifest list, walk the artifacts to find a match based on flavor
+	* Read the manifest of the artifact to find the single layer holding the disk
+	* Look the digest of the layer up in the image cache; the cached file is named after
+	  the digest with the type and compression appended
+	  i.e. 91d1e51ddfac9d4afb1f96df878089cfdb9ab9be5886f8bccac0f0557ed28974.qcow2.xz
+	* If it is not cached, stream the layer blob into the cache, verifying its digest
 	* Decompress the cached image to the image dir in the form of <vmname>-<arch>.<raw|vhdx|qcow2>
 
 */
 
 func NewOCIArtifactPull(ctx context.Context, dirs *define.MachineDirs, vmName string, vmType define.VMType, finalPath *define.VMFile) (*OCIArtifactDisk, error) {
+	artifactVersion := getVersion()
+	endpoint := fmt.Sprintf("docker://%s/%s/%s:%s", artifactRegistry, artifactRepo, artifactImageName, artifactVersion.majorMinor())
+	ociDisk, err := NewOCIArtifactPullFromReference(ctx, dirs, endpoint, vmName, vmType, finalPath)
+	if err != nil {
+		return nil, err
+	}
+	ociDisk.machineVersion = artifactVersion
+	return ociDisk, nil
+}
+
+// NewOCIArtifactPullFromReference pulls the disk artifact from the given image
+// reference, which includes its transport, e.g. docker://quay.io/org/image:tag
+// for a registry or oci:/path/to/layout:tag for a local OCI layout.  The
+// reference may be a manifest list, in which case the artifact matching the
+// architecture and the disk type of the machine is pulled, or the manifest of
+// a single disk artifact.
+func NewOCIArtifactPullFromReference(ctx context.Context, dirs *define.MachineDirs, imageReference string, vmName string, vmType define.VMType, finalPath *define.VMFile) (*OCIArtifactDisk, error) {
 	var (
 		arch string
 	)
 
-	artifactVersion := getVersion()
 	switch runtime.GOARCH {
 	case "amd64":
 		arch = "x86_64"
@@ -96,8 +110,7 @@ func NewOCIArtifactPull(ctx context.Context, dirs *define.MachineDirs, vmName st
 		dirs:             dirs,
 		diskArtifactOpts: &diskOpts,
 		finalPath:        finalPath.GetPath(),
-		imageEndpoint:    fmt.Sprintf("docker://%s/%s/%s:%s", artifactRegistry, artifactRepo, artifactImageName, artifactVersion.majorMinor()),
-		machineVersion:   artifactVersion,
+		imageEndpoint:    imageReference,
 		name:             vmName,
 		pullOptions:      &PullOptions{},
 		vmType:           vmType,
@@ -120,165 +133,167 @@ func (o *OCIArtifactDisk) GetNoCompress() error {
 	return o.get()
 }
 
-func (o *OCIArtifactDisk) get() error {
-	destRef, artifactDigest, err := o.getDestArtifact()
-	if err != nil {
-		return err
-	}
+// Reference returns the image reference the disk artifact is pulled from
+func (o *OCIArtifactDisk) Reference() string {
+	return o.imageEndpoint
+}
+
+// Digest returns the digest of the pulled disk image
+func (o *OCIArtifactDisk) Digest() digest.Digest {
+	return o.diskDigest
+}
 
-	// Note: the artifactDigest here is the hash of the most recent disk image available
-	cachedImagePath, err := o.dirs.ImageCacheDir.AppendToNewVMFile(fmt.Sprintf("%s.%s", artifactDigest.Encoded(), o.vmType.ImageFormat().KindWithCompression()), nil)
+// Resolve returns the digest of the disk image the reference currently
+// points to, without pulling it
+func (o *OCIArtifactDisk) Resolve() (digest.Digest, error) {
+	imgSrc, err := o.openSource()
 	if err != nil {
-		return err
+		return "", err
 	}
-
-	// check if we have the latest and greatest disk image
-	if _, err = os.Stat(cachedImagePath.GetPath()); err != nil {
-		if !errors.Is(err, os.ErrNotExist) {
-			return fmt.Errorf("unable to access cached image path %q: %q", cachedImagePath.GetPath(), err)
-		}
-		// pull the image down to our local filesystem
-		if err := o.pull(destRef, artifactDigest); err != nil {
-			return err
-		}
-		// grab the artifact disk out of the cache and lay
-		// it into our local cache in the format of
-		// hash + disktype + compression
-		//
-		// in cache it will be used until it is "outdated"
-		//
-		// i.e. 91d1e51...d28974.qcow2.xz
-		if err := o.unpack(artifactDigest); err != nil {
-			return err
+	defer func() {
+		if err := imgSrc.Close(); err != nil {
+			logrus.Warn(err)
 		}
-	} else {
-		logrus.Debugf("cached image exists and is latest: %s", cachedImagePath.GetPath())
-		o.cachedCompressedDiskPath = cachedImagePath
+	}()
+	layer, err := o.getDiskLayer(imgSrc)
+	if err != nil {
+		return "", err
 	}
-	return nil
+	return layer.Digest, nil
 }
 
-func (o *OCIArtifactDisk) getDestArtifact() (types.ImageReference, digest.Digest, error) {
+func (o *OCIArtifactDisk) openSource() (types.ImageSource, error) {
 	imgRef, err := alltransports.ParseImageName(o.imageEndpoint)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 	sysCtx := &types.SystemContext{
 		DockerInsecureSkipTLSVerify: types.NewOptionalBool(!o.pullOptions.TLSVerify),
 	}
-	imgSrc, err := imgRef.NewImageSource(o.ctx, sysCtx)
-	if err != nil {
-		return nil, "", err
+	if o.pullOptions.Credentials != "" {
+		authConf, err := parse.AuthConfig(o.pullOptions.Credentials)
+		if err != nil {
+			return nil, err
+		}
+		sysCtx.DockerAuthConfig = authConf
 	}
+	return imgRef.NewImageSource(o.ctx, sysCtx)
+}
 
+func (o *OCIArtifactDisk) get() error {
+	imgSrc, err := o.openSource()
+	if err != nil {
+		return err
+	}
 	defer func() {
 		if err := imgSrc.Close(); err != nil {
 			logrus.Warn(err)
 		}
 	}()
 
-	diskArtifactDigest, err := GetDiskArtifactReference(o.ctx, imgSrc, o.diskArtifactOpts)
-	if err != nil {
-		return nil, "", err
-	}
-	// create a ref now and return
-	named := imgRef.DockerReference()
-	digestedRef, err := reference.WithDigest(reference.TrimNamed(named), diskArtifactDigest)
+	layer, err := o.getDiskLayer(imgSrc)
 	if err != nil {
-		return nil, "", err
+		return err
 	}
+	o.diskArtifactFileName = layer.Annotations[artifactOriginalName]
 
-	// Get and "store" the original filename the disk artifact had
-	originalFileName, err := getOriginalFileName(o.ctx, imgSrc, diskArtifactDigest)
+	// the layer digest is the hash of the compressed disk image, any source
+	// serving the same disk shares the cached image
+	cache := diskcache.New(o.dirs.ImageCacheDir)
+	suffix := diskcache.KindAndCompression(o.diskArtifactFileName)
+	cachedImagePath, ok, err := cache.Lookup(layer.Digest, suffix)
 	if err != nil {
-		return nil, "", err
+		return fmt.Errorf("unable to access cached image for %s: %w", layer.Digest, err)
 	}
-	o.diskArtifactFileName = originalFileName
-
-	newRef, err := docker.NewReference(digestedRef)
-	if err != nil {
-		return nil, "", err
+	if !ok {
+		if cachedImagePath, err = o.pull(imgSrc, layer, cache, suffix); err != nil {
+			return err
+		}
 	}
-	return newRef, diskArtifactDigest, err
+	o.cachedCompressedDiskPath = cachedImagePath
+	o.diskDigest = layer.Digest
+	return nil
 }
 
-func (o *OCIArtifactDisk) pull(destRef types.ImageReference, artifactDigest digest.Digest) error {
-	destFileName := artifactDigest.Encoded()
-	destFile, err := o.dirs.ImageCacheDir.AppendToNewVMFile(destFileName, nil)
+// getDiskLayer returns the descriptor of the layer holding the disk image
+func (o *OCIArtifactDisk) getDiskLayer(imgSrc types.ImageSource) (*specV1.Descriptor, error) {
+	_, mannyType, err := imgSrc.GetManifest(o.ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return Pull(o.ctx, destRef, destFile, o.pullOptions)
-}
 
-func (o *OCIArtifactDisk) unpack(diskArtifactHash digest.Digest) error {
-	finalSuffix := extractKindAndCompression(o.diskArtifactFileName)
-	blobDir, err := o.dirs.ImageCacheDir.AppendToNewVMFile(diskArtifactHash.Encoded(), nil)
-	if err != nil {
-		return err
-	}
-	cachedCompressedPath, err := o.dirs.ImageCacheDir.AppendToNewVMFile(diskArtifactHash.Encoded()+finalSuffix, nil)
-	if err != nil {
-		return err
+	var instance *digest.Digest
+	if manifest.MIMETypeIsMultiImage(mannyType) {
+		diskArtifactDigest, err := GetDiskArtifactReference(o.ctx, imgSrc, o.diskArtifactOpts)
+		if err != nil {
+			return nil, err
+		}
+		instance = &diskArtifactDigest
 	}
+	return getDiskLayerDescriptor(o.ctx, imgSrc, instance)
+}
 
-	o.cachedCompressedDiskPath = cachedCompressedPath
-
-	blobInfo, err := GetLocalBlob(o.ctx, blobDir.GetPath())
+// pull streams the disk layer into the image cache
+func (o *OCIArtifactDisk) pull(imgSrc types.ImageSource, layer *specV1.Descriptor, cache *diskcache.Cache, suffix string) (*define.VMFile, error) {
+	blob, size, err := imgSrc.GetBlob(o.ctx, types.BlobInfo{Digest: layer.Digest, Size: layer.Size}, none.NoCache)
 	if err != nil {
-		return fmt.Errorf("unable to get local manifest for %s: %q", blobDir.GetPath(), err)
+		return nil, fmt.Errorf("pulling disk image %s: %w", layer.Digest, err)
 	}
-
-	diskBlobPath := filepath.Join(blobDir.GetPath(), "blobs", "sha256", blobInfo.Digest.Encoded())
-
-	// Rename and move the hashed blob file to the cache dir.
-	// If the rename fails, we do a sparsecopy instead
-	if err := os.Rename(diskBlobPath, cachedCompressedPath.GetPath()); err != nil {
-		logrus.Errorf("renaming compressed image %q failed: %q", cachedCompressedPath.GetPath(), err)
-		logrus.Error("trying again using copy")
-		if err := crc.CopyFileSparse(diskBlobPath, cachedCompressedPath.GetPath()); err != nil {
-			return err
+	defer func() {
+		if err := blob.Close(); err != nil {
+			logrus.Error(err)
 		}
+	}()
+
+	var r io.Reader = blob
+	if !o.pullOptions.Quiet {
+		prefix := "Downloading VM image: " + o.diskArtifactFileName
+		p, bar := utils.ProgressBar(prefix, size, prefix+": done")
+		proxyReader := bar.ProxyReader(blob)
+		defer func() {
+			if err := proxyReader.Close(); err != nil {
+				logrus.Error(err)
+			}
+			p.Wait()
+		}()
+		r = proxyReader
 	}
 
-	// Clean up the oci dir which is no longer needed
-	return utils.GuardedRemoveAll(blobDir.GetPath())
+	cached, _, err := cache.Store(r, layer.Digest, suffix)
+	if err != nil {
+		return nil, fmt.Errorf("pulling disk image %s: %w", layer.Digest, err)
+	}
+	return cached, nil
 }
 
 func (o *OCIArtifactDisk) decompress() error {
 	return compression.Decompress(o.cachedCompressedDiskPath, o.finalPath)
 }
 
-func getOriginalFileName(ctx context.Context, imgSrc types.ImageSource, artifactDigest digest.Digest) (string, error) {
-	v1RawMannyfest, _, err := imgSrc.GetManifest(ctx, &artifactDigest)
+// getDiskLayerDescriptor returns the single layer of the disk artifact
+// manifest with the given instance digest, or of the top level manifest if
+// instance is nil
+func getDiskLayerDescriptor(ctx context.Context, imgSrc types.ImageSource, instance *digest.Digest) (*specV1.Descriptor, error) {
+	v1RawMannyfest, _, err := imgSrc.GetManifest(ctx, instance)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	v1MannyFest := specV1.Manifest{}
 	if err := json.Unmarshal(v1RawMannyfest, &v1MannyFest); err != nil {
-		return "", err
+		return nil, err
 	}
-	if layerLen := len(v1MannyFest.Layers); layerLen > 1 {
-		return "", fmt.Errorf("podman-machine images should only have 1 layer: %d found", layerLen)
+	if layerLen := len(v1MannyFest.Layers); layerLen != 1 {
+		return nil, fmt.Errorf("podman-machine images should only have 1 layer: %d found", layerLen)
 	}
 
 	// podman-machine-images should have an original file name
 	// stored in the annotations under org.opencontainers.image.title
 	// i.e. fedora-coreos-39.20240128.2.2-qemu.x86_64.qcow2.xz
-	originalFileName, ok := v1MannyFest.Layers[0].Annotations[artifactOriginalName]
+	layer := v1MannyFest.Layers[0]
+	originalFileName, ok := layer.Annotations[artifactOriginalName]
 	if !ok {
-		return "", fmt.Errorf("unable to determine original artifact name: missing required annotation 'org.opencontainers.image.title'")
+		return nil, fmt.Errorf("unable to determine original artifact name: missing required annotation 'org.opencontainers.image.title'")
 	}
 	logrus.Debugf("original artifact file name: %s", originalFileName)
-	return originalFileName, nil
-}
-
-// extractKindAndCompression extracts the vmimage type and the compression type
-// this is used for when we rename the blob from its hash to something real
-// i.e. fedora-coreos-39.20240128.2.2-qemu.x86_64.qcow2.xz would return qcow2.xz
-func extractKindAndCompression(name string) string {
-	compressAlgo := filepath.Ext(name)
-	compressStrippedName := strings.TrimSuffix(name, compressAlgo)
-	kind := filepath.Ext(compressStrippedName)
-	return kind + compressAlgo
+	return &layer, nil
 }
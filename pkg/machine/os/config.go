@@ -6,9 +6,23 @@ package os
 type Manager interface {
 	// Apply machine OS changes from an OCI image.
 	Apply(image string, opts ApplyOptions) error
+	// Upgrade the machine OS to the latest release it follows, or roll it
+	// back to the release it ran before.
+	Upgrade(opts UpgradeOptions) error
 }
 
 // ApplyOptions are the options for applying an image into a Podman machine VM
 type ApplyOptions struct {
 	Image string
 }
+
+// UpgradeOptions are the options for upgrading the OS of a Podman machine
+type UpgradeOptions struct {
+	// Check only reports whether an upgrade is available
+	Check bool
+	// Rollback returns to the release that ran before the last upgrade
+	Rollback bool
+	// Disk replaces the disk image of the machine by the latest disk image
+	// published on its image source instead of upgrading it in place
+	Disk bool
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
 	"github.com/containers/podman/v5/pkg/machine/shim"
 	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
 )
@@ -33,13 +34,89 @@ func (m *MachineOS) Apply(image string, opts ApplyOptions) error {
 	}
 
 	if m.Restart {
-		if err := shim.Stop(m.VM, m.Provider, dirs, false); err != nil {
+		if _, err := shim.Stop(m.VM, m.Provider, dirs, machine.StopOptions{}); err != nil {
 			return err
 		}
-		if err := shim.Start(m.VM, m.Provider, dirs, machine.StartOptions{NoInfo: true}); err != nil {
+		if _, err := shim.Start(m.VM, m.Provider, dirs, machine.StartOptions{NoInfo: true}); err != nil {
 			return err
 		}
 		fmt.Printf("Machine %q restarted successfully\n", m.VMName)
 	}
 	return nil
 }
+
+// Upgrade upgrades the OS of the machine.  Machines running an ostree based
+// OS are upgraded in place by sshing into the machine and running upgrade
+// from inside the VM, unless the disk image is to be replaced.  The disk image
+// of a stopped machine is replaced from the host.
+func (m *MachineOS) Upgrade(opts UpgradeOptions) error {
+	dirs, err := machine.GetMachineDirs(m.Provider.VMType())
+	if err != nil {
+		return err
+	}
+
+	if opts.Disk || !ostreeBased(m.Provider.VMType()) {
+		return m.upgradeDisk(opts, dirs)
+	}
+
+	args := []string{"podman", "machine", "os", "upgrade"}
+	if opts.Check {
+		args = append(args, "--check")
+	}
+	if opts.Rollback {
+		args = append(args, "--rollback")
+	}
+	if err := machine.CommonSSH(m.VM.SSH.RemoteUsername, m.VM.SSH.IdentityPath, m.VMName, m.VM.SSH.Port, args); err != nil {
+		return err
+	}
+
+	if m.Restart && !opts.Check {
+		if _, err := shim.Stop(m.VM, m.Provider, dirs, machine.StopOptions{}); err != nil {
+			return err
+		}
+		if _, err := shim.Start(m.VM, m.Provider, dirs, machine.StartOptions{NoInfo: true}); err != nil {
+			return err
+		}
+		fmt.Printf("Machine %q restarted successfully\n", m.VMName)
+	}
+	return nil
+}
+
+func (m *MachineOS) upgradeDisk(opts UpgradeOptions, dirs *define.MachineDirs) error {
+	switch {
+	case opts.Check:
+		update, err := shim.CheckOSUpdate(m.VM, m.Provider, dirs)
+		if err != nil {
+			return err
+		}
+		if !update.Available() {
+			fmt.Printf("Machine %q is up to date with %s\n", m.VMName, update.Source)
+			return nil
+		}
+		fmt.Printf("A new disk image %s is available for machine %q from %s\n", update.Latest, m.VMName, update.Source)
+		return nil
+	case opts.Rollback:
+		if err := shim.RollbackDisk(m.VM, m.Provider); err != nil {
+			return err
+		}
+		fmt.Printf("Machine %q rolled back to its previous disk image\n", m.VMName)
+		return nil
+	}
+
+	upgraded, err := shim.UpgradeDisk(m.VM, m.Provider, dirs)
+	if err != nil {
+		return err
+	}
+	if !upgraded {
+		fmt.Printf("Machine %q is up to date\n", m.VMName)
+		return nil
+	}
+	fmt.Printf("Machine %q upgraded to disk image %s, it is provisioned again on its next start\n", m.VMName, m.VM.ImageDigest)
+	return nil
+}
+
+// ostreeBased reports whether the machines of the provider run an ostree
+// based OS, which is upgraded in place.  WSL machines run Fedora.
+func ostreeBased(vmType define.VMType) bool {
+	return vmType != define.WSLVirt
+}
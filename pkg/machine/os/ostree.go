@@ -104,6 +104,35 @@ func (dist *OSTree) Apply(image string, opts ApplyOptions) error {
 	return cmd.Run()
 }
 
+// Upgrade upgrades the deployment in place to the latest release of the
+// image it follows, with bootc on image based systems and rpm-ostree
+// otherwise, or rolls back to the previous deployment.  The new deployment is
+// booted on the next restart.
+func (dist *OSTree) Upgrade(opts UpgradeOptions) error {
+	if opts.Disk {
+		return errors.New("the disk image of a machine can only be upgraded from the host")
+	}
+
+	tool := "rpm-ostree"
+	if _, err := exec.LookPath("bootc"); err == nil {
+		tool = "bootc"
+	}
+	args := []string{tool}
+	switch {
+	case opts.Rollback:
+		args = append(args, "rollback")
+	case opts.Check:
+		args = append(args, "upgrade", "--check")
+	default:
+		args = append(args, "upgrade")
+	}
+
+	cmd := exec.Command("sudo", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
 // pathSafeString creates a path-safe name for our tmpdirs
 func pathSafeString(str string) string {
 	alphanumOnly := regexp.MustCompile(`[^a-zA-Z0-9]+`)
@@ -0,0 +1,132 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StartPhase names a discrete step taken while starting a machine
+type StartPhase string
+
+const (
+	// PhaseNetworking is the host side networking setup (e.g. gvproxy)
+	PhaseNetworking StartPhase = "networking"
+	// PhaseStartVM is the provider launching the hypervisor
+	PhaseStartVM StartPhase = "start-vm"
+	// PhaseWaitForReady is the time until the guest trips the ready socket
+	PhaseWaitForReady StartPhase = "wait-for-ready"
+	// PhaseReadiness is the state and ssh readiness probe
+	PhaseReadiness StartPhase = "readiness"
+	// PhaseProxies is applying the host proxy configuration to the guest
+	PhaseProxies StartPhase = "proxies"
+	// PhaseMounts is mounting volumes into the guest
+	PhaseMounts StartPhase = "mounts"
+	// PhaseHooks is running the first boot and post-start hooks in the guest
+	PhaseHooks StartPhase = "hooks"
+	// PhaseAPIWait is waiting for the forwarded API socket to come up
+	PhaseAPIWait StartPhase = "api-wait"
+)
+
+// ProgressEvent is emitted when a phase of a machine operation begins or ends
+type ProgressEvent struct {
+	Phase StartPhase
+	// Done is false when the phase begins and true when it ends
+	Done bool
+	Time time.Time
+}
+
+// PhaseDuration is the wall clock time spent in a single phase
+type PhaseDuration struct {
+	Phase    StartPhase
+	Duration time.Duration
+}
+
+// StartReport is the machine-readable result of starting a machine
+type StartReport struct {
+	Phases []PhaseDuration
+	Total  time.Duration
+}
+
+// String returns a compact, single line summary of the report
+func (r *StartReport) String() string {
+	parts := make([]string, 0, len(r.Phases)+1)
+	for _, p := range r.Phases {
+		parts = append(parts, fmt.Sprintf("%s=%s", p.Phase, p.Duration.Round(time.Millisecond)))
+	}
+	parts = append(parts, fmt.Sprintf("total=%s", r.Total.Round(time.Millisecond)))
+	return strings.Join(parts, " ")
+}
+
+// Attributes returns the report in a form suitable for event attributes
+func (r *StartReport) Attributes() map[string]string {
+	attrs := make(map[string]string, len(r.Phases)+1)
+	for _, p := range r.Phases {
+		attrs["phase."+string(p.Phase)] = p.Duration.String()
+	}
+	attrs["phase.total"] = r.Total.String()
+	return attrs
+}
+
+// PhaseRecorder derives phase durations from progress events so that
+// the events are the only source of timing information.  Every event
+// is also handed to an optional listener.
+type PhaseRecorder struct {
+	lock     sync.Mutex
+	listener func(ProgressEvent)
+	first    time.Time
+	last     time.Time
+	started  map[StartPhase]time.Time
+	phases   []PhaseDuration
+}
+
+// NewPhaseRecorder returns a recorder forwarding events to listener, which may be nil
+func NewPhaseRecorder(listener func(ProgressEvent)) *PhaseRecorder {
+	return &PhaseRecorder{
+		listener: listener,
+		started:  make(map[StartPhase]time.Time),
+	}
+}
+
+// Begin marks the start of a phase
+func (p *PhaseRecorder) Begin(phase StartPhase) {
+	p.Record(ProgressEvent{Phase: phase, Time: time.Now()})
+}
+
+// End marks the end of a phase
+func (p *PhaseRecorder) End(phase StartPhase) {
+	p.Record(ProgressEvent{Phase: phase, Done: true, Time: time.Now()})
+}
+
+// Record consumes a single progress event
+func (p *PhaseRecorder) Record(ev ProgressEvent) {
+	p.lock.Lock()
+	if p.first.IsZero() {
+		p.first = ev.Time
+	}
+	p.last = ev.Time
+	if !ev.Done {
+		p.started[ev.Phase] = ev.Time
+	} else if begin, ok := p.started[ev.Phase]; ok {
+		delete(p.started, ev.Phase)
+		p.phases = append(p.phases, PhaseDuration{Phase: ev.Phase, Duration: ev.Time.Sub(begin)})
+	}
+	p.lock.Unlock()
+
+	if p.listener != nil {
+		p.listener(ev)
+	}
+}
+
+// Report returns the durations of all completed phases in the order they finished
+func (p *PhaseRecorder) Report() *StartReport {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	phases := make([]PhaseDuration, len(p.phases))
+	copy(phases, p.phases)
+	return &StartReport{
+		Phases: phases,
+		Total:  p.last.Sub(p.first),
+	}
+}
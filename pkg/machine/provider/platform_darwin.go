@@ -33,3 +33,8 @@ func Get() (vmconfigs.VMProvider, error) {
 		return nil, fmt.Errorf("unsupported virtualization provider: `%s`", resolvedVMType.String())
 	}
 }
+
+// GetAll returns the providers podman machine supports on this platform
+func GetAll() []vmconfigs.VMProvider {
+	return []vmconfigs.VMProvider{new(applehv.AppleHVStubber)}
+}
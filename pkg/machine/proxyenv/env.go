@@ -32,26 +32,82 @@ done
 systemctl daemon-reload
 `
 
-func getProxyScript(isWSL bool) io.Reader {
+// proxyRefreshScriptTemplate applies the new environment to the running
+// system and user managers, so that the services they start from now on use
+// it, and restarts the podman API services
+const proxyRefreshScriptTemplate = `
+%s
+systemctl try-restart podman.service podman.socket
+systemctl --user -M %s@ try-restart podman.service podman.socket || true
+`
+
+// HostProxies returns the proxy variables of the host as they are set in the
+// machine, in the KEY=value form
+func HostProxies(mc *vmconfigs.MachineConfig) []string {
 	var envs []string
 	for _, key := range config.ProxyEnv {
 		if value, ok := os.LookupEnv(key); ok {
 			// WSL does not use host.containers.internal as valid name for the VM.
-			if !isWSL {
+			if mc.WSLHypervisor == nil {
 				value = strings.ReplaceAll(value, "127.0.0.1", etchosts.HostContainersInternal)
 				value = strings.ReplaceAll(value, "localhost", etchosts.HostContainersInternal)
 			}
-			// %q to quote the value correctly
-			envs = append(envs, fmt.Sprintf("%q", key+"="+value))
+			envs = append(envs, key+"="+value)
 		}
 	}
+	return envs
+}
+
+// quote quotes the values for the shell, %q quotes them correctly
+func quote(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, fmt.Sprintf("%q", value))
+	}
+	return strings.Join(quoted, " ")
+}
 
-	script := fmt.Sprintf(proxySetupScriptTemplate, strings.Join(envs, " "))
+func getProxyScript(mc *vmconfigs.MachineConfig) io.Reader {
+	script := fmt.Sprintf(proxySetupScriptTemplate, quote(HostProxies(mc)))
 	logrus.Tracef("Final environment variable setup script: %s", script)
 	return strings.NewReader(script)
 }
 
+// getProxyRefreshScript writes the proxy configuration like getProxyScript
+// and applies it to the running machine
+func getProxyRefreshScript(mc *vmconfigs.MachineConfig) io.Reader {
+	envs := HostProxies(mc)
+	var unset []string
+	for _, key := range config.ProxyEnv {
+		if _, ok := os.LookupEnv(key); !ok {
+			unset = append(unset, key)
+		}
+	}
+
+	var managerCmds []string
+	for _, manager := range []string{"systemctl", fmt.Sprintf("systemctl --user -M %s@", mc.SSH.RemoteUsername)} {
+		if len(envs) > 0 {
+			managerCmds = append(managerCmds, fmt.Sprintf("%s set-environment %s || true", manager, quote(envs)))
+		}
+		if len(unset) > 0 {
+			managerCmds = append(managerCmds, fmt.Sprintf("%s unset-environment %s || true", manager, strings.Join(unset, " ")))
+		}
+	}
+
+	script := fmt.Sprintf(proxySetupScriptTemplate, quote(envs)) +
+		fmt.Sprintf(proxyRefreshScriptTemplate, strings.Join(managerCmds, "\n"), mc.SSH.RemoteUsername)
+	logrus.Tracef("Final environment variable refresh script: %s", script)
+	return strings.NewReader(script)
+}
+
 func ApplyProxies(mc *vmconfigs.MachineConfig) error {
 	return machine.CommonSSHWithStdin("root", mc.SSH.IdentityPath, mc.Name, mc.SSH.Port, []string{"/usr/bin/bash"},
-		getProxyScript(mc.WSLHypervisor != nil))
+		getProxyScript(mc))
+}
+
+// RefreshProxies applies the proxy variables of the host to the running
+// machine, without restarting it
+func RefreshProxies(mc *vmconfigs.MachineConfig) error {
+	return machine.CommonSSHWithStdin("root", mc.SSH.IdentityPath, mc.Name, mc.SSH.Port, []string{"/usr/bin/bash"},
+		getProxyRefreshScript(mc))
 }
@@ -3,6 +3,7 @@
 package command
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -46,6 +47,18 @@ func (q *QemuCmd) SetIgnitionFile(file define.VMFile) {
 	*q = append(*q, "-fw_cfg", "name=opt/com.coreos/config,file="+file.GetPath())
 }
 
+// SetSystemdCredential passes a systemd credential to the guest in an SMBIOS
+// type 11 string.  The value is base64 encoded so that it may hold newlines and
+// commas.
+func (q *QemuCmd) SetSystemdCredential(name, value string) {
+	*q = append(*q, "-smbios", "type=11,value=io.systemd.credential.binary:"+name+"="+base64.StdEncoding.EncodeToString([]byte(value)))
+}
+
+// SetCloudInitSeed attaches the cloud-init seed disk of the machine, read-only
+func (q *QemuCmd) SetCloudInitSeed(file define.VMFile) {
+	*q = append(*q, "-drive", "if=virtio,format=raw,readonly=on,file="+file.GetPath())
+}
+
 // SetQmpMonitor specifies the machine's qmp socket
 func (q *QemuCmd) SetQmpMonitor(monitor Monitor) {
 	*q = append(*q, "-qmp", monitor.Network+":"+monitor.Address.GetPath()+",server=on,wait=off")
@@ -82,6 +95,53 @@ func (q *QemuCmd) SetUSBHostPassthrough(usbs []define.USBConfig) {
 	}
 }
 
+// SetGPUPassthrough passes the host GPUs at the given PCI addresses through
+// with VFIO
+func (q *QemuCmd) SetGPUPassthrough(addresses []string) {
+	for _, address := range addresses {
+		*q = append(*q, "-device", "vfio-pci,host="+address)
+	}
+}
+
+// Firmware is UEFI firmware given to the machine as flash drives: the code,
+// which is read-only and shared by the machines, and the variables of the
+// machine, a copy of the template shipped with the code
+type Firmware struct {
+	// Code is the path of the firmware code
+	Code string
+	// CodeFormat is the image format of the code, raw or qcow2
+	CodeFormat string
+	// Vars holds the UEFI variables of the machine
+	Vars define.VMFile
+	// VarsFormat is the image format of the variables, raw or qcow2
+	VarsFormat string
+	// SMM is set when the firmware protects its variables with the
+	// system management mode, as x86 secure boot firmware does
+	SMM bool
+}
+
+// SetFirmware boots the machine with the given UEFI firmware instead of the
+// default firmware
+func (q *QemuCmd) SetFirmware(fw Firmware) {
+	if fw.SMM {
+		*q = append(*q,
+			"-machine", "q35,smm=on",
+			"-global", "driver=cfi.pflash01,property=secure,value=on")
+	}
+	*q = append(*q,
+		"-drive", fmt.Sprintf("if=pflash,unit=0,format=%s,readonly=on,file=%s", fw.CodeFormat, fw.Code),
+		"-drive", fmt.Sprintf("if=pflash,unit=1,format=%s,file=%s", fw.VarsFormat, fw.Vars.GetPath()))
+}
+
+// SetTPM gives the machine the TPM emulated by the swtpm listening on
+// socket.  The device model of the TPM depends on the architecture.
+func (q *QemuCmd) SetTPM(socket define.VMFile, device string) {
+	*q = append(*q,
+		"-chardev", "socket,id=chrtpm,path="+socket.GetPath(),
+		"-tpmdev", "emulator,id=tpm0,chardev=chrtpm",
+		"-device", device+",tpmdev=tpm0")
+}
+
 // SetSerialPort adds a serial port to the machine for readiness
 func (q *QemuCmd) SetSerialPort(readySocket, vmPidFile define.VMFile, name string) {
 	*q = append(*q,
@@ -94,6 +154,14 @@ func (q *QemuCmd) SetSerialPort(readySocket, vmPidFile define.VMFile, name strin
 		"-pidfile", vmPidFile.GetPath())
 }
 
+// SetSerialConsole connects the first serial port of the machine, which the
+// guest uses as its console, to a socket
+func (q *QemuCmd) SetSerialConsole(consoleSocket define.VMFile, name string) {
+	*q = append(*q,
+		"-chardev", "socket,path="+consoleSocket.GetPath()+",server=on,wait=off,id=a"+name+"_console",
+		"-serial", "chardev:a"+name+"_console")
+}
+
 // SetVirtfsMount adds a virtfs mount to the machine
 func (q *QemuCmd) SetVirtfsMount(source, tag, securityModel string, readonly bool) {
 	virtfsOptions := fmt.Sprintf("local,path=%s,mount_tag=%s,security_model=%s", source, tag, securityModel)
@@ -103,9 +171,10 @@ func (q *QemuCmd) SetVirtfsMount(source, tag, securityModel string, readonly boo
 	*q = append(*q, "-virtfs", virtfsOptions)
 }
 
-// SetBootableImage specifies the image the machine will use to boot
+// SetBootableImage specifies the image the machine will use to boot.  The
+// blocks the guest discards, e.g. with fstrim, are freed in the image.
 func (q *QemuCmd) SetBootableImage(image string) {
-	*q = append(*q, "-drive", "if=virtio,file="+image)
+	*q = append(*q, "-drive", "if=virtio,discard=unmap,file="+image)
 }
 
 // SetDisplay specifies whether the machine will have a display
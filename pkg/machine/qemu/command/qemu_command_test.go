@@ -27,6 +27,9 @@ func TestQemuCmd(t *testing.T) {
 	vmPidFile, err := define.NewMachineFile(t.TempDir()+"vmpidfile.pid", nil)
 	assert.NoError(t, err)
 
+	consoleSocket, err := define.NewMachineFile(t.TempDir()+"consoleSocket.sock", nil)
+	assert.NoError(t, err)
+
 	monitor := Monitor{
 		Address: *machineAddrFile,
 		Network: "unix",
@@ -47,6 +50,7 @@ func TestQemuCmd(t *testing.T) {
 	err = cmd.SetNetwork(vlanSocket)
 	assert.NoError(t, err)
 	cmd.SetSerialPort(*readySocket, *vmPidFile, "test-machine")
+	cmd.SetSerialConsole(*consoleSocket, "test-machine")
 	cmd.SetVirtfsMount("/tmp/path", "vol10", "none", true)
 	cmd.SetBootableImage(bootableImagePath)
 	cmd.SetDisplay("none")
@@ -63,9 +67,79 @@ func TestQemuCmd(t *testing.T) {
 		"-chardev", fmt.Sprintf("socket,path=%s,server=on,wait=off,id=atest-machine_ready", readySocketPath),
 		"-device", "virtserialport,chardev=atest-machine_ready,name=org.fedoraproject.port.0",
 		"-pidfile", vmPidFilePath,
+		"-chardev", fmt.Sprintf("socket,path=%s,server=on,wait=off,id=atest-machine_console", consoleSocket.GetPath()),
+		"-serial", "chardev:atest-machine_console",
 		"-virtfs", "local,path=/tmp/path,mount_tag=vol10,security_model=none,readonly",
-		"-drive", fmt.Sprintf("if=virtio,file=%s", bootableImagePath),
+		"-drive", fmt.Sprintf("if=virtio,discard=unmap,file=%s", bootableImagePath),
 		"-display", "none"}
 
 	require.Equal(t, cmd.Build(), expected)
 }
+
+func TestQemuCmdCloudInitSeed(t *testing.T) {
+	seed, err := define.NewMachineFile(t.TempDir()+"test-machine-seed.iso", nil)
+	assert.NoError(t, err)
+
+	cmd := NewQemuBuilder("/usr/bin/qemu-system-x86_64", []string{})
+	cmd.SetBootableImage("/tmp/test-machine.qcow2")
+	cmd.SetCloudInitSeed(*seed)
+
+	expected := []string{
+		"/usr/bin/qemu-system-x86_64",
+		"-drive", "if=virtio,discard=unmap,file=/tmp/test-machine.qcow2",
+		"-drive", fmt.Sprintf("if=virtio,format=raw,readonly=on,file=%s", seed.GetPath()),
+	}
+	require.Equal(t, expected, cmd.Build())
+}
+
+func TestQemuCmdSystemdCredential(t *testing.T) {
+	cmd := NewQemuBuilder("/usr/bin/qemu-system-x86_64", []string{})
+	cmd.SetSystemdCredential("systemd.extra-unit.test.service", "[Service]\nExecStart=/bin/true a,b\n")
+
+	expected := []string{
+		"/usr/bin/qemu-system-x86_64",
+		"-smbios", "type=11,value=io.systemd.credential.binary:systemd.extra-unit.test.service=W1NlcnZpY2VdCkV4ZWNTdGFydD0vYmluL3RydWUgYSxiCg==",
+	}
+	require.Equal(t, expected, cmd.Build())
+}
+
+func TestQemuCmdGPUPassthrough(t *testing.T) {
+	cmd := NewQemuBuilder("/usr/bin/qemu-system-x86_64", []string{})
+	cmd.SetGPUPassthrough([]string{"0000:01:00.0", "0000:03:00.0"})
+
+	expected := []string{
+		"/usr/bin/qemu-system-x86_64",
+		"-device", "vfio-pci,host=0000:01:00.0",
+		"-device", "vfio-pci,host=0000:03:00.0",
+	}
+	require.Equal(t, expected, cmd.Build())
+}
+
+func TestQemuCmdFirmware(t *testing.T) {
+	vars, err := define.NewMachineFile("/var/lib/machine/test-efivars.fd", nil)
+	require.NoError(t, err)
+	socket, err := define.NewMachineFile("/run/machine/test-swtpm.sock", nil)
+	require.NoError(t, err)
+
+	cmd := NewQemuBuilder("/usr/bin/qemu-system-x86_64", []string{})
+	cmd.SetFirmware(Firmware{
+		Code:       "/usr/share/edk2/ovmf/OVMF_CODE.secboot.fd",
+		CodeFormat: "raw",
+		Vars:       *vars,
+		VarsFormat: "raw",
+		SMM:        true,
+	})
+	cmd.SetTPM(*socket, "tpm-tis")
+
+	expected := []string{
+		"/usr/bin/qemu-system-x86_64",
+		"-machine", "q35,smm=on",
+		"-global", "driver=cfi.pflash01,property=secure,value=on",
+		"-drive", "if=pflash,unit=0,format=raw,readonly=on,file=/usr/share/edk2/ovmf/OVMF_CODE.secboot.fd",
+		"-drive", "if=pflash,unit=1,format=raw,file=/var/lib/machine/test-efivars.fd",
+		"-chardev", "socket,id=chrtpm,path=/run/machine/test-swtpm.sock",
+		"-tpmdev", "emulator,id=tpm0,chardev=chrtpm",
+		"-device", "tpm-tis,tpmdev=tpm0",
+	}
+	require.Equal(t, expected, cmd.Build())
+}
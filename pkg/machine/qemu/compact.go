@@ -0,0 +1,54 @@
+//go:build !darwin
+
+package qemu
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/containers/common/pkg/config"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// CompactDisk rewrites the disk image with qemu-img convert, which leaves out
+// the clusters that the guest discarded or that hold zeros.  The internal
+// snapshots of a qcow2 image do not survive the conversion, so a machine
+// with snapshots is not compacted.
+func (q *QEMUStubber) CompactDisk(mc *vmconfigs.MachineConfig) error {
+	if len(mc.Snapshots) > 0 {
+		return fmt.Errorf("compacting the disk image would drop its %d snapshots", len(mc.Snapshots))
+	}
+	image := mc.ImagePath.GetPath()
+	format := define.Raw
+	if strings.HasSuffix(image, "."+define.Qcow.Kind()) {
+		format = define.Qcow
+	}
+
+	mc.Lock()
+	defer mc.Unlock()
+
+	cfg, err := config.Default()
+	if err != nil {
+		return err
+	}
+	qemuImgPath, err := cfg.FindHelperBinary("qemu-img", true)
+	if err != nil {
+		return err
+	}
+
+	compacted := image + ".compact"
+	defer os.Remove(compacted)
+	convert := exec.Command(qemuImgPath, "convert", "-O", format.Kind(), image, compacted)
+	if out, err := convert.CombinedOutput(); err != nil {
+		return fmt.Errorf("converting image: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	// compare exits non-zero if the images have different content
+	compare := exec.Command(qemuImgPath, "compare", image, compacted)
+	if out, err := compare.CombinedOutput(); err != nil {
+		return fmt.Errorf("verifying compacted image: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return os.Rename(compacted, image)
+}
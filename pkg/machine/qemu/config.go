@@ -10,7 +10,11 @@ import (
 // into setting up the qemu command line.  long term, this need
 // should be eliminated
 // TODO Podman5
-type setNewMachineCMDOpts struct{}
+type setNewMachineCMDOpts struct {
+	// flashFirmware is set when the firmware is given as flash drives,
+	// which replace the firmware the architecture boots by default
+	flashFirmware bool
+}
 
 // findQEMUBinary locates and returns the QEMU binary
 func findQEMUBinary() (string, error) {
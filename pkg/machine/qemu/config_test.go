@@ -46,6 +46,38 @@ func TestUSBParsing(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Good vendor and product as printed by lsusb",
+			args: []string{"1050:0407", "0x13D3:0x5406"},
+			result: []define.USBConfig{
+				{
+					Vendor:  4176,
+					Product: 1031,
+				},
+				{
+					Vendor:  5075,
+					Product: 21510,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "Bad vendor and product, out of range",
+			args:    []string{"10500:0407"},
+			result:  []define.USBConfig{},
+			wantErr: true,
+		},
+		{
+			name: "Bad vendor and product, given twice",
+			args: []string{"1050:0407", "vendor=1050,product=407"},
+			result: []define.USBConfig{
+				{
+					Vendor:  4176,
+					Product: 1031,
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name:    "Bad vendor and product, not hexa",
 			args:    []string{"vendor=13dk,product=5406"},
@@ -0,0 +1,25 @@
+//go:build !darwin
+
+package qemu
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// AttachConsole connects to the socket QEMU serves the serial console on.
+// The socket only accepts one client at a time.
+func (q *QEMUStubber) AttachConsole(mc *vmconfigs.MachineConfig) (io.ReadWriteCloser, error) {
+	consoleSocket, err := mc.ConsoleSocket()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial("unix", consoleSocket.GetPath())
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the serial console of machine %q, machines started by an older podman have none: %w", mc.Name, err)
+	}
+	return conn, nil
+}
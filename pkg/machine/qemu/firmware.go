@@ -0,0 +1,294 @@
+//go:build !darwin
+
+package qemu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/containers/common/pkg/config"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/qemu/command"
+	"github.com/containers/podman/v5/pkg/machine/sockets"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+)
+
+// swtpmCommand emulates the TPM of the machines
+const swtpmCommand = "swtpm"
+
+// firmwareDescriptorDirs are where the QEMU firmware descriptors are
+// installed, by increasing priority: a descriptor replaces the descriptors of
+// the same name in the directories before its own
+var firmwareDescriptorDirs = defaultFirmwareDescriptorDirs()
+
+func defaultFirmwareDescriptorDirs() []string {
+	dirs := []string{"/usr/share/qemu/firmware", "/etc/qemu/firmware"}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(configDir, "qemu", "firmware"))
+	}
+	return dirs
+}
+
+// firmwareDescriptor describes firmware shipped for QEMU, see
+// docs/interop/firmware.json in the QEMU sources
+type firmwareDescriptor struct {
+	InterfaceTypes []string `json:"interface-types"`
+	Mapping        struct {
+		Device        string       `json:"device"`
+		Mode          string       `json:"mode"`
+		Executable    firmwareFile `json:"executable"`
+		NVRAMTemplate firmwareFile `json:"nvram-template"`
+	} `json:"mapping"`
+	Targets []struct {
+		Architecture string `json:"architecture"`
+	} `json:"targets"`
+	Features []string `json:"features"`
+}
+
+type firmwareFile struct {
+	Filename string `json:"filename"`
+	Format   string `json:"format"`
+}
+
+// secureBoot reports whether the descriptor is UEFI firmware for arch that
+// enforces secure boot with the Microsoft keys enrolled, split in code and
+// variables so that each machine gets its own variables
+func (d *firmwareDescriptor) secureBoot(arch string) bool {
+	if !slices.Contains(d.InterfaceTypes, "uefi") || d.Mapping.Device != "flash" {
+		return false
+	}
+	if d.Mapping.Mode != "" && d.Mapping.Mode != "split" {
+		return false
+	}
+	if d.Mapping.Executable.Filename == "" || d.Mapping.NVRAMTemplate.Filename == "" {
+		return false
+	}
+	if !slices.Contains(d.Features, "secure-boot") || !slices.Contains(d.Features, "enrolled-keys") {
+		return false
+	}
+	for _, target := range d.Targets {
+		if target.Architecture == arch {
+			return true
+		}
+	}
+	return false
+}
+
+// findSecureBootFirmware returns the first secure boot firmware for arch in
+// the order QEMU gives its descriptors: sorted by name
+func findSecureBootFirmware(arch string) (*firmwareDescriptor, error) {
+	paths := make(map[string]string)
+	for _, dir := range firmwareDescriptorDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				logrus.Debugf("reading firmware descriptors in %s: %v", dir, err)
+			}
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+				paths[entry.Name()] = filepath.Join(dir, entry.Name())
+			}
+		}
+	}
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		content, err := os.ReadFile(paths[name])
+		if err != nil {
+			return nil, err
+		}
+		var desc firmwareDescriptor
+		if err := json.Unmarshal(content, &desc); err != nil {
+			logrus.Debugf("skipping firmware descriptor %s: %v", paths[name], err)
+			continue
+		}
+		if desc.secureBoot(arch) {
+			logrus.Debugf("using the secure boot firmware of %s", paths[name])
+			return &desc, nil
+		}
+	}
+	return nil, fmt.Errorf("no UEFI firmware with secure boot for %s is described in %s, install the edk2 (OVMF) firmware for QEMU", arch, strings.Join(firmwareDescriptorDirs, ", "))
+}
+
+// qemuArch is the name QEMU gives the architecture of the host
+func qemuArch() string {
+	if runtime.GOARCH == "arm64" {
+		return "aarch64"
+	}
+	return "x86_64"
+}
+
+// tpmDevice is the TPM device model of the machines of the host
+func tpmDevice() string {
+	if runtime.GOARCH == "arm64" {
+		return "tpm-tis-device"
+	}
+	return "tpm-tis"
+}
+
+func findSwtpm() (string, error) {
+	cfg, err := config.Default()
+	if err != nil {
+		return "", err
+	}
+	path, err := cfg.FindHelperBinary(swtpmCommand, true)
+	if err != nil {
+		return "", fmt.Errorf("a TPM requires %s, which could not be found: %w", swtpmCommand, err)
+	}
+	return path, nil
+}
+
+// setupFirmware copies the secure boot firmware variables and creates the
+// TPM state of a new machine, as its firmware configuration asks
+func setupFirmware(mc *vmconfigs.MachineConfig, dataDir *define.VMFile) error {
+	fw := mc.Resources.Firmware
+	if fw == nil {
+		return nil
+	}
+	if fw.SecureBoot {
+		desc, err := findSecureBootFirmware(qemuArch())
+		if err != nil {
+			return err
+		}
+		template := desc.Mapping.NVRAMTemplate
+		vars, err := dataDir.AppendToNewVMFile(mc.Name+"-efivars"+filepath.Ext(template.Filename), nil)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(template.Filename, vars.GetPath()); err != nil {
+			return fmt.Errorf("copying the UEFI variables template: %w", err)
+		}
+		mc.QEMUHypervisor.Firmware = &command.Firmware{
+			Code:       desc.Mapping.Executable.Filename,
+			CodeFormat: firmwareFormat(desc.Mapping.Executable),
+			Vars:       *vars,
+			VarsFormat: firmwareFormat(template),
+			SMM:        slices.Contains(desc.Features, "requires-smm"),
+		}
+	}
+	if fw.TPM {
+		if _, err := findSwtpm(); err != nil {
+			return err
+		}
+		state, err := dataDir.AppendToNewVMFile(mc.Name+"-tpm", nil)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(state.GetPath(), 0o700); err != nil {
+			return err
+		}
+		mc.QEMUHypervisor.TPMState = state
+	}
+	return nil
+}
+
+// firmwareFiles are the firmware variables and the TPM state of the machine
+func firmwareFiles(mc *vmconfigs.MachineConfig) []string {
+	var files []string
+	if fw := mc.QEMUHypervisor.Firmware; fw != nil {
+		files = append(files, fw.Vars.GetPath())
+	}
+	if state := mc.QEMUHypervisor.TPMState; state != nil {
+		files = append(files, state.GetPath())
+	}
+	return files
+}
+
+// removeFirmware removes the firmware variables and the TPM state of the
+// machine
+func removeFirmware(mc *vmconfigs.MachineConfig) []error {
+	var errs []error
+	for _, path := range firmwareFiles(mc) {
+		if err := os.RemoveAll(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func firmwareFormat(file firmwareFile) string {
+	if file.Format == "" {
+		return "raw"
+	}
+	return file.Format
+}
+
+func tpmSocket(mc *vmconfigs.MachineConfig) (*define.VMFile, error) {
+	rtDir, err := mc.RuntimeDir()
+	if err != nil {
+		return nil, err
+	}
+	return rtDir.AppendToNewVMFile(mc.Name+"-swtpm.sock", nil)
+}
+
+// swtpmArgs are the arguments of swtpm emulating the TPM whose state is in
+// state for the QEMU connecting to socket.  swtpm exits when QEMU does.
+func swtpmArgs(state, socket, logFile string) []string {
+	return []string{
+		"socket", "--tpm2",
+		"--tpmstate", "dir=" + state,
+		"--ctrl", "type=unixio,path=" + socket,
+		"--log", "file=" + logFile,
+		"--terminate", "--daemon",
+	}
+}
+
+// startSwtpm starts the emulator of the TPM of the machine, if it has one
+func startSwtpm(mc *vmconfigs.MachineConfig) error {
+	state := mc.QEMUHypervisor.TPMState
+	if state == nil {
+		return nil
+	}
+	swtpm, err := findSwtpm()
+	if err != nil {
+		return err
+	}
+	socket, err := tpmSocket(mc)
+	if err != nil {
+		return err
+	}
+	if err := socket.Delete(); err != nil {
+		return err
+	}
+	logFile := strings.TrimSuffix(socket.GetPath(), ".sock") + ".log"
+
+	cmd := exec.Command(swtpm, swtpmArgs(state.GetPath(), socket.GetPath(), logFile)...)
+	logrus.Debugf("swtpm command-line: %v", cmd.Args)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("starting swtpm: %w, see %s", err, logFile)
+	}
+	return sockets.WaitForSocketWithBackoffs(gvProxyMaxBackoffAttempts, gvProxyWaitBackoff, socket.GetPath(), swtpmCommand)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,78 @@
+//go:build !darwin
+
+package qemu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeDescriptor(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
+
+const secureBootDescriptor = `{
+  "interface-types": ["uefi"],
+  "mapping": {
+    "device": "flash",
+    "mode": "split",
+    "executable": {"filename": "%s/OVMF_CODE.secboot.fd", "format": "raw"},
+    "nvram-template": {"filename": "%s/OVMF_VARS.secboot.fd", "format": "raw"}
+  },
+  "targets": [{"architecture": "x86_64", "machines": ["pc-q35-*"]}],
+  "features": ["acpi-s3", "enrolled-keys", "requires-smm", "secure-boot"]
+}`
+
+func TestFindSecureBootFirmware(t *testing.T) {
+	origDirs := firmwareDescriptorDirs
+	defer func() {
+		firmwareDescriptorDirs = origDirs
+	}()
+	system := filepath.Join(t.TempDir(), "usr")
+	user := filepath.Join(t.TempDir(), "home")
+	firmwareDescriptorDirs = []string{system, user}
+
+	_, err := findSecureBootFirmware("x86_64")
+	assert.ErrorContains(t, err, "no UEFI firmware with secure boot")
+
+	// firmware without secure boot, or without the keys to enforce it, is skipped
+	writeDescriptor(t, system, "10-ovmf.json", `{"interface-types": ["uefi"], "mapping": {"device": "flash", "executable": {"filename": "/OVMF_CODE.fd"}, "nvram-template": {"filename": "/OVMF_VARS.fd"}}, "targets": [{"architecture": "x86_64"}], "features": []}`)
+	writeDescriptor(t, system, "20-ovmf-sb.json", `{"interface-types": ["uefi"], "mapping": {"device": "flash", "executable": {"filename": "/sb"}, "nvram-template": {"filename": "/sb-vars"}}, "targets": [{"architecture": "x86_64"}], "features": ["secure-boot"]}`)
+	writeDescriptor(t, system, "30-ovmf-sb-keys.json", replaceDir(secureBootDescriptor, "/system"))
+	writeDescriptor(t, system, "40-broken.json", `{`)
+
+	desc, err := findSecureBootFirmware("x86_64")
+	require.NoError(t, err)
+	assert.Equal(t, "/system/OVMF_CODE.secboot.fd", desc.Mapping.Executable.Filename)
+	assert.Equal(t, "/system/OVMF_VARS.secboot.fd", desc.Mapping.NVRAMTemplate.Filename)
+
+	_, err = findSecureBootFirmware("aarch64")
+	assert.Error(t, err)
+
+	// a descriptor of the user replaces the one of the same name
+	writeDescriptor(t, user, "30-ovmf-sb-keys.json", replaceDir(secureBootDescriptor, "/user"))
+	desc, err = findSecureBootFirmware("x86_64")
+	require.NoError(t, err)
+	assert.Equal(t, "/user/OVMF_CODE.secboot.fd", desc.Mapping.Executable.Filename)
+}
+
+func replaceDir(descriptor, dir string) string {
+	return fmt.Sprintf(descriptor, dir, dir)
+}
+
+func TestSwtpmArgs(t *testing.T) {
+	assert.Equal(t, []string{
+		"socket", "--tpm2",
+		"--tpmstate", "dir=/data/machine-tpm",
+		"--ctrl", "type=unixio,path=/run/machine-swtpm.sock",
+		"--log", "file=/run/machine-swtpm.log",
+		"--terminate", "--daemon",
+	}, swtpmArgs("/data/machine-tpm", "/run/machine-swtpm.sock", "/run/machine-swtpm.log"))
+}
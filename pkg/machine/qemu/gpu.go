@@ -0,0 +1,83 @@
+//go:build !darwin
+
+package qemu
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+)
+
+const (
+	// vfioDriver is the driver GPUs must be bound to on the host to be
+	// passed through
+	vfioDriver = "vfio-pci"
+	// displayClassPrefix starts the PCI class of display controllers
+	displayClassPrefix = "0x03"
+)
+
+// pciDevicesDir lists the PCI devices of the host, a variable so that tests
+// can fake it
+var pciDevicesDir = "/sys/bus/pci/devices"
+
+// vfioGPUs returns the PCI addresses of the GPUs to pass through: the given
+// ones, which must be bound to vfio-pci, or all GPUs bound to it
+func vfioGPUs(gpu *define.GPUConfig) ([]string, error) {
+	if !gpu.All {
+		for _, address := range gpu.Devices {
+			driver, err := pciDriver(address)
+			if err != nil {
+				return nil, fmt.Errorf("GPU %s: %w", address, err)
+			}
+			if driver != vfioDriver {
+				return nil, fmt.Errorf("GPU %s must be bound to the %s driver to be passed through, it is bound to %q", address, vfioDriver, driver)
+			}
+		}
+		return gpu.Devices, nil
+	}
+
+	entries, err := os.ReadDir(pciDevicesDir)
+	if err != nil {
+		return nil, fmt.Errorf("listing the GPUs of the host: %w", err)
+	}
+	var gpus []string
+	for _, entry := range entries {
+		class, err := os.ReadFile(filepath.Join(pciDevicesDir, entry.Name(), "class"))
+		if err != nil || !strings.HasPrefix(strings.TrimSpace(string(class)), displayClassPrefix) {
+			continue
+		}
+		if driver, _ := pciDriver(entry.Name()); driver == vfioDriver {
+			gpus = append(gpus, entry.Name())
+		}
+	}
+	if len(gpus) == 0 {
+		return nil, fmt.Errorf("no GPU of the host is bound to the %s driver", vfioDriver)
+	}
+	sort.Strings(gpus)
+	return gpus, nil
+}
+
+// pciDriver returns the driver the PCI device at address is bound to, empty
+// when it is not bound
+func pciDriver(address string) (string, error) {
+	dev := filepath.Join(pciDevicesDir, address)
+	if _, err := os.Stat(dev); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", errors.New("no such PCI device")
+		}
+		return "", err
+	}
+	driver, err := os.Readlink(filepath.Join(dev, "driver"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return filepath.Base(driver), nil
+}
@@ -0,0 +1,56 @@
+//go:build !darwin
+
+package qemu
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePCIDevice adds a device of class to the fake sysfs, bound to driver
+// unless it is empty
+func fakePCIDevice(t *testing.T, address, class, driver string) {
+	t.Helper()
+	dev := filepath.Join(pciDevicesDir, address)
+	require.NoError(t, os.MkdirAll(dev, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dev, "class"), []byte(class+"\n"), 0o644))
+	if driver != "" {
+		require.NoError(t, os.Symlink(filepath.Join("..", "..", "drivers", driver), filepath.Join(dev, "driver")))
+	}
+}
+
+func TestVFIOGPUs(t *testing.T) {
+	orig := pciDevicesDir
+	pciDevicesDir = t.TempDir()
+	t.Cleanup(func() { pciDevicesDir = orig })
+
+	fakePCIDevice(t, "0000:00:02.0", "0x030000", "i915")
+	fakePCIDevice(t, "0000:03:00.0", "0x030200", "vfio-pci")
+	fakePCIDevice(t, "0000:01:00.0", "0x030000", "vfio-pci")
+	fakePCIDevice(t, "0000:01:00.1", "0x040300", "vfio-pci")
+	fakePCIDevice(t, "0000:02:00.0", "0x030000", "")
+
+	gpus, err := vfioGPUs(&define.GPUConfig{All: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0000:01:00.0", "0000:03:00.0"}, gpus)
+
+	gpus, err = vfioGPUs(&define.GPUConfig{Devices: []string{"0000:03:00.0"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0000:03:00.0"}, gpus)
+
+	_, err = vfioGPUs(&define.GPUConfig{Devices: []string{"0000:00:02.0"}})
+	assert.ErrorContains(t, err, `it is bound to "i915"`)
+	_, err = vfioGPUs(&define.GPUConfig{Devices: []string{"0000:02:00.0"}})
+	assert.ErrorContains(t, err, `it is bound to ""`)
+	_, err = vfioGPUs(&define.GPUConfig{Devices: []string{"0000:09:00.0"}})
+	assert.ErrorContains(t, err, "no such PCI device")
+
+	pciDevicesDir = t.TempDir()
+	_, err = vfioGPUs(&define.GPUConfig{All: true})
+	assert.ErrorContains(t, err, "no GPU of the host is bound to the vfio-pci driver")
+}
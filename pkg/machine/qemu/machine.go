@@ -17,6 +17,7 @@ import (
 
 	"github.com/containers/common/pkg/config"
 	"github.com/containers/podman/v5/pkg/errorhandling"
+	"github.com/containers/podman/v5/pkg/machine"
 	"github.com/containers/podman/v5/pkg/machine/define"
 	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
 	"github.com/digitalocean/go-qemu/qmp"
@@ -147,6 +148,21 @@ func (q *QEMUStubber) StopVM(mc *vmconfigs.MachineConfig, _ bool) error {
 	return stopErr
 }
 
+// KillVM kills the QEMU process and removes its monitor socket, the
+// graceful stop holding the lock returns once the process is gone
+func (q *QEMUStubber) KillVM(mc *vmconfigs.MachineConfig) error {
+	qemuPid, err := qemuPid(mc.QEMUHypervisor.QEMUPidPath)
+	if err != nil {
+		return err
+	}
+	if qemuPid != -1 {
+		if err := sigKill(qemuPid); err != nil {
+			return err
+		}
+	}
+	return mc.QEMUHypervisor.QMPMonitor.Address.Delete()
+}
+
 // stopLocked stops the machine and expects the caller to hold the machine's lock.
 func (q *QEMUStubber) stopLocked(mc *vmconfigs.MachineConfig) error {
 	// check if the qmp socket is there. if not, qemu instance is gone
@@ -232,13 +248,23 @@ func (q *QEMUStubber) Remove(mc *vmconfigs.MachineConfig) ([]string, func() erro
 	mc.Lock()
 	defer mc.Unlock()
 
+	consoleSocket, err := mc.ConsoleSocket()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	qemuRmFiles := []string{
 		mc.QEMUHypervisor.QEMUPidPath.GetPath(),
 		mc.QEMUHypervisor.QMPMonitor.Address.GetPath(),
+		consoleSocket.GetPath(),
 	}
+	qemuRmFiles = append(qemuRmFiles, firmwareFiles(mc)...)
 
 	return qemuRmFiles, func() error {
 		var errs []error
+		if err := consoleSocket.Delete(); err != nil {
+			errs = append(errs, err)
+		}
 		if err := mc.QEMUHypervisor.QEMUPidPath.Delete(); err != nil {
 			errs = append(errs, err)
 		}
@@ -246,6 +272,12 @@ func (q *QEMUStubber) Remove(mc *vmconfigs.MachineConfig) ([]string, func() erro
 		if err := mc.QEMUHypervisor.QMPMonitor.Address.Delete(); err != nil {
 			errs = append(errs, err)
 		}
+
+		errs = append(errs, removeFirmware(mc)...)
+
+		if err := machine.ReleaseMachinePort(mc.SSH.Port); err != nil {
+			errs = append(errs, err)
+		}
 		return errorhandling.JoinErrors(errs)
 	}, nil
 }
@@ -342,3 +374,79 @@ func getDiskSize(path string) (uint64, error) { //nolint:unused
 	}
 	return tmpInfo.VirtualSize, nil
 }
+
+// runQMP runs command on the monitor and decodes its response into response
+func runQMP(monitor *qmp.SocketMonitor, command string, response interface{}) error {
+	input, err := json.Marshal(struct {
+		Execute string `json:"execute"`
+	}{
+		Execute: command,
+	})
+	if err != nil {
+		return err
+	}
+	b, err := monitor.Run(input)
+	if err != nil {
+		return fmt.Errorf("running QMP command %q: %w", command, err)
+	}
+	return json.Unmarshal(b, response)
+}
+
+// RunningConfig queries the QMP monitor of the running machine for its CPUs,
+// memory and disk size
+func (q *QEMUStubber) RunningConfig(mc *vmconfigs.MachineConfig) (*vmconfigs.RunningConfig, error) {
+	monitor, err := qmp.NewSocketMonitor(mc.QEMUHypervisor.QMPMonitor.Network, mc.QEMUHypervisor.QMPMonitor.Address.GetPath(), mc.QEMUHypervisor.QMPMonitor.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := monitor.Connect(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := monitor.Disconnect(); err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	// {"return": [{"cpu-index": 0, ...}, ...]}
+	var cpus struct {
+		Return []json.RawMessage `json:"return"`
+	}
+	if err := runQMP(monitor, "query-cpus-fast", &cpus); err != nil {
+		return nil, err
+	}
+	// {"return": {"base-memory": 2147483648}}
+	var memory struct {
+		Return struct {
+			BaseMemory uint64 `json:"base-memory"`
+		} `json:"return"`
+	}
+	if err := runQMP(monitor, "query-memory-size-summary", &memory); err != nil {
+		return nil, err
+	}
+	// {"return": [{"inserted": {"file": "...", "image": {"virtual-size": 107374182400}}}, ...]}
+	var blocks struct {
+		Return []struct {
+			Inserted *struct {
+				File  string `json:"file"`
+				Image struct {
+					VirtualSize uint64 `json:"virtual-size"`
+				} `json:"image"`
+			} `json:"inserted"`
+		} `json:"return"`
+	}
+	if err := runQMP(monitor, "query-block", &blocks); err != nil {
+		return nil, err
+	}
+
+	running := &vmconfigs.RunningConfig{
+		CPUs:   uint64(len(cpus.Return)),
+		Memory: memory.Return.BaseMemory / (1024 * 1024),
+	}
+	for _, block := range blocks.Return {
+		if block.Inserted != nil && block.Inserted.File == mc.ImagePath.GetPath() {
+			running.DiskSize = block.Inserted.Image.VirtualSize / (1024 * 1024 * 1024)
+		}
+	}
+	return running, nil
+}
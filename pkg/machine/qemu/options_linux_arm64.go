@@ -11,12 +11,14 @@ var (
 	QemuCommand = "qemu-system-aarch64"
 )
 
-func (q *QEMUStubber) addArchOptions(_ *setNewMachineCMDOpts) []string {
+func (q *QEMUStubber) addArchOptions(cmdOpts *setNewMachineCMDOpts) []string {
 	opts := []string{
 		"-accel", "kvm",
 		"-cpu", "host",
 		"-M", "virt,gic-version=max",
-		"-bios", getQemuUefiFile("QEMU_EFI.fd"),
+	}
+	if cmdOpts == nil || !cmdOpts.flashFirmware {
+		opts = append(opts, "-bios", getQemuUefiFile("QEMU_EFI.fd"))
 	}
 	return opts
 }
@@ -0,0 +1,51 @@
+//go:build !darwin
+
+package qemu
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/containers/common/pkg/config"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// qemuImgSnapshot runs "qemu-img snapshot" with the given action flag on the
+// disk image of the machine.  Snapshots are stored inside qcow2 images, raw
+// images cannot hold them.
+func qemuImgSnapshot(mc *vmconfigs.MachineConfig, action, name string) error {
+	if !strings.HasSuffix(mc.ImagePath.GetPath(), "."+define.Qcow.Kind()) {
+		return fmt.Errorf("snapshots require a %s disk image: %w", define.Qcow.Kind(), define.ErrNotImplemented)
+	}
+	cfg, err := config.Default()
+	if err != nil {
+		return err
+	}
+	qemuImgPath, err := cfg.FindHelperBinary("qemu-img", true)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(qemuImgPath, "snapshot", action, name, mc.ImagePath.GetPath())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Snapshot creates an internal snapshot of the qcow2 disk image
+func (q *QEMUStubber) Snapshot(mc *vmconfigs.MachineConfig, name string) (*define.VMFile, error) {
+	mc.Lock()
+	defer mc.Unlock()
+
+	return nil, qemuImgSnapshot(mc, "-c", name)
+}
+
+// RevertSnapshot applies the internal snapshot to the qcow2 disk image
+func (q *QEMUStubber) RevertSnapshot(mc *vmconfigs.MachineConfig, snapshot *vmconfigs.Snapshot) error {
+	mc.Lock()
+	defer mc.Unlock()
+
+	return qemuImgSnapshot(mc, "-a", snapshot.Name)
+}
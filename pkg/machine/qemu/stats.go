@@ -0,0 +1,31 @@
+//go:build !darwin
+
+package qemu
+
+import (
+	"fmt"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// Stats measures the QEMU process of the running machine and its disk image
+func (q *QEMUStubber) Stats(mc *vmconfigs.MachineConfig) (*vmconfigs.Stats, error) {
+	pid, err := qemuPid(mc.QEMUHypervisor.QEMUPidPath)
+	if err != nil {
+		return nil, err
+	}
+	if pid == -1 {
+		return nil, fmt.Errorf("machine %q has no QEMU process: %w", mc.Name, define.ErrWrongState)
+	}
+	stats, err := machine.ProcessStats(pid)
+	if err != nil {
+		return nil, err
+	}
+	stats.DiskUsed, err = machine.DiskUsage(mc.ImagePath.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -3,7 +3,6 @@
 package qemu
 
 import (
-	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
@@ -38,6 +37,9 @@ var (
 	gvProxyMaxBackoffAttempts = 6
 )
 
+// defaultNinePMSize is the msize of volumes that do not set one
+const defaultNinePMSize = 131072
+
 func (q QEMUStubber) UserModeNetworkEnabled(*vmconfigs.MachineConfig) bool {
 	return true
 }
@@ -47,16 +49,30 @@ func (q QEMUStubber) UseProviderNetworkSetup() bool {
 }
 
 func (q QEMUStubber) RequireExclusiveActive() bool {
-	return true
+	return false
 }
 
-func (q *QEMUStubber) setQEMUCommandLine(mc *vmconfigs.MachineConfig) error {
-	qemuBinary, err := findQEMUBinary()
-	if err != nil {
-		return err
+// Capabilities reports the features of QEMU.  Volumes are 9p file systems,
+// which are only added on the next start, and snapshots need a qcow2 disk.
+func (q QEMUStubber) Capabilities() define.Capabilities {
+	return define.Capabilities{
+		CompactDisk:     true,
+		Console:         true,
+		ConvertDisk:     true,
+		Export:          true,
+		GPU:             true,
+		MultipleRunning: !q.RequireExclusiveActive(),
+		Recovery:        true,
+		SecureBoot:      true,
+		Snapshots:       true,
+		Stats:           true,
+		TPM:             true,
+		USB:             true,
 	}
+}
 
-	ignitionFile, err := mc.IgnitionFile()
+func (q *QEMUStubber) setQEMUCommandLine(mc *vmconfigs.MachineConfig) error {
+	qemuBinary, err := findQEMUBinary()
 	if err != nil {
 		return err
 	}
@@ -68,11 +84,34 @@ func (q *QEMUStubber) setQEMUCommandLine(mc *vmconfigs.MachineConfig) error {
 
 	q.QEMUPidPath = mc.QEMUHypervisor.QEMUPidPath
 
-	q.Command = command.NewQemuBuilder(qemuBinary, q.addArchOptions(nil))
+	fw := mc.QEMUHypervisor.Firmware
+	q.Command = command.NewQemuBuilder(qemuBinary, q.addArchOptions(&setNewMachineCMDOpts{flashFirmware: fw != nil}))
+	if fw != nil {
+		q.Command.SetFirmware(*fw)
+	}
+	if mc.QEMUHypervisor.TPMState != nil {
+		tpmSock, err := tpmSocket(mc)
+		if err != nil {
+			return err
+		}
+		q.Command.SetTPM(*tpmSock, tpmDevice())
+	}
 	q.Command.SetBootableImage(mc.ImagePath.GetPath())
 	q.Command.SetMemory(mc.Resources.Memory)
 	q.Command.SetCPUs(mc.Resources.CPUs)
-	q.Command.SetIgnitionFile(*ignitionFile)
+	if mc.Provisioner == define.CloudInitProvisioner {
+		seed, err := mc.CloudInitSeed()
+		if err != nil {
+			return err
+		}
+		q.Command.SetCloudInitSeed(*seed)
+	} else {
+		ignitionFile, err := mc.IgnitionFile()
+		if err != nil {
+			return err
+		}
+		q.Command.SetIgnitionFile(*ignitionFile)
+	}
 	q.Command.SetQmpMonitor(mc.QEMUHypervisor.QMPMonitor)
 	gvProxySock, err := mc.GVProxySocket()
 	if err != nil {
@@ -82,6 +121,11 @@ func (q *QEMUStubber) setQEMUCommandLine(mc *vmconfigs.MachineConfig) error {
 		return err
 	}
 	q.Command.SetSerialPort(*readySocket, *mc.QEMUHypervisor.QEMUPidPath, mc.Name)
+	consoleSocket, err := mc.ConsoleSocket()
+	if err != nil {
+		return err
+	}
+	q.Command.SetSerialConsole(*consoleSocket, mc.Name)
 
 	// Add volumes to qemu command line
 	for _, mount := range mc.Mounts {
@@ -92,6 +136,14 @@ func (q *QEMUStubber) setQEMUCommandLine(mc *vmconfigs.MachineConfig) error {
 
 	q.Command.SetUSBHostPassthrough(mc.Resources.USBs)
 
+	if mc.Resources.GPU != nil {
+		gpus, err := vfioGPUs(mc.Resources.GPU)
+		if err != nil {
+			return err
+		}
+		q.Command.SetGPUPassthrough(gpus)
+	}
+
 	return nil
 }
 
@@ -116,6 +168,9 @@ func (q *QEMUStubber) CreateVM(opts define.CreateVMOpts, mc *vmconfigs.MachineCo
 
 	mc.QEMUHypervisor = &qemuConfig
 	mc.QEMUHypervisor.QEMUPidPath = qemuPidPath
+	if err := setupFirmware(mc, opts.Dirs.DataDir); err != nil {
+		return err
+	}
 	return q.resizeDisk(strongunits.GiB(mc.Resources.DiskSize), mc.ImagePath)
 }
 
@@ -142,6 +197,11 @@ func runStartVMCommand(cmd *exec.Cmd) error {
 }
 
 func (q *QEMUStubber) StartVM(mc *vmconfigs.MachineConfig) (func() error, func() error, error) {
+	return q.startVM(mc, nil)
+}
+
+// startVM runs qemu with the systemd credentials given to the guest
+func (q *QEMUStubber) startVM(mc *vmconfigs.MachineConfig, credentials []ignition.SystemdCredential) (func() error, func() error, error) {
 	if err := q.setQEMUCommandLine(mc); err != nil {
 		return nil, nil, fmt.Errorf("unable to generate qemu command line: %q", err)
 	}
@@ -161,6 +221,11 @@ func (q *QEMUStubber) StartVM(mc *vmconfigs.MachineConfig) (func() error, func()
 		return nil, nil, err
 	}
 
+	// QEMU connects to the TPM emulator as it starts
+	if err := startSwtpm(mc); err != nil {
+		return nil, nil, err
+	}
+
 	dnr, dnw, err := machine.GetDevNullFiles()
 	if err != nil {
 		return nil, nil, err
@@ -168,7 +233,10 @@ func (q *QEMUStubber) StartVM(mc *vmconfigs.MachineConfig) (func() error, func()
 	defer dnr.Close()
 	defer dnw.Close()
 
-	cmdLine := q.Command
+	cmdLine := append(command.QemuCmd(nil), q.Command...)
+	for _, credential := range credentials {
+		cmdLine.SetSystemdCredential(credential.Name, credential.Value)
+	}
 
 	// Disable graphic window when not in debug mode
 	// Done in start, so we're not suck with the debug level we used on init
@@ -195,14 +263,14 @@ func (q *QEMUStubber) StartVM(mc *vmconfigs.MachineConfig) (func() error, func()
 	logrus.Debugf("Started qemu pid %d", cmd.Process.Pid)
 
 	readyFunc := func() error {
-		return waitForReady(readySocket, cmd.Process.Pid, stderrBuf)
+		return waitForReady(mc, readySocket, cmd.Process.Pid, stderrBuf)
 	}
 
 	// if this is not the last line in the func, make it a defer
 	return cmd.Process.Release, readyFunc, nil
 }
 
-func waitForReady(readySocket *define.VMFile, pid int, stdErrBuffer *bytes.Buffer) error {
+func waitForReady(mc *vmconfigs.MachineConfig, readySocket *define.VMFile, pid int, stdErrBuffer *bytes.Buffer) error {
 	defaultBackoff := 500 * time.Millisecond
 	maxBackoffs := 6
 	conn, err := sockets.DialSocketWithBackoffsAndProcCheck(maxBackoffs, defaultBackoff, readySocket.GetPath(), checkProcessStatus, "qemu", pid, stdErrBuffer)
@@ -211,7 +279,10 @@ func waitForReady(readySocket *define.VMFile, pid int, stdErrBuffer *bytes.Buffe
 	}
 	defer conn.Close()
 
-	_, err = bufio.NewReader(conn).ReadString('\n')
+	status, err := sockets.ReadReady(conn)
+	if status != nil {
+		mc.ReadyStatus = *status
+	}
 	return err
 }
 
@@ -251,6 +322,51 @@ func (q *QEMUStubber) resizeDisk(newSize strongunits.GiB, diskPath *define.VMFil
 	return nil
 }
 
+// StartRecovery starts qemu with systemd credentials that switch the guest to
+// the recovery target.  The bootloader of the disk image is left alone, the
+// credentials are read by systemd from the SMBIOS tables.
+func (q *QEMUStubber) StartRecovery(mc *vmconfigs.MachineConfig, override define.RecoveryOverride) error {
+	credentials, err := ignition.RecoveryCredentials(override)
+	if err != nil {
+		return err
+	}
+	releaseCmd, _, err := q.startVM(mc, credentials)
+	if err != nil {
+		return err
+	}
+	return releaseCmd()
+}
+
+// ConvertDisk converts the disk image with qemu-img and compares the result
+// with the original image
+func (q *QEMUStubber) ConvertDisk(mc *vmconfigs.MachineConfig, src, dst *define.VMFile, format define.ImageFormat) error {
+	if format != define.Qcow && format != define.Raw {
+		return fmt.Errorf("qemu does not support %s disk images: %w", format.Kind(), define.ErrNotImplemented)
+	}
+
+	mc.Lock()
+	defer mc.Unlock()
+
+	cfg, err := config.Default()
+	if err != nil {
+		return err
+	}
+	qemuImgPath, err := cfg.FindHelperBinary("qemu-img", true)
+	if err != nil {
+		return err
+	}
+	convert := exec.Command(qemuImgPath, "convert", "-O", format.Kind(), src.GetPath(), dst.GetPath())
+	if out, err := convert.CombinedOutput(); err != nil {
+		return fmt.Errorf("converting image: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	// compare exits non-zero if the images have different content
+	compare := exec.Command(qemuImgPath, "compare", src.GetPath(), dst.GetPath())
+	if out, err := compare.CombinedOutput(); err != nil {
+		return fmt.Errorf("verifying converted image: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 func (q *QEMUStubber) SetProviderAttrs(mc *vmconfigs.MachineConfig, opts define.SetOptions) error {
 	mc.Lock()
 	defer mc.Unlock()
@@ -263,12 +379,6 @@ func (q *QEMUStubber) SetProviderAttrs(mc *vmconfigs.MachineConfig, opts define.
 		return errors.New("unable to change settings unless vm is stopped")
 	}
 
-	if opts.DiskSize != nil {
-		if err := q.resizeDisk(*opts.DiskSize, mc.ImagePath); err != nil {
-			return err
-		}
-	}
-
 	if opts.Rootful != nil && mc.HostUser.Rootful != *opts.Rootful {
 		if err := mc.SetRootful(*opts.Rootful); err != nil {
 			return err
@@ -283,7 +393,20 @@ func (q *QEMUStubber) SetProviderAttrs(mc *vmconfigs.MachineConfig, opts define.
 		mc.Resources.USBs = usbs
 	}
 
-	// Because QEMU does nothing with these hardware attributes, we can simply return
+	return nil
+}
+
+func (q *QEMUStubber) UpdateResources(mc *vmconfigs.MachineConfig, opts define.SetOptions) error {
+	mc.Lock()
+	defer mc.Unlock()
+
+	if opts.DiskSize != nil {
+		if err := q.resizeDisk(*opts.DiskSize, mc.ImagePath); err != nil {
+			return err
+		}
+	}
+
+	// The CPUs and memory are passed to QEMU when the machine starts
 	return nil
 }
 
@@ -332,7 +455,8 @@ func (q *QEMUStubber) MountVolumesToVM(mc *vmconfigs.MachineConfig, quiet bool)
 		case MountType9p:
 			mountOptions := []string{"-t", "9p"}
 			mountOptions = append(mountOptions, []string{"-o", "trans=virtio", mount.Tag, mount.Target}...)
-			mountOptions = append(mountOptions, []string{"-o", "version=9p2000.L,msize=131072,cache=mmap"}...)
+			ninePOptions := append([]string{"version=9p2000.L"}, machine.NinePMountOptions(mount, vmconfigs.CacheAuto, defaultNinePMSize)...)
+			mountOptions = append(mountOptions, []string{"-o", strings.Join(ninePOptions, ",")}...)
 			if mount.ReadOnly {
 				mountOptions = append(mountOptions, []string{"-o", "ro"}...)
 			}
@@ -347,6 +471,13 @@ func (q *QEMUStubber) MountVolumesToVM(mc *vmconfigs.MachineConfig, quiet bool)
 	return nil
 }
 
+// UpdateMounts does nothing, the 9p devices of the mounts are added to the
+// command line and mounted in the guest on the next start.  QEMU cannot add a
+// 9p file system device to a running machine.
+func (q *QEMUStubber) UpdateMounts(_ *vmconfigs.MachineConfig, _, _ []*vmconfigs.Mount) (bool, error) {
+	return false, nil
+}
+
 func (q *QEMUStubber) MountType() vmconfigs.VolumeMountType {
 	return vmconfigs.NineP
 }
@@ -356,5 +487,5 @@ func (q *QEMUStubber) PostStartNetworking(mc *vmconfigs.MachineConfig, noInfo bo
 }
 
 func (q *QEMUStubber) GetDisk(userInputPath string, dirs *define.MachineDirs, mc *vmconfigs.MachineConfig) error {
-	return diskpull.GetDisk(userInputPath, dirs, mc.ImagePath, q.VMType(), mc.Name)
+	return diskpull.GetDisk(userInputPath, dirs, mc, q.VMType())
 }
@@ -0,0 +1,120 @@
+package shim
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// The clock of a guest stands still while the host sleeps, and TLS
+// handshakes and image pulls fail in the guest once it is minutes behind.
+// The monitor of a machine with ClockSync set compares the clock of the
+// guest with the one of the host after the host slept, and every
+// clockCheckInterval otherwise, and steps the clock of the guest when they
+// are more than maxClockSkew apart.
+
+const (
+	maxClockSkew       = 2 * time.Second
+	clockCheckInterval = 10 * time.Minute
+	// hostSleepThreshold is how much more wall clock time than monotonic
+	// time, which stands still while the host sleeps, has to pass between
+	// two checks of the monitor for the host to have slept
+	hostSleepThreshold  = 5 * time.Second
+	clockCommandTimeout = 30 * time.Second
+)
+
+// clockSyncCommands step the clock of the guest, in order of preference:
+// chrony steps it to the time of its NTP sources, hwclock to the real time
+// clock the hypervisor emulates from the clock of the host.  The time of
+// the host is set as a last resort.
+var clockSyncCommands = []string{
+	"sudo chronyc -a makestep",
+	"sudo hwclock --hctosys",
+}
+
+// hostSlept reports whether the host slept between last and now, two
+// readings of time.Now
+func hostSlept(last, now time.Time) bool {
+	wall := now.Round(0).Sub(last.Round(0))
+	return wall-now.Sub(last) > hostSleepThreshold
+}
+
+// ClockSkew returns how far the clock of the running machine is ahead of the
+// clock of the host, negative when it is behind
+func ClockSkew(mc *vmconfigs.MachineConfig) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clockCommandTimeout)
+	defer cancel()
+	before := time.Now()
+	out, err := guestExec(ctx, mc, "date +%s.%N")
+	after := time.Now()
+	if err != nil {
+		return 0, fmt.Errorf("reading the clock of machine %q: %w", mc.Name, err)
+	}
+	guest, err := parseUnixTime(strings.TrimSpace(string(out)))
+	if err != nil {
+		return 0, fmt.Errorf("reading the clock of machine %q: %w", mc.Name, err)
+	}
+	// the guest read its clock about half way through the round trip
+	return guest.Sub(before.Add(after.Sub(before) / 2)), nil
+}
+
+// parseUnixTime parses the seconds since the epoch printed by date +%s.%N
+func parseUnixTime(s string) (time.Time, error) {
+	secs, frac, _ := strings.Cut(s, ".")
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", s)
+	}
+	var nsec int64
+	if frac != "" {
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		frac += strings.Repeat("0", 9-len(frac))
+		if nsec, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q", s)
+		}
+	}
+	return time.Unix(sec, nsec), nil
+}
+
+// SyncClock steps the clock of the running machine to the time of the host
+// when they are more than maxClockSkew apart.  It returns the skew it found
+// before stepping the clock.
+func SyncClock(mc *vmconfigs.MachineConfig) (time.Duration, error) {
+	skew, err := ClockSkew(mc)
+	if err != nil || skew.Abs() <= maxClockSkew {
+		return skew, err
+	}
+
+	mc.Logger().Infof("Clock of machine %q is %s off, stepping it", mc.Name, skew)
+	for _, command := range clockSyncCommands {
+		if out, err := runClockCommand(mc, command); err != nil {
+			mc.Logger().Debugf("%s in machine %q: %v: %s", command, mc.Name, err, out)
+			continue
+		}
+		current, err := ClockSkew(mc)
+		if err != nil {
+			return skew, err
+		}
+		if current.Abs() <= maxClockSkew {
+			return skew, nil
+		}
+	}
+
+	now := time.Now().UTC()
+	if out, err := runClockCommand(mc, fmt.Sprintf("sudo date -u -s @%d.%09d", now.Unix(), now.Nanosecond())); err != nil {
+		return skew, fmt.Errorf("setting the clock of machine %q: %w: %s", mc.Name, err, out)
+	}
+	return skew, nil
+}
+
+func runClockCommand(mc *vmconfigs.MachineConfig, command string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clockCommandTimeout)
+	defer cancel()
+	return guestExec(ctx, mc, command)
+}
@@ -0,0 +1,150 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock replaces the guest with one whose clock is skew off the host.
+// The commands in steps set the clock of the guest right, the others fail.
+// It returns the commands run in the guest besides reading its clock.
+func fakeClock(t *testing.T, skew time.Duration, steps ...string) *[]string {
+	t.Helper()
+	var (
+		lock sync.Mutex
+		ran  []string
+	)
+	origExec, origReachable := guestExec, guestReachable
+	t.Cleanup(func() {
+		guestExec, guestReachable = origExec, origReachable
+	})
+	guestReachable = func(*vmconfigs.MachineConfig) bool { return true }
+	guestExec = func(_ context.Context, _ *vmconfigs.MachineConfig, command string) ([]byte, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if command == "date +%s.%N" {
+			now := time.Now().Add(skew)
+			return []byte(fmt.Sprintf("%d.%09d\n", now.Unix(), now.Nanosecond())), nil
+		}
+		ran = append(ran, command)
+		for _, step := range steps {
+			if strings.HasPrefix(command, step) {
+				skew = 0
+				return nil, nil
+			}
+		}
+		return []byte("failed"), errors.New("exit status 1")
+	}
+	return &ran
+}
+
+func TestParseUnixTime(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{input: "1700000000.123456789", want: time.Unix(1700000000, 123456789)},
+		{input: "1700000000.5", want: time.Unix(1700000000, 500000000)},
+		{input: "1700000000", want: time.Unix(1700000000, 0)},
+		{input: "1700000000.1234567891", want: time.Unix(1700000000, 123456789)},
+		{input: "", wantErr: true},
+		{input: "now", wantErr: true},
+		{input: "1700000000.N", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseUnixTime(tt.input)
+		if tt.wantErr {
+			assert.Error(t, err, tt.input)
+			continue
+		}
+		require.NoError(t, err, tt.input)
+		assert.True(t, tt.want.Equal(got), "%s: got %v", tt.input, got)
+	}
+}
+
+func TestSyncClock(t *testing.T) {
+	mc := &vmconfigs.MachineConfig{Name: "clock"}
+
+	// a clock close enough is left alone
+	ran := fakeClock(t, time.Second)
+	skew, err := SyncClock(mc)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Second, skew, float64(500*time.Millisecond))
+	assert.Empty(t, *ran)
+
+	// chrony has no source, the real time clock is right
+	ran = fakeClock(t, -time.Hour, "sudo hwclock")
+	skew, err = SyncClock(mc)
+	require.NoError(t, err)
+	assert.InDelta(t, -time.Hour, skew, float64(500*time.Millisecond))
+	assert.Equal(t, clockSyncCommands, *ran)
+
+	// the time of the host is set as a last resort
+	ran = fakeClock(t, time.Hour, "sudo date -u -s @")
+	_, err = SyncClock(mc)
+	require.NoError(t, err)
+	require.Len(t, *ran, 3)
+	assert.True(t, strings.HasPrefix((*ran)[2], "sudo date -u -s @"))
+
+	fakeClock(t, time.Hour)
+	_, err = SyncClock(mc)
+	assert.Error(t, err)
+}
+
+func TestMonitorClockSync(t *testing.T) {
+	p, mc, dirs := runningMachine(t, "monitor-clock")
+	mc.ClockSync = true
+	require.NoError(t, mc.Write())
+	ran := fakeClock(t, -time.Hour, "sudo chronyc")
+
+	synced := make(chan time.Duration, 1)
+	cancel, done := runMonitor(mc, p, dirs, MonitorOptions{
+		OnClockSync: func(skew time.Duration, err error) {
+			assert.NoError(t, err)
+			synced <- skew
+		},
+	})
+	defer cancel()
+
+	select {
+	case skew := <-synced:
+		assert.InDelta(t, -time.Hour, skew, float64(time.Second))
+	case <-time.After(5 * time.Second):
+		t.Fatal("the clock of the machine was not synced")
+	}
+	assert.Equal(t, []string{"sudo chronyc -a makestep"}, *ran)
+
+	// without a restart policy, the monitor exits with clock sync off
+	mc.ClockSync = false
+	require.NoError(t, mc.Write())
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the monitor did not exit")
+	}
+	assert.Equal(t, 0, p.Called("StartNetworking"), "gvproxy is not restarted without a restart policy")
+}
+
+func TestSetClockSync(t *testing.T) {
+	spawned := fakeMonitor(t)
+	p, mc, _ := runningMachine(t, "set-clock")
+
+	on := true
+	require.NoError(t, Set(mc, p, define.SetOptions{ClockSync: &on}))
+	assert.True(t, mc.ClockSync)
+	assert.Equal(t, []string{mc.Name}, *spawned, "the running machine is monitored")
+}
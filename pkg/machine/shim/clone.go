@@ -0,0 +1,120 @@
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// Clone creates a new machine named name from the disk image and the
+// configuration of the stopped machine src.  The clone gets its own SSH
+// port, ignition file, system connections and TPM; USB devices and GPUs
+// are not cloned since a device can only be passed to one machine.  The new
+// machine configuration is written before it is returned.
+func Clone(src *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, name string) (*vmconfigs.MachineConfig, error) {
+	state, err := mp.State(src, false)
+	if err != nil {
+		return nil, err
+	}
+	if state != machineDefine.Stopped {
+		return nil, fmt.Errorf("machine %q must be stopped to be cloned: %w", src.Name, machineDefine.ErrWrongState)
+	}
+
+	_, exists, err := VMExists(name, []vmconfigs.VMProvider{mp})
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("%s: %w", name, machineDefine.ErrVMAlreadyExists)
+	}
+
+	opts := initOptionsFrom(src, mp, name)
+	opts.Logger = src.Logger()
+
+	src.Logger().Debugf("cloning machine %q to %q", src.Name, name)
+	copyDisk := func(_ *machineDefine.MachineDirs, mc *vmconfigs.MachineConfig) error {
+		return copyDiskImage(src.ImagePath, mc.ImagePath, mc.Logger())
+	}
+	mc, _, err := initialize(opts, mp, nil, copyDisk)
+	if err != nil {
+		return nil, fmt.Errorf("cloning machine %q: %w", src.Name, err)
+	}
+
+	copyHooks(mc, src)
+	if err := mc.Write(); err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
+// initOptionsFrom returns the options initializing a machine named name
+// like src
+func initOptionsFrom(src *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, name string) machineDefine.InitOptions {
+	volumes := make([]string, 0, len(src.Mounts))
+	for _, mount := range src.Mounts {
+		volumes = append(volumes, mount.OriginalInput)
+	}
+	userModeNetworking := mp.UserModeNetworkEnabled(src)
+	return machineDefine.InitOptions{
+		Name:               name,
+		CPUS:               src.Resources.CPUs,
+		Memory:             src.Resources.Memory,
+		DiskSize:           src.Resources.DiskSize,
+		Username:           src.SSH.RemoteUsername,
+		Rootful:            src.HostUser.Rootful,
+		Volumes:            volumes,
+		UserModeNetworking: &userModeNetworking,
+		Provisioner:        src.Provisioner,
+		ClockSync:          src.ClockSync,
+		WSLNetworking:      string(src.Network.WSLMode),
+		SecureBoot:         src.Resources.Firmware != nil && src.Resources.Firmware.SecureBoot,
+		TPM:                src.Resources.Firmware != nil && src.Resources.Firmware.TPM,
+	}
+}
+
+// copyHooks gives mc the hooks of src, but for the first boot hooks: the
+// disk was provisioned when src first booted
+func copyHooks(mc, src *vmconfigs.MachineConfig) {
+	mc.Hooks = src.Hooks
+	mc.Hooks.FirstBoot = nil
+	mc.HostHooks = src.HostHooks
+}
+
+// copyDiskImage copies the disk image src to dst, which must not exist.
+// The copy goes through os.File so that the kernel can clone or copy the
+// data itself where it supports it.
+func copyDiskImage(src, dst *machineDefine.VMFile, logger machineDefine.Logger) (retErr error) {
+	in, err := os.Open(src.GetPath())
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst.GetPath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := out.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+		if retErr != nil {
+			if err := os.Remove(dst.GetPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+				logger.Warnf("could not remove partial disk image %s: %v", dst.GetPath(), err)
+			}
+		}
+	}()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("copying disk image %s: %w", src.GetPath(), err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"os"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClone(t *testing.T) {
+	p := fakeprovider.New(t)
+	src, dirs := initMachine(t, p, "clone-src")
+	src.Resources.CPUs = 3
+	src.Hooks.PostStart = []vmconfigs.Hook{{Command: "true"}}
+	src.Hooks.FirstBoot = []vmconfigs.Hook{{Command: "true"}}
+	require.NoError(t, src.Write())
+	require.NoError(t, os.WriteFile(src.ImagePath.GetPath(), []byte("pristine"), 0644))
+
+	p.SetState(src.Name, define.Running)
+	_, err := Clone(src, p, "clone-dst")
+	assert.ErrorIs(t, err, define.ErrWrongState)
+	p.SetState(src.Name, define.Stopped)
+
+	_, err = Clone(src, p, src.Name)
+	assert.ErrorIs(t, err, define.ErrVMAlreadyExists)
+
+	mc, err := Clone(src, p, "clone-dst")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, rm, err := mc.Remove(false, false)
+		if err == nil {
+			_ = rm()
+		}
+	})
+
+	// the clone has its own disk image, port and connections
+	assert.NotEqual(t, src.ImagePath.GetPath(), mc.ImagePath.GetPath())
+	content, err := os.ReadFile(mc.ImagePath.GetPath())
+	require.NoError(t, err)
+	assert.Equal(t, "pristine", string(content))
+	assert.NotEqual(t, src.SSH.Port, mc.SSH.Port)
+	assert.Subset(t, connectionNames(t), []string{"clone-dst", "clone-dst-root"})
+
+	loaded, err := vmconfigs.LoadMachineByName("clone-dst", dirs)
+	require.NoError(t, err)
+	assert.Equal(t, src.Resources.CPUs, loaded.Resources.CPUs)
+	assert.Equal(t, src.SSH.RemoteUsername, loaded.SSH.RemoteUsername)
+	assert.Equal(t, src.Hooks.PostStart, loaded.Hooks.PostStart)
+	assert.Empty(t, loaded.Hooks.FirstBoot)
+
+	// changes to the clone do not touch the source
+	require.NoError(t, os.WriteFile(mc.ImagePath.GetPath(), []byte("changed"), 0644))
+	content, err = os.ReadFile(src.ImagePath.GetPath())
+	require.NoError(t, err)
+	assert.Equal(t, "pristine", string(content))
+}
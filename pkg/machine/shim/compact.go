@@ -0,0 +1,92 @@
+package shim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// trimTimeout bounds fstrim in the guest, which walks every file system
+const trimTimeout = 10 * time.Minute
+
+// CompactReport describes a compaction of the disk image of a machine
+type CompactReport struct {
+	// Before and After are the bytes of the host file system used by the
+	// disk image before and after the compaction
+	Before int64
+	After  int64
+	// Trimmed tells whether the free space of the file systems of the
+	// guest was discarded first
+	Trimmed bool
+}
+
+// Compact frees the space of the disk image of the machine that the guest
+// does not use, as disk images only ever grow otherwise.  A running machine
+// discards the free space of its file systems with fstrim first, so that the
+// provider can reclaim it, then is stopped for the compaction and started
+// again.  A stopped machine is compacted as it is.
+func Compact(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, dirs *machineDefine.MachineDirs) (*CompactReport, error) {
+	if mp.VMType() == machineDefine.WSLVirt {
+		return nil, fmt.Errorf("%s machines do not support compacting their disk: %w", mp.VMType().String(), machineDefine.ErrNotImplemented)
+	}
+	state, err := mp.State(mc, false)
+	if err != nil {
+		return nil, err
+	}
+	if state != machineDefine.Running && state != machineDefine.Stopped {
+		return nil, fmt.Errorf("machine %q must be running or stopped to compact its disk: %w", mc.Name, machineDefine.ErrWrongState)
+	}
+	running := state == machineDefine.Running
+
+	report := new(CompactReport)
+	if running {
+		ctx, cancel := context.WithTimeout(context.Background(), trimTimeout)
+		out, err := guestExec(ctx, mc, "sudo fstrim --all")
+		cancel()
+		if err != nil {
+			mc.Logger().Warnf("Discarding the free space of machine %q: %v: %s", mc.Name, err, out)
+		} else {
+			report.Trimmed = true
+		}
+		if _, err := Stop(mc, mp, dirs, machine.StopOptions{}); err != nil {
+			return nil, err
+		}
+	}
+
+	if report.Before, err = diskUsage(mc.ImagePath.GetPath()); err != nil {
+		return nil, err
+	}
+	mc.Logger().Debugf("compacting disk of machine %q", mc.Name)
+	compactErr := mp.CompactDisk(mc)
+	if compactErr != nil {
+		compactErr = fmt.Errorf("compacting disk of machine %q: %w", mc.Name, compactErr)
+	}
+
+	if running {
+		mc.Starting = true
+		if err := mc.Write(); err != nil {
+			mc.Logger().Errorf("%v", err)
+		}
+		_, err := Start(mc, mp, dirs, machine.StartOptions{NoInfo: true, Quiet: true})
+		mc.Starting = false
+		if werr := mc.Write(); werr != nil {
+			mc.Logger().Errorf("%v", werr)
+		}
+		if err != nil {
+			return nil, errors.Join(compactErr, fmt.Errorf("starting machine %q again: %w", mc.Name, err))
+		}
+	}
+	if compactErr != nil {
+		return nil, compactErr
+	}
+
+	if report.After, err = diskUsage(mc.ImagePath.GetPath()); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,61 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompact(t *testing.T) {
+	ran := fakeGuest(t, nil)
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "compact")
+	disk := append([]byte("data"), make([]byte, 64*1024)...)
+	require.NoError(t, os.WriteFile(mc.ImagePath.GetPath(), disk, 0644))
+
+	// a stopped machine is compacted as it is
+	report, err := Compact(mc, p, dirs)
+	require.NoError(t, err)
+	assert.False(t, report.Trimmed)
+	assert.Less(t, report.After, report.Before)
+	assert.Empty(t, *ran)
+	assert.Equal(t, 0, p.Called("StartVM"))
+	content, err := os.ReadFile(mc.ImagePath.GetPath())
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(content))
+
+	// a running machine trims its file systems and is started again
+	p.SetState(mc.Name, define.Running)
+	report, err = Compact(mc, p, dirs)
+	require.NoError(t, err)
+	assert.True(t, report.Trimmed)
+	assert.Equal(t, []string{"sudo fstrim --all"}, *ran)
+	assert.Equal(t, 1, p.Called("StopVM"))
+	assert.Equal(t, 1, p.Called("StartVM"))
+	assert.Equal(t, 2, p.Called("CompactDisk"))
+	assert.False(t, mc.Starting)
+}
+
+func TestCompactFailure(t *testing.T) {
+	fakeGuest(t, map[string]error{"sudo fstrim --all": errors.New("exit status 1")})
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "compact-fail")
+	p.SetState(mc.Name, define.Running)
+	p.Fail("CompactDisk", errors.New("no space left"))
+
+	// the machine is started again even though the compaction failed
+	_, err := Compact(mc, p, dirs)
+	assert.ErrorContains(t, err, "no space left")
+	assert.Equal(t, 1, p.Called("StartVM"))
+
+	p.SetState(mc.Name, define.Starting)
+	_, err = Compact(mc, p, dirs)
+	assert.ErrorIs(t, err, define.ErrWrongState)
+}
@@ -0,0 +1,83 @@
+package shim
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/containers/common/pkg/config"
+	"github.com/containers/podman/v5/pkg/machine/connection"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// rootfulConnectionSuffix is appended to the machine name for the rootful connection
+const rootfulConnectionSuffix = "-root"
+
+// ListConnections returns the podman system connections that were registered
+// for a machine, sorted by name
+func ListConnections() ([]config.Connection, error) {
+	cfg, err := config.Default()
+	if err != nil {
+		return nil, err
+	}
+	cons, err := cfg.GetAllConnections()
+	if err != nil {
+		return nil, err
+	}
+	machineCons := make([]config.Connection, 0, len(cons))
+	for _, con := range cons {
+		if con.IsMachine {
+			machineCons = append(machineCons, con)
+		}
+	}
+	sort.Slice(machineCons, func(i, j int) bool {
+		return machineCons[i].Name < machineCons[j].Name
+	})
+	return machineCons, nil
+}
+
+// PruneConnections removes machine connections that have no backing machine
+// in any of the given providers and returns the names of the removed
+// connections, which are logged to logger
+func PruneConnections(vmstubbers []vmconfigs.VMProvider, logger define.Logger) ([]string, error) {
+	mcs, err := getMCsOverProviders(vmstubbers)
+	if err != nil {
+		return nil, err
+	}
+	cons, err := ListConnections()
+	if err != nil {
+		return nil, err
+	}
+
+	dangling := danglingConnections(cons, mcs)
+	if len(dangling) == 0 {
+		return nil, nil
+	}
+	logger.Debugf("Removing dangling machine connections: %s", strings.Join(dangling, ", "))
+	if err := connection.RemoveConnections(dangling...); err != nil {
+		return nil, err
+	}
+	return dangling, nil
+}
+
+// danglingConnections returns the names of the connections not owned by any of
+// the given machines.  Connections that are not stored in the connections file
+// cannot be removed and are never returned.
+func danglingConnections(cons []config.Connection, mcs map[string]*vmconfigs.MachineConfig) []string {
+	var dangling []string
+	for _, con := range cons {
+		if !con.IsMachine || !con.ReadWrite {
+			continue
+		}
+		if _, found := mcs[con.Name]; found {
+			continue
+		}
+		if name, isRootful := strings.CutSuffix(con.Name, rootfulConnectionSuffix); isRootful {
+			if _, found := mcs[name]; found {
+				continue
+			}
+		}
+		dangling = append(dangling, con.Name)
+	}
+	return dangling
+}
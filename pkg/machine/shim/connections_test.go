@@ -0,0 +1,50 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/connection"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func connectionNames(t *testing.T) []string {
+	t.Helper()
+	cons, err := ListConnections()
+	require.NoError(t, err)
+	names := make([]string, 0, len(cons))
+	for _, con := range cons {
+		names = append(names, con.Name)
+	}
+	return names
+}
+
+func TestPruneConnections(t *testing.T) {
+	p := fakeprovider.New(t)
+	initMachine(t, p, "keep")
+	// a machine whose name looks like a rootful connection
+	initMachine(t, p, "keep2-root")
+
+	// connections left behind by a machine that no longer exists
+	require.NoError(t, connection.AddSSHConnectionsToPodmanSocket(1000, 2222, "/tmp/id", "ghost", "core", define.InitOptions{}))
+	assert.Subset(t, connectionNames(t), []string{"ghost", "ghost-root", "keep", "keep-root", "keep2-root", "keep2-root-root"})
+
+	removed, err := PruneConnections([]vmconfigs.VMProvider{p}, define.LoggerOrStandard(nil))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"ghost", "ghost-root"}, removed)
+
+	names := connectionNames(t)
+	assert.NotContains(t, names, "ghost")
+	assert.NotContains(t, names, "ghost-root")
+	assert.Subset(t, names, []string{"keep", "keep-root", "keep2-root", "keep2-root-root"})
+
+	// nothing left to prune
+	removed, err = PruneConnections([]vmconfigs.VMProvider{p}, define.LoggerOrStandard(nil))
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+}
@@ -0,0 +1,85 @@
+package shim
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// ConsoleEscape is the byte read from stdin that detaches from the console,
+// Ctrl-]
+const ConsoleEscape = 0x1d
+
+// Console attaches stdin and stdout to the serial console of the running
+// machine until ConsoleEscape is read from stdin or the console is closed.
+// The console works while the machine boots, before it can be reached over
+// SSH.
+func Console(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, stdin io.Reader, stdout io.Writer) error {
+	state, err := mp.State(mc, false)
+	if err != nil {
+		return err
+	}
+	if state != machineDefine.Running {
+		return fmt.Errorf("machine %q must be running to attach to its console: %w", mc.Name, machineDefine.ErrWrongState)
+	}
+
+	console, err := mp.AttachConsole(mc)
+	if err != nil {
+		if errors.Is(err, machineDefine.ErrNotImplemented) {
+			return fmt.Errorf("%s machines have no serial console: %w", mp.VMType().String(), err)
+		}
+		return err
+	}
+	defer func() {
+		if err := console.Close(); err != nil {
+			mc.Logger().Debugf("closing the console of machine %q: %v", mc.Name, err)
+		}
+	}()
+
+	done := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(stdout, console)
+		done <- err
+	}()
+	go func() {
+		done <- copyUntilEscape(console, stdin, mc.Logger())
+	}()
+	return <-done
+}
+
+// copyUntilEscape copies stdin to the console until ConsoleEscape is read.
+// Input is dropped once the console refuses it, e.g. because it is
+// read-only, so that the escape still works.
+func copyUntilEscape(console io.Writer, stdin io.Reader, logger machineDefine.Logger) error {
+	buf := make([]byte, 1024)
+	writable := true
+	for {
+		n, err := stdin.Read(buf)
+		if n > 0 {
+			data := buf[:n]
+			i := bytes.IndexByte(data, ConsoleEscape)
+			if i >= 0 {
+				data = data[:i]
+			}
+			if writable && len(data) > 0 {
+				if _, err := console.Write(data); err != nil {
+					logger.Warnf("console input is ignored: %v", err)
+					writable = false
+				}
+			}
+			if i >= 0 {
+				return nil
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,75 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsole(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "console")
+
+	err := Console(mc, p, strings.NewReader(""), io.Discard)
+	assert.ErrorIs(t, err, define.ErrWrongState)
+
+	p.SetState(mc.Name, define.Running)
+	err = Console(mc, p, strings.NewReader(""), io.Discard)
+	assert.ErrorIs(t, err, define.ErrNotImplemented)
+
+	host, guest := net.Pipe()
+	p.Console = host
+	received := make(chan string, 1)
+	go func() {
+		// the guest echoes what it reads until the console is closed
+		var input bytes.Buffer
+		buf := make([]byte, 64)
+		for {
+			n, err := guest.Read(buf)
+			input.Write(buf[:n])
+			if err != nil {
+				received <- input.String()
+				return
+			}
+			if _, err := guest.Write(buf[:n]); err != nil {
+				received <- input.String()
+				return
+			}
+		}
+	}()
+
+	// input after the escape is not sent
+	var output bytes.Buffer
+	stdin := io.MultiReader(strings.NewReader("login\n"), strings.NewReader("\x1dexit\n"))
+	require.NoError(t, Console(mc, p, stdin, &output))
+	assert.Equal(t, "login\n", <-received)
+}
+
+func TestCopyUntilEscape(t *testing.T) {
+	var console bytes.Buffer
+	require.NoError(t, copyUntilEscape(&console, strings.NewReader("ls\n\x1dignored"), define.LoggerOrStandard(nil)))
+	assert.Equal(t, "ls\n", console.String())
+
+	// a read-only console still detaches on the escape
+	require.NoError(t, copyUntilEscape(failingWriter{}, strings.NewReader("ls\n\x1d"), define.LoggerOrStandard(nil)))
+
+	console.Reset()
+	require.NoError(t, copyUntilEscape(&console, strings.NewReader("eof"), define.LoggerOrStandard(nil)))
+	assert.Equal(t, "eof", console.String())
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("read-only")
+}
@@ -0,0 +1,93 @@
+package shim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"golang.org/x/exp/slices"
+)
+
+// guestMountsTimeout is how long listing the mounts of the guest may take
+const guestMountsTimeout = 30 * time.Second
+
+// DiffConfig compares the configuration of a running machine with the values
+// reported by its provider and returns the settings that differ, e.g. because
+// the configuration was changed while the machine was running.  Mounts the
+// provider cannot report are read from the guest.
+func DiffConfig(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider) ([]vmconfigs.ConfigDiscrepancy, error) {
+	state, err := mp.State(mc, false)
+	if err != nil {
+		return nil, err
+	}
+	if state != machineDefine.Running {
+		return nil, fmt.Errorf("machine %q must be running to compare its configuration: %w", mc.Name, machineDefine.ErrWrongState)
+	}
+
+	running, err := mp.RunningConfig(mc)
+	if err != nil {
+		return nil, fmt.Errorf("querying the running configuration of machine %q: %w", mc.Name, err)
+	}
+	if running.Mounts == nil {
+		running.Mounts, err = guestMounts(mc)
+		if err != nil {
+			mc.Logger().Debugf("Unable to list the mounts of machine %q: %v", mc.Name, err)
+		}
+	}
+
+	var diffs []vmconfigs.ConfigDiscrepancy
+	compare := func(setting string, configured, actual uint64, unit string) {
+		if actual != 0 && actual != configured {
+			diffs = append(diffs, vmconfigs.ConfigDiscrepancy{
+				Setting:    setting,
+				Configured: strconv.FormatUint(configured, 10) + unit,
+				Running:    strconv.FormatUint(actual, 10) + unit,
+			})
+		}
+	}
+	compare("CPUs", mc.Resources.CPUs, running.CPUs, "")
+	compare("Memory", mc.Resources.Memory, running.Memory, "MB")
+	compare("DiskSize", mc.Resources.DiskSize, running.DiskSize, "GB")
+
+	if running.Mounts != nil {
+		for _, mount := range mc.Mounts {
+			if !slices.Contains(running.Mounts, mount.Target) {
+				diffs = append(diffs, vmconfigs.ConfigDiscrepancy{
+					Setting:    "Mounts",
+					Configured: fmt.Sprintf("%s mounted on %s", mount.Source, mount.Target),
+					Running:    "nothing mounted on " + mount.Target,
+				})
+			}
+		}
+	}
+
+	if running.UserModeNetworking != nil {
+		if configured := mp.UserModeNetworkEnabled(mc); configured != *running.UserModeNetworking {
+			diffs = append(diffs, vmconfigs.ConfigDiscrepancy{
+				Setting:    "UserModeNetworking",
+				Configured: strconv.FormatBool(configured),
+				Running:    strconv.FormatBool(*running.UserModeNetworking),
+			})
+		}
+	}
+	return diffs, nil
+}
+
+// guestMounts returns the mount points of the guest
+func guestMounts(mc *vmconfigs.MachineConfig) ([]string, error) {
+	if !guestReachable(mc) {
+		return nil, errors.New("guest is not reachable")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), guestMountsTimeout)
+	defer cancel()
+	out, err := guestExec(ctx, mc, "findmnt --noheadings --list --output TARGET")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
@@ -0,0 +1,78 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffConfig(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "diff")
+	mc.Resources.CPUs = 4
+	mc.Resources.Memory = 4096
+	mc.Resources.DiskSize = 100
+	mc.Mounts = []*vmconfigs.Mount{
+		{Source: "/home/user", Target: "/home/user"},
+		{Source: "/srv/data", Target: "/data"},
+	}
+
+	_, err := DiffConfig(mc, p)
+	assert.ErrorIs(t, err, define.ErrWrongState)
+
+	p.SetState(mc.Name, define.Running)
+	p.SetRunningConfig(&vmconfigs.RunningConfig{
+		CPUs:     4,
+		Memory:   4096,
+		DiskSize: 100,
+		Mounts:   []string{"/", "/home/user", "/data"},
+	})
+	diffs, err := DiffConfig(mc, p)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	userMode := true
+	p.SetRunningConfig(&vmconfigs.RunningConfig{
+		CPUs:               2,
+		Memory:             4096,
+		Mounts:             []string{"/", "/home/user"},
+		UserModeNetworking: &userMode,
+	})
+	diffs, err = DiffConfig(mc, p)
+	require.NoError(t, err)
+	assert.Equal(t, []vmconfigs.ConfigDiscrepancy{
+		{Setting: "CPUs", Configured: "4", Running: "2"},
+		{Setting: "Mounts", Configured: "/srv/data mounted on /data", Running: "nothing mounted on /data"},
+		{Setting: "UserModeNetworking", Configured: "false", Running: "true"},
+	}, diffs)
+	assert.Equal(t, "CPUs: config says 4, running machine has 2", diffs[0].String())
+}
+
+func TestDiffConfigGuestMounts(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "diffmounts")
+	mc.Mounts = []*vmconfigs.Mount{
+		{Source: "/home/user", Target: "/home/user"},
+		{Source: "/srv/data", Target: "/data"},
+	}
+	p.SetState(mc.Name, define.Running)
+
+	// the provider does not know the mounts, the guest is asked
+	ran := fakeGuest(t, nil)
+	diffs, err := DiffConfig(mc, p)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"findmnt --noheadings --list --output TARGET"}, *ran)
+	// the fake guest reports "output of findmnt ..." as its mounts
+	assert.Len(t, diffs, 2)
+
+	p.Fail("RunningConfig", errors.New("monitor gone"))
+	_, err = DiffConfig(mc, p)
+	assert.ErrorContains(t, err, "monitor gone")
+}
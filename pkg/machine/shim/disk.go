@@ -0,0 +1,75 @@
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// diskImageName returns the file name of the disk image of a machine.  New
+// images are named vmname-ARCH with an extension matching their format.
+func diskImageName(name, extension string) string {
+	return fmt.Sprintf("%s-%s%s", name, runtime.GOARCH, extension)
+}
+
+// ConvertDiskFormat converts the disk image of a stopped machine to the given
+// format.  The converted image is written next to the original, which is only
+// removed once the conversion is verified and the configuration is updated.
+func ConvertDiskFormat(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, targetFormat machineDefine.ImageFormat) error {
+	if mc.ImagePath == nil {
+		return fmt.Errorf("machine %q has no disk image", mc.Name)
+	}
+	state, err := mp.State(mc, false)
+	if err != nil {
+		return err
+	}
+	if state != machineDefine.Stopped {
+		return fmt.Errorf("machine %q must be stopped to convert its disk: %w", mc.Name, machineDefine.ErrWrongState)
+	}
+
+	src := mc.ImagePath
+	extension := "." + targetFormat.Kind()
+	if filepath.Ext(src.GetPath()) == extension {
+		mc.Logger().Debugf("disk of machine %q is already in %s format", mc.Name, targetFormat.Kind())
+		return nil
+	}
+
+	dstPath := filepath.Join(filepath.Dir(src.GetPath()), diskImageName(mc.Name, extension))
+	if _, err := os.Stat(dstPath); err == nil {
+		return fmt.Errorf("converting disk of machine %q: %q already exists", mc.Name, dstPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	dst, err := machineDefine.NewMachineFile(dstPath, nil)
+	if err != nil {
+		return err
+	}
+
+	mc.Logger().Debugf("converting disk of machine %q from %q to %q", mc.Name, src.GetPath(), dst.GetPath())
+	if err := mp.ConvertDisk(mc, src, dst, targetFormat); err != nil {
+		if err := dst.Delete(); err != nil {
+			mc.Logger().Errorf("%v", err)
+		}
+		return fmt.Errorf("converting disk of machine %q to %s: %w", mc.Name, targetFormat.Kind(), err)
+	}
+	if fi, err := os.Stat(dst.GetPath()); err != nil {
+		return fmt.Errorf("verifying converted disk of machine %q: %w", mc.Name, err)
+	} else if !fi.Mode().IsRegular() {
+		return fmt.Errorf("verifying converted disk of machine %q: %q is not a regular file", mc.Name, dst.GetPath())
+	}
+
+	mc.ImagePath = dst
+	if err := mc.Write(); err != nil {
+		mc.ImagePath = src
+		if err := dst.Delete(); err != nil {
+			mc.Logger().Errorf("%v", err)
+		}
+		return err
+	}
+	return src.Delete()
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package shim
+
+import (
+	"os"
+	"syscall"
+)
+
+// diskUsage returns the bytes of the host file system used by the file,
+// which is less than its size when it is sparse
+func diskUsage(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return stat.Blocks * 512, nil
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,13 @@
+package shim
+
+import "os"
+
+// diskUsage returns the bytes of the host file system used by the file.
+// Disk images are not sparse on Windows, a vhdx shrinks instead.
+func diskUsage(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
@@ -2,31 +2,169 @@ package diskpull
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/diskcache"
 	"github.com/containers/podman/v5/pkg/machine/ocipull"
 	"github.com/containers/podman/v5/pkg/machine/stdpull"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/opencontainers/go-digest"
 )
 
-func GetDisk(userInputPath string, dirs *define.MachineDirs, imagePath *define.VMFile, vmType define.VMType, name string) error {
-	var (
-		err    error
-		mydisk ocipull.Disker
-	)
+// sourceKind is where a disk image comes from
+type sourceKind int
 
-	if userInputPath == "" {
-		mydisk, err = ocipull.NewOCIArtifactPull(context.Background(), dirs, name, vmType, imagePath)
-	} else {
-		if strings.HasPrefix(userInputPath, "http") {
-			// TODO probably should use tempdir instead of datadir
-			mydisk, err = stdpull.NewDiskFromURL(userInputPath, imagePath, dirs.DataDir, nil)
-		} else {
-			mydisk, err = stdpull.NewStdDiskPull(userInputPath, imagePath)
+const (
+	// defaultSource is the machine image matching the podman version
+	defaultSource sourceKind = iota
+	// registrySource is a disk artifact in a registry, docker://
+	registrySource
+	// ociLayoutSource is a disk artifact in a local OCI layout, oci:
+	ociLayoutSource
+	// urlSource is a disk image served over http(s)
+	urlSource
+	// fileSource is a local disk image
+	fileSource
+)
+
+// source is a parsed --image-path
+type source struct {
+	kind sourceKind
+	// ref is the image reference, URL or path, without the digest
+	ref string
+	// digest is the expected digest of an URL or a file, if given
+	digest digest.Digest
+}
+
+// parseSource parses the disk image given by the user.  URLs and paths may
+// end with @<algorithm>:<hex> to give the digest of the image; image
+// references carry their digest themselves.
+func parseSource(input string) (*source, error) {
+	switch {
+	case input == "":
+		return &source{kind: defaultSource}, nil
+	case strings.HasPrefix(input, "docker://"):
+		return &source{kind: registrySource, ref: input}, nil
+	case strings.HasPrefix(input, "oci:"):
+		return &source{kind: ociLayoutSource, ref: input}, nil
+	}
+
+	src := &source{kind: fileSource, ref: input}
+	if strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://") {
+		src.kind = urlSource
+	}
+	i := digestSuffix(input, src.kind == urlSource)
+	if i < 0 {
+		return src, nil
+	}
+	d, err := digest.Parse(input[i+1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest in disk image %q: %w", input, err)
+	}
+	src.ref, src.digest = input[:i], d
+	return src, nil
+}
+
+// digestSuffix returns the index of the "@" starting the digest at the end of
+// input, or -1 if there is none.  Only a suffix naming a known digest
+// algorithm is a digest, other "@"s are part of the name, as is one in the
+// host part of an URL, e.g. before a user name.
+func digestSuffix(input string, isURL bool) int {
+	i := strings.LastIndex(input, "@")
+	if i < 0 {
+		return -1
+	}
+	if isURL {
+		_, rest, _ := strings.Cut(input, "://")
+		host := len(input) - len(rest)
+		if slash := strings.Index(rest, "/"); slash < 0 || i < host+slash {
+			return -1
 		}
 	}
+	suffix := input[i+1:]
+	algorithm, _, ok := strings.Cut(suffix, ":")
+	if !ok || strings.Contains(suffix, "/") || !digest.Algorithm(algorithm).Available() {
+		return -1
+	}
+	return i
+}
+
+// GetDisk resolves the disk image given by the user, pulls it through the
+// disk image cache where it comes from a registry or an URL, verifies its
+// digest and decompresses it to the image path of the machine.  Without
+// userInputPath the machine image matching the podman version is pulled.
+// The source and, when known, the digest of the image are recorded in the
+// machine configuration.
+func GetDisk(userInputPath string, dirs *define.MachineDirs, mc *vmconfigs.MachineConfig, vmType define.VMType) error {
+	src, err := parseSource(userInputPath)
 	if err != nil {
 		return err
 	}
-	return mydisk.Get()
+	switch src.kind {
+	case defaultSource, registrySource, ociLayoutSource:
+		ociDisk, err := newOCIDisk(src, dirs, mc.ImagePath, vmType, mc.Name)
+		if err != nil {
+			return err
+		}
+		if err := ociDisk.Get(); err != nil {
+			return err
+		}
+		mc.ImageSource, mc.ImageDigest = ociDisk.Reference(), ociDisk.Digest()
+		return nil
+	case urlSource:
+		mydisk, err := stdpull.NewCachedDiskFromURL(src.ref, src.digest, mc.ImagePath, diskcache.New(dirs.ImageCacheDir))
+		if err != nil {
+			return err
+		}
+		if err := mydisk.Get(); err != nil {
+			return err
+		}
+	default:
+		if src.digest != "" {
+			if err := diskcache.Verify(src.ref, src.digest); err != nil {
+				return err
+			}
+		}
+		mydisk, err := stdpull.NewStdDiskPull(src.ref, mc.ImagePath)
+		if err != nil {
+			return err
+		}
+		if err := mydisk.Get(); err != nil {
+			return err
+		}
+	}
+	mc.ImageSource, mc.ImageDigest = userInputPath, src.digest
+	return nil
+}
+
+// LatestDigest returns the reference and the digest of the disk image the
+// image source of a machine currently points to.  Only registries and OCI
+// layouts publish new disk images; an empty source is the machine image
+// matching the podman version.
+func LatestDigest(imageSource string, dirs *define.MachineDirs, mc *vmconfigs.MachineConfig, vmType define.VMType) (string, digest.Digest, error) {
+	src, err := parseSource(imageSource)
+	if err != nil {
+		return "", "", err
+	}
+	if src.kind != defaultSource && src.kind != registrySource && src.kind != ociLayoutSource {
+		return "", "", fmt.Errorf("disk image %q is not an image reference and has no updates", imageSource)
+	}
+	ociDisk, err := newOCIDisk(src, dirs, mc.ImagePath, vmType, mc.Name)
+	if err != nil {
+		return "", "", err
+	}
+	latest, err := ociDisk.Resolve()
+	if err != nil {
+		return "", "", err
+	}
+	return ociDisk.Reference(), latest, nil
+}
+
+func newOCIDisk(src *source, dirs *define.MachineDirs, imagePath *define.VMFile, vmType define.VMType, name string) (*ocipull.OCIArtifactDisk, error) {
+	if src.kind == defaultSource {
+		return ocipull.NewOCIArtifactPull(context.Background(), dirs, name, vmType, imagePath)
+	}
+	return ocipull.NewOCIArtifactPullFromReference(context.Background(), dirs, src.ref, name, vmType, imagePath)
 }
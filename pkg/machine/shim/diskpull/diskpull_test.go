@@ -0,0 +1,50 @@
+package diskpull
+
+import (
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSource(t *testing.T) {
+	sum := digest.FromString("disk")
+	tests := []struct {
+		input string
+		want  source
+	}{
+		{input: "", want: source{kind: defaultSource}},
+		{input: "docker://quay.io/podman/machine-os:5.0", want: source{kind: registrySource, ref: "docker://quay.io/podman/machine-os:5.0"}},
+		{input: "docker://quay.io/podman/machine-os@" + sum.String(), want: source{kind: registrySource, ref: "docker://quay.io/podman/machine-os@" + sum.String()}},
+		{input: "oci:/var/tmp/layout:5.0", want: source{kind: ociLayoutSource, ref: "oci:/var/tmp/layout:5.0"}},
+		{input: "https://example.com/disk.qcow2.xz", want: source{kind: urlSource, ref: "https://example.com/disk.qcow2.xz"}},
+		{input: "http://example.com/disk.qcow2.xz@" + sum.String(), want: source{kind: urlSource, ref: "http://example.com/disk.qcow2.xz", digest: sum}},
+		{input: "/var/tmp/disk.qcow2", want: source{kind: fileSource, ref: "/var/tmp/disk.qcow2"}},
+		{input: "/var/tmp/me@home/disk.qcow2", want: source{kind: fileSource, ref: "/var/tmp/me@home/disk.qcow2"}},
+		{input: "disk.raw.zst@" + sum.String(), want: source{kind: fileSource, ref: "disk.raw.zst", digest: sum}},
+		// only a suffix naming a digest algorithm is a digest
+		{input: "/vms/a@b:1.qcow2", want: source{kind: fileSource, ref: "/vms/a@b:1.qcow2"}},
+		{input: "/vms/a@sha256:1/disk.qcow2", want: source{kind: fileSource, ref: "/vms/a@sha256:1/disk.qcow2"}},
+		{input: "https://example.com/disk.qcow2@md5:d41d8cd98f00b204e9800998ecf8427e", want: source{kind: urlSource, ref: "https://example.com/disk.qcow2@md5:d41d8cd98f00b204e9800998ecf8427e"}},
+		// a "@" in the host part of an URL is not a digest
+		{input: "https://user@host:8443/img.qcow2", want: source{kind: urlSource, ref: "https://user@host:8443/img.qcow2"}},
+		{input: "https://user@host:8443", want: source{kind: urlSource, ref: "https://user@host:8443"}},
+		{input: "https://user@host:8443/img.qcow2@" + sum.String(), want: source{kind: urlSource, ref: "https://user@host:8443/img.qcow2", digest: sum}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseSource(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *got)
+		})
+	}
+
+	for _, input := range []string{
+		"/var/tmp/disk.qcow2@sha256:abc",
+		"https://example.com/disk.qcow2@sha512:" + sum.Encoded(),
+	} {
+		_, err := parseSource(input)
+		assert.Error(t, err, input)
+	}
+}
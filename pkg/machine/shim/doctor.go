@@ -0,0 +1,264 @@
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/containers/common/pkg/config"
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/connection"
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"golang.org/x/exp/slices"
+)
+
+// The checks run by Doctor
+const (
+	CheckState       = "state"
+	CheckImage       = "image"
+	CheckIdentity    = "identity"
+	CheckSSHPort     = "ssh-port"
+	CheckGvproxy     = "gvproxy"
+	CheckPidFiles    = "pid-files"
+	CheckReadySocket = "ready-socket"
+	CheckConnections = "connections"
+)
+
+// DoctorOptions are the options of Doctor
+type DoctorOptions struct {
+	// Names restricts the checks of machines to the named machines, the
+	// checks of the host are always run
+	Names []string
+	// Repair repairs the problems that can be repaired
+	Repair bool
+}
+
+// DoctorFinding is a problem found by Doctor
+type DoctorFinding struct {
+	// Machine is the machine the problem is about, empty for the host
+	Machine string `json:",omitempty"`
+	// Check is the check that found the problem, e.g. CheckSSHPort
+	Check string
+	// Problem describes what is wrong
+	Problem string
+	// Repair describes how the problem is repaired, empty when it must be
+	// repaired by hand
+	Repair string `json:",omitempty"`
+	// Repaired is set once the problem is repaired
+	Repaired bool `json:",omitempty"`
+	// RepairError is why the problem could not be repaired
+	RepairError string `json:",omitempty"`
+
+	repair func() error
+}
+
+// findHelperBinary finds a helper binary of podman, it is a variable so that
+// tests do not depend on the binaries of the host
+var findHelperBinary = func(name string) (string, error) {
+	cfg, err := config.Default()
+	if err != nil {
+		return "", err
+	}
+	return cfg.FindHelperBinary(name, false)
+}
+
+// Doctor checks that the machines of the given providers, their files and
+// processes and the system connections of the host are consistent, and
+// repairs what it can with opts.Repair.  It is meant for the problems that
+// otherwise need a reset.  The findings are reported machine by machine,
+// the findings about the host last.
+func Doctor(vmstubbers []vmconfigs.VMProvider, opts DoctorOptions) ([]*DoctorFinding, error) {
+	type providerMachines struct {
+		mp       vmconfigs.VMProvider
+		machines map[string]*vmconfigs.MachineConfig
+	}
+	var (
+		findings     []*DoctorFinding
+		loaded       []providerMachines
+		needsGvproxy bool
+	)
+	mcs := make(map[string]*vmconfigs.MachineConfig)
+	for _, mp := range vmstubbers {
+		dirs, err := machine.GetMachineDirs(mp.VMType())
+		if err != nil {
+			return nil, err
+		}
+		machines, err := vmconfigs.LoadMachinesInDir(dirs)
+		if err != nil {
+			return nil, err
+		}
+		for name, mc := range machines {
+			if _, found := mcs[name]; !found {
+				mcs[name] = mc
+			}
+		}
+		if len(machines) > 0 && !mp.UseProviderNetworkSetup() {
+			needsGvproxy = true
+		}
+		loaded = append(loaded, providerMachines{mp: mp, machines: machines})
+	}
+	for _, name := range opts.Names {
+		if _, found := mcs[name]; !found {
+			return nil, fmt.Errorf("%s: %w", name, machineDefine.ErrNoSuchVM)
+		}
+	}
+
+	for _, pm := range loaded {
+		names := make([]string, 0, len(pm.machines))
+		for name := range pm.machines {
+			if len(opts.Names) == 0 || slices.Contains(opts.Names, name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			findings = append(findings, checkMachine(pm.machines[name], pm.mp, mcs)...)
+		}
+	}
+
+	if needsGvproxy {
+		if _, err := findHelperBinary(machine.ForwarderBinaryName); err != nil {
+			findings = append(findings, &DoctorFinding{
+				Check:   CheckGvproxy,
+				Problem: fmt.Sprintf("%s cannot be found, machines cannot start: %v", machine.ForwarderBinaryName, err),
+			})
+		}
+	}
+	connectionFindings, err := checkConnections(mcs)
+	if err != nil {
+		return nil, err
+	}
+	findings = append(findings, connectionFindings...)
+
+	if opts.Repair {
+		for _, f := range findings {
+			if f.repair == nil {
+				continue
+			}
+			if err := f.repair(); err != nil {
+				f.RepairError = err.Error()
+				continue
+			}
+			f.Repaired = true
+		}
+	}
+	return findings, nil
+}
+
+// checkMachine checks the machine mc of provider mp.  others are the
+// machines of all providers, whose SSH ports must differ from the one of mc.
+func checkMachine(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, others map[string]*vmconfigs.MachineConfig) []*DoctorFinding {
+	var findings []*DoctorFinding
+	add := func(check, problem, repair string, repairFunc func() error) {
+		findings = append(findings, &DoctorFinding{
+			Machine: mc.Name,
+			Check:   check,
+			Problem: problem,
+			Repair:  repair,
+			repair:  repairFunc,
+		})
+	}
+
+	state, err := mp.State(mc, false)
+	if err != nil {
+		add(CheckState, fmt.Sprintf("the state of the machine cannot be read: %v", err), "", nil)
+		return findings
+	}
+	if mc.Starting && state != machineDefine.Starting && state != machineDefine.Running {
+		add(CheckState, "the machine is marked as starting but is "+state, "clear the starting mark", func() error {
+			mc.Starting = false
+			return mc.Write()
+		})
+	}
+
+	if mc.ImagePath != nil {
+		if _, err := os.Stat(mc.ImagePath.GetPath()); errors.Is(err, os.ErrNotExist) {
+			add(CheckImage, fmt.Sprintf("the disk image %s is missing, remove and recreate the machine", mc.ImagePath.GetPath()), "", nil)
+		}
+	}
+
+	for _, path := range []string{mc.SSH.IdentityPath, mc.SSH.IdentityPath + ".pub"} {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			add(CheckIdentity, fmt.Sprintf("the SSH identity file %s is missing, the machine cannot be reached", path), "", nil)
+		}
+	}
+
+	if state != machineDefine.Running && !mp.UseProviderNetworkSetup() {
+		// of the machines sharing a port, the one named first keeps it
+		shared := ""
+		for name, other := range others {
+			if name < mc.Name && other.SSH.Port == mc.SSH.Port && (shared == "" || name < shared) {
+				shared = name
+			}
+		}
+		switch {
+		case shared != "":
+			add(CheckSSHPort, fmt.Sprintf("SSH port %d is also used by machine %q", mc.SSH.Port, shared), "reassign the SSH port", func() error {
+				return reassignSSHPort(mc)
+			})
+		case !machine.IsLocalPortAvailable(mc.SSH.Port):
+			add(CheckSSHPort, fmt.Sprintf("SSH port %d is in use by another process", mc.SSH.Port), "reassign the SSH port", func() error {
+				return reassignSSHPort(mc)
+			})
+		}
+	}
+
+	if !mp.UseProviderNetworkSetup() {
+		if pidFile, err := mc.RunningGVProxyPidFile(); err == nil {
+			pid, alive := processAlive(pidFile)
+			switch {
+			case state == machineDefine.Running && !alive:
+				add(CheckGvproxy, "gvproxy of the running machine is not running, its ports and API are not forwarded", "restart gvproxy", func() error {
+					return restartNetworking(mc, mp)
+				})
+			case state == machineDefine.Stopped && alive:
+				add(CheckGvproxy, fmt.Sprintf("gvproxy (pid %d) outlived the stopped machine", pid), "stop gvproxy", func() error {
+					return machine.CleanupGVProxy(*pidFile)
+				})
+			case !alive && fileExists(pidFile):
+				add(CheckPidFiles, fmt.Sprintf("the gvproxy pid file %s is stale", pidFile.GetPath()), "remove the pid file", pidFile.Delete)
+			}
+		}
+	}
+	if pidFile, err := mc.MonitorPidFile(); err == nil {
+		if _, alive := monitorAlive(pidFile); !alive && fileExists(pidFile) {
+			add(CheckPidFiles, fmt.Sprintf("the monitor pid file %s is stale", pidFile.GetPath()), "remove the pid file", pidFile.Delete)
+		}
+	}
+
+	if state == machineDefine.Stopped {
+		if readySocket, err := mc.ReadySocket(); err == nil && fileExists(readySocket) {
+			add(CheckReadySocket, fmt.Sprintf("the ready socket %s of the stopped machine was left behind", readySocket.GetPath()), "remove the socket", readySocket.Delete)
+		}
+	}
+	return findings
+}
+
+// checkConnections finds the machine connections of the host whose machine
+// does not exist
+func checkConnections(mcs map[string]*vmconfigs.MachineConfig) ([]*DoctorFinding, error) {
+	cons, err := ListConnections()
+	if err != nil {
+		return nil, err
+	}
+	var findings []*DoctorFinding
+	for _, name := range danglingConnections(cons, mcs) {
+		name := name
+		findings = append(findings, &DoctorFinding{
+			Check:   CheckConnections,
+			Problem: fmt.Sprintf("system connection %q points to a machine that does not exist", name),
+			Repair:  "remove the connection",
+			repair: func() error {
+				return connection.RemoveConnections(name)
+			},
+		})
+	}
+	return findings, nil
+}
+
+func fileExists(f *machineDefine.VMFile) bool {
+	_, err := os.Stat(f.GetPath())
+	return err == nil
+}
@@ -0,0 +1,118 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/connection"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func findingChecks(findings []*DoctorFinding) []string {
+	checks := make([]string, 0, len(findings))
+	for _, f := range findings {
+		checks = append(checks, f.Check)
+	}
+	return checks
+}
+
+func TestDoctor(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "doctor")
+	providers := []vmconfigs.VMProvider{p}
+
+	findings, err := Doctor(providers, DoctorOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+
+	// a start that crashed leaves the machine marked as starting, with its
+	// monitor pid file and ready socket behind
+	mc.Starting = true
+	require.NoError(t, mc.Write())
+	// Doctor finds the files in the runtime directory of the host
+	hostDirs, err := machine.GetMachineDirs(p.VMType())
+	require.NoError(t, err)
+	mc.SetDirs(hostDirs)
+	pidFile, err := mc.MonitorPidFile()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(pidFile.GetPath(), []byte("2147483646"), 0644))
+	readySocket, err := mc.ReadySocket()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(readySocket.GetPath(), nil, 0644))
+	require.NoError(t, connection.AddSSHConnectionsToPodmanSocket(1000, 2222, "/tmp/id", "doctor-ghost", "core", define.InitOptions{}))
+
+	findings, err = Doctor(providers, DoctorOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{CheckState, CheckPidFiles, CheckReadySocket, CheckConnections, CheckConnections}, findingChecks(findings))
+	for _, f := range findings {
+		assert.NotEmpty(t, f.Repair)
+		assert.False(t, f.Repaired)
+	}
+	assert.FileExists(t, pidFile.GetPath())
+
+	findings, err = Doctor(providers, DoctorOptions{Repair: true})
+	require.NoError(t, err)
+	require.Len(t, findings, 5)
+	for _, f := range findings {
+		assert.True(t, f.Repaired, f.Problem)
+		assert.Empty(t, f.RepairError)
+	}
+	assert.NoFileExists(t, pidFile.GetPath())
+	assert.NoFileExists(t, readySocket.GetPath())
+	assert.NotContains(t, connectionNames(t), "doctor-ghost")
+	reloaded, err := vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	assert.False(t, reloaded.Starting)
+
+	findings, err = Doctor(providers, DoctorOptions{Repair: true})
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+
+	// problems that cannot be repaired are only reported
+	require.NoError(t, os.Remove(mc.ImagePath.GetPath()))
+	findings, err = Doctor(providers, DoctorOptions{Names: []string{mc.Name}, Repair: true})
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Equal(t, CheckImage, findings[0].Check)
+	assert.False(t, findings[0].Repaired)
+
+	_, err = Doctor(providers, DoctorOptions{Names: []string{"doctor-missing"}})
+	assert.True(t, errors.Is(err, define.ErrNoSuchVM))
+}
+
+func TestDoctorSharedSSHPort(t *testing.T) {
+	origFind := findHelperBinary
+	defer func() { findHelperBinary = origFind }()
+	findHelperBinary = func(string) (string, error) {
+		return "", errors.New("not found")
+	}
+
+	p := fakeprovider.New(t)
+	p.ProviderNetworking = false
+	a, _ := initMachine(t, p, "doctor-a")
+	b, dirs := initMachine(t, p, "doctor-b")
+	b.SSH.Port = a.SSH.Port
+	require.NoError(t, b.Write())
+
+	findings, err := Doctor([]vmconfigs.VMProvider{p}, DoctorOptions{Repair: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{CheckSSHPort, CheckGvproxy}, findingChecks(findings))
+	assert.Equal(t, b.Name, findings[0].Machine)
+	assert.True(t, findings[0].Repaired)
+	// the host finding cannot be repaired
+	assert.Empty(t, findings[1].Machine)
+	assert.Contains(t, findings[1].Problem, machine.ForwarderBinaryName)
+	assert.False(t, findings[1].Repaired)
+
+	reloaded, err := vmconfigs.LoadMachineByName(b.Name, dirs)
+	require.NoError(t, err)
+	assert.NotEqual(t, a.SSH.Port, reloaded.SSH.Port)
+}
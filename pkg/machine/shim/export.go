@@ -0,0 +1,502 @@
+package shim
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/containers/storage/pkg/archive"
+)
+
+// A machine archive is a tar stream, compressed or not, of flat entries:
+// the manifest first, then the machine configuration and the SSH identity
+// the guest accepts, then the disk images.  Import checks the manifest
+// before it extracts gigabytes of disk.
+
+const (
+	exportVersion       = 1
+	manifestEntry       = "manifest.json"
+	configEntry         = "config.json"
+	identityEntry       = "identity"
+	identityPubEntry    = "identity.pub"
+	diskEntryPrefix     = "disk"
+	rollbackEntry       = "rollback"
+	snapshotEntryPrefix = "snapshot-"
+)
+
+// exportManifest describes the content of a machine archive
+type exportManifest struct {
+	Version int
+	// VMType is the provider of the exported machine, the disk images only
+	// work with it
+	VMType string
+	// Disk, Rollback and Snapshots are the entries of the disk images,
+	// Snapshots by snapshot name
+	Disk      string
+	Rollback  string            `json:",omitempty"`
+	Snapshots map[string]string `json:",omitempty"`
+}
+
+// entries returns the entries the manifest expects besides itself
+func (m *exportManifest) entries() map[string]bool {
+	entries := map[string]bool{
+		configEntry:      true,
+		identityEntry:    true,
+		identityPubEntry: true,
+		m.Disk:           true,
+	}
+	if m.Rollback != "" {
+		entries[m.Rollback] = true
+	}
+	for _, entry := range m.Snapshots {
+		entries[entry] = true
+	}
+	return entries
+}
+
+// Export writes an archive of the stopped machine to w, which Import turns
+// into the same machine on another host.  The archive holds the private
+// SSH key of the machine.  Snapshots the hypervisor keeps outside of the
+// disk image cannot be exported, QEMU snapshots live in the disk image and
+// are always exported.
+func Export(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, w io.Writer, opts machineDefine.ExportOptions) error {
+	if mp.VMType() == machineDefine.WSLVirt {
+		return fmt.Errorf("WSL machines cannot be exported: %w", machineDefine.ErrNotImplemented)
+	}
+	compression, err := exportCompression(opts.Compression)
+	if err != nil {
+		return err
+	}
+	if err := requireStopped(mc, mp, "be exported"); err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(mc.ImagePath.GetPath())
+	manifest := exportManifest{
+		Version: exportVersion,
+		VMType:  mp.VMType().String(),
+		Disk:    diskEntryPrefix + ext,
+	}
+	// every disk image but the current one is exported as a file
+	images := map[string]string{}
+	config, err := copyConfig(mc)
+	if err != nil {
+		return err
+	}
+	config.Snapshots = nil
+	for _, snapshot := range mc.Snapshots {
+		switch {
+		case snapshot.File == nil && mp.VMType() != machineDefine.QemuVirt:
+			return fmt.Errorf("machine %q has snapshot %q kept by %s, which cannot be exported", mc.Name, snapshot.Name, mp.VMType().String())
+		case snapshot.File != nil && opts.ExcludeCache:
+			continue
+		case snapshot.File != nil:
+			entry := snapshotEntryPrefix + snapshot.Name + ext
+			if manifest.Snapshots == nil {
+				manifest.Snapshots = map[string]string{}
+			}
+			manifest.Snapshots[snapshot.Name] = entry
+			images[entry] = snapshot.File.GetPath()
+		}
+		config.Snapshots = append(config.Snapshots, snapshot)
+	}
+	if !opts.ExcludeCache {
+		if rollback, err := mc.RollbackImagePath(); err == nil {
+			if _, err := os.Stat(rollback.GetPath()); err == nil {
+				manifest.Rollback = rollbackEntry + ext
+				images[manifest.Rollback] = rollback.GetPath()
+			}
+		}
+	}
+	if manifest.Rollback == "" {
+		config.PreviousImageDigest = ""
+	}
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", " ")
+	if err != nil {
+		return err
+	}
+	configJSON, err := json.MarshalIndent(config, "", " ")
+	if err != nil {
+		return err
+	}
+
+	mc.Logger().Debugf("exporting machine %q with %s compression", mc.Name, compression)
+	compressed, err := archive.CompressStream(w, compression)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(compressed)
+	if err := exportEntries(tw, manifestJSON, configJSON, mc, manifest, images); err != nil {
+		return fmt.Errorf("exporting machine %q: %w", mc.Name, err)
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
+
+// copyConfig returns a deep copy of the configuration of the machine
+func copyConfig(mc *vmconfigs.MachineConfig) (*vmconfigs.MachineConfig, error) {
+	b, err := json.Marshal(mc)
+	if err != nil {
+		return nil, err
+	}
+	config := new(vmconfigs.MachineConfig)
+	return config, json.Unmarshal(b, config)
+}
+
+func exportEntries(tw *tar.Writer, manifestJSON, configJSON []byte, mc *vmconfigs.MachineConfig, manifest exportManifest, images map[string]string) error {
+	if err := writeEntry(tw, manifestEntry, 0644, bytes.NewReader(manifestJSON), int64(len(manifestJSON))); err != nil {
+		return err
+	}
+	if err := writeEntry(tw, configEntry, 0644, bytes.NewReader(configJSON), int64(len(configJSON))); err != nil {
+		return err
+	}
+	if err := writeFileEntry(tw, identityEntry, mc.SSH.IdentityPath); err != nil {
+		return err
+	}
+	if err := writeFileEntry(tw, identityPubEntry, mc.SSH.IdentityPath+".pub"); err != nil {
+		return err
+	}
+	if err := writeFileEntry(tw, manifest.Disk, mc.ImagePath.GetPath()); err != nil {
+		return err
+	}
+	for entry, path := range images {
+		if err := writeFileEntry(tw, entry, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFileEntry(tw *tar.Writer, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return writeEntry(tw, name, info.Mode().Perm(), f, info.Size())
+}
+
+func writeEntry(tw *tar.Writer, name string, mode os.FileMode, r io.Reader, size int64) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(mode),
+		Size:     size,
+		ModTime:  time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tw, r); err != nil {
+		return fmt.Errorf("archiving %s: %w", name, err)
+	}
+	return nil
+}
+
+// exportCompression returns the compression of the archive
+func exportCompression(name string) (archive.Compression, error) {
+	switch name {
+	case "", machineDefine.CompressionZstd:
+		return archive.Zstd, nil
+	case machineDefine.CompressionGzip:
+		return archive.Gzip, nil
+	case machineDefine.CompressionNone:
+		return archive.Uncompressed, nil
+	default:
+		return archive.Uncompressed, fmt.Errorf("compression %q must be %s, %s or %s", name, machineDefine.CompressionNone, machineDefine.CompressionGzip, machineDefine.CompressionZstd)
+	}
+}
+
+// Import creates a machine from an archive written by Export, read from r,
+// compressed or not.  The machine is given the name of the exported machine
+// unless opts has one, and a new SSH port, ignition file and system
+// connections.  Every host specific path is set anew: the disk images go to
+// the data directory of the provider, the identity of the machine is kept
+// next to them unless it is the one of this host, and the volumes whose
+// source is missing on this host are dropped.  The hooks of the archive
+// are not imported, they are only printed.  The new machine configuration
+// is written before it is returned.
+func Import(r io.Reader, mp vmconfigs.VMProvider, opts machineDefine.ImportOptions) (_ *vmconfigs.MachineConfig, retErr error) {
+	if mp.VMType() == machineDefine.WSLVirt {
+		return nil, fmt.Errorf("WSL machines cannot be imported: %w", machineDefine.ErrNotImplemented)
+	}
+	logger := machineDefine.LoggerOrStandard(opts.Logger)
+	dirs, err := machine.GetMachineDirs(mp.VMType())
+	if err != nil {
+		return nil, err
+	}
+	// next to the disk image so that it is moved and not copied
+	tmpDir, err := os.MkdirTemp(dirs.DataDir.GetPath(), "import-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			logger.Warnf("could not remove %s: %v", tmpDir, err)
+		}
+	}()
+
+	manifest, src, err := extractArchive(r, tmpDir, mp.VMType())
+	if err != nil {
+		return nil, fmt.Errorf("reading machine archive: %w", err)
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = src.Name
+	}
+	_, exists, err := VMExists(name, []vmconfigs.VMProvider{mp})
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("%s: %w", name, machineDefine.ErrVMAlreadyExists)
+	}
+
+	initOpts := initOptionsFrom(src, mp, name)
+	initOpts.Logger = logger
+	initOpts.Volumes = importedVolumes(src.Mounts, logger)
+	for _, port := range src.Network.Ports {
+		initOpts.Ports = append(initOpts.Ports, port.String())
+	}
+	initOpts.RestartPolicy = string(src.RestartPolicy)
+
+	// the files put in place for the machine besides its disk image, which
+	// initialize removes on failure
+	var placed []string
+	defer func() {
+		if retErr == nil {
+			return
+		}
+		for _, path := range placed {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				logger.Warnf("could not remove %s: %v", path, err)
+			}
+		}
+	}()
+	place := func(entry, path string) error {
+		if err := os.Rename(filepath.Join(tmpDir, entry), path); err != nil {
+			return err
+		}
+		placed = append(placed, path)
+		return nil
+	}
+
+	logger.Debugf("importing %s machine %q as %q", manifest.VMType, src.Name, name)
+	importDisks := func(_ *machineDefine.MachineDirs, mc *vmconfigs.MachineConfig) error {
+		if err := os.Rename(filepath.Join(tmpDir, manifest.Disk), mc.ImagePath.GetPath()); err != nil {
+			return err
+		}
+		mc.ImageSource = src.ImageSource
+		mc.ImageDigest = src.ImageDigest
+
+		if manifest.Rollback != "" {
+			rollback, err := mc.RollbackImagePath()
+			if err != nil {
+				return err
+			}
+			if err := place(manifest.Rollback, rollback.GetPath()); err != nil {
+				return err
+			}
+			mc.PreviousImageDigest = src.PreviousImageDigest
+		}
+
+		image := mc.ImagePath.GetPath()
+		ext := filepath.Ext(image)
+		for _, snapshot := range src.Snapshots {
+			if !snapshotNameRegexp.MatchString(snapshot.Name) {
+				return fmt.Errorf("invalid snapshot name %q", snapshot.Name)
+			}
+			if snapshot.File != nil {
+				entry, ok := manifest.Snapshots[snapshot.Name]
+				if !ok {
+					return fmt.Errorf("the archive has no disk image for snapshot %q", snapshot.Name)
+				}
+				path := fmt.Sprintf("%s.snapshot-%s%s", strings.TrimSuffix(image, ext), snapshot.Name, ext)
+				if err := place(entry, path); err != nil {
+					return err
+				}
+				if snapshot.File, err = machineDefine.NewMachineFile(path, nil); err != nil {
+					return err
+				}
+			}
+			mc.Snapshots = append(mc.Snapshots, snapshot)
+		}
+		return nil
+	}
+	// The connections of the machine are registered with the identity the
+	// guest accepts
+	identity := func(mc *vmconfigs.MachineConfig) error {
+		return importIdentity(mc, tmpDir, place)
+	}
+	mc, _, err := initialize(initOpts, mp, identity, importDisks)
+	if err != nil {
+		return nil, fmt.Errorf("importing machine %q: %w", name, err)
+	}
+
+	// The hooks of an archive are commands from wherever it came from, run
+	// on this host or in the guest: they are not imported
+	for _, hook := range archivedHooks(src) {
+		logger.Warnf("Not importing hook %q, add it again to the machine if you trust it", hook)
+	}
+	if err := mc.Write(); err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
+// archivedHooks returns the commands of the host and guest hooks of src
+func archivedHooks(src *vmconfigs.MachineConfig) []string {
+	var commands []string
+	for _, hooks := range [][]vmconfigs.Hook{
+		src.HostHooks.PreStart, src.HostHooks.PostStart, src.HostHooks.PreStop, src.HostHooks.PostStop,
+		src.Hooks.PreStop, src.Hooks.PostStart, src.Hooks.FirstBoot,
+	} {
+		for _, hook := range hooks {
+			commands = append(commands, hook.Command)
+		}
+	}
+	return commands
+}
+
+// importIdentity keeps the identity of the archive for the machine, unless
+// it is the identity the machine was given, which is the one of this host
+func importIdentity(mc *vmconfigs.MachineConfig, tmpDir string, place func(entry, path string) error) error {
+	pub, err := os.ReadFile(filepath.Join(tmpDir, identityPubEntry))
+	if err != nil {
+		return err
+	}
+	hostPub, err := os.ReadFile(mc.SSH.IdentityPath + ".pub")
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(bytes.TrimSpace(pub), bytes.TrimSpace(hostPub)) {
+		return nil
+	}
+
+	identity, err := mc.MachineIdentityPath()
+	if err != nil {
+		return err
+	}
+	if err := place(identityEntry, identity.GetPath()); err != nil {
+		return err
+	}
+	if err := os.Chmod(identity.GetPath(), 0600); err != nil {
+		return err
+	}
+	if err := place(identityPubEntry, identity.GetPath()+".pub"); err != nil {
+		return err
+	}
+	mc.SSH.IdentityPath = identity.GetPath()
+	return nil
+}
+
+// importedVolumes returns the volumes of the exported machine whose source
+// exists on this host
+func importedVolumes(mounts []*vmconfigs.Mount, logger machineDefine.Logger) []string {
+	volumes := make([]string, 0, len(mounts))
+	for _, mount := range mounts {
+		if _, err := os.Stat(mount.Source); err != nil {
+			logger.Warnf("Dropping volume %s: %v", mount.Target, err)
+			continue
+		}
+		volume := mount.OriginalInput
+		if volume == "" {
+			volume = mount.Source + ":" + mount.Target
+			if mount.ReadOnly {
+				volume += ":ro"
+			}
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes
+}
+
+// extractArchive extracts the machine archive of a vmType machine read from
+// r to dir, and returns its manifest and the exported machine configuration
+func extractArchive(r io.Reader, dir string, vmType machineDefine.VMType) (*exportManifest, *vmconfigs.MachineConfig, error) {
+	decompressed, err := archive.DecompressStream(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer decompressed.Close()
+	tr := tar.NewReader(decompressed)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, nil, err
+	}
+	if hdr.Name != manifestEntry {
+		return nil, nil, fmt.Errorf("not a machine archive, its first entry is %q", hdr.Name)
+	}
+	manifest := new(exportManifest)
+	if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+		return nil, nil, fmt.Errorf("decoding %s: %w", manifestEntry, err)
+	}
+	if manifest.Version != exportVersion {
+		return nil, nil, fmt.Errorf("unsupported machine archive version %d", manifest.Version)
+	}
+	if manifest.VMType != vmType.String() {
+		return nil, nil, fmt.Errorf("the archive holds a %s machine, which cannot be imported as a %s machine", manifest.VMType, vmType.String())
+	}
+
+	expected := manifest.entries()
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if !expected[hdr.Name] || hdr.Name != filepath.Base(hdr.Name) || hdr.Typeflag != tar.TypeReg {
+			return nil, nil, fmt.Errorf("unexpected entry %q", hdr.Name)
+		}
+		delete(expected, hdr.Name)
+		if err := extractEntry(tr, filepath.Join(dir, hdr.Name), hdr.FileInfo().Mode().Perm()); err != nil {
+			return nil, nil, err
+		}
+	}
+	for entry := range expected {
+		return nil, nil, fmt.Errorf("missing entry %q", entry)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, configEntry))
+	if err != nil {
+		return nil, nil, err
+	}
+	src := new(vmconfigs.MachineConfig)
+	if err := json.Unmarshal(b, src); err != nil {
+		return nil, nil, fmt.Errorf("decoding %s: %w", configEntry, err)
+	}
+	return manifest, src, nil
+}
+
+func extractEntry(r io.Reader, path string, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("extracting %s: %w", filepath.Base(path), err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,150 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// importMachine imports the archive and removes the machine when the test
+// ends
+func importMachine(t *testing.T, p vmconfigs.VMProvider, archive []byte, name string) *vmconfigs.MachineConfig {
+	t.Helper()
+	mc, err := Import(bytes.NewReader(archive), p, define.ImportOptions{Name: name})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, rm, err := mc.Remove(false, false)
+		if err == nil {
+			_ = rm()
+		}
+	})
+	return mc
+}
+
+func TestExportImport(t *testing.T) {
+	p := fakeprovider.New(t)
+	src, dirs := initMachine(t, p, "export-src")
+	src.Resources.CPUs = 3
+	src.RestartPolicy = define.RestartNetwork
+	src.Hooks.PostStart = []vmconfigs.Hook{{Command: "true"}}
+	src.HostHooks.PreStart = []vmconfigs.Hook{{Command: "touch /tmp/imported"}}
+	require.NoError(t, os.WriteFile(src.ImagePath.GetPath(), []byte("disk"), 0644))
+	rollback, err := src.RollbackImagePath()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(rollback.GetPath(), []byte("rollback"), 0644))
+	snapshotFile, err := define.NewMachineFile(src.ImagePath.GetPath()+".snapshot-before", nil)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(snapshotFile.GetPath(), []byte("before"), 0644))
+	src.Snapshots = []vmconfigs.Snapshot{{Name: "before", Created: time.Now(), File: snapshotFile}}
+	require.NoError(t, src.Write())
+
+	p.SetState(src.Name, define.Running)
+	var archive bytes.Buffer
+	assert.ErrorIs(t, Export(src, p, &archive, define.ExportOptions{}), define.ErrWrongState)
+	p.SetState(src.Name, define.Stopped)
+
+	assert.Error(t, Export(src, p, &archive, define.ExportOptions{Compression: "bzip2"}))
+	require.NoError(t, Export(src, p, &archive, define.ExportOptions{Compression: define.CompressionGzip}))
+
+	_, err = Import(bytes.NewReader(archive.Bytes()), p, define.ImportOptions{})
+	assert.ErrorIs(t, err, define.ErrVMAlreadyExists)
+
+	mc := importMachine(t, p, archive.Bytes(), "export-dst")
+	assert.NotEqual(t, src.SSH.Port, mc.SSH.Port)
+	assert.Subset(t, connectionNames(t), []string{"export-dst", "export-dst-root"})
+	// the identity of this host is the one of the machine
+	assert.Equal(t, src.SSH.IdentityPath, mc.SSH.IdentityPath)
+
+	content, err := os.ReadFile(mc.ImagePath.GetPath())
+	require.NoError(t, err)
+	assert.Equal(t, "disk", string(content))
+	mcRollback, err := mc.RollbackImagePath()
+	require.NoError(t, err)
+	content, err = os.ReadFile(mcRollback.GetPath())
+	require.NoError(t, err)
+	assert.Equal(t, "rollback", string(content))
+	require.Len(t, mc.Snapshots, 1)
+	assert.NotEqual(t, snapshotFile.GetPath(), mc.Snapshots[0].File.GetPath())
+	content, err = os.ReadFile(mc.Snapshots[0].File.GetPath())
+	require.NoError(t, err)
+	assert.Equal(t, "before", string(content))
+
+	loaded, err := vmconfigs.LoadMachineByName("export-dst", dirs)
+	require.NoError(t, err)
+	assert.Equal(t, src.Resources.CPUs, loaded.Resources.CPUs)
+	assert.Equal(t, define.RestartNetwork, loaded.RestartPolicy)
+	// the hooks of an archive are not run on this host
+	assert.Equal(t, vmconfigs.Hooks{}, loaded.Hooks)
+	assert.Equal(t, vmconfigs.HostHooks{}, loaded.HostHooks)
+
+	// without the cache, only the disk image is exported
+	archive.Reset()
+	require.NoError(t, Export(src, p, &archive, define.ExportOptions{ExcludeCache: true}))
+	mc = importMachine(t, p, archive.Bytes(), "export-nocache")
+	assert.Empty(t, mc.Snapshots)
+	mcRollback, err = mc.RollbackImagePath()
+	require.NoError(t, err)
+	assert.NoFileExists(t, mcRollback.GetPath())
+}
+
+func TestImportIdentity(t *testing.T) {
+	p := fakeprovider.New(t)
+	src, _ := initMachine(t, p, "identity-src")
+	var archive bytes.Buffer
+	require.NoError(t, Export(src, p, &archive, define.ExportOptions{Compression: define.CompressionNone}))
+
+	// another host has another identity
+	require.NoError(t, os.WriteFile(src.SSH.IdentityPath+".pub", []byte("ssh-ed25519 BBBB other"), 0644))
+	mc := importMachine(t, p, archive.Bytes(), "identity-dst")
+	identity, err := mc.MachineIdentityPath()
+	require.NoError(t, err)
+	assert.Equal(t, identity.GetPath(), mc.SSH.IdentityPath)
+	content, err := os.ReadFile(mc.SSH.IdentityPath)
+	require.NoError(t, err)
+	assert.Equal(t, "private", string(content))
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(mc.SSH.IdentityPath)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	// the identity goes with the machine
+	_, rm, err := mc.Remove(false, false)
+	require.NoError(t, err)
+	require.NoError(t, rm())
+	assert.NoFileExists(t, identity.GetPath())
+	assert.NoFileExists(t, identity.GetPath()+".pub")
+}
+
+func TestImportInvalidArchive(t *testing.T) {
+	p := fakeprovider.New(t)
+	_, err := Import(bytes.NewReader([]byte("not an archive")), p, define.ImportOptions{Name: "invalid"})
+	assert.Error(t, err)
+}
+
+func TestImportOtherVMType(t *testing.T) {
+	p := fakeprovider.New(t)
+	manifest, err := json.Marshal(exportManifest{Version: exportVersion, VMType: define.AppleHvVirt.String(), Disk: diskEntryPrefix + ".raw"})
+	require.NoError(t, err)
+	// the manifest alone is enough to refuse the archive, the entries after
+	// it are never read
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	require.NoError(t, writeEntry(tw, manifestEntry, 0644, bytes.NewReader(manifest), int64(len(manifest))))
+	require.NoError(t, tw.Close())
+
+	_, err = Import(&archive, p, define.ImportOptions{Name: "other-vmtype"})
+	assert.ErrorContains(t, err, "cannot be imported as a qemu machine")
+}
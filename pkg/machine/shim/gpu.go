@@ -0,0 +1,32 @@
+package shim
+
+import (
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/ignition"
+)
+
+// gpuProvisioning returns the script and the unit describing the GPUs of
+// the guest to the containers run in it, as CDI specs generated on every
+// boot
+func gpuProvisioning() (ignition.File, ignition.Unit) {
+	script := ignition.File{
+		Node: ignition.Node{
+			Group: ignition.GetNodeGrp("root"),
+			Path:  machine.GPUCDIScriptPath,
+			User:  ignition.GetNodeUsr("root"),
+		},
+		FileEmbedded1: ignition.FileEmbedded1{
+			Contents: ignition.Resource{
+				Source: ignition.EncodeDataURLPtr(machine.GPUCDIScript),
+			},
+			Mode: ignition.IntToPtr(0755),
+		},
+	}
+	unitContents := machine.GPUCDIUnit
+	unit := ignition.Unit{
+		Enabled:  ignition.BoolToPtr(true),
+		Name:     machine.GPUCDIUnitName,
+		Contents: &unitContents,
+	}
+	return script, unit
+}
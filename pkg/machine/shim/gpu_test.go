@@ -0,0 +1,44 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitGPU(t *testing.T) {
+	writeIdentity(t)
+	p := fakeprovider.New(t)
+
+	mc, _, err := Init(define.InitOptions{Name: "gpu", Username: "core", GPUs: []string{"all"}}, p)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if _, rm, err := mc.Remove(false, false); err == nil {
+			_ = rm()
+		}
+	})
+	assert.Equal(t, &define.GPUConfig{All: true}, mc.Resources.GPU)
+
+	// the guest describes its GPUs to the containers
+	cfg := readIgnition(t, mc)
+	var unitNames, filePaths []string
+	for _, u := range cfg.Systemd.Units {
+		unitNames = append(unitNames, u.Name)
+	}
+	for _, f := range cfg.Storage.Files {
+		filePaths = append(filePaths, f.Path)
+	}
+	assert.Contains(t, unitNames, machine.GPUCDIUnitName)
+	assert.Contains(t, filePaths, machine.GPUCDIScriptPath)
+
+	p.Type = define.AppleHvVirt
+	_, _, err = Init(define.InitOptions{Name: "gpu-applehv", Username: "core", GPUs: []string{"all"}}, p)
+	assert.True(t, errors.Is(err, define.ErrNotImplemented))
+}
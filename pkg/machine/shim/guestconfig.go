@@ -0,0 +1,203 @@
+package shim
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/containers/image/v5/signature"
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/ignition"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+const (
+	// guestContainersConf and guestRegistriesConf are named to be read
+	// after the fragments of the image and the ones podman writes, e.g.
+	// 999-podman-machine.conf on WSL
+	guestContainersConf = "/etc/containers/containers.conf.d/9999-podman-machine-host.conf"
+	guestRegistriesConf = "/etc/containers/registries.conf.d/9999-podman-machine-host.conf"
+	guestPolicy         = "/etc/containers/policy.json"
+	// guestPolicyBackup keeps the policy of the guest replaced after its
+	// first boot, it is restored when the policy is removed
+	guestPolicyBackup = guestPolicy + ".podman-machine-orig"
+
+	// guestConfigTimeout is how long copying the files into the guest may
+	// take
+	guestConfigTimeout = 30 * time.Second
+)
+
+// guestConfigFile is a host file of a GuestConfig and where it goes in the
+// guest
+type guestConfigFile struct {
+	hostPath  string
+	guestPath string
+}
+
+// guestConfigFiles returns the files of gc, including the ones not given
+func guestConfigFiles(gc vmconfigs.GuestConfig) []guestConfigFile {
+	return []guestConfigFile{
+		{hostPath: gc.ContainersConf, guestPath: guestContainersConf},
+		{hostPath: gc.RegistriesConf, guestPath: guestRegistriesConf},
+		{hostPath: gc.SignaturePolicy, guestPath: guestPolicy},
+	}
+}
+
+// readGuestConfigFile reads the host file that goes to guestPath and checks
+// that the guest can parse it
+func readGuestConfigFile(hostPath, guestPath string) ([]byte, error) {
+	content, err := os.ReadFile(hostPath)
+	if err != nil {
+		return nil, err
+	}
+	if guestPath == guestPolicy {
+		if _, err := signature.NewPolicyFromBytes(content); err != nil {
+			return nil, fmt.Errorf("parsing signature policy %s: %w", hostPath, err)
+		}
+		return content, nil
+	}
+	var parsed map[string]interface{}
+	if _, err := toml.Decode(string(content), &parsed); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", hostPath, err)
+	}
+	return content, nil
+}
+
+// updateGuestConfig applies opts to gc after checking the given files, and
+// reports whether gc changed.  Giving a file again counts as a change so
+// that its new content is copied.
+func updateGuestConfig(gc *vmconfigs.GuestConfig, opts machineDefine.GuestConfigOptions) (bool, error) {
+	updated := *gc
+	changed := false
+	for _, f := range []struct {
+		opt       *string
+		field     *string
+		guestPath string
+	}{
+		{opts.ContainersConf, &updated.ContainersConf, guestContainersConf},
+		{opts.RegistriesConf, &updated.RegistriesConf, guestRegistriesConf},
+		{opts.SignaturePolicy, &updated.SignaturePolicy, guestPolicy},
+	} {
+		if f.opt == nil {
+			continue
+		}
+		hostPath := *f.opt
+		if hostPath != "" {
+			abs, err := filepath.Abs(hostPath)
+			if err != nil {
+				return false, err
+			}
+			if _, err := readGuestConfigFile(abs, f.guestPath); err != nil {
+				return false, err
+			}
+			hostPath = abs
+		} else if *f.field == "" {
+			continue
+		}
+		*f.field = hostPath
+		changed = true
+	}
+	if changed {
+		updated.Pending = true
+		*gc = updated
+	}
+	return changed, nil
+}
+
+// guestConfigIgnitionFiles returns the files of gc for the ignition config
+// of a new machine
+func guestConfigIgnitionFiles(gc vmconfigs.GuestConfig) ([]ignition.File, error) {
+	var files []ignition.File
+	for _, f := range guestConfigFiles(gc) {
+		if f.hostPath == "" {
+			continue
+		}
+		content, err := readGuestConfigFile(f.hostPath, f.guestPath)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, ignition.File{
+			Node: ignition.Node{
+				Group:     ignition.GetNodeGrp("root"),
+				Path:      f.guestPath,
+				User:      ignition.GetNodeUsr("root"),
+				Overwrite: ignition.BoolToPtr(true),
+			},
+			FileEmbedded1: ignition.FileEmbedded1{
+				Contents: ignition.Resource{
+					Source: ignition.EncodeDataURLPtr(string(content)),
+				},
+				Mode: ignition.IntToPtr(0644),
+			},
+		})
+	}
+	return files, nil
+}
+
+// guestConfigCommand returns the command that makes the files of the guest
+// match gc: the given files are written and the others are removed.  The
+// policy of the guest is backed up before it is first replaced and restored
+// when it is removed.
+func guestConfigCommand(gc vmconfigs.GuestConfig) (string, error) {
+	steps := []string{"set -e"}
+	for _, f := range guestConfigFiles(gc) {
+		if f.hostPath == "" {
+			if f.guestPath == guestPolicy {
+				steps = append(steps, fmt.Sprintf("if [ -e %[1]s ]; then mv -f %[1]s %[2]s; fi", guestPolicyBackup, guestPolicy))
+			} else {
+				steps = append(steps, "rm -f "+f.guestPath)
+			}
+			continue
+		}
+		content, err := readGuestConfigFile(f.hostPath, f.guestPath)
+		if err != nil {
+			return "", err
+		}
+		if f.guestPath == guestPolicy {
+			steps = append(steps, fmt.Sprintf("if [ -e %[1]s ] && [ ! -e %[2]s ]; then cp -p %[1]s %[2]s; fi", guestPolicy, guestPolicyBackup))
+		}
+		steps = append(steps,
+			"mkdir -p "+path.Dir(f.guestPath),
+			fmt.Sprintf("echo %s | base64 -d > %s.tmp", base64.StdEncoding.EncodeToString(content), f.guestPath),
+			fmt.Sprintf("chmod 0644 %[1]s.tmp && mv -f %[1]s.tmp %[1]s", f.guestPath))
+	}
+	return "sudo sh -c " + ignition.ShellQuote(strings.Join(steps, "; ")), nil
+}
+
+// copyGuestConfig copies the files of the guest config into the running
+// machine and clears their pending state
+func copyGuestConfig(mc *vmconfigs.MachineConfig) error {
+	command, err := guestConfigCommand(mc.GuestConfig)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), guestConfigTimeout)
+	defer cancel()
+	if out, err := guestExec(ctx, mc, command); err != nil {
+		return fmt.Errorf("copying the configuration files into machine %q: %w: %s", mc.Name, err, out)
+	}
+	mc.GuestConfig.Pending = false
+	return nil
+}
+
+// applyPendingGuestConfig copies the guest config into the machine that just
+// started when it changed since it was last copied.  Failures are logged and
+// the copy is tried again on the next start.
+func applyPendingGuestConfig(mc *vmconfigs.MachineConfig) {
+	if !mc.GuestConfig.Pending {
+		return
+	}
+	if err := copyGuestConfig(mc); err != nil {
+		mc.Logger().Warnf("%v, it is retried on the next start", err)
+		return
+	}
+	if err := mc.Write(); err != nil {
+		mc.Logger().Errorf("%v", err)
+	}
+}
@@ -0,0 +1,124 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `{"default": [{"type": "reject"}], "transports": {"docker": {"registry.example.com": [{"type": "insecureAcceptAnything"}]}}}`
+
+func writeGuestConfigFiles(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	registries := filepath.Join(dir, "mirrors.conf")
+	require.NoError(t, os.WriteFile(registries, []byte("[[registry]]\nlocation = \"docker.io\"\n[[registry.mirror]]\nlocation = \"mirror.example.com\"\n"), 0o644))
+	policy := filepath.Join(dir, "policy.json")
+	require.NoError(t, os.WriteFile(policy, []byte(testPolicy), 0o644))
+	return registries, policy
+}
+
+func TestUpdateGuestConfig(t *testing.T) {
+	registries, policy := writeGuestConfigFiles(t)
+
+	var gc vmconfigs.GuestConfig
+	changed, err := updateGuestConfig(&gc, define.GuestConfigOptions{})
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	changed, err = updateGuestConfig(&gc, define.GuestConfigOptions{RegistriesConf: &registries, SignaturePolicy: &policy})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, vmconfigs.GuestConfig{RegistriesConf: registries, SignaturePolicy: policy, Pending: true}, gc)
+
+	files, err := guestConfigIgnitionFiles(gc)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, guestRegistriesConf, files[0].Path)
+	assert.Equal(t, guestPolicy, files[1].Path)
+
+	// removing a file that was never given changes nothing
+	gc.Pending = false
+	empty := ""
+	changed, err = updateGuestConfig(&gc, define.GuestConfigOptions{ContainersConf: &empty})
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	changed, err = updateGuestConfig(&gc, define.GuestConfigOptions{SignaturePolicy: &empty})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, vmconfigs.GuestConfig{RegistriesConf: registries, Pending: true}, gc)
+
+	// files the guest cannot parse are refused and gc is left alone
+	bad := filepath.Join(t.TempDir(), "bad.conf")
+	require.NoError(t, os.WriteFile(bad, []byte("[engine\n"), 0o644))
+	_, err = updateGuestConfig(&gc, define.GuestConfigOptions{ContainersConf: &bad})
+	assert.Error(t, err)
+	_, err = updateGuestConfig(&gc, define.GuestConfigOptions{SignaturePolicy: &registries})
+	assert.Error(t, err)
+	missing := filepath.Join(t.TempDir(), "missing.conf")
+	_, err = updateGuestConfig(&gc, define.GuestConfigOptions{RegistriesConf: &missing})
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.Equal(t, vmconfigs.GuestConfig{RegistriesConf: registries, Pending: true}, gc)
+}
+
+func TestGuestConfigCommand(t *testing.T) {
+	registries, _ := writeGuestConfigFiles(t)
+	content, err := os.ReadFile(registries)
+	require.NoError(t, err)
+
+	command, err := guestConfigCommand(vmconfigs.GuestConfig{RegistriesConf: registries})
+	require.NoError(t, err)
+	assert.Contains(t, command, base64.StdEncoding.EncodeToString(content))
+	assert.Contains(t, command, "mv -f "+guestRegistriesConf+".tmp "+guestRegistriesConf)
+	assert.Contains(t, command, "rm -f "+guestContainersConf)
+	assert.Contains(t, command, "mv -f "+guestPolicyBackup+" "+guestPolicy)
+}
+
+func TestSetGuestConfig(t *testing.T) {
+	registries, _ := writeGuestConfigFiles(t)
+	ran := fakeGuest(t, nil)
+	p, mc, dirs := runningMachine(t, "guest-config")
+
+	// a stopped machine gets the files on its next start
+	p.SetState(mc.Name, define.Stopped)
+	require.NoError(t, Set(mc, p, define.SetOptions{GuestConfig: define.GuestConfigOptions{RegistriesConf: &registries}}))
+	assert.Empty(t, *ran)
+	reloaded, err := vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	assert.Equal(t, registries, reloaded.GuestConfig.RegistriesConf)
+	assert.True(t, reloaded.GuestConfig.Pending)
+
+	applyPendingGuestConfig(mc)
+	assert.Len(t, *ran, 1)
+	assert.False(t, mc.GuestConfig.Pending)
+	applyPendingGuestConfig(mc)
+	assert.Len(t, *ran, 1)
+
+	// a running machine gets them right away
+	p.SetState(mc.Name, define.Running)
+	empty := ""
+	require.NoError(t, Set(mc, p, define.SetOptions{GuestConfig: define.GuestConfigOptions{RegistriesConf: &empty}}))
+	assert.Len(t, *ran, 2)
+	assert.Contains(t, (*ran)[1], "rm -f "+guestRegistriesConf)
+	assert.False(t, mc.GuestConfig.Pending)
+
+	// a failed copy is retried on the next start
+	guestExec = func(context.Context, *vmconfigs.MachineConfig, string) ([]byte, error) {
+		return nil, errors.New("ssh failed")
+	}
+	require.NoError(t, Set(mc, p, define.SetOptions{GuestConfig: define.GuestConfigOptions{RegistriesConf: &registries}}))
+	reloaded, err = vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	assert.True(t, reloaded.GuestConfig.Pending)
+}
@@ -0,0 +1,182 @@
+package shim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+const (
+	// defaultHookTimeout is how long a hook without its own timeout may run
+	defaultHookTimeout = 30 * time.Second
+	// maxHooksDuration caps how long all hooks of one stage may run together
+	maxHooksDuration = 2 * time.Minute
+)
+
+// These are variables so that tests can fake the guest and the host
+var (
+	// guestExec runs command in the guest and returns its combined output
+	guestExec = func(ctx context.Context, mc *vmconfigs.MachineConfig, command string) ([]byte, error) {
+		return machine.CommonSSHWithOutput(ctx, mc.SSH.RemoteUsername, mc.SSH.IdentityPath, mc.SSH.Port, []string{command})
+	}
+	// guestReachable reports whether commands can be run in the guest
+	guestReachable = func(mc *vmconfigs.MachineConfig) bool {
+		return isListening(mc.SSH.Port, mc.Logger())
+	}
+	// hostExec runs command of a host hook of stage with the shell of the
+	// host and returns its combined output
+	hostExec = func(ctx context.Context, mc *vmconfigs.MachineConfig, stage, command string) ([]byte, error) {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/c", command)
+		} else {
+			cmd = exec.CommandContext(ctx, "sh", "-c", command)
+		}
+		cmd.Env = append(os.Environ(), hostHookEnv(mc, stage)...)
+		return cmd.CombinedOutput()
+	}
+)
+
+// hostHookEnv describes the machine to the host hooks
+func hostHookEnv(mc *vmconfigs.MachineConfig, stage string) []string {
+	return []string{
+		"PODMAN_MACHINE_NAME=" + mc.Name,
+		"PODMAN_MACHINE_HOOK=" + stage,
+		"PODMAN_MACHINE_SSH_PORT=" + strconv.Itoa(mc.SSH.Port),
+		"PODMAN_MACHINE_SSH_USER=" + mc.SSH.RemoteUsername,
+		"PODMAN_MACHINE_SSH_IDENTITY=" + mc.SSH.IdentityPath,
+	}
+}
+
+// runHooks runs the given guest hooks of stage in order and appends their
+// output to the log file of the machine.  Failing hooks are logged and
+// returned; only a failing required hook aborts the remaining hooks and is
+// returned as error.  With once set, hooks that succeed are marked as
+// completed and completed hooks are skipped.
+func runHooks(mc *vmconfigs.MachineConfig, stage string, hooks []vmconfigs.Hook, once bool) ([]error, error) {
+	run := func(ctx context.Context, command string) ([]byte, error) {
+		return guestExec(ctx, mc, command)
+	}
+	return runHookCommands(mc, stage, hooks, once, run)
+}
+
+// runHostHooks runs the given host hooks of stage like runHooks does
+func runHostHooks(mc *vmconfigs.MachineConfig, stage string, hooks []vmconfigs.Hook) ([]error, error) {
+	run := func(ctx context.Context, command string) ([]byte, error) {
+		return hostExec(ctx, mc, stage, command)
+	}
+	return runHookCommands(mc, "host "+stage, hooks, false, run)
+}
+
+func runHookCommands(mc *vmconfigs.MachineConfig, stage string, hooks []vmconfigs.Hook, once bool, run func(context.Context, string) ([]byte, error)) ([]error, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), maxHooksDuration)
+	defer cancel()
+
+	hookLog := openHookLog(mc)
+	if hookLog != nil {
+		defer hookLog.Close()
+	}
+
+	var failures []error
+	for i := range hooks {
+		hook := &hooks[i]
+		if once && hook.Completed {
+			continue
+		}
+		timeout := hook.Timeout
+		if timeout <= 0 {
+			timeout = defaultHookTimeout
+		}
+		hookCtx, hookCancel := context.WithTimeout(ctx, timeout)
+		out, err := run(hookCtx, hook.Command)
+		if err == nil && hookCtx.Err() != nil {
+			err = hookCtx.Err()
+		}
+		hookCancel()
+
+		mc.Logger().Debugf("Machine %q %s hook %q output: %s", mc.Name, stage, hook.Command, strings.TrimSpace(string(out)))
+		if hookLog != nil {
+			fmt.Fprintf(hookLog, "%s %s hook %q (error: %v):\n%s\n", time.Now().Format(time.RFC3339), stage, hook.Command, err, out)
+		}
+		if err == nil {
+			hook.Completed = once
+			continue
+		}
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out: %w", err)
+		}
+		err = fmt.Errorf("%s hook %q of machine %q failed: %w", stage, hook.Command, mc.Name, err)
+		if hook.Required {
+			return failures, err
+		}
+		mc.Logger().Warnf("%v", err)
+		failures = append(failures, err)
+	}
+	return failures, nil
+}
+
+// openHookLog opens the log file of the machine for appending.  Hooks still
+// run when it cannot be opened, their output is then only logged at debug
+// level.
+func openHookLog(mc *vmconfigs.MachineConfig) *os.File {
+	logFile, err := mc.LogFile()
+	if err != nil {
+		mc.Logger().Debugf("Unable to get log file of machine %q: %v", mc.Name, err)
+		return nil
+	}
+	f, err := os.OpenFile(logFile.GetPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		mc.Logger().Debugf("Unable to open log file of machine %q: %v", mc.Name, err)
+		return nil
+	}
+	return f
+}
+
+// needsStartHooks reports whether runStartHooks has anything to do
+func needsStartHooks(mc *vmconfigs.MachineConfig) bool {
+	for _, hook := range mc.Hooks.FirstBoot {
+		if !hook.Completed {
+			return true
+		}
+	}
+	return len(mc.Hooks.PostStart) > 0 || len(mc.HostHooks.PostStart) > 0 || mc.Degraded != ""
+}
+
+// runStartHooks runs the first boot hooks that have not completed yet followed
+// by the post-start hooks of the guest and of the host.  Failing hooks do not
+// fail the start, instead the machine is marked as degraded until its next
+// start.
+func runStartHooks(mc *vmconfigs.MachineConfig) {
+	// Failed first boot hooks are run again on the next start
+	failures, err := runHooks(mc, "first-boot", mc.Hooks.FirstBoot, true)
+	if err == nil {
+		var postStartFailures []error
+		postStartFailures, err = runHooks(mc, "post-start", mc.Hooks.PostStart, false)
+		failures = append(failures, postStartFailures...)
+	}
+	if err == nil {
+		var hostFailures []error
+		hostFailures, err = runHostHooks(mc, "post-start", mc.HostHooks.PostStart)
+		failures = append(failures, hostFailures...)
+	}
+	if err != nil {
+		failures = append(failures, err)
+	}
+
+	mc.Degraded = ""
+	if len(failures) > 0 {
+		mc.Degraded = errors.Join(failures...).Error()
+	}
+	if err := mc.Write(); err != nil {
+		mc.Logger().Errorf("%v", err)
+	}
+}
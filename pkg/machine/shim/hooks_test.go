@@ -0,0 +1,283 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGuest replaces ssh for the duration of the test.  Commands named in
+// failures fail, "sleep" blocks until its context is done and everything
+// else succeeds.
+func fakeGuest(t *testing.T, failures map[string]error) *[]string {
+	t.Helper()
+	var (
+		lock sync.Mutex
+		ran  []string
+	)
+	origExec, origReachable := guestExec, guestReachable
+	t.Cleanup(func() {
+		guestExec, guestReachable = origExec, origReachable
+	})
+	guestReachable = func(*vmconfigs.MachineConfig) bool { return true }
+	guestExec = func(ctx context.Context, _ *vmconfigs.MachineConfig, command string) ([]byte, error) {
+		lock.Lock()
+		ran = append(ran, command)
+		lock.Unlock()
+		if command == "sleep" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return []byte("output of " + command), failures[command]
+	}
+	return &ran
+}
+
+func runningMachine(t *testing.T, name string, hooks ...vmconfigs.Hook) (*fakeprovider.Provider, *vmconfigs.MachineConfig, *define.MachineDirs) {
+	t.Helper()
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, name)
+	mc.Hooks.PreStop = hooks
+	p.SetState(mc.Name, define.Running)
+	return p, mc, dirs
+}
+
+func TestPreStopHooks(t *testing.T) {
+	ran := fakeGuest(t, map[string]error{"flaky": errors.New("exit status 1")})
+	p, mc, dirs := runningMachine(t, "hooks-ok",
+		vmconfigs.Hook{Command: "sync"},
+		vmconfigs.Hook{Command: "flaky"},
+		vmconfigs.Hook{Command: "checkpoint", Required: true},
+	)
+
+	_, err := Stop(mc, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sync", "flaky", "checkpoint"}, *ran)
+	assert.Equal(t, 1, p.Called("StopVM"))
+}
+
+func TestPreStopHookTimeout(t *testing.T) {
+	ran := fakeGuest(t, nil)
+	p, mc, dirs := runningMachine(t, "hooks-timeout",
+		vmconfigs.Hook{Command: "sleep", Timeout: 10 * time.Millisecond},
+		vmconfigs.Hook{Command: "sync"},
+	)
+
+	_, err := Stop(mc, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sleep", "sync"}, *ran)
+	assert.Equal(t, 1, p.Called("StopVM"))
+
+	// a required hook that times out aborts the stop
+	p.SetState(mc.Name, define.Running)
+	mc.Hooks.PreStop[0].Required = true
+	_, err = Stop(mc, p, dirs, machine.StopOptions{})
+	require.ErrorContains(t, err, "timed out")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, p.Called("StopVM"))
+}
+
+func TestRequiredPreStopHookFailure(t *testing.T) {
+	ran := fakeGuest(t, map[string]error{"checkpoint": errors.New("exit status 3")})
+	p, mc, dirs := runningMachine(t, "hooks-required",
+		vmconfigs.Hook{Command: "checkpoint", Required: true},
+		vmconfigs.Hook{Command: "sync"},
+	)
+
+	_, err := Stop(mc, p, dirs, machine.StopOptions{})
+	require.ErrorContains(t, err, `pre-stop hook "checkpoint" of machine "hooks-required" failed: exit status 3`)
+	assert.Equal(t, []string{"checkpoint"}, *ran)
+	assert.Zero(t, p.Called("StopVM"))
+	require.NotNil(t, mc.LastError)
+	assert.Equal(t, vmconfigs.OperationStop, mc.LastError.Operation)
+}
+
+func TestPreStopHooksSkipped(t *testing.T) {
+	ran := fakeGuest(t, nil)
+	p, mc, dirs := runningMachine(t, "hooks-skipped", vmconfigs.Hook{Command: "sync"})
+
+	_, err := Stop(mc, p, dirs, machine.StopOptions{NoHooks: true})
+	require.NoError(t, err)
+	p.SetState(mc.Name, define.Running)
+	_, err = Stop(mc, p, dirs, machine.StopOptions{Hard: true})
+	require.NoError(t, err)
+	p.SetState(mc.Name, define.Running)
+	guestReachable = func(*vmconfigs.MachineConfig) bool { return false }
+	_, err = Stop(mc, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+
+	assert.Empty(t, *ran)
+	assert.Equal(t, 3, p.Called("StopVM"))
+}
+
+func TestStartHooks(t *testing.T) {
+	ran := fakeGuest(t, nil)
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "start-hooks")
+	mc.Hooks.FirstBoot = []vmconfigs.Hook{{Command: "install"}, {Command: "login"}}
+	mc.Hooks.PostStart = []vmconfigs.Hook{{Command: "trust-mirror"}}
+
+	_, err := Start(mc, p, dirs, machine.StartOptions{NoInfo: true, Quiet: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"install", "login", "trust-mirror"}, *ran)
+	assert.Empty(t, mc.Degraded)
+
+	// first boot hooks are not run again, also not after a reload
+	reloaded, err := vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	_, err = Stop(reloaded, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+	*ran = nil
+	_, err = Start(reloaded, p, dirs, machine.StartOptions{NoInfo: true, Quiet: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"trust-mirror"}, *ran)
+
+	// the output ends up in the log file of the machine
+	logFile, err := mc.LogFile()
+	require.NoError(t, err)
+	content, err := os.ReadFile(logFile.GetPath())
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `first-boot hook "install"`)
+	assert.Contains(t, string(content), "output of trust-mirror")
+}
+
+func TestStartHooksFailure(t *testing.T) {
+	failures := map[string]error{"login": errors.New("exit status 1")}
+	ran := fakeGuest(t, failures)
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "start-hooks-fail")
+	mc.Hooks.FirstBoot = []vmconfigs.Hook{{Command: "install"}, {Command: "login"}}
+	mc.Hooks.PostStart = []vmconfigs.Hook{{Command: "check", Required: true}, {Command: "trust-mirror"}}
+	failures["check"] = errors.New("exit status 2")
+
+	// failing hooks degrade the machine but it keeps running
+	_, err := Start(mc, p, dirs, machine.StartOptions{NoInfo: true, Quiet: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"install", "login", "check"}, *ran)
+	assert.Contains(t, mc.Degraded, `first-boot hook "login" of machine "start-hooks-fail" failed: exit status 1`)
+	assert.Contains(t, mc.Degraded, `post-start hook "check" of machine "start-hooks-fail" failed: exit status 2`)
+	assert.Zero(t, p.Called("StopVM"))
+	assert.Nil(t, mc.LastError)
+	reloaded, err := vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	assert.Equal(t, mc.Degraded, reloaded.Degraded)
+
+	// only the failed first boot hook is run again and a clean start clears
+	// the degraded state
+	delete(failures, "login")
+	delete(failures, "check")
+	_, err = Stop(mc, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+	*ran = nil
+	_, err = Start(mc, p, dirs, machine.StartOptions{NoInfo: true, Quiet: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"login", "check", "trust-mirror"}, *ran)
+	assert.Empty(t, mc.Degraded)
+}
+
+// fakeHost replaces the shell of the host for the duration of the test and
+// records the hooks run as "stage command".  Commands named in failures fail.
+func fakeHost(t *testing.T, failures map[string]error) *[]string {
+	t.Helper()
+	var ran []string
+	origExec := hostExec
+	t.Cleanup(func() {
+		hostExec = origExec
+	})
+	hostExec = func(_ context.Context, _ *vmconfigs.MachineConfig, stage, command string) ([]byte, error) {
+		ran = append(ran, stage+" "+command)
+		return nil, failures[command]
+	}
+	return &ran
+}
+
+func TestHostHooks(t *testing.T) {
+	guestRan := fakeGuest(t, nil)
+	hostRan := fakeHost(t, map[string]error{"notify": errors.New("exit status 1")})
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "host-hooks")
+	mc.Hooks.PostStart = []vmconfigs.Hook{{Command: "trust-mirror"}}
+	mc.HostHooks = vmconfigs.HostHooks{
+		PreStart:  []vmconfigs.Hook{{Command: "vpn-up", Required: true}},
+		PostStart: []vmconfigs.Hook{{Command: "mount-share"}, {Command: "notify"}},
+		PreStop:   []vmconfigs.Hook{{Command: "umount-share"}},
+		PostStop:  []vmconfigs.Hook{{Command: "vpn-down"}},
+	}
+
+	_, err := Start(mc, p, dirs, machine.StartOptions{NoInfo: true, Quiet: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"trust-mirror"}, *guestRan)
+	assert.Equal(t, []string{"pre-start vpn-up", "post-start mount-share", "post-start notify"}, *hostRan)
+	// a failing post-start host hook degrades the machine
+	assert.Contains(t, mc.Degraded, `host post-start hook "notify" of machine "host-hooks" failed: exit status 1`)
+
+	*hostRan = nil
+	_, err = Stop(mc, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pre-stop umount-share", "post-stop vpn-down"}, *hostRan)
+
+	// host hooks run for hard stops but not with NoHooks
+	*hostRan = nil
+	p.SetState(mc.Name, define.Running)
+	_, err = Stop(mc, p, dirs, machine.StopOptions{Hard: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pre-stop umount-share", "post-stop vpn-down"}, *hostRan)
+	*hostRan = nil
+	p.SetState(mc.Name, define.Running)
+	_, err = Stop(mc, p, dirs, machine.StopOptions{NoHooks: true})
+	require.NoError(t, err)
+	assert.Empty(t, *hostRan)
+}
+
+func TestRequiredHostHookFailure(t *testing.T) {
+	fakeGuest(t, nil)
+	failures := map[string]error{"vpn-up": errors.New("exit status 2")}
+	hostRan := fakeHost(t, failures)
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "host-hooks-required")
+	mc.HostHooks.PreStart = []vmconfigs.Hook{{Command: "vpn-up", Required: true}, {Command: "mount-share"}}
+
+	_, err := Start(mc, p, dirs, machine.StartOptions{NoInfo: true, Quiet: true})
+	require.ErrorContains(t, err, `host pre-start hook "vpn-up" of machine "host-hooks-required" failed: exit status 2`)
+	assert.Equal(t, []string{"pre-start vpn-up"}, *hostRan)
+	assert.Zero(t, p.Called("StartVM"))
+
+	// a required post-stop hook fails the stop of a machine that is stopped
+	delete(failures, "vpn-up")
+	failures["vpn-down"] = errors.New("exit status 3")
+	mc.HostHooks.PostStop = []vmconfigs.Hook{{Command: "vpn-down", Required: true}}
+	_, err = Start(mc, p, dirs, machine.StartOptions{NoInfo: true, Quiet: true})
+	require.NoError(t, err)
+	_, err = Stop(mc, p, dirs, machine.StopOptions{})
+	require.ErrorContains(t, err, `machine "host-hooks-required" is stopped: host post-stop hook "vpn-down"`)
+	assert.Equal(t, 1, p.Called("StopVM"))
+}
+
+func TestHostExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook uses the POSIX shell")
+	}
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "host-exec")
+
+	out, err := hostExec(context.Background(), mc, "pre-start", "echo $PODMAN_MACHINE_NAME $PODMAN_MACHINE_HOOK")
+	require.NoError(t, err)
+	assert.Equal(t, "host-exec pre-start", strings.TrimSpace(string(out)))
+
+	_, err = hostExec(context.Background(), mc, "pre-start", "exit 4")
+	assert.ErrorContains(t, err, "exit status 4")
+}
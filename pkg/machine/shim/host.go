@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
+	"strings"
 	"time"
 
 	"github.com/containers/podman/v5/pkg/machine"
@@ -13,14 +13,15 @@ import (
 	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
 	"github.com/containers/podman/v5/pkg/machine/ignition"
 	"github.com/containers/podman/v5/pkg/machine/proxyenv"
+	"github.com/containers/podman/v5/pkg/machine/sockets"
 	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
 	"github.com/containers/podman/v5/utils"
 	"github.com/hashicorp/go-multierror"
-	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 )
 
-// List is done at the host level to allow for a *possible* future where
-// more than one provider is used
+// List returns the machines of the given providers, in the order of the
+// providers
 func List(vmstubbers []vmconfigs.VMProvider, _ machine.ListOptions) ([]*machine.ListResponse, error) {
 	var (
 		lrs []*machine.ListResponse
@@ -46,6 +47,7 @@ func List(vmstubbers []vmconfigs.VMProvider, _ machine.ListOptions) ([]*machine.
 				LastUp:    mc.LastUp,
 				Running:   state == machineDefine.Running,
 				Starting:  mc.Starting,
+				State:     state,
 				//Stream:             "", // No longer applicable
 				VMType:             s.VMType().String(),
 				CPUs:               mc.Resources.CPUs,
@@ -55,6 +57,11 @@ func List(vmstubbers []vmconfigs.VMProvider, _ machine.ListOptions) ([]*machine.
 				RemoteUsername:     mc.SSH.RemoteUsername,
 				IdentityPath:       mc.SSH.IdentityPath,
 				UserModeNetworking: s.UserModeNetworkEnabled(mc),
+				LastError:          mc.LastError,
+				Health:             health(mc, s, state),
+			}
+			if configFile := mc.ConfigFile(); configFile != nil {
+				lr.ConfigPath = configFile.GetPath()
 			}
 			lrs = append(lrs, &lr)
 		}
@@ -63,7 +70,52 @@ func List(vmstubbers []vmconfigs.VMProvider, _ machine.ListOptions) ([]*machine.
 	return lrs, nil
 }
 
-func Init(opts machineDefine.InitOptions, mp vmconfigs.VMProvider) (*vmconfigs.MachineConfig, error) {
+// Init creates a new machine.  With opts.StartAfterInit, the machine is
+// started once it is created and the report of the start is returned.  A
+// machine that fails to start stays initialized and the error is an
+// *define.ErrStartAfterInit.  A machine given an ignition file with
+// opts.IgnitionPath cannot be started after init, as nothing in the guest
+// reports it ready.
+func Init(opts machineDefine.InitOptions, mp vmconfigs.VMProvider) (*vmconfigs.MachineConfig, *machine.StartReport, error) {
+	if opts.StartAfterInit {
+		if len(opts.IgnitionPath) > 0 {
+			return nil, nil, errors.New("a machine given an ignition file cannot be started after init, start it with podman machine start")
+		}
+		// Check before anything is created; the new machine is not
+		// on disk yet so it is not part of the check
+		if err := CheckExclusiveActiveVM(mp, &vmconfigs.MachineConfig{Name: opts.Name}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	getDisk := func(dirs *machineDefine.MachineDirs, mc *vmconfigs.MachineConfig) error {
+		return mp.GetDisk(opts.ImagePath, dirs, mc)
+	}
+	mc, dirs, err := initialize(opts, mp, nil, getDisk)
+	if err != nil || mc == nil || !opts.StartAfterInit {
+		return mc, nil, err
+	}
+
+	mc.Starting = true
+	if err := mc.Write(); err != nil {
+		return mc, nil, err
+	}
+	report, err := Start(mc, mp, dirs, machine.StartOptions{})
+	mc.Starting = false
+	if err := mc.Write(); err != nil {
+		mc.Logger().Errorf("%v", err)
+	}
+	if err != nil {
+		return mc, nil, &machineDefine.ErrStartAfterInit{Name: mc.Name, Err: err}
+	}
+	return mc, report, nil
+}
+
+// initialize creates the machine and returns it with its directories.  The
+// disk image is written to mc.ImagePath by getDisk, while the rest of the
+// machine is set up.  prepare, unless nil, adjusts the machine configuration
+// before.  The callbacks it registers undo everything it did if it fails.
+func initialize(opts machineDefine.InitOptions, mp vmconfigs.VMProvider, prepare func(*vmconfigs.MachineConfig) error, getDisk func(*machineDefine.MachineDirs, *vmconfigs.MachineConfig) error) (*vmconfigs.MachineConfig, *machineDefine.MachineDirs, error) {
 	var (
 		err            error
 		imageExtension string
@@ -76,25 +128,40 @@ func Init(opts machineDefine.InitOptions, mp vmconfigs.VMProvider) (*vmconfigs.M
 
 	dirs, err := machine.GetMachineDirs(mp.VMType())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	sshIdentityPath, err := machine.GetSSHIdentityPath(machineDefine.DefaultIdentityName)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	sshKey, err := machine.GetSSHKeys(sshIdentityPath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	mc, err := vmconfigs.NewMachineConfig(opts, dirs, sshIdentityPath, mp.VMType())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	mc.Version = vmconfigs.MachineConfigVersion
 
+	if err := checkFirmwareSupport(mc.Resources.Firmware, mp); err != nil {
+		return nil, nil, err
+	}
+
+	if err := selectProvisioner(opts, mc, mp.VMType()); err != nil {
+		return nil, nil, err
+	}
+
+	if err := allocateSSHPort(mc); err != nil {
+		return nil, nil, err
+	}
+	callbackFuncs.Add(func() error {
+		return machine.ReleaseMachinePort(mc.SSH.Port)
+	})
+
 	createOpts := machineDefine.CreateVMOpts{
 		Name: opts.Name,
 		Dirs: dirs,
@@ -104,6 +171,32 @@ func Init(opts machineDefine.InitOptions, mp vmconfigs.VMProvider) (*vmconfigs.M
 		createOpts.UserModeNetworking = *umn
 	}
 
+	// Mounts are validated before the slow image pull
+	if mp.VMType() != machineDefine.WSLVirt {
+		mc.Mounts, err = CmdLineVolumesToMounts(opts.Volumes, mp.MountType(), mp.VMType().ReservedMountTargets(), mc.Logger())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// So are the init scripts and units
+	userProvisioning, err := loadUserProvisioning(opts, mp.VMType())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// And the configuration files of the guest.  Ignition copies them on
+	// the first boot, WSL machines get them when they first start.
+	guestConfigChanged, err := updateGuestConfig(&mc.GuestConfig, opts.GuestConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	if guestConfigChanged && len(opts.IgnitionPath) > 0 {
+		err = errors.New("configuration files cannot be added to an ignition file given with --ignition-path")
+		return nil, nil, err
+	}
+	mc.GuestConfig.Pending = guestConfigChanged && mp.VMType() == machineDefine.WSLVirt
+
 	// Get Image
 	// TODO This needs rework bigtime; my preference is most of below of not living in here.
 	// ideally we could get a func back that pulls the image, and only do so IF everything works because
@@ -123,9 +216,9 @@ func Init(opts machineDefine.InitOptions, mp vmconfigs.VMProvider) (*vmconfigs.M
 		// do nothing
 	}
 
-	imagePath, err = dirs.DataDir.AppendToNewVMFile(fmt.Sprintf("%s-%s%s", opts.Name, runtime.GOARCH, imageExtension), nil)
+	imagePath, err = dirs.DataDir.AppendToNewVMFile(diskImageName(opts.Name, imageExtension), nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	mc.ImagePath = imagePath
 
@@ -138,17 +231,99 @@ func Init(opts machineDefine.InitOptions, mp vmconfigs.VMProvider) (*vmconfigs.M
 	// "/path
 	// "docker://quay.io/something/someManifest
 
-	if err := mp.GetDisk(opts.ImagePath, dirs, mc); err != nil {
-		return nil, err
+	// TODO the definition of "user" should go into
+	// common for WSL
+	if mp.VMType() == machineDefine.WSLVirt && opts.Username == "core" {
+		mc.SSH.RemoteUsername = "user"
+	}
+
+	if prepare != nil {
+		err = prepare(mc)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
+	// The image is the slowest part of the operation: it is pulled and
+	// decompressed while the ignition config is generated and the
+	// connections are registered, and the VM is created once both are done.
+	// getDisk must not change the parts of mc the ignition config and the
+	// connections are made of.
 	callbackFuncs.Add(mc.ImagePath.Delete)
+	var (
+		g          errgroup.Group
+		ignBuilder ignition.IgnitionBuilder
+	)
+	g.Go(func() error {
+		if err := getDisk(dirs, mc); err != nil {
+			return err
+		}
+		mc.Logger().Debugf("--> imagePath is %q", imagePath.GetPath())
+		return nil
+	})
+	g.Go(func() error {
+		var err error
+		ignBuilder, err = buildIgnition(opts, mp, mc, sshKey, userProvisioning)
+		if err != nil || len(opts.IgnitionPath) > 0 {
+			return err
+		}
 
-	logrus.Debugf("--> imagePath is %q", imagePath.GetPath())
+		// TODO AddSSHConnectionToPodmanSocket could take an machineconfig instead
+		if err := connection.AddSSHConnectionsToPodmanSocket(mc.HostUser.UID, mc.SSH.Port, mc.SSH.IdentityPath, mc.Name, mc.SSH.RemoteUsername, opts); err != nil {
+			return err
+		}
+		callbackFuncs.Add(func() error {
+			return connection.RemoveConnections(mc.Name, mc.Name+"-root")
+		})
+		return nil
+	})
+	if err = g.Wait(); err != nil {
+		return nil, nil, err
+	}
 
+	// If the user provides an ignition file, it was copied into the conf
+	// dir and there is nothing left to do.  No machine config is written,
+	// so no machine removal would release the SSH port.
+	if len(opts.IgnitionPath) > 0 {
+		err = machine.ReleaseMachinePort(mc.SSH.Port)
+		return nil, nil, err
+	}
+
+	err = mp.CreateVM(createOpts, mc, &ignBuilder)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = writeProvisioning(mc, &ignBuilder)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return mc, dirs, err
+}
+
+// checkFirmwareSupport checks that the provider can give machines the
+// firmware options of fw
+func checkFirmwareSupport(fw *machineDefine.FirmwareConfig, mp vmconfigs.VMProvider) error {
+	if fw == nil {
+		return nil
+	}
+	caps := mp.Capabilities()
+	if fw.SecureBoot && !caps.SecureBoot {
+		return fmt.Errorf("secure boot is not supported for %s machines: %w", mp.VMType().String(), machineDefine.ErrNotImplemented)
+	}
+	if fw.TPM && !caps.TPM {
+		return fmt.Errorf("a TPM is not supported for %s machines: %w", mp.VMType().String(), machineDefine.ErrNotImplemented)
+	}
+	return nil
+}
+
+// buildIgnition generates the ignition config of the new machine.  With
+// opts.IgnitionPath the file given by the user is copied instead.
+func buildIgnition(opts machineDefine.InitOptions, mp vmconfigs.VMProvider, mc *vmconfigs.MachineConfig, sshKey string, userProvisioning *userProvisioning) (ignition.IgnitionBuilder, error) {
 	ignitionFile, err := mc.IgnitionFile()
 	if err != nil {
-		return nil, err
+		return ignition.IgnitionBuilder{}, err
 	}
 
 	uid := os.Getuid()
@@ -156,18 +331,8 @@ func Init(opts machineDefine.InitOptions, mp vmconfigs.VMProvider) (*vmconfigs.M
 		uid = 1000
 	}
 
-	// TODO the definition of "user" should go into
-	// common for WSL
-	userName := opts.Username
-	if mp.VMType() == machineDefine.WSLVirt {
-		if opts.Username == "core" {
-			userName = "user"
-			mc.SSH.RemoteUsername = "user"
-		}
-	}
-
 	ignBuilder := ignition.NewIgnitionBuilder(ignition.DynamicIgnition{
-		Name:      userName,
+		Name:      mc.SSH.RemoteUsername,
 		Key:       sshKey,
 		TimeZone:  opts.TimeZone,
 		UID:       uid,
@@ -180,60 +345,50 @@ func Init(opts machineDefine.InitOptions, mp vmconfigs.VMProvider) (*vmconfigs.M
 	// If the user provides an ignition file, we need to
 	// copy it into the conf dir
 	if len(opts.IgnitionPath) > 0 {
-		err = ignBuilder.BuildWithIgnitionFile(opts.IgnitionPath)
-		return nil, err
+		return ignBuilder, ignBuilder.BuildWithIgnitionFile(opts.IgnitionPath)
 	}
 
-	err = ignBuilder.GenerateIgnitionConfig()
-	if err != nil {
-		return nil, err
+	if err := ignBuilder.GenerateIgnitionConfig(); err != nil {
+		return ignBuilder, err
 	}
 
 	readyIgnOpts, err := mp.PrepareIgnition(mc, &ignBuilder)
 	if err != nil {
-		return nil, err
+		return ignBuilder, err
 	}
 
-	readyUnitFile, err := ignition.CreateReadyUnitFile(mp.VMType(), readyIgnOpts)
+	readyUnit, err := newReadyUnit(mp.VMType(), readyIgnOpts)
 	if err != nil {
-		return nil, err
-	}
-
-	readyUnit := ignition.Unit{
-		Enabled:  ignition.BoolToPtr(true),
-		Name:     "ready.service",
-		Contents: ignition.StrToPtr(readyUnitFile),
+		return ignBuilder, err
 	}
 	ignBuilder.WithUnit(readyUnit)
 
-	// Mounts
-	if mp.VMType() != machineDefine.WSLVirt {
-		mc.Mounts = CmdLineVolumesToMounts(opts.Volumes, mp.MountType())
-	}
-
-	// TODO AddSSHConnectionToPodmanSocket could take an machineconfig instead
-	if err := connection.AddSSHConnectionsToPodmanSocket(mc.HostUser.UID, mc.SSH.Port, mc.SSH.IdentityPath, mc.Name, mc.SSH.RemoteUsername, opts); err != nil {
-		return nil, err
-	}
-
-	cleanup := func() error {
-		return connection.RemoveConnections(mc.Name, mc.Name+"-root")
+	if mc.Resources.GPU != nil {
+		gpuScript, gpuUnit := gpuProvisioning()
+		ignBuilder.WithFile(gpuScript)
+		ignBuilder.WithUnit(gpuUnit)
 	}
-	callbackFuncs.Add(cleanup)
 
-	err = mp.CreateVM(createOpts, mc, &ignBuilder)
-	if err != nil {
-		return nil, err
-	}
-
-	err = ignBuilder.Build()
-	if err != nil {
-		return nil, err
+	if mp.VMType() != machineDefine.WSLVirt {
+		files, err := guestConfigIgnitionFiles(mc.GuestConfig)
+		if err != nil {
+			return ignBuilder, err
+		}
+		ignBuilder.WithFile(files...)
 	}
 
-	return mc, err
+	return ignBuilder, userProvisioning.apply(&ignBuilder)
 }
 
+// These are variables so that tests can replace them with fakes that do
+// not need a booted guest to talk to
+var (
+	readinessCheck    = conductVMReadinessCheck
+	applyProxies      = proxyenv.ApplyProxies
+	refreshProxies    = proxyenv.RefreshProxies
+	updateSockService = machine.UpdatePodmanDockerSockService
+)
+
 // VMExists looks across given providers for a machine's existence.  returns the actual config and found bool
 func VMExists(name string, vmstubbers []vmconfigs.VMProvider) (*vmconfigs.MachineConfig, bool, error) {
 	// Look on disk first
@@ -306,64 +461,137 @@ func getMCsOverProviders(vmstubbers []vmconfigs.VMProvider) (map[string]*vmconfi
 	return mcs, nil
 }
 
-// Stop stops the machine as well as supporting binaries/processes
-func Stop(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, dirs *machineDefine.MachineDirs, hardStop bool) error {
+// Stop stops the machine as well as supporting binaries/processes.  The
+// returned report tells how the machine was stopped, it is nil when the
+// machine was not running.
+func Stop(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, dirs *machineDefine.MachineDirs, opts machine.StopOptions) (*machine.StopReport, error) {
+	report, err := stop(mc, mp, dirs, opts)
+	mc.RecordOperationResult(vmconfigs.OperationStop, err)
+	return report, err
+}
+
+func stop(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, dirs *machineDefine.MachineDirs, opts machine.StopOptions) (*machine.StopReport, error) {
+	// the monitor must not restart a machine stopped on purpose
+	if err := stopMonitor(mc); err != nil {
+		mc.Logger().Warnf("Stopping monitor of machine %q: %v", mc.Name, err)
+	}
+
 	// state is checked here instead of earlier because stopping a stopped vm is not considered
 	// an error.  so putting in one place instead of sprinkling all over.
 	state, err := mp.State(mc, false)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// stopping a stopped machine is NOT an error
 	if state == machineDefine.Stopped {
-		return nil
+		return nil, nil
 	}
 	if state != machineDefine.Running {
-		return machineDefine.ErrWrongState
+		return nil, machineDefine.ErrWrongState
+	}
+
+	if !opts.NoHooks && len(mc.HostHooks.PreStop) > 0 {
+		if _, err := runHostHooks(mc, "pre-stop", mc.HostHooks.PreStop); err != nil {
+			return nil, err
+		}
+	}
+
+	// Give the guest a chance to flush its state first
+	if !opts.Hard && !opts.NoHooks && len(mc.Hooks.PreStop) > 0 {
+		if guestReachable(mc) {
+			if _, err := runHooks(mc, "pre-stop", mc.Hooks.PreStop, false); err != nil {
+				return nil, err
+			}
+		} else {
+			mc.Logger().Warnf("Machine %q is not reachable, skipping its pre-stop hooks", mc.Name)
+		}
 	}
 
 	// Provider stops the machine
-	if err := mp.StopVM(mc, hardStop); err != nil {
-		return err
+	report, err := stopVM(mc, mp, opts)
+	if err != nil {
+		return nil, err
 	}
 
 	// Remove Ready Socket
 	readySocket, err := mc.ReadySocket()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if err := readySocket.Delete(); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Stop GvProxy and remove PID file
 	if !mp.UseProviderNetworkSetup() {
-		gvproxyPidFile, err := dirs.RuntimeDir.AppendToNewVMFile("gvproxy.pid", nil)
+		gvproxyPidFile, err := mc.RunningGVProxyPidFile()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if err := machine.CleanupGVProxy(*gvproxyPidFile); err != nil {
-			return fmt.Errorf("unable to clean up gvproxy: %w", err)
+			return nil, fmt.Errorf("unable to clean up gvproxy: %w", err)
 		}
 	}
 
-	return nil
+	if !opts.NoHooks && len(mc.HostHooks.PostStop) > 0 {
+		if _, err := runHostHooks(mc, "post-stop", mc.HostHooks.PostStop); err != nil {
+			return report, fmt.Errorf("machine %q is stopped: %w", mc.Name, err)
+		}
+	}
+
+	return report, nil
 }
 
-func Start(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, dirs *machineDefine.MachineDirs, opts machine.StartOptions) error {
+// Start starts the machine and its supporting processes.  The returned report holds
+// the time spent in each phase of the start.
+func Start(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, dirs *machineDefine.MachineDirs, opts machine.StartOptions) (*machine.StartReport, error) {
+	report, err := start(mc, mp, dirs, opts)
+	mc.RecordOperationResult(vmconfigs.OperationStart, err)
+	if err == nil && mc.Monitored() {
+		if err := spawnMonitor(mc); err != nil {
+			mc.Logger().Warnf("Machine %q will not be monitored: %v", mc.Name, err)
+		}
+	}
+	return report, err
+}
+
+func start(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, dirs *machineDefine.MachineDirs, opts machine.StartOptions) (*machine.StartReport, error) {
 	defaultBackoff := 500 * time.Millisecond
 	maxBackoffs := 6
 
-	gvproxyPidFile, err := dirs.RuntimeDir.AppendToNewVMFile("gvproxy.pid", nil)
+	phases := machine.NewPhaseRecorder(opts.Progress)
+	defer func() {
+		mc.Logger().Debugf("Machine %q start phases: %s", mc.Name, phases.Report())
+	}()
+
+	gvproxyPidFile, err := mc.GVProxyPidFile()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// the host may need to be prepared, e.g. a VPN brought up, before the
+	// machine can start
+	if len(mc.HostHooks.PreStart) > 0 {
+		if _, err := runHostHooks(mc, "pre-start", mc.HostHooks.PreStart); err != nil {
+			return nil, err
+		}
+	}
+
+	// another machine may have been given the SSH port of a machine
+	// created before ports were reserved
+	if !mp.UseProviderNetworkSetup() {
+		if err := ensureSSHPort(mc); err != nil {
+			return nil, err
+		}
 	}
 
 	// start gvproxy and set up the API socket forwarding
-	forwardSocketPath, forwardingState, err := startNetworking(mc, mp)
+	phases.Begin(machine.PhaseNetworking)
+	forwardSocketPath, secondarySocketPath, forwardingState, err := startNetworking(mc, mp)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	phases.End(machine.PhaseNetworking)
 
 	callBackFuncs := machine.CleanUp()
 	defer callBackFuncs.CleanIfErr(&err)
@@ -381,23 +609,33 @@ func Start(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, dirs *machineDe
 	// releaseFunc is if the provider starts a vm using a go command
 	// and we still need control of it while it is booting until the ready
 	// socket is tripped
+	phases.Begin(machine.PhaseStartVM)
+	// the guest has yet to fetch its ignition config
+	err = refreshFirstBootIgnition(mc, mp)
+	if err != nil {
+		return nil, err
+	}
+	mc.ReadyStatus = sockets.ReadyStatus{}
 	releaseCmd, WaitForReady, err := mp.StartVM(mc)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	phases.End(machine.PhaseStartVM)
 
 	if WaitForReady == nil {
-		return errors.New("no valid wait function returned")
+		return nil, errors.New("no valid wait function returned")
 	}
 
-	if err := WaitForReady(); err != nil {
-		return err
+	phases.Begin(machine.PhaseWaitForReady)
+	if err := waitForReady(mc, WaitForReady, opts.ReadyTimeout); err != nil {
+		return nil, err
 	}
+	phases.End(machine.PhaseWaitForReady)
 
 	if releaseCmd != nil && releaseCmd() != nil { // some providers can return nil here (hyperv)
 		if err := releaseCmd(); err != nil {
 			// I think it is ok for a "light" error?
-			logrus.Error(err)
+			mc.Logger().Errorf("%v", err)
 		}
 	}
 
@@ -407,70 +645,208 @@ func Start(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, dirs *machineDe
 
 	err = mp.PostStartNetworking(mc, opts.NoInfo)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	stateF := func() (machineDefine.Status, error) {
 		return mp.State(mc, true)
 	}
 
-	connected, sshError, err := conductVMReadinessCheck(mc, maxBackoffs, defaultBackoff, stateF)
+	phases.Begin(machine.PhaseReadiness)
+	connected, sshError, err := readinessCheck(mc, maxBackoffs, defaultBackoff, stateF)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if !connected {
 		msg := "machine did not transition into running state"
+		if problems := mc.ReadyStatus.Problems(); len(problems) > 0 {
+			msg = fmt.Sprintf("%s (%s)", msg, strings.Join(problems, "; "))
+		}
 		if sshError != nil {
-			return fmt.Errorf("%s: ssh error: %v", msg, sshError)
+			return nil, fmt.Errorf("%s: ssh error: %v", msg, sshError)
 		}
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
+	phases.End(machine.PhaseReadiness)
 
-	if err := proxyenv.ApplyProxies(mc); err != nil {
-		return err
+	phases.Begin(machine.PhaseProxies)
+	if err := applyProxies(mc); err != nil {
+		return nil, err
 	}
+	recordProxies(mc)
+	phases.End(machine.PhaseProxies)
 
 	// mount the volumes to the VM
+	phases.Begin(machine.PhaseMounts)
 	if err := mp.MountVolumesToVM(mc, opts.Quiet); err != nil {
-		return err
+		return nil, err
 	}
+	phases.End(machine.PhaseMounts)
 
 	// update the podman/docker socket service if the host user has been modified at all (UID or Rootful)
 	if mc.HostUser.Modified {
-		if machine.UpdatePodmanDockerSockService(mc) == nil {
+		if updateSockService(mc) == nil {
 			// Reset modification state if there are no errors, otherwise ignore errors
 			// which are already logged
 			mc.HostUser.Modified = false
 			if err := mc.Write(); err != nil {
-				logrus.Error(err)
+				mc.Logger().Errorf("%v", err)
 			}
 		}
 	}
 
+	// the configuration files changed while the machine was stopped
+	applyPendingGuestConfig(mc)
+
+	if needsStartHooks(mc) {
+		phases.Begin(machine.PhaseHooks)
+		runStartHooks(mc)
+		phases.End(machine.PhaseHooks)
+	}
+
 	// Provider is responsible for waiting
 	if mp.UseProviderNetworkSetup() {
-		return nil
+		return phases.Report(), nil
 	}
 
 	noInfo := opts.NoInfo
 
+	phases.Begin(machine.PhaseAPIWait)
 	machine.WaitAPIAndPrintInfo(
 		forwardingState,
 		mc.Name,
 		findClaimHelper(),
 		forwardSocketPath,
+		secondarySocketPath,
 		noInfo,
 		mc.HostUser.Rootful,
 	)
+	phases.End(machine.PhaseAPIWait)
 
+	return phases.Report(), nil
+}
+
+// Set applies the given settings to the machine through its provider and
+// writes the updated configuration.  The CPUs, memory and disk size are
+// changed with Update.
+func Set(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, opts machineDefine.SetOptions) error {
+	if opts.PreStopHooks != nil {
+		hooks, err := vmconfigs.ParseHooks(*opts.PreStopHooks)
+		if err != nil {
+			return err
+		}
+		mc.Hooks.PreStop = hooks
+	}
+	if err := mc.HostHooks.Parse(opts.HostHooks); err != nil {
+		return err
+	}
+	if opts.RestartPolicy != nil {
+		policy, err := machineDefine.ParseRestartPolicy(*opts.RestartPolicy)
+		if err != nil {
+			return err
+		}
+		mc.RestartPolicy = policy
+	}
+	if opts.ClockSync != nil {
+		mc.ClockSync = *opts.ClockSync
+	}
+	if opts.Ports != nil {
+		ports, err := machineDefine.ParsePortForwards(*opts.Ports)
+		if err != nil {
+			return err
+		}
+		if err := vmconfigs.CheckPortSupport(mp.VMType(), ports); err != nil {
+			return err
+		}
+		mc.Network.Ports = ports
+	}
+	if opts.WSLNetworking != nil || opts.UserModeNetworking != nil {
+		mode := mc.Network.WSLMode
+		if opts.WSLNetworking != nil {
+			parsed, err := machineDefine.ParseWSLNetworkingMode(*opts.WSLNetworking)
+			if err != nil {
+				return err
+			}
+			mode = parsed
+		}
+		userModeNetworking := mp.UserModeNetworkEnabled(mc)
+		if opts.UserModeNetworking != nil {
+			userModeNetworking = *opts.UserModeNetworking
+		}
+		if err := vmconfigs.CheckWSLNetworkingSupport(mp.VMType(), mode, userModeNetworking); err != nil {
+			return err
+		}
+		mc.Network.WSLMode = mode
+	}
+
+	guestConfigChanged, err := updateGuestConfig(&mc.GuestConfig, opts.GuestConfig)
+	if err != nil {
+		return err
+	}
+
+	if err := checkUpdate(mc, mp, opts); err != nil {
+		mc.RecordOperationResult(vmconfigs.OperationSet, err)
+		return err
+	}
+
+	// At this point, we have the known changed information, etc
+	// Walk through changes to the providers if they need them
+	if err := mp.SetProviderAttrs(mc, opts); err != nil {
+		mc.RecordOperationResult(vmconfigs.OperationSet, err)
+		return err
+	}
+
+	// the disk is resized last as it cannot be undone
+	if err := updateResources(mc, mp, opts); err != nil {
+		mc.RecordOperationResult(vmconfigs.OperationSet, err)
+		return err
+	}
+
+	// Update the configuration file last if everything earlier worked
+	if mc.LastError != nil && mc.LastError.Operation == vmconfigs.OperationSet {
+		mc.LastError = nil
+	}
+	if err := mc.Write(); err != nil {
+		return err
+	}
+
+	// a running machine gets the configuration files right away, otherwise
+	// they are copied when it starts
+	if guestConfigChanged {
+		state, err := mp.State(mc, false)
+		if err != nil {
+			return err
+		}
+		if state == machineDefine.Running {
+			if err := copyGuestConfig(mc); err != nil {
+				mc.Logger().Warnf("%v, it is retried on the next start", err)
+			} else if err := mc.Write(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// a running machine is monitored from now on, or no longer
+	if opts.RestartPolicy != nil || opts.ClockSync != nil {
+		state, err := mp.State(mc, false)
+		if err != nil {
+			return err
+		}
+		if state == machineDefine.Running {
+			if mc.Monitored() {
+				return spawnMonitor(mc)
+			}
+			return stopMonitor(mc)
+		}
+	}
 	return nil
 }
 
 func Reset(dirs *machineDefine.MachineDirs, mp vmconfigs.VMProvider, mcs map[string]*vmconfigs.MachineConfig) error {
 	var resetErrors *multierror.Error
 	for _, mc := range mcs {
-		err := Stop(mc, mp, dirs, true)
+		_, err := stop(mc, mp, dirs, machine.StopOptions{Hard: true})
 		if err != nil {
 			resetErrors = multierror.Append(resetErrors, err)
 		}
@@ -0,0 +1,613 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// Machine configs, images and connections all live under the
+	// XDG directories so point them somewhere disposable
+	tmp, err := os.MkdirTemp("", "shim-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(tmp, "config"))
+	os.Setenv("XDG_DATA_HOME", filepath.Join(tmp, "data"))
+	os.Setenv("XDG_RUNTIME_DIR", filepath.Join(tmp, "run"))
+	os.Setenv("PODMAN_CONNECTIONS_CONF", filepath.Join(tmp, "podman-connections.json"))
+
+	// The guest is never reached so replace everything that needs ssh
+	readinessCheck = func(*vmconfigs.MachineConfig, int, time.Duration, func() (define.Status, error)) (bool, error, error) {
+		return true, nil, nil
+	}
+	applyProxies = func(*vmconfigs.MachineConfig) error {
+		return nil
+	}
+	refreshProxies = func(*vmconfigs.MachineConfig) error {
+		return nil
+	}
+
+	code := m.Run()
+	os.RemoveAll(tmp)
+	os.Exit(code)
+}
+
+// writeIdentity creates a dummy ssh identity so that Init does not call ssh-keygen
+func writeIdentity(t *testing.T) {
+	t.Helper()
+	identity, err := machine.GetSSHIdentityPath(define.DefaultIdentityName)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(identity), 0755))
+	require.NoError(t, os.WriteFile(identity, []byte("private"), 0600))
+	require.NoError(t, os.WriteFile(identity+".pub", []byte("ssh-ed25519 AAAA test"), 0644))
+}
+
+// initMachine initializes and writes a machine using the given provider.  The
+// runtime directory is moved to a temporary directory.
+func initMachine(t *testing.T, p vmconfigs.VMProvider, name string) (*vmconfigs.MachineConfig, *define.MachineDirs) {
+	t.Helper()
+	writeIdentity(t)
+
+	mc, _, err := Init(define.InitOptions{Name: name, Username: "core"}, p)
+	require.NoError(t, err)
+	require.NoError(t, mc.Write())
+
+	dirs, err := machine.GetMachineDirs(p.VMType())
+	require.NoError(t, err)
+	dirs.RuntimeDir, err = define.NewMachineFile(t.TempDir(), nil)
+	require.NoError(t, err)
+	mc.SetDirs(dirs)
+
+	t.Cleanup(func() {
+		_, rm, err := mc.Remove(false, false)
+		if err == nil {
+			_ = rm()
+		}
+	})
+	return mc, dirs
+}
+
+func TestInit(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "init")
+
+	// the disk is pulled while the ignition config is generated
+	calls := p.Calls()
+	require.Len(t, calls, 3)
+	assert.ElementsMatch(t, []string{"GetDisk", "PrepareIgnition"}, calls[:2])
+	assert.Equal(t, "CreateVM", calls[2])
+	assert.FileExists(t, mc.ImagePath.GetPath())
+	ign, err := mc.IgnitionFile()
+	require.NoError(t, err)
+	assert.FileExists(t, ign.GetPath())
+
+	exists, err := p.Exists("init")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestInitCleansUpOnError(t *testing.T) {
+	writeIdentity(t)
+	p := fakeprovider.New(t)
+	p.Fail("CreateVM", errors.New("no hypervisor"))
+
+	_, _, err := Init(define.InitOptions{Name: "initfail", Username: "core"}, p)
+	require.ErrorContains(t, err, "no hypervisor")
+
+	dirs, err := machine.GetMachineDirs(p.VMType())
+	require.NoError(t, err)
+	matches, err := filepath.Glob(filepath.Join(dirs.DataDir.GetPath(), "initfail-*"))
+	require.NoError(t, err)
+	assert.Empty(t, matches, "image must be removed")
+
+	// the connections were removed so adding them again must work
+	p.Fail("CreateVM", nil)
+	initMachine(t, p, "initfail")
+}
+
+func TestInitPullsDiskConcurrently(t *testing.T) {
+	delay := 300 * time.Millisecond
+	p := fakeprovider.New(t)
+	p.Delay("GetDisk", delay)
+	p.Delay("PrepareIgnition", delay)
+
+	start := time.Now()
+	initMachine(t, p, "initconcurrent")
+	assert.Less(t, time.Since(start), 2*delay, "the disk pull and the ignition config must overlap")
+}
+
+func TestInitFirmware(t *testing.T) {
+	writeIdentity(t)
+	p := fakeprovider.New(t)
+
+	mc, _, err := Init(define.InitOptions{Name: "firmware", Username: "core", SecureBoot: true, TPM: true}, p)
+	require.NoError(t, err)
+	assert.Equal(t, &define.FirmwareConfig{SecureBoot: true, TPM: true}, mc.Resources.Firmware)
+
+	// providers without the firmware options refuse them before any work
+	p = fakeprovider.New(t)
+	p.DefaultFirmware = true
+	_, _, err = Init(define.InitOptions{Name: "nofirmware", Username: "core", TPM: true}, p)
+	require.ErrorIs(t, err, define.ErrNotImplemented)
+	assert.ErrorContains(t, err, "TPM")
+	assert.Empty(t, p.Calls())
+}
+
+func TestInitDiskFailure(t *testing.T) {
+	writeIdentity(t)
+	p := fakeprovider.New(t)
+	p.Fail("GetDisk", errors.New("pull failed"))
+	p.Delay("GetDisk", 100*time.Millisecond)
+
+	// the connections registered while the disk was pulled are removed
+	_, _, err := Init(define.InitOptions{Name: "diskfail", Username: "core"}, p)
+	require.ErrorContains(t, err, "pull failed")
+	assert.Zero(t, p.Called("CreateVM"))
+	assert.NotContains(t, connectionNames(t), "diskfail")
+}
+
+func TestInitStartAfterInit(t *testing.T) {
+	writeIdentity(t)
+	p := fakeprovider.New(t)
+
+	mc, report, err := Init(define.InitOptions{Name: "initstart", Username: "core", StartAfterInit: true}, p)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, rm, err := mc.Remove(false, false)
+		if err == nil {
+			_ = rm()
+		}
+	})
+	require.NotNil(t, report)
+	assert.Equal(t, "CreateVM", p.Calls()[2])
+	assert.Equal(t, 1, p.Called("StartVM"))
+	state, err := p.State(mc, false)
+	require.NoError(t, err)
+	assert.Equal(t, define.Running, state)
+
+	dirs, err := machine.GetMachineDirs(p.VMType())
+	require.NoError(t, err)
+	reloaded, err := vmconfigs.LoadMachineByName("initstart", dirs)
+	require.NoError(t, err)
+	assert.False(t, reloaded.Starting)
+	_, err = Stop(mc, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+}
+
+func TestInitStartAfterInitErrors(t *testing.T) {
+	writeIdentity(t)
+	p := fakeprovider.New(t)
+
+	// an init failure does not attempt a start
+	p.Fail("CreateVM", errors.New("no hypervisor"))
+	_, _, err := Init(define.InitOptions{Name: "initstartfail", Username: "core", StartAfterInit: true}, p)
+	require.ErrorContains(t, err, "no hypervisor")
+	var startErr *define.ErrStartAfterInit
+	assert.False(t, errors.As(err, &startErr))
+	assert.Zero(t, p.Called("StartVM"))
+	p.Fail("CreateVM", nil)
+
+	// a start failure leaves the machine initialized
+	p.Fail("StartVM", errors.New("no kvm"))
+	mc, report, err := Init(define.InitOptions{Name: "initstartfail", Username: "core", StartAfterInit: true}, p)
+	require.ErrorAs(t, err, &startErr)
+	assert.ErrorContains(t, err, "no kvm")
+	assert.Nil(t, report)
+	require.NotNil(t, mc)
+	t.Cleanup(func() {
+		_, rm, err := mc.Remove(false, false)
+		if err == nil {
+			_ = rm()
+		}
+	})
+	assert.FileExists(t, mc.ImagePath.GetPath())
+	p.Fail("StartVM", nil)
+
+	// exclusivity is checked before the machine is created
+	other, _ := initMachine(t, p, "initstart-other")
+	p.SetState(other.Name, define.Running)
+	p.Exclusive = true
+	calls := len(p.Calls())
+	_, _, err = Init(define.InitOptions{Name: "initstart-excl", Username: "core", StartAfterInit: true}, p)
+	require.ErrorContains(t, err, "machine initstart-other already running")
+	for _, call := range p.Calls()[calls:] {
+		assert.Equal(t, "State", call, "only State is called")
+	}
+}
+
+func TestInitIgnitionPath(t *testing.T) {
+	writeIdentity(t)
+	p := fakeprovider.New(t)
+	ign := filepath.Join(t.TempDir(), "custom.ign")
+	require.NoError(t, os.WriteFile(ign, []byte(`{"ignition":{"version":"3.4.0"}}`), 0644))
+
+	_, _, err := Init(define.InitOptions{Name: "initign", Username: "core", IgnitionPath: ign, StartAfterInit: true}, p)
+	require.ErrorContains(t, err, "cannot be started after init")
+	assert.Zero(t, p.Called("CreateVM"))
+
+	// the machine is left to the ignition file, it is not created
+	mc, _, err := Init(define.InitOptions{Name: "initign", Username: "core", IgnitionPath: ign}, p)
+	require.NoError(t, err)
+	assert.Nil(t, mc)
+	assert.Zero(t, p.Called("CreateVM"))
+}
+
+func TestStartStop(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "startstop")
+
+	_, err := Start(mc, p, dirs, machine.StartOptions{NoInfo: true})
+	require.NoError(t, err)
+	state, err := p.State(mc, false)
+	require.NoError(t, err)
+	assert.Equal(t, define.Running, state)
+	assert.Equal(t, 1, p.Called("MountVolumesToVM"))
+
+	_, err = Stop(mc, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.Called("StopVM"))
+	readySocket, err := mc.ReadySocket()
+	require.NoError(t, err)
+	assert.NoFileExists(t, readySocket.GetPath())
+
+	// stopping a stopped machine is not an error and does nothing
+	_, err = Stop(mc, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.Called("StopVM"))
+}
+
+func TestStopWrongState(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "wrongstate")
+
+	p.SetStates(mc.Name, define.Starting, define.Running)
+	_, err := Stop(mc, p, dirs, machine.StopOptions{})
+	assert.ErrorIs(t, err, define.ErrWrongState)
+	_, err = Stop(mc, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.Called("StopVM"))
+}
+
+func TestStartErrors(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "starterr")
+
+	p.Fail("StartVM", errors.New("boom"))
+	_, err := Start(mc, p, dirs, machine.StartOptions{NoInfo: true})
+	require.ErrorContains(t, err, "boom")
+	assert.Zero(t, p.Called("WaitForReady"))
+	p.Fail("StartVM", nil)
+
+	p.Fail("MountVolumesToVM", errors.New("mount failed"))
+	_, err = Start(mc, p, dirs, machine.StartOptions{NoInfo: true})
+	require.ErrorContains(t, err, "mount failed")
+}
+
+func TestStartReadyTimeout(t *testing.T) {
+	p := fakeprovider.New(t)
+	p.SkipReady = true
+	mc, dirs := initMachine(t, p, "readytimeout")
+
+	logFile, err := mc.LogFile()
+	require.NoError(t, err)
+	var console []string
+	for i := 0; i < consoleTailLines+5; i++ {
+		console = append(console, fmt.Sprintf("console line %d", i))
+	}
+	require.NoError(t, os.WriteFile(logFile.GetPath(), []byte(strings.Join(console, "\n")+"\n"), 0644))
+
+	_, err = Start(mc, p, dirs, machine.StartOptions{NoInfo: true, ReadyTimeout: 100 * time.Millisecond})
+	var readyErr *define.ErrReadyTimeout
+	require.ErrorAs(t, err, &readyErr)
+	assert.Equal(t, 100*time.Millisecond, readyErr.Timeout)
+	assert.Equal(t, console[5:], readyErr.Console)
+	assert.Zero(t, p.Called("MountVolumesToVM"))
+}
+
+func TestStartReadyPayload(t *testing.T) {
+	p := fakeprovider.New(t)
+	p.ReadyPayload = `Ready {"ignition":"success","unit":"ready.service","uptime":"4.20"}`
+	mc, dirs := initMachine(t, p, "readypayload")
+
+	_, err := Start(mc, p, dirs, machine.StartOptions{NoInfo: true, ReadyTimeout: 5 * time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.Called("WaitForReady"))
+	assert.Equal(t, "success", mc.ReadyStatus.Ignition)
+}
+
+func TestStartReadyDiagnostics(t *testing.T) {
+	origReadiness := readinessCheck
+	defer func() {
+		readinessCheck = origReadiness
+	}()
+	readinessCheck = func(*vmconfigs.MachineConfig, int, time.Duration, func() (define.Status, error)) (bool, error, error) {
+		return false, errors.New("connection refused"), nil
+	}
+
+	p := fakeprovider.New(t)
+	p.ReadyPayload = `Ready {"ignition":"success","failed_units":["sshd.service"],"network":"offline"}`
+	mc, dirs := initMachine(t, p, "readydiag")
+
+	// the status the guest sent when it booted tells why ssh fails
+	_, err := Start(mc, p, dirs, machine.StartOptions{NoInfo: true, ReadyTimeout: 5 * time.Second})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "failed units: sshd.service")
+	assert.ErrorContains(t, err, "the network of the guest is offline")
+	assert.ErrorContains(t, err, "connection refused")
+
+	// a plain ping does not report the problems of the previous boot
+	p.ReadyPayload = ""
+	p.SetState(mc.Name, define.Stopped)
+	_, err = Start(mc, p, dirs, machine.StartOptions{NoInfo: true, ReadyTimeout: 5 * time.Second})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "sshd.service")
+}
+
+func TestStartReportsPhases(t *testing.T) {
+	delay := 20 * time.Millisecond
+
+	origReadiness, origProxies := readinessCheck, applyProxies
+	defer func() {
+		readinessCheck, applyProxies = origReadiness, origProxies
+	}()
+	readinessCheck = func(*vmconfigs.MachineConfig, int, time.Duration, func() (define.Status, error)) (bool, error, error) {
+		time.Sleep(delay)
+		return true, nil, nil
+	}
+	applyProxies = func(*vmconfigs.MachineConfig) error {
+		time.Sleep(delay)
+		return nil
+	}
+
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "phases")
+	for _, method := range []string{"StartNetworking", "StartVM", "WaitForReady", "MountVolumesToVM"} {
+		p.Delay(method, delay)
+	}
+
+	var events []machine.ProgressEvent
+	opts := machine.StartOptions{
+		NoInfo: true,
+		Progress: func(ev machine.ProgressEvent) {
+			events = append(events, ev)
+		},
+	}
+	report, err := Start(mc, p, dirs, opts)
+	require.NoError(t, err)
+
+	expected := []machine.StartPhase{
+		machine.PhaseNetworking,
+		machine.PhaseStartVM,
+		machine.PhaseWaitForReady,
+		machine.PhaseReadiness,
+		machine.PhaseProxies,
+		machine.PhaseMounts,
+	}
+	require.Len(t, report.Phases, len(expected))
+	var sum time.Duration
+	for i, phase := range expected {
+		assert.Equal(t, phase, report.Phases[i].Phase)
+		assert.GreaterOrEqual(t, report.Phases[i].Duration, delay, "phase %s", phase)
+		assert.Less(t, report.Phases[i].Duration, delay*10, "phase %s", phase)
+		sum += report.Phases[i].Duration
+	}
+	assert.GreaterOrEqual(t, report.Total, sum)
+
+	// every phase produced a begin and an end event
+	assert.Len(t, events, 2*len(expected))
+
+	attrs := report.Attributes()
+	assert.Contains(t, attrs, "phase.mounts")
+	assert.Contains(t, attrs, "phase.total")
+}
+
+func TestList(t *testing.T) {
+	p := fakeprovider.New(t)
+	initMachine(t, p, "list-a")
+	initMachine(t, p, "list-b")
+	p.SetState("list-b", define.Running)
+
+	dirs, err := machine.GetMachineDirs(p.VMType())
+	require.NoError(t, err)
+
+	lrs, err := List([]vmconfigs.VMProvider{p}, machine.ListOptions{})
+	require.NoError(t, err)
+	running := map[string]bool{}
+	states := map[string]define.Status{}
+	for _, lr := range lrs {
+		running[lr.Name] = lr.Running
+		states[lr.Name] = lr.State
+		assert.Equal(t, filepath.Join(dirs.ConfigDir.GetPath(), lr.Name+".json"), lr.ConfigPath)
+		assert.Equal(t, p.VMType().String(), lr.VMType)
+	}
+	assert.Equal(t, map[string]bool{"list-a": false, "list-b": true}, running)
+	assert.Equal(t, define.Running, states["list-b"])
+	assert.NotEqual(t, define.Running, states["list-a"])
+}
+
+func TestCheckExclusiveActiveVM(t *testing.T) {
+	p := fakeprovider.New(t)
+	a, _ := initMachine(t, p, "excl-a")
+	b, _ := initMachine(t, p, "excl-b")
+
+	p.SetState(a.Name, define.Running)
+	assert.NoError(t, CheckExclusiveActiveVM(p, b), "provider allows parallel machines")
+
+	p.Exclusive = true
+	assert.ErrorContains(t, CheckExclusiveActiveVM(p, b), `machine excl-a already running`)
+
+	p.SetState(a.Name, define.Stopped)
+	assert.NoError(t, CheckExclusiveActiveVM(p, b))
+
+	p.Fail("State", errors.New("state failed"))
+	assert.ErrorContains(t, CheckExclusiveActiveVM(p, b), "state failed")
+}
+
+func TestReset(t *testing.T) {
+	p := fakeprovider.New(t)
+	initMachine(t, p, "reset-a")
+	initMachine(t, p, "reset-b")
+	p.SetState("reset-a", define.Running)
+
+	dirs, err := machine.GetMachineDirs(p.VMType())
+	require.NoError(t, err)
+	mcs, err := getMCsOverProviders([]vmconfigs.VMProvider{p})
+	require.NoError(t, err)
+	require.Len(t, mcs, 2)
+
+	require.NoError(t, Reset(dirs, p, mcs))
+	assert.Equal(t, 1, p.Called("StopVM"))
+	assert.Equal(t, 2, p.Called("Remove"))
+	assert.NoDirExists(t, dirs.ConfigDir.GetPath())
+	assert.NoDirExists(t, dirs.DataDir.GetPath())
+	for _, name := range []string{"reset-a", "reset-b"} {
+		exists, err := p.Exists(name)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	}
+}
+
+func TestStartRecordsLastError(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "lasterror")
+
+	p.Fail("StartVM", errors.New("hypervisor exploded"))
+	_, err := Start(mc, p, dirs, machine.StartOptions{NoInfo: true})
+	require.Error(t, err)
+
+	loaded, err := vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	require.NotNil(t, loaded.LastError)
+	assert.Equal(t, vmconfigs.OperationStart, loaded.LastError.Operation)
+	assert.Equal(t, "hypervisor exploded", loaded.LastError.Error)
+	assert.False(t, loaded.LastError.Time.IsZero())
+
+	lrs, err := List([]vmconfigs.VMProvider{p}, machine.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, lrs, 1)
+	assert.Equal(t, loaded.LastError, lrs[0].LastError)
+
+	// a successful stop does not clear the start failure
+	_, err = Stop(mc, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+	assert.NotNil(t, mc.LastError)
+
+	p.Fail("StartVM", nil)
+	_, err = Start(mc, p, dirs, machine.StartOptions{NoInfo: true})
+	require.NoError(t, err)
+	assert.Nil(t, mc.LastError)
+	loaded, err = vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	assert.Nil(t, loaded.LastError)
+}
+
+func TestSetRecordsLastError(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "seterror")
+
+	clockSync := true
+	p.Fail("SetProviderAttrs", errors.New("cannot resize"))
+	require.ErrorContains(t, Set(mc, p, define.SetOptions{ClockSync: &clockSync}), "cannot resize")
+	require.NotNil(t, mc.LastError)
+	assert.Equal(t, vmconfigs.OperationSet, mc.LastError.Operation)
+
+	// only the error is saved, not the settings of the failed set
+	loaded, err := vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	require.NotNil(t, loaded.LastError)
+	assert.Equal(t, "cannot resize", loaded.LastError.Error)
+	assert.False(t, loaded.ClockSync)
+
+	p.Fail("SetProviderAttrs", nil)
+	require.NoError(t, Set(mc, p, define.SetOptions{}))
+	assert.Nil(t, mc.LastError)
+}
+
+func TestConvertDiskFormat(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "convert")
+	qcowPath := mc.ImagePath.GetPath()
+	require.Equal(t, ".qcow2", filepath.Ext(qcowPath))
+	require.NoError(t, os.WriteFile(qcowPath, []byte("disk content"), 0644))
+
+	require.NoError(t, ConvertDiskFormat(mc, p, define.Raw))
+	rawPath := mc.ImagePath.GetPath()
+	assert.Equal(t, filepath.Join(filepath.Dir(qcowPath), diskImageName("convert", ".raw")), rawPath)
+	assert.NoFileExists(t, qcowPath)
+	content, err := os.ReadFile(rawPath)
+	require.NoError(t, err)
+	assert.Equal(t, "disk content", string(content))
+
+	// the new image path is persisted
+	reloaded, err := vmconfigs.LoadMachineByName("convert", dirs)
+	require.NoError(t, err)
+	assert.Equal(t, rawPath, reloaded.ImagePath.GetPath())
+
+	// converting to the current format is a no-op
+	require.NoError(t, ConvertDiskFormat(mc, p, define.Raw))
+	assert.Equal(t, 1, p.Called("ConvertDisk"))
+
+	// and back again
+	require.NoError(t, ConvertDiskFormat(mc, p, define.Qcow))
+	assert.Equal(t, qcowPath, mc.ImagePath.GetPath())
+	assert.NoFileExists(t, rawPath)
+	assert.FileExists(t, qcowPath)
+}
+
+func TestConvertDiskFormatErrors(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "convert-errors")
+	qcowPath := mc.ImagePath.GetPath()
+
+	p.SetState(mc.Name, define.Running)
+	assert.ErrorIs(t, ConvertDiskFormat(mc, p, define.Raw), define.ErrWrongState)
+	p.SetState(mc.Name, define.Stopped)
+
+	// a failed conversion keeps the original disk
+	p.Fail("ConvertDisk", errors.New("no space left on device"))
+	assert.ErrorContains(t, ConvertDiskFormat(mc, p, define.Raw), "no space left on device")
+	assert.Equal(t, qcowPath, mc.ImagePath.GetPath())
+	assert.FileExists(t, qcowPath)
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(qcowPath), diskImageName("convert-errors", ".raw")))
+}
+
+func TestStartRecovery(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "recovery")
+
+	require.NoError(t, StartRecovery(mc, p, machine.RecoveryOptions{MaskUnits: []string{"broken.service", "var-lib\\x2dfoo.mount"}}))
+	assert.Equal(t, []define.RecoveryOverride{{
+		Target:    "emergency.target",
+		MaskUnits: []string{"broken.service", "var-lib\\x2dfoo.mount"},
+	}}, p.RecoveryOverrides())
+	assert.Equal(t, 1, p.Called("StartNetworking"))
+	// the ready service does not run in recovery, so nothing waits for it
+	assert.Zero(t, p.Called("StartVM"))
+	assert.Zero(t, p.Called("WaitForReady"))
+
+	// a running machine is not restarted for recovery
+	assert.ErrorIs(t, StartRecovery(mc, p, machine.RecoveryOptions{}), define.ErrVMAlreadyRunning)
+	assert.Equal(t, 1, p.Called("StartRecovery"))
+
+	p.SetState(mc.Name, define.Stopped)
+	assert.ErrorContains(t, StartRecovery(mc, p, machine.RecoveryOptions{MaskUnits: []string{"two units"}}), "invalid unit name")
+	assert.ErrorContains(t, StartRecovery(mc, p, machine.RecoveryOptions{MaskUnits: []string{"$(reboot).service"}}), "invalid unit name")
+
+	p.Fail("StartRecovery", define.ErrNotImplemented)
+	assert.ErrorIs(t, StartRecovery(mc, p, machine.RecoveryOptions{}), define.ErrNotImplemented)
+}
@@ -0,0 +1,194 @@
+package shim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/ignition"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// readyUnitName is the unit that trips the ready socket when the guest booted
+const readyUnitName = "ready.service"
+
+// newReadyUnit returns the ready unit for the given provider
+func newReadyUnit(vmType machineDefine.VMType, opts *ignition.ReadyUnitOpts) (ignition.Unit, error) {
+	readyUnitFile, err := ignition.CreateReadyUnitFile(vmType, opts)
+	if err != nil {
+		return ignition.Unit{}, err
+	}
+	return ignition.Unit{
+		Enabled:  ignition.BoolToPtr(true),
+		Name:     readyUnitName,
+		Contents: ignition.StrToPtr(readyUnitFile),
+	}, nil
+}
+
+// RegenerateIgnition rebuilds the ignition file of an existing machine from its
+// configuration and the given options.  Units, files and links of the current
+// ignition file that are not generated, e.g. the ones added by the provider at
+// init, are kept.  Because ignition only runs on the first boot, the commands
+// that apply the changes to an already booted guest are returned as well.
+func RegenerateIgnition(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, opts machineDefine.UpdateConfigOptions) ([]string, error) {
+	if mp.VMType() == machineDefine.WSLVirt {
+		return nil, fmt.Errorf("%s machines do not use ignition: %w", mp.VMType().String(), machineDefine.ErrNotImplemented)
+	}
+	if mc.Provisioner == machineDefine.CloudInitProvisioner {
+		return nil, fmt.Errorf("machine %q is provisioned by cloud-init, not ignition: %w", mc.Name, machineDefine.ErrNotImplemented)
+	}
+
+	keys := make([]string, 0, len(opts.SSHKeys))
+	for _, key := range opts.SSHKeys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, errors.New("ssh key must not be empty")
+		}
+		keys = append(keys, key)
+	}
+
+	ignitionFile, err := mc.IgnitionFile()
+	if err != nil {
+		return nil, err
+	}
+	var previous ignition.Config
+	content, err := ignitionFile.Read()
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(content, &previous); err != nil {
+			return nil, fmt.Errorf("parsing ignition file of machine %q: %w", mc.Name, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	sshKey, err := machine.GetSSHKeys(mc.SSH.IdentityPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ignBuilder := ignition.NewIgnitionBuilder(ignition.DynamicIgnition{
+		Name:           mc.SSH.RemoteUsername,
+		Key:            sshKey,
+		AdditionalKeys: keys,
+		TimeZone:       opts.TimeZone,
+		UID:            mc.HostUser.UID,
+		VMName:         mc.Name,
+		VMType:         mp.VMType(),
+		WritePath:      ignitionFile.GetPath(),
+		Rootful:        mc.HostUser.Rootful,
+	})
+	if err := ignBuilder.GenerateIgnitionConfig(); err != nil {
+		return nil, err
+	}
+
+	// The ready unit may depend on provider state set up at init, so the
+	// existing one is kept by the merge below
+	hasReadyUnit := false
+	for _, unit := range previous.Systemd.Units {
+		if unit.Name == readyUnitName {
+			hasReadyUnit = true
+			break
+		}
+	}
+	if !hasReadyUnit {
+		readyUnit, err := newReadyUnit(mp.VMType(), nil)
+		if err != nil {
+			return nil, err
+		}
+		ignBuilder.WithUnit(readyUnit)
+	}
+	ignBuilder.MergeFrom(previous)
+
+	if err := ignBuilder.Build(); err != nil {
+		return nil, err
+	}
+	return ignition.LiveApplyCommands(previous, ignBuilder.Config()), nil
+}
+
+// ApplyIgnitionPlan runs the commands returned by RegenerateIgnition in the
+// guest, in order, stopping at the first failure
+func ApplyIgnitionPlan(mc *vmconfigs.MachineConfig, plan []string) error {
+	hooks := make([]vmconfigs.Hook, 0, len(plan))
+	for _, cmd := range plan {
+		hooks = append(hooks, vmconfigs.Hook{Command: cmd, Required: true})
+	}
+	mc.Logger().Debugf("Applying %d ignition changes to machine %q", len(plan), mc.Name)
+	_, err := runHooks(mc, "update-config", hooks, false)
+	return err
+}
+
+// refreshFirstBootIgnition brings the ignition config of a machine that never
+// booted up to date when its provider serves the config as the machine boots:
+// the SSH key of the machine replaces the one it was created with, e.g. after
+// the identity was rotated, and the pending guest config files are written by
+// ignition instead of being copied once the machine is up.
+func refreshFirstBootIgnition(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider) error {
+	if !mp.Capabilities().IgnitionVsock || mc.Provisioner == machineDefine.CloudInitProvisioner {
+		return nil
+	}
+	firstBoot, err := mc.IsFirstBoot()
+	if err != nil || !firstBoot {
+		return err
+	}
+
+	ignitionFile, err := mc.IgnitionFile()
+	if err != nil {
+		return err
+	}
+	content, err := ignitionFile.Read()
+	if err != nil {
+		return err
+	}
+	var cfg ignition.Config
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return fmt.Errorf("parsing ignition file of machine %q: %w", mc.Name, err)
+	}
+
+	sshKey, err := machine.GetSSHKeys(mc.SSH.IdentityPath)
+	if err != nil {
+		return err
+	}
+	// the key of the machine is the first one of its users, the others
+	// were added with update-config
+	for i, user := range cfg.Passwd.Users {
+		if len(user.SSHAuthorizedKeys) > 0 {
+			cfg.Passwd.Users[i].SSHAuthorizedKeys[0] = ignition.SSHAuthorizedKey(sshKey)
+		}
+	}
+
+	if mc.GuestConfig.Pending {
+		guestPaths := make(map[string]bool)
+		for _, f := range guestConfigFiles(mc.GuestConfig) {
+			guestPaths[f.guestPath] = true
+		}
+		files := make([]ignition.File, 0, len(cfg.Storage.Files))
+		for _, f := range cfg.Storage.Files {
+			if !guestPaths[f.Path] {
+				files = append(files, f)
+			}
+		}
+		guestFiles, err := guestConfigIgnitionFiles(mc.GuestConfig)
+		if err != nil {
+			return err
+		}
+		cfg.Storage.Files = append(files, guestFiles...)
+	}
+
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(ignitionFile.GetPath(), b, 0644); err != nil {
+		return err
+	}
+	if mc.GuestConfig.Pending {
+		mc.GuestConfig.Pending = false
+		return mc.Write()
+	}
+	return nil
+}
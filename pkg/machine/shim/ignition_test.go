@@ -0,0 +1,160 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/ignition"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readIgnition(t *testing.T, mc *vmconfigs.MachineConfig) ignition.Config {
+	t.Helper()
+	ignitionFile, err := mc.IgnitionFile()
+	require.NoError(t, err)
+	content, err := ignitionFile.Read()
+	require.NoError(t, err)
+	var cfg ignition.Config
+	require.NoError(t, json.Unmarshal(content, &cfg))
+	return cfg
+}
+
+func TestRegenerateIgnition(t *testing.T) {
+	// no host certificates end up in the ignition
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SSL_CERT_FILE", "")
+	t.Setenv("SSL_CERT_DIR", "")
+
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "regenerate")
+	before := readIgnition(t, mc)
+
+	plan, err := RegenerateIgnition(mc, p, define.UpdateConfigOptions{
+		TimeZone: "Europe/Berlin",
+		SSHKeys:  []string{"ssh-ed25519 BBBB extra\n"},
+	})
+	require.NoError(t, err)
+
+	after := readIgnition(t, mc)
+	passwd, err := json.Marshal(after.Passwd)
+	require.NoError(t, err)
+	assert.JSONEq(t, fmt.Sprintf(`{"users": [
+		{"name": "core", "uid": %d, "sshAuthorizedKeys": ["ssh-ed25519 AAAA test", "ssh-ed25519 BBBB extra"]},
+		{"name": "root", "sshAuthorizedKeys": ["ssh-ed25519 AAAA test", "ssh-ed25519 BBBB extra"]}
+	]}`, mc.HostUser.UID), string(passwd))
+
+	tzLink, err := json.Marshal(after.Storage.Links[len(after.Storage.Links)-1])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"group": {"name": "root"}, "overwrite": false, "path": "/etc/localtime", "user": {"name": "root"},
+		"hard": false, "target": "/usr/share/zoneinfo/Europe/Berlin"
+	}`, string(tzLink))
+
+	// the ready unit of the provider is kept
+	readyUnits := 0
+	for _, unit := range after.Systemd.Units {
+		if unit.Name == "ready.service" {
+			readyUnits++
+		}
+	}
+	assert.Equal(t, 1, readyUnits)
+	assert.Len(t, after.Storage.Files, len(before.Storage.Files))
+
+	keys := `'ssh-ed25519 AAAA test' 'ssh-ed25519 BBBB extra'`
+	assert.Equal(t, []string{
+		`sudo mkdir -p /home/core/.ssh/authorized_keys.d && printf '%s\n' ` + keys + ` | sudo tee /home/core/.ssh/authorized_keys.d/ignition >/dev/null && sudo chown -R core: /home/core/.ssh`,
+		`sudo mkdir -p /root/.ssh/authorized_keys.d && printf '%s\n' ` + keys + ` | sudo tee /root/.ssh/authorized_keys.d/ignition >/dev/null && sudo chown -R root: /root/.ssh`,
+		`sudo timedatectl set-timezone 'Europe/Berlin'`,
+	}, plan)
+
+	// regenerating without changes keeps the added key and the time zone
+	plan, err = RegenerateIgnition(mc, p, define.UpdateConfigOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, plan)
+	assert.Equal(t, after, readIgnition(t, mc))
+
+	ran := fakeGuest(t, nil)
+	plan = []string{"true", "sudo timedatectl set-timezone UTC"}
+	require.NoError(t, ApplyIgnitionPlan(mc, plan))
+	assert.Equal(t, plan, *ran)
+}
+
+func TestRegenerateIgnitionCerts(t *testing.T) {
+	cfg := ignition.Config{}
+	cfg.Storage.Files = []ignition.File{{
+		Node: ignition.Node{Path: define.UserCertsTargetPath + "/registry.example.com/ca.crt"},
+		FileEmbedded1: ignition.FileEmbedded1{
+			Contents: ignition.Resource{Source: ignition.EncodeDataURLPtr("it's a cert\n")},
+		},
+	}}
+	assert.Equal(t, []string{
+		`sudo mkdir -p /etc/containers/certs.d/registry.example.com && printf '%s' 'it'\''s a cert
+' | sudo tee /etc/containers/certs.d/registry.example.com/ca.crt >/dev/null`,
+	}, ignition.LiveApplyCommands(ignition.Config{}, cfg))
+	assert.Empty(t, ignition.LiveApplyCommands(cfg, cfg))
+}
+
+func TestRefreshFirstBootIgnition(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SSL_CERT_FILE", "")
+	t.Setenv("SSL_CERT_DIR", "")
+	registries, policy := writeGuestConfigFiles(t)
+
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "refresh-ignition")
+	require.NoError(t, Set(mc, p, define.SetOptions{GuestConfig: define.GuestConfigOptions{RegistriesConf: &registries}}))
+	before := readIgnition(t, mc)
+
+	// the config of providers that hand it to the hypervisor is left alone
+	require.NoError(t, refreshFirstBootIgnition(mc, p))
+	assert.Equal(t, before, readIgnition(t, mc))
+	assert.True(t, mc.GuestConfig.Pending)
+
+	p.IgnitionVsock = true
+	identity, err := machine.GetSSHIdentityPath(define.DefaultIdentityName)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(identity+".pub", []byte("ssh-ed25519 CCCC rotated"), 0644))
+	require.NoError(t, refreshFirstBootIgnition(mc, p))
+	after := readIgnition(t, mc)
+	for _, user := range after.Passwd.Users {
+		if len(user.SSHAuthorizedKeys) > 0 {
+			assert.Equal(t, ignition.SSHAuthorizedKey("ssh-ed25519 CCCC rotated"), user.SSHAuthorizedKeys[0])
+		}
+	}
+	paths := func(cfg ignition.Config) []string {
+		var paths []string
+		for _, f := range cfg.Storage.Files {
+			paths = append(paths, f.Path)
+		}
+		return paths
+	}
+	assert.Contains(t, paths(after), guestRegistriesConf)
+	reloaded, err := vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	assert.False(t, reloaded.GuestConfig.Pending)
+
+	// a file given again replaces the one in the config
+	require.NoError(t, Set(mc, p, define.SetOptions{GuestConfig: define.GuestConfigOptions{SignaturePolicy: &policy}}))
+	require.NoError(t, refreshFirstBootIgnition(mc, p))
+	after = readIgnition(t, mc)
+	assert.Len(t, after.Storage.Files, len(before.Storage.Files)+2)
+	assert.Contains(t, paths(after), guestPolicy)
+
+	// once the machine booted, ignition does not run anymore
+	empty := ""
+	require.NoError(t, Set(mc, p, define.SetOptions{GuestConfig: define.GuestConfigOptions{RegistriesConf: &empty}}))
+	mc.LastUp = time.Now()
+	require.NoError(t, refreshFirstBootIgnition(mc, p))
+	assert.Equal(t, after, readIgnition(t, mc))
+	assert.True(t, mc.GuestConfig.Pending)
+}
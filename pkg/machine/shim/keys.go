@@ -0,0 +1,205 @@
+package shim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/connection"
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/ignition"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// keysTimeout is how long each step of replacing the keys in the guest may take
+const keysTimeout = 30 * time.Second
+
+// generateIdentity creates an SSH key pair at the given path and returns its
+// public key.  It is a variable so that tests do not need ssh-keygen.
+var generateIdentity = machine.CreateSSHKeys
+
+// RegenerateKeys replaces the SSH identity of the running machine with a new
+// key pair of its own.  The new key is authorized in the guest next to the
+// old one and the old one is only revoked once logging in with the new key
+// works, after the configuration and the connections of the machine point at
+// the new identity.  The identity shared by the machines created on this
+// host is left in place for the other machines.
+func RegenerateKeys(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider) error {
+	state, err := mp.State(mc, false)
+	if err != nil {
+		return err
+	}
+	if state != machineDefine.Running {
+		return fmt.Errorf("machine %q must be running to regenerate its SSH keys: %w", mc.Name, machineDefine.ErrWrongState)
+	}
+
+	oldPub, err := os.ReadFile(mc.SSH.IdentityPath + ".pub")
+	if err != nil {
+		return err
+	}
+	oldKey := strings.TrimSpace(string(oldPub))
+	if keyBlob(oldKey) == "" {
+		return fmt.Errorf("public key %s is empty", mc.SSH.IdentityPath+".pub")
+	}
+
+	identity, err := mc.MachineIdentityPath()
+	if err != nil {
+		return err
+	}
+	newPath := identity.GetPath() + ".new"
+	removeNew := func() {
+		for _, path := range []string{newPath, newPath + ".pub"} {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				mc.Logger().Warnf("Removing %s: %v", path, err)
+			}
+		}
+	}
+	// a previous attempt may have left its key pair behind
+	removeNew()
+	newKey, err := generateIdentity(newPath)
+	if err != nil {
+		removeNew()
+		return fmt.Errorf("generating SSH keys: %w", err)
+	}
+
+	if out, err := runKeysCommand(mc, authorizeKeyCommand(mc.SSH.RemoteUsername, keyBlob(oldKey), newKey)); err != nil {
+		removeNew()
+		return fmt.Errorf("authorizing the new key in machine %q: %w: %s", mc.Name, err, out)
+	}
+	oldPath := mc.SSH.IdentityPath
+	mc.SSH.IdentityPath = newPath
+	_, err = runKeysCommand(mc, "true")
+	mc.SSH.IdentityPath = oldPath
+	if err != nil {
+		if out, err := runKeysCommand(mc, revokeKeyCommand(mc.SSH.RemoteUsername, keyBlob(newKey))); err != nil {
+			mc.Logger().Warnf("Revoking the new key in machine %q: %v: %s", mc.Name, err, out)
+		}
+		removeNew()
+		return fmt.Errorf("logging in to machine %q with the new key: %w", mc.Name, err)
+	}
+
+	// Only the private key is used to log in, so it is switched first
+	if err := os.Rename(newPath, identity.GetPath()); err != nil {
+		removeNew()
+		return err
+	}
+	if err := os.Rename(newPath+".pub", identity.GetPath()+".pub"); err != nil {
+		return err
+	}
+	mc.SSH.IdentityPath = identity.GetPath()
+	if err := replaceIgnitionKey(mc, oldKey, newKey); err != nil {
+		mc.Logger().Warnf("Updating the ignition file of machine %q: %v", mc.Name, err)
+	}
+	if err := mc.Write(); err != nil {
+		return err
+	}
+	if err := connection.UpdateConnectionPairIdentity(mc.Name, mc.SSH.IdentityPath); err != nil {
+		return fmt.Errorf("updating connections of machine %q: %w", mc.Name, err)
+	}
+
+	if out, err := runKeysCommand(mc, revokeKeyCommand(mc.SSH.RemoteUsername, keyBlob(oldKey))); err != nil {
+		return fmt.Errorf("the new key is in use but the old key is still authorized in machine %q: %w: %s", mc.Name, err, out)
+	}
+	return nil
+}
+
+func runKeysCommand(mc *vmconfigs.MachineConfig, command string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keysTimeout)
+	defer cancel()
+	return guestExec(ctx, mc, command)
+}
+
+// keyBlob returns the base64 encoded key of an authorized keys line, which
+// identifies it whatever its options and comment
+func keyBlob(key string) string {
+	fields := strings.Fields(key)
+	for i, field := range fields {
+		if strings.HasPrefix(field, "ssh-") || strings.HasPrefix(field, "ecdsa-") || strings.HasPrefix(field, "sk-") {
+			if i+1 < len(fields) {
+				return fields[i+1]
+			}
+		}
+	}
+	if len(fields) > 1 {
+		return fields[1]
+	}
+	return ""
+}
+
+// authorizedKeysFiles are the files the keys of user and root can be
+// authorized in: the authorized_keys file read by sshd and the fragments
+// ignition writes to authorized_keys.d
+func authorizedKeysFiles(user string) string {
+	homes := []string{"/root"}
+	if user != "root" {
+		homes = append(homes, "/home/"+user)
+	}
+	files := make([]string, 0, 2*len(homes))
+	for _, home := range homes {
+		files = append(files, ignition.ShellQuote(home)+"/.ssh/authorized_keys", ignition.ShellQuote(home)+"/.ssh/authorized_keys.d/*")
+	}
+	return strings.Join(files, " ")
+}
+
+// authorizeKeyCommand returns the command that authorizes newKey in every
+// authorized keys file of the guest that authorizes the key oldBlob.  It
+// fails when no file authorizes oldBlob.
+func authorizeKeyCommand(user, oldBlob, newKey string) string {
+	script := `found=; for f in ` + authorizedKeysFiles(user) + `; do [ -f "$f" ] && grep -qF "$1" "$f" || continue; [ -z "$(tail -c1 "$f")" ] || echo >> "$f"; printf '%s\n' "$2" >> "$f"; found=1; done; [ -n "$found" ]`
+	return "sudo sh -c " + ignition.ShellQuote(script) + " sh " + ignition.ShellQuote(oldBlob) + " " + ignition.ShellQuote(newKey)
+}
+
+// revokeKeyCommand returns the command that removes the key blob from every
+// authorized keys file of the guest.  The files are rewritten in place to
+// keep their owner and mode.
+func revokeKeyCommand(user, blob string) string {
+	script := `for f in ` + authorizedKeysFiles(user) + `; do [ -f "$f" ] && grep -qF "$1" "$f" || continue; grep -vF "$1" "$f" > "$f.tmp"; cat "$f.tmp" > "$f" && rm -f "$f.tmp" || exit 1; done`
+	return "sudo sh -c " + ignition.ShellQuote(script) + " sh " + ignition.ShellQuote(blob)
+}
+
+// replaceIgnitionKey authorizes newKey instead of oldKey in the ignition file
+// of the machine, so that the old key does not come back when the ignition
+// file is regenerated.  Machines without an ignition file are left alone.
+func replaceIgnitionKey(mc *vmconfigs.MachineConfig, oldKey, newKey string) error {
+	if mc.Provisioner == machineDefine.CloudInitProvisioner {
+		return nil
+	}
+	ignitionFile, err := mc.IgnitionFile()
+	if err != nil {
+		return err
+	}
+	content, err := ignitionFile.Read()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var cfg ignition.Config
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return err
+	}
+	oldBlob := keyBlob(oldKey)
+	replaced := false
+	for i := range cfg.Passwd.Users {
+		for j, key := range cfg.Passwd.Users[i].SSHAuthorizedKeys {
+			if keyBlob(string(key)) == oldBlob {
+				cfg.Passwd.Users[i].SSHAuthorizedKeys[j] = ignition.SSHAuthorizedKey(newKey)
+				replaced = true
+			}
+		}
+	}
+	if !replaced {
+		return nil
+	}
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ignitionFile.GetPath(), b, 0644)
+}
@@ -0,0 +1,113 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKeygen replaces ssh-keygen for the duration of the test
+func fakeKeygen(t *testing.T, pub string) {
+	t.Helper()
+	orig := generateIdentity
+	t.Cleanup(func() {
+		generateIdentity = orig
+	})
+	generateIdentity = func(path string) (string, error) {
+		if err := os.WriteFile(path, []byte("new private"), 0600); err != nil {
+			return "", err
+		}
+		return pub, os.WriteFile(path+".pub", []byte(pub+"\n"), 0644)
+	}
+}
+
+func TestKeyBlob(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "ssh-ed25519 AAAA user@host", want: "AAAA"},
+		{key: "ssh-ed25519 AAAA", want: "AAAA"},
+		{key: `no-pty,command="true" ssh-rsa BBBB comment`, want: "BBBB"},
+		{key: "ecdsa-sha2-nistp256 CCCC", want: "CCCC"},
+		{key: "", want: ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, keyBlob(tt.key), tt.key)
+	}
+}
+
+func TestRegenerateKeys(t *testing.T) {
+	fakeKeygen(t, "ssh-ed25519 BBBB new")
+	p, mc, _ := runningMachine(t, "regenkeys")
+	sharedIdentity := mc.SSH.IdentityPath
+
+	// the stopped machine cannot be reached
+	p.SetState(mc.Name, define.Stopped)
+	err := RegenerateKeys(mc, p)
+	require.ErrorIs(t, err, define.ErrWrongState)
+	p.SetState(mc.Name, define.Running)
+
+	ran := fakeGuest(t, nil)
+	require.NoError(t, RegenerateKeys(mc, p))
+
+	identity, err := mc.MachineIdentityPath()
+	require.NoError(t, err)
+	assert.Equal(t, identity.GetPath(), mc.SSH.IdentityPath)
+	pub, err := os.ReadFile(mc.SSH.IdentityPath + ".pub")
+	require.NoError(t, err)
+	assert.Equal(t, "ssh-ed25519 BBBB new\n", string(pub))
+	assert.NoFileExists(t, identity.GetPath()+".new")
+	// the other machines keep using the shared identity
+	assert.FileExists(t, sharedIdentity)
+
+	require.Len(t, *ran, 3)
+	assert.Contains(t, (*ran)[0], "'AAAA' 'ssh-ed25519 BBBB new'")
+	assert.Equal(t, "true", (*ran)[1])
+	assert.True(t, strings.HasSuffix((*ran)[2], " sh 'AAAA'"), (*ran)[2])
+
+	cons, err := ListConnections()
+	require.NoError(t, err)
+	for _, con := range cons {
+		if strings.HasPrefix(con.Name, "regenkeys") {
+			assert.Equal(t, mc.SSH.IdentityPath, con.Identity, con.Name)
+		}
+	}
+
+	ign, err := mc.IgnitionFile()
+	require.NoError(t, err)
+	content, err := ign.Read()
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "ssh-ed25519 BBBB new")
+	assert.NotContains(t, string(content), "ssh-ed25519 AAAA test")
+}
+
+func TestRegenerateKeysLoginFailure(t *testing.T) {
+	fakeKeygen(t, "ssh-ed25519 BBBB new")
+	p, mc, _ := runningMachine(t, "regenkeysfail")
+	oldIdentity := mc.SSH.IdentityPath
+	ran := fakeGuest(t, map[string]error{"true": errors.New("permission denied")})
+
+	err := RegenerateKeys(mc, p)
+	require.ErrorContains(t, err, "permission denied")
+	assert.Equal(t, oldIdentity, mc.SSH.IdentityPath)
+	identity, err := mc.MachineIdentityPath()
+	require.NoError(t, err)
+	assert.NoFileExists(t, identity.GetPath()+".new")
+	assert.NoFileExists(t, identity.GetPath())
+
+	// the new key is revoked again
+	require.Len(t, *ran, 3)
+	assert.True(t, strings.HasSuffix((*ran)[2], " sh 'BBBB'"), (*ran)[2])
+	pub, err := machine.GetSSHKeys(oldIdentity)
+	require.NoError(t, err)
+	assert.Equal(t, "ssh-ed25519 AAAA test", pub)
+}
@@ -0,0 +1,104 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type capturingLogger struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (l *capturingLogger) log(level, format string, args ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines = append(l.lines, level+": "+fmt.Sprintf(format, args...))
+}
+
+func (l *capturingLogger) Debugf(format string, args ...interface{}) { l.log("debug", format, args...) }
+func (l *capturingLogger) Infof(format string, args ...interface{})  { l.log("info", format, args...) }
+func (l *capturingLogger) Warnf(format string, args ...interface{})  { l.log("warn", format, args...) }
+func (l *capturingLogger) Errorf(format string, args ...interface{}) { l.log("error", format, args...) }
+
+func (l *capturingLogger) contains(level, substr string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, level+": ") && strings.Contains(line, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// captureLogs makes the messages about mc go to a capturing logger
+func captureLogs(mc *vmconfigs.MachineConfig) *capturingLogger {
+	l := &capturingLogger{}
+	mc.SetLogger(l)
+	return l
+}
+
+func TestMachineLogger(t *testing.T) {
+	fakeGuest(t, map[string]error{"false": errors.New("exit status 1")})
+	_, mc, _ := runningMachine(t, "logger", vmconfigs.Hook{Command: "false"})
+	_, other, _ := runningMachine(t, "logger-other", vmconfigs.Hook{Command: "false"})
+	l := captureLogs(mc)
+	otherLogs := captureLogs(other)
+
+	// each machine logs to its own logger
+	var wg sync.WaitGroup
+	for _, m := range []*vmconfigs.MachineConfig{mc, other} {
+		wg.Add(1)
+		go func(m *vmconfigs.MachineConfig) {
+			defer wg.Done()
+			_, err := runHooks(m, "pre-stop", m.Hooks.PreStop, false)
+			assert.NoError(t, err)
+		}(m)
+	}
+	wg.Wait()
+	assert.True(t, l.contains("warn", `pre-stop hook "false" of machine "logger" failed: exit status 1`), l.lines)
+	assert.True(t, l.contains("debug", `Machine "logger" pre-stop hook "false" output`), l.lines)
+	assert.False(t, l.contains("warn", `machine "logger-other"`), l.lines)
+	assert.True(t, otherLogs.contains("warn", `pre-stop hook "false" of machine "logger-other" failed`), otherLogs.lines)
+	assert.False(t, otherLogs.contains("warn", `machine "logger" failed`), otherLogs.lines)
+
+	origUpdate := updateSockService
+	defer func() { updateSockService = origUpdate }()
+	updateSockService = func(*vmconfigs.MachineConfig) error { return errors.New("ssh failed") }
+	p, mc, _ := runningMachine(t, "logger-rootful")
+	l = captureLogs(mc)
+	require.NoError(t, SetRootful(mc, p, true))
+	assert.True(t, l.contains("warn", `socket service of machine "logger-rootful" is updated on its next start`), l.lines)
+}
+
+func TestMachineLoggerErrors(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "logger-errors")
+	setRestartPolicy(t, mc, define.RestartAlways)
+	p.Fail("StartVM", errors.New("boom"))
+	l := captureLogs(mc)
+
+	cancel, done := runMonitor(mc, p, dirs, MonitorOptions{})
+	defer cancel()
+	select {
+	case err := <-done:
+		require.ErrorContains(t, err, "not restarting it anymore")
+	case <-time.After(5 * time.Second):
+		t.Fatal("monitor did not give up")
+	}
+	assert.True(t, l.contains("warn", `Restarting machine of machine "logger-errors"`), l.lines)
+	assert.True(t, l.contains("error", `Restarting machine of machine "logger-errors": boom`), l.lines)
+}
@@ -0,0 +1,85 @@
+package shim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/ignition"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// guestStream runs command in the guest and writes its output to w as it
+// comes.  It is a variable so that tests can fake the guest.
+var guestStream = func(ctx context.Context, mc *vmconfigs.MachineConfig, command string, w io.Writer) error {
+	return machine.CommonSSHWithWriter(ctx, mc.SSH.RemoteUsername, mc.SSH.IdentityPath, mc.SSH.Port, []string{command}, w)
+}
+
+// Logs writes the journal of the running machine to w.  The console log of
+// the last boot is written instead when the machine is not running, which
+// tells why a machine that cannot be reached did not boot.
+func Logs(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, opts machineDefine.LogsOptions, w io.Writer) error {
+	state, err := mp.State(mc, false)
+	if err != nil {
+		return err
+	}
+	if state == machineDefine.Running {
+		return guestStream(context.Background(), mc, journalCommand(opts), w)
+	}
+
+	if opts.Follow || opts.Since != "" || opts.Unit != "" {
+		return fmt.Errorf("machine %q is not running, only the console log of its last boot can be shown: %w", mc.Name, machineDefine.ErrWrongState)
+	}
+	logFile, err := mc.LogFile()
+	if err != nil {
+		return err
+	}
+	var lines []string
+	if opts.Tail >= 0 {
+		lines, err = tailLines(logFile.GetPath(), opts.Tail)
+	} else {
+		var content []byte
+		content, err = os.ReadFile(logFile.GetPath())
+		lines = strings.SplitAfter(string(content), "\n")
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("machine %q is not running and has no console log: %w", mc.Name, machineDefine.ErrWrongState)
+	}
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if opts.Tail >= 0 {
+			line += "\n"
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// journalCommand returns the journalctl command line that shows the entries
+// of the journal of the guest selected by opts
+func journalCommand(opts machineDefine.LogsOptions) string {
+	args := []string{"sudo", "journalctl", "--no-pager"}
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+	if opts.Since != "" {
+		args = append(args, "--since", ignition.ShellQuote(opts.Since))
+	}
+	if opts.Tail >= 0 {
+		args = append(args, "--lines", strconv.Itoa(opts.Tail))
+	}
+	if opts.Unit != "" {
+		args = append(args, "--unit", ignition.ShellQuote(opts.Unit))
+	}
+	return strings.Join(args, " ")
+}
@@ -0,0 +1,74 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalCommand(t *testing.T) {
+	tests := []struct {
+		opts define.LogsOptions
+		want string
+	}{
+		{opts: define.LogsOptions{Tail: -1}, want: "sudo journalctl --no-pager"},
+		{opts: define.LogsOptions{Follow: true, Tail: 10}, want: "sudo journalctl --no-pager --follow --lines 10"},
+		{opts: define.LogsOptions{Since: "-10m", Tail: -1, Unit: "podman.socket"}, want: "sudo journalctl --no-pager --since '-10m' --unit 'podman.socket'"},
+		{opts: define.LogsOptions{Since: "it's; reboot", Tail: -1}, want: `sudo journalctl --no-pager --since 'it'\''s; reboot'`},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, journalCommand(tt.opts))
+	}
+}
+
+func TestLogs(t *testing.T) {
+	var ran []string
+	origStream := guestStream
+	defer func() {
+		guestStream = origStream
+	}()
+	guestStream = func(_ context.Context, _ *vmconfigs.MachineConfig, command string, w io.Writer) error {
+		ran = append(ran, command)
+		_, err := io.WriteString(w, "journal\n")
+		return err
+	}
+
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "logs")
+
+	// a stopped machine without a console log has nothing to show
+	var out bytes.Buffer
+	err := Logs(mc, p, define.LogsOptions{Tail: -1}, &out)
+	assert.ErrorIs(t, err, define.ErrWrongState)
+
+	logFile, err := mc.LogFile()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(logFile.GetPath(), []byte("one\ntwo\nthree\n"), 0644))
+	require.NoError(t, Logs(mc, p, define.LogsOptions{Tail: -1}, &out))
+	assert.Equal(t, "one\ntwo\nthree\n", out.String())
+
+	out.Reset()
+	require.NoError(t, Logs(mc, p, define.LogsOptions{Tail: 2}, &out))
+	assert.Equal(t, "two\nthree\n", out.String())
+
+	// the journal is only available while the machine runs
+	err = Logs(mc, p, define.LogsOptions{Follow: true, Tail: -1}, &out)
+	assert.ErrorIs(t, err, define.ErrWrongState)
+	assert.Empty(t, ran)
+
+	p.SetState(mc.Name, define.Running)
+	out.Reset()
+	require.NoError(t, Logs(mc, p, define.LogsOptions{Unit: "sshd.service", Tail: -1}, &out))
+	assert.Equal(t, "journal\n", out.String())
+	assert.Equal(t, []string{"sudo journalctl --no-pager --unit 'sshd.service'"}, ran)
+}
@@ -0,0 +1,371 @@
+package shim
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	psutil "github.com/shirou/gopsutil/v3/process"
+	"github.com/sirupsen/logrus"
+)
+
+// A machine with a restart policy other than define.RestartNo or with
+// ClockSync set is watched by a monitor, a `podman machine monitor` process
+// started with the machine and stopped by podman machine stop.  The monitor
+// restarts gvproxy or the whole machine when they fail, and gives up when it
+// had to restart them monitorMaxRestarts times within monitorRestartWindow.
+// It keeps the clock of the machine in sync, see clock.go.
+
+const (
+	// monitorInterval is how often the monitor checks the machine
+	monitorInterval      = 5 * time.Second
+	monitorMaxRestarts   = 3
+	monitorRestartWindow = 10 * time.Minute
+	// processStartTimeSlack is how much later than its pid file a
+	// process may seem to have started, as some platforms only know the
+	// start time of processes to the second
+	processStartTimeSlack = time.Second
+)
+
+// spawnMonitor starts the monitor of the machine.  It is a variable so that
+// tests do not start podman.
+var spawnMonitor = startMonitorProcess
+
+// Restart targets passed to MonitorOptions.OnRestart
+const (
+	RestartTargetGvproxy = "gvproxy"
+	RestartTargetMachine = "machine"
+)
+
+// MonitorOptions configure Monitor
+type MonitorOptions struct {
+	// Interval is how often the machine is checked, every five seconds
+	// when zero
+	Interval time.Duration
+	// OnRestart is called after the monitor restarted target,
+	// RestartTargetGvproxy or RestartTargetMachine, with the error of the
+	// restart
+	OnRestart func(target string, err error)
+	// OnClockSync is called after the monitor stepped the clock of the
+	// machine, which was skew ahead of the host, with the error of the step
+	OnClockSync func(skew time.Duration, err error)
+}
+
+// Health tells whether the machine can be used
+func Health(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider) (define.Health, error) {
+	state, err := mp.State(mc, false)
+	if err != nil {
+		return "", err
+	}
+	return health(mc, mp, state), nil
+}
+
+func health(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, state define.Status) define.Health {
+	switch {
+	case mc.Starting || state == define.Starting:
+		return define.HealthStarting
+	case state != define.Running:
+		return define.HealthStopped
+	case !mp.UseProviderNetworkSetup() && !gvproxyRunning(mc):
+		return define.HealthUnhealthy
+	case !isListening(mc.SSH.Port, mc.Logger()):
+		return define.HealthUnhealthy
+	}
+	return define.HealthHealthy
+}
+
+// gvproxyRunning reports whether the gvproxy of the machine runs
+func gvproxyRunning(mc *vmconfigs.MachineConfig) bool {
+	pidFile, err := mc.RunningGVProxyPidFile()
+	if err != nil {
+		return false
+	}
+	_, alive := processAlive(pidFile)
+	return alive
+}
+
+// processAlive returns the pid written in pidFile and whether it runs
+func processAlive(pidFile *define.VMFile) (int, bool) {
+	pid, err := pidFile.ReadPIDFrom()
+	if err != nil {
+		return 0, false
+	}
+	alive, err := psutil.PidExists(int32(pid))
+	return pid, err == nil && alive
+}
+
+// monitorAlive returns the pid written in the pid file of a monitor and
+// whether the monitor runs.  A stale pid file may hold the pid of another
+// process by now, which is told apart by having started after the pid file
+// was written.
+func monitorAlive(pidFile *define.VMFile) (int, bool) {
+	pid, alive := processAlive(pidFile)
+	if !alive {
+		return pid, false
+	}
+	info, err := os.Stat(pidFile.GetPath())
+	if err != nil {
+		return pid, false
+	}
+	p, err := psutil.NewProcess(int32(pid))
+	if err != nil {
+		return pid, false
+	}
+	created, err := p.CreateTime()
+	if err != nil {
+		return pid, false
+	}
+	return pid, time.UnixMilli(created).Before(info.ModTime().Add(processStartTimeSlack))
+}
+
+// Monitor watches the running machine, restarts gvproxy or the machine
+// according to the restart policy of the machine and keeps its clock in sync
+// when ClockSync is set.  It returns when the machine stops and is not to be
+// restarted, when the machine is no longer to be monitored, when it gives up
+// restarting and when ctx is done.
+func Monitor(ctx context.Context, mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, dirs *define.MachineDirs, opts MonitorOptions) error {
+	interval := opts.Interval
+	if interval == 0 {
+		interval = monitorInterval
+	}
+
+	var restarts []time.Time
+	restart := func(target string, f func() error) error {
+		now := time.Now()
+		recent := restarts[:0]
+		for _, t := range restarts {
+			if now.Sub(t) < monitorRestartWindow {
+				recent = append(recent, t)
+			}
+		}
+		restarts = recent
+		if len(restarts) >= monitorMaxRestarts {
+			return fmt.Errorf("machine %q failed %d times within %s, not restarting it anymore", mc.Name, len(restarts), monitorRestartWindow)
+		}
+		restarts = append(restarts, now)
+
+		mc.Logger().Warnf("Restarting %s of machine %q", target, mc.Name)
+		err := f()
+		if err != nil {
+			mc.Logger().Errorf("Restarting %s of machine %q: %v", target, mc.Name, err)
+		}
+		if opts.OnRestart != nil {
+			opts.OnRestart(target, err)
+		}
+		return nil
+	}
+
+	// the clock is first checked once the machine is reachable
+	var lastClockCheck time.Time
+	lastTick := time.Now()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		now := time.Now()
+		slept := hostSlept(lastTick, now)
+		lastTick = now
+
+		// the settings may have been changed with podman machine set
+		if err := mc.Refresh(); err != nil {
+			return err
+		}
+		if !mc.Monitored() {
+			return nil
+		}
+		if mc.Starting {
+			continue
+		}
+		state, err := mp.State(mc, false)
+		if err != nil {
+			mc.Logger().Warnf("Checking the state of machine %q: %v", mc.Name, err)
+			continue
+		}
+
+		switch {
+		case state == define.Stopped:
+			if mc.RestartPolicy != define.RestartAlways {
+				return nil
+			}
+			if err := restart(RestartTargetMachine, func() error {
+				return restartMachine(mc, mp, dirs, false)
+			}); err != nil {
+				return err
+			}
+		case state == define.Running && mc.RestartPolicy.Monitored() && !mp.UseProviderNetworkSetup() && !gvproxyRunning(mc):
+			if err := restart(RestartTargetGvproxy, func() error {
+				return restartNetworking(mc, mp)
+			}); err != nil {
+				return err
+			}
+			// not every guest reconnects to a new gvproxy, QEMU's
+			// network device for one does not
+			if mc.RestartPolicy == define.RestartAlways && !guestResponds(mc, mp) {
+				if err := restart(RestartTargetMachine, func() error {
+					return restartMachine(mc, mp, dirs, true)
+				}); err != nil {
+					return err
+				}
+			}
+		case state == define.Running && mc.ClockSync && (slept || now.Sub(lastClockCheck) >= clockCheckInterval) && guestReachable(mc):
+			if slept {
+				mc.Logger().Infof("Host slept, checking the clock of machine %q", mc.Name)
+			}
+			lastClockCheck = now
+			skew, err := SyncClock(mc)
+			if err != nil {
+				mc.Logger().Warnf("Syncing the clock of machine %q: %v", mc.Name, err)
+			}
+			if opts.OnClockSync != nil && skew.Abs() > maxClockSkew {
+				opts.OnClockSync(skew, err)
+			}
+		}
+	}
+}
+
+// guestResponds reports whether commands can be run in the guest over SSH
+func guestResponds(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider) bool {
+	stateF := func() (define.Status, error) {
+		return mp.State(mc, true)
+	}
+	connected, _, err := readinessCheck(mc, 4, time.Second, stateF)
+	return err == nil && connected
+}
+
+// restartNetworking starts gvproxy again for the running machine, which
+// publishes its ports and forwards its API again
+func restartNetworking(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider) error {
+	pidFile, err := mc.RunningGVProxyPidFile()
+	if err != nil {
+		return err
+	}
+	if err := machine.CleanupGVProxy(*pidFile); err != nil {
+		return err
+	}
+	_, _, _, err = startNetworking(mc, mp)
+	return err
+}
+
+// restartMachine starts the machine again, after stopping it when it is
+// running but cannot be reached
+func restartMachine(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, dirs *define.MachineDirs, running bool) error {
+	if running {
+		if _, err := stop(mc, mp, dirs, machine.StopOptions{Hard: true, NoHooks: true}); err != nil {
+			return err
+		}
+	} else if !mp.UseProviderNetworkSetup() {
+		// gvproxy outlives a machine that crashed
+		pidFile, err := mc.GVProxyPidFile()
+		if err != nil {
+			return err
+		}
+		if err := machine.CleanupGVProxy(*pidFile); err != nil {
+			return err
+		}
+	}
+
+	mc.Starting = true
+	if err := mc.Write(); err != nil {
+		mc.Logger().Errorf("%v", err)
+	}
+	_, err := start(mc, mp, dirs, machine.StartOptions{NoInfo: true, Quiet: true})
+	mc.Starting = false
+	if werr := mc.Write(); werr != nil {
+		mc.Logger().Errorf("%v", werr)
+	}
+	mc.RecordOperationResult(vmconfigs.OperationStart, err)
+	return err
+}
+
+// startMonitorProcess starts `podman machine monitor` for the machine,
+// replacing a monitor left over from an earlier start
+func startMonitorProcess(mc *vmconfigs.MachineConfig) error {
+	if err := stopMonitor(mc); err != nil {
+		return err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := []string{}
+	if logrus.IsLevelEnabled(logrus.DebugLevel) {
+		args = append(args, "--log-level=debug")
+	}
+	args = append(args, "machine", "monitor", mc.Name)
+
+	logFile, err := mc.MonitorLogFile()
+	if err != nil {
+		return err
+	}
+	log, err := os.OpenFile(logFile.GetPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	cmd.SysProcAttr = monitorSysProcAttr()
+	mc.Logger().Debugf("Starting monitor of machine %q: %s %v", mc.Name, executable, args)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting monitor of machine %q: %w", mc.Name, err)
+	}
+
+	pidFile, err := mc.MonitorPidFile()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(pidFile.GetPath(), []byte(strconv.Itoa(cmd.Process.Pid)), 0644); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// stopMonitor stops the monitor of the machine, unless the monitor itself
+// is stopping the machine
+func stopMonitor(mc *vmconfigs.MachineConfig) error {
+	pidFile, err := mc.MonitorPidFile()
+	if err != nil {
+		return err
+	}
+	pid, alive := monitorAlive(pidFile)
+	if pid == os.Getpid() {
+		return nil
+	}
+	if alive {
+		p, err := os.FindProcess(pid)
+		if err == nil {
+			if err := p.Kill(); err != nil {
+				mc.Logger().Warnf("Stopping monitor of machine %q: %v", mc.Name, err)
+			}
+		}
+	}
+	return pidFile.Delete()
+}
+
+// ReleaseMonitor removes the pid file of the monitor of the machine when it
+// is the calling process, as the monitor exits
+func ReleaseMonitor(mc *vmconfigs.MachineConfig) {
+	pidFile, err := mc.MonitorPidFile()
+	if err != nil {
+		return
+	}
+	if pid, _ := processAlive(pidFile); pid == os.Getpid() {
+		if err := pidFile.Delete(); err != nil {
+			mc.Logger().Warnf("Removing pid file of monitor of machine %q: %v", mc.Name, err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMonitor replaces the monitor process for the duration of the test and
+// returns the names of the machines it was started for
+func fakeMonitor(t *testing.T) *[]string {
+	t.Helper()
+	var spawned []string
+	orig := spawnMonitor
+	t.Cleanup(func() { spawnMonitor = orig })
+	spawnMonitor = func(mc *vmconfigs.MachineConfig) error {
+		spawned = append(spawned, mc.Name)
+		return nil
+	}
+	return &spawned
+}
+
+func setRestartPolicy(t *testing.T, mc *vmconfigs.MachineConfig, policy define.RestartPolicy) {
+	t.Helper()
+	mc.RestartPolicy = policy
+	require.NoError(t, mc.Write())
+}
+
+func runMonitor(mc *vmconfigs.MachineConfig, p *fakeprovider.Provider, dirs *define.MachineDirs, opts MonitorOptions) (context.CancelFunc, <-chan error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	opts.Interval = 10 * time.Millisecond
+	done := make(chan error, 1)
+	go func() {
+		done <- Monitor(ctx, mc, p, dirs, opts)
+	}()
+	return cancel, done
+}
+
+func TestHealth(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "health")
+
+	h, err := Health(mc, p)
+	require.NoError(t, err)
+	assert.Equal(t, define.HealthStopped, h)
+
+	p.SetState(mc.Name, define.Running)
+	h, err = Health(mc, p)
+	require.NoError(t, err)
+	assert.Equal(t, define.HealthUnhealthy, h, "SSH is not listening")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	mc.SSH.Port = listener.Addr().(*net.TCPAddr).Port
+	h, err = Health(mc, p)
+	require.NoError(t, err)
+	assert.Equal(t, define.HealthHealthy, h)
+
+	mc.Starting = true
+	h, err = Health(mc, p)
+	require.NoError(t, err)
+	assert.Equal(t, define.HealthStarting, h)
+}
+
+func TestMonitorRestartsMachine(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "monitor-always")
+	setRestartPolicy(t, mc, define.RestartAlways)
+
+	var targets []string
+	cancel, done := runMonitor(mc, p, dirs, MonitorOptions{
+		OnRestart: func(target string, err error) {
+			assert.NoError(t, err)
+			targets = append(targets, target)
+		},
+	})
+	require.Eventually(t, func() bool { return p.Called("StartVM") == 1 }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{RestartTargetMachine}, targets)
+	assert.Nil(t, mc.LastError)
+}
+
+func TestMonitorGivesUp(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "monitor-giveup")
+	setRestartPolicy(t, mc, define.RestartAlways)
+	p.Fail("StartVM", errors.New("boom"))
+
+	cancel, done := runMonitor(mc, p, dirs, MonitorOptions{})
+	defer cancel()
+	select {
+	case err := <-done:
+		require.ErrorContains(t, err, "not restarting it anymore")
+	case <-time.After(5 * time.Second):
+		t.Fatal("monitor did not give up")
+	}
+	assert.Equal(t, monitorMaxRestarts, p.Called("StartVM"))
+	require.NotNil(t, mc.LastError)
+	assert.Equal(t, vmconfigs.OperationStart, mc.LastError.Operation)
+}
+
+func TestMonitorExits(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "monitor-exits")
+
+	// a stopped machine is only restarted with the always policy
+	setRestartPolicy(t, mc, define.RestartNetwork)
+	cancel, done := runMonitor(mc, p, dirs, MonitorOptions{})
+	defer cancel()
+	require.NoError(t, <-done)
+	assert.Zero(t, p.Called("StartVM"))
+
+	// the monitor exits once the policy is set to no
+	p.SetState(mc.Name, define.Running)
+	setRestartPolicy(t, mc, define.RestartNo)
+	cancel, done = runMonitor(mc, p, dirs, MonitorOptions{})
+	defer cancel()
+	require.NoError(t, <-done)
+}
+
+func TestStartStopMonitor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the monitor is faked with sleep")
+	}
+	spawned := fakeMonitor(t)
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "start-monitor")
+
+	_, err := Start(mc, p, dirs, machine.StartOptions{NoInfo: true})
+	require.NoError(t, err)
+	assert.Empty(t, *spawned, "machines without a restart policy are not monitored")
+	_, err = Stop(mc, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+
+	setRestartPolicy(t, mc, define.RestartNetwork)
+	_, err = Start(mc, p, dirs, machine.StartOptions{NoInfo: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{mc.Name}, *spawned)
+
+	// stopping the machine kills its monitor
+	monitorProcess := exec.Command("sleep", "60")
+	require.NoError(t, monitorProcess.Start())
+	waited := make(chan error, 1)
+	go func() { waited <- monitorProcess.Wait() }()
+	pidFile, err := mc.MonitorPidFile()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(pidFile.GetPath(), []byte(strconv.Itoa(monitorProcess.Process.Pid)), 0644))
+
+	_, err = Stop(mc, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("monitor was not killed")
+	}
+	assert.NoFileExists(t, pidFile.GetPath())
+}
+
+func TestStopMonitorStalePidFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the other process is faked with sleep")
+	}
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "monitor-stale")
+
+	// the pid of a stale pid file was reused by a process started later
+	other := exec.Command("sleep", "60")
+	require.NoError(t, other.Start())
+	t.Cleanup(func() {
+		_ = other.Process.Kill()
+		_ = other.Wait()
+	})
+	pidFile, err := mc.MonitorPidFile()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(pidFile.GetPath(), []byte(strconv.Itoa(other.Process.Pid)), 0644))
+	written := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(pidFile.GetPath(), written, written))
+
+	require.NoError(t, stopMonitor(mc))
+	assert.NoFileExists(t, pidFile.GetPath())
+	assert.NoError(t, other.Process.Signal(syscall.Signal(0)), "the process was killed")
+}
+
+func TestSetRestartPolicy(t *testing.T) {
+	spawned := fakeMonitor(t)
+	p, mc, _ := runningMachine(t, "set-restart")
+
+	policy := "always"
+	require.NoError(t, Set(mc, p, define.SetOptions{RestartPolicy: &policy}))
+	assert.Equal(t, define.RestartAlways, mc.RestartPolicy)
+	assert.Equal(t, []string{mc.Name}, *spawned, "the running machine is monitored")
+
+	policy = "sometimes"
+	require.Error(t, Set(mc, p, define.SetOptions{RestartPolicy: &policy}))
+	assert.Equal(t, define.RestartAlways, mc.RestartPolicy)
+
+	policy = "no"
+	require.NoError(t, Set(mc, p, define.SetOptions{RestartPolicy: &policy}))
+	assert.Equal(t, define.RestartNo, mc.RestartPolicy)
+	assert.Len(t, *spawned, 1)
+}
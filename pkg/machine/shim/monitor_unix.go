@@ -0,0 +1,11 @@
+//go:build !windows
+
+package shim
+
+import "syscall"
+
+// monitorSysProcAttr starts the monitor in its own session, detached from
+// the terminal of the command that started the machine
+func monitorSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
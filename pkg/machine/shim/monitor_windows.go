@@ -0,0 +1,13 @@
+package shim
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// monitorSysProcAttr starts the monitor detached from the console of the
+// command that started the machine
+func monitorSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: windows.CREATE_NEW_PROCESS_GROUP | windows.DETACHED_PROCESS}
+}
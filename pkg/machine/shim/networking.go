@@ -27,17 +27,16 @@ var (
 	ErrSSHNotListening = errors.New("machine is not listening on ssh port")
 )
 
-func startHostForwarder(mc *vmconfigs.MachineConfig, provider vmconfigs.VMProvider, dirs *define.MachineDirs, hostSocks []string) error {
-	forwardUser := mc.SSH.RemoteUsername
-
-	// TODO should this go up the stack higher or
-	// the guestSock is "inside" the guest machine
-	guestSock := fmt.Sprintf(defaultGuestSock, mc.HostUser.UID)
-	if mc.HostUser.Rootful {
-		guestSock = "/run/podman/podman.sock"
-		forwardUser = "root"
+// guestAPISocket returns the socket of the rootful or rootless Podman API in
+// the guest and the user forwarding it
+func guestAPISocket(mc *vmconfigs.MachineConfig, rootful bool) (string, string) {
+	if rootful {
+		return "/run/podman/podman.sock", "root"
 	}
+	return fmt.Sprintf(defaultGuestSock, mc.HostUser.UID), mc.SSH.RemoteUsername
+}
 
+func startHostForwarder(mc *vmconfigs.MachineConfig, provider vmconfigs.VMProvider, dirs *define.MachineDirs, hostSocks []string, secondarySock string) error {
 	cfg, err := config.Default()
 	if err != nil {
 		return err
@@ -50,17 +49,21 @@ func startHostForwarder(mc *vmconfigs.MachineConfig, provider vmconfigs.VMProvid
 
 	cmd := gvproxy.NewGvproxyCommand()
 
-	// GvProxy PID file path is now derived
-	runDir := dirs.RuntimeDir
-	cmd.PidFile = filepath.Join(runDir.GetPath(), "gvproxy.pid")
+	// GvProxy PID file path is derived from the machine name
+	pidFile, err := mc.GVProxyPidFile()
+	if err != nil {
+		return err
+	}
+	cmd.PidFile = pidFile.GetPath()
 
 	if logrus.IsLevelEnabled(logrus.DebugLevel) {
-		cmd.LogFile = filepath.Join(runDir.GetPath(), "gvproxy.log")
+		cmd.LogFile = filepath.Join(dirs.RuntimeDir.GetPath(), mc.Name+"-gvproxy.log")
 	}
 
 	cmd.SSHPort = mc.SSH.Port
 
 	// Windows providers listen on multiple sockets since they do not involve links
+	guestSock, forwardUser := guestAPISocket(mc, mc.HostUser.Rootful)
 	for _, hostSock := range hostSocks {
 		cmd.AddForwardSock(hostSock)
 		cmd.AddForwardDest(guestSock)
@@ -68,9 +71,30 @@ func startHostForwarder(mc *vmconfigs.MachineConfig, provider vmconfigs.VMProvid
 		cmd.AddForwardIdentity(mc.SSH.IdentityPath)
 	}
 
+	// the API of the other mode is forwarded too, so that clients can
+	// switch to it without restarting the machine
+	if secondarySock != "" {
+		guestSock, forwardUser := guestAPISocket(mc, !mc.HostUser.Rootful)
+		cmd.AddForwardSock(secondarySock)
+		cmd.AddForwardDest(guestSock)
+		cmd.AddForwardUser(forwardUser)
+		cmd.AddForwardIdentity(mc.SSH.IdentityPath)
+	}
+
+	// the ports of the machine are published through the API of gvproxy
+	var apiNetwork, apiAddress string
+	if len(mc.Network.Ports) > 0 {
+		endpoint, network, address, err := gvproxyAPIEndpoint(mc)
+		if err != nil {
+			return err
+		}
+		cmd.AddEndpoint(endpoint)
+		apiNetwork, apiAddress = network, address
+	}
+
 	if logrus.IsLevelEnabled(logrus.DebugLevel) {
 		cmd.Debug = true
-		logrus.Debug(cmd)
+		mc.Logger().Debugf("%v", cmd)
 	}
 
 	// This allows a provider to perform additional setup as well as
@@ -81,35 +105,51 @@ func startHostForwarder(mc *vmconfigs.MachineConfig, provider vmconfigs.VMProvid
 
 	c := cmd.Cmd(binary)
 
-	logrus.Debugf("gvproxy command-line: %s %s", binary, strings.Join(cmd.ToCmdline(), " "))
+	mc.Logger().Debugf("gvproxy command-line: %s %s", binary, strings.Join(cmd.ToCmdline(), " "))
 	if err := c.Start(); err != nil {
 		return fmt.Errorf("unable to execute: %q: %w", cmd.ToCmdline(), err)
 	}
 
+	if len(mc.Network.Ports) > 0 {
+		if err := publishPorts(mc, apiNetwork, apiAddress); err != nil {
+			if kerr := c.Process.Kill(); kerr != nil {
+				mc.Logger().Errorf("stopping gvproxy: %v", kerr)
+			}
+			return err
+		}
+	}
+
 	return nil
 }
 
-func startNetworking(mc *vmconfigs.MachineConfig, provider vmconfigs.VMProvider) (string, machine.APIForwardingState, error) {
+// startNetworking starts gvproxy and forwards the Podman API of the machine.
+// It returns the socket of the API of the mode the machine defaults to and
+// the socket of the other mode, empty when it is not forwarded.
+func startNetworking(mc *vmconfigs.MachineConfig, provider vmconfigs.VMProvider) (string, string, machine.APIForwardingState, error) {
 	// Provider has its own networking code path (e.g. WSL)
 	if provider.UseProviderNetworkSetup() {
-		return "", 0, provider.StartNetworking(mc, nil)
+		return "", "", 0, provider.StartNetworking(mc, nil)
 	}
 
 	dirs, err := machine.GetMachineDirs(provider.VMType())
 	if err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 
-	hostSocks, forwardSock, forwardingState, err := setupMachineSockets(mc.Name, dirs)
+	hostSocks, forwardSock, forwardingState, err := setupMachineSockets(mc, dirs)
+	if err != nil {
+		return "", "", 0, err
+	}
+	secondarySock, err := setupSecondaryMachineSocket(mc)
 	if err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 
-	if err := startHostForwarder(mc, provider, dirs, hostSocks); err != nil {
-		return "", 0, err
+	if err := startHostForwarder(mc, provider, dirs, hostSocks, secondarySock); err != nil {
+		return "", "", 0, err
 	}
 
-	return forwardSock, forwardingState, nil
+	return forwardSock, secondarySock, forwardingState, nil
 }
 
 // conductVMReadinessCheck checks to make sure the machine is in the proper state
@@ -128,7 +168,7 @@ func conductVMReadinessCheck(mc *vmconfigs.MachineConfig, maxBackoffs int, backo
 			sshError = ErrNotRunning
 			continue
 		}
-		if !isListening(mc.SSH.Port) {
+		if !isListening(mc.SSH.Port, mc.Logger()) {
 			sshError = ErrSSHNotListening
 			continue
 		}
@@ -142,7 +182,7 @@ func conductVMReadinessCheck(mc *vmconfigs.MachineConfig, maxBackoffs int, backo
 		// the underlying source of the issue remains unknown.
 
 		if sshError = machine.CommonSSHSilent(mc.SSH.RemoteUsername, mc.SSH.IdentityPath, mc.Name, mc.SSH.Port, []string{"true"}); sshError != nil {
-			logrus.Debugf("SSH readiness check for machine failed: %v", sshError)
+			mc.Logger().Debugf("SSH readiness check for machine failed: %v", sshError)
 			continue
 		}
 		connected = true
@@ -152,14 +192,14 @@ func conductVMReadinessCheck(mc *vmconfigs.MachineConfig, maxBackoffs int, backo
 	return
 }
 
-func isListening(port int) bool {
+func isListening(port int, logger define.Logger) bool {
 	// Check if we can dial it
 	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", "127.0.0.1", port), 10*time.Millisecond)
 	if err != nil {
 		return false
 	}
 	if err := conn.Close(); err != nil {
-		logrus.Error(err)
+		logger.Errorf("%v", err)
 	}
 	return true
 }
@@ -10,11 +10,11 @@ import (
 
 	"github.com/containers/podman/v5/pkg/machine"
 	"github.com/containers/podman/v5/pkg/machine/define"
-	"github.com/sirupsen/logrus"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
 )
 
-func setupMachineSockets(name string, dirs *define.MachineDirs) ([]string, string, machine.APIForwardingState, error) {
-	hostSocket, err := dirs.DataDir.AppendToNewVMFile("podman.sock", nil)
+func setupMachineSockets(mc *vmconfigs.MachineConfig, dirs *define.MachineDirs) ([]string, string, machine.APIForwardingState, error) {
+	hostSocket, err := mc.APISocket()
 	if err != nil {
 		return nil, "", 0, err
 	}
@@ -25,11 +25,56 @@ func setupMachineSockets(name string, dirs *define.MachineDirs) ([]string, strin
 		return nil, "", 0, err
 	}
 
-	forwardSock, state := setupForwardingLinks(hostSocket, linkSocket)
+	linkLegacyAPISocket(mc, hostSocket)
+
+	forwardSock, state := setupForwardingLinks(hostSocket, linkSocket, mc.Logger())
 	return []string{hostSocket.GetPath()}, forwardSock, state, nil
 }
 
-func setupForwardingLinks(hostSocket, linkSocket *define.VMFile) (string, machine.APIForwardingState) {
+// linkLegacyAPISocket links the API socket of older versions of Podman,
+// which was not named after the machine, to hostSocket so that clients
+// configured with it keep working.  It is left alone while another machine
+// serves it.
+func linkLegacyAPISocket(mc *vmconfigs.MachineConfig, hostSocket *define.VMFile) {
+	legacySocket, err := mc.LegacyAPISocket()
+	if err != nil {
+		mc.Logger().Debugf("Resolving legacy API socket: %v", err)
+		return
+	}
+	if alreadyLinked(hostSocket.GetPath(), legacySocket.GetPath()) || checkSockInUse(legacySocket.GetPath()) {
+		return
+	}
+	_ = legacySocket.Delete()
+	if err := os.Symlink(hostSocket.GetPath(), legacySocket.GetPath()); err != nil {
+		mc.Logger().Debugf("Linking legacy API socket: %v", err)
+	}
+}
+
+// setupSecondaryMachineSocket returns the host socket the API of the mode the
+// machine does not default to is forwarded to
+func setupSecondaryMachineSocket(mc *vmconfigs.MachineConfig) (string, error) {
+	socket, err := mc.SecondaryAPISocket()
+	if err != nil {
+		return "", err
+	}
+	return socket.GetPath(), nil
+}
+
+// gvproxyAPIEndpoint returns the endpoint gvproxy serves its API on and the
+// network and address to reach it
+func gvproxyAPIEndpoint(mc *vmconfigs.MachineConfig) (string, string, string, error) {
+	socket, err := mc.GVProxyAPISocket()
+	if err != nil {
+		return "", "", "", err
+	}
+	// make sure it does not exist before gvproxy is called
+	if err := socket.Delete(); err != nil {
+		return "", "", "", err
+	}
+	return "unix://" + socket.GetPath(), "unix", socket.GetPath(), nil
+}
+
+func setupForwardingLinks(hostSocket, linkSocket *define.VMFile, logger define.Logger) (string, machine.APIForwardingState) {
 	// The linking pattern is /var/run/docker.sock -> user global sock (link) -> machine sock (socket)
 	// This allows the helper to only have to maintain one constant target to the user, which can be
 	// repositioned without updating docker.sock.
@@ -50,7 +95,7 @@ func setupForwardingLinks(hostSocket, linkSocket *define.VMFile) (string, machin
 		_ = linkSocket.Delete()
 
 		if err := os.Symlink(hostSocket.GetPath(), linkSocket.GetPath()); err != nil {
-			logrus.Warnf("could not create user global API forwarding link: %s", err.Error())
+			logger.Warnf("could not create user global API forwarding link: %s", err.Error())
 			return hostSocket.GetPath(), machine.MachineLocal
 		}
 	}
@@ -61,7 +106,7 @@ func setupForwardingLinks(hostSocket, linkSocket *define.VMFile) (string, machin
 		}
 
 		if !claimDockerSock() {
-			logrus.Warn("podman helper is installed, but was not able to claim the global docker sock")
+			logger.Warnf("podman helper is installed, but was not able to claim the global docker sock")
 			return hostSocket.GetPath(), machine.MachineLocal
 		}
 	}
@@ -76,8 +121,12 @@ func alreadyLinked(target string, link string) bool {
 
 func checkSockInUse(sock string) bool {
 	if info, err := os.Stat(sock); err == nil && info.Mode()&fs.ModeSocket == fs.ModeSocket {
-		_, err = net.DialTimeout("unix", dockerSock, dockerConnectTimeout)
-		return err == nil
+		conn, err := net.DialTimeout("unix", sock, dockerConnectTimeout)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
 	}
 
 	return false
@@ -2,13 +2,15 @@ package shim
 
 import (
 	"fmt"
+	"net"
 
 	"github.com/containers/podman/v5/pkg/machine"
 	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
 )
 
-func setupMachineSockets(name string, dirs *define.MachineDirs) ([]string, string, machine.APIForwardingState, error) {
-	machinePipe := machine.ToDist(name)
+func setupMachineSockets(mc *vmconfigs.MachineConfig, dirs *define.MachineDirs) ([]string, string, machine.APIForwardingState, error) {
+	machinePipe := machine.ToDist(mc.Name)
 	if !machine.PipeNameAvailable(machinePipe, machine.MachineNameWait) {
 		return nil, "", 0, fmt.Errorf("could not start api proxy since expected pipe is not available: %s", machinePipe)
 	}
@@ -22,3 +24,30 @@ func setupMachineSockets(name string, dirs *define.MachineDirs) ([]string, strin
 
 	return sockets, sockets[len(sockets)-1], state, nil
 }
+
+// setupSecondaryMachineSocket returns the pipe the API of the mode the machine
+// does not default to is forwarded to.  The API is not forwarded when another
+// process holds the pipe.
+func setupSecondaryMachineSocket(mc *vmconfigs.MachineConfig) (string, error) {
+	pipe := machine.ToDist(mc.Name) + mc.SecondaryAPISuffix()
+	if !machine.PipeNameAvailable(pipe, machine.GlobalNameWait) {
+		mc.Logger().Warnf("Not forwarding the API of the other mode of machine %q, pipe %s is in use", mc.Name, pipe)
+		return "", nil
+	}
+	return machine.NamedPipePrefix + pipe, nil
+}
+
+// gvproxyAPIEndpoint returns the endpoint gvproxy serves its API on and the
+// network and address to reach it.  gvproxy cannot listen on a unix socket
+// given a Windows path, so the API is served on a free loopback port.
+func gvproxyAPIEndpoint(_ *vmconfigs.MachineConfig) (string, string, string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", "", "", err
+	}
+	address := listener.Addr().String()
+	if err := listener.Close(); err != nil {
+		return "", "", "", err
+	}
+	return "tcp://" + address, "tcp", address, nil
+}
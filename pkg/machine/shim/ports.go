@@ -0,0 +1,143 @@
+package shim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	gvproxy "github.com/containers/gvisor-tap-vsock/pkg/types"
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/connection"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+const (
+	gvProxyWaitBackoff        = 100 * time.Millisecond
+	gvProxyMaxBackoffAttempts = 6
+	gvProxyAPITimeout         = 10 * time.Second
+)
+
+// The host ports of a machine are reserved in the user wide port allocation
+// file when the machine is created, so that machines running at the same
+// time, whatever their provider, never share a port.  The sockets and pid
+// files of a machine are named after it, see vmconfigs.MachineConfig.
+
+// allocateSSHPort reserves a new SSH port for the machine.  The provider
+// releases it when the machine is removed.
+func allocateSSHPort(mc *vmconfigs.MachineConfig) error {
+	port, err := machine.AllocateMachinePort()
+	if err != nil {
+		return err
+	}
+	mc.SSH.Port = port
+	return nil
+}
+
+// ensureSSHPort gives a stopped machine a new SSH port when its port is taken,
+// e.g. by a machine created before ports were reserved or by another process,
+// and points the connections of the machine at the new port.
+func ensureSSHPort(mc *vmconfigs.MachineConfig) error {
+	if machine.IsLocalPortAvailable(mc.SSH.Port) {
+		return nil
+	}
+	mc.Logger().Warnf("SSH port %d of machine %q is in use, reassigning it", mc.SSH.Port, mc.Name)
+	return reassignSSHPort(mc)
+}
+
+// reassignSSHPort gives the stopped machine a new SSH port and points the
+// connections of the machine at it
+func reassignSSHPort(mc *vmconfigs.MachineConfig) error {
+	oldPort := mc.SSH.Port
+	newPort, err := machine.AllocateMachinePort()
+	if err != nil {
+		return err
+	}
+	mc.Logger().Debugf("Reassigning SSH port %d of machine %q to port %d", oldPort, mc.Name, newPort)
+
+	if err := connection.UpdateConnectionPairPort(mc.Name, newPort, mc.HostUser.UID, mc.SSH.RemoteUsername); err != nil {
+		_ = machine.ReleaseMachinePort(newPort)
+		return err
+	}
+	mc.SSH.Port = newPort
+	if err := mc.Write(); err != nil {
+		return err
+	}
+
+	if err := machine.ReleaseMachinePort(oldPort); err != nil {
+		mc.Logger().Warnf("could not release SSH port %d of machine %q: %v", oldPort, mc.Name, err)
+	}
+	return nil
+}
+
+// publishPorts publishes the ports of the machine on the host through the
+// API gvproxy serves on address.  gvproxy forgets them when it exits, so
+// they are published again every time the machine starts.
+func publishPorts(mc *vmconfigs.MachineConfig, network, address string) error {
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, address)
+	}
+	if err := waitForGvproxyAPI(dial); err != nil {
+		return fmt.Errorf("gvproxy API of machine %q at %s: %w", mc.Name, address, err)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			// gvproxy is reached directly, never through a proxy
+			Proxy:       nil,
+			DialContext: dial,
+		},
+		Timeout: gvProxyAPITimeout,
+	}
+	for _, port := range mc.Network.Ports {
+		if err := exposePort(client, port); err != nil {
+			return fmt.Errorf("publishing port %s of machine %q: %w", port, mc.Name, err)
+		}
+		mc.Logger().Debugf("published port %s of machine %q", port, mc.Name)
+	}
+	return nil
+}
+
+// waitForGvproxyAPI waits for gvproxy, which was just started, to listen
+func waitForGvproxyAPI(dial func(ctx context.Context, network, address string) (net.Conn, error)) error {
+	backoff := gvProxyWaitBackoff
+	var err error
+	for i := 0; i < gvProxyMaxBackoffAttempts; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		var conn net.Conn
+		if conn, err = dial(context.Background(), "", ""); err == nil {
+			return conn.Close()
+		}
+	}
+	return err
+}
+func exposePort(client *http.Client, port define.PortForward) error {
+	body, err := json.Marshal(gvproxy.ExposeRequest{
+		Local:    port.HostAddress(),
+		Remote:   port.GuestAddress(),
+		Protocol: gvproxy.TransportProtocol(port.Protocol),
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post("http://gvproxy/services/forwarder/expose", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("gvproxy: %s", strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
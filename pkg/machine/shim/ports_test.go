@@ -0,0 +1,225 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	gvproxy "github.com/containers/gvisor-tap-vsock/pkg/types"
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureSSHPort(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "ports")
+	other, _ := initMachine(t, p, "ports-other")
+	assert.NotZero(t, mc.SSH.Port)
+	assert.NotEqual(t, mc.SSH.Port, other.SSH.Port)
+
+	// a free port is kept
+	port := mc.SSH.Port
+	require.NoError(t, ensureSSHPort(mc))
+	assert.Equal(t, port, mc.SSH.Port)
+
+	// a port taken by another process is reassigned
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	defer l.Close()
+	require.NoError(t, ensureSSHPort(mc))
+	assert.NotEqual(t, port, mc.SSH.Port)
+	assert.NotEqual(t, other.SSH.Port, mc.SSH.Port)
+
+	loaded, err := vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	assert.Equal(t, mc.SSH.Port, loaded.SSH.Port)
+
+	cons, err := ListConnections()
+	require.NoError(t, err)
+	updated := 0
+	for _, con := range cons {
+		if con.Name == mc.Name || con.Name == mc.Name+"-root" {
+			assert.Contains(t, con.URI, fmt.Sprintf(":%d/", mc.SSH.Port))
+			updated++
+		}
+	}
+	assert.Equal(t, 2, updated)
+}
+
+// allocatedPorts returns the SSH ports reserved in the user-wide allocation
+// file
+func allocatedPorts(t *testing.T) []int {
+	t.Helper()
+	dir, err := machine.GetGlobalDataDir()
+	require.NoError(t, err)
+	var ports []int
+	data, err := os.ReadFile(filepath.Join(dir, "port-alloc.dat"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &ports))
+	return ports
+}
+
+func TestInitReleasesSSHPort(t *testing.T) {
+	writeIdentity(t)
+	p := fakeprovider.New(t)
+	ign := filepath.Join(t.TempDir(), "custom.ign")
+	require.NoError(t, os.WriteFile(ign, []byte(`{"ignition":{"version":"3.4.0"}}`), 0644))
+	registries, _ := writeGuestConfigFiles(t)
+	before := allocatedPorts(t)
+
+	// no machine config is written for an ignition file, nothing else
+	// would release the port
+	mc, _, err := Init(define.InitOptions{Name: "portign", Username: "core", IgnitionPath: ign}, p)
+	require.NoError(t, err)
+	assert.Nil(t, mc)
+	assert.ElementsMatch(t, before, allocatedPorts(t))
+
+	_, _, err = Init(define.InitOptions{Name: "portign", Username: "core", IgnitionPath: ign, GuestConfig: define.GuestConfigOptions{RegistriesConf: &registries}}, p)
+	require.ErrorContains(t, err, "--ignition-path")
+	assert.ElementsMatch(t, before, allocatedPorts(t))
+
+	prepare := func(*vmconfigs.MachineConfig) error {
+		return errors.New("prepare failed")
+	}
+	getDisk := func(dirs *define.MachineDirs, mc *vmconfigs.MachineConfig) error {
+		return p.GetDisk("", dirs, mc)
+	}
+	_, _, err = initialize(define.InitOptions{Name: "portprepare", Username: "core"}, p, prepare, getDisk)
+	require.ErrorContains(t, err, "prepare failed")
+	assert.ElementsMatch(t, before, allocatedPorts(t))
+}
+
+// fakeGvproxyAPI serves the port forwarding API of gvproxy on a unix socket
+// and records the exposed ports.  Ports in taken cannot be exposed.
+type fakeGvproxyAPI struct {
+	socket string
+	taken  map[string]bool
+
+	mu      sync.Mutex
+	exposed []gvproxy.ExposeRequest
+}
+
+func newFakeGvproxyAPI(t *testing.T) *fakeGvproxyAPI {
+	api := &fakeGvproxyAPI{
+		socket: filepath.Join(t.TempDir(), "api.sock"),
+		taken:  map[string]bool{},
+	}
+	l, err := net.Listen("unix", api.socket)
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/services/forwarder/expose", func(w http.ResponseWriter, r *http.Request) {
+		var req gvproxy.ExposeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if api.taken[req.Local] {
+			http.Error(w, "listen tcp "+req.Local+": bind: address already in use", http.StatusInternalServerError)
+			return
+		}
+		api.mu.Lock()
+		api.exposed = append(api.exposed, req)
+		api.mu.Unlock()
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: time.Second}
+	go func() {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.Error(err)
+		}
+	}()
+	t.Cleanup(func() { _ = srv.Close() })
+	return api
+}
+
+func TestPublishPorts(t *testing.T) {
+	api := newFakeGvproxyAPI(t)
+	mc := &vmconfigs.MachineConfig{Name: "publish"}
+	var err error
+	mc.Network.Ports, err = define.ParsePortForwards([]string{"127.0.0.1:80:8080", "5353:53/udp"})
+	require.NoError(t, err)
+
+	require.NoError(t, publishPorts(mc, "unix", api.socket))
+	assert.Equal(t, []gvproxy.ExposeRequest{
+		{Local: "127.0.0.1:80", Remote: "192.168.127.2:8080", Protocol: gvproxy.TCP},
+		{Local: ":5353", Remote: "192.168.127.2:53", Protocol: gvproxy.UDP},
+	}, api.exposed)
+
+	// a port that cannot be published fails the start
+	api.taken[":5353"] = true
+	err = publishPorts(mc, "unix", api.socket)
+	assert.ErrorContains(t, err, `publishing port 5353:53/udp of machine "publish": gvproxy: listen tcp :5353: bind: address already in use`)
+}
+
+func TestPublishPortsNotListening(t *testing.T) {
+	mc := &vmconfigs.MachineConfig{Name: "publish"}
+	mc.Network.Ports = []define.PortForward{{HostPort: 80, GuestPort: 80, Protocol: "tcp"}}
+	err := publishPorts(mc, "unix", filepath.Join(t.TempDir(), "missing.sock"))
+	assert.ErrorContains(t, err, `gvproxy API of machine "publish"`)
+}
+
+func TestSetPorts(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "set-ports")
+
+	ports := []string{"8080", "127.0.0.1:2049:2049"}
+	require.NoError(t, Set(mc, p, define.SetOptions{Ports: &ports}))
+	assert.Equal(t, []define.PortForward{
+		{HostPort: 8080, GuestPort: 8080, Protocol: "tcp"},
+		{HostIP: "127.0.0.1", HostPort: 2049, GuestPort: 2049, Protocol: "tcp"},
+	}, mc.Network.Ports)
+
+	// an empty string stops publishing the ports
+	ports = []string{""}
+	require.NoError(t, Set(mc, p, define.SetOptions{Ports: &ports}))
+	assert.Empty(t, mc.Network.Ports)
+
+	// WSL forwards the ports itself
+	p.Type = define.WSLVirt
+	ports = []string{"8080"}
+	err := Set(mc, p, define.SetOptions{Ports: &ports})
+	assert.ErrorIs(t, err, define.ErrNotImplemented)
+}
+
+func TestSetWSLNetworking(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "set-wsl-networking")
+
+	// only WSL machines switch the networking mode of WSL
+	mirrored := string(define.WSLNetworkingMirrored)
+	err := Set(mc, p, define.SetOptions{WSLNetworking: &mirrored})
+	assert.ErrorIs(t, err, define.ErrNotImplemented)
+
+	p.Type = define.WSLVirt
+	require.NoError(t, Set(mc, p, define.SetOptions{WSLNetworking: &mirrored}))
+	assert.Equal(t, define.WSLNetworkingMirrored, mc.Network.WSLMode)
+
+	userMode := true
+	err = Set(mc, p, define.SetOptions{UserModeNetworking: &userMode})
+	assert.ErrorContains(t, err, "cannot be combined with user-mode networking")
+	assert.Equal(t, define.WSLNetworkingMirrored, mc.Network.WSLMode)
+
+	invalid := "bridged"
+	err = Set(mc, p, define.SetOptions{WSLNetworking: &invalid})
+	assert.ErrorContains(t, err, `WSL networking mode "bridged"`)
+
+	// an empty mode leaves WSL as it is configured
+	unset := ""
+	require.NoError(t, Set(mc, p, define.SetOptions{WSLNetworking: &unset, UserModeNetworking: &userMode}))
+	assert.Empty(t, mc.Network.WSLMode)
+}
@@ -0,0 +1,212 @@
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/podman/v5/pkg/machine/cloudinit"
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/ignition"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/containers/podman/v5/pkg/systemd/parser"
+	"golang.org/x/exp/slices"
+)
+
+// cloudImageMarkers are found in the names of the distribution cloud images
+// provisioned by cloud-init, e.g. jammy-server-cloudimg-amd64.img or
+// debian-12-genericcloud-amd64.qcow2
+var cloudImageMarkers = []string{"cloudimg", "genericcloud", "cloud-base", "ubuntu", "debian"}
+
+// provisionerForImage picks the provisioner of a machine created from
+// imagePath, as given to --image-path.  The machine image of podman and the
+// Fedora CoreOS images are provisioned by ignition.
+func provisionerForImage(imagePath string) machineDefine.Provisioner {
+	name := strings.ToLower(path.Base(strings.ReplaceAll(imagePath, "\\", "/")))
+	if name == "" || strings.Contains(name, "coreos") {
+		return machineDefine.IgnitionProvisioner
+	}
+	for _, marker := range cloudImageMarkers {
+		if strings.Contains(name, marker) {
+			return machineDefine.CloudInitProvisioner
+		}
+	}
+	return machineDefine.IgnitionProvisioner
+}
+
+// selectProvisioner records the provisioner of a new machine in mc.  Only
+// qemu machines can be provisioned by cloud-init for now: the other providers
+// have no way to attach the seed disk, or no first boot provisioning at all.
+func selectProvisioner(opts machineDefine.InitOptions, mc *vmconfigs.MachineConfig, vmType machineDefine.VMType) error {
+	p := opts.Provisioner
+	switch {
+	case p != "":
+	case len(opts.IgnitionPath) > 0:
+		p = machineDefine.IgnitionProvisioner
+	default:
+		p = provisionerForImage(opts.ImagePath)
+	}
+	if p == machineDefine.CloudInitProvisioner {
+		if vmType != machineDefine.QemuVirt {
+			return fmt.Errorf("%s machines cannot be provisioned by cloud-init: %w", vmType.String(), machineDefine.ErrNotImplemented)
+		}
+		if len(opts.IgnitionPath) > 0 {
+			return errors.New("an ignition file cannot provision a machine using cloud-init")
+		}
+	}
+	mc.Provisioner = p
+	return nil
+}
+
+// writeProvisioning writes what provisions the machine on its first boot from
+// the config collected by builder: the ignition file or the cloud-init seed
+// disk.
+func writeProvisioning(mc *vmconfigs.MachineConfig, builder *ignition.IgnitionBuilder) error {
+	if mc.Provisioner != machineDefine.CloudInitProvisioner {
+		return builder.Build()
+	}
+	seed, err := mc.CloudInitSeed()
+	if err != nil {
+		return err
+	}
+	userData, err := cloudinit.UserData(builder.Config())
+	if err != nil {
+		return fmt.Errorf("rendering the cloud-init user-data of machine %q: %w", mc.Name, err)
+	}
+	mc.Logger().Debugf("writing cloud-init seed disk %s", seed.GetPath())
+	return cloudinit.WriteSeed(seed.GetPath(), userData, cloudinit.MetaData(mc.Name))
+}
+
+const (
+	// initScriptsDir is where the init scripts given at init are written in
+	// the guest, named after their order
+	initScriptsDir = "/usr/local/libexec/podman-machine/init.d"
+	// initScriptsUnitName runs the init scripts on the first boot, before
+	// the guest reports ready
+	initScriptsUnitName = "podman-machine-init-scripts.service"
+	// initScriptsStamp is created once all init scripts succeeded
+	initScriptsStamp = "/var/lib/podman-machine/init-scripts.done"
+)
+
+// unitSuffixes are the types of the systemd units that can be given at init
+var unitSuffixes = []string{".service", ".socket", ".timer", ".path", ".mount", ".automount", ".target", ".slice"}
+
+// userProvisioning holds the init scripts and the systemd units given at
+// init, ready to be merged into the generated config
+type userProvisioning struct {
+	files []ignition.File
+	units []ignition.Unit
+}
+
+// loadUserProvisioning reads and checks the init scripts and the systemd
+// units given at init.  Scripts run as root on the first boot, in the given
+// order, and must start with an interpreter line.  Units are enabled when
+// they have an [Install] section.
+func loadUserProvisioning(opts machineDefine.InitOptions, vmType machineDefine.VMType) (*userProvisioning, error) {
+	up := new(userProvisioning)
+	if len(opts.InitScripts) == 0 && len(opts.Units) == 0 {
+		return up, nil
+	}
+	if vmType == machineDefine.WSLVirt {
+		return nil, fmt.Errorf("%s machines have no first boot provisioning for init scripts and units: %w", vmType.String(), machineDefine.ErrNotImplemented)
+	}
+	if len(opts.IgnitionPath) > 0 {
+		return nil, errors.New("init scripts and units cannot be added to an ignition file given with --ignition-path")
+	}
+
+	if len(opts.InitScripts) > 0 {
+		scriptsUnit := parser.NewUnitFile()
+		scriptsUnit.Add("Unit", "Description", "Run the init scripts of the machine on its first boot")
+		scriptsUnit.Add("Unit", "Wants", "network-online.target")
+		scriptsUnit.Add("Unit", "After", "network-online.target")
+		scriptsUnit.Add("Unit", "Before", readyUnitName)
+		scriptsUnit.Add("Unit", "ConditionPathExists", "!"+initScriptsStamp)
+		scriptsUnit.Add("Service", "Type", "oneshot")
+		scriptsUnit.Add("Service", "RemainAfterExit", "yes")
+		scriptsUnit.Add("Service", "TimeoutStartSec", "infinity")
+
+		for i, script := range opts.InitScripts {
+			content, err := os.ReadFile(script)
+			if err != nil {
+				return nil, fmt.Errorf("reading init script: %w", err)
+			}
+			if !strings.HasPrefix(string(content), "#!") {
+				return nil, fmt.Errorf("init script %s must start with an interpreter line, e.g. #!/bin/sh", script)
+			}
+			guestPath := path.Join(initScriptsDir, fmt.Sprintf("%02d-%s", i, filepath.Base(script)))
+			up.files = append(up.files, ignition.File{
+				Node: ignition.Node{
+					Group: ignition.GetNodeGrp("root"),
+					Path:  guestPath,
+					User:  ignition.GetNodeUsr("root"),
+				},
+				FileEmbedded1: ignition.FileEmbedded1{
+					Contents: ignition.Resource{
+						Source: ignition.EncodeDataURLPtr(string(content)),
+					},
+					Mode: ignition.IntToPtr(0755),
+				},
+			})
+			scriptsUnit.Add("Service", "ExecStart", guestPath)
+		}
+		scriptsUnit.Add("Service", "ExecStartPost", "/usr/bin/mkdir -p "+path.Dir(initScriptsStamp))
+		scriptsUnit.Add("Service", "ExecStartPost", "/usr/bin/touch "+initScriptsStamp)
+		scriptsUnit.Add("Install", "WantedBy", "multi-user.target")
+
+		contents, err := scriptsUnit.ToString()
+		if err != nil {
+			return nil, err
+		}
+		up.units = append(up.units, ignition.Unit{
+			Enabled:  ignition.BoolToPtr(true),
+			Name:     initScriptsUnitName,
+			Contents: &contents,
+		})
+	}
+
+	for _, unitPath := range opts.Units {
+		name := filepath.Base(unitPath)
+		if !slices.Contains(unitSuffixes, path.Ext(name)) {
+			return nil, fmt.Errorf("unit %s must be one of the %s unit types", unitPath, strings.Join(unitSuffixes, ", "))
+		}
+		if slices.ContainsFunc(up.units, func(u ignition.Unit) bool { return u.Name == name }) {
+			return nil, fmt.Errorf("unit %s is given twice", name)
+		}
+		content, err := os.ReadFile(unitPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading unit: %w", err)
+		}
+		// the unit is only parsed to be checked, it is installed as given
+		unitFile := parser.NewUnitFile()
+		if err := unitFile.Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("parsing unit %s: %w", unitPath, err)
+		}
+		contents := string(content)
+		unit := ignition.Unit{
+			Name:     name,
+			Contents: &contents,
+		}
+		if unitFile.HasGroup("Install") {
+			unit.Enabled = ignition.BoolToPtr(true)
+		}
+		up.units = append(up.units, unit)
+	}
+	return up, nil
+}
+
+// apply merges the init scripts and units into the config collected by
+// builder.  The units must not replace the units podman generates.
+func (up *userProvisioning) apply(builder *ignition.IgnitionBuilder) error {
+	generated := builder.Config().Systemd.Units
+	for _, unit := range up.units {
+		if slices.ContainsFunc(generated, func(u ignition.Unit) bool { return u.Name == unit.Name }) {
+			return fmt.Errorf("unit %s is generated by podman and cannot be given at init", unit.Name)
+		}
+	}
+	builder.WithFile(up.files...)
+	builder.WithUnit(up.units...)
+	return nil
+}
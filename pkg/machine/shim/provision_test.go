@@ -0,0 +1,107 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/ignition"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvisionerForImage(t *testing.T) {
+	tests := []struct {
+		imagePath string
+		want      define.Provisioner
+	}{
+		{"", define.IgnitionProvisioner},
+		{"docker://quay.io/podman/machine-os:5.0", define.IgnitionProvisioner},
+		{"/tmp/fedora-coreos-39.20231101.3.0-qemu.x86_64.qcow2", define.IgnitionProvisioner},
+		{"https://cloud-images.ubuntu.com/jammy/current/jammy-server-cloudimg-amd64.img", define.CloudInitProvisioner},
+		{"/tmp/debian-12-genericcloud-amd64.qcow2", define.CloudInitProvisioner},
+		{`C:\images\Fedora-Cloud-Base-39-1.5.x86_64.qcow2`, define.CloudInitProvisioner},
+		{"/tmp/Ubuntu-24.04.qcow2", define.CloudInitProvisioner},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, provisionerForImage(tt.imagePath), tt.imagePath)
+	}
+}
+
+func TestSelectProvisioner(t *testing.T) {
+	cloudImage := "/tmp/jammy-server-cloudimg-amd64.img"
+
+	mc := new(vmconfigs.MachineConfig)
+	require.NoError(t, selectProvisioner(define.InitOptions{ImagePath: cloudImage}, mc, define.QemuVirt))
+	assert.Equal(t, define.CloudInitProvisioner, mc.Provisioner)
+
+	// the provisioner given wins over the image
+	mc = new(vmconfigs.MachineConfig)
+	opts := define.InitOptions{ImagePath: cloudImage, Provisioner: define.IgnitionProvisioner}
+	require.NoError(t, selectProvisioner(opts, mc, define.QemuVirt))
+	assert.Equal(t, define.IgnitionProvisioner, mc.Provisioner)
+
+	// so does an ignition file
+	mc = new(vmconfigs.MachineConfig)
+	opts = define.InitOptions{ImagePath: cloudImage, IgnitionPath: "/tmp/custom.ign"}
+	require.NoError(t, selectProvisioner(opts, mc, define.QemuVirt))
+	assert.Equal(t, define.IgnitionProvisioner, mc.Provisioner)
+
+	opts = define.InitOptions{Provisioner: define.CloudInitProvisioner, IgnitionPath: "/tmp/custom.ign"}
+	assert.Error(t, selectProvisioner(opts, new(vmconfigs.MachineConfig), define.QemuVirt))
+
+	err := selectProvisioner(define.InitOptions{ImagePath: cloudImage}, new(vmconfigs.MachineConfig), define.AppleHvVirt)
+	assert.True(t, errors.Is(err, define.ErrNotImplemented))
+}
+
+func TestUserProvisioning(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "install.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\nrpm-ostree install -A htop\n"), 0o755))
+	unitContent := "[Unit]\nDescription=mirror\n\n[Service]\nExecStart=/usr/bin/true\n\n[Install]\nWantedBy=multi-user.target\n"
+	unit := filepath.Join(dir, "mirror.service")
+	require.NoError(t, os.WriteFile(unit, []byte(unitContent), 0o644))
+
+	opts := define.InitOptions{InitScripts: []string{script}, Units: []string{unit}}
+	up, err := loadUserProvisioning(opts, define.QemuVirt)
+	require.NoError(t, err)
+
+	builder := ignition.NewIgnitionBuilder(ignition.DynamicIgnition{})
+	builder.WithUnit(ignition.Unit{Name: readyUnitName})
+	require.NoError(t, up.apply(&builder))
+	cfg := builder.Config()
+
+	require.Len(t, cfg.Storage.Files, 1)
+	assert.Equal(t, initScriptsDir+"/00-install.sh", cfg.Storage.Files[0].Path)
+	require.Len(t, cfg.Systemd.Units, 3)
+	scriptsUnit := cfg.Systemd.Units[1]
+	assert.Equal(t, initScriptsUnitName, scriptsUnit.Name)
+	assert.Contains(t, *scriptsUnit.Contents, "ExecStart="+initScriptsDir+"/00-install.sh")
+	assert.Contains(t, *scriptsUnit.Contents, "Before="+readyUnitName)
+	assert.Equal(t, "mirror.service", cfg.Systemd.Units[2].Name)
+	assert.Equal(t, unitContent, *cfg.Systemd.Units[2].Contents)
+	assert.True(t, *cfg.Systemd.Units[2].Enabled)
+
+	// units generated by podman cannot be replaced
+	ready := filepath.Join(dir, readyUnitName)
+	require.NoError(t, os.WriteFile(ready, []byte(unitContent), 0o644))
+	up, err = loadUserProvisioning(define.InitOptions{Units: []string{ready}}, define.QemuVirt)
+	require.NoError(t, err)
+	assert.Error(t, up.apply(&builder))
+
+	noShebang := filepath.Join(dir, "plain.sh")
+	require.NoError(t, os.WriteFile(noShebang, []byte("echo hi\n"), 0o755))
+	_, err = loadUserProvisioning(define.InitOptions{InitScripts: []string{noShebang}}, define.QemuVirt)
+	assert.Error(t, err)
+
+	_, err = loadUserProvisioning(define.InitOptions{Units: []string{script}}, define.QemuVirt)
+	assert.Error(t, err)
+
+	_, err = loadUserProvisioning(opts, define.WSLVirt)
+	assert.True(t, errors.Is(err, define.ErrNotImplemented))
+}
@@ -0,0 +1,49 @@
+package shim
+
+import (
+	"fmt"
+
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/proxyenv"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"golang.org/x/exp/slices"
+)
+
+// recordProxies records the proxy variables of the host applied to the
+// machine, so that RefreshProxies can tell when they change
+func recordProxies(mc *vmconfigs.MachineConfig) {
+	proxies := proxyenv.HostProxies(mc)
+	if slices.Equal(proxies, mc.ProxyEnv) {
+		return
+	}
+	mc.ProxyEnv = proxies
+	if err := mc.Write(); err != nil {
+		mc.Logger().Errorf("%v", err)
+	}
+}
+
+// RefreshProxies applies the proxy variables of the host to the running
+// machine when they changed since they were last applied, or always with
+// force, so that the machine follows the host across networks without a
+// restart.  It reports whether the proxies were applied.
+func RefreshProxies(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, force bool) (bool, error) {
+	proxies := proxyenv.HostProxies(mc)
+	if !force && slices.Equal(proxies, mc.ProxyEnv) {
+		return false, nil
+	}
+
+	state, err := mp.State(mc, false)
+	if err != nil {
+		return false, err
+	}
+	if state != machineDefine.Running {
+		return false, fmt.Errorf("machine %q must be running to refresh its proxies, they are applied when it starts: %w", mc.Name, machineDefine.ErrWrongState)
+	}
+
+	mc.Logger().Debugf("refreshing the proxies of machine %q", mc.Name)
+	if err := refreshProxies(mc); err != nil {
+		return false, fmt.Errorf("refreshing the proxies of machine %q: %w", mc.Name, err)
+	}
+	mc.ProxyEnv = proxies
+	return true, mc.Write()
+}
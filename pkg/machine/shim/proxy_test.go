@@ -0,0 +1,71 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/containers/common/pkg/config"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshProxies(t *testing.T) {
+	for _, key := range config.ProxyEnv {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	origRefresh := refreshProxies
+	defer func() {
+		refreshProxies = origRefresh
+	}()
+	refreshed := 0
+	refreshProxies = func(*vmconfigs.MachineConfig) error {
+		refreshed++
+		return nil
+	}
+
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "proxies")
+
+	// nothing changed since the machine started
+	applied, err := RefreshProxies(mc, p, false)
+	require.NoError(t, err)
+	assert.False(t, applied)
+
+	t.Setenv("HTTPS_PROXY", "http://localhost:3128")
+	_, err = RefreshProxies(mc, p, false)
+	assert.ErrorIs(t, err, define.ErrWrongState)
+
+	p.SetState(mc.Name, define.Running)
+	refreshProxies = func(*vmconfigs.MachineConfig) error {
+		return errors.New("ssh failed")
+	}
+	_, err = RefreshProxies(mc, p, false)
+	assert.ErrorContains(t, err, "ssh failed")
+	assert.Empty(t, mc.ProxyEnv)
+
+	refreshProxies = func(*vmconfigs.MachineConfig) error {
+		refreshed++
+		return nil
+	}
+	applied, err = RefreshProxies(mc, p, false)
+	require.NoError(t, err)
+	assert.True(t, applied)
+	loaded, err := vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HTTPS_PROXY=http://host.containers.internal:3128"}, loaded.ProxyEnv)
+
+	applied, err = RefreshProxies(mc, p, false)
+	require.NoError(t, err)
+	assert.False(t, applied)
+	applied, err = RefreshProxies(mc, p, true)
+	require.NoError(t, err)
+	assert.True(t, applied)
+	assert.Equal(t, 2, refreshed)
+}
@@ -0,0 +1,68 @@
+package shim
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// consoleTailLines is how many lines of the console log are reported when a
+// machine does not become ready
+const consoleTailLines = 20
+
+// waitForReady calls the wait function returned by the provider and gives up
+// after timeout.  The wait function keeps running in the background when it
+// times out, the caller is expected to tear the machine down.
+func waitForReady(mc *vmconfigs.MachineConfig, wait func() error, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = machine.DefaultReadyTimeout
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- wait()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-errChan:
+		return err
+	case <-timer.C:
+	}
+
+	readyErr := &machineDefine.ErrReadyTimeout{Name: mc.Name, Timeout: timeout}
+	logFile, err := mc.LogFile()
+	if err != nil {
+		mc.Logger().Debugf("Unable to get console log of machine %q: %v", mc.Name, err)
+		return readyErr
+	}
+	readyErr.Console, err = tailLines(logFile.GetPath(), consoleTailLines)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		mc.Logger().Debugf("Unable to read console log of machine %q: %v", mc.Name, err)
+	}
+	return readyErr
+}
+
+// tailLines returns the last n lines of the file at path
+func tailLines(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := make([]string, 0, n)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(lines) == n {
+			lines = lines[1:]
+		}
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,66 @@
+package shim
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// recoveryTarget is the systemd target a machine boots into for repair.  It
+// only starts a root shell on the console, so neither SSH nor the ready
+// service are started.
+const recoveryTarget = "emergency.target"
+
+// validUnitName matches the systemd unit names that can be masked for a
+// recovery boot
+var validUnitName = regexp.MustCompile(`^[a-zA-Z0-9:_.\\@-]+$`)
+
+// StartRecovery boots a stopped machine into a minimal state for repair, e.g.
+// when the guest is broken to the point that SSH does not come up.  The
+// units in opts.MaskUnits are not started.  Nothing waits for the machine to
+// be ready, it is repaired from its console.
+func StartRecovery(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, opts machine.RecoveryOptions) (err error) {
+	state, err := mp.State(mc, false)
+	if err != nil {
+		return err
+	}
+	if state == machineDefine.Running || state == machineDefine.Starting || mc.Starting {
+		return machineDefine.ErrVMAlreadyRunning
+	}
+
+	override := machineDefine.RecoveryOverride{Target: recoveryTarget}
+	for _, unit := range opts.MaskUnits {
+		if !validUnitName.MatchString(unit) {
+			return fmt.Errorf("invalid unit name %q", unit)
+		}
+		override.MaskUnits = append(override.MaskUnits, unit)
+	}
+
+	// the guest network comes up once repaired, gvproxy is started as for
+	// any start
+	if _, _, _, err := startNetworking(mc, mp); err != nil {
+		return err
+	}
+	if !mp.UseProviderNetworkSetup() {
+		gvproxyPidFile, err := mc.GVProxyPidFile()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				if cleanErr := machine.CleanupGVProxy(*gvproxyPidFile); cleanErr != nil {
+					mc.Logger().Errorf("Unable to clean up gvproxy: %v", cleanErr)
+				}
+			}
+		}()
+	}
+
+	mc.Logger().Debugf("Starting machine %q for recovery into %s, masking %q", mc.Name, override.Target, override.MaskUnits)
+	if err := mp.StartRecovery(mc, override); err != nil {
+		return fmt.Errorf("starting machine %q for recovery: %w", mc.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package shim
+
+import (
+	"errors"
+	"fmt"
+
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// Update resizes an existing machine to the CPUs, memory and disk size set in
+// opts.  The provider grows the disk image and adjusts the hypervisor
+// configuration, then the new resources are recorded in the machine
+// configuration, which is written by the caller.  The machine must be
+// stopped and its disk can only grow.  Nothing is done if opts changes none
+// of the resources.
+func Update(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, opts machineDefine.SetOptions) error {
+	if err := checkUpdate(mc, mp, opts); err != nil {
+		return err
+	}
+	return updateResources(mc, mp, opts)
+}
+
+// checkUpdate returns an error if the resources in opts cannot be applied
+// to the machine
+func checkUpdate(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, opts machineDefine.SetOptions) error {
+	if !resourcesChanged(opts) {
+		return nil
+	}
+	if opts.CPUs != nil && *opts.CPUs == 0 {
+		return errors.New("the number of CPUs must be at least 1")
+	}
+	if opts.Memory != nil && *opts.Memory == 0 {
+		return errors.New("the memory must be at least 1 MiB")
+	}
+	if opts.DiskSize != nil && uint64(*opts.DiskSize) <= mc.Resources.DiskSize {
+		return fmt.Errorf("new disk size must be larger than %d GB", mc.Resources.DiskSize)
+	}
+
+	state, err := mp.State(mc, false)
+	if err != nil {
+		return err
+	}
+	if state != machineDefine.Stopped {
+		return fmt.Errorf("unable to change the resources of machine %q unless it is stopped", mc.Name)
+	}
+	return nil
+}
+
+// updateResources has the provider apply the resources checked by
+// checkUpdate.  The disk cannot shrink again, so callers run it after
+// everything else that can fail.
+func updateResources(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, opts machineDefine.SetOptions) error {
+	if !resourcesChanged(opts) {
+		return nil
+	}
+	if err := mp.UpdateResources(mc, opts); err != nil {
+		return fmt.Errorf("updating the resources of machine %q: %w", mc.Name, err)
+	}
+
+	if opts.CPUs != nil {
+		mc.Resources.CPUs = *opts.CPUs
+	}
+	if opts.Memory != nil {
+		mc.Resources.Memory = *opts.Memory
+	}
+	if opts.DiskSize != nil {
+		mc.Resources.DiskSize = uint64(*opts.DiskSize)
+	}
+	return nil
+}
+
+func resourcesChanged(opts machineDefine.SetOptions) bool {
+	return opts.CPUs != nil || opts.Memory != nil || opts.DiskSize != nil
+}
@@ -0,0 +1,60 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/containers/common/pkg/strongunits"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdate(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "update")
+	initial := mc.Resources
+
+	// nothing to change
+	require.NoError(t, Update(mc, p, define.SetOptions{}))
+	assert.Zero(t, p.Called("UpdateResources"))
+
+	cpus, memory := uint64(4), uint64(4096)
+	smaller := strongunits.GiB(initial.DiskSize)
+	for _, opts := range []define.SetOptions{
+		{DiskSize: &smaller},
+		{CPUs: new(uint64)},
+		{Memory: new(uint64)},
+	} {
+		assert.Error(t, Update(mc, p, opts))
+	}
+
+	p.SetState(mc.Name, define.Running)
+	assert.ErrorContains(t, Update(mc, p, define.SetOptions{CPUs: &cpus}), "unless it is stopped")
+	p.SetState(mc.Name, define.Stopped)
+
+	p.Fail("UpdateResources", errors.New("resize failed"))
+	assert.ErrorContains(t, Update(mc, p, define.SetOptions{CPUs: &cpus}), "resize failed")
+	assert.Equal(t, initial, mc.Resources)
+	p.Fail("UpdateResources", nil)
+
+	// the resources are not changed when another setting fails
+	p.Fail("SetProviderAttrs", errors.New("cannot set"))
+	assert.ErrorContains(t, Set(mc, p, define.SetOptions{CPUs: &cpus}), "cannot set")
+	assert.Equal(t, 1, p.Called("UpdateResources"))
+	assert.Equal(t, initial, mc.Resources)
+	p.Fail("SetProviderAttrs", nil)
+
+	// the new resources are recorded and written by Set
+	larger := strongunits.GiB(initial.DiskSize + 10)
+	require.NoError(t, Set(mc, p, define.SetOptions{CPUs: &cpus, Memory: &memory, DiskSize: &larger}))
+	loaded, err := vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	assert.Equal(t, cpus, loaded.Resources.CPUs)
+	assert.Equal(t, memory, loaded.Resources.Memory)
+	assert.Equal(t, initial.DiskSize+10, loaded.Resources.DiskSize)
+}
@@ -0,0 +1,40 @@
+package shim
+
+import (
+	"fmt"
+
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// SetRootful switches the machine between rootful and rootless mode and
+// writes its configuration.  If the machine is rootless and its connection
+// is the default one, the default becomes its rootful connection and vice
+// versa.  A running machine has its podman/docker socket service updated
+// right away, a stopped one is updated on its next start.  Nothing is done
+// if the machine already is in the requested mode.
+func SetRootful(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, rootful bool) error {
+	if mc.HostUser.Rootful == rootful {
+		return nil
+	}
+
+	state, err := mp.State(mc, false)
+	if err != nil {
+		return err
+	}
+
+	if err := mc.SetRootful(rootful); err != nil {
+		return fmt.Errorf("updating connections of machine %q: %w", mc.Name, err)
+	}
+
+	if state == machineDefine.Running {
+		// Errors are already logged and the service is updated again on
+		// the next start since the host user stays modified
+		if updateSockService(mc) == nil {
+			mc.HostUser.Modified = false
+		} else {
+			mc.Logger().Warnf("The socket service of machine %q is updated on its next start", mc.Name)
+		}
+	}
+	return mc.Write()
+}
@@ -0,0 +1,91 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/containers/common/pkg/config"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setDefaultConnection(t *testing.T, name string) {
+	t.Helper()
+	require.NoError(t, config.EditConnectionConfig(func(cfg *config.ConnectionsFile) error {
+		cfg.Connection.Default = name
+		return nil
+	}))
+}
+
+func defaultConnection(t *testing.T) string {
+	t.Helper()
+	cfg, err := config.Default()
+	require.NoError(t, err)
+	con, err := cfg.GetConnection("", true)
+	require.NoError(t, err)
+	return con.Name
+}
+
+func TestSetRootful(t *testing.T) {
+	origUpdate := updateSockService
+	defer func() { updateSockService = origUpdate }()
+	var updated []bool
+	updateSockService = func(mc *vmconfigs.MachineConfig) error {
+		updated = append(updated, mc.HostUser.Rootful)
+		return nil
+	}
+
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "rootful")
+	p.SetState(mc.Name, define.Stopped)
+	setDefaultConnection(t, mc.Name)
+
+	// a stopped machine is updated on its next start
+	require.NoError(t, SetRootful(mc, p, true))
+	assert.True(t, mc.HostUser.Rootful)
+	assert.True(t, mc.HostUser.Modified)
+	assert.Equal(t, mc.Name+"-root", defaultConnection(t))
+	assert.Empty(t, updated)
+
+	reloaded, err := vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	assert.True(t, reloaded.HostUser.Rootful)
+	assert.True(t, reloaded.HostUser.Modified)
+
+	// already rootful
+	require.NoError(t, SetRootful(mc, p, true))
+	assert.Equal(t, mc.Name+"-root", defaultConnection(t))
+
+	// a running machine has its socket service updated right away
+	p.SetState(mc.Name, define.Running)
+	require.NoError(t, SetRootful(mc, p, false))
+	assert.False(t, mc.HostUser.Rootful)
+	assert.False(t, mc.HostUser.Modified)
+	assert.Equal(t, mc.Name, defaultConnection(t))
+	assert.Equal(t, []bool{false}, updated)
+
+	// a failed update is retried on the next start
+	updateSockService = func(*vmconfigs.MachineConfig) error {
+		return errors.New("ssh failed")
+	}
+	require.NoError(t, SetRootful(mc, p, true))
+	assert.True(t, mc.HostUser.Rootful)
+	assert.True(t, mc.HostUser.Modified)
+}
+
+func TestSetRootfulKeepsOtherDefault(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "notdefault")
+	other, _ := initMachine(t, p, "otherdefault")
+	p.SetState(mc.Name, define.Stopped)
+	setDefaultConnection(t, other.Name)
+
+	require.NoError(t, SetRootful(mc, p, true))
+	assert.True(t, mc.HostUser.Rootful)
+	assert.Equal(t, other.Name, defaultConnection(t))
+}
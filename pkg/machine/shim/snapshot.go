@@ -0,0 +1,84 @@
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// snapshotNameRegexp matches the valid snapshot names.  They are passed to
+// the hypervisor tools and used in file names.
+var snapshotNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// requireStopped returns an error unless the machine is stopped, which
+// is required for its disk image to be consistent
+func requireStopped(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, action string) error {
+	state, err := mp.State(mc, false)
+	if err != nil {
+		return err
+	}
+	if state != machineDefine.Stopped {
+		return fmt.Errorf("machine %q must be stopped to %s: %w", mc.Name, action, machineDefine.ErrWrongState)
+	}
+	return nil
+}
+
+// Snapshot checkpoints the disk image of a stopped machine under the given
+// name, so that it can be rolled back with RevertSnapshot, and records the
+// snapshot in the machine configuration.
+func Snapshot(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, name string) error {
+	if !snapshotNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q: must match %s", name, snapshotNameRegexp.String())
+	}
+	if mc.FindSnapshot(name) != nil {
+		return fmt.Errorf("machine %q already has a snapshot named %q", mc.Name, name)
+	}
+	if err := requireStopped(mc, mp, "take a snapshot"); err != nil {
+		return err
+	}
+
+	mc.Logger().Debugf("taking snapshot %q of machine %q", name, mc.Name)
+	file, err := mp.Snapshot(mc, name)
+	if err != nil {
+		if errors.Is(err, machineDefine.ErrNotImplemented) {
+			return fmt.Errorf("%s machines do not support snapshots: %w", mp.VMType().String(), err)
+		}
+		return fmt.Errorf("taking snapshot %q of machine %q: %w", name, mc.Name, err)
+	}
+
+	mc.Snapshots = append(mc.Snapshots, vmconfigs.Snapshot{
+		Name:     name,
+		Created:  time.Now(),
+		DiskSize: mc.Resources.DiskSize,
+		File:     file,
+	})
+	return mc.Write()
+}
+
+// RevertSnapshot rolls the disk image of a stopped machine back to the
+// snapshot with the given name.  The snapshot is kept, so that the machine
+// can be rolled back to it again.
+func RevertSnapshot(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, name string) error {
+	snapshot := mc.FindSnapshot(name)
+	if snapshot == nil {
+		return fmt.Errorf("machine %q has no snapshot named %q", mc.Name, name)
+	}
+	if err := requireStopped(mc, mp, "restore a snapshot"); err != nil {
+		return err
+	}
+
+	mc.Logger().Debugf("restoring snapshot %q of machine %q", name, mc.Name)
+	if err := mp.RevertSnapshot(mc, snapshot); err != nil {
+		return fmt.Errorf("restoring snapshot %q of machine %q: %w", name, mc.Name, err)
+	}
+
+	// the disk is back to its size when the snapshot was taken
+	if snapshot.DiskSize != 0 {
+		mc.Resources.DiskSize = snapshot.DiskSize
+	}
+	return mc.Write()
+}
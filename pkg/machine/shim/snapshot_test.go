@@ -0,0 +1,59 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"os"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "snapshot")
+	mc.Resources.DiskSize = 20
+	image := mc.ImagePath.GetPath()
+	require.NoError(t, os.WriteFile(image, []byte("before"), 0644))
+
+	for _, name := range []string{"", "-dash", "with space", "../escape"} {
+		assert.ErrorContains(t, Snapshot(mc, p, name), "invalid snapshot name")
+	}
+
+	p.SetState(mc.Name, define.Running)
+	assert.ErrorIs(t, Snapshot(mc, p, "base"), define.ErrWrongState)
+	p.SetState(mc.Name, define.Stopped)
+
+	require.NoError(t, Snapshot(mc, p, "base"))
+	assert.ErrorContains(t, Snapshot(mc, p, "base"), "already has a snapshot")
+	assert.Equal(t, 1, p.Called("Snapshot"))
+
+	// the snapshot is recorded in the machine configuration
+	loaded, err := vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	require.Len(t, loaded.Snapshots, 1)
+	assert.Equal(t, "base", loaded.Snapshots[0].Name)
+	assert.Equal(t, mc.Resources.DiskSize, loaded.Snapshots[0].DiskSize)
+
+	// restoring rolls back both the disk content and its size
+	require.NoError(t, os.WriteFile(image, []byte("after"), 0644))
+	mc.Resources.DiskSize += 10
+	assert.ErrorContains(t, RevertSnapshot(mc, p, "missing"), "no snapshot named")
+	require.NoError(t, RevertSnapshot(mc, p, "base"))
+	content, err := os.ReadFile(image)
+	require.NoError(t, err)
+	assert.Equal(t, "before", string(content))
+	assert.Equal(t, uint64(20), mc.Resources.DiskSize)
+
+	// removing the machine removes its snapshots
+	snapshotFile := loaded.Snapshots[0].File.GetPath()
+	require.FileExists(t, snapshotFile)
+	_, rm, err := mc.Remove(false, false)
+	require.NoError(t, err)
+	require.NoError(t, rm())
+	assert.NoFileExists(t, snapshotFile)
+}
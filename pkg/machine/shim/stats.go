@@ -0,0 +1,54 @@
+package shim
+
+import (
+	"fmt"
+	"time"
+
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// Stats measures the resource usage of the running machine.  Unless the
+// provider measures the CPU load itself, it is computed from the CPU time
+// used since previous, the last sample of the machine, or on average since
+// the machine started when there is no previous sample.
+func Stats(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, previous *vmconfigs.Stats) (*vmconfigs.Stats, error) {
+	state, err := mp.State(mc, false)
+	if err != nil {
+		return nil, err
+	}
+	if state != machineDefine.Running {
+		return nil, fmt.Errorf("machine %q must be running to measure its usage: %w", mc.Name, machineDefine.ErrWrongState)
+	}
+
+	stats, err := mp.Stats(mc)
+	if err != nil {
+		return nil, fmt.Errorf("measuring the usage of machine %q: %w", mc.Name, err)
+	}
+	stats.Sampled = time.Now()
+	if stats.CPUPercent != 0 || stats.CPUTime == 0 {
+		return stats, nil
+	}
+
+	switch {
+	case previous != nil && !previous.Sampled.IsZero() && previous.CPUTime <= stats.CPUTime:
+		stats.CPUPercent = cpuPercent(stats.CPUTime-previous.CPUTime, stats.Sampled.Sub(previous.Sampled), mc.Resources.CPUs)
+	case !mc.LastUp.IsZero():
+		stats.CPUPercent = cpuPercent(stats.CPUTime, stats.Sampled.Sub(mc.LastUp), mc.Resources.CPUs)
+	}
+	return stats, nil
+}
+
+// cpuPercent returns the load of cpus CPUs that used cpuTime during elapsed,
+// 100 when all of them were busy
+func cpuPercent(cpuTime, elapsed time.Duration, cpus uint64) float64 {
+	if elapsed <= 0 || cpus == 0 {
+		return 0
+	}
+	percent := float64(cpuTime) / float64(elapsed) / float64(cpus) * 100
+	// the hypervisor uses CPU time of its own on top of the guest
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
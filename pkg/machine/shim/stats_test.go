@@ -0,0 +1,59 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "stats")
+	mc.Resources.CPUs = 2
+
+	_, err := Stats(mc, p, nil)
+	assert.True(t, errors.Is(err, define.ErrWrongState))
+
+	p.SetState(mc.Name, define.Running)
+	mc.LastUp = time.Now().Add(-10 * time.Second)
+	p.SetStats(
+		vmconfigs.Stats{CPUTime: 10 * time.Second, MemoryUsed: 512 << 20, DiskUsed: 2 << 30},
+		vmconfigs.Stats{CPUTime: 10*time.Second + 50*time.Millisecond},
+	)
+
+	// on average since the machine started, one of two CPUs was busy
+	stats, err := Stats(mc, p, nil)
+	require.NoError(t, err)
+	assert.InDelta(t, 50, stats.CPUPercent, 1)
+	assert.Equal(t, uint64(512<<20), stats.MemoryUsed)
+	assert.Equal(t, uint64(2<<30), stats.DiskUsed)
+	assert.False(t, stats.Sampled.IsZero())
+
+	// since the previous sample, the CPUs were mostly idle
+	previous := *stats
+	previous.Sampled = stats.Sampled.Add(-time.Second)
+	stats, err = Stats(mc, p, &previous)
+	require.NoError(t, err)
+	assert.Less(t, stats.CPUPercent, 5.0)
+
+	// the load measured by the provider is kept
+	p.SetStats(vmconfigs.Stats{CPUPercent: 42})
+	stats, err = Stats(mc, p, &previous)
+	require.NoError(t, err)
+	assert.Equal(t, 42.0, stats.CPUPercent)
+}
+
+func TestCPUPercent(t *testing.T) {
+	assert.Equal(t, 25.0, cpuPercent(time.Second, time.Second, 4))
+	assert.Equal(t, 100.0, cpuPercent(3*time.Second, time.Second, 2))
+	assert.Equal(t, 0.0, cpuPercent(time.Second, 0, 2))
+	assert.Equal(t, 0.0, cpuPercent(time.Second, time.Second, 0))
+}
@@ -0,0 +1,64 @@
+package shim
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+)
+
+// killGrace is how long a graceful stop that timed out may take to return
+// once the machine was forced off
+const killGrace = 10 * time.Second
+
+// stopVM asks the guest to shut down and forces the machine off when it has
+// not stopped within the timeout of opts.  A hard stop forces it off right
+// away.
+func stopVM(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, opts machine.StopOptions) (*machine.StopReport, error) {
+	began := time.Now()
+	if opts.Hard {
+		if err := mp.StopVM(mc, true); err != nil {
+			return nil, err
+		}
+		return &machine.StopReport{Method: machine.StopHard, Duration: time.Since(began)}, nil
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = machine.DefaultStopTimeout
+	}
+	// the provider may hang on a guest that does not shut down, e.g. qemu
+	// waits for its process to exit
+	done := make(chan error, 1)
+	go func() {
+		done <- mp.StopVM(mc, false)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+		return &machine.StopReport{Method: machine.StopGraceful, Duration: time.Since(began)}, nil
+	case <-timer.C:
+	}
+
+	mc.Logger().Warnf("Machine %q did not shut down within %s, forcing it off", mc.Name, timeout)
+	if err := mp.KillVM(mc); err != nil {
+		return nil, fmt.Errorf("forcing machine %q off: %w", mc.Name, err)
+	}
+	// the graceful stop returns once the machine is gone, its error is
+	// about the shutdown that did not happen
+	select {
+	case err := <-done:
+		if err != nil {
+			mc.Logger().Debugf("Graceful stop of machine %q: %v", mc.Name, err)
+		}
+	case <-time.After(killGrace):
+		mc.Logger().Debugf("Graceful stop of machine %q did not return after it was forced off", mc.Name)
+	}
+	return &machine.StopReport{Method: machine.StopForced, Duration: time.Since(began)}, nil
+}
@@ -0,0 +1,73 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine"
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopMethods(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "stop-methods")
+
+	// a stopped machine is not stopped again
+	report, err := Stop(mc, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, report)
+
+	p.SetState(mc.Name, define.Running)
+	report, err = Stop(mc, p, dirs, machine.StopOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, machine.StopGraceful, report.Method)
+
+	p.SetState(mc.Name, define.Running)
+	report, err = Stop(mc, p, dirs, machine.StopOptions{Hard: true})
+	require.NoError(t, err)
+	assert.Equal(t, machine.StopHard, report.Method)
+
+	assert.Equal(t, 2, p.Called("StopVM"))
+	assert.Zero(t, p.Called("KillVM"))
+}
+
+func TestStopTimeoutForcesOff(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "stop-timeout")
+	p.SetState(mc.Name, define.Running)
+	p.Delay("StopVM", 500*time.Millisecond)
+
+	report, err := Stop(mc, p, dirs, machine.StopOptions{Timeout: 20 * time.Millisecond})
+	require.NoError(t, err)
+	assert.Equal(t, machine.StopForced, report.Method)
+	assert.Equal(t, []string{"StopVM", "KillVM"}, filterCalls(p.Calls(), "StopVM", "KillVM"))
+	assert.Equal(t, "forced", report.Attributes()["stop.method"])
+
+	// a machine that cannot be forced off fails the stop
+	p.SetState(mc.Name, define.Running)
+	p.Fail("KillVM", errors.New("permission denied"))
+	_, err = Stop(mc, p, dirs, machine.StopOptions{Timeout: 20 * time.Millisecond})
+	require.ErrorContains(t, err, `forcing machine "stop-timeout" off: permission denied`)
+	require.NotNil(t, mc.LastError)
+	assert.Equal(t, vmconfigs.OperationStop, mc.LastError.Operation)
+}
+
+// filterCalls returns the calls to the given methods, in order
+func filterCalls(calls []string, methods ...string) []string {
+	var filtered []string
+	for _, c := range calls {
+		for _, m := range methods {
+			if c == m {
+				filtered = append(filtered, c)
+			}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,162 @@
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/containers/common/pkg/strongunits"
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/shim/diskpull"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/opencontainers/go-digest"
+)
+
+// latestDiskDigest looks up the disk image published on the image source of
+// a machine, tests replace it to stay offline
+var latestDiskDigest = diskpull.LatestDigest
+
+// OSUpdate describes the disk image published on the image source a machine
+// was created from
+type OSUpdate struct {
+	// Source is the image reference the machine follows
+	Source string
+	// Current is the digest of the disk image of the machine, if known
+	Current digest.Digest
+	// Latest is the digest of the disk image the source points to
+	Latest digest.Digest
+}
+
+// Available reports whether the source has a different disk image than the
+// machine
+func (u *OSUpdate) Available() bool {
+	return u.Current != u.Latest
+}
+
+// CheckOSUpdate looks up the disk image published on the image source of the
+// machine.  Machines created before the source was recorded follow the
+// machine image of the podman version.
+func CheckOSUpdate(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, dirs *machineDefine.MachineDirs) (*OSUpdate, error) {
+	source, latest, err := latestDiskDigest(mc.ImageSource, dirs, mc, mp.VMType())
+	if err != nil {
+		return nil, fmt.Errorf("checking for updates of machine %q: %w", mc.Name, err)
+	}
+	return &OSUpdate{Source: source, Current: mc.ImageDigest, Latest: latest}, nil
+}
+
+// canSwapDisk returns an error unless the disk image of the stopped machine
+// can be replaced.  WSL imports its disk image into a distribution when the
+// machine is created, and snapshots belong to the disk image they were taken
+// from.
+func canSwapDisk(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, action string) error {
+	if mp.VMType() == machineDefine.WSLVirt {
+		return fmt.Errorf("the disk image of %s machines cannot be replaced: %w", mp.VMType().String(), machineDefine.ErrNotImplemented)
+	}
+	if len(mc.Snapshots) > 0 {
+		return fmt.Errorf("machine %q has snapshots, remove them to %s", mc.Name, action)
+	}
+	return requireStopped(mc, mp, action)
+}
+
+// UpgradeDisk replaces the disk image of a stopped machine by the disk image
+// published on its image source, if it changed.  The machine is provisioned
+// again on its next boot and the previous disk image is kept for
+// RollbackDisk.  It reports whether the disk image was replaced.
+func UpgradeDisk(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, dirs *machineDefine.MachineDirs) (bool, error) {
+	if err := canSwapDisk(mc, mp, "upgrade its disk image"); err != nil {
+		return false, err
+	}
+	update, err := CheckOSUpdate(mc, mp, dirs)
+	if err != nil {
+		return false, err
+	}
+	if !update.Available() {
+		return false, nil
+	}
+
+	mc.Logger().Debugf("upgrading the disk image of machine %q from %s to %s", mc.Name, update.Current, update.Latest)
+	rollbackFile, err := mc.RollbackImagePath()
+	if err != nil {
+		return false, err
+	}
+	rollback := rollbackFile.GetPath()
+	if err := rollbackFile.Delete(); err != nil {
+		return false, err
+	}
+	if err := os.Rename(mc.ImagePath.GetPath(), rollback); err != nil {
+		return false, err
+	}
+
+	source, current := mc.ImageSource, mc.ImageDigest
+	if err := mp.GetDisk(update.Source, dirs, mc); err != nil {
+		mc.ImageSource, mc.ImageDigest = source, current
+		if err := os.Rename(rollback, mc.ImagePath.GetPath()); err != nil {
+			mc.Logger().Errorf("could not restore the disk image of machine %q from %s: %v", mc.Name, rollback, err)
+		}
+		return false, fmt.Errorf("upgrading the disk image of machine %q: %w", mc.Name, err)
+	}
+	mc.ImageSource, mc.ImageDigest, mc.PreviousImageDigest = update.Source, update.Latest, current
+
+	// the new disk image is as small as published, grow it to the size of
+	// the machine
+	if mc.Resources.DiskSize > 0 {
+		diskSize := strongunits.GiB(mc.Resources.DiskSize)
+		if err := mp.UpdateResources(mc, machineDefine.SetOptions{DiskSize: &diskSize}); err != nil {
+			return false, fmt.Errorf("resizing the new disk image of machine %q: %w", mc.Name, err)
+		}
+	}
+
+	// the new disk image boots for the first time
+	mc.LastUp = time.Time{}
+	for i := range mc.Hooks.FirstBoot {
+		mc.Hooks.FirstBoot[i].Completed = false
+	}
+	return true, mc.Write()
+}
+
+// RollbackDisk swaps the disk image of a stopped machine with the one kept by
+// the last UpgradeDisk, so that rolling back twice returns to the upgraded
+// disk image.
+func RollbackDisk(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider) error {
+	rollbackFile, err := mc.RollbackImagePath()
+	if err != nil {
+		return err
+	}
+	rollback := rollbackFile.GetPath()
+	if _, err := os.Stat(rollback); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("machine %q has no disk image to roll back to", mc.Name)
+		}
+		return err
+	}
+	if err := canSwapDisk(mc, mp, "roll back its disk image"); err != nil {
+		return err
+	}
+
+	mc.Logger().Debugf("rolling the disk image of machine %q back to %s", mc.Name, mc.PreviousImageDigest)
+	current := mc.ImagePath.GetPath()
+	swap := current + ".swap"
+	if err := os.Rename(current, swap); err != nil {
+		return err
+	}
+	if err := os.Rename(rollback, current); err != nil {
+		if err := os.Rename(swap, current); err != nil {
+			mc.Logger().Errorf("could not restore the disk image of machine %q from %s: %v", mc.Name, swap, err)
+		}
+		return err
+	}
+	if err := os.Rename(swap, rollback); err != nil {
+		return err
+	}
+	mc.ImageDigest, mc.PreviousImageDigest = mc.PreviousImageDigest, mc.ImageDigest
+
+	// the previous disk image was provisioned already
+	if mc.LastUp.IsZero() {
+		mc.LastUp = time.Now()
+	}
+	for i := range mc.Hooks.FirstBoot {
+		mc.Hooks.FirstBoot[i].Completed = true
+	}
+	return mc.Write()
+}
@@ -0,0 +1,103 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeDisk(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "upgrade")
+	current, latest := digest.FromString("current"), digest.FromString("latest")
+	mc.ImageDigest = current
+	mc.Resources.DiskSize = 20
+	mc.LastUp = mc.Created
+	mc.Hooks.FirstBoot = []vmconfigs.Hook{{Command: "true", Completed: true}}
+	require.NoError(t, os.WriteFile(mc.ImagePath.GetPath(), []byte("current"), 0644))
+
+	published := current
+	defaultLatestDiskDigest := latestDiskDigest
+	latestDiskDigest = func(string, *define.MachineDirs, *vmconfigs.MachineConfig, define.VMType) (string, digest.Digest, error) {
+		return "docker://quay.io/podman/machine-os:5.0", published, nil
+	}
+	t.Cleanup(func() {
+		latestDiskDigest = defaultLatestDiskDigest
+	})
+
+	upgraded, err := UpgradeDisk(mc, p, dirs)
+	require.NoError(t, err)
+	assert.False(t, upgraded)
+	assert.ErrorContains(t, RollbackDisk(mc, p), "no disk image to roll back to")
+
+	published = latest
+	p.SetState(mc.Name, define.Running)
+	_, err = UpgradeDisk(mc, p, dirs)
+	assert.ErrorIs(t, err, define.ErrWrongState)
+	p.SetState(mc.Name, define.Stopped)
+
+	mc.Snapshots = []vmconfigs.Snapshot{{Name: "before"}}
+	_, err = UpgradeDisk(mc, p, dirs)
+	assert.ErrorContains(t, err, "has snapshots")
+	mc.Snapshots = nil
+
+	// a failed pull keeps the current disk image
+	p.Fail("GetDisk", errors.New("pull failed"))
+	_, err = UpgradeDisk(mc, p, dirs)
+	assert.ErrorContains(t, err, "pull failed")
+	p.Fail("GetDisk", nil)
+	assertFileContent(t, mc.ImagePath.GetPath(), "current")
+	assert.Equal(t, current, mc.ImageDigest)
+
+	calls := p.Called("UpdateResources")
+	upgraded, err = UpgradeDisk(mc, p, dirs)
+	require.NoError(t, err)
+	assert.True(t, upgraded)
+	assert.Equal(t, calls+1, p.Called("UpdateResources"))
+	rollback, err := mc.RollbackImagePath()
+	require.NoError(t, err)
+	assertFileContent(t, rollback.GetPath(), "current")
+
+	loaded, err := vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	assert.Equal(t, latest, loaded.ImageDigest)
+	assert.Equal(t, current, loaded.PreviousImageDigest)
+	assert.Equal(t, "docker://quay.io/podman/machine-os:5.0", loaded.ImageSource)
+	firstBoot, err := loaded.IsFirstBoot()
+	require.NoError(t, err)
+	assert.True(t, firstBoot)
+	assert.False(t, loaded.Hooks.FirstBoot[0].Completed)
+
+	// rolling back swaps the disk images
+	require.NoError(t, os.WriteFile(mc.ImagePath.GetPath(), []byte("latest"), 0644))
+	require.NoError(t, RollbackDisk(mc, p))
+	assertFileContent(t, mc.ImagePath.GetPath(), "current")
+	assertFileContent(t, rollback.GetPath(), "latest")
+	assert.Equal(t, current, mc.ImageDigest)
+	assert.Equal(t, latest, mc.PreviousImageDigest)
+	firstBoot, err = mc.IsFirstBoot()
+	require.NoError(t, err)
+	assert.False(t, firstBoot)
+
+	vmType := p.Type
+	p.Type = define.WSLVirt
+	_, err = UpgradeDisk(mc, p, dirs)
+	assert.ErrorIs(t, err, define.ErrNotImplemented)
+	p.Type = vmType
+}
+
+func assertFileContent(t *testing.T, path, expected string) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+}
@@ -1,17 +1,51 @@
 package shim
 
 import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
 	"github.com/containers/podman/v5/pkg/machine"
+	machineDefine "github.com/containers/podman/v5/pkg/machine/define"
 	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"golang.org/x/exp/slices"
 )
 
-func CmdLineVolumesToMounts(volumes []string, volumeType vmconfigs.VolumeMountType) []*vmconfigs.Mount {
+// CmdLineVolumesToMounts converts the --volume values to mounts.  Targets are
+// cleaned and must be absolute, unique and must not hide any of the reserved
+// guest paths.  Nested targets are logged to logger.
+func CmdLineVolumesToMounts(volumes []string, volumeType vmconfigs.VolumeMountType, reserved []string, logger machineDefine.Logger) ([]*vmconfigs.Mount, error) {
 	mounts := []*vmconfigs.Mount{}
+	targets := make(map[string]string, len(volumes))
 	for i, volume := range volumes {
 		var mount vmconfigs.Mount
 		tag, source, target, readOnly, _ := vmconfigs.SplitVolume(i, volume)
+		target, err := validateMountTarget(target, reserved)
+		if err != nil {
+			return nil, fmt.Errorf("invalid volume %q: %w", volume, err)
+		}
+		opts, err := vmconfigs.ParseVolumeOptions(volume)
+		if err != nil {
+			return nil, fmt.Errorf("invalid volume %q: %w", volume, err)
+		}
+		if other, found := targets[target]; found {
+			return nil, fmt.Errorf("invalid volume %q: target %q is already used by volume %q", volume, target, other)
+		}
+		for otherTarget, other := range targets {
+			if isSubPath(target, otherTarget) || isSubPath(otherTarget, target) {
+				logger.Warnf("Volume %q and volume %q are nested, make sure they are given in the order they should be mounted", other, volume)
+			}
+		}
+		targets[target] = volume
+
 		switch volumeType {
 		case vmconfigs.VirtIOFS:
+			// the virtiofs device of the hypervisor decides how files
+			// are cached, the guest cannot change it
+			if opts.Cache != "" || opts.MSize != 0 || opts.DirectIO {
+				return nil, fmt.Errorf("invalid volume %q: the cache, msize and direct-io options are not supported by %s volumes", volume, volumeType)
+			}
 			virtioMount := machine.NewVirtIoFsMount(source, target, readOnly)
 			mount = virtioMount.ToMount()
 		default:
@@ -22,9 +56,111 @@ func CmdLineVolumesToMounts(volumes []string, volumeType vmconfigs.VolumeMountTy
 				Target:        target,
 				ReadOnly:      readOnly,
 				OriginalInput: volume,
+				Cache:         opts.Cache,
+				MSize:         opts.MSize,
+				DirectIO:      opts.DirectIO,
 			}
 		}
 		mounts = append(mounts, &mount)
 	}
-	return mounts
+	return mounts, nil
+}
+
+// validateMountTarget returns the cleaned target or an error if it is not
+// absolute or if mounting on it hides a reserved path
+func validateMountTarget(target string, reserved []string) (string, error) {
+	// the guest is always Linux, so the path package is used instead of filepath
+	if !path.IsAbs(target) {
+		return "", fmt.Errorf("target %q must be an absolute path", target)
+	}
+	target = path.Clean(target)
+	for _, r := range reserved {
+		if target == r {
+			return "", fmt.Errorf("target %q is reserved in the machine", target)
+		}
+		if isSubPath(r, target) {
+			return "", fmt.Errorf("target %q would hide %q which is reserved in the machine", target, r)
+		}
+	}
+	return target, nil
+}
+
+// isSubPath reports whether p is below parent
+func isSubPath(p, parent string) bool {
+	if parent == "/" {
+		return p != "/"
+	}
+	return strings.HasPrefix(p, parent+"/")
+}
+
+// AddVolume adds the volume, given in the --volume form, to an existing
+// machine and records it in the machine configuration.  It returns whether
+// the volume was mounted in the running machine right away, otherwise it is
+// mounted on the next start.
+func AddVolume(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, volume string) (bool, error) {
+	mounts, err := CmdLineVolumesToMounts([]string{volume}, mp.MountType(), mp.VMType().ReservedMountTargets(), mc.Logger())
+	if err != nil {
+		return false, err
+	}
+	mount := mounts[0]
+	for _, other := range mc.Mounts {
+		if other.Target == mount.Target {
+			return false, fmt.Errorf("invalid volume %q: target %q is already used by volume %s:%s", volume, mount.Target, other.Source, other.Target)
+		}
+		if isSubPath(mount.Target, other.Target) || isSubPath(other.Target, mount.Target) {
+			mc.Logger().Warnf("Volume %s:%s and volume %q are nested, the added volume is mounted last", other.Source, other.Target, volume)
+		}
+	}
+	if mount.Type == vmconfigs.NineP.String() {
+		mount.Tag = unusedMountTag(mc.Mounts)
+	}
+
+	previous := mc.Mounts
+	mc.Mounts = append(mc.Mounts, mount)
+	attached, err := mp.UpdateMounts(mc, []*vmconfigs.Mount{mount}, nil)
+	if err != nil {
+		mc.Mounts = previous
+		return false, updateMountsError(mc, mp, err)
+	}
+	return attached, mc.Write()
+}
+
+// RemoveVolume removes the volume mounted on target from an existing machine.
+// A running machine keeps the volume mounted until it stops.
+func RemoveVolume(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, target string) error {
+	target = path.Clean(target)
+	i := slices.IndexFunc(mc.Mounts, func(m *vmconfigs.Mount) bool {
+		return m.Target == target
+	})
+	if i < 0 {
+		return fmt.Errorf("machine %q has no volume mounted on %q", mc.Name, target)
+	}
+	mount := mc.Mounts[i]
+
+	previous := mc.Mounts
+	mc.Mounts = slices.Delete(slices.Clone(mc.Mounts), i, i+1)
+	if _, err := mp.UpdateMounts(mc, nil, []*vmconfigs.Mount{mount}); err != nil {
+		mc.Mounts = previous
+		return updateMountsError(mc, mp, err)
+	}
+	return mc.Write()
+}
+
+func updateMountsError(mc *vmconfigs.MachineConfig, mp vmconfigs.VMProvider, err error) error {
+	if errors.Is(err, machineDefine.ErrNotImplemented) {
+		return fmt.Errorf("%s machines do not support volumes: %w", mp.VMType().String(), err)
+	}
+	return fmt.Errorf("updating the volumes of machine %q: %w", mc.Name, err)
+}
+
+// unusedMountTag returns a 9p tag that none of the mounts uses.  The tags of
+// the volumes given at init are numbered after their position, which changes
+// as volumes are removed.
+func unusedMountTag(mounts []*vmconfigs.Mount) string {
+	for i := 0; ; i++ {
+		tag := fmt.Sprintf("vol%d", i)
+		if !slices.ContainsFunc(mounts, func(m *vmconfigs.Mount) bool { return m.Tag == tag }) {
+			return tag
+		}
+	}
 }
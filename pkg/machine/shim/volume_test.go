@@ -0,0 +1,192 @@
+//go:build amd64 || arm64
+
+package shim
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/fakeprovider"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCmdLineVolumesToMounts(t *testing.T) {
+	reserved := define.QemuVirt.ReservedMountTargets()
+	for _, tc := range []struct {
+		name    string
+		volumes []string
+		targets []string
+		err     string
+	}{
+		{
+			name:    "valid",
+			volumes: []string{"/home/user:/home/user", "/tmp/data:/mnt/data:ro"},
+			targets: []string{"/home/user", "/mnt/data"},
+		},
+		{
+			name:    "cleaned",
+			volumes: []string{"/src:/mnt//data/../data/"},
+			targets: []string{"/mnt/data"},
+		},
+		{
+			name:    "below reserved parent",
+			volumes: []string{"/var/folders:/var/folders", "/src:/etc/extra"},
+			targets: []string{"/var/folders", "/etc/extra"},
+		},
+		{
+			name:    "nested user mounts",
+			volumes: []string{"/a:/mnt/a", "/b:/mnt/a/b"},
+			targets: []string{"/mnt/a", "/mnt/a/b"},
+		},
+		{
+			name:    "root",
+			volumes: []string{"/src:/"},
+			err:     `invalid volume "/src:/": target "/" is reserved in the machine`,
+		},
+		{
+			name:    "reserved",
+			volumes: []string{"/src:/usr"},
+			err:     `invalid volume "/src:/usr": target "/usr" is reserved in the machine`,
+		},
+		{
+			name:    "reserved after cleaning",
+			volumes: []string{"/src:/etc/"},
+			err:     `invalid volume "/src:/etc/": target "/etc" is reserved in the machine`,
+		},
+		{
+			name:    "containers storage",
+			volumes: []string{"/src:/var/lib/containers"},
+			err:     `invalid volume "/src:/var/lib/containers": target "/var/lib/containers" is reserved in the machine`,
+		},
+		{
+			name:    "hides reserved",
+			volumes: []string{"/src:/var"},
+			err:     `invalid volume "/src:/var": target "/var" would hide "/var/lib/containers" which is reserved in the machine`,
+		},
+		{
+			name:    "relative",
+			volumes: []string{"/src:data"},
+			err:     `invalid volume "/src:data": target "data" must be an absolute path`,
+		},
+		{
+			name:    "duplicate",
+			volumes: []string{"/a:/mnt/data", "/b:/mnt/data/"},
+			err:     `invalid volume "/b:/mnt/data/": target "/mnt/data" is already used by volume "/a:/mnt/data"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mounts, err := CmdLineVolumesToMounts(tc.volumes, vmconfigs.NineP, reserved, define.LoggerOrStandard(nil))
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			targets := make([]string, 0, len(mounts))
+			for _, mount := range mounts {
+				targets = append(targets, mount.Target)
+			}
+			assert.Equal(t, tc.targets, targets)
+		})
+	}
+}
+
+func TestCmdLineVolumesToMountsOptions(t *testing.T) {
+	reserved := define.QemuVirt.ReservedMountTargets()
+	mounts, err := CmdLineVolumesToMounts([]string{"/src:/src:ro,cache=always,msize=524288"}, vmconfigs.NineP, reserved, define.LoggerOrStandard(nil))
+	require.NoError(t, err)
+	assert.True(t, mounts[0].ReadOnly)
+	assert.Equal(t, vmconfigs.CacheAlways, mounts[0].Cache)
+	assert.Equal(t, uint32(524288), mounts[0].MSize)
+
+	_, err = CmdLineVolumesToMounts([]string{"/src:/src:cache=sometimes"}, vmconfigs.NineP, reserved, define.LoggerOrStandard(nil))
+	assert.ErrorContains(t, err, `cache "sometimes" must be`)
+
+	// the hypervisor decides how virtiofs volumes are cached
+	mounts, err = CmdLineVolumesToMounts([]string{"/src:/src:ro"}, vmconfigs.VirtIOFS, reserved, define.LoggerOrStandard(nil))
+	require.NoError(t, err)
+	assert.True(t, mounts[0].ReadOnly)
+	_, err = CmdLineVolumesToMounts([]string{"/src:/src:cache=auto"}, vmconfigs.VirtIOFS, reserved, define.LoggerOrStandard(nil))
+	assert.ErrorContains(t, err, "not supported by virtiofs volumes")
+}
+
+func TestReservedMountTargets(t *testing.T) {
+	assert.Contains(t, define.WSLVirt.ReservedMountTargets(), "/mnt/wsl")
+	assert.NotContains(t, define.QemuVirt.ReservedMountTargets(), "/mnt/wsl")
+	assert.Contains(t, define.AppleHvVirt.ReservedMountTargets(), "/sysroot")
+
+	_, err := CmdLineVolumesToMounts([]string{"/src:/mnt/wsl"}, vmconfigs.NineP, define.WSLVirt.ReservedMountTargets(), define.LoggerOrStandard(nil))
+	assert.Error(t, err)
+	_, err = CmdLineVolumesToMounts([]string{"/src:/mnt/wsl"}, vmconfigs.NineP, define.QemuVirt.ReservedMountTargets(), define.LoggerOrStandard(nil))
+	assert.NoError(t, err)
+}
+
+func mountTargets(mounts []*vmconfigs.Mount) []string {
+	targets := make([]string, 0, len(mounts))
+	for _, m := range mounts {
+		targets = append(targets, m.Target)
+	}
+	return targets
+}
+
+func TestAddRemoveVolume(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, dirs := initMachine(t, p, "volumes")
+
+	attached, err := AddVolume(mc, p, "/src:/mnt/src/")
+	require.NoError(t, err)
+	assert.False(t, attached)
+	_, err = AddVolume(mc, p, "/data:/mnt/data:ro")
+	require.NoError(t, err)
+	assert.Equal(t, 2, p.Called("UpdateMounts"))
+
+	saved, err := vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/mnt/src", "/mnt/data"}, mountTargets(saved.Mounts))
+	assert.True(t, saved.Mounts[1].ReadOnly)
+
+	_, err = AddVolume(mc, p, "/other:/mnt/src")
+	assert.ErrorContains(t, err, "already used")
+	_, err = AddVolume(mc, p, "/src:/usr")
+	assert.ErrorContains(t, err, "reserved")
+
+	require.NoError(t, RemoveVolume(mc, p, "/mnt/src/"))
+	assert.Equal(t, []string{"/mnt/data"}, mountTargets(mc.Mounts))
+	assert.ErrorContains(t, RemoveVolume(mc, p, "/mnt/src"), "no volume mounted")
+
+	saved, err = vmconfigs.LoadMachineByName(mc.Name, dirs)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/mnt/data"}, mountTargets(saved.Mounts))
+}
+
+func TestAddVolumeProvider(t *testing.T) {
+	p := fakeprovider.New(t)
+	mc, _ := initMachine(t, p, "volumes-provider")
+
+	p.AttachMounts = true
+	attached, err := AddVolume(mc, p, "/src:/mnt/src")
+	require.NoError(t, err)
+	assert.True(t, attached)
+
+	// the configuration is left alone when the provider fails
+	p.Fail("UpdateMounts", errors.New("boom"))
+	_, err = AddVolume(mc, p, "/data:/mnt/data")
+	assert.ErrorContains(t, err, "boom")
+	assert.ErrorContains(t, RemoveVolume(mc, p, "/mnt/src"), "boom")
+	assert.Equal(t, []string{"/mnt/src"}, mountTargets(mc.Mounts))
+
+	p.Fail("UpdateMounts", define.ErrNotImplemented)
+	_, err = AddVolume(mc, p, "/data:/mnt/data")
+	assert.ErrorIs(t, err, define.ErrNotImplemented)
+}
+
+func TestUnusedMountTag(t *testing.T) {
+	assert.Equal(t, "vol0", unusedMountTag(nil))
+	// vol0 was removed, vol1 is still used
+	mounts := []*vmconfigs.Mount{{Tag: "vol1"}, {Tag: "vol2"}}
+	assert.Equal(t, "vol0", unusedMountTag(mounts))
+	mounts = append(mounts, &vmconfigs.Mount{Tag: "vol0"})
+	assert.Equal(t, "vol3", unusedMountTag(mounts))
+}
@@ -0,0 +1,96 @@
+package sockets
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// readyPrefix starts every line a guest sends on its ready socket
+const readyPrefix = "Ready"
+
+// NetworkOffline is the network status of a guest without a default route
+const NetworkOffline = "offline"
+
+// ReadyStatus is the status payload a guest sends on its ready socket once
+// it is booted.  Guests created by older versions of Podman only send the
+// "Ready" ping, without a payload.
+type ReadyStatus struct {
+	// Ignition is the result of the ignition run of the guest
+	Ignition string `json:"ignition,omitempty"`
+	// Unit is the name of the systemd unit that reported ready
+	Unit string `json:"unit,omitempty"`
+	// Uptime is the time in seconds since the guest kernel booted
+	Uptime string `json:"uptime,omitempty"`
+	// FailedUnits are the systemd units that failed while the guest booted
+	FailedUnits []string `json:"failed_units,omitempty"`
+	// Network is "online" when the guest has a default route,
+	// NetworkOffline otherwise
+	Network string `json:"network,omitempty"`
+	// IgnitionErrors are the last errors ignition logged
+	IgnitionErrors []string `json:"ignition_errors,omitempty"`
+}
+
+func (s *ReadyStatus) String() string {
+	return fmt.Sprintf("ignition=%s unit=%s uptime=%ss network=%s failed_units=%d", s.Ignition, s.Unit, s.Uptime, s.Network, len(s.FailedUnits))
+}
+
+// Problems describes what went wrong while the guest booted, for the errors
+// of a machine that does not become usable
+func (s *ReadyStatus) Problems() []string {
+	var problems []string
+	if len(s.FailedUnits) > 0 {
+		problems = append(problems, "failed units: "+strings.Join(s.FailedUnits, ", "))
+	}
+	if s.Network == NetworkOffline {
+		problems = append(problems, "the network of the guest is offline")
+	}
+	for _, e := range s.IgnitionErrors {
+		problems = append(problems, "ignition: "+e)
+	}
+	return problems
+}
+
+// ParseReadyPayload parses a line received on a ready socket.  A plain
+// "Ready" ping returns a nil status.
+func ParseReadyPayload(line string) (*ReadyStatus, error) {
+	line = strings.TrimSpace(line)
+	payload, ok := strings.CutPrefix(line, readyPrefix)
+	if !ok {
+		return nil, fmt.Errorf("unexpected ready payload %q", line)
+	}
+	payload = strings.TrimSpace(payload)
+	if payload == "" {
+		return nil, nil
+	}
+	status := new(ReadyStatus)
+	if err := json.Unmarshal([]byte(payload), status); err != nil {
+		return nil, fmt.Errorf("parsing ready payload %q: %w", payload, err)
+	}
+	return status, nil
+}
+
+// ReadReady reads the line a guest sends on its ready socket and logs the
+// status it carries.  A payload that cannot be parsed is only logged, the
+// guest is booted regardless.
+func ReadReady(r io.Reader) (*ReadyStatus, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !(err == io.EOF && line != "") {
+		return nil, err
+	}
+	status, err := ParseReadyPayload(line)
+	switch {
+	case err != nil:
+		logrus.Warnf("Ignoring ready payload: %v", err)
+		return nil, nil
+	case status == nil:
+		logrus.Debug("ready ack received")
+	default:
+		logrus.Debugf("ready ack received: %s", status)
+	}
+	return status, nil
+}
@@ -0,0 +1,83 @@
+package sockets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReadyPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    *ReadyStatus
+		wantErr string
+	}{
+		{name: "empty ping", line: "Ready\n"},
+		{name: "ping without newline", line: "Ready"},
+		{
+			name: "payload",
+			line: `Ready {"ignition":"success","unit":"ready.service","uptime":"12.34"}` + "\n",
+			want: &ReadyStatus{Ignition: "success", Unit: "ready.service", Uptime: "12.34"},
+		},
+		{
+			name: "partial payload",
+			line: `Ready {"unit":"ready.service"}`,
+			want: &ReadyStatus{Unit: "ready.service"},
+		},
+		{
+			name: "diagnostics",
+			line: `Ready {"unit":"ready.service","failed_units":["sshd.service"],"network":"offline","ignition_errors":["no \"user\""]}`,
+			want: &ReadyStatus{Unit: "ready.service", FailedUnits: []string{"sshd.service"}, Network: NetworkOffline, IgnitionErrors: []string{`no "user"`}},
+		},
+		{name: "garbage", line: "hello\n", wantErr: "unexpected ready payload"},
+		{name: "bad json", line: "Ready {not json\n", wantErr: "parsing ready payload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := ParseReadyPayload(tt.line)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, status)
+		})
+	}
+}
+
+func TestReadReady(t *testing.T) {
+	status, err := ReadReady(strings.NewReader(`Ready {"ignition":"success"}` + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, &ReadyStatus{Ignition: "success"}, status)
+
+	status, err = ReadReady(strings.NewReader("Ready\n"))
+	require.NoError(t, err)
+	assert.Nil(t, status)
+
+	// a payload that cannot be parsed still means the guest booted
+	status, err = ReadReady(strings.NewReader("Ready {\n"))
+	require.NoError(t, err)
+	assert.Nil(t, status)
+
+	// the guest closing the connection without sending anything is an error
+	_, err = ReadReady(strings.NewReader(""))
+	assert.Error(t, err)
+}
+
+func TestReadyStatusProblems(t *testing.T) {
+	assert.Empty(t, (&ReadyStatus{Ignition: "success", Network: "online"}).Problems())
+
+	status := &ReadyStatus{
+		FailedUnits:    []string{"sshd.service", "podman.socket"},
+		Network:        NetworkOffline,
+		IgnitionErrors: []string{"failed to fetch config"},
+	}
+	assert.Equal(t, []string{
+		"failed units: sshd.service, podman.socket",
+		"the network of the guest is offline",
+		"ignition: failed to fetch config",
+	}, status.Problems())
+}
@@ -1,7 +1,6 @@
 package sockets
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"net"
@@ -30,17 +29,20 @@ func ReadySocketPath(runtimeDir, machineName string) string {
 }
 
 // ListenAndWaitOnSocket waits for a new connection to the listener and sends
-// any error back through the channel. ListenAndWaitOnSocket is intended to be
-// used as a goroutine
-func ListenAndWaitOnSocket(errChan chan<- error, listener net.Listener) {
+// any error back through the channel.  The status the guest sends is stored
+// in status unless it is nil.  ListenAndWaitOnSocket is intended to be used
+// as a goroutine
+func ListenAndWaitOnSocket(errChan chan<- error, listener net.Listener, status *ReadyStatus) {
 	conn, err := listener.Accept()
 	if err != nil {
 		logrus.Debug("failed to connect to ready socket")
 		errChan <- err
 		return
 	}
-	_, err = bufio.NewReader(conn).ReadString('\n')
-	logrus.Debug("ready ack received")
+	ready, err := ReadReady(conn)
+	if ready != nil && status != nil {
+		*status = *ready
+	}
 
 	if closeErr := conn.Close(); closeErr != nil {
 		errChan <- closeErr
@@ -1,6 +1,7 @@
 package machine
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -25,14 +26,39 @@ func CommonSSHWithStdin(username, identityPath, name string, sshPort int, inputA
 	return commonSSH(username, identityPath, name, sshPort, inputArgs, false, stdin)
 }
 
-func commonSSH(username, identityPath, name string, sshPort int, inputArgs []string, silent bool, stdin io.Reader) error {
+// CommonSSHWithOutput runs the given command in the machine and returns its
+// combined output.  The command is killed when ctx is done.
+func CommonSSHWithOutput(ctx context.Context, username, identityPath string, sshPort int, inputArgs []string) ([]byte, error) {
+	args := append(sshArgs(username, identityPath, sshPort), inputArgs...)
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	logrus.Debugf("Executing: ssh %v\n", args)
+	return cmd.CombinedOutput()
+}
+
+// CommonSSHWithWriter runs the given command in the machine and writes its
+// output to w as it comes, its errors to stderr.  The command is killed when
+// ctx is done.
+func CommonSSHWithWriter(ctx context.Context, username, identityPath string, sshPort int, inputArgs []string, w io.Writer) error {
+	args := append(sshArgs(username, identityPath, sshPort), inputArgs...)
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	logrus.Debugf("Executing: ssh %v\n", args)
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func sshArgs(username, identityPath string, sshPort int) []string {
 	sshDestination := username + "@localhost"
 	port := strconv.Itoa(sshPort)
-	interactive := true
-
-	args := []string{"-i", identityPath, "-p", port, sshDestination,
+	return []string{"-i", identityPath, "-p", port, sshDestination,
 		"-o", "IdentitiesOnly=yes",
 		"-o", "StrictHostKeyChecking=no", "-o", "LogLevel=ERROR", "-o", "SetEnv=LC_ALL="}
+}
+
+func commonSSH(username, identityPath, name string, sshPort int, inputArgs []string, silent bool, stdin io.Reader) error {
+	interactive := true
+
+	args := sshArgs(username, identityPath, sshPort)
 	if len(inputArgs) > 0 {
 		interactive = false
 		args = append(args, inputArgs...)
@@ -0,0 +1,30 @@
+package machine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	psutil "github.com/shirou/gopsutil/v3/process"
+)
+
+// ProcessStats returns the CPU time and the resident memory of the process
+// running a VM, for the providers that run a process per machine
+func ProcessStats(pid int) (*vmconfigs.Stats, error) {
+	p, err := psutil.NewProcess(int32(pid))
+	if err != nil {
+		return nil, fmt.Errorf("looking up PID %d: %w", pid, err)
+	}
+	times, err := p.Times()
+	if err != nil {
+		return nil, fmt.Errorf("reading CPU time of PID %d: %w", pid, err)
+	}
+	memory, err := p.MemoryInfo()
+	if err != nil {
+		return nil, fmt.Errorf("reading memory usage of PID %d: %w", pid, err)
+	}
+	return &vmconfigs.Stats{
+		CPUTime:    time.Duration((times.User + times.System) * float64(time.Second)),
+		MemoryUsed: memory.RSS,
+	}, nil
+}
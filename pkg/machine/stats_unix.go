@@ -0,0 +1,21 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package machine
+
+import (
+	"os"
+	"syscall"
+)
+
+// DiskUsage returns the bytes allocated on the host for the disk image at
+// path, which is less than its size for sparse images
+func DiskUsage(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Blocks) * 512, nil
+	}
+	return uint64(info.Size()), nil
+}
@@ -0,0 +1,14 @@
+package machine
+
+import "os"
+
+// DiskUsage returns the size of the disk image at path.  The dynamic VHDX
+// images of Hyper-V grow as they are written to, so their size is what they
+// use.
+func DiskUsage(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(info.Size()), nil
+}
@@ -10,10 +10,13 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/containers/podman/v5/pkg/machine/compression"
 	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/diskcache"
 	"github.com/containers/podman/v5/utils"
+	"github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
 )
 
@@ -21,6 +24,10 @@ type DiskFromURL struct {
 	u            *url2.URL
 	finalPath    *define.VMFile
 	tempLocation *define.VMFile
+	// cache and expected are set when the image is downloaded into the
+	// disk image cache rather than to tempLocation
+	cache    *diskcache.Cache
+	expected digest.Digest
 }
 
 func NewDiskFromURL(inputPath string, finalPath *define.VMFile, tempDir *define.VMFile, optionalTempFileName *string) (*DiskFromURL, error) {
@@ -59,7 +66,31 @@ func NewDiskFromURL(inputPath string, finalPath *define.VMFile, tempDir *define.
 	}, nil
 }
 
+// NewCachedDiskFromURL downloads the disk image into the disk image cache,
+// where it is stored under its digest.  When expected is set the download is
+// verified against it, and skipped if the image is already cached.
+func NewCachedDiskFromURL(inputPath string, expected digest.Digest, finalPath *define.VMFile, cache *diskcache.Cache) (*DiskFromURL, error) {
+	u, err := url2.Parse(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if expected != "" {
+		if err := expected.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid digest for %q: %w", inputPath, err)
+		}
+	}
+	return &DiskFromURL{
+		u:         u,
+		finalPath: finalPath,
+		cache:     cache,
+		expected:  expected,
+	}, nil
+}
+
 func (d *DiskFromURL) Get() error {
+	if d.cache != nil {
+		return d.getCached()
+	}
 	// this fetches the image and writes it to the temporary location
 	if err := d.pull(); err != nil {
 		return err
@@ -68,18 +99,73 @@ func (d *DiskFromURL) Get() error {
 	return compression.Decompress(d.tempLocation, d.finalPath.GetPath())
 }
 
+func (d *DiskFromURL) getCached() error {
+	suffix := diskcache.KindAndCompression(path.Base(d.u.Path))
+	var (
+		cached *define.VMFile
+		ok     bool
+		err    error
+	)
+	// the partial download is named after what is downloaded so that
+	// it is only resumed by a pull of the same image
+	key := digest.FromString(d.u.String()).Encoded()
+	if d.expected != "" {
+		if cached, ok, err = d.cache.Lookup(d.expected, suffix); err != nil {
+			return err
+		}
+		key = d.expected.Encoded()
+	}
+	if !ok {
+		partial, err := d.cache.PartialPath(key)
+		if err != nil {
+			return err
+		}
+		if err := d.download(path.Base(d.u.Path), partial.GetPath()); err != nil {
+			return err
+		}
+		if cached, _, err = d.cache.StoreFile(partial.GetPath(), d.expected, suffix); err != nil {
+			return err
+		}
+	}
+	logrus.Debugf("decompressing (if needed) %s to %s", cached.GetPath(), d.finalPath.GetPath())
+	return compression.Decompress(cached, d.finalPath.GetPath())
+}
+
 func (d *DiskFromURL) pull() error {
-	out, err := os.Create(d.tempLocation.GetPath())
+	partial := d.tempLocation.GetPath() + ".partial"
+	if err := d.download(filepath.Base(d.tempLocation.GetPath()), partial); err != nil {
+		return err
+	}
+	return os.Rename(partial, d.tempLocation.GetPath())
+}
+
+// download fetches the image into the file at partial.  What an interrupted
+// download left in that file is kept and the rest of the image is requested
+// with a range request, unless the server does not support them in which
+// case the download starts over.
+func (d *DiskFromURL) download(name, partial string) error {
+	out, err := os.OpenFile(partial, os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		if err := out.Close(); err != nil {
+		if err := out.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
 			logrus.Error(err)
 		}
 	}()
+	offset, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
 
-	resp, err := http.Get(d.u.String())
+	req, err := http.NewRequest(http.MethodGet, d.u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -89,14 +175,49 @@ func (d *DiskFromURL) pull() error {
 		}
 	}()
 
-	if resp.StatusCode != http.StatusOK {
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+		logrus.Debugf("resuming the download of %s at byte %d", d.u.String(), offset)
+	case resp.StatusCode == http.StatusOK:
+		if offset > 0 {
+			logrus.Debugf("%s cannot be resumed, downloading it again", d.u.String())
+			if err := out.Truncate(0); err != nil {
+				return err
+			}
+			if offset, err = out.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// the previous download stopped after the last byte was written
+		if resp.Header.Get("Content-Range") == fmt.Sprintf("bytes */%d", offset) {
+			return nil
+		}
+		fallthrough
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		// the partial file does not match what the server has, e.g. the
+		// image was updated in the meantime
+		logrus.Debugf("partial download of %s does not match the image, downloading it again", d.u.String())
+		if err := out.Close(); err != nil {
+			return err
+		}
+		if err := os.Remove(partial); err != nil {
+			return err
+		}
+		return d.download(name, partial)
+	default:
 		return fmt.Errorf("downloading VM image %s: %s", d.u.String(), resp.Status)
 	}
+
 	size := resp.ContentLength
-	prefix := "Downloading VM image: " + filepath.Base(d.tempLocation.GetPath())
+	if size >= 0 {
+		size += offset
+	}
+	prefix := "Downloading VM image: " + name
 	onComplete := prefix + ": done"
 
 	p, bar := utils.ProgressBar(prefix, size, onComplete)
+	bar.SetCurrent(offset)
 
 	proxyReader := bar.ProxyReader(resp.Body)
 	defer func() {
@@ -108,7 +229,12 @@ func (d *DiskFromURL) pull() error {
 	if _, err := io.Copy(out, proxyReader); err != nil {
 		return err
 	}
+	if size < 0 {
+		// the server did not tell the size, complete the bar so that
+		// waiting for it returns
+		bar.SetTotal(-1, true)
+	}
 
 	p.Wait()
-	return nil
+	return out.Close()
 }
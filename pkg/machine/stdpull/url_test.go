@@ -0,0 +1,76 @@
+package stdpull
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	url2 "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadResumes(t *testing.T) {
+	image := []byte(strings.Repeat("disk image ", 1000))
+	var ranges []string
+	rangeSupport := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if !rangeSupport {
+			_, _ = w.Write(image)
+			return
+		}
+		http.ServeContent(w, r, "image.raw", time.Time{}, bytes.NewReader(image))
+	}))
+	defer srv.Close()
+	u, err := url2.Parse(srv.URL + "/image.raw")
+	require.NoError(t, err)
+	d := &DiskFromURL{u: u}
+	partial := filepath.Join(t.TempDir(), "image.raw.partial")
+
+	tests := []struct {
+		name         string
+		existing     []byte
+		rangeSupport bool
+		wantRange    string
+	}{
+		{name: "new download", rangeSupport: true},
+		{name: "interrupted download", existing: image[:4000], rangeSupport: true, wantRange: "bytes=4000-"},
+		{name: "no range support", existing: image[:4000], wantRange: "bytes=4000-"},
+		{name: "complete download", existing: image, rangeSupport: true, wantRange: "bytes=11000-"},
+		{name: "different image", existing: append(image, "more"...), rangeSupport: true, wantRange: "bytes=11004-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = os.Remove(partial)
+			if tt.existing != nil {
+				require.NoError(t, os.WriteFile(partial, tt.existing, 0644))
+			}
+			ranges = nil
+			rangeSupport = tt.rangeSupport
+
+			require.NoError(t, d.download("image.raw", partial))
+			got, err := os.ReadFile(partial)
+			require.NoError(t, err)
+			assert.Equal(t, image, got)
+			require.NotEmpty(t, ranges)
+			assert.Equal(t, tt.wantRange, ranges[0])
+		})
+	}
+}
+
+func TestDownloadFailure(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	u, err := url2.Parse(srv.URL + "/image.raw")
+	require.NoError(t, err)
+	d := &DiskFromURL{u: u}
+
+	err = d.download("image.raw", filepath.Join(t.TempDir(), "image.raw.partial"))
+	assert.ErrorContains(t, err, "404")
+}
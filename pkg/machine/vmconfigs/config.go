@@ -1,12 +1,16 @@
 package vmconfigs
 
 import (
+	"fmt"
+	"io"
 	"time"
 
 	gvproxy "github.com/containers/gvisor-tap-vsock/pkg/types"
 	"github.com/containers/podman/v5/pkg/machine/define"
 	"github.com/containers/podman/v5/pkg/machine/ignition"
+	"github.com/containers/podman/v5/pkg/machine/sockets"
 	"github.com/containers/storage/pkg/lockfile"
+	"github.com/opencontainers/go-digest"
 )
 
 const MachineConfigVersion = 1
@@ -23,6 +27,7 @@ type MachineConfig struct {
 	Name   string
 
 	Resources ResourceConfig
+	Network   NetworkConfig
 	SSH       SSHConfig
 	Version   uint
 
@@ -30,6 +35,17 @@ type MachineConfig struct {
 	imageDescription machineImage //nolint:unused
 
 	ImagePath *define.VMFile // Temporary only until a proper image struct is worked out
+	// ImageSource is the disk image the machine was created from: the
+	// reference of the machine image or what was given to --image-path
+	ImageSource string `json:",omitempty"`
+	// ImageDigest is the digest of the disk image, when it is known
+	ImageDigest digest.Digest `json:",omitempty"`
+	// PreviousImageDigest is the digest of the disk image kept by the last
+	// os upgrade, which the machine can be rolled back to
+	PreviousImageDigest digest.Digest `json:",omitempty"`
+	// Provisioner configures the machine on its first boot, empty for
+	// ignition
+	Provisioner define.Provisioner `json:",omitempty"`
 
 	// Provider stuff
 	AppleHypervisor  *AppleHVConfig `json:",omitempty"`
@@ -45,10 +61,45 @@ type MachineConfig struct {
 	// used for deriving file, socket, etc locations
 	dirs *define.MachineDirs
 
+	// logger receives the log messages about the machine
+	logger define.Logger
+
 	// State
 
 	// Starting is defined as "on" but not fully booted
 	Starting bool
+	// ReadyStatus is what the guest reported on its ready socket when it
+	// last booted.  It is only known to the process that started the
+	// machine and is not saved.
+	ReadyStatus sockets.ReadyStatus `json:"-"`
+
+	// Hooks are commands run in the guest during the machine lifecycle
+	Hooks Hooks
+	// HostHooks are commands run on the host during the machine lifecycle
+	HostHooks HostHooks
+	// RestartPolicy tells what the monitor of the running machine restarts
+	// when it fails, empty for define.RestartNo
+	RestartPolicy define.RestartPolicy `json:",omitempty"`
+	// ClockSync steps the clock of the running machine to the time of the
+	// host when they drift apart, e.g. after the host slept
+	ClockSync bool `json:",omitempty"`
+
+	// LastError describes the last failed start, stop or set operation
+	LastError *OperationError `json:",omitempty"`
+	// Degraded describes why the last start only partially succeeded, e.g.
+	// because a post-start hook failed
+	Degraded string `json:",omitempty"`
+
+	// ProxyEnv are the proxy variables of the host, in the KEY=value form,
+	// applied to the machine when it last started or refreshed them
+	ProxyEnv []string `json:",omitempty"`
+
+	// Snapshots are the checkpoints of the disk image, oldest first
+	Snapshots []Snapshot `json:",omitempty"`
+
+	// GuestConfig are the host files copied into the guest to configure
+	// its container tools
+	GuestConfig GuestConfig
 }
 
 type machineImage interface { //nolint:unused
@@ -74,6 +125,20 @@ func (o OCIMachineImage) download() error {
 }
 
 type VMProvider interface { //nolint:interfacebloat
+	// AttachConsole connects to the serial console of the running machine,
+	// which works before the machine is reachable over SSH.  Providers
+	// without a serial console return define.ErrNotImplemented.
+	AttachConsole(mc *MachineConfig) (io.ReadWriteCloser, error)
+	// Capabilities tells which of the optional features of podman machine
+	// the provider supports
+	Capabilities() define.Capabilities
+	// CompactDisk frees the space of the disk image of a stopped machine
+	// that the guest does not use.  Providers that cannot compact the disk
+	// return define.ErrNotImplemented.
+	CompactDisk(mc *MachineConfig) error
+	// ConvertDisk writes the disk image src of a stopped machine to dst in the
+	// given format and verifies the result
+	ConvertDisk(mc *MachineConfig, src, dst *define.VMFile, format define.ImageFormat) error
 	CreateVM(opts define.CreateVMOpts, mc *MachineConfig, builder *ignition.IgnitionBuilder) error
 	// GetDisk should be only temporary.  It is largely here only because WSL disk pulling is different
 	// TODO
@@ -83,14 +148,48 @@ type VMProvider interface { //nolint:interfacebloat
 	Exists(name string) (bool, error)
 	MountType() VolumeMountType
 	MountVolumesToVM(mc *MachineConfig, quiet bool) error
+	// UpdateMounts is called once mounts were added to or removed from the
+	// machine configuration.  Providers that can attach the added mounts to
+	// the running machine do so and return true, the others prepare them
+	// for the next start and return false.  Providers that do not mount
+	// volumes return define.ErrNotImplemented.
+	UpdateMounts(mc *MachineConfig, added, removed []*Mount) (bool, error)
 	Remove(mc *MachineConfig) ([]string, func() error, error)
 	RemoveAndCleanMachines(dirs *define.MachineDirs) error
+	// RunningConfig returns the configuration of the running machine as
+	// reported by the hypervisor
+	RunningConfig(mc *MachineConfig) (*RunningConfig, error)
 	SetProviderAttrs(mc *MachineConfig, opts define.SetOptions) error
+	// Snapshot checkpoints the disk image of a stopped machine under the
+	// given name.  It returns the file holding the snapshot when it is kept
+	// apart from the disk image, nil otherwise.  Providers that cannot
+	// checkpoint the disk return define.ErrNotImplemented.
+	Snapshot(mc *MachineConfig, name string) (*define.VMFile, error)
+	// RevertSnapshot rolls the disk image of a stopped machine back to the
+	// given snapshot, which is kept
+	RevertSnapshot(mc *MachineConfig, snapshot *Snapshot) error
+	// UpdateResources applies the CPUs, memory and disk size set in opts to
+	// a stopped machine, growing its disk image and adjusting the
+	// hypervisor configuration as needed.  The machine configuration still
+	// holds the previous resources.
+	UpdateResources(mc *MachineConfig, opts define.SetOptions) error
 	StartNetworking(mc *MachineConfig, cmd *gvproxy.GvproxyCommand) error
 	PostStartNetworking(mc *MachineConfig, noInfo bool) error
 	StartVM(mc *MachineConfig) (func() error, func() error, error)
+	// StartRecovery boots the machine with the given override so that it
+	// drops to a minimal shell for repair.  Providers that cannot change
+	// how the guest boots return define.ErrNotImplemented.
+	StartRecovery(mc *MachineConfig, override define.RecoveryOverride) error
 	State(mc *MachineConfig, bypass bool) (define.Status, error)
+	// Stats returns the resource usage of the running machine as seen from
+	// the host.  Providers that cannot measure it return
+	// define.ErrNotImplemented.
+	Stats(mc *MachineConfig) (*Stats, error)
 	StopVM(mc *MachineConfig, hardStop bool) error
+	// KillVM forces the machine off without a shutdown of the guest.  It
+	// does not take the lock of the machine since it is called while a
+	// graceful StopVM that hangs holds it.
+	KillVM(mc *MachineConfig) error
 	StopHostNetworking(mc *MachineConfig, vmType define.VMType) error
 	VMType() define.VMType
 	UserModeNetworkEnabled(mc *MachineConfig) bool
@@ -98,6 +197,114 @@ type VMProvider interface { //nolint:interfacebloat
 	RequireExclusiveActive() bool
 }
 
+// RunningConfig is the configuration of a running machine as reported by its
+// provider.  Values the provider cannot report are left zero, or nil, and are
+// not compared with the machine configuration.
+type RunningConfig struct {
+	// CPUs assigned to the VM
+	CPUs uint64
+	// Memory in megabytes assigned to the VM
+	Memory uint64
+	// DiskSize in gigabytes of the VM disk
+	DiskSize uint64
+	// Mounts are the targets of the volumes mounted in the guest
+	Mounts []string
+	// UserModeNetworking reports whether user mode networking is in use
+	UserModeNetworking *bool
+}
+
+// Stats is the resource usage of a running machine as reported by its
+// provider.  Values the provider cannot report are left zero.
+type Stats struct {
+	// CPUTime is the CPU time used by the VM since it started
+	CPUTime time.Duration
+	// CPUPercent is the load of the CPUs of the VM, set by providers that
+	// measure it themselves and otherwise computed from CPUTime
+	CPUPercent float64
+	// MemoryUsed in bytes by the VM on the host
+	MemoryUsed uint64
+	// DiskUsed in bytes by the disk image on the host
+	DiskUsed uint64
+	// Sampled is when the usage was measured
+	Sampled time.Time
+}
+
+// ConfigDiscrepancy is a setting whose value in the machine configuration
+// differs from the running machine
+type ConfigDiscrepancy struct {
+	// Setting is the name of the setting, e.g. "CPUs"
+	Setting string
+	// Configured is the value in the machine configuration
+	Configured string
+	// Running is the value of the running machine
+	Running string
+}
+
+func (d ConfigDiscrepancy) String() string {
+	return fmt.Sprintf("%s: config says %s, running machine has %s", d.Setting, d.Configured, d.Running)
+}
+
+// Hooks are commands run in the guest at given points of the machine lifecycle
+type Hooks struct {
+	// PreStop hooks are run before the machine is stopped
+	PreStop []Hook `json:",omitempty"`
+	// PostStart hooks are run every time the machine has started
+	PostStart []Hook `json:",omitempty"`
+	// FirstBoot hooks are run once, after the first successful start
+	FirstBoot []Hook `json:",omitempty"`
+}
+
+// HostHooks are commands run on the host at given points of the machine
+// lifecycle, e.g. to bring up a VPN or mount a network share
+type HostHooks struct {
+	// PreStart hooks are run before the machine is started
+	PreStart []Hook `json:",omitempty"`
+	// PostStart hooks are run every time the machine has started
+	PostStart []Hook `json:",omitempty"`
+	// PreStop hooks are run before the machine is stopped
+	PreStop []Hook `json:",omitempty"`
+	// PostStop hooks are run once the machine has stopped
+	PostStop []Hook `json:",omitempty"`
+}
+
+// Hook is a command run in the guest over SSH or on the host
+type Hook struct {
+	// Command is run by the shell of the remote user, or by the shell of
+	// the host for host hooks
+	Command string
+	// Required hooks abort the operation if they fail
+	Required bool `json:",omitempty"`
+	// Timeout is how long the hook may run, the default is used when zero
+	Timeout time.Duration `json:",omitempty"`
+	// Completed is set once a first boot hook succeeded so it is not run again
+	Completed bool `json:",omitempty"`
+}
+
+// GuestConfig are the host files copied into the guest to configure its
+// container tools, e.g. with the mirrors and the signature policy of an
+// organization.  Empty paths are not copied.
+type GuestConfig struct {
+	// ContainersConf is a containers.conf fragment
+	ContainersConf string `json:",omitempty"`
+	// RegistriesConf is a registries.conf fragment
+	RegistriesConf string `json:",omitempty"`
+	// SignaturePolicy replaces the policy.json of the guest
+	SignaturePolicy string `json:",omitempty"`
+	// Pending is set when the files changed since they were last copied
+	// into the guest, they are copied on its next start
+	Pending bool `json:",omitempty"`
+}
+
+// OperationError records the outcome of a failed machine operation
+type OperationError struct {
+	// Operation is the name of the operation that failed, e.g. "start"
+	Operation string
+	// Time the operation failed
+	Time time.Time
+	// Error is the error message, truncated to a reasonable length
+	Error string
+}
+
 // HostUser describes the host user
 type HostUser struct {
 	// Whether this machine should run in a rootful or rootless manner
@@ -116,6 +323,11 @@ type Mount struct {
 	Target        string
 	Type          string
 	VSockNumber   *uint64
+	// Cache, MSize and DirectIO are the VolumeOptions of the same names,
+	// translated by the provider
+	Cache    string `json:",omitempty"`
+	MSize    uint32 `json:",omitempty"`
+	DirectIO bool   `json:",omitempty"`
 }
 
 // ResourceConfig describes physical attributes of the machine
@@ -128,6 +340,23 @@ type ResourceConfig struct {
 	Memory uint64
 	// Usbs
 	USBs []define.USBConfig
+	// GPU the machine can use, nil for none
+	GPU *define.GPUConfig `json:",omitempty"`
+	// Firmware selects secure boot and a TPM, nil for the default firmware
+	// of the provider
+	Firmware *define.FirmwareConfig `json:",omitempty"`
+}
+
+// NetworkConfig describes how the machine is reached from the host.  The
+// machine is always in the define.GuestSubnet network of gvproxy, with the
+// define.GuestIP address.
+type NetworkConfig struct {
+	// Ports are published on the host by gvproxy every time the machine
+	// starts
+	Ports []define.PortForward `json:",omitempty"`
+	// WSLMode is the networking mode WSL is switched to when the machine
+	// starts, empty to leave WSL as it is configured
+	WSLMode define.WSLNetworkingMode `json:",omitempty"`
 }
 
 // SSHConfig contains remote access information for SSH
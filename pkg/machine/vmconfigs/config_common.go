@@ -14,6 +14,12 @@ type QEMUConfig struct {
 	QMPMonitor command.Monitor
 	// QEMUPidPath is where to write the PID for QEMU when running
 	QEMUPidPath *define.VMFile
+	// Firmware is the secure boot firmware the machine boots, nil for the
+	// default firmware
+	Firmware *command.Firmware `json:",omitempty"`
+	// TPMState is the directory where swtpm keeps the TPM of the machine,
+	// nil when the machine has no TPM
+	TPMState *define.VMFile `json:",omitempty"`
 }
 
 // Stubs
@@ -0,0 +1,56 @@
+package vmconfigs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+)
+
+// requiredHookPrefix marks a hook given on the command line as required
+const requiredHookPrefix = "required:"
+
+// ParseHooks parses hooks as given on the command line.  A hook prefixed
+// with "required:" aborts the operation it is attached to when it fails.
+func ParseHooks(inputs []string) ([]Hook, error) {
+	hooks := make([]Hook, 0, len(inputs))
+	for _, input := range inputs {
+		hook := Hook{Command: input}
+		if cmd, found := strings.CutPrefix(input, requiredHookPrefix); found {
+			hook.Command = cmd
+			hook.Required = true
+		}
+		hook.Command = strings.TrimSpace(hook.Command)
+		if hook.Command == "" {
+			return nil, errors.New("hook command must not be empty")
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// Parse replaces the host hooks of the stages set in opts by the parsed hooks
+func (h *HostHooks) Parse(opts define.HostHookOptions) error {
+	stages := []struct {
+		name   string
+		inputs *[]string
+		hooks  *[]Hook
+	}{
+		{"pre-start", opts.PreStart, &h.PreStart},
+		{"post-start", opts.PostStart, &h.PostStart},
+		{"pre-stop", opts.PreStop, &h.PreStop},
+		{"post-stop", opts.PostStop, &h.PostStop},
+	}
+	for _, stage := range stages {
+		if stage.inputs == nil {
+			continue
+		}
+		hooks, err := ParseHooks(*stage.inputs)
+		if err != nil {
+			return fmt.Errorf("host %s hook: %w", stage.name, err)
+		}
+		*stage.hooks = hooks
+	}
+	return nil
+}
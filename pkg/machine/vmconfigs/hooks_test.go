@@ -0,0 +1,34 @@
+package vmconfigs
+
+import (
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHooks(t *testing.T) {
+	hooks, err := ParseHooks([]string{"sync", "required: pg_ctl checkpoint"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Hook{
+		{Command: "sync"},
+		{Command: "pg_ctl checkpoint", Required: true},
+	}, hooks)
+
+	_, err = ParseHooks([]string{"required:  "})
+	assert.Error(t, err)
+}
+
+func TestHostHooksParse(t *testing.T) {
+	h := HostHooks{
+		PreStart: []Hook{{Command: "vpn up"}},
+		PostStop: []Hook{{Command: "vpn down"}},
+	}
+	preStart := []string{"required: mount-share"}
+	postStop := []string{}
+	assert.NoError(t, h.Parse(define.HostHookOptions{PreStart: &preStart, PostStop: &postStop}))
+	assert.Equal(t, HostHooks{PreStart: []Hook{{Command: "mount-share", Required: true}}, PostStop: []Hook{}}, h)
+
+	preStop := []string{" "}
+	assert.ErrorContains(t, h.Parse(define.HostHookOptions{PreStop: &preStop}), "host pre-stop hook")
+}
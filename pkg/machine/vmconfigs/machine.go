@@ -15,7 +15,6 @@ import (
 	"github.com/containers/podman/v5/pkg/machine/connection"
 	"github.com/containers/podman/v5/pkg/machine/define"
 	"github.com/containers/podman/v5/pkg/machine/lock"
-	"github.com/containers/podman/v5/utils"
 	"github.com/containers/storage/pkg/ioutils"
 	"github.com/sirupsen/logrus"
 )
@@ -33,6 +32,21 @@ import (
   stop        Stop an existing machine					specific
 */
 
+const (
+	OperationStart = "start"
+	OperationStop  = "stop"
+	OperationSet   = "set"
+
+	// maxOperationErrorLen is the longest error message stored in the config
+	maxOperationErrorLen = 512
+
+	// legacyAPISocketName and legacyGVProxyPidFileName are the API socket
+	// and gvproxy pid file of machines started by versions of Podman that
+	// ran one machine at a time and did not name them after the machine
+	legacyAPISocketName      = "podman.sock"
+	legacyGVProxyPidFileName = "gvproxy.pid"
+)
+
 var (
 	SSHRemoteConnection     RemoteConnectionType = "ssh"
 	DefaultIgnitionUserName                      = "core"
@@ -46,6 +60,7 @@ func NewMachineConfig(opts define.InitOptions, dirs *define.MachineDirs, sshIden
 	mc := new(MachineConfig)
 	mc.Name = opts.Name
 	mc.dirs = dirs
+	mc.logger = opts.Logger
 
 	machineLock, err := lock.GetMachineLock(opts.Name, dirs.ConfigDir.GetPath())
 	if err != nil {
@@ -61,7 +76,7 @@ func NewMachineConfig(opts define.InitOptions, dirs *define.MachineDirs, sshIden
 	mc.configPath = cf
 
 	if vmtype != define.QemuVirt && len(opts.USBs) > 0 {
-		return nil, fmt.Errorf("USB host passthrough not supported for %s machines", vmtype)
+		return nil, &define.ErrUSBPassthrough{VMType: vmtype}
 	}
 
 	usbs, err := define.ParseUSBs(opts.USBs)
@@ -69,24 +84,72 @@ func NewMachineConfig(opts define.InitOptions, dirs *define.MachineDirs, sshIden
 		return nil, err
 	}
 
+	gpu, err := define.ParseGPUs(opts.GPUs)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkGPUSupport(vmtype, gpu); err != nil {
+		return nil, err
+	}
+
 	// System Resources
 	mrc := ResourceConfig{
 		CPUs:     opts.CPUS,
 		DiskSize: opts.DiskSize,
 		Memory:   opts.Memory,
 		USBs:     usbs,
+		GPU:      gpu,
+		Firmware: define.NewFirmwareConfig(opts.SecureBoot, opts.TPM),
 	}
 	mc.Resources = mrc
 
-	// TODO WSL had a locking port mechanism, we should consider this.
-	sshPort, err := utils.GetRandomPort()
+	ports, err := define.ParsePortForwards(opts.Ports)
 	if err != nil {
 		return nil, err
 	}
+	if err := CheckPortSupport(vmtype, ports); err != nil {
+		return nil, err
+	}
+	mc.Network.Ports = ports
 
+	wslMode, err := define.ParseWSLNetworkingMode(opts.WSLNetworking)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckWSLNetworkingSupport(vmtype, wslMode, opts.UserModeNetworking != nil && *opts.UserModeNetworking); err != nil {
+		return nil, err
+	}
+	mc.Network.WSLMode = wslMode
+
+	restartPolicy, err := define.ParseRestartPolicy(opts.RestartPolicy)
+	if err != nil {
+		return nil, err
+	}
+	mc.RestartPolicy = restartPolicy
+	mc.ClockSync = opts.ClockSync
+
+	preStopHooks, err := ParseHooks(opts.PreStopHooks)
+	if err != nil {
+		return nil, err
+	}
+	mc.Hooks.PreStop = preStopHooks
+	postStartHooks, err := ParseHooks(opts.PostStartHooks)
+	if err != nil {
+		return nil, err
+	}
+	mc.Hooks.PostStart = postStartHooks
+	firstBootHooks, err := ParseHooks(opts.FirstBootHooks)
+	if err != nil {
+		return nil, err
+	}
+	mc.Hooks.FirstBoot = firstBootHooks
+	if err := mc.HostHooks.Parse(opts.HostHooks); err != nil {
+		return nil, err
+	}
+
+	// The SSH port is reserved by the caller, see shim.Init
 	sshConfig := SSHConfig{
 		IdentityPath:   sshIdentityPath,
-		Port:           sshPort,
 		RemoteUsername: opts.Username,
 	}
 
@@ -98,6 +161,52 @@ func NewMachineConfig(opts define.InitOptions, dirs *define.MachineDirs, sshIden
 	return mc, nil
 }
 
+// checkGPUSupport checks that machines of vmtype can use gpu.  QEMU passes
+// GPUs bound to vfio-pci through and WSL shares all GPUs of the host with
+// GPU-PV.
+func checkGPUSupport(vmtype define.VMType, gpu *define.GPUConfig) error {
+	if gpu == nil {
+		return nil
+	}
+	switch vmtype {
+	case define.QemuVirt:
+		return nil
+	case define.WSLVirt:
+		if len(gpu.Devices) > 0 {
+			return fmt.Errorf("WSL machines share all GPUs of the host, use --gpus %s", define.AllGPUs)
+		}
+		return nil
+	default:
+		return fmt.Errorf("GPU access is not supported for %s machines: %w", vmtype.String(), define.ErrNotImplemented)
+	}
+}
+
+// CheckPortSupport checks that machines of vmtype can publish ports.  The
+// ports are published by gvproxy, which WSL machines do not use: WSL
+// forwards the ports the guest listens on to localhost itself.
+func CheckPortSupport(vmtype define.VMType, ports []define.PortForward) error {
+	if len(ports) > 0 && vmtype == define.WSLVirt {
+		return fmt.Errorf("publishing ports is not supported for %s machines, WSL forwards the ports of the machine to localhost: %w", vmtype.String(), define.ErrNotImplemented)
+	}
+	return nil
+}
+
+// CheckWSLNetworkingSupport checks that machines of vmtype can set the
+// networking mode of WSL.  Mirrored networking cannot be combined with
+// user-mode networking, which replaces the routes of WSL.
+func CheckWSLNetworkingSupport(vmtype define.VMType, mode define.WSLNetworkingMode, userModeNetworking bool) error {
+	if mode == "" {
+		return nil
+	}
+	if vmtype != define.WSLVirt {
+		return fmt.Errorf("the WSL networking mode cannot be set for %s machines: %w", vmtype.String(), define.ErrNotImplemented)
+	}
+	if mode == define.WSLNetworkingMirrored && userModeNetworking {
+		return errors.New("mirrored WSL networking cannot be combined with user-mode networking")
+	}
+	return nil
+}
+
 // Lock creates a lock on the machine for single access
 func (mc *MachineConfig) Lock() {
 	mc.lock.Lock()
@@ -146,6 +255,56 @@ func (mc *MachineConfig) SetRootful(rootful bool) error {
 	return nil
 }
 
+// RecordOperationResult persists the outcome of operation.  A failure is
+// stored as the last error of the machine and a success clears an earlier
+// failure of the same operation.  Only the last error is written to the
+// configuration file on disk: mc may hold changes of a failed operation that
+// must not be saved.  Errors writing the configuration are only logged so
+// that they never mask the result of the operation.
+func (mc *MachineConfig) RecordOperationResult(operation string, opErr error) {
+	var lastError *OperationError
+	if opErr == nil {
+		if mc.LastError == nil || mc.LastError.Operation != operation {
+			return
+		}
+	} else {
+		msg := opErr.Error()
+		if len(msg) > maxOperationErrorLen {
+			msg = msg[:maxOperationErrorLen] + "..."
+		}
+		lastError = &OperationError{
+			Operation: operation,
+			Time:      time.Now(),
+			Error:     msg,
+		}
+	}
+	mc.LastError = lastError
+	if err := mc.writeLastError(); err != nil {
+		logrus.Errorf("Unable to record result of machine %s: %v", operation, err)
+	}
+}
+
+// writeLastError updates the last error in the configuration file on disk
+// and leaves the other settings stored there untouched
+func (mc *MachineConfig) writeLastError() error {
+	if mc.configPath == nil {
+		return fmt.Errorf("no configuration file associated with vm %q", mc.Name)
+	}
+	mc.Lock()
+	defer mc.Unlock()
+	b, err := mc.configPath.Read()
+	if err != nil {
+		return err
+	}
+	stored := new(MachineConfig)
+	if err := json.Unmarshal(b, stored); err != nil {
+		return fmt.Errorf("unable to load machine config file: %q", err)
+	}
+	stored.configPath = mc.configPath
+	stored.LastError = mc.LastError
+	return stored.write()
+}
+
 func (mc *MachineConfig) removeSystemConnection() error { //nolint:unused
 	return define2.ErrNotImplemented
 }
@@ -195,11 +354,36 @@ func (mc *MachineConfig) Remove(saveIgnition, saveImage bool) ([]string, func()
 			if err := ignitionFile.Delete(); err != nil {
 				errs = append(errs, err)
 			}
+			if seed, err := mc.CloudInitSeed(); err == nil {
+				if err := seed.Delete(); err != nil {
+					errs = append(errs, err)
+				}
+			}
 		}
 		if !saveImage {
 			if err := mc.ImagePath.Delete(); err != nil {
 				errs = append(errs, err)
 			}
+			if rollback, err := mc.RollbackImagePath(); err == nil {
+				if err := rollback.Delete(); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			for _, snapshot := range mc.Snapshots {
+				if snapshot.File == nil {
+					continue
+				}
+				if err := snapshot.File.Delete(); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+		if identity, err := mc.MachineIdentityPath(); err == nil && identity.GetPath() == mc.SSH.IdentityPath {
+			for _, path := range []string{identity.GetPath(), identity.GetPath() + ".pub"} {
+				if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+					errs = append(errs, err)
+				}
+			}
 		}
 		if err := readySocket.Delete(); err != nil {
 			errs = append(errs, err)
@@ -217,6 +401,33 @@ func (mc *MachineConfig) Remove(saveIgnition, saveImage bool) ([]string, func()
 	return rmFiles, mcRemove, nil
 }
 
+// RollbackImagePath is where os upgrade keeps the disk image it replaced
+func (mc *MachineConfig) RollbackImagePath() (*define.VMFile, error) {
+	if mc.ImagePath == nil {
+		return nil, errors.New("no image path set")
+	}
+	return define.NewMachineFile(mc.ImagePath.GetPath()+".rollback", nil)
+}
+
+// Monitored reports whether the running machine is watched by a monitor,
+// which restarts it according to its restart policy and keeps its clock in
+// sync
+func (mc *MachineConfig) Monitored() bool {
+	return mc.RestartPolicy.Monitored() || mc.ClockSync
+}
+
+// MachineIdentityPath is where the SSH identity of the machine is kept when
+// it does not share the identity of the machines created on this host: the
+// identity an imported machine came with, or the one its keys were
+// regenerated to
+func (mc *MachineConfig) MachineIdentityPath() (*define.VMFile, error) {
+	dataDir, err := mc.DataDir()
+	if err != nil {
+		return nil, err
+	}
+	return dataDir.AppendToNewVMFile(mc.Name+"-identity", nil)
+}
+
 // ConfigDir is a simple helper to obtain the machine config dir
 func (mc *MachineConfig) ConfigDir() (*define.VMFile, error) {
 	if mc.dirs == nil || mc.dirs.ConfigDir == nil {
@@ -245,6 +456,23 @@ func (mc *MachineConfig) SetDirs(dirs *define.MachineDirs) {
 	mc.dirs = dirs
 }
 
+// SetLogger makes the messages about the machine go to l, e.g. a logrus
+// entry carrying the fields of an embedder.  A nil l restores the standard
+// logrus logger.  It is meant to be called before the machine is handled.
+func (mc *MachineConfig) SetLogger(l define.Logger) {
+	mc.logger = l
+}
+
+// Logger returns where the messages about the machine go
+func (mc *MachineConfig) Logger() define.Logger {
+	return define.LoggerOrStandard(mc.logger)
+}
+
+// ConfigFile is the configuration file of the machine
+func (mc *MachineConfig) ConfigFile() *define.VMFile {
+	return mc.configPath
+}
+
 func (mc *MachineConfig) IgnitionFile() (*define.VMFile, error) {
 	configDir, err := mc.ConfigDir()
 	if err != nil {
@@ -253,6 +481,16 @@ func (mc *MachineConfig) IgnitionFile() (*define.VMFile, error) {
 	return configDir.AppendToNewVMFile(mc.Name+".ign", nil)
 }
 
+// CloudInitSeed is the NoCloud seed disk of the machine, for the machines
+// provisioned by cloud-init
+func (mc *MachineConfig) CloudInitSeed() (*define.VMFile, error) {
+	configDir, err := mc.ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return configDir.AppendToNewVMFile(mc.Name+"-seed.iso", nil)
+}
+
 func (mc *MachineConfig) ReadySocket() (*define.VMFile, error) {
 	rtDir, err := mc.RuntimeDir()
 	if err != nil {
@@ -261,6 +499,16 @@ func (mc *MachineConfig) ReadySocket() (*define.VMFile, error) {
 	return readySocket(mc.Name, rtDir)
 }
 
+// ConsoleSocket is the socket of the serial console of the machine, for the
+// providers that expose it as a socket
+func (mc *MachineConfig) ConsoleSocket() (*define.VMFile, error) {
+	rtDir, err := mc.RuntimeDir()
+	if err != nil {
+		return nil, err
+	}
+	return consoleSocket(mc.Name, rtDir)
+}
+
 func (mc *MachineConfig) GVProxySocket() (*define.VMFile, error) {
 	machineRuntimeDir, err := mc.RuntimeDir()
 	if err != nil {
@@ -269,6 +517,129 @@ func (mc *MachineConfig) GVProxySocket() (*define.VMFile, error) {
 	return gvProxySocket(mc.Name, machineRuntimeDir)
 }
 
+// GVProxyAPISocket is the socket gvproxy serves its HTTP API on, through
+// which the ports of the machine are published
+func (mc *MachineConfig) GVProxyAPISocket() (*define.VMFile, error) {
+	machineRuntimeDir, err := mc.RuntimeDir()
+	if err != nil {
+		return nil, err
+	}
+	return gvProxyAPISocket(mc.Name, machineRuntimeDir)
+}
+
+// GVProxyPidFile is the pid file of the gvproxy process forwarding the
+// network of the machine
+func (mc *MachineConfig) GVProxyPidFile() (*define.VMFile, error) {
+	rtDir, err := mc.RuntimeDir()
+	if err != nil {
+		return nil, err
+	}
+	return rtDir.AppendToNewVMFile(mc.Name+"-gvproxy.pid", nil)
+}
+
+// RunningGVProxyPidFile is the pid file of the gvproxy of the running
+// machine.  It is the legacy pid file shared by all machines of the provider
+// if the machine was started by an older version of Podman.
+func (mc *MachineConfig) RunningGVProxyPidFile() (*define.VMFile, error) {
+	if mc.startedByLegacyPodman() {
+		return mc.legacyGVProxyPidFile()
+	}
+	return mc.GVProxyPidFile()
+}
+
+func (mc *MachineConfig) legacyGVProxyPidFile() (*define.VMFile, error) {
+	rtDir, err := mc.RuntimeDir()
+	if err != nil {
+		return nil, err
+	}
+	return rtDir.AppendToNewVMFile(legacyGVProxyPidFileName, nil)
+}
+
+// startedByLegacyPodman reports whether the machine runs without its own
+// gvproxy pid file and API socket while an older version of Podman left its
+// gvproxy pid file.  Only one machine could run with these versions, so the
+// legacy files belong to this machine if it runs.
+func (mc *MachineConfig) startedByLegacyPodman() bool {
+	for _, f := range []func() (*define.VMFile, error){mc.GVProxyPidFile, mc.APISocket} {
+		file, err := f()
+		if err != nil || fileExists(file) {
+			return false
+		}
+	}
+	legacy, err := mc.legacyGVProxyPidFile()
+	return err == nil && fileExists(legacy)
+}
+
+func fileExists(f *define.VMFile) bool {
+	_, err := os.Lstat(f.GetPath())
+	return err == nil
+}
+
+// MonitorPidFile is the pid file of the monitor restarting the machine
+// according to its restart policy
+func (mc *MachineConfig) MonitorPidFile() (*define.VMFile, error) {
+	rtDir, err := mc.RuntimeDir()
+	if err != nil {
+		return nil, err
+	}
+	return rtDir.AppendToNewVMFile(mc.Name+"-monitor.pid", nil)
+}
+
+// MonitorLogFile is where the monitor of the machine logs what it restarts
+func (mc *MachineConfig) MonitorLogFile() (*define.VMFile, error) {
+	rtDir, err := mc.RuntimeDir()
+	if err != nil {
+		return nil, err
+	}
+	return rtDir.AppendToNewVMFile(mc.Name+"-monitor.log", nil)
+}
+
+// APISocket is the host socket the Podman API of the machine is forwarded
+// to.  It is named after the machine so that the sockets of machines running
+// at the same time do not collide.
+func (mc *MachineConfig) APISocket() (*define.VMFile, error) {
+	dataDir, err := mc.DataDir()
+	if err != nil {
+		return nil, err
+	}
+	sockName := mc.Name + "-api.sock"
+	return define.NewMachineFile(filepath.Join(dataDir.Path, sockName), &sockName)
+}
+
+// LegacyAPISocket is the host socket the Podman API was forwarded to by
+// older versions of Podman, before the sockets were named after the
+// machines.  Clients may still be configured to use it.
+func (mc *MachineConfig) LegacyAPISocket() (*define.VMFile, error) {
+	dataDir, err := mc.DataDir()
+	if err != nil {
+		return nil, err
+	}
+	sockName := legacyAPISocketName
+	return define.NewMachineFile(filepath.Join(dataDir.Path, sockName), &sockName)
+}
+
+// SecondaryAPISuffix names the secondary API socket and pipe of the machine
+// after the mode they forward, the one the machine does not default to
+func (mc *MachineConfig) SecondaryAPISuffix() string {
+	if mc.HostUser.Rootful {
+		return "-rootless"
+	}
+	return "-root"
+}
+
+// SecondaryAPISocket is the host socket the Podman API of the mode the
+// machine does not default to, rootless or rootful, is forwarded to.  With
+// both forwarded, clients switch between them without restarting the
+// machine.
+func (mc *MachineConfig) SecondaryAPISocket() (*define.VMFile, error) {
+	dataDir, err := mc.DataDir()
+	if err != nil {
+		return nil, err
+	}
+	sockName := mc.Name + mc.SecondaryAPISuffix() + "-api.sock"
+	return define.NewMachineFile(filepath.Join(dataDir.Path, sockName), &sockName)
+}
+
 func (mc *MachineConfig) LogFile() (*define.VMFile, error) {
 	rtDir, err := mc.RuntimeDir()
 	if err != nil {
@@ -304,16 +675,26 @@ func (mc *MachineConfig) IsFirstBoot() (bool, error) {
 }
 
 func (mc *MachineConfig) ConnectionInfo(vmtype define.VMType) (*define.VMFile, *define.VMFile, error) {
-	var (
-		socket *define.VMFile
-		pipe   *define.VMFile
-	)
+	return mc.connectionInfo(vmtype, false)
+}
+
+// SecondaryConnectionInfo returns the socket and pipe the Podman API of the
+// mode the machine does not default to is forwarded to
+func (mc *MachineConfig) SecondaryConnectionInfo(vmtype define.VMType) (*define.VMFile, *define.VMFile, error) {
+	return mc.connectionInfo(vmtype, true)
+}
+
+func (mc *MachineConfig) connectionInfo(vmtype define.VMType, secondary bool) (*define.VMFile, *define.VMFile, error) {
+	var pipe *define.VMFile
 
 	if vmtype == define.HyperVVirt || vmtype == define.WSLVirt {
 		pipeName := mc.Name
 		if !strings.HasPrefix(pipeName, "podman") {
 			pipeName = "podman-" + pipeName
 		}
+		if secondary {
+			pipeName += mc.SecondaryAPISuffix()
+		}
 		pipe = &define.VMFile{Path: `\\.\pipe\` + pipeName}
 	}
 
@@ -321,15 +702,18 @@ func (mc *MachineConfig) ConnectionInfo(vmtype define.VMType) (*define.VMFile, *
 		return nil, pipe, nil
 	}
 
-	sockName := "podman.sock"
-	dataDir, err := mc.DataDir()
+	socketF := mc.APISocket
+	if secondary {
+		socketF = mc.SecondaryAPISocket
+	} else if mc.startedByLegacyPodman() {
+		socketF = mc.LegacyAPISocket
+	}
+	socket, err := socketF()
 	if err != nil {
-		logrus.Errorf("Resolving data dir: %s", err.Error())
+		logrus.Errorf("Resolving API socket: %s", err.Error())
 		return nil, nil, err
 	}
-
-	socket, err = define.NewMachineFile(filepath.Join(dataDir.Path, sockName), &sockName)
-	return socket, pipe, err
+	return socket, pipe, nil
 }
 
 // LoadMachineByName returns a machine config based on the vm name and provider
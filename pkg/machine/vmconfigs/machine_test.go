@@ -0,0 +1,65 @@
+package vmconfigs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecondaryConnectionInfo(t *testing.T) {
+	dataDir := t.TempDir()
+	mc := &MachineConfig{Name: "dev"}
+	mc.SetDirs(&define.MachineDirs{DataDir: &define.VMFile{Path: dataDir}})
+
+	// a rootless machine also forwards the rootful API
+	socket, pipe, err := mc.SecondaryConnectionInfo(define.HyperVVirt)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dataDir, "dev-root-api.sock"), socket.GetPath())
+	assert.Equal(t, `\\.\pipe\podman-dev-root`, pipe.GetPath())
+
+	primary, _, err := mc.ConnectionInfo(define.QemuVirt)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dataDir, "dev-api.sock"), primary.GetPath())
+
+	// and a rootful one the rootless API
+	mc.HostUser.Rootful = true
+	socket, pipe, err = mc.SecondaryConnectionInfo(define.WSLVirt)
+	require.NoError(t, err)
+	assert.Nil(t, socket)
+	assert.Equal(t, `\\.\pipe\podman-dev-rootless`, pipe.GetPath())
+}
+
+func TestLegacyPodmanFiles(t *testing.T) {
+	dataDir, runtimeDir := t.TempDir(), t.TempDir()
+	mc := &MachineConfig{Name: "dev"}
+	mc.SetDirs(&define.MachineDirs{
+		DataDir:    &define.VMFile{Path: dataDir},
+		RuntimeDir: &define.VMFile{Path: runtimeDir},
+	})
+
+	pidFile, err := mc.RunningGVProxyPidFile()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(runtimeDir, "dev-gvproxy.pid"), pidFile.GetPath())
+
+	// a machine started by an older version of Podman
+	require.NoError(t, os.WriteFile(filepath.Join(runtimeDir, "gvproxy.pid"), []byte("1"), 0o644))
+	pidFile, err = mc.RunningGVProxyPidFile()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(runtimeDir, "gvproxy.pid"), pidFile.GetPath())
+	socket, _, err := mc.ConnectionInfo(define.QemuVirt)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dataDir, "podman.sock"), socket.GetPath())
+
+	// the files of the machine take precedence
+	require.NoError(t, os.WriteFile(filepath.Join(runtimeDir, "dev-gvproxy.pid"), []byte("2"), 0o644))
+	pidFile, err = mc.RunningGVProxyPidFile()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(runtimeDir, "dev-gvproxy.pid"), pidFile.GetPath())
+	socket, _, err = mc.ConnectionInfo(define.QemuVirt)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dataDir, "dev-api.sock"), socket.GetPath())
+}
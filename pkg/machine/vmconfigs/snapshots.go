@@ -0,0 +1,32 @@
+package vmconfigs
+
+import (
+	"time"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+)
+
+// Snapshot is a checkpoint of the disk image of a machine
+type Snapshot struct {
+	// Name identifies the snapshot among the snapshots of the machine
+	Name string
+	// Created is when the snapshot was taken
+	Created time.Time
+	// DiskSize in gigabytes of the disk when the snapshot was taken, it
+	// is restored with the snapshot
+	DiskSize uint64
+	// File holds the snapshot when the provider keeps it apart from the
+	// disk image, e.g. an APFS clone of it
+	File *define.VMFile `json:",omitempty"`
+}
+
+// FindSnapshot returns the snapshot of the machine with the given name, or
+// nil if there is none
+func (mc *MachineConfig) FindSnapshot(name string) *Snapshot {
+	for i := range mc.Snapshots {
+		if mc.Snapshots[i].Name == name {
+			return &mc.Snapshots[i]
+		}
+	}
+	return nil
+}
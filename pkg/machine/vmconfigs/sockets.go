@@ -12,6 +12,14 @@ func gvProxySocket(name string, machineRuntimeDir *define.VMFile) (*define.VMFil
 	return machineRuntimeDir.AppendToNewVMFile(fmt.Sprintf("%s-gvproxy.sock", name), nil)
 }
 
+func gvProxyAPISocket(name string, machineRuntimeDir *define.VMFile) (*define.VMFile, error) {
+	return machineRuntimeDir.AppendToNewVMFile(name+"-gvproxy-api.sock", nil)
+}
+
 func readySocket(name string, machineRuntimeDir *define.VMFile) (*define.VMFile, error) {
 	return machineRuntimeDir.AppendToNewVMFile(name+".sock", nil)
 }
+
+func consoleSocket(name string, machineRuntimeDir *define.VMFile) (*define.VMFile, error) {
+	return machineRuntimeDir.AppendToNewVMFile(name+"-console.sock", nil)
+}
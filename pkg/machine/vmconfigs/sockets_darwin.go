@@ -11,7 +11,17 @@ func gvProxySocket(name string, machineRuntimeDir *define.VMFile) (*define.VMFil
 	return machineRuntimeDir.AppendToNewVMFile(socketName, &socketName)
 }
 
+func gvProxyAPISocket(name string, machineRuntimeDir *define.VMFile) (*define.VMFile, error) {
+	socketName := name + "-gvproxy-api.sock"
+	return machineRuntimeDir.AppendToNewVMFile(socketName, &socketName)
+}
+
 func readySocket(name string, machineRuntimeDir *define.VMFile) (*define.VMFile, error) {
 	socketName := name + ".sock"
 	return machineRuntimeDir.AppendToNewVMFile(socketName, &socketName)
 }
+
+func consoleSocket(name string, machineRuntimeDir *define.VMFile) (*define.VMFile, error) {
+	socketName := name + "-console.sock"
+	return machineRuntimeDir.AppendToNewVMFile(socketName, &socketName)
+}
@@ -2,6 +2,7 @@ package vmconfigs
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -28,26 +29,74 @@ func extractSourcePath(paths []string) string {
 	return paths[0]
 }
 
-func extractMountOptions(paths []string) (bool, string) {
-	readonly := false
-	securityModel := "none"
-	if len(paths) > 2 {
-		options := paths[2]
-		volopts := strings.Split(options, ",")
-		for _, o := range volopts {
-			switch {
-			case o == "rw":
-				readonly = false
-			case o == "ro":
-				readonly = true
-			case strings.HasPrefix(o, "security_model="):
-				securityModel = strings.Split(o, "=")[1]
+// Cache modes of volumes, translated by the providers to the cache options
+// of their file systems
+const (
+	// CacheNone does not cache the files of the volume in the guest
+	CacheNone = "none"
+	// CacheAuto caches the files until the guest notices they changed
+	CacheAuto = "auto"
+	// CacheAlways caches the files and their metadata for as long as
+	// possible, which is fastest for large trees that are mostly read, e.g.
+	// node_modules, but shows changes of the host late
+	CacheAlways = "always"
+)
+
+// VolumeOptions are the options given after the target of a volume
+type VolumeOptions struct {
+	ReadOnly      bool
+	SecurityModel string
+	// Cache is one of the Cache* modes, empty for the default of the
+	// provider
+	Cache string
+	// MSize is the maximum size of the 9p messages in bytes, zero for the
+	// default of the provider
+	MSize uint32
+	// DirectIO bypasses the page cache of the guest
+	DirectIO bool
+}
+
+// ParseVolumeOptions parses the comma-separated options given after the
+// target of the volume
+func ParseVolumeOptions(volume string) (VolumeOptions, error) {
+	opts := VolumeOptions{SecurityModel: "none"}
+	paths := pathsFromVolume(volume)
+	if len(paths) < 3 {
+		return opts, nil
+	}
+	for _, o := range strings.Split(paths[2], ",") {
+		name, value, _ := strings.Cut(o, "=")
+		switch name {
+		case "rw":
+			opts.ReadOnly = false
+		case "ro":
+			opts.ReadOnly = true
+		case "security_model":
+			opts.SecurityModel = value
+		case "cache":
+			switch value {
+			case CacheNone, CacheAuto, CacheAlways:
+				opts.Cache = value
 			default:
-				fmt.Printf("Unknown option: %s\n", o)
+				return opts, fmt.Errorf("cache %q must be %s, %s or %s", value, CacheNone, CacheAuto, CacheAlways)
 			}
+		case "msize":
+			msize, err := strconv.ParseUint(value, 10, 32)
+			// the 9p client needs room for its headers
+			if err != nil || msize < 4096 {
+				return opts, fmt.Errorf("msize %q must be a number of bytes of at least 4096", value)
+			}
+			opts.MSize = uint32(msize)
+		case "direct-io":
+			opts.DirectIO = true
+		default:
+			fmt.Printf("Unknown option: %s\n", o)
 		}
 	}
-	return readonly, securityModel
+	if opts.DirectIO && opts.Cache != "" && opts.Cache != CacheNone {
+		return opts, fmt.Errorf("direct-io bypasses the cache and cannot be combined with cache=%s", opts.Cache)
+	}
+	return opts, nil
 }
 
 func SplitVolume(idx int, volume string) (string, string, string, bool, string) {
@@ -55,6 +104,7 @@ func SplitVolume(idx int, volume string) (string, string, string, bool, string)
 	paths := pathsFromVolume(volume)
 	source := extractSourcePath(paths)
 	target := extractTargetPath(paths)
-	readonly, securityModel := extractMountOptions(paths)
-	return tag, source, target, readonly, securityModel
+	// invalid options are reported when the volume is added to the machine
+	opts, _ := ParseVolumeOptions(volume)
+	return tag, source, target, opts.ReadOnly, opts.SecurityModel
 }
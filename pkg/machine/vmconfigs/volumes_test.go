@@ -0,0 +1,58 @@
+package vmconfigs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVolumeOptions(t *testing.T) {
+	for _, tc := range []struct {
+		volume string
+		want   VolumeOptions
+		err    string
+	}{
+		{
+			volume: "/src:/src",
+			want:   VolumeOptions{SecurityModel: "none"},
+		},
+		{
+			volume: "/src:/src:ro,security_model=mapped-xattr",
+			want:   VolumeOptions{ReadOnly: true, SecurityModel: "mapped-xattr"},
+		},
+		{
+			volume: "/src:/src:cache=always,msize=524288",
+			want:   VolumeOptions{SecurityModel: "none", Cache: CacheAlways, MSize: 524288},
+		},
+		{
+			volume: "/src:/src:direct-io,cache=none",
+			want:   VolumeOptions{SecurityModel: "none", Cache: CacheNone, DirectIO: true},
+		},
+		{
+			volume: "/src:/src:cache=loose",
+			err:    `cache "loose" must be none, auto or always`,
+		},
+		{
+			volume: "/src:/src:msize=1k",
+			err:    `msize "1k" must be a number of bytes of at least 4096`,
+		},
+		{
+			volume: "/src:/src:msize=512",
+			err:    `msize "512" must be a number of bytes of at least 4096`,
+		},
+		{
+			volume: "/src:/src:direct-io,cache=auto",
+			err:    "direct-io bypasses the cache and cannot be combined with cache=auto",
+		},
+	} {
+		t.Run(tc.volume, func(t *testing.T) {
+			opts, err := ParseVolumeOptions(tc.volume)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, opts)
+		})
+	}
+}
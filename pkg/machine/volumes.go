@@ -1,6 +1,7 @@
 package machine
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
@@ -71,3 +72,37 @@ func MountToVirtIOFs(mnt *vmconfigs.Mount) VirtIoFs {
 		Target:     mnt.Target,
 	}
 }
+
+// ninePCache maps the cache modes of volumes to the cache options of the 9p
+// file system of Linux
+var ninePCache = map[string]string{
+	vmconfigs.CacheNone:   "none",
+	vmconfigs.CacheAuto:   "mmap",
+	vmconfigs.CacheAlways: "loose",
+}
+
+// NinePMountOptions translates the options of the 9p mount to the options of
+// the 9p file system of the guest.  The cache mode and msize of the provider
+// are used when the mount does not set them, and left to the guest when they
+// are empty too.
+func NinePMountOptions(mnt *vmconfigs.Mount, defaultCache string, defaultMSize uint32) []string {
+	var options []string
+	msize := mnt.MSize
+	if msize == 0 {
+		msize = defaultMSize
+	}
+	if msize != 0 {
+		options = append(options, fmt.Sprintf("msize=%d", msize))
+	}
+	cache := mnt.Cache
+	if mnt.DirectIO {
+		cache = vmconfigs.CacheNone
+	}
+	if cache == "" {
+		cache = defaultCache
+	}
+	if cache != "" {
+		options = append(options, "cache="+ninePCache[cache])
+	}
+	return options
+}
@@ -0,0 +1,40 @@
+//go:build amd64 || arm64
+
+package machine
+
+import (
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNinePMountOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		mount vmconfigs.Mount
+		want  []string
+	}{
+		{
+			name: "provider defaults",
+			want: []string{"msize=131072", "cache=mmap"},
+		},
+		{
+			name:  "always cached",
+			mount: vmconfigs.Mount{Cache: vmconfigs.CacheAlways, MSize: 524288},
+			want:  []string{"msize=524288", "cache=loose"},
+		},
+		{
+			name:  "direct I/O",
+			mount: vmconfigs.Mount{DirectIO: true},
+			want:  []string{"msize=131072", "cache=none"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, NinePMountOptions(&tc.mount, vmconfigs.CacheAuto, 131072))
+		})
+	}
+
+	// without defaults the guest picks
+	assert.Empty(t, NinePMountOptions(&vmconfigs.Mount{}, "", 0))
+}
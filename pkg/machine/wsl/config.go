@@ -30,7 +30,7 @@ func VirtualizationProvider() machine.VirtProvider {
 func (p *WSLVirtualization) NewMachine(opts define.InitOptions) (machine.VM, error) {
 	vm := new(MachineVM)
 	if len(opts.USBs) > 0 {
-		return nil, fmt.Errorf("USB host passthrough is not supported for WSL machines")
+		return nil, &define.ErrUSBPassthrough{VMType: define.WSLVirt}
 	}
 	if len(opts.Name) > 0 {
 		vm.Name = opts.Name
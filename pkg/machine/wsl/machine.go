@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -285,6 +286,26 @@ func installScripts(dist string) error {
 	return nil
 }
 
+// installGPUCDI installs the unit describing the GPU-PV devices to the
+// containers of the guest on every boot
+func installGPUCDI(dist string) error {
+	if err := wslPipe(machine.GPUCDIScript, dist, "sh", "-c",
+		fmt.Sprintf("mkdir -p %s; cat > %s; chmod 755 %s", path.Dir(machine.GPUCDIScriptPath), machine.GPUCDIScriptPath, machine.GPUCDIScriptPath)); err != nil {
+		return fmt.Errorf("could not create GPU CDI script for guest OS: %w", err)
+	}
+
+	unitPath := "/etc/systemd/system/" + machine.GPUCDIUnitName
+	if err := wslPipe(machine.GPUCDIUnit, dist, "sh", "-c", "cat > "+unitPath); err != nil {
+		return fmt.Errorf("could not create GPU CDI unit for guest OS: %w", err)
+	}
+	// systemd is not running yet, enable the unit by hand
+	wants := "/etc/systemd/system/multi-user.target.wants"
+	if err := wslInvoke(dist, "sh", "-c", fmt.Sprintf("mkdir -p %s; ln -sf %s %s/%s", wants, unitPath, wants, machine.GPUCDIUnitName)); err != nil {
+		return fmt.Errorf("could not enable GPU CDI unit for guest OS: %w", err)
+	}
+	return nil
+}
+
 func writeWslConf(dist string, user string) error {
 	if err := wslPipe(withUser(wslConf, user), dist, "sh", "-c", "cat > /etc/wsl.conf"); err != nil {
 		return fmt.Errorf("could not configure wsl config for guest OS: %w", err)
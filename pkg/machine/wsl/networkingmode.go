@@ -0,0 +1,84 @@
+//go:build windows
+
+package wsl
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/containers/podman/v5/pkg/machine/vmconfigs"
+	"github.com/containers/podman/v5/pkg/machine/wsl/wutil"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+)
+
+func verifyWSLMirroredCompat() error {
+	if winVersionAtLeast(10, 0, 22621) && wutil.IsWSLStoreVersionInstalled() {
+		return nil
+	}
+	return errors.New("mirrored WSL networking requires Windows 11 22H2 and a newer version of WSL: " +
+		"apply all outstanding windows updates, and then run `wsl --update`")
+}
+
+func getWSLConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".wslconfig"), nil
+}
+
+// applyWSLNetworkingMode switches WSL to the networking mode the machine
+// requires.  The mode is shared by all the distributions and WSL only reads
+// it as it boots, so it is only changed while no distribution runs, and WSL
+// is shut down for the change to apply.
+func applyWSLNetworkingMode(mc *vmconfigs.MachineConfig) error {
+	mode := mc.Network.WSLMode
+	if mode == "" {
+		return nil
+	}
+
+	path, err := getWSLConfigPath()
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("reading the WSL configuration: %w", err)
+	}
+	current := wslConfigNetworkingMode(string(content))
+	if current == mode {
+		return nil
+	}
+
+	if mode == define.WSLNetworkingMirrored {
+		if err := verifyWSLMirroredCompat(); err != nil {
+			return err
+		}
+	}
+	running, err := getAllWSLDistros(true)
+	if err != nil {
+		return err
+	}
+	if len(running) > 0 {
+		names := make([]string, 0, len(running))
+		for name := range running {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("machine %q requires %s WSL networking but WSL uses %s networking, which can only be changed while no WSL distribution runs: "+
+			"stop %v or run `wsl --shutdown`", mc.Name, mode, current, names)
+	}
+
+	logrus.Infof("Switching WSL to %s networking in %s", mode, path)
+	if err := os.WriteFile(path, []byte(setWSLConfigNetworkingMode(string(content), mode)), 0644); err != nil {
+		return fmt.Errorf("writing the WSL configuration: %w", err)
+	}
+	// the WSL VM lingers for a few seconds after the last distribution
+	// stopped, and would keep the previous mode if it was reused
+	return wutil.SilentExec(wutil.FindWSL(), "--shutdown")
+}
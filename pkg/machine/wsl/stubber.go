@@ -5,6 +5,7 @@ package wsl
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -77,6 +78,12 @@ func (w WSLStubber) CreateVM(opts define.CreateVMOpts, mc *vmconfigs.MachineConf
 		return err
 	}
 
+	if mc.Resources.GPU != nil {
+		if err = installGPUCDI(dist); err != nil {
+			return err
+		}
+	}
+
 	if err = createKeys(mc, dist); err != nil {
 		return err
 	}
@@ -101,6 +108,11 @@ func (w WSLStubber) MountVolumesToVM(mc *vmconfigs.MachineConfig, quiet bool) er
 	return nil
 }
 
+func (w WSLStubber) UpdateMounts(_ *vmconfigs.MachineConfig, _, _ []*vmconfigs.Mount) (bool, error) {
+	// the drives of the host are mounted by WSL itself
+	return false, define.ErrNotImplemented
+}
+
 func (w WSLStubber) Remove(mc *vmconfigs.MachineConfig) ([]string, func() error, error) {
 	// Note: we could consider swapping the two conditionals
 	// below if we wanted to hard error on the wsl unregister
@@ -137,20 +149,8 @@ func (w WSLStubber) SetProviderAttrs(mc *vmconfigs.MachineConfig, opts define.Se
 		}
 	}
 
-	if opts.CPUs != nil {
-		return errors.New("changing CPUs not supported for WSL machines")
-	}
-
-	if opts.Memory != nil {
-		return errors.New("changing memory not supported for WSL machines")
-	}
-
 	if opts.USBs != nil {
-		return errors.New("changing USBs not supported for WSL machines")
-	}
-
-	if opts.DiskSize != nil {
-		return errors.New("changing disk size not supported for WSL machines")
+		return &define.ErrUSBPassthrough{VMType: define.WSLVirt}
 	}
 
 	if opts.UserModeNetworking != nil && mc.WSLHypervisor.UserModeNetworking != *opts.UserModeNetworking {
@@ -169,7 +169,25 @@ func (w WSLStubber) SetProviderAttrs(mc *vmconfigs.MachineConfig, opts define.Se
 	return nil
 }
 
+func (w WSLStubber) UpdateResources(mc *vmconfigs.MachineConfig, opts define.SetOptions) error {
+	if opts.CPUs != nil {
+		return errors.New("changing CPUs not supported for WSL machines")
+	}
+
+	if opts.Memory != nil {
+		return errors.New("changing memory not supported for WSL machines")
+	}
+
+	if opts.DiskSize != nil {
+		return errors.New("changing disk size not supported for WSL machines")
+	}
+	return nil
+}
+
 func (w WSLStubber) StartNetworking(mc *vmconfigs.MachineConfig, cmd *gvproxy.GvproxyCommand) error {
+	if err := applyWSLNetworkingMode(mc); err != nil {
+		return err
+	}
 	// Startup user-mode networking if enabled
 	if mc.WSLHypervisor.UserModeNetworking {
 		return startUserModeNetworking(mc)
@@ -189,6 +207,49 @@ func (w WSLStubber) RequireExclusiveActive() bool {
 	return false
 }
 
+// Capabilities reports the features of WSL.  The distributions of all
+// machines share one VM, which WSL manages.
+func (w WSLStubber) Capabilities() define.Capabilities {
+	return define.Capabilities{
+		GPU:             true,
+		MultipleRunning: !w.RequireExclusiveActive(),
+	}
+}
+
+func (w WSLStubber) StartRecovery(_ *vmconfigs.MachineConfig, _ define.RecoveryOverride) error {
+	return define.ErrNotImplemented
+}
+
+func (w WSLStubber) Snapshot(_ *vmconfigs.MachineConfig, _ string) (*define.VMFile, error) {
+	return nil, define.ErrNotImplemented
+}
+
+func (w WSLStubber) RevertSnapshot(_ *vmconfigs.MachineConfig, _ *vmconfigs.Snapshot) error {
+	return define.ErrNotImplemented
+}
+
+// AttachConsole is not supported, WSL distributions have no serial console
+func (w WSLStubber) AttachConsole(_ *vmconfigs.MachineConfig) (io.ReadWriteCloser, error) {
+	return nil, define.ErrNotImplemented
+}
+
+func (w WSLStubber) CompactDisk(_ *vmconfigs.MachineConfig) error {
+	return define.ErrNotImplemented
+}
+
+func (w WSLStubber) ConvertDisk(_ *vmconfigs.MachineConfig, _, _ *define.VMFile, _ define.ImageFormat) error {
+	return define.ErrNotImplemented
+}
+
+func (w WSLStubber) RunningConfig(_ *vmconfigs.MachineConfig) (*vmconfigs.RunningConfig, error) {
+	return nil, define.ErrNotImplemented
+}
+
+// Stats is not implemented since all WSL distributions share one VM
+func (w WSLStubber) Stats(_ *vmconfigs.MachineConfig) (*vmconfigs.Stats, error) {
+	return nil, define.ErrNotImplemented
+}
+
 func (w WSLStubber) PostStartNetworking(mc *vmconfigs.MachineConfig, noInfo bool) error {
 	winProxyOpts := machine.WinProxyOpts{
 		Name:           mc.Name,
@@ -197,6 +258,7 @@ func (w WSLStubber) PostStartNetworking(mc *vmconfigs.MachineConfig, noInfo bool
 		RemoteUsername: mc.SSH.RemoteUsername,
 		Rootful:        mc.HostUser.Rootful,
 		VMType:         w.VMType(),
+		SecondaryPipe:  machine.ToDist(mc.Name) + mc.SecondaryAPISuffix(),
 	}
 	machine.LaunchWinProxy(winProxyOpts, noInfo)
 
@@ -279,6 +341,10 @@ func (w WSLStubber) StopVM(mc *vmconfigs.MachineConfig, hardStop bool) error {
 	return terminateDist(dist)
 }
 
+func (w WSLStubber) KillVM(mc *vmconfigs.MachineConfig) error {
+	return terminateDist(machine.ToDist(mc.Name))
+}
+
 func (w WSLStubber) StopHostNetworking(mc *vmconfigs.MachineConfig, vmType define.VMType) error {
 	return stopUserModeNetworking(mc)
 }
@@ -0,0 +1,109 @@
+package wsl
+
+import (
+	"strings"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+)
+
+const (
+	// wslConfigSection is the section of .wslconfig holding the settings
+	// of the WSL 2 VM
+	wslConfigSection = "wsl2"
+	// wslConfigNetworkingKey sets the networking mode of WSL
+	wslConfigNetworkingKey = "networkingMode"
+)
+
+// wslConfigNetworkingMode returns the networking mode set in content, the
+// content of a .wslconfig file.  WSL defaults to NAT when it is not set.
+func wslConfigNetworkingMode(content string) define.WSLNetworkingMode {
+	section := ""
+	mode := define.WSLNetworkingNAT
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if name, ok := wslConfigSectionName(line); ok {
+			section = name
+			continue
+		}
+		if !strings.EqualFold(section, wslConfigSection) {
+			continue
+		}
+		if key, value, ok := wslConfigSetting(line); ok && strings.EqualFold(key, wslConfigNetworkingKey) {
+			mode = define.WSLNetworkingMode(strings.ToLower(value))
+		}
+	}
+	return mode
+}
+
+// setWSLConfigNetworkingMode returns content with the networking mode set
+// to mode.  The other settings and comments of the file are kept.
+func setWSLConfigNetworkingMode(content string, mode define.WSLNetworkingMode) string {
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+	}
+	setting := wslConfigNetworkingKey + "=" + string(mode)
+
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	result := make([]string, 0, len(lines)+2)
+	section := ""
+	header := -1
+	set := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if name, ok := wslConfigSectionName(trimmed); ok {
+			section = name
+			if strings.EqualFold(name, wslConfigSection) && header < 0 {
+				header = len(result)
+			}
+		} else if key, _, ok := wslConfigSetting(trimmed); ok && strings.EqualFold(section, wslConfigSection) && strings.EqualFold(key, wslConfigNetworkingKey) {
+			// later settings would override the one written
+			if set {
+				continue
+			}
+			line = setting
+			set = true
+		}
+		result = append(result, line)
+	}
+
+	switch {
+	case set:
+	case header >= 0:
+		result = append(result[:header+1], append([]string{setting}, result[header+1:]...)...)
+	default:
+		// keep a single newline at the end of the file
+		if len(result) > 0 && result[len(result)-1] == "" {
+			result = result[:len(result)-1]
+		}
+		if len(result) > 0 {
+			result = append(result, "")
+		}
+		result = append(result, "["+wslConfigSection+"]", setting, "")
+	}
+	return strings.Join(result, newline)
+}
+
+// wslConfigSectionName returns the name of the section started by line
+func wslConfigSectionName(line string) (string, bool) {
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return "", false
+	}
+	return strings.TrimSpace(line[1 : len(line)-1]), true
+}
+
+// wslConfigSetting returns the key and the value set by line, skipping
+// comments
+func wslConfigSetting(line string) (string, string, bool) {
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+		return "", "", false
+	}
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	if i := strings.IndexAny(value, "#;"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(value), true
+}
@@ -0,0 +1,63 @@
+package wsl
+
+import (
+	"testing"
+
+	"github.com/containers/podman/v5/pkg/machine/define"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWSLConfigNetworkingMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    define.WSLNetworkingMode
+	}{
+		{name: "empty", content: "", want: define.WSLNetworkingNAT},
+		{name: "other section", content: "[experimental]\nnetworkingMode=mirrored\n", want: define.WSLNetworkingNAT},
+		{name: "mirrored", content: "[wsl2]\nmemory=8GB\nnetworkingMode = Mirrored # since 22H2\n", want: define.WSLNetworkingMirrored},
+		{name: "commented", content: "[wsl2]\n#networkingMode=mirrored\n", want: define.WSLNetworkingNAT},
+		{name: "windows newlines", content: "[WSL2]\r\nnetworkingmode=mirrored\r\n", want: define.WSLNetworkingMirrored},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, wslConfigNetworkingMode(tt.content))
+		})
+	}
+}
+
+func TestSetWSLConfigNetworkingMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "empty",
+			content: "",
+			want:    "[wsl2]\nnetworkingMode=mirrored\n",
+		},
+		{
+			name:    "other section",
+			content: "[experimental]\nautoMemoryReclaim=gradual\n",
+			want:    "[experimental]\nautoMemoryReclaim=gradual\n\n[wsl2]\nnetworkingMode=mirrored\n",
+		},
+		{
+			name:    "section without the setting",
+			content: "[wsl2]\r\nmemory=8GB\r\n",
+			want:    "[wsl2]\r\nnetworkingMode=mirrored\r\nmemory=8GB\r\n",
+		},
+		{
+			name:    "replaced setting",
+			content: "# my settings\n[wsl2]\nnetworkingMode=nat\nmemory=8GB\nnetworkingMode=nat\n",
+			want:    "# my settings\n[wsl2]\nnetworkingMode=mirrored\nmemory=8GB\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := setWSLConfigNetworkingMode(tt.content, define.WSLNetworkingMirrored)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, define.WSLNetworkingMirrored, wslConfigNetworkingMode(got))
+		})
+	}
+}
@@ -0,0 +1,258 @@
+// Package mirrorhealth tracks the health of registry mirrors across pulls.
+// The outcome and latency of every access of a mirror is recorded in the run
+// directory, and subsequent pulls try the healthy mirrors ordered by latency
+// while skipping mirrors that failed repeatedly.
+package mirrorhealth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/pkg/sysregistriesv2"
+	"github.com/containers/podman/v5/libpod/define"
+	"github.com/containers/storage/pkg/ioutils"
+	"github.com/containers/storage/pkg/lockfile"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// FailureThreshold is the number of consecutive failures after which a
+	// mirror is considered unhealthy.
+	FailureThreshold = 3
+	// RetryInterval is the time an unhealthy mirror is skipped before it
+	// is tried again.
+	RetryInterval = 5 * time.Minute
+
+	// latencyWeight is the weight of the latest access in the moving
+	// average of the latency.
+	latencyWeight = 0.3
+
+	stateName = "mirror-health.json"
+	lockName  = "mirror-health.lock"
+)
+
+// record is the persisted state of a mirror.
+type record struct {
+	Successes           uint64        `json:"successes"`
+	Failures            uint64        `json:"failures"`
+	ConsecutiveFailures uint64        `json:"consecutiveFailures"`
+	Latency             time.Duration `json:"latency"`
+	LastSuccess         time.Time     `json:"lastSuccess"`
+	LastFailure         time.Time     `json:"lastFailure"`
+	LastError           string        `json:"lastError,omitempty"`
+}
+
+// healthy returns whether the mirror may be used at the specified time.
+func (r *record) healthy(now time.Time) bool {
+	return r.ConsecutiveFailures < FailureThreshold || now.Sub(r.LastFailure) >= RetryInterval
+}
+
+// Tracker records the health of registry mirrors.
+type Tracker struct {
+	path string
+	lock *lockfile.LockFile
+	// now returns the current time and can be overridden by tests.
+	now func() time.Time
+}
+
+// NewTracker returns a tracker persisting its state in dir.
+func NewTracker(dir string) (*Tracker, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	lock, err := lockfile.GetLockFile(filepath.Join(dir, lockName))
+	if err != nil {
+		return nil, err
+	}
+	return &Tracker{path: filepath.Join(dir, stateName), lock: lock, now: time.Now}, nil
+}
+
+// WithHooks returns a copy of ctx that makes pulls from registries with
+// mirrors use the mirrors in the order of their health and record the
+// outcome of every access.
+func (t *Tracker) WithHooks(ctx context.Context) context.Context {
+	return docker.WithPullSourceHooks(ctx, &docker.PullSourceHooks{
+		Order: t.Order,
+		Report: func(source sysregistriesv2.PullSource, mirror bool, latency time.Duration, err error) {
+			if !mirror {
+				return
+			}
+			if err := t.Record(source.Endpoint.Location, latency, err); err != nil {
+				logrus.Warnf("Recording the health of mirror %q: %v", source.Endpoint.Location, err)
+			}
+		},
+	})
+}
+
+// Order returns the pull sources in the order they should be tried.  The
+// healthy mirrors come first, those never accessed before followed by the
+// others ordered by latency.  Unhealthy mirrors due for another attempt come
+// next, and the remaining unhealthy mirrors are skipped.  The primary
+// location, i.e., the last source, always comes last.
+func (t *Tracker) Order(sources []sysregistriesv2.PullSource) []sysregistriesv2.PullSource {
+	if len(sources) < 2 {
+		return sources
+	}
+	records, err := t.load()
+	if err != nil {
+		logrus.Warnf("Reading the health of registry mirrors: %v", err)
+		return sources
+	}
+	now := t.now()
+
+	mirrors := sources[:len(sources)-1]
+	var healthy, retry []sysregistriesv2.PullSource
+	for _, source := range mirrors {
+		r, ok := records[source.Endpoint.Location]
+		switch {
+		case !ok || r.ConsecutiveFailures < FailureThreshold:
+			healthy = append(healthy, source)
+		case r.healthy(now):
+			retry = append(retry, source)
+		default:
+			logrus.Debugf("Skipping unhealthy mirror %q after %d consecutive failures", source.Endpoint.Location, r.ConsecutiveFailures)
+		}
+	}
+	latency := func(source sysregistriesv2.PullSource) time.Duration {
+		if r, ok := records[source.Endpoint.Location]; ok {
+			return r.Latency
+		}
+		return 0
+	}
+	sort.SliceStable(healthy, func(i, j int) bool {
+		return latency(healthy[i]) < latency(healthy[j])
+	})
+
+	ordered := make([]sysregistriesv2.PullSource, 0, len(sources))
+	ordered = append(ordered, healthy...)
+	ordered = append(ordered, retry...)
+	return append(ordered, sources[len(sources)-1])
+}
+
+// Record records the outcome of accessing the mirror at location.
+func (t *Tracker) Record(location string, latency time.Duration, accessErr error) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	records, err := t.read()
+	if err != nil {
+		return err
+	}
+	r, ok := records[location]
+	if !ok {
+		r = &record{}
+		records[location] = r
+	}
+	now := t.now()
+	if IsFailure(accessErr) {
+		r.Failures++
+		r.ConsecutiveFailures++
+		r.LastFailure = now
+		r.LastError = accessErr.Error()
+	} else {
+		r.Successes++
+		r.ConsecutiveFailures = 0
+		r.LastSuccess = now
+		if r.Latency == 0 {
+			r.Latency = latency
+		} else {
+			r.Latency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(r.Latency))
+		}
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return ioutils.AtomicWriteFile(t.path, data, 0o600)
+}
+
+// Status returns the health of all mirrors accessed so far, sorted by
+// location.
+func (t *Tracker) Status() ([]define.MirrorHealth, error) {
+	records, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+	now := t.now()
+	status := make([]define.MirrorHealth, 0, len(records))
+	for location, r := range records {
+		h := define.MirrorHealth{
+			Location:            location,
+			Healthy:             r.healthy(now),
+			Successes:           r.Successes,
+			Failures:            r.Failures,
+			ConsecutiveFailures: r.ConsecutiveFailures,
+			LastError:           r.LastError,
+		}
+		if r.Latency > 0 {
+			h.Latency = r.Latency.Round(time.Millisecond).String()
+		}
+		if !r.LastSuccess.IsZero() {
+			lastSuccess := r.LastSuccess
+			h.LastSuccess = &lastSuccess
+		}
+		if !r.LastFailure.IsZero() {
+			lastFailure := r.LastFailure
+			h.LastFailure = &lastFailure
+		}
+		status = append(status, h)
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Location < status[j].Location
+	})
+	return status, nil
+}
+
+// IsFailure returns whether err indicates that a mirror is unhealthy.  A
+// mirror which does not have the requested image or which rejects the
+// credentials is reachable and thus considered healthy.
+func IsFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var unauthorized docker.ErrUnauthorizedForCredentials
+	if errors.As(err, &unauthorized) {
+		return false
+	}
+	var ec errcode.ErrorCoder
+	if errors.As(err, &ec) {
+		switch ec.ErrorCode() {
+		case v2.ErrorCodeManifestUnknown, v2.ErrorCodeNameUnknown, errcode.ErrorCodeUnauthorized, errcode.ErrorCodeDenied:
+			return false
+		}
+	}
+	return true
+}
+
+// load reads the records with the lock held.
+func (t *Tracker) load() (map[string]*record, error) {
+	t.lock.RLock()
+	defer t.lock.Unlock()
+	return t.read()
+}
+
+// read reads the records.  Must be called with the lock held.
+func (t *Tracker) read() (map[string]*record, error) {
+	records := make(map[string]*record)
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return records, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", t.path, err)
+	}
+	return records, nil
+}
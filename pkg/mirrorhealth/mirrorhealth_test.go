@@ -0,0 +1,83 @@
+package mirrorhealth
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/pkg/sysregistriesv2"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sources(locations ...string) []sysregistriesv2.PullSource {
+	s := make([]sysregistriesv2.PullSource, 0, len(locations))
+	for _, location := range locations {
+		s = append(s, sysregistriesv2.PullSource{Endpoint: sysregistriesv2.Endpoint{Location: location}})
+	}
+	return s
+}
+
+func locations(sources []sysregistriesv2.PullSource) []string {
+	l := make([]string, 0, len(sources))
+	for _, s := range sources {
+		l = append(l, s.Endpoint.Location)
+	}
+	return l
+}
+
+func TestTracker(t *testing.T) {
+	tracker, err := NewTracker(t.TempDir())
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	all := sources("slow.mirror", "fast.mirror", "broken.mirror", "new.mirror", "registry")
+	// Nothing has been recorded yet.
+	assert.Equal(t, all, tracker.Order(all))
+
+	require.NoError(t, tracker.Record("slow.mirror", 300*time.Millisecond, nil))
+	require.NoError(t, tracker.Record("fast.mirror", 100*time.Millisecond, nil))
+	for i := 0; i < FailureThreshold; i++ {
+		require.NoError(t, tracker.Record("broken.mirror", time.Second, errors.New("connection refused")))
+	}
+	assert.Equal(t, []string{"new.mirror", "fast.mirror", "slow.mirror", "registry"}, locations(tracker.Order(all)))
+
+	status, err := tracker.Status()
+	require.NoError(t, err)
+	require.Len(t, status, 3)
+	assert.Equal(t, "broken.mirror", status[0].Location)
+	assert.False(t, status[0].Healthy)
+	assert.Equal(t, uint64(FailureThreshold), status[0].ConsecutiveFailures)
+	assert.Equal(t, "connection refused", status[0].LastError)
+	assert.Nil(t, status[0].LastSuccess)
+	assert.Equal(t, "fast.mirror", status[1].Location)
+	assert.True(t, status[1].Healthy)
+	assert.Equal(t, "100ms", status[1].Latency)
+
+	// The broken mirror is retried after the retry interval.
+	now = now.Add(RetryInterval)
+	assert.Equal(t, []string{"new.mirror", "fast.mirror", "slow.mirror", "broken.mirror", "registry"}, locations(tracker.Order(all)))
+	require.NoError(t, tracker.Record("broken.mirror", 50*time.Millisecond, nil))
+	assert.Equal(t, []string{"new.mirror", "broken.mirror", "fast.mirror", "slow.mirror", "registry"}, locations(tracker.Order(all)))
+
+	// The latency is a moving average.
+	require.NoError(t, tracker.Record("fast.mirror", 200*time.Millisecond, nil))
+	status, err = tracker.Status()
+	require.NoError(t, err)
+	assert.Equal(t, "130ms", status[1].Latency)
+	assert.Equal(t, uint64(2), status[1].Successes)
+}
+
+func TestIsFailure(t *testing.T) {
+	assert.False(t, IsFailure(nil))
+	assert.True(t, IsFailure(errors.New("connection refused")))
+	assert.True(t, IsFailure(docker.ErrTooManyRequests))
+	assert.False(t, IsFailure(docker.ErrUnauthorizedForCredentials{Err: errors.New("denied")}))
+	assert.False(t, IsFailure(fmt.Errorf("reading manifest: %w", v2.ErrorCodeManifestUnknown.WithMessage("manifest unknown"))))
+	assert.False(t, IsFailure(errcode.ErrorCodeDenied))
+}
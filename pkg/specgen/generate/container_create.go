@@ -174,6 +174,10 @@ func MakeContainer(ctx context.Context, rt *libpod.Runtime, s *specgen.SpecGener
 	}
 
 	if newImage != nil {
+		if err := rt.CheckImageScanPolicy(ctx, newImage); err != nil {
+			return nil, nil, nil, err
+		}
+
 		// If the input name changed, we could properly resolve the
 		// image. Otherwise, it must have been an ID where we're
 		// defaulting to the first name or an empty one if no names are
@@ -668,14 +672,25 @@ func createContainerOptions(rt *libpod.Runtime, s *specgen.SpecGenerator, pod *l
 
 	if len(s.DependencyContainers) > 0 {
 		deps := make([]*libpod.Container, 0, len(s.DependencyContainers))
-		for _, ctr := range s.DependencyContainers {
+		conditions := make(map[string]string)
+		for _, dep := range s.DependencyContainers {
+			ctr, condition, hasCondition := strings.Cut(dep, ":")
 			depCtr, err := rt.LookupContainer(ctr)
 			if err != nil {
 				return nil, fmt.Errorf("%q is not a valid container, cannot be used as a dependency: %w", ctr, err)
 			}
 			deps = append(deps, depCtr)
+			if hasCondition && condition != define.DependencyConditionStarted {
+				if condition == define.DependencyConditionHealthy && !depCtr.HasHealthCheck() {
+					return nil, fmt.Errorf("container %q has no healthcheck, cannot be required to be healthy: %w", ctr, define.ErrInvalidArg)
+				}
+				conditions[depCtr.ID()] = condition
+			}
 		}
 		options = append(options, libpod.WithDependencyCtrs(deps))
+		if len(conditions) > 0 {
+			options = append(options, libpod.WithDependencyConditions(conditions))
+		}
 	}
 	if s.PidFile != "" {
 		options = append(options, libpod.WithPidFile(s.PidFile))
@@ -158,6 +158,9 @@ func createPodOptions(p *specgen.PodSpecGenerator) ([]libpod.PodCreateOption, er
 	}
 
 	options = append(options, libpod.WithPodExitPolicy(p.ExitPolicy))
+	if p.ExitCodePropagation != "" {
+		options = append(options, libpod.WithPodExitCodePropagation(p.ExitCodePropagation))
+	}
 	options = append(options, libpod.WithPodRestartPolicy(p.RestartPolicy))
 	if p.RestartRetries != nil {
 		options = append(options, libpod.WithPodRestartRetries(*p.RestartRetries))
@@ -10,6 +10,7 @@ import (
 
 	"github.com/containers/common/libimage"
 	"github.com/containers/common/libnetwork/types"
+	"github.com/containers/podman/v5/libpod/define"
 	"github.com/containers/podman/v5/pkg/specgen"
 	"github.com/containers/podman/v5/pkg/specgenutil"
 	"github.com/containers/podman/v5/utils"
@@ -329,6 +330,65 @@ func ParsePortMapping(portMappings []types.PortMapping, exposePorts map[uint16][
 	return portMappings, nil
 }
 
+// RemovePortMappings removes the ports in remove from portMappings.  A port
+// to remove without host port matches every host port the container port is
+// published on, one without host IP every host IP.  It is an error to remove a
+// port which is not published.  The returned ports are not joined to ranges,
+// use ParsePortMapping for that.
+func RemovePortMappings(portMappings, remove []types.PortMapping) ([]types.PortMapping, error) {
+	ports, err := splitPortMappings(portMappings)
+	if err != nil {
+		return nil, err
+	}
+	toRemove, err := splitPortMappings(remove)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range toRemove {
+		n := len(ports)
+		ports = slices.DeleteFunc(ports, func(p types.PortMapping) bool {
+			return p.ContainerPort == r.ContainerPort && p.Protocol == r.Protocol &&
+				(r.HostPort == 0 || p.HostPort == r.HostPort) &&
+				(r.HostIP == "" || p.HostIP == r.HostIP)
+		})
+		if len(ports) == n {
+			return nil, fmt.Errorf("container port %d/%s is not published: %w", r.ContainerPort, r.Protocol, define.ErrInvalidArg)
+		}
+	}
+	return ports, nil
+}
+
+// splitPortMappings returns a mapping for every single port and protocol of
+// the port mappings.
+func splitPortMappings(portMappings []types.PortMapping) ([]types.PortMapping, error) {
+	ports := make([]types.PortMapping, 0, len(portMappings))
+	for _, port := range portMappings {
+		protocols, err := checkProtocol(port.Protocol, true)
+		if err != nil {
+			return nil, err
+		}
+		portRange := port.Range
+		if portRange == 0 {
+			portRange = 1
+		}
+		for _, protocol := range protocols {
+			for i := uint16(0); i < portRange; i++ {
+				p := types.PortMapping{
+					HostIP:        port.HostIP,
+					ContainerPort: port.ContainerPort + i,
+					Protocol:      protocol,
+					Range:         1,
+				}
+				if port.HostPort != 0 {
+					p.HostPort = port.HostPort + i
+				}
+				ports = append(ports, p)
+			}
+		}
+	}
+	return ports, nil
+}
+
 func appendProtocolsNoDuplicates(slice []string, protocols []string) []string {
 	for _, proto := range protocols {
 		if slices.Contains(slice, proto) {
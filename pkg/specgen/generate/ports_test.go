@@ -989,3 +989,119 @@ func TestParsePortMappingError(t *testing.T) {
 		})
 	}
 }
+
+func TestRemovePortMappings(t *testing.T) {
+	ports := []types.PortMapping{
+		{
+			HostPort:      8080,
+			ContainerPort: 80,
+			Protocol:      "tcp,udp",
+			Range:         3,
+		},
+		{
+			HostIP:        "127.0.0.1",
+			HostPort:      9090,
+			ContainerPort: 90,
+			Protocol:      "tcp",
+			Range:         1,
+		},
+	}
+	tests := []struct {
+		name   string
+		remove []types.PortMapping
+		want   []types.PortMapping
+		err    string
+	}{
+		{
+			name: "remove port from range",
+			remove: []types.PortMapping{
+				{
+					HostPort:      8081,
+					ContainerPort: 81,
+				},
+			},
+			want: []types.PortMapping{
+				{
+					HostPort:      8080,
+					ContainerPort: 80,
+					Protocol:      "tcp",
+					Range:         1,
+				},
+				{
+					HostPort:      8082,
+					ContainerPort: 82,
+					Protocol:      "tcp",
+					Range:         1,
+				},
+				{
+					HostPort:      8080,
+					ContainerPort: 80,
+					Protocol:      "udp",
+					Range:         3,
+				},
+				{
+					HostIP:        "127.0.0.1",
+					HostPort:      9090,
+					ContainerPort: 90,
+					Protocol:      "tcp",
+					Range:         1,
+				},
+			},
+		},
+		{
+			name: "remove container port without host port",
+			remove: []types.PortMapping{
+				{
+					ContainerPort: 80,
+					Protocol:      "tcp,udp",
+					Range:         3,
+				},
+			},
+			want: []types.PortMapping{
+				{
+					HostIP:        "127.0.0.1",
+					HostPort:      9090,
+					ContainerPort: 90,
+					Protocol:      "tcp",
+					Range:         1,
+				},
+			},
+		},
+		{
+			name: "port not published",
+			remove: []types.PortMapping{
+				{
+					HostPort:      8080,
+					ContainerPort: 80,
+					Protocol:      "sctp",
+				},
+			},
+			err: "container port 80/sctp is not published: invalid argument",
+		},
+		{
+			name: "host ip does not match",
+			remove: []types.PortMapping{
+				{
+					HostIP:        "127.0.0.2",
+					ContainerPort: 90,
+				},
+			},
+			err: "container port 90/tcp is not published: invalid argument",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RemovePortMappings(ports, tt.remove)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err, "error does not match")
+				return
+			}
+			assert.NoError(t, err, "error is not nil")
+			got, err = ParsePortMapping(got, nil)
+			assert.NoError(t, err, "error is not nil")
+			// use ElementsMatch because the map ordering is random
+			assert.ElementsMatch(t, tt.want, got, "got unexpected port mapping")
+		})
+	}
+}
@@ -146,6 +146,18 @@ func finalizeMounts(ctx context.Context, s *specgen.SpecGenerator, rt *libpod.Ru
 		unifiedMounts[initMount.Destination] = initMount
 	}
 
+	// Add the blobs of artifact volumes
+	artifactMounts, err := getArtifactMounts(ctx, s.ArtifactVolumes, rt)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, m := range artifactMounts {
+		if _, ok := unifiedMounts[m.Destination]; ok {
+			return nil, nil, nil, fmt.Errorf("conflict with artifact volume at %q: %w", m.Destination, specgen.ErrDuplicateDest)
+		}
+		unifiedMounts[m.Destination] = m
+	}
+
 	// Before superseding, we need to find volume mounts which conflict with
 	// named volumes, and vice versa.
 	// We'll delete the conflicts here as we supersede.
@@ -213,6 +225,38 @@ func finalizeMounts(ctx context.Context, s *specgen.SpecGenerator, rt *libpod.Ru
 	return finalMounts, finalVolumes, finalOverlays, nil
 }
 
+// getArtifactMounts resolves the specified artifact volumes to read-only bind
+// mounts of the artifacts' blobs.  Each blob is mounted into the
+// destination directory of the volume under its title.
+func getArtifactMounts(ctx context.Context, volumes []*specgen.ArtifactVolume, rt *libpod.Runtime) ([]spec.Mount, error) {
+	if len(volumes) == 0 {
+		return nil, nil
+	}
+	artifactStore, err := rt.ArtifactStore()
+	if err != nil {
+		return nil, err
+	}
+	var mounts []spec.Mount
+	for _, v := range volumes {
+		if err := parse.ValidateVolumeCtrDir(v.Destination); err != nil {
+			return nil, err
+		}
+		blobs, err := artifactStore.BlobMountPaths(ctx, v.Source)
+		if err != nil {
+			return nil, fmt.Errorf("artifact volume %q: %w", v.Source, err)
+		}
+		for _, blob := range blobs {
+			mounts = append(mounts, spec.Mount{
+				Destination: path.Join(filepath.Clean(v.Destination), blob.Name),
+				Source:      blob.SourcePath,
+				Type:        define.TypeBind,
+				Options:     []string{define.TypeBind, "ro"},
+			})
+		}
+	}
+	return mounts, nil
+}
+
 // Get image volumes from the given image
 func getImageVolumes(ctx context.Context, img *libimage.Image, s *specgen.SpecGenerator) (map[string]spec.Mount, map[string]*specgen.NamedVolume, error) {
 	mounts := make(map[string]spec.Mount)
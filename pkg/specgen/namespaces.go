@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/containers/common/libnetwork/types"
+	netutil "github.com/containers/common/libnetwork/util"
 	"github.com/containers/common/pkg/cgroups"
 	"github.com/containers/podman/v5/libpod/define"
 	"github.com/containers/podman/v5/pkg/namespaces"
@@ -471,6 +472,15 @@ func parseBridgeNetworkOptions(opts string) (types.PerNetworkOptions, error) {
 			}
 			netOpts.InterfaceName = value
 
+		case types.EgressBandwidthOption, types.IngressBandwidthOption:
+			if _, err := netutil.ParseBandwidth(value); err != nil {
+				return netOpts, err
+			}
+			if netOpts.Options == nil {
+				netOpts.Options = make(map[string]string)
+			}
+			netOpts.Options[name] = value
+
 		default:
 			return netOpts, fmt.Errorf("unknown bridge network option: %s", name)
 		}
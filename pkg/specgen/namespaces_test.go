@@ -157,6 +157,25 @@ func TestParseNetworkFlag(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:   "bridge mode with bandwidth options",
+			args:   []string{"bridge:egress-bandwidth=10mbit,ingress-bandwidth=1gbit"},
+			nsmode: Namespace{NSMode: Bridge},
+			networks: map[string]types.PerNetworkOptions{
+				defaultNetName: {
+					Options: map[string]string{
+						"egress-bandwidth":  "10mbit",
+						"ingress-bandwidth": "1gbit",
+					},
+				},
+			},
+		},
+		{
+			name:   "bridge mode with invalid bandwidth",
+			args:   []string{"bridge:egress-bandwidth=fast"},
+			nsmode: Namespace{NSMode: Bridge},
+			err:    "invalid bandwidth \"fast\": must be a positive number with an optional unit like kbit, mbit or gbit",
+		},
 		{
 			name:   "bridge mode with invalid option",
 			args:   []string{"bridge:abc=123"},
@@ -34,6 +34,9 @@ func (p *PodSpecGenerator) Validate() error {
 		if len(p.SharedNamespaces) > 0 {
 			return exclusivePodOptions("NoInfra", "SharedNamespaces")
 		}
+		if len(p.ExitCodePropagation) > 0 {
+			return exclusivePodOptions("NoInfra", "ExitCodePropagation")
+		}
 	}
 
 	// PodNetworkConfig
@@ -22,6 +22,10 @@ type PodBasicConfig struct {
 	Hostname string `json:"hostname,omitempty"`
 	// ExitPolicy determines the pod's exit and stop behaviour.
 	ExitPolicy string `json:"exit_policy,omitempty"`
+	// ExitCodePropagation determines how the exit codes of the pod's
+	// containers are propagated when the pod stops.  Only honored with
+	// the "stop" exit policy.
+	ExitCodePropagation string `json:"exit_code_propagation,omitempty"`
 	// Labels are key-value pairs that are used to add metadata to pods.
 	// Optional.
 	Labels map[string]string `json:"labels,omitempty"`
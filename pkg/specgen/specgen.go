@@ -183,7 +183,9 @@ type ContainerBasicConfig struct {
 	Timezone string `json:"timezone,omitempty"`
 	// DependencyContainers is an array of containers this container
 	// depends on. Dependency containers must be started before this
-	// container. Dependencies can be specified by name or full/partial ID.
+	// container. Dependencies can be specified by name or full/partial ID,
+	// optionally followed by a colon and the condition the dependency
+	// must reach: started (default), healthy or exited-successfully.
 	// Optional.
 	DependencyContainers []string `json:"dependencyContainers,omitempty"`
 	// PidFile is the file that saves container's PID.
@@ -299,6 +301,10 @@ type ContainerStorageConfig struct {
 	// Image volumes bind-mount a container-image mount into the container.
 	// Optional.
 	ImageVolumes []*ImageVolume `json:"image_volumes,omitempty"`
+	// Artifact volumes bind-mount the blobs of an OCI artifact into the
+	// container.
+	// Optional.
+	ArtifactVolumes []*ArtifactVolume `json:"artifact_volumes,omitempty"`
 	// Devices are devices that will be added to the container.
 	// Optional.
 	Devices []spec.LinuxDevice `json:"devices,omitempty"`
@@ -55,6 +55,16 @@ type ImageVolume struct {
 	ReadWrite bool
 }
 
+// ArtifactVolume is a volume based on an OCI artifact in the local artifact
+// store.  Each blob of the artifact is bind-mounted read-only into the
+// destination directory, named after its title.
+type ArtifactVolume struct {
+	// Source is the name or digest of the artifact.
+	Source string `json:"source"`
+	// Destination is the absolute path of the directory in the container.
+	Destination string `json:"destination"`
+}
+
 // GenVolumeMounts parses user input into mounts, volumes and overlay volumes
 func GenVolumeMounts(volumeFlag []string) (map[string]spec.Mount, map[string]*NamedVolume, map[string]*OverlayVolume, error) {
 	mounts := make(map[string]spec.Mount)
@@ -5,6 +5,7 @@ import (
 
 	"github.com/containers/common/pkg/config"
 	"github.com/containers/podman/v5/pkg/domain/entities"
+	"github.com/containers/podman/v5/pkg/util"
 )
 
 // validate determines if the flags and values given by the user are valid. things checked
@@ -15,7 +16,7 @@ func validate(c *entities.ContainerCreateOptions) error {
 		return errors.New(`the --rm option conflicts with --restart, when the restartPolicy is not "" and "no"`)
 	}
 
-	if _, err := config.ParsePullPolicy(c.Pull); err != nil {
+	if _, _, err := util.ParsePullPolicy(c.Pull); err != nil {
 		return err
 	}
 
@@ -754,7 +754,7 @@ func FillOutSpecGen(s *specgen.SpecGenerator, c *entities.ContainerCreateOptions
 
 	// Only add read-only tmpfs mounts in case that we are read-only and the
 	// read-only tmpfs flag has been set.
-	mounts, volumes, overlayVolumes, imageVolumes, err := parseVolumes(rtc, c.Volume, c.Mount, c.TmpFS)
+	mounts, volumes, overlayVolumes, imageVolumes, artifactVolumes, err := parseVolumes(rtc, c.Volume, c.Mount, c.TmpFS)
 	if err != nil {
 		return err
 	}
@@ -782,6 +782,9 @@ func FillOutSpecGen(s *specgen.SpecGenerator, c *entities.ContainerCreateOptions
 	if len(s.ImageVolumes) == 0 {
 		s.ImageVolumes = imageVolumes
 	}
+	if len(s.ArtifactVolumes) == 0 {
+		s.ArtifactVolumes = artifactVolumes
+	}
 
 	devices := c.Devices
 	for _, gpu := range c.GPUs {
@@ -1076,6 +1079,73 @@ func parseThrottleIOPsDevices(iopsDevices []string) (map[string]specs.LinuxThrot
 	return td, nil
 }
 
+func parseHugeTLBLimits(limits []string) ([]specs.LinuxHugepageLimit, error) {
+	hugetlb := make([]specs.LinuxHugepageLimit, 0, len(limits))
+	for _, l := range limits {
+		pagesize, val, hasVal := strings.Cut(l, ":")
+		if !hasVal {
+			return nil, fmt.Errorf("bad format: %s", l)
+		}
+		size, err := units.RAMInBytes(pagesize)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid page size for hugetlb limit: %s", l)
+		}
+		limit, err := units.RAMInBytes(val)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid limit for hugetlb limit: %s. The correct format is <pagesize>:<number>[<unit>]", l)
+		}
+		hugetlb = append(hugetlb, specs.LinuxHugepageLimit{
+			Pagesize: hugePageSizeName(size),
+			Limit:    uint64(limit),
+		})
+	}
+	return hugetlb, nil
+}
+
+// hugePageSizeName returns the name the hugetlb controller uses for pages
+// of the given size in bytes, e.g. 2MB or 1GB.
+func hugePageSizeName(size int64) string {
+	suffixes := []string{"B", "KB", "MB", "GB", "TB", "PB"}
+	i := 0
+	for size%1024 == 0 && i < len(suffixes)-1 {
+		size /= 1024
+		i++
+	}
+	return fmt.Sprintf("%d%s", size, suffixes[i])
+}
+
+func parseRdmaLimits(limits []string) (map[string]specs.LinuxRdma, error) {
+	rdma := make(map[string]specs.LinuxRdma)
+	for _, l := range limits {
+		fields := strings.Split(l, ":")
+		if len(fields) != 3 || fields[0] == "" {
+			return nil, fmt.Errorf("bad format: %s. The correct format is <device>:<hca_handles>:<hca_objects>", l)
+		}
+		var limit specs.LinuxRdma
+		if fields[1] != "" {
+			handles, err := strconv.ParseUint(fields[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hca_handles for rdma limit: %s", l)
+			}
+			h := uint32(handles)
+			limit.HcaHandles = &h
+		}
+		if fields[2] != "" {
+			objects, err := strconv.ParseUint(fields[2], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hca_objects for rdma limit: %s", l)
+			}
+			o := uint32(objects)
+			limit.HcaObjects = &o
+		}
+		if limit.HcaHandles == nil && limit.HcaObjects == nil {
+			return nil, fmt.Errorf("no limit given for rdma device: %s", l)
+		}
+		rdma[fields[0]] = limit
+	}
+	return rdma, nil
+}
+
 func parseSecrets(secrets []string) ([]specgen.Secret, map[string]string, error) {
 	secretParseError := errors.New("parsing secret")
 	var mount []specgen.Secret
@@ -1264,6 +1334,20 @@ func GetResources(s *specgen.SpecGenerator, c *entities.ContainerCreateOptions)
 		s.ResourceLimits.Pids = &pids
 	}
 
+	if len(c.HugeTLB) > 0 {
+		s.ResourceLimits.HugepageLimits, err = parseHugeTLBLimits(c.HugeTLB)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(c.Rdma) > 0 {
+		s.ResourceLimits.Rdma, err = parseRdmaLimits(c.Rdma)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if s.ResourceLimits.CPU == nil || (c.CPUPeriod != 0 || c.CPUQuota != 0 || c.CPURTPeriod != 0 || c.CPURTRuntime != 0 || c.CPUS != 0 || len(c.CPUSetCPUs) != 0 || len(c.CPUSetMems) != 0 || c.CPUShares != 0) {
 		s.ResourceLimits.CPU = getCPULimits(c)
 	}
@@ -1280,7 +1364,8 @@ func GetResources(s *specgen.SpecGenerator, c *entities.ContainerCreateOptions)
 		s.ResourceLimits.Unified = unifieds
 	}
 
-	if s.ResourceLimits.CPU == nil && s.ResourceLimits.Pids == nil && s.ResourceLimits.BlockIO == nil && s.ResourceLimits.Memory == nil && s.ResourceLimits.Unified == nil {
+	if s.ResourceLimits.CPU == nil && s.ResourceLimits.Pids == nil && s.ResourceLimits.BlockIO == nil && s.ResourceLimits.Memory == nil && s.ResourceLimits.Unified == nil &&
+		s.ResourceLimits.HugepageLimits == nil && s.ResourceLimits.Rdma == nil {
 		s.ResourceLimits = nil
 	}
 	return s.ResourceLimits, nil
@@ -156,6 +156,38 @@ func TestParseLinuxResourcesDeviceAccess(t *testing.T) {
 	assert.NotNil(t, err, "err is not nil")
 }
 
+func TestParseHugeTLBLimits(t *testing.T) {
+	limits, err := parseHugeTLBLimits([]string{"2MB:1g", "1GB:2g", "64k:128m"})
+	assert.NoError(t, err)
+	assert.Len(t, limits, 3)
+	assert.Equal(t, "2MB", limits[0].Pagesize)
+	assert.Equal(t, uint64(1<<30), limits[0].Limit)
+	assert.Equal(t, "1GB", limits[1].Pagesize)
+	assert.Equal(t, uint64(2<<30), limits[1].Limit)
+	assert.Equal(t, "64KB", limits[2].Pagesize)
+	assert.Equal(t, uint64(128<<20), limits[2].Limit)
+
+	for _, bad := range []string{"2MB", "abc:1g", "2MB:abc", "0:1g"} {
+		_, err = parseHugeTLBLimits([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseRdmaLimits(t *testing.T) {
+	limits, err := parseRdmaLimits([]string{"mlx5_0:3:100", "mlx5_1::10"})
+	assert.NoError(t, err)
+	assert.Len(t, limits, 2)
+	assert.Equal(t, uint32(3), *limits["mlx5_0"].HcaHandles)
+	assert.Equal(t, uint32(100), *limits["mlx5_0"].HcaObjects)
+	assert.Nil(t, limits["mlx5_1"].HcaHandles)
+	assert.Equal(t, uint32(10), *limits["mlx5_1"].HcaObjects)
+
+	for _, bad := range []string{"mlx5_0", "mlx5_0:3", ":3:100", "mlx5_0::", "mlx5_0:a:1", "mlx5_0:1:-1"} {
+		_, err = parseRdmaLimits([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
+
 func TestGenRlimits(t *testing.T) {
 	testLimits := map[string]string{
 		"core":       "1:2",
@@ -66,3 +66,50 @@ func Test_validChownFlag(t *testing.T) {
 		})
 	}
 }
+
+func Test_getArtifactVolume(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantSrc  string
+		wantDest string
+		wantErr  bool
+	}{
+		{
+			name:     "source and destination",
+			args:     []string{"src=quay.io/foo/bar:latest", "dst=/data/"},
+			wantSrc:  "quay.io/foo/bar:latest",
+			wantDest: "/data",
+		},
+		{
+			name:    "missing destination",
+			args:    []string{"source=quay.io/foo/bar"},
+			wantErr: true,
+		},
+		{
+			name:    "relative destination",
+			args:    []string{"source=quay.io/foo/bar", "target=data"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported option",
+			args:    []string{"source=quay.io/foo/bar", "target=/data", "rw=true"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getArtifactVolume(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getArtifactVolume() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if got.Source != tt.wantSrc || got.Destination != tt.wantDest {
+				t.Errorf("getArtifactVolume() got = %+v, want source %q and destination %q", got, tt.wantSrc, tt.wantDest)
+			}
+		})
+	}
+}
@@ -3,10 +3,12 @@
 package generate
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/containers/podman/v5/pkg/domain/entities"
 	"github.com/containers/podman/v5/pkg/systemd/define"
 )
 
@@ -25,6 +27,15 @@ func validateRestartPolicy(restart string) error {
 	return fmt.Errorf("%s is not a valid restart policy", restart)
 }
 
+// validateRestartBackoff checks that the restart backoff options are either
+// both set or both unset.
+func validateRestartBackoff(options entities.GenerateSystemdOptions) error {
+	if (options.RestartSteps == nil) != (options.RestartMaxDelaySec == nil) {
+		return errors.New("--restart-steps and --restart-max-delay-sec must be used together")
+	}
+	return nil
+}
+
 const headerTemplate = `# {{{{.ServiceName}}}}{{{{- if (eq .IdentifySpecifier true) }}}}@{{{{- end}}}}.service
 {{{{- if (eq .GenerateNoHeader false) }}}}
 # autogenerated by Podman {{{{.PodmanVersion}}}}
@@ -31,6 +31,8 @@ type containerInfo struct {
 	StopTimeout            uint
 	RestartPolicy          string
 	RestartSec             uint
+	RestartSteps           uint
+	RestartMaxDelaySec     uint
 	StartLimitBurst        string
 	PIDFile                string
 	ContainerIDFile        string
@@ -93,6 +95,10 @@ Restart={{{{.RestartPolicy}}}}
 {{{{- if .RestartSec}}}}
 RestartSec={{{{.RestartSec}}}}
 {{{{- end}}}}
+{{{{- if .RestartSteps}}}}
+RestartSteps={{{{.RestartSteps}}}}
+RestartMaxDelaySec={{{{.RestartMaxDelaySec}}}}
+{{{{- end}}}}
 {{{{- if .StartLimitBurst}}}}
 StartLimitBurst={{{{.StartLimitBurst}}}}
 {{{{- end}}}}
@@ -123,6 +129,9 @@ WantedBy=default.target
 // on the options, the return value might be the entire unit or a file it has
 // been written to.
 func ContainerUnit(ctr *libpod.Container, options entities.GenerateSystemdOptions) (string, string, error) {
+	if options.ExitCodePropagation != nil {
+		return "", "", errors.New("--exit-code-propagation is only supported for pods")
+	}
 	info, err := generateContainerInfo(ctr, options)
 	if err != nil {
 		return "", "", err
@@ -296,6 +305,26 @@ func executeContainerTemplate(info *containerInfo, options entities.GenerateSyst
 		info.RestartSec = *options.RestartSec
 	}
 
+	if err := validateRestartBackoff(options); err != nil {
+		return "", err
+	}
+	if options.RestartSteps != nil {
+		info.RestartSteps = *options.RestartSteps
+		info.RestartMaxDelaySec = *options.RestartMaxDelaySec
+	}
+
+	if options.SdNotify != nil {
+		if !options.New {
+			return "", errors.New("--sdnotify requires --new")
+		}
+		if *options.SdNotify == "" || *options.SdNotify == libpodDefine.SdNotifyModeIgnore {
+			return "", fmt.Errorf("--sdnotify must be one of %s, %s or %s", libpodDefine.SdNotifyModeConmon, libpodDefine.SdNotifyModeContainer, libpodDefine.SdNotifyModeHealthy)
+		}
+		if err := libpodDefine.ValidateSdNotifyMode(*options.SdNotify); err != nil {
+			return "", err
+		}
+	}
+
 	// Make sure the executable is set.
 	if info.Executable == "" {
 		executable, err := os.Executable()
@@ -394,11 +423,18 @@ func executeContainerTemplate(info *containerInfo, options entities.GenerateSyst
 		}
 
 		// Default to --sdnotify=conmon unless already set by the
-		// container.
+		// container.  An explicitly requested mode always takes
+		// precedence.
 		sdnotifyFlag := fs.Lookup("sdnotify")
-		if !sdnotifyFlag.Changed {
+		switch {
+		case options.SdNotify != nil:
+			if sdnotifyFlag.Changed {
+				remainingCmd = removeSdNotifyArg(remainingCmd, fs.NArg())
+			}
+			startCommand = append(startCommand, "--sdnotify="+*options.SdNotify)
+		case !sdnotifyFlag.Changed:
 			startCommand = append(startCommand, "--sdnotify=conmon")
-		} else if sdnotifyFlag.Value.String() == libpodDefine.SdNotifyModeIgnore {
+		case sdnotifyFlag.Value.String() == libpodDefine.SdNotifyModeIgnore:
 			// If ignore is set force conmon otherwise the unit with Type=notify will fail.
 			logrus.Infof("Forcing --sdnotify=conmon for container %s", info.ContainerNameOrID)
 			remainingCmd = removeSdNotifyArg(remainingCmd, fs.NArg())
@@ -1769,3 +1769,30 @@ func TestSetContainerNameForTemplate(t *testing.T) {
 		assert.Equal(t, te.expected, res)
 	}
 }
+
+func TestContainerSdNotifyOption(t *testing.T) {
+	newInfo := func() *containerInfo {
+		return &containerInfo{
+			Executable:        "/usr/bin/podman",
+			ServiceName:       "container-foobar",
+			ContainerNameOrID: "foobar",
+			StopTimeout:       10,
+			PodmanVersion:     "CI",
+			CreateCommand:     []string{"podman", "run", "-d", "--sdnotify=conmon", "awesome-image:latest"},
+		}
+	}
+
+	mode := "container"
+	got, err := executeContainerTemplate(newInfo(), entities.GenerateSystemdOptions{New: true, SdNotify: &mode})
+	assert.NoError(t, err)
+	assert.Contains(t, got, "--sdnotify=container")
+	assert.NotContains(t, got, "--sdnotify=conmon")
+	assert.Contains(t, got, "Type=notify\nNotifyAccess=all\n")
+
+	_, err = executeContainerTemplate(newInfo(), entities.GenerateSystemdOptions{SdNotify: &mode})
+	assert.ErrorContains(t, err, "requires --new")
+
+	mode = "ignore"
+	_, err = executeContainerTemplate(newInfo(), entities.GenerateSystemdOptions{New: true, SdNotify: &mode})
+	assert.ErrorContains(t, err, "--sdnotify must be one of")
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/containers/podman/v5/libpod"
+	libpodDefine "github.com/containers/podman/v5/libpod/define"
 	"github.com/containers/podman/v5/pkg/domain/entities"
 	"github.com/containers/podman/v5/pkg/systemd/define"
 	"github.com/containers/podman/v5/version"
@@ -34,6 +35,12 @@ type podInfo struct {
 	RestartPolicy string
 	// RestartSec of the systemd unit. Configures the time to sleep before restarting a service.
 	RestartSec uint
+	// RestartSteps of the systemd unit. Number of steps to increase the
+	// restart interval from RestartSec to RestartMaxDelaySec.
+	RestartSteps uint
+	// RestartMaxDelaySec of the systemd unit. The longest time to sleep
+	// before restarting a service.
+	RestartMaxDelaySec uint
 	// PIDFile of the service. Required for forking services. Must point to the
 	// PID of the associated conmon process.
 	PIDFile string
@@ -114,6 +121,10 @@ Restart={{{{.RestartPolicy}}}}
 {{{{- if .RestartSec}}}}
 RestartSec={{{{.RestartSec}}}}
 {{{{- end}}}}
+{{{{- if .RestartSteps}}}}
+RestartSteps={{{{.RestartSteps}}}}
+RestartMaxDelaySec={{{{.RestartMaxDelaySec}}}}
+{{{{- end}}}}
 TimeoutStopSec={{{{.TimeoutStopSec}}}}
 {{{{- if .ExecStartPre}}}}
 ExecStartPre={{{{.ExecStartPre}}}}
@@ -265,6 +276,24 @@ func hasPodExitPolicy(cmd []string) bool {
 	return false
 }
 
+// removeExitCodePropagationArg removes all --exit-code-propagation flags from
+// the pod create arguments.
+func removeExitCodePropagationArg(args []string) []string {
+	processed := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		s := args[i]
+		switch {
+		case s == "--exit-code-propagation":
+			i++
+			continue
+		case strings.HasPrefix(s, "--exit-code-propagation="):
+			continue
+		}
+		processed = append(processed, s)
+	}
+	return processed
+}
+
 // executePodTemplate executes the pod template on the specified podInfo.  Note
 // that the podInfo is also post processed and completed, which allows for an
 // easier unit testing.
@@ -281,6 +310,23 @@ func executePodTemplate(info *podInfo, options entities.GenerateSystemdOptions)
 		info.RestartSec = *options.RestartSec
 	}
 
+	if err := validateRestartBackoff(options); err != nil {
+		return "", err
+	}
+	if options.RestartSteps != nil {
+		info.RestartSteps = *options.RestartSteps
+		info.RestartMaxDelaySec = *options.RestartMaxDelaySec
+	}
+
+	if options.ExitCodePropagation != nil {
+		if !options.New {
+			return "", errors.New("--exit-code-propagation requires --new")
+		}
+		if _, err := libpodDefine.ParseKubeExitCodePropagation(*options.ExitCodePropagation); err != nil {
+			return "", err
+		}
+	}
+
 	// Make sure the executable is set.
 	if info.Executable == "" {
 		executable, err := os.Executable()
@@ -366,6 +412,12 @@ func executePodTemplate(info *podInfo, options entities.GenerateSystemdOptions)
 		if !hasPodExitPolicy(append(startCommand, podCreateArgs...)) {
 			startCommand = append(startCommand, "--exit-policy=stop")
 		}
+		// An explicitly requested exit-code propagation overrides the
+		// one the pod has been created with.
+		if options.ExitCodePropagation != nil {
+			podCreateArgs = removeExitCodePropagationArg(podCreateArgs)
+			startCommand = append(startCommand, "--exit-code-propagation="+*options.ExitCodePropagation)
+		}
 		startCommand = append(startCommand, podCreateArgs...)
 		startCommand = escapeSystemdArguments(startCommand)
 
@@ -32,6 +32,66 @@ func TestHasPodExitPolicy(t *testing.T) {
 	}
 }
 
+func TestRemoveExitCodePropagationArg(t *testing.T) {
+	tests := []struct {
+		input    []string
+		expected []string
+	}{
+		{
+			[]string{"--name", "foo"},
+			[]string{"--name", "foo"},
+		},
+		{
+			[]string{"--exit-code-propagation=all", "--name", "foo"},
+			[]string{"--name", "foo"},
+		},
+		{
+			[]string{"--exit-code-propagation", "all", "--name", "foo"},
+			[]string{"--name", "foo"},
+		},
+	}
+	for _, test := range tests {
+		assert.Equalf(t, test.expected, removeExitCodePropagationArg(test.input), "%v", test.input)
+	}
+}
+
+func TestPodExitCodePropagationAndRestartBackoff(t *testing.T) {
+	newInfo := func() *podInfo {
+		return &podInfo{
+			Executable:       "/usr/bin/podman",
+			ServiceName:      "pod-123abc",
+			InfraNameOrID:    "jadda-jadda-infra",
+			StopTimeout:      10,
+			PodmanVersion:    "CI",
+			RequiredServices: []string{"container-1"},
+			CreateCommand:    []string{"podman", "pod", "create", "--name", "foo", "--exit-code-propagation=all"},
+		}
+	}
+	ecp := "any"
+	steps, maxDelay := uint(5), uint(300)
+
+	got, err := executePodTemplate(newInfo(), entities.GenerateSystemdOptions{
+		New:                 true,
+		ExitCodePropagation: &ecp,
+		RestartSteps:        &steps,
+		RestartMaxDelaySec:  &maxDelay,
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, got, "--exit-policy=stop \\\n\t--exit-code-propagation=any \\\n\t--name foo")
+	assert.NotContains(t, got, "--exit-code-propagation=all")
+	assert.Contains(t, got, "RestartSteps=5\nRestartMaxDelaySec=300\n")
+
+	_, err = executePodTemplate(newInfo(), entities.GenerateSystemdOptions{ExitCodePropagation: &ecp})
+	assert.ErrorContains(t, err, "requires --new")
+
+	invalid := "some"
+	_, err = executePodTemplate(newInfo(), entities.GenerateSystemdOptions{New: true, ExitCodePropagation: &invalid})
+	assert.ErrorContains(t, err, "unsupported exit-code propagation")
+
+	_, err = executePodTemplate(newInfo(), entities.GenerateSystemdOptions{RestartSteps: &steps})
+	assert.ErrorContains(t, err, "must be used together")
+}
+
 func TestValidateRestartPolicyPod(t *testing.T) {
 	type podInfo struct {
 		restart string
@@ -25,15 +25,18 @@ const (
 	UnitDirDistro = "/usr/share/containers/systemd"
 
 	// Names of commonly used systemd/quadlet group names
+	BuildGroup      = "Build"
 	ContainerGroup  = "Container"
 	InstallGroup    = "Install"
 	KubeGroup       = "Kube"
+	PathGroup       = "Path"
 	NetworkGroup    = "Network"
 	PodGroup        = "Pod"
 	ServiceGroup    = "Service"
 	UnitGroup       = "Unit"
 	VolumeGroup     = "Volume"
 	ImageGroup      = "Image"
+	XBuildGroup     = "X-Build"
 	XContainerGroup = "X-Container"
 	XKubeGroup      = "X-Kube"
 	XNetworkGroup   = "X-Network"
@@ -77,10 +80,13 @@ const (
 	KeyExec                  = "Exec"
 	KeyExitCodePropagation   = "ExitCodePropagation"
 	KeyExposeHostPort        = "ExposeHostPort"
+	KeyFile                  = "File"
+	KeyForceRM               = "ForceRM"
 	KeyGateway               = "Gateway"
 	KeyGIDMap                = "GIDMap"
 	KeyGlobalArgs            = "GlobalArgs"
 	KeyGroup                 = "Group"
+	KeyGroupAdd              = "GroupAdd"
 	KeyHealthCmd             = "HealthCmd"
 	KeyHealthInterval        = "HealthInterval"
 	KeyHealthOnFailure       = "HealthOnFailure"
@@ -140,6 +146,7 @@ const (
 	KeySubnet                = "Subnet"
 	KeySubUIDMap             = "SubUIDMap"
 	KeySysctl                = "Sysctl"
+	KeyTarget                = "Target"
 	KeyTimezone              = "Timezone"
 	KeyTLSVerify             = "TLSVerify"
 	KeyTmpfs                 = "Tmpfs"
@@ -153,6 +160,7 @@ const (
 	KeyVolatileTmp           = "VolatileTmp" // deprecated
 	KeyVolume                = "Volume"
 	KeyVolumeName            = "VolumeName"
+	KeyWatchContext          = "WatchContext"
 	KeyWorkingDir            = "WorkingDir"
 	KeyYaml                  = "Yaml"
 )
@@ -320,8 +328,37 @@ var (
 		KeyVariant:              true,
 	}
 
+	// Supported keys in "Build" group
+	supportedBuildKeys = map[string]bool{
+		KeyAnnotation:           true,
+		KeyArch:                 true,
+		KeyAuthFile:             true,
+		KeyContainersConfModule: true,
+		KeyDNS:                  true,
+		KeyDNSOption:            true,
+		KeyDNSSearch:            true,
+		KeyEnvironment:          true,
+		KeyFile:                 true,
+		KeyForceRM:              true,
+		KeyGlobalArgs:           true,
+		KeyGroupAdd:             true,
+		KeyImageTag:             true,
+		KeyLabel:                true,
+		KeyNetwork:              true,
+		KeyPodmanArgs:           true,
+		KeyPull:                 true,
+		KeySecret:               true,
+		KeySetWorkingDirectory:  true,
+		KeyTarget:               true,
+		KeyTLSVerify:            true,
+		KeyVariant:              true,
+		KeyVolume:               true,
+		KeyWatchContext:         true,
+	}
+
 	supportedPodKeys = map[string]bool{
 		KeyContainersConfModule: true,
+		KeyExitCodePropagation:  true,
 		KeyGlobalArgs:           true,
 		KeyNetwork:              true,
 		KeyPodName:              true,
@@ -331,6 +368,10 @@ var (
 	}
 )
 
+// replaceExtension replaces the extension of name and adds the extra prefix
+// and suffix to its base name.  For template and instance unit names the
+// extra suffix is added before the "@", so that foo@bar.volume becomes
+// foo-volume@bar.service.
 func replaceExtension(name string, extension string, extraPrefix string, extraSuffix string) string {
 	baseName := name
 
@@ -339,9 +380,51 @@ func replaceExtension(name string, extension string, extraPrefix string, extraSu
 		baseName = name[:dot]
 	}
 
+	if prefix, instance, found := strings.Cut(baseName, "@"); found && extraSuffix != "" {
+		return extraPrefix + prefix + extraSuffix + "@" + instance + extension
+	}
+
 	return extraPrefix + baseName + extraSuffix + extension
 }
 
+// getDefaultResourceName returns the Podman name of the network, volume or
+// pod of a Quadlet unit if none is specified by the user, i.e. systemd-$name.
+// As "@" is not valid in these names, the instance of a template unit is
+// appended with an "_" instead, using %i for the template unit itself.
+func getDefaultResourceName(quadletName string) string {
+	baseName := replaceExtension(quadletName, "", "", "")
+	if prefix, instance, found := strings.Cut(baseName, "@"); found {
+		if instance == "" {
+			instance = "%i"
+		}
+		return "systemd-" + prefix + "_" + instance
+	}
+	return "systemd-" + baseName
+}
+
+// lookupResourceName returns the Podman name of the resource of the
+// referenced Quadlet unit.  A reference to an instance of a template unit,
+// like foo@%i.volume, resolves to the name of the template unit with %i
+// replaced by the instance.  If the unit is unknown, the default name is used.
+func lookupResourceName(quadletName string, names map[string]string) string {
+	if name, ok := names[quadletName]; ok && name != "" {
+		return name
+	}
+	baseName := replaceExtension(quadletName, "", "", "")
+	if prefix, instance, found := strings.Cut(baseName, "@"); found && instance != "" {
+		if name, ok := names[prefix+"@"+filepath.Ext(quadletName)]; ok && name != "" {
+			return strings.ReplaceAll(name, "%i", instance)
+		}
+	}
+	return getDefaultResourceName(quadletName)
+}
+
+// containsSystemdSpecifier returns whether s contains a systemd specifier
+// like %i which is only resolved by systemd when running the service.
+func containsSystemdSpecifier(s string) bool {
+	return strings.Contains(strings.ReplaceAll(s, "%%", ""), "%")
+}
+
 func isPortRange(port string) bool {
 	return validPortRange.MatchString(port)
 }
@@ -818,7 +901,7 @@ func ConvertNetwork(network *parser.UnitFile, name string) (*parser.UnitFile, st
 	// Derive network name from unit name (with added prefix), or use user-provided name.
 	networkName, ok := network.Lookup(NetworkGroup, KeyNetworkName)
 	if !ok || len(networkName) == 0 {
-		networkName = replaceExtension(name, "", "systemd-", "")
+		networkName = getDefaultResourceName(name)
 	}
 
 	// Need the containers filesystem mounted to start podman
@@ -922,7 +1005,7 @@ func ConvertVolume(volume *parser.UnitFile, name string, names map[string]string
 	// Derive volume name from unit name (with added prefix), or use user-provided name.
 	volumeName, ok := volume.Lookup(VolumeGroup, KeyVolumeName)
 	if !ok || len(volumeName) == 0 {
-		volumeName = replaceExtension(name, "", "systemd-", "")
+		volumeName = getDefaultResourceName(name)
 	}
 
 	// Need the containers filesystem mounted to start podman
@@ -1238,6 +1321,221 @@ func ConvertImage(image *parser.UnitFile) (*parser.UnitFile, string, error) {
 	return service, imageName, nil
 }
 
+func ConvertBuild(build *parser.UnitFile, names map[string]string) (*parser.UnitFile, string, error) {
+	service := build.Dup()
+	service.Filename = replaceExtension(build.Filename, ".service", "", "-build")
+
+	if build.Path != "" {
+		service.Add(UnitGroup, "SourcePath", build.Path)
+	}
+
+	if err := checkForUnknownKeys(build, BuildGroup, supportedBuildKeys); err != nil {
+		return nil, "", err
+	}
+
+	imageTags := build.LookupAll(BuildGroup, KeyImageTag)
+	if len(imageTags) == 0 {
+		return nil, "", fmt.Errorf("no ImageTag key specified")
+	}
+
+	contextDir, file, err := getBuildContext(build)
+	if err != nil {
+		return nil, "", err
+	}
+
+	/* Rename old Build group to X-Build so that systemd ignores it */
+	service.RenameGroup(BuildGroup, XBuildGroup)
+
+	// Need the containers filesystem mounted to start podman
+	service.Add(UnitGroup, "RequiresMountsFor", "%t/containers")
+
+	podman := createBasePodmanCommand(build, BuildGroup)
+	podman.add("build")
+
+	stringKeys := map[string]string{
+		KeyArch:     "--arch",
+		KeyAuthFile: "--authfile",
+		KeyPull:     "--pull",
+		KeyTarget:   "--target",
+		KeyVariant:  "--variant",
+	}
+
+	boolKeys := map[string]string{
+		KeyForceRM:   "--force-rm",
+		KeyTLSVerify: "--tls-verify",
+	}
+
+	for key, flag := range stringKeys {
+		lookupAndAddString(build, BuildGroup, key, flag, podman)
+	}
+
+	for key, flag := range boolKeys {
+		lookupAndAddBoolean(build, BuildGroup, key, flag, podman)
+	}
+
+	for _, imageTag := range imageTags {
+		podman.addf("--tag=%s", imageTag)
+	}
+
+	annotations := build.LookupAllKeyVal(BuildGroup, KeyAnnotation)
+	podman.addAnnotations(annotations)
+
+	labels := build.LookupAllKeyVal(BuildGroup, KeyLabel)
+	podman.addLabels(labels)
+
+	podmanEnv := build.LookupAllKeyVal(BuildGroup, KeyEnvironment)
+	podman.addEnv(podmanEnv)
+
+	for _, dns := range build.LookupAll(BuildGroup, KeyDNS) {
+		podman.addf("--dns=%s", dns)
+	}
+
+	for _, dnsOption := range build.LookupAll(BuildGroup, KeyDNSOption) {
+		podman.addf("--dns-option=%s", dnsOption)
+	}
+
+	for _, dnsSearch := range build.LookupAll(BuildGroup, KeyDNSSearch) {
+		podman.addf("--dns-search=%s", dnsSearch)
+	}
+
+	for _, groupAdd := range build.LookupAll(BuildGroup, KeyGroupAdd) {
+		podman.addf("--group-add=%s", groupAdd)
+	}
+
+	for _, secret := range build.LookupAllArgs(BuildGroup, KeySecret) {
+		podman.add("--secret", secret)
+	}
+
+	addNetworks(build, BuildGroup, service, names, podman)
+
+	if err := addVolumes(build, service, BuildGroup, names, podman); err != nil {
+		return nil, "", err
+	}
+
+	handlePodmanArgs(build, BuildGroup, podman)
+
+	if file != "" {
+		podman.addf("--file=%s", file)
+	}
+	podman.add(contextDir)
+
+	service.AddCmdline(ServiceGroup, "ExecStart", podman.Args)
+
+	// Keep the service active after the build so that dependent units do
+	// not rebuild the image, unless the build is triggered by changes of
+	// its context.
+	remainAfterExit := "yes"
+	if watch, ok := build.LookupBoolean(BuildGroup, KeyWatchContext); ok && watch {
+		remainAfterExit = "no"
+	}
+
+	service.Setv(ServiceGroup,
+		"Type", "oneshot",
+		"RemainAfterExit", remainAfterExit,
+
+		// The default syslog identifier is the exec basename (podman) which isn't very useful here
+		"SyslogIdentifier", "%N")
+
+	if _, ok := service.Lookup(ServiceGroup, ServiceKeyWorkingDirectory); !ok && !isURL(contextDir) {
+		service.Add(ServiceGroup, ServiceKeyWorkingDirectory, contextDir)
+	}
+
+	return service, imageTags[0], nil
+}
+
+// ConvertBuildPath returns a path unit which starts the build service of the
+// build unit whenever its context or Containerfile changes, or nil if the
+// build unit does not set WatchContext.
+func ConvertBuildPath(build *parser.UnitFile) (*parser.UnitFile, error) {
+	if watch, ok := build.LookupBoolean(BuildGroup, KeyWatchContext); !ok || !watch {
+		return nil, nil
+	}
+
+	contextDir, file, err := getBuildContext(build)
+	if err != nil {
+		return nil, err
+	}
+	if isURL(contextDir) {
+		return nil, fmt.Errorf("%s cannot be used with a remote build context", KeyWatchContext)
+	}
+
+	path := parser.NewUnitFile()
+	path.Filename = replaceExtension(build.Filename, ".path", "", "-build")
+
+	if build.Path != "" {
+		path.Add(UnitGroup, "SourcePath", build.Path)
+	}
+	path.Add(PathGroup, "PathModified", contextDir)
+	if file != "" && !isURL(file) {
+		path.Add(PathGroup, "PathModified", file)
+	}
+	path.Add(PathGroup, "Unit", replaceExtension(build.Filename, ".service", "", "-build"))
+	path.Add(InstallGroup, "WantedBy", "paths.target")
+
+	return path, nil
+}
+
+// getBuildContext returns the build context and the Containerfile of a build
+// unit.  The context is the working directory set with SetWorkingDirectory,
+// the directory of a local File or a remote File itself.
+func getBuildContext(build *parser.UnitFile) (string, string, error) {
+	file, _ := build.Lookup(BuildGroup, KeyFile)
+
+	contextDir := ""
+	if setWorkingDirectory, ok := build.Lookup(BuildGroup, KeySetWorkingDirectory); ok && len(setWorkingDirectory) > 0 {
+		var relativeToFile string
+		switch strings.ToLower(setWorkingDirectory) {
+		case "file":
+			if file == "" || isURL(file) {
+				return "", "", fmt.Errorf("SetWorkingDirectory=file requires a local File key")
+			}
+			relativeToFile = file
+		case "unit":
+			relativeToFile = build.Path
+		default:
+			dir, err := getAbsolutePath(build, setWorkingDirectory)
+			if err != nil {
+				return "", "", err
+			}
+			contextDir = dir
+		}
+		if contextDir == "" {
+			fileInWorkingDir, err := getAbsolutePath(build, relativeToFile)
+			if err != nil {
+				return "", "", err
+			}
+			contextDir = filepath.Dir(fileInWorkingDir)
+		}
+	}
+
+	switch {
+	case file == "":
+		if contextDir == "" {
+			return "", "", fmt.Errorf("neither SetWorkingDirectory, nor File key specified")
+		}
+	case isURL(file):
+		if contextDir == "" {
+			// A remote Containerfile or repository is the context itself
+			return file, "", nil
+		}
+	default:
+		absFile, err := getAbsolutePath(build, file)
+		if err != nil {
+			return "", "", err
+		}
+		if contextDir == "" {
+			contextDir = filepath.Dir(absFile)
+		}
+		file = absFile
+	}
+
+	return contextDir, file, nil
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
 func GetPodServiceName(podUnit *parser.UnitFile) string {
 	return replaceExtension(podUnit.Filename, "", "", "-pod")
 }
@@ -1262,7 +1560,7 @@ func ConvertPod(podUnit *parser.UnitFile, name string, podsInfoMap map[string]*P
 	// Derive pod name from unit name (with added prefix), or use user-provided name.
 	podName, ok := podUnit.Lookup(PodGroup, KeyPodName)
 	if !ok || len(podName) == 0 {
-		podName = replaceExtension(name, "", "systemd-", "")
+		podName = getDefaultResourceName(name)
 	}
 
 	/* Rename old Pod group to x-Pod so that systemd ignores it */
@@ -1311,6 +1609,10 @@ func ConvertPod(podUnit *parser.UnitFile, name string, podsInfoMap map[string]*P
 		"--replace",
 	)
 
+	if ecp, ok := podUnit.Lookup(PodGroup, KeyExitCodePropagation); ok && len(ecp) > 0 {
+		execStartPre.addf("--exit-code-propagation=%s", ecp)
+	}
+
 	if err := handlePublishPorts(podUnit, PodGroup, execStartPre); err != nil {
 		return nil, err
 	}
@@ -1476,10 +1778,7 @@ func addNetworks(quadletUnitFile *parser.UnitFile, groupName string, serviceUnit
 			quadletNetworkName, options, found := strings.Cut(network, ":")
 			if strings.HasSuffix(quadletNetworkName, ".network") {
 				// the podman network name is systemd-$name if none is specified by the user.
-				networkName := names[quadletNetworkName]
-				if networkName == "" {
-					networkName = replaceExtension(quadletNetworkName, "", "systemd-", "")
-				}
+				networkName := lookupResourceName(quadletNetworkName, names)
 
 				// the systemd unit name is $name-network.service
 				networkServiceName := replaceExtension(quadletNetworkName, ".service", "", "-network")
@@ -1568,11 +1867,13 @@ func handlePublishPorts(unitFile *parser.UnitFile, groupName string, podman *Pod
 			ip = ""
 		}
 
-		if len(hostPort) > 0 && !isPortRange(hostPort) {
+		// Ports using specifiers, like %i in template units, are only known
+		// when the service runs, so leave their validation to podman.
+		if len(hostPort) > 0 && !isPortRange(hostPort) && !containsSystemdSpecifier(hostPort) {
 			return fmt.Errorf("invalid port format '%s'", hostPort)
 		}
 
-		if len(containerPort) > 0 && !isPortRange(containerPort) {
+		if len(containerPort) > 0 && !isPortRange(containerPort) && !containsSystemdSpecifier(containerPort) {
 			return fmt.Errorf("invalid port format '%s'", containerPort)
 		}
 
@@ -1612,10 +1913,7 @@ func handleStorageSource(quadletUnitFile, serviceUnitFile *parser.UnitFile, sour
 		serviceUnitFile.Add(UnitGroup, "RequiresMountsFor", source)
 	} else if strings.HasSuffix(source, ".volume") {
 		// the podman volume name is systemd-$name if none has been provided by the user.
-		volumeName := names[source]
-		if volumeName == "" {
-			volumeName = replaceExtension(source, "", "systemd-", "")
-		}
+		volumeName := lookupResourceName(source, names)
 
 		// the systemd unit name is $name-volume.service
 		volumeServiceName := replaceExtension(source, ".service", "", "-volume")
@@ -1710,6 +2008,22 @@ func lookupAndAddBoolean(unit *parser.UnitFile, group, key, flag string, podman
 }
 
 func handleImageSource(quadletImageName string, serviceUnitFile *parser.UnitFile, names map[string]string) (string, error) {
+	if strings.HasSuffix(quadletImageName, ".build") {
+		// the image name is the first ImageTag of the build unit
+		imageName, ok := names[quadletImageName]
+		if !ok {
+			return "", fmt.Errorf("requested Quadlet build %s was not found", quadletImageName)
+		}
+
+		// the systemd unit name is $name-build.service
+		buildServiceName := replaceExtension(quadletImageName, ".service", "", "-build")
+
+		serviceUnitFile.Add(UnitGroup, "Requires", buildServiceName)
+		serviceUnitFile.Add(UnitGroup, "After", buildServiceName)
+
+		return imageName, nil
+	}
+
 	if strings.HasSuffix(quadletImageName, ".image") {
 		// since there is no default name conversion, the actual image name must exist in the names map
 		imageName, ok := names[quadletImageName]
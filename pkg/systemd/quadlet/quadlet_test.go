@@ -43,3 +43,23 @@ func TestQuadlet_SplitPorts(t *testing.T) {
 	assert.Equal(t, parts[0], "foo")
 	assert.Equal(t, parts[1], "abc[foo::barxyz:bar")
 }
+
+func TestQuadlet_TemplateNames(t *testing.T) {
+	assert.Equal(t, "foo-volume.service", replaceExtension("foo.volume", ".service", "", "-volume"))
+	assert.Equal(t, "foo-volume@.service", replaceExtension("foo@.volume", ".service", "", "-volume"))
+	assert.Equal(t, "foo-volume@bar.service", replaceExtension("foo@bar.volume", ".service", "", "-volume"))
+	assert.Equal(t, "foo@bar.service", replaceExtension("foo@bar.container", ".service", "", ""))
+
+	assert.Equal(t, "systemd-foo", getDefaultResourceName("foo.volume"))
+	assert.Equal(t, "systemd-foo_%i", getDefaultResourceName("foo@.volume"))
+	assert.Equal(t, "systemd-foo_bar", getDefaultResourceName("foo@bar.volume"))
+
+	names := map[string]string{
+		"foo@.network": "net-%i",
+		"bar.network":  "bar",
+	}
+	assert.Equal(t, "net-1", lookupResourceName("foo@1.network", names))
+	assert.Equal(t, "net-%i", lookupResourceName("foo@%i.network", names))
+	assert.Equal(t, "bar", lookupResourceName("bar.network", names))
+	assert.Equal(t, "systemd-baz_1", lookupResourceName("baz@1.network", names))
+}
@@ -0,0 +1,122 @@
+package trust
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/signature"
+)
+
+// Requirement describes a policy requirement which accepted an image.
+type Requirement struct {
+	// Type of the requirement, e.g. "signedBy" or "sigstoreSigned".
+	Type string `json:"type"`
+	// KeyPaths are the paths of the public keys the requirement trusts.
+	KeyPaths []string `json:"keyPaths,omitempty"`
+	// EmbeddedKey is set if the requirement includes the public key.
+	EmbeddedKey bool `json:"embeddedKey,omitempty"`
+	// FulcioOIDCIssuer is the OIDC issuer of the Fulcio identity the
+	// requirement trusts.
+	FulcioOIDCIssuer string `json:"fulcioOIDCIssuer,omitempty"`
+	// FulcioSubjectEmail is the subject email of the Fulcio identity the
+	// requirement trusts.
+	FulcioSubjectEmail string `json:"fulcioSubjectEmail,omitempty"`
+}
+
+// requirementContent is the policy.json representation of the requirements
+// described by Requirement (= c/image/v5/signature.{prSignedBy,prSigstoreSigned}).
+type requirementContent struct {
+	Type     string   `json:"type"`
+	KeyPath  string   `json:"keyPath,omitempty"`
+	KeyPaths []string `json:"keyPaths,omitempty"`
+	KeyData  []byte   `json:"keyData,omitempty"`
+	Fulcio   *struct {
+		OIDCIssuer   string `json:"oidcIssuer,omitempty"`
+		SubjectEmail string `json:"subjectEmail,omitempty"`
+	} `json:"fulcio,omitempty"`
+}
+
+// Verified returns whether the requirement verifies signatures.
+func (r *Requirement) Verified() bool {
+	return r.Type == "signedBy" || r.Type == "sigstoreSigned"
+}
+
+// String returns a user-readable description of the requirement.
+func (r *Requirement) String() string {
+	var mechanism string
+	switch r.Type {
+	case "insecureAcceptAnything":
+		return "accepted without verification"
+	case "signedBaseLayer":
+		return "accepted by base layer identity"
+	case "signedBy":
+		mechanism = "simple signing"
+	case "sigstoreSigned":
+		mechanism = "sigstore"
+	default:
+		return "accepted by " + r.Type
+	}
+	switch {
+	case r.FulcioSubjectEmail != "":
+		return fmt.Sprintf("verified by %s Fulcio identity %s (%s)", mechanism, r.FulcioSubjectEmail, r.FulcioOIDCIssuer)
+	case len(r.KeyPaths) > 0:
+		return fmt.Sprintf("verified by %s key %s", mechanism, strings.Join(r.KeyPaths, ", "))
+	case r.EmbeddedKey:
+		return fmt.Sprintf("verified by %s key embedded in policy", mechanism)
+	default:
+		return "verified by " + mechanism
+	}
+}
+
+// Status describes how an image was verified against the trust policy.
+type Status struct {
+	// Reference the image was verified for.
+	Reference string `json:"reference"`
+	// Time of the verification.
+	Time time.Time `json:"time"`
+	// Verified is set if signatures of the image have been verified.
+	Verified bool `json:"verified"`
+	// Requirements of the policy which accepted the image.
+	Requirements []Requirement `json:"requirements"`
+}
+
+// NewStatus returns the status of an image accepted for reference by the
+// specified policy requirements.
+func NewStatus(reference string, reqs signature.PolicyRequirements) (*Status, error) {
+	status := Status{Reference: reference, Time: time.Now()}
+	for _, req := range reqs {
+		data, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		content := requirementContent{}
+		if err := json.Unmarshal(data, &content); err != nil {
+			return nil, err
+		}
+		requirement := Requirement{Type: content.Type, EmbeddedKey: len(content.KeyData) > 0}
+		if content.KeyPath != "" {
+			requirement.KeyPaths = append(requirement.KeyPaths, content.KeyPath)
+		}
+		requirement.KeyPaths = append(requirement.KeyPaths, content.KeyPaths...)
+		if content.Fulcio != nil {
+			requirement.FulcioOIDCIssuer = content.Fulcio.OIDCIssuer
+			requirement.FulcioSubjectEmail = content.Fulcio.SubjectEmail
+		}
+		if requirement.Verified() {
+			status.Verified = true
+		}
+		status.Requirements = append(status.Requirements, requirement)
+	}
+	return &status, nil
+}
+
+// String returns a user-readable description of the status.
+func (s *Status) String() string {
+	descriptions := make([]string, 0, len(s.Requirements))
+	for _, r := range s.Requirements {
+		descriptions = append(descriptions, r.String())
+	}
+	return strings.Join(descriptions, "; ")
+}
@@ -0,0 +1,47 @@
+package trust
+
+import (
+	"testing"
+
+	"github.com/containers/image/v5/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStatus(t *testing.T) {
+	status, err := NewStatus("quay.io/libpod/alpine:latest", signature.PolicyRequirements{signature.NewPRInsecureAcceptAnything()})
+	require.NoError(t, err)
+	assert.Equal(t, "quay.io/libpod/alpine:latest", status.Reference)
+	assert.False(t, status.Verified)
+	assert.Equal(t, "accepted without verification", status.String())
+
+	signedBy, err := signature.NewPRSignedByKeyPath(signature.SBKeyTypeGPGKeys, "/etc/pki/gpg.pub", signature.NewPRMMatchRepoDigestOrExact())
+	require.NoError(t, err)
+	sigstoreKey, err := signature.NewPRSigstoreSignedKeyData([]byte("key"), signature.NewPRMMatchRepoDigestOrExact())
+	require.NoError(t, err)
+	status, err = NewStatus("quay.io/libpod/alpine:latest", signature.PolicyRequirements{signedBy, sigstoreKey})
+	require.NoError(t, err)
+	assert.True(t, status.Verified)
+	assert.Equal(t, []Requirement{
+		{Type: "signedBy", KeyPaths: []string{"/etc/pki/gpg.pub"}},
+		{Type: "sigstoreSigned", EmbeddedKey: true},
+	}, status.Requirements)
+	assert.Equal(t, "verified by simple signing key /etc/pki/gpg.pub; verified by sigstore key embedded in policy", status.String())
+
+	fulcio, err := signature.NewPRSigstoreSignedFulcio(
+		signature.PRSigstoreSignedFulcioWithCAPath("/etc/pki/fulcio.pem"),
+		signature.PRSigstoreSignedFulcioWithOIDCIssuer("https://github.com/login/oauth"),
+		signature.PRSigstoreSignedFulcioWithSubjectEmail("dev@example.com"),
+	)
+	require.NoError(t, err)
+	sigstoreFulcio, err := signature.NewPRSigstoreSigned(
+		signature.PRSigstoreSignedWithFulcio(fulcio),
+		signature.PRSigstoreSignedWithRekorPublicKeyPath("/etc/pki/rekor.pub"),
+		signature.PRSigstoreSignedWithSignedIdentity(signature.NewPRMMatchRepoDigestOrExact()),
+	)
+	require.NoError(t, err)
+	status, err = NewStatus("quay.io/libpod/alpine:latest", signature.PolicyRequirements{sigstoreFulcio})
+	require.NoError(t, err)
+	assert.True(t, status.Verified)
+	assert.Equal(t, "verified by sigstore Fulcio identity dev@example.com (https://github.com/login/oauth)", status.String())
+}
@@ -153,6 +153,29 @@ func ParseRegistryCreds(creds string) (*types.DockerAuthConfig, error) {
 	}, nil
 }
 
+// ParsePullPolicy parses the pull policy like config.ParsePullPolicy and
+// additionally supports "max-age=DURATION" and "daily", which is short for
+// "max-age=24h".  These policies pull like "newer" but only contact the
+// registry if it has not been checked for a newer image within the returned
+// max age.
+func ParsePullPolicy(s string) (config.PullPolicy, time.Duration, error) {
+	if s == "daily" {
+		return config.PullPolicyNewer, 24 * time.Hour, nil
+	}
+	if value, ok := strings.CutPrefix(s, "max-age="); ok {
+		maxAge, err := time.ParseDuration(value)
+		if err != nil {
+			return config.PullPolicyUnsupported, 0, fmt.Errorf("invalid max age of pull policy %q: %w", s, err)
+		}
+		if maxAge <= 0 {
+			return config.PullPolicyUnsupported, 0, fmt.Errorf("max age of pull policy %q must be positive", s)
+		}
+		return config.PullPolicyNewer, maxAge, nil
+	}
+	policy, err := config.ParsePullPolicy(s)
+	return policy, 0, err
+}
+
 // StringMatchRegexSlice determines if a given string matches one of the given regexes, returns bool
 func StringMatchRegexSlice(s string, re []string) bool {
 	for _, r := range re {
@@ -1122,7 +1145,7 @@ func ParseInputTime(inputTime string, since bool) (time.Time, error) {
 	}
 
 	// input might be a duration
-	duration, err := time.ParseDuration(inputTime)
+	duration, err := parseDuration(inputTime)
 	if err != nil {
 		return time.Time{}, errors.New("unable to interpret time value")
 	}
@@ -1132,6 +1155,28 @@ func ParseInputTime(inputTime string, since bool) (time.Time, error) {
 	return time.Now().Add(duration), nil
 }
 
+// parseDuration parses a Go duration string which may be prefixed with a
+// number of days, e.g. 3d or 1d12h.
+func parseDuration(inputDuration string) (time.Duration, error) {
+	days, rest, found := strings.Cut(inputDuration, "d")
+	if !found {
+		return time.ParseDuration(inputDuration)
+	}
+	numDays, err := strconv.ParseUint(days, 10, 16)
+	if err != nil {
+		return 0, err
+	}
+	duration := time.Duration(numDays) * 24 * time.Hour
+	if len(rest) > 0 {
+		restDuration, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, err
+		}
+		duration += restDuration
+	}
+	return duration, nil
+}
+
 // OpenExclusiveFile opens a file for writing and ensure it doesn't already exist
 func OpenExclusiveFile(path string) (*os.File, error) {
 	baseDir := filepath.Dir(path)
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/containers/common/pkg/config"
 	"github.com/containers/storage/pkg/idtools"
 	ruser "github.com/moby/sys/user"
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -548,6 +549,20 @@ func TestParseInputTime(t *testing.T) {
 	assert.Equal(t, expected, tm)
 }
 
+func TestParseInputTimeDays(t *testing.T) {
+	before := time.Now()
+	tm, err := ParseInputTime("3d", true)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, before.Add(-72*time.Hour), tm, time.Minute)
+
+	tm, err = ParseInputTime("1d12h", false)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, before.Add(36*time.Hour), tm, time.Minute)
+
+	_, err = ParseInputTime("xd", true)
+	assert.Error(t, err)
+}
+
 func TestConvertMappings(t *testing.T) {
 	start := []specs.LinuxIDMapping{
 		{
@@ -588,3 +603,27 @@ func TestConvertTimeout(t *testing.T) {
 	timeout = ConvertTimeout(-100)
 	assert.Equal(t, uint(math.MaxUint32), timeout)
 }
+
+func TestParsePullPolicy(t *testing.T) {
+	policy, maxAge, err := ParsePullPolicy("newer")
+	assert.NoError(t, err)
+	assert.Equal(t, config.PullPolicyNewer, policy)
+	assert.Zero(t, maxAge)
+
+	policy, maxAge, err = ParsePullPolicy("daily")
+	assert.NoError(t, err)
+	assert.Equal(t, config.PullPolicyNewer, policy)
+	assert.Equal(t, 24*time.Hour, maxAge)
+
+	policy, maxAge, err = ParsePullPolicy("max-age=90m")
+	assert.NoError(t, err)
+	assert.Equal(t, config.PullPolicyNewer, policy)
+	assert.Equal(t, 90*time.Minute, maxAge)
+
+	_, _, err = ParsePullPolicy("max-age=0s")
+	assert.ErrorContains(t, err, "must be positive")
+	_, _, err = ParsePullPolicy("max-age=tomorrow")
+	assert.ErrorContains(t, err, "invalid max age")
+	_, _, err = ParsePullPolicy("sometimes")
+	assert.ErrorContains(t, err, "unsupported pull policy")
+}
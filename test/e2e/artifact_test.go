@@ -0,0 +1,97 @@
+package integration
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/containers/podman/v5/test/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Podman artifact", func() {
+
+	BeforeEach(func() {
+		SkipIfRemote("artifacts are not supported by the remote client")
+	})
+
+	It("podman artifact add, ls, inspect and rm", func() {
+		artifactFile := filepath.Join(podmanTest.TempDir, "foobar.txt")
+		err := os.WriteFile(artifactFile, []byte("artifact content"), 0o644)
+		Expect(err).ToNot(HaveOccurred())
+		artifactName := "localhost/test/artifact:latest"
+
+		add := podmanTest.Podman([]string{"artifact", "add", "--type", "application/vnd.test", artifactName, artifactFile})
+		add.WaitWithDefaultTimeout()
+		Expect(add).Should(ExitCleanly())
+		artifactDigest := add.OutputToString()
+
+		list := podmanTest.Podman([]string{"artifact", "ls", "--format", "{{.Repository}}:{{.Tag}} {{.Type}}"})
+		list.WaitWithDefaultTimeout()
+		Expect(list).Should(ExitCleanly())
+		Expect(list.OutputToString()).To(Equal(artifactName + " application/vnd.test"))
+
+		inspect := podmanTest.Podman([]string{"artifact", "inspect", "--format", "{{.Digest}}", artifactName})
+		inspect.WaitWithDefaultTimeout()
+		Expect(inspect).Should(ExitCleanly())
+		Expect(inspect.OutputToString()).To(Equal(artifactDigest))
+
+		add = podmanTest.Podman([]string{"artifact", "add", "shortname", artifactFile})
+		add.WaitWithDefaultTimeout()
+		Expect(add).Should(ExitWithError())
+		Expect(add.ErrorToString()).To(ContainSubstring("short name"))
+
+		rm := podmanTest.Podman([]string{"artifact", "rm", artifactName})
+		rm.WaitWithDefaultTimeout()
+		Expect(rm).Should(ExitCleanly())
+		Expect(rm.OutputToString()).To(Equal(artifactDigest))
+
+		inspect = podmanTest.Podman([]string{"artifact", "inspect", artifactName})
+		inspect.WaitWithDefaultTimeout()
+		Expect(inspect).Should(ExitWithError())
+		Expect(inspect.ErrorToString()).To(ContainSubstring("artifact does not exist"))
+	})
+
+	It("podman run --mount type=artifact", func() {
+		artifactFile := filepath.Join(podmanTest.TempDir, "foobar.txt")
+		err := os.WriteFile(artifactFile, []byte("artifact content"), 0o644)
+		Expect(err).ToNot(HaveOccurred())
+		artifactName := "localhost/test/mount:latest"
+
+		add := podmanTest.Podman([]string{"artifact", "add", artifactName, artifactFile})
+		add.WaitWithDefaultTimeout()
+		Expect(add).Should(ExitCleanly())
+
+		session := podmanTest.Podman([]string{"run", "--rm", "--mount", "type=artifact,src=" + artifactName + ",dst=/data", ALPINE, "cat", "/data/foobar.txt"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		Expect(session.OutputToString()).To(Equal("artifact content"))
+
+		session = podmanTest.Podman([]string{"run", "--rm", "--mount", "type=artifact,src=" + artifactName + ",dst=/data", ALPINE, "touch", "/data/foobar.txt"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitWithError())
+		Expect(session.ErrorToString()).To(ContainSubstring("Read-only file system"))
+
+		session = podmanTest.Podman([]string{"run", "--rm", "--mount", "type=artifact,src=localhost/test/missing,dst=/data", ALPINE, "true"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitWithError())
+		Expect(session.ErrorToString()).To(ContainSubstring("artifact does not exist"))
+
+		session = podmanTest.Podman([]string{"run", "-d", "--name", "artifact-top", "--mount", "type=artifact,src=" + artifactName + ",dst=/data", ALPINE, "top"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		rm := podmanTest.Podman([]string{"artifact", "rm", artifactName})
+		rm.WaitWithDefaultTimeout()
+		Expect(rm).Should(ExitWithError())
+		Expect(rm.ErrorToString()).To(ContainSubstring("artifact is in use"))
+
+		session = podmanTest.Podman([]string{"rm", "-f", "-t0", "artifact-top"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		rm = podmanTest.Podman([]string{"artifact", "rm", artifactName})
+		rm.WaitWithDefaultTimeout()
+		Expect(rm).Should(ExitCleanly())
+	})
+})
@@ -10,6 +10,7 @@ import (
 	. "github.com/containers/podman/v5/test/utils"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
 )
 
 var _ = Describe("Podman checkpoint", func() {
@@ -320,4 +321,57 @@ var _ = Describe("Podman checkpoint", func() {
 		Expect(result).Should(ExitCleanly())
 		Expect(podmanTest.NumberOfContainersRunning()).To(Equal(0))
 	})
+
+	It("podman checkpoint --push without --create-image", func() {
+		session := podmanTest.Podman([]string{"container", "checkpoint", "--push", "foobar"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).To(ExitWithError())
+		Expect(session.ErrorToString()).To(ContainSubstring("--push can only be used with --create-image"))
+	})
+
+	It("podman restore from checkpoint image in registry", func() {
+		if podmanTest.Host.Arch == "ppc64le" {
+			Skip("No registry image for ppc64le")
+		}
+		lock := GetPortLock("5013")
+		defer lock.Unlock()
+		session := podmanTest.Podman([]string{"run", "-d", "--name", "registry", "-p", "5013:5000", REGISTRY_IMAGE, "/entrypoint.sh", "/etc/docker/registry/config.yml"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		if !WaitContainerReady(podmanTest, "registry", "listening on", 20, 1) {
+			Skip("Cannot start docker registry.")
+		}
+
+		checkpointImage := "localhost:5013/alpine-checkpoint-" + strings.ToLower(RandomString(6))
+		containerName := "alpine-container-" + RandomString(6)
+		session = podmanTest.Podman([]string{"run", "-d", "--name", containerName, ALPINE, "top"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		result := podmanTest.Podman([]string{"container", "checkpoint", "--create-image", checkpointImage, "--push", "--tls-verify=false", containerName})
+		result.WaitWithDefaultTimeout()
+		Expect(result).Should(Exit(0))
+		Expect(result.ErrorToString()).To(ContainSubstring("Writing manifest to image destination"))
+
+		// Restore on a "new host": neither the container nor the image exist
+		result = podmanTest.Podman([]string{"rm", "-t", "0", "-f", containerName})
+		result.WaitWithDefaultTimeout()
+		Expect(result).Should(ExitCleanly())
+		result = podmanTest.Podman([]string{"rmi", checkpointImage})
+		result.WaitWithDefaultTimeout()
+		Expect(result).Should(ExitCleanly())
+
+		result = podmanTest.Podman([]string{"container", "restore", "--tls-verify=false", checkpointImage})
+		result.WaitWithDefaultTimeout()
+		Expect(result).Should(Exit(0))
+
+		status := podmanTest.Podman([]string{"inspect", containerName, "--format={{.State.Status}}"})
+		status.WaitWithDefaultTimeout()
+		Expect(status).Should(ExitCleanly())
+		Expect(status.OutputToString()).To(Equal("running"))
+
+		result = podmanTest.Podman([]string{"rm", "-t", "0", "-f", containerName})
+		result.WaitWithDefaultTimeout()
+		Expect(result).Should(ExitCleanly())
+	})
 })
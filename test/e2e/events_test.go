@@ -110,6 +110,29 @@ var _ = Describe("Podman events", func() {
 		Expect(result).Should(ExitCleanly())
 	})
 
+	It("podman events --since with days", func() {
+		_, ec, cid := podmanTest.RunLsContainer("")
+		Expect(ec).To(Equal(0))
+		result := podmanTest.Podman([]string{"events", "--stream=false", "--since", "3d", "--filter", "container=" + cid})
+		result.WaitWithDefaultTimeout()
+		Expect(result).Should(ExitCleanly())
+		Expect(result.OutputToStringArray()).ToNot(BeEmpty())
+	})
+
+	It("podman events with the sqlite backend", func() {
+		SkipIfRemote("the events backend of the remote server is set when it starts")
+		name := "sqlite" + stringid.GenerateRandomID()
+		session := podmanTest.Podman([]string{"--events-backend", "sqlite", "run", "--name", name, ALPINE, "true"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		result := podmanTest.Podman([]string{"--events-backend", "sqlite", "events", "--stream=false", "--since", "1d",
+			"--filter", "type=container", "--filter", "container=" + name, "--format", "{{.Status}} {{.Name}}"})
+		result.WaitWithDefaultTimeout()
+		Expect(result).Should(ExitCleanly())
+		Expect(result.OutputToStringArray()).To(ContainElements("create "+name, "start "+name))
+	})
+
 	It("podman events --until", func() {
 		_, ec, _ := podmanTest.RunLsContainer("")
 		Expect(ec).To(Equal(0))
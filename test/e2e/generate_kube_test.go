@@ -1313,6 +1313,40 @@ USER test1`
 		}
 	})
 
+	It("--volumes on container with named volume and memory reservation", func() {
+		vol := "used-named-volume"
+
+		session := podmanTest.Podman([]string{"volume", "create", vol})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		session = podmanTest.Podman([]string{"create", "--name", "top", "-v", vol + ":/data", "--memory-reservation", "64m", CITEST_IMAGE, "top"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		kube := podmanTest.Podman([]string{"kube", "generate", "--type", "deployment", "--volumes", "top"})
+		kube.WaitWithDefaultTimeout()
+		Expect(kube).Should(ExitCleanly())
+
+		// The PersistentVolumeClaim comes first and the Deployment last
+		docs := strings.Split(string(kube.Out.Contents()), "---")
+		Expect(len(docs)).To(BeNumerically(">=", 2))
+
+		pvc := new(v1.PersistentVolumeClaim)
+		err := yaml.Unmarshal([]byte(docs[0]), pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc).To(HaveField("Name", vol))
+		Expect(pvc.Spec.Resources.Requests.Storage().String()).To(Equal("1Gi"))
+
+		dep := new(v1.Deployment)
+		err = yaml.Unmarshal([]byte(docs[len(docs)-1]), dep)
+		Expect(err).ToNot(HaveOccurred())
+		containers := dep.Spec.Template.Spec.Containers
+		Expect(containers).To(HaveLen(1))
+		Expect(containers[0].Resources.Requests.Memory().String()).To(Equal("64Mi"))
+		Expect(dep.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal(vol))
+	})
+
 	It("on container with auto update labels", func() {
 		top := podmanTest.Podman([]string{"run", "-dt", "--name", "top", "--label", "io.containers.autoupdate=local", CITEST_IMAGE, "top"})
 		top.WaitWithDefaultTimeout()
@@ -371,6 +371,42 @@ var _ = Describe("Podman generate systemd", func() {
 		Expect(session.OutputToString()).To(ContainSubstring(" pod create "))
 	})
 
+	It("podman generate systemd --new pod --exit-code-propagation", func() {
+		n := podmanTest.Podman([]string{"pod", "create", "--name", "foo", "--exit-code-propagation", "all"})
+		n.WaitWithDefaultTimeout()
+		Expect(n).Should(ExitCleanly())
+
+		inspect := podmanTest.Podman([]string{"pod", "inspect", "foo", "--format", "{{.ExitCodePropagation}}"})
+		inspect.WaitWithDefaultTimeout()
+		Expect(inspect).Should(ExitCleanly())
+		Expect(inspect.OutputToString()).To(Equal("all"))
+
+		session := podmanTest.Podman([]string{"generate", "systemd", "--name", "--new", "--exit-code-propagation", "any", "--restart-steps", "5", "--restart-max-delay-sec", "300", "foo"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(Exit(0))
+		Expect(session.OutputToString()).To(ContainSubstring("--exit-code-propagation=any"))
+		Expect(session.OutputToString()).ToNot(ContainSubstring("--exit-code-propagation=all"))
+		Expect(session.OutputToString()).To(ContainSubstring("RestartSteps=5"))
+		Expect(session.OutputToString()).To(ContainSubstring("RestartMaxDelaySec=300"))
+
+		session = podmanTest.Podman([]string{"generate", "systemd", "--exit-code-propagation", "any", "foo"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitWithError())
+		Expect(session.ErrorToString()).To(ContainSubstring("--exit-code-propagation requires --new"))
+	})
+
+	It("podman generate systemd --new --sdnotify container", func() {
+		n := podmanTest.Podman([]string{"create", "--name", "foo", ALPINE, "top"})
+		n.WaitWithDefaultTimeout()
+		Expect(n).Should(ExitCleanly())
+
+		session := podmanTest.Podman([]string{"generate", "systemd", "--new", "--sdnotify", "container", "foo"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(Exit(0))
+		Expect(session.OutputToString()).To(ContainSubstring("--sdnotify=container"))
+		Expect(session.OutputToString()).ToNot(ContainSubstring("--sdnotify=conmon"))
+	})
+
 	It("podman generate systemd --restart-sec 15 --name foo", func() {
 		n := podmanTest.Podman([]string{"pod", "create", "--name", "foo"})
 		n.WaitWithDefaultTimeout()
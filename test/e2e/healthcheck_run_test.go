@@ -340,6 +340,8 @@ HEALTHCHECK CMD ls -l / 2>&1`, ALPINE)
 
 		inspect := podmanTest.InspectContainer(ctrName)
 		Expect(inspect[0].State.Health).To(HaveField("Status", "starting"))
+		Expect(inspect[0].Config.StartupHealthCheck).To(HaveField("Test", []string{"CMD-SHELL", "cat /test"}))
+		Expect(inspect[0].State.StartupHealthCheckPassed).To(BeFalse())
 
 		hc := podmanTest.Podman([]string{"healthcheck", "run", ctrName})
 		hc.WaitWithDefaultTimeout()
@@ -355,6 +357,7 @@ HEALTHCHECK CMD ls -l / 2>&1`, ALPINE)
 
 		inspect = podmanTest.InspectContainer(ctrName)
 		Expect(inspect[0].State.Health).To(HaveField("Status", define.HealthCheckHealthy))
+		Expect(inspect[0].State.StartupHealthCheckPassed).To(BeTrue())
 
 		hc = podmanTest.Podman([]string{"healthcheck", "run", ctrName})
 		hc.WaitWithDefaultTimeout()
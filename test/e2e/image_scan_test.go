@@ -0,0 +1,78 @@
+package integration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/containers/podman/v5/test/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Podman image scan", func() {
+
+	BeforeEach(func() {
+		SkipIfRemote("podman image scan is not supported for remote clients")
+	})
+
+	// useScanner configures a scanner reporting a finding of the
+	// specified severity for every image.
+	useScanner := func(severity, blockSeverity string) {
+		scanner := filepath.Join(podmanTest.TempDir, "scanner")
+		script := fmt.Sprintf(`#!/bin/sh
+grep -q '"rootfs":"/' || exit 1
+echo '{"scanner":"test","findings":[{"id":"CVE-0000-0001","severity":"%s","package":"busybox"}]}'
+`, severity)
+		err := os.WriteFile(scanner, []byte(script), 0755)
+		Expect(err).ToNot(HaveOccurred())
+
+		conffile := filepath.Join(podmanTest.TempDir, "containers.conf")
+		conf := fmt.Sprintf("[engine]\nimage_scanner = [%q]\nimage_scan_block_severity = %q\n", scanner, blockSeverity)
+		err = os.WriteFile(conffile, []byte(conf), 0644)
+		Expect(err).ToNot(HaveOccurred())
+		os.Setenv("CONTAINERS_CONF_OVERRIDE", conffile)
+	}
+
+	It("podman image scan without scanner", func() {
+		session := podmanTest.Podman([]string{"image", "scan", ALPINE})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitWithError())
+		Expect(session.ErrorToString()).To(ContainSubstring("no image scanner configured"))
+	})
+
+	It("podman image scan reports findings", func() {
+		useScanner("medium", "")
+
+		session := podmanTest.Podman([]string{"image", "scan", "--format", "{{.ID}} {{.Severity}} {{.Package}}", ALPINE})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		Expect(session.OutputToString()).To(Equal("CVE-0000-0001 medium busybox"))
+
+		session = podmanTest.Podman([]string{"image", "scan", "--fail-on", "medium", ALPINE})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitWithError())
+		Expect(session.ErrorToString()).To(ContainSubstring("findings of severity medium or higher"))
+
+		session = podmanTest.Podman([]string{"image", "scan", "--fail-on", "high", ALPINE})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+	})
+
+	It("podman run blocked by image scan", func() {
+		useScanner("critical", "high")
+
+		session := podmanTest.Podman([]string{"run", "--rm", ALPINE, "true"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitWithError())
+		Expect(session.ErrorToString()).To(ContainSubstring("image blocked by scan policy"))
+	})
+
+	It("podman run allowed by image scan", func() {
+		useScanner("low", "high")
+
+		session := podmanTest.Podman([]string{"run", "--rm", ALPINE, "true"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+	})
+})
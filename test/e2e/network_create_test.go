@@ -2,7 +2,10 @@ package integration
 
 import (
 	"encoding/json"
+	"fmt"
 	"net"
+	"os"
+	"path/filepath"
 
 	"github.com/containers/common/libnetwork/types"
 	"github.com/containers/podman/v5/pkg/domain/entities"
@@ -392,6 +395,47 @@ var _ = Describe("Podman network create", func() {
 		Expect(containerIP.To4()).To(Not(BeNil()))
 	})
 
+	It("podman network create with IPv4 disabled (ipv6 only)", func() {
+		netName := "ipv6only-" + stringid.GenerateRandomID()
+		nc := podmanTest.Podman([]string{"network", "create", "--ipv6", "--ipv4=false", netName})
+		nc.WaitWithDefaultTimeout()
+		defer podmanTest.removeNetwork(netName)
+		Expect(nc).Should(ExitCleanly())
+
+		inspect := podmanTest.Podman([]string{"network", "inspect", netName})
+		inspect.WaitWithDefaultTimeout()
+		Expect(inspect).Should(ExitCleanly())
+
+		var results []entities.NetworkInspectReport
+		err := json.Unmarshal([]byte(inspect.OutputToString()), &results)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(1))
+		result := results[0]
+		Expect(result).To(HaveField("IPv6Enabled", true))
+		Expect(result.Subnets).To(HaveLen(1))
+		Expect(result.Subnets[0].Subnet.IP.To4()).To(BeNil())
+
+		defer removeNetworkDevice(result.NetworkInterface)
+
+		try := podmanTest.Podman([]string{"run", "--rm", "--network", netName, ALPINE, "sh", "-c", "ip addr show eth0 | awk ' /inet / {print $2}'"})
+		try.WaitWithDefaultTimeout()
+		Expect(try).To(ExitCleanly())
+		Expect(try.OutputToString()).To(BeEmpty())
+
+		// an ipv4 subnet cannot be used when ipv4 is disabled
+		netName2 := "ipv6only-" + stringid.GenerateRandomID()
+		nc = podmanTest.Podman([]string{"network", "create", "--ipv4=false", "--subnet", "10.11.12.0/24", netName2})
+		nc.WaitWithDefaultTimeout()
+		defer podmanTest.removeNetwork(netName2)
+		Expect(nc).Should(ExitWithError())
+		Expect(nc.ErrorToString()).To(ContainSubstring("cannot use IPv4 subnet 10.11.12.0/24 when IPv4 is disabled"))
+
+		nc = podmanTest.Podman([]string{"network", "create", "--ipv4=false", "-d", "macvlan", netName2})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).Should(ExitWithError())
+		Expect(nc.ErrorToString()).To(ContainSubstring("IPv4 can only be disabled for bridge networks"))
+	})
+
 	It("podman network create with invalid subnet", func() {
 		nc := podmanTest.Podman([]string{"network", "create", "--subnet", "10.11.12.0/17000", stringid.GenerateRandomID()})
 		nc.WaitWithDefaultTimeout()
@@ -510,6 +554,30 @@ var _ = Describe("Podman network create", func() {
 		Expect(nc.OutputToString()).To(ContainSubstring(`"vlan": "9"`))
 	})
 
+	It("podman Netavark network create with bandwidth options", func() {
+		SkipIfCNI(podmanTest)
+		net := "bandwidth-test" + stringid.GenerateRandomID()
+		nc := podmanTest.Podman([]string{"network", "create", "--opt", "egress-bandwidth=10mbit", "--opt", "ingress-bandwidth=1gbit", net})
+		nc.WaitWithDefaultTimeout()
+		defer podmanTest.removeNetwork(net)
+		Expect(nc).Should(ExitCleanly())
+
+		nc = podmanTest.Podman([]string{"network", "inspect", "--format", "{{index .Options \"egress-bandwidth\"}} {{index .Options \"ingress-bandwidth\"}}", net})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).Should(ExitCleanly())
+		Expect(nc.OutputToString()).To(Equal("10mbit 1gbit"))
+
+		nc = podmanTest.Podman([]string{"network", "create", "--opt", "egress-bandwidth=fast", net + "-invalid"})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).To(ExitWithError())
+		Expect(nc.ErrorToString()).To(ContainSubstring(`invalid bandwidth "fast"`))
+
+		session := podmanTest.Podman([]string{"run", "--rm", "--network", net + ":egress-bandwidth=0", ALPINE, "true"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).To(ExitWithError())
+		Expect(session.ErrorToString()).To(ContainSubstring(`invalid bandwidth "0"`))
+	})
+
 	It("podman network create with invalid option", func() {
 		net := "invalid-test" + stringid.GenerateRandomID()
 		nc := podmanTest.Podman([]string{"network", "create", "--opt", "foo=bar", net})
@@ -555,6 +623,106 @@ var _ = Describe("Podman network create", func() {
 		Expect(nc.OutputToString()).To(ContainSubstring(`"dns_enabled": true`))
 	})
 
+	It("podman Netavark network create with wireguard driver", func() {
+		SkipIfCNI(podmanTest)
+		peer := "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="
+		net := "wg-test" + stringid.GenerateRandomID()
+		nc := podmanTest.Podman([]string{"network", "create", "--driver", "wireguard", "--subnet", "10.89.240.0/24",
+			"--opt", "peers=" + peer + "@192.0.2.1:51820;10.89.241.0/24", "--opt", "listen_port=51820", net})
+		nc.WaitWithDefaultTimeout()
+		defer podmanTest.removeNetwork(net)
+		Expect(nc).Should(ExitCleanly())
+
+		nc = podmanTest.Podman([]string{"network", "inspect", "--format", "{{.DNSEnabled}} {{.Options.private_key_secret}} {{len .Options.public_key}}", net})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).Should(ExitCleanly())
+		Expect(nc.OutputToString()).To(Equal(fmt.Sprintf("false %s-wireguard-key 44", net)))
+
+		nc = podmanTest.Podman([]string{"secret", "inspect", "--format", "{{.Spec.Labels}}", net + "-wireguard-key"})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).Should(ExitCleanly())
+		Expect(nc.OutputToString()).To(ContainSubstring("io.podman.network.wireguard:" + net))
+
+		nc = podmanTest.Podman([]string{"network", "rm", net})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).Should(ExitCleanly())
+
+		// the key is kept so the network can be created again with the same identity
+		nc = podmanTest.Podman([]string{"network", "create", "--driver", "wireguard", "--subnet", "10.89.240.0/24",
+			"--opt", "peers=" + peer, "--opt", "private_key_secret=" + net + "-wireguard-key", net})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).Should(ExitCleanly())
+
+		nc = podmanTest.Podman([]string{"network", "inspect", "--format", "{{.Options.public_key}}", net})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).Should(ExitCleanly())
+		publicKey := nc.OutputToString()
+
+		nc = podmanTest.Podman([]string{"network", "rm", net})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).Should(ExitCleanly())
+
+		// without the option, the generated key is used again
+		nc = podmanTest.Podman([]string{"network", "create", "--driver", "wireguard", "--subnet", "10.89.240.0/24",
+			"--opt", "peers=" + peer, net})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).Should(ExitCleanly())
+
+		nc = podmanTest.Podman([]string{"network", "inspect", "--format", "{{.Options.private_key_secret}} {{.Options.public_key}}", net})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).Should(ExitCleanly())
+		Expect(nc.OutputToString()).To(Equal(net + "-wireguard-key " + publicKey))
+
+		nc = podmanTest.Podman([]string{"network", "rm", net})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).Should(ExitCleanly())
+
+		// a secret of that name not generated for the network is not used
+		nc = podmanTest.Podman([]string{"secret", "rm", net + "-wireguard-key"})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).Should(ExitCleanly())
+		keyFile := filepath.Join(podmanTest.TempDir, "wireguard-key")
+		err := os.WriteFile(keyFile, []byte(peer), 0o600)
+		Expect(err).ToNot(HaveOccurred())
+		nc = podmanTest.Podman([]string{"secret", "create", net + "-wireguard-key", keyFile})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).Should(ExitCleanly())
+
+		nc = podmanTest.Podman([]string{"network", "create", "--driver", "wireguard", "--subnet", "10.89.240.0/24",
+			"--opt", "peers=" + peer, net})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).To(ExitWithError())
+		Expect(nc.ErrorToString()).To(ContainSubstring("does not hold a generated key of network " + net))
+
+		nc = podmanTest.Podman([]string{"secret", "rm", net + "-wireguard-key"})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).Should(ExitCleanly())
+	})
+
+	It("podman Netavark network create with wireguard driver and invalid options", func() {
+		SkipIfCNI(podmanTest)
+		net := "wg-test" + stringid.GenerateRandomID()
+		nc := podmanTest.Podman([]string{"network", "create", "--driver", "wireguard", "--subnet", "10.89.242.0/24", net})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).To(ExitWithError())
+		Expect(nc.ErrorToString()).To(ContainSubstring("no wireguard peers given"))
+
+		nc = podmanTest.Podman([]string{"network", "create", "--driver", "wireguard", "--subnet", "10.89.242.0/24", "--opt", "peers=foo@192.0.2.1:51820", net})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).To(ExitWithError())
+		Expect(nc.ErrorToString()).To(ContainSubstring(`invalid wireguard key "foo"`))
+
+		// no key must be left behind when the network cannot be created
+		nc = podmanTest.Podman([]string{"network", "create", "--driver", "wireguard", "--opt", "peers=xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=", net})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).To(ExitWithError())
+		Expect(nc.ErrorToString()).To(ContainSubstring("wireguard driver needs at least one subnet specified"))
+
+		nc = podmanTest.Podman([]string{"secret", "exists", net + "-wireguard-key"})
+		nc.WaitWithDefaultTimeout()
+		Expect(nc).To(ExitWithError())
+	})
+
 	It("podman network create with invalid name", func() {
 		for _, name := range []string{"none", "host", "bridge", "private", "slirp4netns", "pasta", "container", "ns", "default"} {
 			nc := podmanTest.Podman([]string{"network", "create", name})
@@ -5416,6 +5416,21 @@ cgroups="disabled"`), 0644)
 		Expect(kube).Should(ExitCleanly())
 	})
 
+	It("podman kube play --watch-configs requires --wait and a file", func() {
+		err := writeYaml(simplePodYaml, kubeYaml)
+		Expect(err).ToNot(HaveOccurred())
+
+		kube := podmanTest.Podman([]string{"kube", "play", "--watch-configs", kubeYaml})
+		kube.WaitWithDefaultTimeout()
+		Expect(kube).Should(ExitWithError())
+		Expect(kube.ErrorToString()).To(ContainSubstring("--watch-configs may be specified only with --wait"))
+
+		kube = podmanTest.Podman([]string{"kube", "play", "--wait", "--watch-configs", "-"})
+		kube.WaitWithDefaultTimeout()
+		Expect(kube).Should(ExitWithError())
+		Expect(kube.ErrorToString()).To(ContainSubstring("--watch-configs requires the YAML to be read from a file"))
+	})
+
 	It("podman kube --quiet with error", func() {
 		SkipIfNotRootless("We need to create an error trying to bind to port 80")
 		yaml := `
@@ -42,6 +42,29 @@ var _ = Describe("Podman pod start", func() {
 		Expect(session).Should(ExitCleanly())
 	})
 
+	It("podman pod start respects dependency conditions", func() {
+		_, ec, _ := podmanTest.CreatePod(map[string][]string{"--name": {"migratepod"}})
+		Expect(ec).To(Equal(0))
+
+		session := podmanTest.Podman([]string{"create", "--pod", "migratepod", "--name", "migrate", ALPINE, "sleep", "2"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		session = podmanTest.Podman([]string{"create", "--pod", "migratepod", "--name", "app", "--requires", "migrate:exited-successfully", ALPINE, "top"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		session = podmanTest.Podman([]string{"pod", "start", "migratepod"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		inspect := podmanTest.InspectContainer("migrate")
+		Expect(inspect[0].State.Status).To(Equal("exited"))
+		Expect(inspect[0].State.ExitCode).To(BeNumerically("==", 0))
+		inspect = podmanTest.InspectContainer("app")
+		Expect(inspect[0].State.Running).To(BeTrue())
+	})
+
 	It("podman pod start multiple pods", func() {
 		_, ec, podid1 := podmanTest.CreatePod(map[string][]string{"--name": {"foobar99"}})
 		Expect(ec).To(Equal(0))
@@ -2,9 +2,12 @@ package integration
 
 import (
 	"fmt"
+	"net"
 	"strings"
+	"time"
 
 	. "github.com/containers/podman/v5/test/utils"
+	"github.com/containers/podman/v5/utils"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -127,4 +130,85 @@ var _ = Describe("Podman port", func() {
 		Expect(result2).Should(ExitCleanly())
 		Expect(result2.OutputToStringArray()).To(ContainElement(HavePrefix("0.0.0.0:5011")))
 	})
+
+	It("podman container port add and remove on a running container", func() {
+		SkipIfCNI(podmanTest)
+		port1, err := utils.GetRandomPort()
+		Expect(err).ShouldNot(HaveOccurred())
+		port2, err := utils.GetRandomPort()
+		Expect(err).ShouldNot(HaveOccurred())
+
+		session := podmanTest.Podman([]string{"run", "-d", "--name", "portupdate", "-p", fmt.Sprintf("%d:6379", port1), REDIS_IMAGE})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		cid := session.OutputToString()
+		if !WaitContainerReady(podmanTest, cid, "Ready to accept connections", 20, 1) {
+			Fail("Container failed to get ready")
+		}
+
+		add := podmanTest.Podman([]string{"container", "port", "add", "portupdate", fmt.Sprintf("%d:6379", port2), "5300/udp"})
+		add.WaitWithDefaultTimeout()
+		Expect(add).Should(ExitCleanly())
+		Expect(add.OutputToStringArray()).To(ContainElement(fmt.Sprintf("6379/tcp -> 0.0.0.0:%d", port1)))
+		Expect(add.OutputToStringArray()).To(ContainElement(fmt.Sprintf("6379/tcp -> 0.0.0.0:%d", port2)))
+		Expect(add.OutputToStringArray()).To(ContainElement(HavePrefix("5300/udp -> 0.0.0.0:")))
+
+		// the container keeps running and is reachable via the new port
+		Expect(podmanTest.NumberOfContainersRunning()).To(Equal(1))
+		conn, err := net.DialTimeout("tcp4", fmt.Sprintf("localhost:%d", port2), 3*time.Second)
+		Expect(err).ShouldNot(HaveOccurred())
+		conn.Close()
+
+		remove := podmanTest.Podman([]string{"container", "port", "remove", "portupdate", fmt.Sprintf("%d:6379", port1), "5300/udp"})
+		remove.WaitWithDefaultTimeout()
+		Expect(remove).Should(ExitCleanly())
+		Expect(remove.OutputToStringArray()).To(Equal([]string{fmt.Sprintf("6379/tcp -> 0.0.0.0:%d", port2)}))
+
+		_, err = net.DialTimeout("tcp4", fmt.Sprintf("localhost:%d", port1), 3*time.Second)
+		Expect(err).To(HaveOccurred())
+		conn, err = net.DialTimeout("tcp4", fmt.Sprintf("localhost:%d", port2), 3*time.Second)
+		Expect(err).ShouldNot(HaveOccurred())
+		conn.Close()
+
+		// the change is stored in the container config
+		inspect := podmanTest.Podman([]string{"container", "inspect", "--format", "{{range $k, $v := .HostConfig.PortBindings}}{{$k}}={{range $v}}{{.HostPort}}{{end}} {{end}}", "portupdate"})
+		inspect.WaitWithDefaultTimeout()
+		Expect(inspect).Should(ExitCleanly())
+		Expect(inspect.OutputToString()).To(Equal(fmt.Sprintf("6379/tcp=%d", port2)))
+	})
+
+	It("podman port add and remove errors", func() {
+		session := podmanTest.Podman([]string{"create", "--name", "portupdate", "-p", "5012:5002", ALPINE, "top"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		remove := podmanTest.Podman([]string{"port", "remove", "portupdate", "5013:5002"})
+		remove.WaitWithDefaultTimeout()
+		Expect(remove).Should(ExitWithError())
+		Expect(remove.ErrorToString()).To(ContainSubstring("container port 5002/tcp is not published"))
+
+		add := podmanTest.Podman([]string{"port", "add", "portupdate", "5012:5003"})
+		add.WaitWithDefaultTimeout()
+		Expect(add).Should(ExitWithError())
+		Expect(add.ErrorToString()).To(ContainSubstring("conflicting port mappings for host port 5012"))
+
+		add = podmanTest.Podman([]string{"port", "add", "portupdate", "notaport"})
+		add.WaitWithDefaultTimeout()
+		Expect(add).Should(ExitWithError())
+
+		session = podmanTest.Podman([]string{"create", "--name", "hostnet", "--network", "host", ALPINE, "top"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		add = podmanTest.Podman([]string{"port", "add", "hostnet", "5014:5004"})
+		add.WaitWithDefaultTimeout()
+		Expect(add).Should(ExitWithError())
+		Expect(add.ErrorToString()).To(ContainSubstring("cannot change published ports of container"))
+
+		// the failed changes are not stored
+		result := podmanTest.Podman([]string{"port", "remove", "portupdate", "5002"})
+		result.WaitWithDefaultTimeout()
+		Expect(result).Should(ExitCleanly())
+		Expect(result.OutputToString()).To(BeEmpty())
+	})
 })
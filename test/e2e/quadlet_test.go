@@ -43,15 +43,24 @@ func loadQuadletTestcase(path string) *quadletTestcase {
 	base := filepath.Base(path)
 	ext := filepath.Ext(base)
 	service := base[:len(base)-len(ext)]
+	suffix := ""
 	switch ext {
 	case ".volume":
-		service += "-volume"
+		suffix = "-volume"
 	case ".network":
-		service += "-network"
+		suffix = "-network"
 	case ".image":
-		service += "-image"
+		suffix = "-image"
+	case ".build":
+		suffix = "-build"
 	case ".pod":
-		service += "-pod"
+		suffix = "-pod"
+	}
+	// The suffix of template units goes before the "@"
+	if prefix, instance, found := strings.Cut(service, "@"); found {
+		service = prefix + suffix + "@" + instance
+	} else {
+		service += suffix
 	}
 	service += ".service"
 
@@ -804,6 +813,7 @@ BOGUS=foo
 		Entry("notify-healthy.container", "notify-healthy.container", 0, ""),
 		Entry("oneshot.container", "oneshot.container", 0, ""),
 		Entry("other-sections.container", "other-sections.container", 0, ""),
+		Entry("build.non-quadlet.container", "build.non-quadlet.container", 1, "converting \"build.non-quadlet.container\": requested Quadlet build missing.build was not found"),
 		Entry("pod.non-quadlet.container", "pod.non-quadlet.container", 1, "converting \"pod.non-quadlet.container\": pod test-pod is not Quadlet based"),
 		Entry("pod.not-found.container", "pod.not-found.container", 1, "converting \"pod.not-found.container\": quadlet pod unit not-found.pod does not exist"),
 		Entry("podmanargs.container", "podmanargs.container", 0, ""),
@@ -846,6 +856,7 @@ BOGUS=foo
 		Entry("merged-override.container", "merged-override.container", 0, ""),
 		Entry("template@.container", "template@.container", 0, ""),
 		Entry("template@instance.container", "template@instance.container", 0, ""),
+		Entry("template.quadlet@.container", "template.quadlet@.container", 0, ""),
 
 		Entry("basic.volume", "basic.volume", 0, ""),
 		Entry("device-copy.volume", "device-copy.volume", 0, ""),
@@ -855,6 +866,7 @@ BOGUS=foo
 		Entry("podmanargs.volume", "podmanargs.volume", 0, ""),
 		Entry("uid.volume", "uid.volume", 0, ""),
 		Entry("image.volume", "image.volume", 0, ""),
+		Entry("template@.volume", "template@.volume", 0, ""),
 		Entry("image-no-image.volume", "image-no-image.volume", 1, "converting \"image-no-image.volume\": the key Image is mandatory when using the image driver"),
 		Entry("Volume - global args", "globalargs.volume", 0, ""),
 		Entry("Volume - Containers Conf Modules", "containersconfmodule.volume", 0, ""),
@@ -905,6 +917,7 @@ BOGUS=foo
 		Entry("Network - subnet, gateway and range", "subnet-trio.network", 0, ""),
 		Entry("Network - global args", "globalargs.network", 0, ""),
 		Entry("Network - Containers Conf Modules", "containersconfmodule.network", 0, ""),
+		Entry("Network - Template", "template@.network", 0, ""),
 
 		Entry("Image - Basic", "basic.image", 0, ""),
 		Entry("Image - No Image", "no-image.image", 1, "converting \"no-image.image\": no Image key specified"),
@@ -921,7 +934,15 @@ BOGUS=foo
 		Entry("Image - global args", "globalargs.image", 0, ""),
 		Entry("Image - Containers Conf Modules", "containersconfmodule.image", 0, ""),
 
+		Entry("Build - Basic", "basic.build", 0, ""),
+		Entry("Build - Options", "options.build", 0, ""),
+		Entry("Build - Remote File", "url.build", 0, ""),
+		Entry("Build - Watch Context", "watch.build", 0, ""),
+		Entry("Build - No ImageTag", "no-tag.build", 1, "converting \"no-tag.build\": no ImageTag key specified"),
+		Entry("Build - No Context", "no-context.build", 1, "converting \"no-context.build\": neither SetWorkingDirectory, nor File key specified"),
+
 		Entry("basic.pod", "basic.pod", 0, ""),
+		Entry("exitcodepropagation.pod", "exitcodepropagation.pod", 0, ""),
 		Entry("name.pod", "name.pod", 0, ""),
 		Entry("network.pod", "network.pod", 0, ""),
 		Entry("network-quadlet.pod", "network.quadlet.pod", 0, ""),
@@ -7,6 +7,7 @@ import (
 	. "github.com/containers/podman/v5/test/utils"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
 )
 
 var _ = Describe("Podman run networking", func() {
@@ -289,4 +290,52 @@ var _ = Describe("Podman run networking", func() {
 		digShort("cone", "testB1_nw", cipAB1, podmanTest)
 	})
 
+	It("Aardvark Test 7: Shared alias resolves to healthy containers", func() {
+		netName := createNetworkName("Test")
+		session := podmanTest.Podman([]string{"network", "create", netName})
+		session.WaitWithDefaultTimeout()
+		defer podmanTest.removeNetwork(netName)
+		Expect(session).Should(ExitCleanly())
+
+		var ips []string
+		for _, name := range []string{"webone", "webtwo"} {
+			ctr := podmanTest.Podman([]string{"run", "-dt", "--name", name, "--network", netName, "--network-alias", "web",
+				"--health-cmd", "test ! -e /unhealthy", "--health-retries", "1", "--health-interval", "disable", NGINX_IMAGE})
+			ctr.WaitWithDefaultTimeout()
+			Expect(ctr).Should(ExitCleanly())
+
+			ctrIP := podmanTest.Podman([]string{"inspect", "--format", fmt.Sprintf(`{{.NetworkSettings.Networks.%s.IPAddress}}`, netName), name})
+			ctrIP.WaitWithDefaultTimeout()
+			Expect(ctrIP).Should(ExitCleanly())
+			ips = append(ips, ctrIP.OutputToString())
+		}
+
+		digWeb := func() []string {
+			dig := podmanTest.Podman([]string{"exec", "webone", "dig", "+short", "web"})
+			dig.WaitWithDefaultTimeout()
+			Expect(dig).Should(ExitCleanly())
+			return dig.OutputToStringArray()
+		}
+		Expect(digWeb()).To(ConsistOf(ips))
+
+		session = podmanTest.Podman([]string{"exec", "webone", "touch", "/unhealthy"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		hc := podmanTest.Podman([]string{"healthcheck", "run", "webone"})
+		hc.WaitWithDefaultTimeout()
+		Expect(hc).Should(Exit(1))
+		Expect(digWeb()).To(ConsistOf(ips[1]))
+
+		// the container name still resolves
+		digShort("webtwo", "webone", ips[0], podmanTest)
+
+		session = podmanTest.Podman([]string{"exec", "webone", "rm", "/unhealthy"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		hc = podmanTest.Podman([]string{"healthcheck", "run", "webone"})
+		hc.WaitWithDefaultTimeout()
+		Expect(hc).Should(ExitCleanly())
+		Expect(digWeb()).To(ConsistOf(ips))
+	})
+
 })
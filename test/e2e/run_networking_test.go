@@ -501,6 +501,31 @@ EXPOSE 2004-2005/tcp`, ALPINE)
 		Expect(ncBusy).To(ExitWithError())
 	})
 
+	It("podman run network expose host port on IPv4 and IPv6", func() {
+		SkipIfRootless("port reservation is not supported for rootless users")
+		l, err := net.Listen("tcp6", "[::]:0")
+		if err != nil {
+			Skip("host does not support IPv6")
+		}
+		l.Close()
+
+		netName := "dual-" + stringid.GenerateRandomID()
+		session := podmanTest.Podman([]string{"network", "create", "--ipv6", netName})
+		session.WaitWithDefaultTimeout()
+		defer podmanTest.removeNetwork(netName)
+		Expect(session).Should(ExitCleanly())
+
+		port := GetPort()
+		session = podmanTest.Podman([]string{"run", "-d", "--network", netName, "-p", fmt.Sprintf("%d:80", port), ALPINE, "top"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		_, err = net.Listen("tcp4", fmt.Sprintf("0.0.0.0:%d", port))
+		Expect(err).To(HaveOccurred())
+		_, err = net.Listen("tcp6", fmt.Sprintf("[::]:%d", port))
+		Expect(err).To(HaveOccurred())
+	})
+
 	It("podman run network expose host port 18081 to container port 8000 using rootlesskit port handler", func() {
 		port1 := GetPort()
 		port2 := GetPort()
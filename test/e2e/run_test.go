@@ -2101,6 +2101,42 @@ WORKDIR /madethis`, BB)
 		Expect(running.OutputToStringArray()).To(HaveLen(2))
 	})
 
+	It("podman run --requires with conditions", func() {
+		migrate := podmanTest.Podman([]string{"create", "--name", "migrate", ALPINE, "sh", "-c", "sleep 2; touch /tmp/done"})
+		migrate.WaitWithDefaultTimeout()
+		Expect(migrate).Should(ExitCleanly())
+
+		app := podmanTest.Podman([]string{"run", "--name", "app", "--requires", "migrate:exited-successfully", "-d", ALPINE, "top"})
+		app.WaitWithDefaultTimeout()
+		Expect(app).Should(ExitCleanly())
+
+		// the migration must have finished before the app started
+		inspect := podmanTest.InspectContainer("migrate")
+		Expect(inspect[0].State.Status).To(Equal("exited"))
+		Expect(inspect[0].State.ExitCode).To(BeNumerically("==", 0))
+		inspect = podmanTest.InspectContainer("app")
+		Expect(inspect[0].DependencyConditions).To(HaveKeyWithValue(migrate.OutputToString(), "exited-successfully"))
+
+		failing := podmanTest.Podman([]string{"create", "--name", "failing", ALPINE, "false"})
+		failing.WaitWithDefaultTimeout()
+		Expect(failing).Should(ExitCleanly())
+
+		app = podmanTest.Podman([]string{"run", "--requires", "failing:exited-successfully", "-d", ALPINE, "top"})
+		app.WaitWithDefaultTimeout()
+		Expect(app).Should(ExitWithError())
+		Expect(app.ErrorToString()).To(ContainSubstring("did not reach their condition"))
+
+		app = podmanTest.Podman([]string{"create", "--requires", "migrate:healthy", ALPINE, "top"})
+		app.WaitWithDefaultTimeout()
+		Expect(app).Should(ExitWithError())
+		Expect(app.ErrorToString()).To(ContainSubstring("has no healthcheck"))
+
+		app = podmanTest.Podman([]string{"create", "--requires", "migrate:bogus", ALPINE, "top"})
+		app.WaitWithDefaultTimeout()
+		Expect(app).Should(ExitWithError())
+		Expect(app.ErrorToString()).To(ContainSubstring(`invalid dependency condition "bogus"`))
+	})
+
 	It("podman run with pidfile", func() {
 		SkipIfRemote("pidfile not handled by remote")
 		pidfile := tempdir + "pidfile"
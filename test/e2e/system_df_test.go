@@ -99,6 +99,19 @@ var _ = Describe("podman system df", func() {
 		Expect(session.ErrorToString()).To(Equal("Error: cannot combine --format and --verbose flags"))
 	})
 
+	It("podman system df --verbose shows volume size limit", func() {
+		SkipIfRemote("tmpfs volume options need a local volume")
+		session := podmanTest.Podman([]string{"volume", "create", "--opt", "type=tmpfs", "--opt", "device=tmpfs", "--opt", "o=size=2m", "limitvol"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		session = podmanTest.Podman([]string{"system", "df", "--verbose"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		Expect(session.OutputToString()).To(ContainSubstring("SIZE LIMIT"))
+		Expect(session.OutputToStringArray()).To(ContainElement(MatchRegexp(`^limitvol\s+0\s+\S+\s+2MB$`)))
+	})
+
 	It("podman system df --format json", func() {
 		session := podmanTest.Podman([]string{"create", ALPINE})
 		session.WaitWithDefaultTimeout()
@@ -96,4 +96,16 @@ var _ = Describe("Podman trust", Ordered, func() {
 		Expect(session.OutputToString()).To(BeValidJSON())
 		Expect(string(session.Out.Contents())).To(Equal(string(contents) + "\n"))
 	})
+
+	It("podman image trust verify", func() {
+		session := podmanTest.Podman([]string{"image", "trust", "verify", "--format", "{{.TrustStatus.Reference}} {{.TrustStatus.Verified}}", ALPINE})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		Expect(session.OutputToString()).To(Equal(ALPINE + " false"))
+
+		session = podmanTest.Podman([]string{"image", "inspect", "--format", "{{.TrustStatus}}", ALPINE})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		Expect(session.OutputToString()).To(Equal("accepted without verification"))
+	})
 })
@@ -1,6 +1,10 @@
 package integration
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/containers/common/pkg/cgroupv2"
 	. "github.com/containers/podman/v5/test/utils"
 	. "github.com/onsi/ginkgo/v2"
@@ -218,4 +222,113 @@ var _ = Describe("Podman update", func() {
 		Expect(session).Should(ExitCleanly())
 		Expect(session.OutputToString()).Should(ContainSubstring("500000"))
 	})
+
+	It("podman update --health-on-failure", func() {
+		session := podmanTest.Podman([]string{"create", "--health-cmd", "true", ALPINE, "top"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		ctrID := session.OutputToString()
+
+		session = podmanTest.Podman([]string{"update", "--health-on-failure", "kill", ctrID})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		session = podmanTest.Podman([]string{"inspect", "--format", "{{.Config.HealthcheckOnFailureAction}}", ctrID})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		Expect(session.OutputToString()).To(Equal("kill"))
+
+		session = podmanTest.Podman([]string{"update", "--health-on-failure", "bogus", ctrID})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitWithError())
+		Expect(session.ErrorToString()).To(ContainSubstring(`invalid on-failure action "bogus"`))
+
+		session = podmanTest.Podman([]string{"create", ALPINE, "top"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		session = podmanTest.Podman([]string{"update", "--health-on-failure", "stop", session.OutputToString()})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitWithError())
+		Expect(session.ErrorToString()).To(ContainSubstring("has no healthcheck"))
+	})
+
+	It("podman update keeps limits across restarts", func() {
+		SkipIfCgroupV1("testing flags that only work in cgroup v2")
+		SkipIfRootless("many of these handlers are not enabled while rootless in CI")
+		session := podmanTest.Podman([]string{"run", "-d", ALPINE, "top"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		ctrID := session.OutputToString()
+
+		session = podmanTest.Podman([]string{"update", "--pids-limit", "123", "--memory", "512m", ctrID})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		session = podmanTest.Podman([]string{"inspect", "--format", "{{.HostConfig.PidsLimit}}", ctrID})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		Expect(session.OutputToString()).Should(Equal("123"))
+
+		session = podmanTest.Podman([]string{"restart", ctrID})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		session = podmanTest.Podman([]string{"exec", ctrID, "cat", "/sys/fs/cgroup/pids.max", "/sys/fs/cgroup/memory.max"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		Expect(session.OutputToStringArray()).Should(Equal([]string{"123", "536870912"}))
+
+		// a stopped container gets the new limits on its next start
+		session = podmanTest.Podman([]string{"stop", ctrID})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		session = podmanTest.Podman([]string{"update", "--pids-limit", "456", ctrID})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		session = podmanTest.Podman([]string{"start", ctrID})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		session = podmanTest.Podman([]string{"exec", ctrID, "cat", "/sys/fs/cgroup/pids.max", "/sys/fs/cgroup/memory.max"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+		Expect(session.OutputToStringArray()).Should(Equal([]string{"456", "536870912"}))
+	})
+
+	It("podman pod update", func() {
+		SkipIfCgroupV1("testing flags that only work in cgroup v2")
+		SkipIfRootless("many of these handlers are not enabled while rootless in CI")
+		podName := "limitedpod"
+		session := podmanTest.Podman([]string{"pod", "create", "--memory", "512m", "--pids-limit", "100", "--name", podName})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		session = podmanTest.Podman([]string{"run", "-d", "--pod", podName, ALPINE, "top"})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		session = podmanTest.Podman([]string{"pod", "update", "--pids-limit", "200", podName})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitCleanly())
+
+		podInspect := podmanTest.Podman([]string{"pod", "inspect", podName})
+		podInspect.WaitWithDefaultTimeout()
+		Expect(podInspect).Should(ExitCleanly())
+		podJSON := podInspect.InspectPodToJSON()
+		Expect(podJSON).To(HaveField("PidsLimit", int64(200)))
+		// limits that are not updated are kept
+		Expect(podJSON).To(HaveField("MemoryLimit", uint64(536870912)))
+
+		pidsMax, err := os.ReadFile(filepath.Join("/sys/fs/cgroup", podJSON.CgroupPath, "pids.max"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.TrimSpace(string(pidsMax))).To(Equal("200"))
+
+		session = podmanTest.Podman([]string{"pod", "update", podName})
+		session.WaitWithDefaultTimeout()
+		Expect(session).Should(ExitWithError())
+		Expect(session.ErrorToString()).To(ContainSubstring("at least one of --cpus, --memory, --memory-swap or --pids-limit must be set"))
+	})
 })
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package buildah
+
+func runningInUserNS() bool {
+	return false
+}
@@ -0,0 +1,9 @@
+package buildah
+
+import (
+	"github.com/opencontainers/runc/libcontainer/userns"
+)
+
+func runningInUserNS() bool {
+	return userns.RunningInUserNS()
+}
@@ -0,0 +1,15 @@
+package define
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobsLimit(t *testing.T) {
+	jobs := func(n int) *int { return &n }
+	assert.Equal(t, 1, JobsLimit(nil))
+	assert.Equal(t, -1, JobsLimit(jobs(0)))
+	assert.Equal(t, 1, JobsLimit(jobs(1)))
+	assert.Equal(t, 4, JobsLimit(jobs(4)))
+}
@@ -0,0 +1,92 @@
+package buildah
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pendingBlobs(n int) []*layerBlob {
+	blobs := make([]*layerBlob, n)
+	for i := range blobs {
+		blobs[i] = &layerBlob{pending: true}
+	}
+	return blobs
+}
+
+func TestForEachPendingBlob(t *testing.T) {
+	for _, jobs := range []int{0, 1, 3} {
+		blobs := pendingBlobs(6)
+		// already written
+		blobs[2].pending = false
+		blobs[2].blobSum = digest.FromString("kept")
+
+		var running, maxRunning atomic.Int32
+		err := forEachPendingBlob(context.Background(), &jobs, blobs, func(_ context.Context, index int, blob *layerBlob) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			// the first blobs finish last
+			time.Sleep(time.Duration(len(blobs)-index) * 5 * time.Millisecond)
+			blob.blobSum = digest.FromString(string(rune('a'+index)))
+			return nil
+		})
+		require.NoError(t, err)
+
+		// each blob is filled in by the call for its index
+		for index, blob := range blobs {
+			if index == 2 {
+				assert.Equal(t, digest.FromString("kept"), blob.blobSum)
+				continue
+			}
+			assert.Equal(t, digest.FromString(string(rune('a'+index))), blob.blobSum)
+		}
+		switch jobs {
+		case 0:
+			assert.Equal(t, int32(5), maxRunning.Load(), "no limit")
+		default:
+			assert.Equal(t, int32(jobs), maxRunning.Load())
+		}
+	}
+
+	// without jobs, the blobs are written one at a time
+	var running atomic.Int32
+	require.NoError(t, forEachPendingBlob(context.Background(), nil, pendingBlobs(3), func(context.Context, int, *layerBlob) error {
+		assert.Equal(t, int32(1), running.Add(1))
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		return nil
+	}))
+}
+
+func TestForEachPendingBlobError(t *testing.T) {
+	jobs := 0
+	errBroken := errors.New("broken layer")
+	var canceled atomic.Int32
+	err := forEachPendingBlob(context.Background(), &jobs, pendingBlobs(4), func(ctx context.Context, index int, _ *layerBlob) error {
+		if index == 1 {
+			return errBroken
+		}
+		// the other blobs are canceled rather than written
+		select {
+		case <-ctx.Done():
+			canceled.Add(1)
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+			return nil
+		}
+	})
+	assert.ErrorIs(t, err, errBroken)
+	assert.Equal(t, int32(3), canceled.Load())
+}
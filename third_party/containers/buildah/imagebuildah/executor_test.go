@@ -0,0 +1,81 @@
+package imagebuildah
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/semaphore"
+)
+
+func TestCommitJob(t *testing.T) {
+	b := &Executor{
+		stagesSemaphore: semaphore.NewWeighted(1),
+		commitSemaphore: semaphore.NewWeighted(1),
+	}
+	ctx := context.Background()
+	// the stage job of the stage that commits
+	require.NoError(t, b.stagesSemaphore.Acquire(ctx, 1))
+
+	committing := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- b.commitJob(ctx, func() error {
+			close(committing)
+			<-release
+			return nil
+		})
+	}()
+	<-committing
+
+	// another stage runs while the first one commits, but its commit
+	// waits for the first one
+	require.True(t, b.stagesSemaphore.TryAcquire(1))
+	assert.False(t, b.commitSemaphore.TryAcquire(1))
+	b.stagesSemaphore.Release(1)
+
+	close(release)
+	require.NoError(t, <-done)
+	// the committing stage holds its stage job again
+	assert.False(t, b.stagesSemaphore.TryAcquire(1))
+	assert.True(t, b.commitSemaphore.TryAcquire(1))
+	b.commitSemaphore.Release(1)
+}
+
+func TestCommitJobError(t *testing.T) {
+	b := &Executor{
+		stagesSemaphore: semaphore.NewWeighted(2),
+		commitSemaphore: semaphore.NewWeighted(1),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, b.stagesSemaphore.Acquire(ctx, 1))
+
+	errCommit := errors.New("commit failed")
+	assert.ErrorIs(t, b.commitJob(ctx, func() error { return errCommit }), errCommit)
+
+	// a canceled build does not wait for a commit job, and the jobs are
+	// left as they were
+	require.NoError(t, b.commitSemaphore.Acquire(ctx, 1))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err := b.commitJob(ctx, func() error {
+		t.Error("committed without a commit job")
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	b.commitSemaphore.Release(1)
+	assert.True(t, b.stagesSemaphore.TryAcquire(1))
+	assert.False(t, b.stagesSemaphore.TryAcquire(1))
+
+	// without a limit, commits do not wait
+	b = &Executor{stagesSemaphore: semaphore.NewWeighted(1)}
+	require.NoError(t, b.stagesSemaphore.Acquire(context.Background(), 1))
+	assert.NoError(t, b.commitJob(context.Background(), func() error { return nil }))
+}
@@ -0,0 +1,6 @@
+package mkcw
+
+import _ "embed"
+
+//go:embed "embed/entrypoint_amd64.gz"
+var entrypointCompressedBytes []byte
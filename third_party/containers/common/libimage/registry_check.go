@@ -0,0 +1,27 @@
+package libimage
+
+import "context"
+
+// RegistryCheckReporter is called with the name of an image pulled from a
+// container registry whenever the registry was reached for it.
+type RegistryCheckReporter func(name string)
+
+type registryCheckReporterKey struct{}
+
+// WithRegistryCheckReporter returns a copy of ctx that makes pulls from
+// container registries using it report to reporter when a registry was
+// reached: the image was pulled, or, with the "newer" pull policy, the
+// registry has no newer image than the local one.  Pulls with the "newer"
+// pull policy that fall back to the local image because no registry could be
+// reached do not report.
+func WithRegistryCheckReporter(ctx context.Context, reporter RegistryCheckReporter) context.Context {
+	return context.WithValue(ctx, registryCheckReporterKey{}, reporter)
+}
+
+// reportRegistryCheck calls the reporter set with WithRegistryCheckReporter,
+// if any.
+func reportRegistryCheck(ctx context.Context, name string) {
+	if reporter, ok := ctx.Value(registryCheckReporterKey{}).(RegistryCheckReporter); ok {
+		reporter(name)
+	}
+}
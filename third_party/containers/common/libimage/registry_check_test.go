@@ -0,0 +1,95 @@
+package libimage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"testing"
+
+	"github.com/containers/common/pkg/config"
+	"github.com/containers/image/v5/types"
+	"github.com/containers/storage"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryCheckReporter(t *testing.T) {
+	imageConfig := []byte(fmt.Sprintf(`{"architecture":%q,"os":%q,"rootfs":{"type":"layers","diff_ids":[]}}`, goruntime.GOARCH, goruntime.GOOS))
+	configDigest := digest.FromBytes(imageConfig)
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"config":{"mediaType":%q,"digest":%q,"size":%d},"layers":[]}`,
+		imgspecv1.MediaTypeImageManifest, imgspecv1.MediaTypeImageConfig, configDigest, len(imageConfig)))
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/repo/manifests/latest":
+			w.Header().Set("Content-Type", imgspecv1.MediaTypeImageManifest)
+			_, _ = w.Write(manifest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer registry.Close()
+	host := strings.TrimPrefix(registry.URL, "http://")
+
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "registries.conf")
+	require.NoError(t, os.WriteFile(conf, []byte(fmt.Sprintf("[[registry]]\nlocation = %q\ninsecure = true\n", host)), 0o644))
+	policy := filepath.Join(dir, "policy.json")
+	require.NoError(t, os.WriteFile(policy, []byte(`{"default":[{"type":"insecureAcceptAnything"}]}`), 0o644))
+	store, err := storage.GetStore(storage.StoreOptions{
+		RunRoot:         filepath.Join(dir, "run"),
+		GraphRoot:       filepath.Join(dir, "root"),
+		GraphDriverName: "vfs",
+	})
+	require.NoError(t, err)
+	defer func() {
+		_, _ = store.Shutdown(true)
+	}()
+	runtime, err := RuntimeFromStore(store, &RuntimeOptions{SystemContext: &types.SystemContext{
+		SystemRegistriesConfPath:    conf,
+		SystemRegistriesConfDirPath: filepath.Join(dir, "registries.conf.d"),
+		AuthFilePath:                filepath.Join(dir, "auth.json"),
+		SignaturePolicyPath:         policy,
+	}})
+	require.NoError(t, err)
+
+	// the local image has the manifest the registry serves
+	name := host + "/repo:latest"
+	_, err = store.CreateImage(configDigest.Encoded(), []string{name}, "", "", &storage.ImageOptions{
+		Digest: digest.FromBytes(manifest),
+		BigData: []storage.ImageBigDataOption{
+			{Key: storage.ImageDigestBigDataKey, Data: manifest, Digest: digest.FromBytes(manifest)},
+			{Key: configDigest.String(), Data: imageConfig, Digest: configDigest},
+		},
+	})
+	require.NoError(t, err)
+
+	var checked []string
+	ctx := WithRegistryCheckReporter(context.Background(), func(name string) {
+		checked = append(checked, name)
+	})
+	images, err := runtime.Pull(ctx, name, config.PullPolicyNewer, nil)
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+	assert.Equal(t, []string{name}, checked)
+
+	// an unreachable registry falls back to the local image
+	checked = nil
+	registry.Close()
+	images, err = runtime.Pull(ctx, name, config.PullPolicyNewer, nil)
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+	assert.Empty(t, checked)
+
+	// without a reporter, nothing is reported
+	_, err = runtime.Pull(context.Background(), name, config.PullPolicyNewer, nil)
+	require.NoError(t, err)
+}
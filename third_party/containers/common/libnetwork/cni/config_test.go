@@ -0,0 +1,39 @@
+//go:build (linux || freebsd) && cni
+
+package cni
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/common/libnetwork/types"
+	"github.com/containers/common/libnetwork/util"
+	"github.com/containers/common/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateNetworkDisableIPv4(t *testing.T) {
+	dir := t.TempDir()
+	n, err := NewCNINetworkInterface(&InitConfig{
+		CNIConfigDir: filepath.Join(dir, "net.d"),
+		RunDir:       filepath.Join(dir, "run"),
+		Config:       &config.Config{},
+	})
+	require.NoError(t, err)
+	disable := &types.NetworkCreateOptions{DisableIPv4: true}
+
+	network, err := n.NetworkCreate(types.Network{Driver: types.BridgeNetworkDriver}, disable)
+	require.NoError(t, err)
+	assert.True(t, network.IPv6Enabled)
+	require.NotEmpty(t, network.Subnets)
+	for _, subnet := range network.Subnets {
+		assert.False(t, util.IsIPv4(subnet.Subnet.IP), subnet.Subnet.String())
+	}
+
+	_, err = n.NetworkCreate(types.Network{Driver: types.MacVLANNetworkDriver}, disable)
+	assert.ErrorIs(t, err, types.ErrInvalidArg)
+
+	// the aliases of running containers cannot be changed with CNI
+	assert.ErrorIs(t, n.UpdateContainerAliases(network.Name, "abc", []string{"www"}), types.ErrInvalidArg)
+}
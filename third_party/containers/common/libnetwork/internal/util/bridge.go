@@ -0,0 +1,84 @@
+package util
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/containers/common/libnetwork/types"
+	"github.com/containers/common/libnetwork/util"
+	"github.com/containers/common/pkg/config"
+	"golang.org/x/exp/slices"
+)
+
+// CreateBridge fills the missing fields of a bridge network. When disableIPv4
+// is set the network gets only IPv6 subnets.
+func CreateBridge(n NetUtil, network *types.Network, usedNetworks []*net.IPNet, subnetPools []config.SubnetPool, disableIPv4 bool) error {
+	if network.NetworkInterface != "" {
+		bridges := GetBridgeInterfaceNames(n)
+		if slices.Contains(bridges, network.NetworkInterface) {
+			return fmt.Errorf("bridge name %s already in use", network.NetworkInterface)
+		}
+		if !types.NameRegex.MatchString(network.NetworkInterface) {
+			return fmt.Errorf("bridge name %s invalid: %w", network.NetworkInterface, types.RegexError)
+		}
+	} else {
+		var err error
+		network.NetworkInterface, err = GetFreeDeviceName(n)
+		if err != nil {
+			return err
+		}
+	}
+
+	ipamDriver := network.IPAMOptions[types.Driver]
+	// also do this when the driver is unset
+	if ipamDriver == "" || ipamDriver == types.HostLocalIPAMDriver {
+		if disableIPv4 {
+			for _, subnet := range network.Subnets {
+				if util.IsIPv4(subnet.Subnet.IP) {
+					return fmt.Errorf("cannot use IPv4 subnet %s when IPv4 is disabled: %w", subnet.Subnet.String(), types.ErrInvalidArg)
+				}
+			}
+			// an ipv6 only network is always ipv6 enabled
+			network.IPv6Enabled = true
+		}
+		if len(network.Subnets) == 0 && !disableIPv4 {
+			freeSubnet, err := GetFreeIPv4NetworkSubnet(usedNetworks, subnetPools)
+			if err != nil {
+				return err
+			}
+			network.Subnets = append(network.Subnets, *freeSubnet)
+		}
+		// ipv6 enabled means dual stack, check if we already have
+		// a ipv4 or ipv6 subnet and add one if not.
+		if network.IPv6Enabled {
+			ipv4 := false
+			ipv6 := false
+			for _, subnet := range network.Subnets {
+				if util.IsIPv6(subnet.Subnet.IP) {
+					ipv6 = true
+				}
+				if util.IsIPv4(subnet.Subnet.IP) {
+					ipv4 = true
+				}
+			}
+			if !ipv4 && !disableIPv4 {
+				freeSubnet, err := GetFreeIPv4NetworkSubnet(usedNetworks, subnetPools)
+				if err != nil {
+					return err
+				}
+				network.Subnets = append(network.Subnets, *freeSubnet)
+			}
+			if !ipv6 {
+				freeSubnet, err := GetFreeIPv6NetworkSubnet(usedNetworks)
+				if err != nil {
+					return err
+				}
+				network.Subnets = append(network.Subnets, *freeSubnet)
+			}
+		}
+		network.IPAMOptions[types.Driver] = types.HostLocalIPAMDriver
+	} else if disableIPv4 {
+		return fmt.Errorf("cannot disable IPv4 with the %s ipam driver: %w", ipamDriver, types.ErrInvalidArg)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+//go:build linux || freebsd
+
+package netavark
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/common/libnetwork/types"
+	"github.com/containers/common/libnetwork/util"
+	"github.com/containers/common/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testWireGuardKey = "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="
+
+// newTestNetworkInterface returns a netavark interface with the config and
+// run directories in a new temporary directory.
+func newTestNetworkInterface(t *testing.T) (types.ContainerNetwork, string) {
+	dir := t.TempDir()
+	runDir := filepath.Join(dir, "run")
+	n, err := NewNetworkInterface(&InitConfig{
+		NetworkConfigDir: filepath.Join(dir, "networks"),
+		NetworkRunDir:    runDir,
+		Config:           &config.Config{},
+	})
+	require.NoError(t, err)
+	return n, runDir
+}
+
+func TestCreateWireGuardNetwork(t *testing.T) {
+	n, _ := newTestNetworkInterface(t)
+	subnet, err := types.ParseCIDR("10.89.10.0/24")
+	require.NoError(t, err)
+	options := func() map[string]string {
+		return map[string]string{
+			types.PeersOption:            testWireGuardKey + "@203.0.113.5:51820;10.89.11.0/24",
+			types.PrivateKeySecretOption: "wg-key",
+			types.ConfigOption:           "/etc/wireguard/wg0.conf",
+			types.ListenPortOption:       "51820",
+		}
+	}
+
+	network, err := n.NetworkCreate(types.Network{
+		Name:       "wg",
+		Driver:     types.WireGuardNetworkDriver,
+		DNSEnabled: true,
+		Subnets:    []types.Subnet{{Subnet: subnet}},
+		Options:    options(),
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, types.WireGuardNetworkDriver, network.Driver)
+	assert.False(t, network.DNSEnabled)
+	assert.Equal(t, types.HostLocalIPAMDriver, network.IPAMOptions[types.Driver])
+	assert.Contains(t, n.Drivers(), types.WireGuardNetworkDriver)
+
+	// options returns the valid options with changes applied, an empty
+	// value removes the option
+	withOptions := func(changes map[string]string) map[string]string {
+		o := options()
+		for key, value := range changes {
+			if value == "" {
+				delete(o, key)
+			} else {
+				o[key] = value
+			}
+		}
+		return o
+	}
+	subnets := []types.Subnet{{Subnet: subnet}}
+	for _, tc := range []struct {
+		network types.Network
+		err     string
+	}{
+		{types.Network{Options: options()}, "needs at least one subnet"},
+		{types.Network{Subnets: subnets, IPAMOptions: map[string]string{types.Driver: types.DHCPIPAMDriver}, Options: options()}, "ipam driver dhcp is not supported"},
+		{types.Network{Subnets: subnets, Options: withOptions(map[string]string{types.PeersOption: ""})}, "needs the peers option"},
+		{types.Network{Subnets: subnets, Options: withOptions(map[string]string{types.PeersOption: "invalid"})}, "invalid wireguard peer"},
+		{types.Network{Subnets: subnets, Options: withOptions(map[string]string{types.PublicKeyOption: "invalid"})}, "invalid wireguard key"},
+		{types.Network{Subnets: subnets, Options: withOptions(map[string]string{types.ListenPortOption: "70000"})}, "listen_port"},
+		{types.Network{Subnets: subnets, Options: withOptions(map[string]string{types.MTUOption: "big"})}, `parsing "big"`},
+		{types.Network{Subnets: subnets, Options: withOptions(map[string]string{"vlan": "5"})}, "unsupported wireguard network option vlan"},
+	} {
+		tc.network.Driver = types.WireGuardNetworkDriver
+		_, err := n.NetworkCreate(tc.network, nil)
+		assert.ErrorContains(t, err, tc.err)
+	}
+
+	_, err = n.NetworkCreate(types.Network{Driver: types.WireGuardNetworkDriver, Subnets: subnets, Options: options()}, &types.NetworkCreateOptions{DisableIPv4: true})
+	assert.ErrorContains(t, err, "IPv4 can only be disabled")
+}
+
+func TestCreateNetworkBandwidthOptions(t *testing.T) {
+	n, _ := newTestNetworkInterface(t)
+	network, err := n.NetworkCreate(types.Network{
+		Driver: types.BridgeNetworkDriver,
+		Options: map[string]string{
+			types.EgressBandwidthOption:  "10mbit",
+			types.IngressBandwidthOption: "1gbit",
+		},
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "10mbit", network.Options[types.EgressBandwidthOption])
+
+	for _, key := range []string{types.EgressBandwidthOption, types.IngressBandwidthOption} {
+		_, err := n.NetworkCreate(types.Network{
+			Driver:  types.BridgeNetworkDriver,
+			Options: map[string]string{key: "fast"},
+		}, nil)
+		assert.ErrorContains(t, err, "invalid bandwidth", key)
+	}
+	_, err = util.ParseBandwidth(network.Options[types.IngressBandwidthOption])
+	assert.NoError(t, err)
+}
+
+func TestCreateNetworkDisableIPv4(t *testing.T) {
+	n, _ := newTestNetworkInterface(t)
+	disable := &types.NetworkCreateOptions{DisableIPv4: true}
+
+	// without subnets, the network gets a free ipv6 subnet only
+	network, err := n.NetworkCreate(types.Network{Driver: types.BridgeNetworkDriver}, disable)
+	require.NoError(t, err)
+	assert.True(t, network.IPv6Enabled)
+	require.NotEmpty(t, network.Subnets)
+	for _, subnet := range network.Subnets {
+		assert.False(t, util.IsIPv4(subnet.Subnet.IP), subnet.Subnet.String())
+	}
+
+	ipv6, err := types.ParseCIDR("fd10:89::/64")
+	require.NoError(t, err)
+	network, err = n.NetworkCreate(types.Network{Driver: types.BridgeNetworkDriver, Subnets: []types.Subnet{{Subnet: ipv6}}}, disable)
+	require.NoError(t, err)
+	assert.Equal(t, []types.Subnet{{Subnet: ipv6, Gateway: network.Subnets[0].Gateway}}, network.Subnets)
+
+	ipv4, err := types.ParseCIDR("10.89.20.0/24")
+	require.NoError(t, err)
+	_, err = n.NetworkCreate(types.Network{Driver: types.BridgeNetworkDriver, Subnets: []types.Subnet{{Subnet: ipv4}}}, disable)
+	assert.ErrorContains(t, err, "cannot use IPv4 subnet 10.89.20.0/24")
+
+	_, err = n.NetworkCreate(types.Network{Driver: types.BridgeNetworkDriver, IPAMOptions: map[string]string{types.Driver: types.DHCPIPAMDriver}}, disable)
+	assert.ErrorContains(t, err, "cannot disable IPv4 with the dhcp ipam driver")
+
+	_, err = n.NetworkCreate(types.Network{Driver: types.MacVLANNetworkDriver}, disable)
+	assert.ErrorContains(t, err, "IPv4 can only be disabled for bridge networks")
+
+	// without the option, a dual stack network gets an ipv4 subnet added
+	ipv6, err = types.ParseCIDR("fd10:90::/64")
+	require.NoError(t, err)
+	network, err = n.NetworkCreate(types.Network{Driver: types.BridgeNetworkDriver, IPv6Enabled: true, Subnets: []types.Subnet{{Subnet: ipv6}}}, nil)
+	require.NoError(t, err)
+	assert.Len(t, network.Subnets, 2)
+}
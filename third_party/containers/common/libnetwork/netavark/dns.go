@@ -0,0 +1,95 @@
+//go:build linux || freebsd
+
+package netavark
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containers/common/libnetwork/types"
+	"github.com/containers/storage/pkg/ioutils"
+	"golang.org/x/exp/slices"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// aardvarkConfigDir is the directory below the network run dir where
+	// netavark writes the aardvark-dns config, one file per network.
+	aardvarkConfigDir = "aardvark-dns"
+	// aardvarkPidFile is the file in the aardvark config dir which contains
+	// the pid of the running aardvark-dns server.
+	aardvarkPidFile = "aardvark.pid"
+)
+
+// UpdateContainerAliases changes the dns aliases of a running container on the
+// given network. The record of the container name is kept so only the aliases
+// are added to or removed from the dns server.
+func (n *netavarkNetwork) UpdateContainerAliases(nameOrID, containerID string, aliases []string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	err := n.loadNetworks()
+	if err != nil {
+		return err
+	}
+	network, err := n.getNetwork(nameOrID)
+	if err != nil {
+		return err
+	}
+	if !network.DNSEnabled {
+		return fmt.Errorf("dns is not enabled for network %s: %w", network.Name, types.ErrInvalidArg)
+	}
+
+	dir := filepath.Join(n.networkRunDir, aardvarkConfigDir)
+	path := filepath.Join(dir, network.Name)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("container %s is not connected to network %s", containerID, network.Name)
+		}
+		return err
+	}
+
+	// The first line lists the addresses of the dns server, every other line
+	// describes a container as "ID IPV4S IPV6S NAMES [DNS_SERVERS]" where the
+	// container name is the first of the comma separated names.
+	lines := strings.Split(string(content), "\n")
+	found := false
+	for i, line := range lines[1:] {
+		fields := strings.Split(line, " ")
+		if len(fields) < 4 || fields[0] != containerID {
+			continue
+		}
+		found = true
+		names := strings.Split(fields[3], ",")
+		newNames := append([]string{names[0]}, aliases...)
+		if slices.Equal(names, newNames) {
+			return nil
+		}
+		fields[3] = strings.Join(newNames, ",")
+		lines[i+1] = strings.Join(fields, " ")
+	}
+	if !found {
+		return fmt.Errorf("container %s is not connected to network %s", containerID, network.Name)
+	}
+	if err := ioutils.AtomicWriteFile(path, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		return err
+	}
+
+	// signal aardvark-dns to reload its config
+	pid, err := os.ReadFile(filepath.Join(dir, aardvarkPidFile))
+	if err != nil {
+		return fmt.Errorf("reading aardvark-dns pid: %w", err)
+	}
+	p, err := strconv.Atoi(strings.TrimSpace(string(pid)))
+	if err != nil {
+		return fmt.Errorf("parsing aardvark-dns pid: %w", err)
+	}
+	if err := unix.Kill(p, unix.SIGHUP); err != nil {
+		return fmt.Errorf("reloading aardvark-dns: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+//go:build linux || freebsd
+
+package netavark
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/containers/common/libnetwork/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateContainerAliases(t *testing.T) {
+	n, runDir := newTestNetworkInterface(t)
+	network, err := n.NetworkCreate(types.Network{Driver: types.BridgeNetworkDriver, DNSEnabled: true}, nil)
+	require.NoError(t, err)
+	noDNS, err := n.NetworkCreate(types.Network{Driver: types.BridgeNetworkDriver}, nil)
+	require.NoError(t, err)
+
+	// the aardvark-dns config netavark writes for the network, and a
+	// process standing in for aardvark-dns to reload it
+	dir := filepath.Join(runDir, aardvarkConfigDir)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	configPath := filepath.Join(dir, network.Name)
+	require.NoError(t, os.WriteFile(configPath, []byte("10.89.0.1\nabc 10.89.0.2  web,old 8.8.8.8\ndef 10.89.0.3  db\n"), 0o644))
+	aardvark := exec.Command("sleep", "60")
+	require.NoError(t, aardvark.Start())
+	t.Cleanup(func() {
+		_ = aardvark.Process.Kill()
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, aardvarkPidFile), []byte(strconv.Itoa(aardvark.Process.Pid)), 0o644))
+
+	require.NoError(t, n.UpdateContainerAliases(network.Name, "abc", []string{"www", "frontend"}))
+	config, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "10.89.0.1\nabc 10.89.0.2  web,www,frontend 8.8.8.8\ndef 10.89.0.3  db\n", string(config))
+	err = aardvark.Wait()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, syscall.SIGHUP, exitErr.Sys().(syscall.WaitStatus).Signal())
+
+	// unchanged aliases do not signal the server, which is gone now; a
+	// change is written but reloading it fails
+	require.NoError(t, n.UpdateContainerAliases(network.ID, "abc", []string{"www", "frontend"}))
+	assert.ErrorContains(t, n.UpdateContainerAliases(network.ID, "abc", nil), "reloading aardvark-dns")
+	config, err = os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "10.89.0.1\nabc 10.89.0.2  web 8.8.8.8\ndef 10.89.0.3  db\n", string(config))
+
+	assert.ErrorContains(t, n.UpdateContainerAliases(network.Name, "xyz", nil), "container xyz is not connected")
+	assert.ErrorIs(t, n.UpdateContainerAliases(noDNS.Name, "abc", nil), types.ErrInvalidArg)
+	assert.ErrorIs(t, n.UpdateContainerAliases("missing", "abc", nil), types.ErrNoSuchNetwork)
+}
@@ -0,0 +1,40 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// bandwidthUnits maps the units of bandwidths understood by tc(8) to their
+// size in bits per second.
+var bandwidthUnits = []struct {
+	suffix string
+	bits   float64
+}{
+	// longest suffixes first so that "kbit" does not match "bit"
+	{"tbit", 1e12}, {"gbit", 1e9}, {"mbit", 1e6}, {"kbit", 1e3}, {"bit", 1},
+	{"tbps", 8e12}, {"gbps", 8e9}, {"mbps", 8e6}, {"kbps", 8e3}, {"bps", 8},
+}
+
+// ParseBandwidth parses a bandwidth in the format used by tc(8), e.g. "10mbit"
+// or "1gbps", and returns it in bytes per second. A bare number is a bandwidth
+// in bits per second.
+func ParseBandwidth(value string) (uint64, error) {
+	number, bits := strings.ToLower(value), 1.0
+	for _, unit := range bandwidthUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, bits = strings.TrimSuffix(number, unit.suffix), unit.bits
+			break
+		}
+	}
+	rate, err := strconv.ParseFloat(number, 64)
+	if err != nil || rate <= 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q: must be a positive number with an optional unit like kbit, mbit or gbit", value)
+	}
+	bytes := uint64(rate * bits / 8)
+	if bytes == 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q: must be at least 8bit", value)
+	}
+	return bytes, nil
+}
@@ -0,0 +1,31 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	for value, expected := range map[string]uint64{
+		"8":        1,
+		"800bit":   100,
+		"10kbit":   1250,
+		"10Mbit":   1250000,
+		"1gbit":    125000000,
+		"1.5mbit":  187500,
+		"100kbps":  100000,
+		"2MBPS":    2000000,
+		"1tbit":    125000000000,
+		"1000mbit": 125000000,
+	} {
+		bandwidth, err := ParseBandwidth(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, bandwidth, value)
+	}
+	for _, value := range []string{"", "fast", "0", "-10mbit", "10 mbit", "mbit", "4bit", "10mb"} {
+		_, err := ParseBandwidth(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// WireGuardKeyLen is the length of wireguard keys in bytes.
+const WireGuardKeyLen = 32
+
+// WireGuardPeer is a peer of a wireguard network.
+type WireGuardPeer struct {
+	// PublicKey is the base64 encoded public key of the peer.
+	PublicKey string
+	// Endpoint is the host:port the peer can be reached at. It is empty
+	// when the peer connects to us.
+	Endpoint string
+	// AllowedIPs are the subnets which are routed to the peer.
+	AllowedIPs []*net.IPNet
+}
+
+// ValidateWireGuardKey checks that key is a base64 encoded wireguard key.
+func ValidateWireGuardKey(key string) error {
+	data, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(data) != WireGuardKeyLen {
+		return fmt.Errorf("invalid wireguard key %q: must be %d base64 encoded bytes", key, WireGuardKeyLen)
+	}
+	return nil
+}
+
+// ParseWireGuardPeers parses the value of the peers option of a wireguard
+// network. The value is a comma separated list of peers in the form
+// PUBLICKEY[@HOST:PORT][;SUBNET...], e.g.
+// "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=@203.0.113.5:51820;10.89.1.0/24".
+func ParseWireGuardPeers(value string) ([]WireGuardPeer, error) {
+	if value == "" {
+		return nil, fmt.Errorf("no wireguard peers given")
+	}
+	var peers []WireGuardPeer
+	for _, spec := range strings.Split(value, ",") {
+		fields := strings.Split(spec, ";")
+		key, endpoint, hasEndpoint := strings.Cut(fields[0], "@")
+		if err := ValidateWireGuardKey(key); err != nil {
+			return nil, fmt.Errorf("invalid wireguard peer %q: %w", spec, err)
+		}
+		peer := WireGuardPeer{PublicKey: key}
+		if hasEndpoint {
+			_, port, err := net.SplitHostPort(endpoint)
+			if err != nil {
+				return nil, fmt.Errorf("invalid endpoint of wireguard peer %q: %w", spec, err)
+			}
+			if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+				return nil, fmt.Errorf("invalid endpoint port of wireguard peer %q: %w", spec, err)
+			}
+			peer.Endpoint = endpoint
+		}
+		for _, field := range fields[1:] {
+			_, subnet, err := net.ParseCIDR(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowed ip of wireguard peer %q: %w", spec, err)
+			}
+			peer.AllowedIPs = append(peer.AllowedIPs, subnet)
+		}
+		peers = append(peers, peer)
+	}
+	return peers, nil
+}
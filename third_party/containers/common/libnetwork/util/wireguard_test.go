@@ -0,0 +1,44 @@
+package util
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testWireGuardKey = "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="
+
+func TestValidateWireGuardKey(t *testing.T) {
+	assert.NoError(t, ValidateWireGuardKey(testWireGuardKey))
+	for _, key := range []string{"", "not base64!", "c2hvcnQ=", testWireGuardKey + testWireGuardKey} {
+		assert.Error(t, ValidateWireGuardKey(key), key)
+	}
+}
+
+func TestParseWireGuardPeers(t *testing.T) {
+	_, subnet1, err := net.ParseCIDR("10.89.1.0/24")
+	require.NoError(t, err)
+	_, subnet2, err := net.ParseCIDR("fd00::/64")
+	require.NoError(t, err)
+
+	peers, err := ParseWireGuardPeers(testWireGuardKey + "@203.0.113.5:51820;10.89.1.0/24;fd00::/64," + testWireGuardKey)
+	require.NoError(t, err)
+	assert.Equal(t, []WireGuardPeer{
+		{PublicKey: testWireGuardKey, Endpoint: "203.0.113.5:51820", AllowedIPs: []*net.IPNet{subnet1, subnet2}},
+		{PublicKey: testWireGuardKey},
+	}, peers)
+
+	for _, value := range []string{
+		"",
+		"invalid-key",
+		testWireGuardKey + "@203.0.113.5",
+		testWireGuardKey + "@203.0.113.5:70000",
+		testWireGuardKey + ";10.89.1.0",
+		testWireGuardKey + ",",
+	} {
+		_, err := ParseWireGuardPeers(value)
+		assert.Error(t, err, value)
+	}
+}
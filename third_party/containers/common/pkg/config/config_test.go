@@ -0,0 +1,32 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageScanConfig(t *testing.T) {
+	c, err := defaultConfig()
+	require.NoError(t, err)
+	assert.Empty(t, c.Engine.ImageScanner.Get())
+	assert.False(t, c.Engine.ImageScanOnPull)
+	assert.Empty(t, c.Engine.ImageScanBlockSeverity)
+	assert.Equal(t, "24h", c.Engine.ImageScanMaxAge)
+
+	path := filepath.Join(t.TempDir(), "containers.conf")
+	require.NoError(t, os.WriteFile(path, []byte(`[engine]
+image_scanner = ["trivy", "image", "--format", "json"]
+image_scan_on_pull = true
+image_scan_block_severity = "high"
+`), 0o644))
+	require.NoError(t, readConfigFromFile(path, c, false))
+	assert.Equal(t, []string{"trivy", "image", "--format", "json"}, c.Engine.ImageScanner.Get())
+	assert.True(t, c.Engine.ImageScanOnPull)
+	assert.Equal(t, "high", c.Engine.ImageScanBlockSeverity)
+	// the fields not in the file keep their defaults
+	assert.Equal(t, "24h", c.Engine.ImageScanMaxAge)
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package config
+
+func selinuxEnabled() bool {
+	return false
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !windows
+
+package sysinfo
+
+import "runtime"
+
+func numCPU() int {
+	return runtime.NumCPU()
+}
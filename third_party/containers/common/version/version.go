@@ -0,0 +1,4 @@
+package version
+
+// Version is the version of the build.
+const Version = "0.58.0-dev"
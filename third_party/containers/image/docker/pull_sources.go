@@ -0,0 +1,37 @@
+package docker
+
+import (
+	"context"
+	"time"
+
+	"github.com/containers/image/v5/pkg/sysregistriesv2"
+)
+
+// PullSourceHooks allow callers to influence and observe which pull sources
+// (i.e., the mirrors and the primary location of a registry) an image is
+// pulled from.  The hooks are only used for registries with mirrors.
+type PullSourceHooks struct {
+	// Order, if set, returns the pull sources to try, in order.  sources
+	// lists the mirrors of the registry followed by its primary location.
+	// If Order returns an empty slice, sources are used as is.
+	Order func(sources []sysregistriesv2.PullSource) []sysregistriesv2.PullSource
+	// Report, if set, is called with the outcome of each attempt to access
+	// a pull source and the time the attempt took.  mirror is false for the
+	// primary location of the registry.
+	Report func(source sysregistriesv2.PullSource, mirror bool, latency time.Duration, err error)
+}
+
+type pullSourceHooksKey struct{}
+
+// WithPullSourceHooks returns a copy of ctx that makes images pulled from
+// registries with mirrors use hooks.
+func WithPullSourceHooks(ctx context.Context, hooks *PullSourceHooks) context.Context {
+	return context.WithValue(ctx, pullSourceHooksKey{}, hooks)
+}
+
+// pullSourceHooksFromContext returns the hooks set with WithPullSourceHooks,
+// or nil.
+func pullSourceHooksFromContext(ctx context.Context) *PullSourceHooks {
+	hooks, _ := ctx.Value(pullSourceHooksKey{}).(*PullSourceHooks)
+	return hooks
+}
@@ -0,0 +1,171 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/sysregistriesv2"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRegistry is a plain HTTP registry serving the manifest of repo:latest,
+// or failing with manifestStatus, and failing the blob requests with
+// blobStatus.
+type testRegistry struct {
+	*httptest.Server
+	manifestStatus int
+	blobStatus     int
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	r := &testRegistry{manifestStatus: http.StatusOK, blobStatus: http.StatusOK}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case req.URL.Path == "/v2/repo/manifests/latest":
+			if r.manifestStatus != http.StatusOK {
+				w.WriteHeader(r.manifestStatus)
+				return
+			}
+			w.Header().Set("Content-Type", imgspecv1.MediaTypeImageManifest)
+			fmt.Fprintf(w, `{"schemaVersion":2,"mediaType":%q}`, imgspecv1.MediaTypeImageManifest)
+		case strings.HasPrefix(req.URL.Path, "/v2/repo/blobs/"):
+			// the client retries 429 by itself, without waiting here
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(r.blobStatus)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *testRegistry) host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+// mirroredSystemContext returns a SystemContext using only a registries.conf
+// which makes mirror a mirror of primary.
+func mirroredSystemContext(t *testing.T, primary, mirror *testRegistry) *types.SystemContext {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "registries.conf")
+	require.NoError(t, os.WriteFile(conf, []byte(fmt.Sprintf(`
+[[registry]]
+location = %q
+insecure = true
+
+[[registry.mirror]]
+location = %q
+insecure = true
+`, primary.host(), mirror.host())), 0o644))
+	return &types.SystemContext{
+		SystemRegistriesConfPath:    conf,
+		SystemRegistriesConfDirPath: filepath.Join(dir, "registries.conf.d"),
+		RegistriesDirPath:           filepath.Join(dir, "registries.d"),
+		AuthFilePath:                filepath.Join(dir, "auth.json"),
+	}
+}
+
+type pullSourceReport struct {
+	location string
+	mirror   bool
+	failed   bool
+}
+
+func TestPullSourceHooks(t *testing.T) {
+	primary, mirror := newTestRegistry(t), newTestRegistry(t)
+	mirror.manifestStatus = http.StatusNotFound
+	sys := mirroredSystemContext(t, primary, mirror)
+	sysregistriesv2.InvalidateCache()
+	ref, err := ParseReference("//" + primary.host() + "/repo:latest")
+	require.NoError(t, err)
+
+	var reports []pullSourceReport
+	hooks := &PullSourceHooks{
+		Report: func(source sysregistriesv2.PullSource, isMirror bool, latency time.Duration, err error) {
+			assert.GreaterOrEqual(t, latency, time.Duration(0))
+			reports = append(reports, pullSourceReport{source.Endpoint.Location, isMirror, err != nil})
+		},
+	}
+
+	// without an order, the mirror is tried first
+	src, err := newImageSource(WithPullSourceHooks(context.Background(), hooks), sys, ref.(dockerReference))
+	require.NoError(t, err)
+	src.Close()
+	assert.Equal(t, []pullSourceReport{
+		{mirror.host(), true, true},
+		{primary.host(), false, false},
+	}, reports)
+
+	// the order returned by the hook is used
+	reports = nil
+	hooks.Order = func(sources []sysregistriesv2.PullSource) []sysregistriesv2.PullSource {
+		require.Len(t, sources, 2)
+		return []sysregistriesv2.PullSource{sources[1], sources[0]}
+	}
+	src, err = newImageSource(WithPullSourceHooks(context.Background(), hooks), sys, ref.(dockerReference))
+	require.NoError(t, err)
+	src.Close()
+	assert.Equal(t, []pullSourceReport{{primary.host(), false, false}}, reports)
+
+	// an empty order keeps the configured one
+	reports = nil
+	hooks.Order = func([]sysregistriesv2.PullSource) []sysregistriesv2.PullSource { return nil }
+	src, err = newImageSource(WithPullSourceHooks(context.Background(), hooks), sys, ref.(dockerReference))
+	require.NoError(t, err)
+	src.Close()
+	assert.Len(t, reports, 2)
+}
+
+func TestGetBlobAtTransientErrors(t *testing.T) {
+	primary, mirror := newTestRegistry(t), newTestRegistry(t)
+	mirror.manifestStatus = http.StatusNotFound
+	sys := mirroredSystemContext(t, primary, mirror)
+	sysregistriesv2.InvalidateCache()
+	ref, err := ParseReference("//" + primary.host() + "/repo:latest")
+	require.NoError(t, err)
+	src, err := newImageSource(context.Background(), sys, ref.(dockerReference))
+	require.NoError(t, err)
+	defer src.Close()
+
+	info := types.BlobInfo{Digest: digest.FromString("blob")}
+	chunks := []private.ImageSourceChunk{{Offset: 0, Length: 10}}
+	for _, tc := range []struct {
+		status    int
+		transient bool
+	}{
+		{http.StatusRequestTimeout, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusGatewayTimeout, true},
+		{http.StatusUnauthorized, false},
+		{http.StatusNotFound, false},
+	} {
+		primary.blobStatus = tc.status
+		_, _, err := src.GetBlobAt(context.Background(), info, chunks)
+		require.Error(t, err, tc.status)
+		var transient private.TransientPartialRequestError
+		assert.Equal(t, tc.transient, errors.As(err, &transient), "status %d: %v", tc.status, err)
+	}
+
+	primary.blobStatus = http.StatusBadRequest
+	_, _, err = src.GetBlobAt(context.Background(), info, chunks)
+	var badRequest private.BadPartialRequestError
+	assert.ErrorAs(t, err, &badRequest)
+}
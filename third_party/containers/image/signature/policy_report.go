@@ -0,0 +1,51 @@
+package signature
+
+import (
+	"context"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/unparsedimage"
+	"github.com/containers/image/v5/types"
+)
+
+// AcceptedRequirementsReporter is called with the reference of an image and
+// the policy requirements which allowed running it whenever
+// PolicyContext.IsRunningImageAllowed accepts the image.
+type AcceptedRequirementsReporter func(ref types.ImageReference, reqs PolicyRequirements)
+
+type acceptedRequirementsReporterKey struct{}
+
+// WithAcceptedRequirementsReporter returns a copy of ctx that makes policy
+// evaluations using it report the requirements accepting images to reporter.
+func WithAcceptedRequirementsReporter(ctx context.Context, reporter AcceptedRequirementsReporter) context.Context {
+	return context.WithValue(ctx, acceptedRequirementsReporterKey{}, reporter)
+}
+
+// acceptedRequirementsReporterFromContext returns the reporter set with
+// WithAcceptedRequirementsReporter, or nil.
+func acceptedRequirementsReporterFromContext(ctx context.Context) AcceptedRequirementsReporter {
+	reporter, _ := ctx.Value(acceptedRequirementsReporterKey{}).(AcceptedRequirementsReporter)
+	return reporter
+}
+
+// referenceOverrideImage is an image evaluated as if it had been accessed
+// using ref.
+type referenceOverrideImage struct {
+	private.UnparsedImage
+	ref types.ImageReference
+}
+
+// Reference returns the reference the image is evaluated for.
+func (i *referenceOverrideImage) Reference() types.ImageReference {
+	return i.ref
+}
+
+// IsRunningImageAllowedAs is like IsRunningImageAllowed, but evaluates the
+// image as if it had been accessed using ref: the policy requirements for ref
+// apply, and signatures must match the identity of ref.  This allows
+// re-verifying images in local storage against the policy of the registry they
+// were pulled from.
+func (pc *PolicyContext) IsRunningImageAllowedAs(ctx context.Context, publicImage types.UnparsedImage, ref types.ImageReference) (bool, error) {
+	image := unparsedimage.FromPublic(publicImage)
+	return pc.IsRunningImageAllowed(ctx, &referenceOverrideImage{UnparsedImage: image, ref: ref})
+}
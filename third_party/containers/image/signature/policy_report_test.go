@@ -0,0 +1,79 @@
+package signature
+
+import (
+	"context"
+	"testing"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// referenceImage is an image which only has a reference, enough for the
+// requirements which do not check signatures.
+type referenceImage struct {
+	types.UnparsedImage
+	ref types.ImageReference
+}
+
+func (i referenceImage) Reference() types.ImageReference {
+	return i.ref
+}
+
+type acceptedReport struct {
+	ref  string
+	reqs PolicyRequirements
+}
+
+func TestAcceptedRequirementsReporter(t *testing.T) {
+	allowedRef, err := docker.ParseReference("//registry.example/allowed:latest")
+	require.NoError(t, err)
+	deniedRef, err := docker.ParseReference("//registry.example/denied:latest")
+	require.NoError(t, err)
+	accept := PolicyRequirements{NewPRInsecureAcceptAnything()}
+	pc, err := NewPolicyContext(&Policy{
+		Default: PolicyRequirements{NewPRReject()},
+		Transports: map[string]PolicyTransportScopes{
+			"docker": {"registry.example/allowed": accept},
+		},
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, pc.Destroy())
+	}()
+
+	var reports []acceptedReport
+	ctx := WithAcceptedRequirementsReporter(context.Background(), func(ref types.ImageReference, reqs PolicyRequirements) {
+		reports = append(reports, acceptedReport{ref.StringWithinTransport(), reqs})
+	})
+
+	allowed, err := pc.IsRunningImageAllowed(ctx, referenceImage{ref: allowedRef})
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, []acceptedReport{{allowedRef.StringWithinTransport(), accept}}, reports)
+
+	// rejected images are not reported
+	reports = nil
+	allowed, err = pc.IsRunningImageAllowed(ctx, referenceImage{ref: deniedRef})
+	assert.Error(t, err)
+	assert.False(t, allowed)
+	assert.Empty(t, reports)
+
+	// the policy of the reference the image is evaluated as applies
+	allowed, err = pc.IsRunningImageAllowedAs(ctx, referenceImage{ref: deniedRef}, allowedRef)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, []acceptedReport{{allowedRef.StringWithinTransport(), accept}}, reports)
+
+	reports = nil
+	allowed, err = pc.IsRunningImageAllowedAs(ctx, referenceImage{ref: allowedRef}, deniedRef)
+	assert.Error(t, err)
+	assert.False(t, allowed)
+	assert.Empty(t, reports)
+
+	// without a reporter, the evaluation is unchanged
+	allowed, err = pc.IsRunningImageAllowed(context.Background(), referenceImage{ref: allowedRef})
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
@@ -0,0 +1,47 @@
+//go:build !containers_image_storage_stub
+// +build !containers_image_storage_stub
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/types"
+	"github.com/containers/storage/pkg/chunked"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingChunkAccessor fails every GetBlobAt request with err.
+type failingChunkAccessor struct {
+	err    error
+	chunks []private.ImageSourceChunk
+}
+
+func (a *failingChunkAccessor) GetBlobAt(ctx context.Context, info types.BlobInfo, chunks []private.ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	a.chunks = chunks
+	return nil, nil, a.err
+}
+
+func TestZstdFetcherErrors(t *testing.T) {
+	cause := errors.New("503 Service Unavailable")
+	for _, tc := range []struct {
+		err      error
+		expected error
+	}{
+		{private.BadPartialRequestError{Status: "400 Bad Request"}, chunked.ErrBadRequest{}},
+		{private.TransientPartialRequestError{Err: cause}, chunked.ErrTransient{Err: cause}},
+		{cause, cause},
+	} {
+		accessor := &failingChunkAccessor{err: tc.err}
+		f := &zstdFetcher{chunkAccessor: accessor, ctx: context.Background()}
+		_, _, err := f.GetBlobAt([]chunked.ImageSourceChunk{{Offset: 10, Length: 5}})
+		assert.Equal(t, tc.expected, err)
+		require.Len(t, accessor.chunks, 1)
+		assert.Equal(t, private.ImageSourceChunk{Offset: 10, Length: 5}, accessor.chunks[0])
+	}
+}
@@ -0,0 +1,8 @@
+//go:build linux
+// +build linux
+
+package overlay
+
+import jsoniter "github.com/json-iterator/go"
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
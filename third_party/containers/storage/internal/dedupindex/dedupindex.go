@@ -0,0 +1,212 @@
+// Package dedupindex is a persistent index of the files and chunks of the
+// layers in a store, by digest, so that the differ can find a file in the
+// other layers with a single lookup instead of a search in the lookaside
+// cache of every layer.
+//
+// The layer store adds a layer to the index when it is created and removes it
+// when it is deleted.  Readers open the index read-only, with a shared lock,
+// only while they look up the digests they need, so that they do not block
+// the writers.
+package dedupindex
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	indexFile = "dedup-index.db"
+
+	// lockTimeout is how long to wait for another process using the
+	// index.
+	lockTimeout = time.Second
+)
+
+var (
+	// digestsBucket maps DIGEST\x00LAYER to the location $OFFSET@$PATH
+	// of the file or chunk with that digest in the layer.
+	digestsBucket = []byte("digests")
+	// targetsBucket maps the ID of every indexed layer to its checkout
+	// directory.
+	targetsBucket = []byte("targets")
+	// layerDigestsBucket holds a bucket for every indexed layer, with the
+	// digests it added to digestsBucket.
+	layerDigestsBucket = []byte("layer-digests")
+)
+
+// Location is where a file or chunk is found in a layer.
+type Location struct {
+	// Layer is the ID of the layer.
+	Layer string
+	// Target is the checkout directory of the layer.
+	Target string
+	// Path is the path of the file, relative to Target.
+	Path string
+	// Offset is the offset of the chunk in the file.
+	Offset int64
+}
+
+func indexPath(graphRoot string) string {
+	return filepath.Join(graphRoot, "cache", indexFile)
+}
+
+func digestKey(digest, layerID string) []byte {
+	return []byte(digest + "\x00" + layerID)
+}
+
+// AddLayer adds the files and chunks of the layer with the given checkout
+// directory to the index of the store with the given graph root.  locations
+// maps their digests to their locations $OFFSET@$PATH in the layer.
+func AddLayer(graphRoot, id, target string, locations map[string][]byte) error {
+	path := indexPath(graphRoot)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return fmt.Errorf("opening database %s: %w", path, err)
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{digestsBucket, targetsBucket, layerDigestsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		digests := tx.Bucket(digestsBucket)
+		layerDigests, err := tx.Bucket(layerDigestsBucket).CreateBucketIfNotExists([]byte(id))
+		if err != nil {
+			return err
+		}
+		for digest, location := range locations {
+			if err := digests.Put(digestKey(digest, id), location); err != nil {
+				return err
+			}
+			if err := layerDigests.Put([]byte(digest), nil); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(targetsBucket).Put([]byte(id), []byte(target))
+	})
+}
+
+// RemoveLayer removes the files and chunks of the layer from the index of the
+// store with the given graph root, if the store has an index.
+func RemoveLayer(graphRoot, id string) error {
+	path := indexPath(graphRoot)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return fmt.Errorf("opening database %s: %w", path, err)
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		layersBucket := tx.Bucket(layerDigestsBucket)
+		if layersBucket == nil {
+			return nil
+		}
+		digests := tx.Bucket(digestsBucket)
+		if layerDigests := layersBucket.Bucket([]byte(id)); layerDigests != nil {
+			err := layerDigests.ForEach(func(digest, _ []byte) error {
+				return digests.Delete(digestKey(string(digest), id))
+			})
+			if err != nil {
+				return err
+			}
+			if err := layersBucket.DeleteBucket([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(targetsBucket).Delete([]byte(id))
+	})
+}
+
+// Reader looks up digests in the index.
+type Reader struct {
+	db *bolt.DB
+}
+
+// OpenReader opens the index of the store with the given graph root
+// read-only.  It returns an error wrapping os.ErrNotExist if the store has no
+// index.  The reader holds a shared lock until it is closed.
+func OpenReader(graphRoot string) (*Reader, error) {
+	path := indexPath(graphRoot)
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: lockTimeout, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("opening database %s: %w", path, err)
+	}
+	return &Reader{db: db}, nil
+}
+
+// Close releases the lock on the index.
+func (r *Reader) Close() error {
+	return r.db.Close()
+}
+
+// Layers returns the IDs of the indexed layers.
+func (r *Reader) Layers() (map[string]struct{}, error) {
+	layers := make(map[string]struct{})
+	err := r.db.View(func(tx *bolt.Tx) error {
+		targets := tx.Bucket(targetsBucket)
+		if targets == nil {
+			return nil
+		}
+		return targets.ForEach(func(k, _ []byte) error {
+			layers[string(k)] = struct{}{}
+			return nil
+		})
+	})
+	return layers, err
+}
+
+// Lookup returns the locations of the files or chunks with the given
+// digests, in the layers for which use returns true.  The digests which are
+// not found are missing from the result.
+func (r *Reader) Lookup(digests []string, use func(layer string) bool) (map[string]Location, error) {
+	locations := make(map[string]Location)
+	err := r.db.View(func(tx *bolt.Tx) error {
+		digestsB, targets := tx.Bucket(digestsBucket), tx.Bucket(targetsBucket)
+		if digestsB == nil || targets == nil {
+			return nil
+		}
+		c := digestsB.Cursor()
+		for _, digest := range digests {
+			if _, found := locations[digest]; found {
+				continue
+			}
+			prefix := digestKey(digest, "")
+			for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+				layer := string(k[len(prefix):])
+				target := targets.Get([]byte(layer))
+				if target == nil || !use(layer) {
+					continue
+				}
+				offset, path, ok := strings.Cut(string(v), "@")
+				if !ok {
+					return fmt.Errorf("invalid location %q for %q in the dedup index", v, k)
+				}
+				off, err := strconv.ParseInt(offset, 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid location %q for %q in the dedup index: %w", v, k, err)
+				}
+				locations[digest] = Location{Layer: layer, Target: string(target), Path: path, Offset: off}
+				break
+			}
+		}
+		return nil
+	})
+	return locations, err
+}
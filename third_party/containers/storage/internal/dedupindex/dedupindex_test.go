@@ -0,0 +1,59 @@
+package dedupindex
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadersShareTheIndex(t *testing.T) {
+	graphRoot := t.TempDir()
+
+	_, err := OpenReader(graphRoot)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	// removing a layer does not create the index
+	require.NoError(t, RemoveLayer(graphRoot, "layer1"))
+	_, err = OpenReader(graphRoot)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, AddLayer(graphRoot, "layer1", "/layers/layer1", map[string][]byte{"digest1": []byte("0@file1")}))
+	require.NoError(t, AddLayer(graphRoot, "layer2", "/layers/layer2", map[string][]byte{"digest1": []byte("8@file2")}))
+
+	// readers hold a shared lock and do not block each other
+	r1, err := OpenReader(graphRoot)
+	require.NoError(t, err)
+	defer r1.Close()
+	r2, err := OpenReader(graphRoot)
+	require.NoError(t, err)
+	defer r2.Close()
+
+	locations, err := r1.Lookup([]string{"digest1", "digest2"}, func(layer string) bool {
+		return layer == "layer2"
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Location{
+		"digest1": {Layer: "layer2", Target: "/layers/layer2", Path: "file2", Offset: 8},
+	}, locations)
+
+	layers, err := r2.Layers()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{"layer1": {}, "layer2": {}}, layers)
+}
+
+func TestRemoveLayer(t *testing.T) {
+	graphRoot := t.TempDir()
+	require.NoError(t, AddLayer(graphRoot, "layer1", "/layers/layer1", map[string][]byte{"digest1": []byte("0@file1")}))
+	require.NoError(t, RemoveLayer(graphRoot, "layer1"))
+
+	r, err := OpenReader(graphRoot)
+	require.NoError(t, err)
+	defer r.Close()
+	locations, err := r.Lookup([]string{"digest1"}, func(string) bool { return true })
+	require.NoError(t, err)
+	assert.Empty(t, locations)
+	layers, err := r.Layers()
+	require.NoError(t, err)
+	assert.Empty(t, layers)
+}
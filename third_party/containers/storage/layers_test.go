@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/containers/storage/pkg/idtools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckStagedMappings(t *testing.T) {
+	uidMap := []idtools.IDMap{{ContainerID: 0, HostID: 100000, Size: 65536}}
+	gidMap := []idtools.IDMap{{ContainerID: 0, HostID: 200000, Size: 65536}}
+
+	// unshifted files for a layer mapped at mount time
+	assert.NoError(t, checkStagedMappings(&Layer{ID: "unshifted"}, nil))
+	assert.NoError(t, checkStagedMappings(&Layer{ID: "unshifted"}, &idtools.IDMappings{}))
+
+	// files mapped by the differ for a layer with its own mappings
+	layer := &Layer{ID: "mapped", UIDMap: uidMap, GIDMap: gidMap}
+	assert.NoError(t, checkStagedMappings(layer, idtools.NewIDMappingsFromMaps(uidMap, gidMap)))
+
+	// unshifted files are not chowned for a layer with its own mappings
+	assert.ErrorContains(t, checkStagedMappings(layer, nil), `layer "mapped" uses the ID mappings`)
+	assert.Error(t, checkStagedMappings(layer, idtools.NewIDMappingsFromMaps(uidMap, uidMap)))
+	assert.Error(t, checkStagedMappings(&Layer{ID: "unshifted"}, idtools.NewIDMappingsFromMaps(uidMap, gidMap)))
+}
@@ -0,0 +1,86 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	modeSetuid     = 0o4000
+	modeSetgid     = 0o2000
+	modeSticky     = 0o1000
+	modeWorldWrite = 0o0002
+)
+
+// ModeNormalization describes permission bits that are stripped from files
+// when they are extracted.  Unlike ForceMask, which replaces the mode
+// entirely, it only ever clears bits and leaves everything else untouched.
+type ModeNormalization struct {
+	ClearSetuid     bool
+	ClearSetgid     bool
+	ClearSticky     bool
+	ClearWorldWrite bool
+	// Mask holds additional permission bits that are cleared.
+	Mask os.FileMode
+}
+
+// ParseModeNormalization parses a comma separated list of policies.  Valid
+// entries are "clear-setuid", "clear-setgid", "clear-sticky",
+// "clear-world-write" and an octal mask of the bits to clear, e.g. "0o022".
+// An empty value returns a nil policy.
+func ParseModeNormalization(value string) (*ModeNormalization, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	m := &ModeNormalization{}
+	for _, entry := range strings.Split(value, ",") {
+		switch entry = strings.TrimSpace(strings.ToLower(entry)); entry {
+		case "clear-setuid":
+			m.ClearSetuid = true
+		case "clear-setgid":
+			m.ClearSetgid = true
+		case "clear-sticky":
+			m.ClearSticky = true
+		case "clear-world-write":
+			m.ClearWorldWrite = true
+		default:
+			mask, err := strconv.ParseUint(strings.TrimPrefix(entry, "0o"), 8, 32)
+			if err != nil || mask&^0o7777 != 0 {
+				return nil, fmt.Errorf("invalid mode normalization policy %q", entry)
+			}
+			m.Mask |= os.FileMode(mask)
+		}
+	}
+	return m, nil
+}
+
+// bits returns the raw permission bits cleared by the policy.
+func (m *ModeNormalization) bits() os.FileMode {
+	bits := m.Mask
+	if m.ClearSetuid {
+		bits |= modeSetuid
+	}
+	if m.ClearSetgid {
+		bits |= modeSetgid
+	}
+	if m.ClearSticky {
+		bits |= modeSticky
+	}
+	if m.ClearWorldWrite {
+		bits |= modeWorldWrite
+	}
+	return bits
+}
+
+// Normalize returns mode with the bits selected by the policy cleared.  mode
+// holds the raw permission bits as they are stored in a tar header, so
+// setuid is 0o4000 rather than os.ModeSetuid.  A nil policy returns mode as is.
+func (m *ModeNormalization) Normalize(mode os.FileMode) os.FileMode {
+	if m == nil {
+		return mode
+	}
+	return mode &^ m.bits()
+}
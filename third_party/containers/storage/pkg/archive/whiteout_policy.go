@@ -0,0 +1,60 @@
+package archive
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// WhiteoutPolicy restricts the files that the whiteouts of a layer may
+// delete from the layers below it.  A nil policy allows every whiteout.
+type WhiteoutPolicy struct {
+	// Allowed lists the paths, relative to the root of the layer, that
+	// whiteouts may delete.  Everything below an allowed directory may be
+	// deleted as well.
+	Allowed []string
+	// ReportOnly only reports whiteouts outside of Allowed instead of
+	// rejecting the layer.
+	ReportOnly bool
+}
+
+// ParseWhiteoutPolicy parses a whiteout policy.  mode is "deny" to reject
+// layers with whiteouts outside of allowed, "warn" to only report them, or
+// empty for no policy.  allowed is a colon separated list of paths.
+func ParseWhiteoutPolicy(mode, allowed string) (*WhiteoutPolicy, error) {
+	p := &WhiteoutPolicy{}
+	switch strings.TrimSpace(strings.ToLower(mode)) {
+	case "":
+		return nil, nil
+	case "deny":
+	case "warn":
+		p.ReportOnly = true
+	default:
+		return nil, fmt.Errorf("invalid whiteout policy %q", mode)
+	}
+	for _, path := range strings.Split(allowed, ":") {
+		if path = strings.TrimSpace(path); path != "" {
+			p.Allowed = append(p.Allowed, cleanWhiteoutPath(path))
+		}
+	}
+	return p, nil
+}
+
+// cleanWhiteoutPath returns path relative to the root of the layer.
+func cleanWhiteoutPath(path string) string {
+	return strings.TrimPrefix(filepath.Clean("/"+path), "/")
+}
+
+// Allows reports whether the policy allows a whiteout to delete path.
+func (p *WhiteoutPolicy) Allows(path string) bool {
+	if p == nil {
+		return true
+	}
+	path = cleanWhiteoutPath(path)
+	for _, allowed := range p.Allowed {
+		if allowed == "" || path == allowed || strings.HasPrefix(path, allowed+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package chunked
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/docker/go-units"
+	"golang.org/x/time/rate"
+)
+
+var (
+	bandwidthLimitersLock sync.Mutex
+	bandwidthLimiters     = make(map[int64]*rate.Limiter)
+)
+
+// parseMaxBandwidth parses the partial_pull_max_bandwidth pull option, in
+// bytes per second with an optional decimal unit, e.g. "10MB".  0 means that
+// the bandwidth is not limited.
+func parseMaxBandwidth(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := units.FromHumanSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid partial_pull_max_bandwidth %q: %w", value, err)
+	}
+	return limit, nil
+}
+
+// bandwidthLimiter returns the limiter of the pulls limited to bytesPerSecond.
+// It is shared by all the layers pulled by the process with the same limit,
+// so that the limit applies to the whole uplink and not to each layer.
+func bandwidthLimiter(bytesPerSecond int64) *rate.Limiter {
+	bandwidthLimitersLock.Lock()
+	defer bandwidthLimitersLock.Unlock()
+	limiter, ok := bandwidthLimiters[bytesPerSecond]
+	if !ok {
+		// Allow bursts of one second worth of data.
+		limiter = rate.NewLimiter(rate.Limit(bytesPerSecond), int(bytesPerSecond))
+		bandwidthLimiters[bytesPerSecond] = limiter
+	}
+	return limiter
+}
+
+// limitBandwidth returns an ImageSourceSeekable reading the streams returned
+// by src no faster than limiter allows.  The streams are read only as fast
+// as the limit, so that the server is slowed down as well.
+func limitBandwidth(src ImageSourceSeekable, limiter *rate.Limiter) ImageSourceSeekable {
+	return &limitedSource{
+		source:  src,
+		limiter: limiter,
+	}
+}
+
+type limitedSource struct {
+	source  ImageSourceSeekable
+	limiter *rate.Limiter
+}
+
+func (s *limitedSource) GetBlobAt(chunks []ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	streams, errs, err := s.source.GetBlobAt(chunks)
+	if err != nil {
+		return nil, nil, err
+	}
+	limited := make(chan io.ReadCloser)
+	go func() {
+		defer close(limited)
+		for stream := range streams {
+			limited <- &limitedReader{ReadCloser: stream, limiter: s.limiter}
+		}
+	}()
+	return limited, errs, nil
+}
+
+type limitedReader struct {
+	io.ReadCloser
+	limiter *rate.Limiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if burst := r.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if errWait := r.limiter.WaitN(context.Background(), n); errWait != nil && err == nil {
+			err = errWait
+		}
+	}
+	return n, err
+}
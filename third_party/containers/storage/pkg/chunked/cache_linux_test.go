@@ -0,0 +1,411 @@
+package chunked
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	storage "github.com/containers/storage"
+	graphdriver "github.com/containers/storage/drivers"
+	"github.com/containers/storage/internal/dedupindex"
+	"github.com/containers/storage/pkg/chunked/internal"
+	"github.com/klauspost/compress/zstd"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+type discardBigData struct{}
+
+func (discardBigData) SetLayerBigData(id, key string, data io.Reader) error {
+	_, err := io.Copy(io.Discard, data)
+	return err
+}
+
+type graphRootStore struct {
+	storage.Store
+	graphRoot string
+}
+
+func (s graphRootStore) GraphRoot() string {
+	return s.graphRoot
+}
+
+func TestDedupIndex(t *testing.T) {
+	fileDigest := digest.FromString("file content").String()
+	chunkDigest := digest.FromString("chunk").String()
+	manifest, err := json.Marshal(internal.TOC{
+		Version: 1,
+		Entries: []internal.FileMetadata{
+			{Type: internal.TypeReg, Name: "usr/bin/file", Size: 12, Digest: fileDigest},
+			{Type: internal.TypeChunk, Name: "usr/bin/file", ChunkOffset: 4, ChunkDigest: chunkDigest},
+		},
+	})
+	require.NoError(t, err)
+	metadata, err := writeCache(manifest, graphdriver.DifferOutputFormatDir, "layer1", discardBigData{}, logrus.StandardLogger())
+	require.NoError(t, err)
+
+	// the layer store adds the layer to the index when it is created
+	graphRoot := t.TempDir()
+	require.NoError(t, dedupindex.AddLayer(graphRoot, "layer1", "/layers/layer1", metadata.dedupLocations()))
+
+	c := &layersCache{store: graphRootStore{graphRoot: graphRoot}, indexed: make(map[string]*dedupindex.Location)}
+	layers, err := c.dedupIndexLayers()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{"layer1": {}}, layers)
+	c.indexedLayers = layers
+
+	missingDigest := digest.FromString("missing").String()
+	require.NoError(t, c.lookupDedupIndex([]string{fileDigest, missingDigest}))
+	assert.Contains(t, c.indexed, fileDigest)
+	assert.Contains(t, c.indexed, missingDigest)
+	assert.NotContains(t, c.indexed, chunkDigest)
+
+	target, name, err := c.findFileInOtherLayers(&internal.FileMetadata{Digest: fileDigest}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "/layers/layer1", target)
+	assert.Equal(t, "usr/bin/file", name)
+
+	// the digests that were not looked up yet are looked up on demand
+	target, name, off, err := c.findChunkInOtherLayers(&internal.FileMetadata{ChunkDigest: chunkDigest})
+	require.NoError(t, err)
+	assert.Equal(t, "/layers/layer1", target)
+	assert.Equal(t, "usr/bin/file", name)
+	assert.Equal(t, int64(4), off)
+
+	target, _, _, err = c.findDigestInternal(missingDigest)
+	require.NoError(t, err)
+	assert.Empty(t, target)
+
+	// the layer store removes the layer from the index when it is deleted
+	require.NoError(t, dedupindex.RemoveLayer(graphRoot, "layer1"))
+	c = &layersCache{store: graphRootStore{graphRoot: graphRoot}, indexed: make(map[string]*dedupindex.Location)}
+	layers, err = c.dedupIndexLayers()
+	require.NoError(t, err)
+	assert.Empty(t, layers)
+}
+
+func TestDedupIndexMissing(t *testing.T) {
+	c := &layersCache{store: graphRootStore{graphRoot: t.TempDir()}, indexed: make(map[string]*dedupindex.Location)}
+	layers, err := c.dedupIndexLayers()
+	require.NoError(t, err)
+	assert.Empty(t, layers)
+}
+
+func TestConvertCache(t *testing.T) {
+	graphRoot := t.TempDir()
+	cache := newConvertCache(graphRoot)
+	logger := logrus.StandardLogger()
+	blobDigest := digest.FromString("gzip layer")
+	diffID := digest.FromString("tarball")
+	encoder, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	blob := encoder.EncodeAll([]byte("tarball"), nil)
+	annotations := map[string]string{"annotation": "value"}
+
+	_, _, _, found := cache.lookup(blobDigest, logger)
+	assert.False(t, found)
+
+	// the converted blobs are O_TMPFILE files on the same file system
+	fd, err := unix.Open(graphRoot, unix.O_TMPFILE|unix.O_RDWR|unix.O_CLOEXEC, 0o600)
+	require.NoError(t, err)
+	converted := &seekableFile{file: os.NewFile(uintptr(fd), "converted")}
+	defer converted.Close()
+	_, err = converted.file.Write(blob)
+	require.NoError(t, err)
+
+	cache.store(blobDigest, converted, diffID, annotations, logger)
+	// storing the same blob again is not an error
+	require.NoError(t, cache.storeEntry(blobDigest, converted, diffID, annotations))
+
+	cached, cachedDiffID, cachedAnnotations, found := cache.lookup(blobDigest, logger)
+	require.True(t, found)
+	defer cached.Close()
+	assert.Equal(t, diffID, cachedDiffID)
+	assert.Equal(t, annotations, cachedAnnotations)
+	content, err := io.ReadAll(io.NewSectionReader(cached.file, 0, 1<<20))
+	require.NoError(t, err)
+	assert.Equal(t, blob, content)
+
+	// an entry that does not match its metadata is not used, and removed
+	tampered := digest.FromString("tampered layer")
+	require.NoError(t, cache.storeEntry(tampered, converted, digest.FromString("another tarball"), nil))
+	_, _, _, found = cache.lookup(tampered, logger)
+	assert.False(t, found)
+	_, err = os.Stat(cache.entry(tampered))
+	assert.True(t, os.IsNotExist(err))
+
+	corrupted := digest.FromString("corrupted layer")
+	require.NoError(t, cache.storeEntry(corrupted, converted, diffID, nil))
+	require.NoError(t, os.WriteFile(filepath.Join(cache.entry(corrupted), convertCacheBlob), encoder.EncodeAll([]byte("tarbal!"), nil), 0o600))
+	_, _, _, found = cache.lookup(corrupted, logger)
+	assert.False(t, found)
+
+	// a disabled cache has nothing
+	_, _, _, found = convertCache("").lookup(blobDigest, logger)
+	assert.False(t, found)
+
+	// the entries not used recently are pruned, the others are kept
+	other := digest.FromString("other layer")
+	require.NoError(t, cache.storeEntry(other, converted, diffID, nil))
+	old := time.Now().Add(-2 * convertCacheMaxAge)
+	require.NoError(t, os.Chtimes(cache.entry(other), old, old))
+	cache.prune(convertCacheMaxAge, logger)
+	_, err = os.Stat(cache.entry(other))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(cache.entry(blobDigest), convertCacheBlob))
+	assert.NoError(t, err)
+}
+
+// fakeSource serves chunks of blob and records the requested chunks
+type fakeSource struct {
+	blob []byte
+	// badRequest makes GetBlobAt fail with ErrBadRequest
+	badRequest bool
+	// failAfter sends an error instead of the stream of the chunk at this index
+	failAfter int
+
+	lock      sync.Mutex
+	requested [][]ImageSourceChunk
+}
+
+func (f *fakeSource) GetBlobAt(chunks []ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	if f.badRequest {
+		return nil, nil, ErrBadRequest{}
+	}
+	f.lock.Lock()
+	f.requested = append(f.requested, chunks)
+	f.lock.Unlock()
+
+	streams := make(chan io.ReadCloser)
+	errs := make(chan error)
+	go func() {
+		defer close(streams)
+		defer close(errs)
+		for i, chunk := range chunks {
+			if f.failAfter > 0 && i == f.failAfter {
+				errs <- errors.New("connection reset")
+				return
+			}
+			streams <- io.NopCloser(io.NewSectionReader(bytesReaderAt(f.blob), int64(chunk.Offset), int64(chunk.Length)))
+		}
+	}()
+	return streams, errs, nil
+}
+
+type bytesReaderAt []byte
+
+func (b bytesReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readChunks reads all the chunks the way storeMissingFiles does
+func readChunks(t *testing.T, src ImageSourceSeekable, chunks []ImageSourceChunk) ([]string, error) {
+	t.Helper()
+	streams, errs, err := src.GetBlobAt(chunks)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for range chunks {
+		select {
+		case part := <-streams:
+			if part == nil {
+				return result, errors.New("invalid stream returned")
+			}
+			data, err := io.ReadAll(part)
+			part.Close()
+			require.NoError(t, err)
+			result = append(result, string(data))
+		case err := <-errs:
+			if err == nil {
+				return result, errors.New("not enough data returned from the server")
+			}
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func TestSourceCache(t *testing.T) {
+	blob := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	blobDigest := digest.FromBytes(blob)
+	cache := NewSourceCache(64)
+
+	upstream := &fakeSource{blob: blob}
+	src := cache.Wrap(upstream, blobDigest)
+	result, err := readChunks(t, src, []ImageSourceChunk{{Offset: 0, Length: 10}, {Offset: 20, Length: 5}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0123456789", "klmno"}, result)
+	assert.Len(t, upstream.requested, 1)
+
+	// a later pull of the same blob is served from the cache, including
+	// ranges contained in a cached one, and only the rest is fetched
+	upstream2 := &fakeSource{blob: blob}
+	src = cache.Wrap(upstream2, blobDigest)
+	result, err = readChunks(t, src, []ImageSourceChunk{{Offset: 2, Length: 3}, {Offset: 30, Length: 4}, {Offset: 20, Length: 5}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"234", "uvwx", "klmno"}, result)
+	assert.Equal(t, [][]ImageSourceChunk{{{Offset: 30, Length: 4}}}, upstream2.requested)
+
+	// fully cached requests do not reach the source at all
+	upstream3 := &fakeSource{blob: blob}
+	src = cache.Wrap(upstream3, blobDigest)
+	result, err = readChunks(t, src, []ImageSourceChunk{{Offset: 30, Length: 4}, {Offset: 0, Length: 10}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"uvwx", "0123456789"}, result)
+	assert.Empty(t, upstream3.requested)
+
+	// ranges of another blob are not shared
+	upstream4 := &fakeSource{blob: blob}
+	src = cache.Wrap(upstream4, digest.FromString("other"))
+	_, err = readChunks(t, src, []ImageSourceChunk{{Offset: 0, Length: 10}})
+	require.NoError(t, err)
+	assert.Len(t, upstream4.requested, 1)
+}
+
+func TestSourceCacheEviction(t *testing.T) {
+	blob := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	blobDigest := digest.FromBytes(blob)
+	cache := NewSourceCache(20)
+
+	upstream := &fakeSource{blob: blob}
+	src := cache.Wrap(upstream, blobDigest)
+	// the first range is evicted by the fifth, the big one is never cached
+	for _, chunk := range []ImageSourceChunk{{Offset: 0, Length: 5}, {Offset: 5, Length: 5}, {Offset: 10, Length: 5}, {Offset: 16, Length: 5}, {Offset: 25, Length: 5}, {Offset: 0, Length: 30}} {
+		_, err := readChunks(t, src, []ImageSourceChunk{chunk})
+		require.NoError(t, err)
+	}
+	assert.LessOrEqual(t, cache.size, uint64(20))
+
+	upstream.requested = nil
+	result, err := readChunks(t, src, []ImageSourceChunk{{Offset: 0, Length: 5}, {Offset: 16, Length: 5}, {Offset: 0, Length: 30}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"01234", "ghijk", string(blob[:30])}, result)
+	assert.Equal(t, [][]ImageSourceChunk{{{Offset: 0, Length: 5}, {Offset: 0, Length: 30}}}, upstream.requested)
+}
+
+func TestSourceCacheErrors(t *testing.T) {
+	blob := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	blobDigest := digest.FromBytes(blob)
+	cache := NewSourceCache(64)
+
+	// ErrBadRequest is passed through so that the caller can merge chunks
+	src := cache.Wrap(&fakeSource{blob: blob, badRequest: true}, blobDigest)
+	_, err := readChunks(t, src, []ImageSourceChunk{{Offset: 0, Length: 5}})
+	var badRequest ErrBadRequest
+	assert.ErrorAs(t, err, &badRequest)
+
+	// errors sent by the source are forwarded after the chunks before them
+	upstream := &fakeSource{blob: blob, failAfter: 1}
+	src = cache.Wrap(upstream, blobDigest)
+	result, err := readChunks(t, src, []ImageSourceChunk{{Offset: 0, Length: 5}, {Offset: 5, Length: 5}})
+	assert.EqualError(t, err, "connection reset")
+	assert.Equal(t, []string{"01234"}, result)
+
+	// the chunk fetched before the error is cached, the failed one is not
+	upstream = &fakeSource{blob: blob}
+	src = cache.Wrap(upstream, blobDigest)
+	result, err = readChunks(t, src, []ImageSourceChunk{{Offset: 0, Length: 5}, {Offset: 5, Length: 5}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"01234", "56789"}, result)
+	assert.Equal(t, [][]ImageSourceChunk{{{Offset: 5, Length: 5}}}, upstream.requested)
+
+	// a source that sends fewer streams than requested is reported as such
+	short := &shortSource{fakeSource{blob: blob}}
+	src = cache.Wrap(short, blobDigest)
+	_, err = readChunks(t, src, []ImageSourceChunk{{Offset: 20, Length: 5}, {Offset: 25, Length: 5}})
+	assert.EqualError(t, err, "not enough data returned from the server")
+}
+
+// shortSource only returns the first requested chunk
+type shortSource struct {
+	fakeSource
+}
+
+func (s *shortSource) GetBlobAt(chunks []ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	return s.fakeSource.GetBlobAt(chunks[:1])
+}
+
+// serveChunkProvider serves blobs on a Unix socket the way a chunk provider
+// does, and returns the path of the socket.
+func serveChunkProvider(t *testing.T, blobs map[digest.Digest][]byte) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "provider.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			blob, ok := blobs[digest.Digest(strings.TrimPrefix(r.URL.Path, "/v1/blobs/"))]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(blob))
+		}),
+		ReadHeaderTimeout: time.Second,
+	}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		server.Close()
+	})
+	return socket
+}
+
+func TestChunkProvider(t *testing.T) {
+	blob := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	cached := digest.FromBytes(blob)
+	socket := serveChunkProvider(t, map[digest.Digest][]byte{cached: blob})
+	provider := newChunkProvider(socket)
+
+	for _, chunks := range [][]ImageSourceChunk{
+		{{Offset: 10, Length: 5}},
+		{{Offset: 0, Length: 4}, {Offset: 10, Length: 5}, {Offset: 30, Length: 6}},
+	} {
+		registry := &fakeSource{blob: blob}
+		data, err := readChunks(t, provider.wrap(registry, cached, logrus.StandardLogger()), chunks)
+		require.NoError(t, err)
+		for i, chunk := range chunks {
+			assert.Equal(t, string(blob[chunk.Offset:chunk.Offset+chunk.Length]), data[i])
+		}
+		assert.Empty(t, registry.requested)
+	}
+
+	// the chunks missed by the provider are requested from the registry
+	registry := &fakeSource{blob: blob}
+	chunks := []ImageSourceChunk{{Offset: 0, Length: 4}, {Offset: 10, Length: 5}}
+	data, err := readChunks(t, provider.wrap(registry, digest.FromString("unknown"), logrus.StandardLogger()), chunks)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0123", "abcde"}, data)
+	assert.Equal(t, [][]ImageSourceChunk{chunks}, registry.requested)
+
+	// as are all the chunks when the provider is not running
+	registry = &fakeSource{blob: blob}
+	down := newChunkProvider(filepath.Join(t.TempDir(), "missing.sock"))
+	data, err = readChunks(t, down.wrap(registry, cached, logrus.StandardLogger()), chunks)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0123", "abcde"}, data)
+	assert.Len(t, registry.requested, 1)
+
+	assert.Same(t, chunkProviderFor(socket), chunkProviderFor(socket))
+}
@@ -0,0 +1,185 @@
+package chunked
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// chunkProviderResponseTimeout is how long the chunk provider has to start
+// answering a request before the chunks are requested from the registry.
+const chunkProviderResponseTimeout = 10 * time.Second
+
+var (
+	chunkProvidersLock sync.Mutex
+	chunkProviders     = make(map[string]*chunkProvider)
+)
+
+// chunkProvider is a client of an external daemon serving the chunks of the
+// blobs, e.g. a peer-to-peer distribution agent or a local cache.  The
+// daemon speaks HTTP on a Unix socket, with the same semantics as a registry
+// for range requests:
+//
+//	GET /v1/blobs/<digest> HTTP/1.1
+//	Range: bytes=<first>-<last>[,<first>-<last>...]
+//
+// It answers 206 with the single requested range, or with a
+// multipart/byteranges body holding all the ranges in the requested order.
+// Any other answer, 404 in particular, is a miss and the chunks are then
+// requested from the registry.
+type chunkProvider struct {
+	socket string
+	client *http.Client
+}
+
+// chunkProviderFor returns the client of the chunk provider listening on
+// socket.  It is shared by all the layers pulled by the process, so that the
+// connections are reused.
+func chunkProviderFor(socket string) *chunkProvider {
+	chunkProvidersLock.Lock()
+	defer chunkProvidersLock.Unlock()
+	provider, ok := chunkProviders[socket]
+	if !ok {
+		provider = newChunkProvider(socket)
+		chunkProviders[socket] = provider
+	}
+	return provider
+}
+
+func newChunkProvider(socket string) *chunkProvider {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+		ResponseHeaderTimeout: chunkProviderResponseTimeout,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	return &chunkProvider{
+		socket: socket,
+		client: &http.Client{Transport: transport},
+	}
+}
+
+// wrap returns an ImageSourceSeekable for the blob with the given digest that
+// requests the chunks from the provider first, and from src when the
+// provider misses.
+func (p *chunkProvider) wrap(src ImageSourceSeekable, blobDigest digest.Digest, logger Logger) ImageSourceSeekable {
+	return &providerSource{
+		provider:   p,
+		source:     src,
+		blobDigest: blobDigest,
+		logger:     logger,
+	}
+}
+
+// getBlobAt requests chunks of blob from the provider.  It returns an error
+// when the provider cannot serve them, the streams are sent as
+// GetBlobAt does.
+func (p *chunkProvider) getBlobAt(blob digest.Digest, chunks []ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	if err := blob.Validate(); err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, "http://chunk-provider/v1/blobs/"+blob.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	ranges := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		if chunk.Length == 0 {
+			return nil, nil, errors.New("empty chunk requested")
+		}
+		ranges = append(ranges, fmt.Sprintf("%d-%d", chunk.Offset, chunk.Offset+chunk.Length-1))
+	}
+	req.Header.Set("Range", "bytes="+strings.Join(ranges, ","))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("unexpected status %q", resp.Status)
+	}
+
+	streams := make(chan io.ReadCloser)
+	errs := make(chan error)
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		if len(chunks) != 1 {
+			resp.Body.Close()
+			return nil, nil, errors.New("a single range returned for multiple chunks")
+		}
+		go func() {
+			defer close(streams)
+			defer close(errs)
+			streams <- resp.Body
+		}()
+		return streams, errs, nil
+	}
+
+	go func() {
+		defer close(streams)
+		defer close(errs)
+		defer resp.Body.Close()
+
+		mr := multipart.NewReader(resp.Body, params["boundary"])
+		for range chunks {
+			part, err := mr.NextPart()
+			if err != nil {
+				if err == io.EOF {
+					err = errors.New("not enough data returned from the chunk provider")
+				}
+				errs <- err
+				return
+			}
+			// The next part can be read only once the consumer is done
+			// with this one.
+			s := &signalCloseReader{ReadCloser: part, closed: make(chan struct{})}
+			streams <- s
+			<-s.closed
+		}
+	}()
+	return streams, errs, nil
+}
+
+// signalCloseReader closes the closed channel when it is closed.
+type signalCloseReader struct {
+	io.ReadCloser
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (r *signalCloseReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(func() { close(r.closed) })
+	return err
+}
+
+type providerSource struct {
+	provider   *chunkProvider
+	source     ImageSourceSeekable
+	blobDigest digest.Digest
+	logger     Logger
+}
+
+// GetBlobAt requests the chunks from the chunk provider, and from the
+// wrapped source if the provider cannot serve all of them.
+func (s *providerSource) GetBlobAt(chunks []ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	streams, errs, err := s.provider.getBlobAt(s.blobDigest, chunks)
+	if err == nil {
+		return streams, errs, nil
+	}
+	s.logger.Debugf("Chunk provider %s could not serve %d chunks of %s, requesting them from the registry: %v", s.provider.socket, len(chunks), s.blobDigest, err)
+	return s.source.GetBlobAt(chunks)
+}
@@ -0,0 +1,56 @@
+package chunked
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/containers/storage/pkg/chunked/dump"
+	"github.com/containers/storage/pkg/chunked/internal"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// composefsImageKey is the artifact with the path of the composefs
+	// image written for graphdriver.DifferOutputFormatComposefs
+	composefsImageKey = "composefs-image"
+
+	// composefsImageName is the name of the composefs image in the
+	// destination directory.  The files of the flat layout are stored
+	// under checksum[0:2]/, so it does not collide with any of them.
+	composefsImageName = "composefs.blob"
+)
+
+// writeComposefsImage writes the composefs EROFS image of toc to dest with
+// mkcomposefs.  The image refers to the files of the flat layout in dest and
+// carries their fs-verity digests, so that they are checked when the image
+// is mounted.  It returns the path of the image.
+func writeComposefsImage(dest string, toc *internal.TOC, verityDigests map[string]string) (string, error) {
+	helper, err := exec.LookPath("mkcomposefs")
+	if err != nil {
+		return "", fmt.Errorf("failed to find mkcomposefs: %w", err)
+	}
+
+	dumpReader, err := dump.GenerateDump(toc, verityDigests)
+	if err != nil {
+		return "", err
+	}
+
+	imagePath := filepath.Join(dest, composefsImageName)
+	fd, err := unix.Open(imagePath, newFileFlags|unix.O_CLOEXEC, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open output file %q: %w", imagePath, err)
+	}
+	out := os.NewFile(uintptr(fd), imagePath)
+	defer out.Close()
+
+	cmd := exec.Command(helper, "--from-file", "-", "/proc/self/fd/3")
+	cmd.ExtraFiles = []*os.File{out}
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = dumpReader
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to convert json to erofs: %w", err)
+	}
+	return imagePath, nil
+}
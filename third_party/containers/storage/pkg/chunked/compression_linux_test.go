@@ -0,0 +1,206 @@
+package chunked
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/storage/pkg/chunked/compressor"
+	"github.com/containers/storage/pkg/chunked/internal"
+	"github.com/klauspost/compress/zstd"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vbatts/tar-split/tar/asm"
+	"github.com/vbatts/tar-split/tar/storage"
+)
+
+func TestConvertTarToZstdChunked(t *testing.T) {
+	files := map[string][]byte{
+		"empty":  {},
+		"small":  []byte("small file"),
+		"random": make([]byte, 1<<20),
+		"zeros":  make([]byte, 64<<10),
+	}
+	_, err := rand.New(rand.NewSource(1)).Read(files["random"])
+	require.NoError(t, err)
+
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	for _, name := range []string{"empty", "small", "random", "zeros"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(files[name]))}))
+		_, err := tw.Write(files[name])
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	for _, threads := range []int{1, 4} {
+		payload, err := os.CreateTemp(t.TempDir(), "layer")
+		require.NoError(t, err)
+		defer payload.Close()
+		_, err = payload.Write(layer.Bytes())
+		require.NoError(t, err)
+		_, err = payload.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		converted, diffID, annotations, err := convertTarToZstdChunked(t.TempDir(), payload, 3, threads)
+		require.NoError(t, err)
+		defer converted.Close()
+		assert.Equal(t, digest.FromBytes(layer.Bytes()), diffID)
+
+		// the cache verifies a converted blob by decompressing it to the tarball
+		blobDigest, err := digest.FromReader(io.NewSectionReader(converted.file, 0, 1<<63-1))
+		require.NoError(t, err)
+		assert.NoError(t, verifyConvertCacheEntry(converted.file, &convertCacheEntry{Digest: blobDigest, DiffID: diffID}))
+
+		st, err := converted.file.Stat()
+		require.NoError(t, err)
+		manifest, _, _, err := readZstdChunkedManifest(converted, st.Size(), annotations)
+		require.NoError(t, err)
+		toc, err := unmarshalToc(manifest)
+		require.NoError(t, err)
+
+		// every file can be decompressed from its own range of the blob
+		for _, e := range toc.Entries {
+			if e.Type != internal.TypeReg || e.Size == 0 {
+				continue
+			}
+			r, err := zstd.NewReader(io.NewSectionReader(converted.file, e.Offset, e.EndOffset-e.Offset))
+			require.NoError(t, err)
+			content, err := io.ReadAll(r)
+			r.Close()
+			require.NoError(t, err)
+			assert.Equal(t, files[e.Name], content, "file %s with %d threads", e.Name, threads)
+		}
+	}
+}
+
+func TestConvertTarToZstdChunkedHoles(t *testing.T) {
+	data := make([]byte, 16<<10)
+	_, err := rand.New(rand.NewSource(1)).Read(data)
+	require.NoError(t, err)
+	sparse := append(append(append([]byte{}, data...), make([]byte, 1<<20)...), data...)
+
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	for name, content := range map[string][]byte{"sparse": sparse, "zeros": make([]byte, 1<<20)} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(content))}))
+		_, err := tw.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	payload, err := os.CreateTemp(t.TempDir(), "layer")
+	require.NoError(t, err)
+	defer payload.Close()
+	_, err = payload.Write(layer.Bytes())
+	require.NoError(t, err)
+	_, err = payload.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	converted, _, annotations, err := convertTarToZstdChunked(t.TempDir(), payload, 3, 1)
+	require.NoError(t, err)
+	defer converted.Close()
+	st, err := converted.file.Stat()
+	require.NoError(t, err)
+	manifest, _, _, err := readZstdChunkedManifest(converted, st.Size(), annotations)
+	require.NoError(t, err)
+	toc, err := unmarshalToc(manifest)
+	require.NoError(t, err)
+
+	holes := make(map[string]int64)
+	for _, e := range toc.Entries {
+		if e.ChunkType == internal.ChunkTypeZeros {
+			holes[e.Name] += e.ChunkSize
+		}
+	}
+	assert.Equal(t, map[string]int64{"sparse": 1 << 20, "zeros": 1 << 20}, holes)
+
+	// rolling the zeros of a hole at once does not change the chunking
+	a, b := compressor.NewRollSum(), compressor.NewRollSum()
+	for _, c := range data[:100] {
+		a.Roll(c)
+		b.Roll(c)
+	}
+	for i := 0; i < 1000; i++ {
+		a.Roll(0)
+	}
+	b.RollZeros(1000)
+	for _, c := range data[100:200] {
+		a.Roll(c)
+		b.Roll(c)
+		assert.Equal(t, a.Digest(), b.Digest())
+	}
+}
+
+func TestParseConvertOptions(t *testing.T) {
+	level, err := parseConvertZstdLevel("")
+	require.NoError(t, err)
+	assert.Equal(t, 1, level)
+	level, err = parseConvertZstdLevel("19")
+	require.NoError(t, err)
+	assert.Equal(t, 19, level)
+	for _, value := range []string{"0", "23", "fast"} {
+		_, err := parseConvertZstdLevel(value)
+		assert.Error(t, err, value)
+	}
+
+	threads, err := parseConvertThreads("")
+	require.NoError(t, err)
+	assert.Equal(t, 1, threads)
+	threads, err = parseConvertThreads("8")
+	require.NoError(t, err)
+	assert.Equal(t, 8, threads)
+	for _, value := range []string{"0", "-1", "many"} {
+		_, err := parseConvertThreads(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestReassembledDigest(t *testing.T) {
+	files := map[string]string{
+		"a":     "content of a",
+		"dir/b": "content of b",
+		"empty": "",
+	}
+
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0o755}))
+	for _, name := range []string{"a", "dir/b", "empty"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(files[name]))}))
+		_, err := tw.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	var tarSplit bytes.Buffer
+	stream, err := asm.NewInputTarStream(bytes.NewReader(layer.Bytes()), storage.NewJSONPacker(&tarSplit), storage.NewDiscardFilePutter())
+	require.NoError(t, err)
+	_, err = io.Copy(io.Discard, stream)
+	require.NoError(t, err)
+
+	dest, dirfd := checkoutDir(t)
+	for name, content := range files {
+		path := filepath.Join(dest, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	d, err := reassembledDigest(dirfd, tarSplit.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, digest.FromBytes(layer.Bytes()), d)
+
+	// a file that differs from the tar stream is detected
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "dir/b"), []byte("CONTENT OF B"), 0o644))
+	_, err = reassembledDigest(dirfd, tarSplit.Bytes())
+	assert.Error(t, err)
+
+	// and so is a missing file
+	require.NoError(t, os.Remove(filepath.Join(dest, "a")))
+	_, err = reassembledDigest(dirfd, tarSplit.Bytes())
+	assert.Error(t, err)
+}
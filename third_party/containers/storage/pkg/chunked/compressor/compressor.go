@@ -0,0 +1,678 @@
+package compressor
+
+// NOTE: This is used from github.com/containers/image by callers that
+// don't otherwise use containers/storage, so don't make this depend on any
+// larger software like the graph drivers.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+
+	"github.com/containers/storage/pkg/chunked/internal"
+	"github.com/containers/storage/pkg/ioutils"
+	"github.com/klauspost/compress/zstd"
+	"github.com/opencontainers/go-digest"
+	"github.com/vbatts/tar-split/archive/tar"
+	"github.com/vbatts/tar-split/tar/asm"
+	"github.com/vbatts/tar-split/tar/storage"
+)
+
+const (
+	RollsumBits    = 16
+	holesThreshold = int64(1 << 10)
+)
+
+type holesFinder struct {
+	reader    *bufio.Reader
+	zeros     int64
+	threshold int64
+
+	state int
+}
+
+const (
+	holesFinderStateRead = iota
+	holesFinderStateAccumulate
+	holesFinderStateFound
+	holesFinderStateEOF
+)
+
+// skipZeros consumes the run of zeros at the start of the data buffered by
+// the reader, filling it first if it is empty, and returns its length.  The
+// zeros are counted in place, without copying them out of the buffer one
+// byte at a time.
+func (f *holesFinder) skipZeros() (int64, error) {
+	if _, err := f.reader.Peek(1); err != nil {
+		return 0, err
+	}
+	buf, err := f.reader.Peek(f.reader.Buffered())
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for n < len(buf) && buf[n] == 0 {
+		n++
+	}
+	if _, err := f.reader.Discard(n); err != nil {
+		return 0, err
+	}
+	return int64(n), nil
+}
+
+// readByte reads a single byte from the underlying reader.
+// If a single byte is read, the return value is (0, RAW-BYTE-VALUE, nil).
+// If there are at least f.THRESHOLD consecutive zeros, then the
+// return value is (N_CONSECUTIVE_ZEROS, '\x00').
+func (f *holesFinder) readByte() (int64, byte, error) {
+	for {
+		switch f.state {
+		// reading the file stream
+		case holesFinderStateRead:
+			if f.zeros > 0 {
+				f.zeros--
+				return 0, 0, nil
+			}
+			b, err := f.reader.ReadByte()
+			if err != nil {
+				return 0, b, err
+			}
+
+			if b != 0 {
+				return 0, b, err
+			}
+
+			f.zeros = 1
+			if f.zeros >= f.threshold {
+				f.state = holesFinderStateFound
+			} else {
+				f.state = holesFinderStateAccumulate
+			}
+		// accumulating zeros, but still didn't reach the threshold
+		case holesFinderStateAccumulate:
+			n, err := f.skipZeros()
+			if err != nil {
+				if err == io.EOF {
+					f.state = holesFinderStateEOF
+					continue
+				}
+				return 0, 0, err
+			}
+			if n == 0 {
+				// the next byte is not a zero, it is left in the reader.
+				f.state = holesFinderStateRead
+				continue
+			}
+			f.zeros += n
+			if f.zeros >= f.threshold {
+				f.state = holesFinderStateFound
+			}
+		// found a hole.  Number of zeros >= threshold
+		case holesFinderStateFound:
+			n, err := f.skipZeros()
+			if err != nil && err != io.EOF {
+				return 0, 0, err
+			}
+			if err == io.EOF || n == 0 {
+				if err == io.EOF {
+					f.state = holesFinderStateEOF
+				} else {
+					f.state = holesFinderStateRead
+				}
+				holeLen := f.zeros
+				f.zeros = 0
+				return holeLen, 0, nil
+			}
+			f.zeros += n
+		// reached EOF.  Flush pending zeros if any.
+		case holesFinderStateEOF:
+			if f.zeros > 0 {
+				f.zeros--
+				return 0, 0, nil
+			}
+			return 0, 0, io.EOF
+		}
+	}
+}
+
+type rollingChecksumReader struct {
+	reader      *holesFinder
+	closed      bool
+	rollsum     *RollSum
+	pendingHole int64
+
+	// WrittenOut is the total number of bytes read from
+	// the stream.
+	WrittenOut int64
+
+	// IsLastChunkZeros tells whether the last generated
+	// chunk is a hole (made of consecutive zeros).  If it
+	// is false, then the last chunk is a data chunk
+	// generated by the rolling checksum.
+	IsLastChunkZeros bool
+}
+
+func (rc *rollingChecksumReader) Read(b []byte) (bool, int, error) {
+	rc.IsLastChunkZeros = false
+
+	if rc.pendingHole > 0 {
+		toCopy := int64(len(b))
+		if rc.pendingHole < toCopy {
+			toCopy = rc.pendingHole
+		}
+		rc.pendingHole -= toCopy
+		for i := int64(0); i < toCopy; i++ {
+			b[i] = 0
+		}
+
+		rc.WrittenOut += toCopy
+
+		rc.IsLastChunkZeros = true
+
+		// if there are no other zeros left, terminate the chunk
+		return rc.pendingHole == 0, int(toCopy), nil
+	}
+
+	if rc.closed {
+		return false, 0, io.EOF
+	}
+
+	for i := 0; i < len(b); i++ {
+		holeLen, n, err := rc.reader.readByte()
+		if err != nil {
+			if err == io.EOF {
+				rc.closed = true
+				if i == 0 {
+					return false, 0, err
+				}
+				return false, i, nil
+			}
+			// Report any other error type
+			return false, -1, err
+		}
+		if holeLen > 0 {
+			rc.rollsum.RollZeros(holeLen)
+			rc.pendingHole = holeLen
+			return true, i, nil
+		}
+		b[i] = n
+		rc.WrittenOut++
+		rc.rollsum.Roll(n)
+		if rc.rollsum.OnSplitWithBits(RollsumBits) {
+			return true, i + 1, nil
+		}
+	}
+	return false, len(b), nil
+}
+
+type chunk struct {
+	ChunkOffset int64
+	Offset      int64
+	Checksum    string
+	ChunkSize   int64
+	ChunkType   string
+}
+
+type tarSplitData struct {
+	compressed          *bytes.Buffer
+	digester            digest.Digester
+	uncompressedCounter *ioutils.WriteCounter
+	zstd                *zstd.Encoder
+	packer              storage.Packer
+}
+
+func newTarSplitData(level int) (*tarSplitData, error) {
+	compressed := bytes.NewBuffer(nil)
+	digester := digest.Canonical.Digester()
+
+	zstdWriter, err := internal.ZstdWriterWithLevel(io.MultiWriter(compressed, digester.Hash()), level)
+	if err != nil {
+		return nil, err
+	}
+
+	uncompressedCounter := ioutils.NewWriteCounter(zstdWriter)
+	metaPacker := storage.NewJSONPacker(uncompressedCounter)
+
+	return &tarSplitData{
+		compressed:          compressed,
+		digester:            digester,
+		uncompressedCounter: uncompressedCounter,
+		zstd:                zstdWriter,
+		packer:              metaPacker,
+	}, nil
+}
+
+// frameWriter compresses the data written to it as a sequence of zstd frames.
+// The frames are identified by the number of frames ended before them, their
+// offsets in the output are known only once all of them are written.
+type frameWriter interface {
+	io.Writer
+	// endFrame ends the current frame and returns the number of frames
+	// ended so far.
+	endFrame() (int64, error)
+	// close ends the last frame, waits for all the frames to be written
+	// and returns the offset of the end of each frame.
+	close() ([]int64, error)
+}
+
+// newFrameWriter returns a frameWriter writing to dest, which compresses up
+// to threads frames concurrently.
+func newFrameWriter(dest *ioutils.WriteCounter, level, threads int) (frameWriter, error) {
+	if threads <= 1 {
+		encoder, err := internal.ZstdWriterWithLevel(dest, level)
+		if err != nil {
+			return nil, err
+		}
+		return &streamFrameWriter{dest: dest, encoder: encoder}, nil
+	}
+	encoder, err := zstd.NewWriter(nil,
+		zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)),
+		zstd.WithEncoderConcurrency(threads),
+		zstd.WithZeroFrames(true))
+	if err != nil {
+		return nil, err
+	}
+	w := &parallelFrameWriter{
+		dest:    dest,
+		encoder: encoder,
+		frames:  make(chan *pendingFrame, threads),
+		done:    make(chan error, 1),
+	}
+	go w.writeFrames()
+	return w, nil
+}
+
+// streamFrameWriter compresses the frames in the calling goroutine.
+type streamFrameWriter struct {
+	dest    *ioutils.WriteCounter
+	encoder *zstd.Encoder
+	ends    []int64
+}
+
+func (w *streamFrameWriter) Write(p []byte) (int, error) {
+	return w.encoder.Write(p)
+}
+
+func (w *streamFrameWriter) endFrame() (int64, error) {
+	if err := w.encoder.Close(); err != nil {
+		return 0, err
+	}
+	w.ends = append(w.ends, w.dest.Count)
+	w.encoder.Reset(w.dest)
+	return int64(len(w.ends)), nil
+}
+
+func (w *streamFrameWriter) close() ([]int64, error) {
+	if err := w.encoder.Flush(); err != nil {
+		w.encoder.Close()
+		return nil, err
+	}
+	if err := w.encoder.Close(); err != nil {
+		return nil, err
+	}
+	w.ends = append(w.ends, w.dest.Count)
+	return w.ends, nil
+}
+
+// pendingFrame is a frame being compressed, data is set once done is closed.
+type pendingFrame struct {
+	data []byte
+	done chan struct{}
+}
+
+// parallelFrameWriter compresses each frame in its own goroutine, and writes
+// the compressed frames in order from another one.
+type parallelFrameWriter struct {
+	dest    *ioutils.WriteCounter
+	encoder *zstd.Encoder
+	current []byte
+	nframes int64
+	frames  chan *pendingFrame
+	ends    []int64
+	done    chan error
+}
+
+func (w *parallelFrameWriter) Write(p []byte) (int, error) {
+	w.current = append(w.current, p...)
+	return len(p), nil
+}
+
+func (w *parallelFrameWriter) endFrame() (int64, error) {
+	frame := &pendingFrame{done: make(chan struct{})}
+	data := w.current
+	w.current = nil
+	go func() {
+		frame.data = w.encoder.EncodeAll(data, nil)
+		close(frame.done)
+	}()
+	w.frames <- frame
+	w.nframes++
+	return w.nframes, nil
+}
+
+// writeFrames writes the frames in the order they were ended.  After an
+// error it keeps consuming them so that endFrame never blocks.
+func (w *parallelFrameWriter) writeFrames() {
+	var err error
+	for frame := range w.frames {
+		<-frame.done
+		if err == nil {
+			_, err = w.dest.Write(frame.data)
+		}
+		w.ends = append(w.ends, w.dest.Count)
+	}
+	w.done <- err
+}
+
+func (w *parallelFrameWriter) close() ([]int64, error) {
+	_, _ = w.endFrame()
+	close(w.frames)
+	err := <-w.done
+	if errClose := w.encoder.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w.ends, nil
+}
+
+func writeZstdChunkedStream(destFile io.Writer, outMetadata map[string]string, reader io.Reader, level, threads int) error {
+	// total written so far.  Used to retrieve partial offsets in the file
+	dest := ioutils.NewWriteCounter(destFile)
+
+	tarSplitData, err := newTarSplitData(level)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if tarSplitData.zstd != nil {
+			tarSplitData.zstd.Close()
+		}
+	}()
+
+	its, err := asm.NewInputTarStream(reader, tarSplitData.packer, nil)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(its)
+	tr.RawAccounting = true
+
+	buf := make([]byte, 4096)
+
+	// The offsets recorded in the metadata are the numbers of frames ended
+	// before them, they are resolved to offsets in dest once all the
+	// frames are written.
+	zstdWriter, err := newFrameWriter(dest, level, threads)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if zstdWriter != nil {
+			_, _ = zstdWriter.close()
+		}
+	}()
+
+	restartCompression := zstdWriter.endFrame
+
+	var metadata []internal.FileMetadata
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		rawBytes := tr.RawBytes()
+		if _, err := zstdWriter.Write(rawBytes); err != nil {
+			return err
+		}
+
+		payloadDigester := digest.Canonical.Digester()
+		chunkDigester := digest.Canonical.Digester()
+
+		// Now handle the payload, if any
+		startOffset := int64(0)
+		lastOffset := int64(0)
+		lastChunkOffset := int64(0)
+
+		checksum := ""
+
+		chunks := []chunk{}
+
+		hf := &holesFinder{
+			threshold: holesThreshold,
+			reader:    bufio.NewReader(tr),
+		}
+
+		rcReader := &rollingChecksumReader{
+			reader:  hf,
+			rollsum: NewRollSum(),
+		}
+
+		payloadDest := io.MultiWriter(payloadDigester.Hash(), chunkDigester.Hash(), zstdWriter)
+		for {
+			mustSplit, read, errRead := rcReader.Read(buf)
+			if errRead != nil && errRead != io.EOF {
+				return err
+			}
+			// restart the compression only if there is a payload.
+			if read > 0 {
+				if startOffset == 0 {
+					startOffset, err = restartCompression()
+					if err != nil {
+						return err
+					}
+					lastOffset = startOffset
+				}
+
+				if _, err := payloadDest.Write(buf[:read]); err != nil {
+					return err
+				}
+			}
+			if (mustSplit || errRead == io.EOF) && startOffset > 0 {
+				off, err := restartCompression()
+				if err != nil {
+					return err
+				}
+
+				chunkSize := rcReader.WrittenOut - lastChunkOffset
+				if chunkSize > 0 {
+					chunkType := internal.ChunkTypeData
+					if rcReader.IsLastChunkZeros {
+						chunkType = internal.ChunkTypeZeros
+					}
+
+					chunks = append(chunks, chunk{
+						ChunkOffset: lastChunkOffset,
+						Offset:      lastOffset,
+						Checksum:    chunkDigester.Digest().String(),
+						ChunkSize:   chunkSize,
+						ChunkType:   chunkType,
+					})
+				}
+
+				lastOffset = off
+				lastChunkOffset = rcReader.WrittenOut
+				chunkDigester = digest.Canonical.Digester()
+				payloadDest = io.MultiWriter(payloadDigester.Hash(), chunkDigester.Hash(), zstdWriter)
+			}
+			if errRead == io.EOF {
+				if startOffset > 0 {
+					checksum = payloadDigester.Digest().String()
+				}
+				break
+			}
+		}
+
+		typ, err := internal.GetType(hdr.Typeflag)
+		if err != nil {
+			return err
+		}
+		xattrs := make(map[string]string)
+		for k, v := range hdr.Xattrs {
+			xattrs[k] = base64.StdEncoding.EncodeToString([]byte(v))
+		}
+		entries := []internal.FileMetadata{
+			{
+				Type:       typ,
+				Name:       hdr.Name,
+				Linkname:   hdr.Linkname,
+				Mode:       hdr.Mode,
+				Size:       hdr.Size,
+				UID:        hdr.Uid,
+				GID:        hdr.Gid,
+				ModTime:    &hdr.ModTime,
+				AccessTime: &hdr.AccessTime,
+				ChangeTime: &hdr.ChangeTime,
+				Devmajor:   hdr.Devmajor,
+				Devminor:   hdr.Devminor,
+				Xattrs:     xattrs,
+				Digest:     checksum,
+				Offset:     startOffset,
+				EndOffset:  lastOffset,
+			},
+		}
+		for i := 1; i < len(chunks); i++ {
+			entries = append(entries, internal.FileMetadata{
+				Type:        internal.TypeChunk,
+				Name:        hdr.Name,
+				ChunkOffset: chunks[i].ChunkOffset,
+			})
+		}
+		if len(chunks) > 1 {
+			for i := range chunks {
+				entries[i].ChunkSize = chunks[i].ChunkSize
+				entries[i].Offset = chunks[i].Offset
+				entries[i].ChunkDigest = chunks[i].Checksum
+				entries[i].ChunkType = chunks[i].ChunkType
+			}
+		} else if len(chunks) == 1 && chunks[0].ChunkType == internal.ChunkTypeZeros {
+			// a file made only of zeros is a single hole, record it so that
+			// it is recreated as a hole instead of being fetched.
+			entries[0].ChunkSize = chunks[0].ChunkSize
+			entries[0].ChunkType = chunks[0].ChunkType
+		}
+		metadata = append(metadata, entries...)
+	}
+
+	rawBytes := tr.RawBytes()
+	if _, err := zstdWriter.Write(rawBytes); err != nil {
+		return err
+	}
+
+	// make sure the entire tarball is flushed to the output as it might contain
+	// some trailing zeros that affect the checksum.
+	if _, err := io.Copy(zstdWriter, its); err != nil {
+		return err
+	}
+
+	frameEnds, err := zstdWriter.close()
+	zstdWriter = nil
+	if err != nil {
+		return err
+	}
+	frameOffset := func(frames int64) int64 {
+		if frames == 0 {
+			return 0
+		}
+		return frameEnds[frames-1]
+	}
+	for i := range metadata {
+		metadata[i].Offset = frameOffset(metadata[i].Offset)
+		metadata[i].EndOffset = frameOffset(metadata[i].EndOffset)
+	}
+
+	if err := tarSplitData.zstd.Flush(); err != nil {
+		return err
+	}
+	if err := tarSplitData.zstd.Close(); err != nil {
+		return err
+	}
+	tarSplitData.zstd = nil
+
+	ts := internal.TarSplitData{
+		Data:             tarSplitData.compressed.Bytes(),
+		Digest:           tarSplitData.digester.Digest(),
+		UncompressedSize: tarSplitData.uncompressedCounter.Count,
+	}
+
+	return internal.WriteZstdChunkedManifest(dest, outMetadata, uint64(dest.Count), &ts, metadata, level)
+}
+
+type zstdChunkedWriter struct {
+	tarSplitOut *io.PipeWriter
+	tarSplitErr chan error
+}
+
+func (w zstdChunkedWriter) Close() error {
+	errClose := w.tarSplitOut.Close()
+
+	if err := <-w.tarSplitErr; err != nil && err != io.EOF {
+		return err
+	}
+	return errClose
+}
+
+func (w zstdChunkedWriter) Write(p []byte) (int, error) {
+	select {
+	case err := <-w.tarSplitErr:
+		w.tarSplitOut.Close()
+		return 0, err
+	default:
+		return w.tarSplitOut.Write(p)
+	}
+}
+
+// zstdChunkedWriterWithLevel writes a zstd compressed tarball where each file is
+// compressed separately so it can be addressed separately.  Idea based on CRFS:
+// https://github.com/google/crfs
+// The difference with CRFS is that the zstd compression is used instead of gzip.
+// The reason for it is that zstd supports embedding metadata ignored by the decoder
+// as part of the compressed stream.
+// A manifest json file with all the metadata is appended at the end of the tarball
+// stream, using zstd skippable frames.
+// The final file will look like:
+// [FILE_1][FILE_2]..[FILE_N][SKIPPABLE FRAME 1][SKIPPABLE FRAME 2]
+// Where:
+// [FILE_N]: [ZSTD HEADER][TAR HEADER][PAYLOAD FILE_N][ZSTD FOOTER]
+// [SKIPPABLE FRAME 1]: [ZSTD SKIPPABLE FRAME, SIZE=MANIFEST LENGTH][MANIFEST]
+// [SKIPPABLE FRAME 2]: [ZSTD SKIPPABLE FRAME, SIZE=16][MANIFEST_OFFSET][MANIFEST_LENGTH][MANIFEST_LENGTH_UNCOMPRESSED][MANIFEST_TYPE][CHUNKED_ZSTD_MAGIC_NUMBER]
+// MANIFEST_OFFSET, MANIFEST_LENGTH, MANIFEST_LENGTH_UNCOMPRESSED and CHUNKED_ZSTD_MAGIC_NUMBER are 64 bits unsigned in little endian format.
+// The files are compressed by up to threads goroutines.
+func zstdChunkedWriterWithLevel(out io.Writer, metadata map[string]string, level, threads int) (io.WriteCloser, error) {
+	ch := make(chan error, 1)
+	r, w := io.Pipe()
+
+	go func() {
+		ch <- writeZstdChunkedStream(out, metadata, r, level, threads)
+		_, _ = io.Copy(io.Discard, r) // Ordinarily writeZstdChunkedStream consumes all of r. If it fails, ensure the write end never blocks and eventually terminates.
+		r.Close()
+		close(ch)
+	}()
+
+	return zstdChunkedWriter{
+		tarSplitOut: w,
+		tarSplitErr: ch,
+	}, nil
+}
+
+// ZstdCompressor is a CompressorFunc for the zstd compression algorithm.
+func ZstdCompressor(r io.Writer, metadata map[string]string, level *int) (io.WriteCloser, error) {
+	return ZstdCompressorWithThreads(r, metadata, level, 1)
+}
+
+// ZstdCompressorWithThreads is like ZstdCompressor, but compresses the files
+// of the tarball with up to threads goroutines.
+func ZstdCompressorWithThreads(r io.Writer, metadata map[string]string, level *int, threads int) (io.WriteCloser, error) {
+	if level == nil {
+		l := 10
+		level = &l
+	}
+
+	return zstdChunkedWriterWithLevel(r, metadata, *level, threads)
+}
@@ -0,0 +1,203 @@
+package chunked
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	digest "github.com/opencontainers/go-digest"
+	"golang.org/x/sys/unix"
+)
+
+// convertCacheMaxAge is how long a converted blob stays in the cache once it
+// is not used anymore.
+const convertCacheMaxAge = 7 * 24 * time.Hour
+
+const (
+	convertCacheBlob     = "blob"
+	convertCacheMetadata = "metadata.json"
+	convertCacheTmp      = ".tmp-"
+)
+
+// convertCache is a directory holding the zstd:chunked conversions of the
+// blobs pulled with convert_images, by the digest of the original blob, so
+// that a blob pulled again is neither downloaded nor converted again.  Every
+// entry is a directory holding the converted blob and its metadata, created
+// with a rename so that a partial entry is never used.  An entry is verified
+// against its metadata every time it is used.  An empty convertCache is
+// disabled.
+type convertCache string
+
+// convertCacheEntry is the metadata of a converted blob.
+type convertCacheEntry struct {
+	// Digest is the digest of the converted blob.
+	Digest      digest.Digest     `json:"digest"`
+	DiffID      digest.Digest     `json:"diffID"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// newConvertCache returns the cache of the store with the given graph root.
+func newConvertCache(graphRoot string) convertCache {
+	return convertCache(filepath.Join(graphRoot, "cache", "zstd-chunked"))
+}
+
+// entry returns the directory of the conversion of blobDigest.
+func (c convertCache) entry(blobDigest digest.Digest) string {
+	return filepath.Join(string(c), blobDigest.Algorithm().String()+"-"+blobDigest.Encoded())
+}
+
+// lookup returns the conversion of blobDigest, if the cache has it and the
+// digest of the converted blob, and of the tarball it decompresses to, match
+// the ones recorded when it was stored.  An entry that does not match is
+// removed.
+func (c convertCache) lookup(blobDigest digest.Digest, logger Logger) (*seekableFile, digest.Digest, map[string]string, bool) {
+	if c == "" || blobDigest.Validate() != nil {
+		return nil, "", nil, false
+	}
+	entry := c.entry(blobDigest)
+	data, err := os.ReadFile(filepath.Join(entry, convertCacheMetadata))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Debugf("Could not read the conversion of %s from the cache: %v", blobDigest, err)
+		}
+		return nil, "", nil, false
+	}
+	var metadata convertCacheEntry
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		logger.Debugf("Could not parse the conversion of %s in the cache: %v", blobDigest, err)
+		return nil, "", nil, false
+	}
+	f, err := os.Open(filepath.Join(entry, convertCacheBlob))
+	if err != nil {
+		logger.Debugf("Could not open the conversion of %s in the cache: %v", blobDigest, err)
+		return nil, "", nil, false
+	}
+	if err := verifyConvertCacheEntry(f, &metadata); err != nil {
+		f.Close()
+		logger.Warnf("Removing the invalid conversion of %s from the cache: %v", blobDigest, err)
+		if err := os.RemoveAll(entry); err != nil {
+			logger.Debugf("Could not remove the conversion of %s from the cache: %v", blobDigest, err)
+		}
+		return nil, "", nil, false
+	}
+	// The entries that are used are not pruned.
+	now := time.Now()
+	if err := os.Chtimes(entry, now, now); err != nil {
+		logger.Debugf("Could not mark the conversion of %s in the cache as used: %v", blobDigest, err)
+	}
+	logger.Debugf("Using the conversion of %s from the cache", blobDigest)
+	return &seekableFile{file: f}, metadata.DiffID, metadata.Annotations, true
+}
+
+// store adds the conversion of blobDigest to the cache, and prunes the
+// entries that were not used recently.  The file of the conversion is linked
+// into the cache, so it must be on the same file system.  Failures are only
+// logged, the cache is an optimization.
+func (c convertCache) store(blobDigest digest.Digest, file *seekableFile, diffID digest.Digest, annotations map[string]string, logger Logger) {
+	if c == "" || blobDigest.Validate() != nil {
+		return
+	}
+	if err := c.storeEntry(blobDigest, file, diffID, annotations); err != nil {
+		logger.Debugf("Could not add the conversion of %s to the cache: %v", blobDigest, err)
+	}
+	c.prune(convertCacheMaxAge, logger)
+}
+
+// verifyConvertCacheEntry checks that the converted blob in f has the digest
+// recorded in metadata, and that it decompresses to a tarball with the
+// recorded DiffID.  The zstd decoder skips the frames holding the TOC and
+// the tar-split, so both digests are computed in a single read.
+func verifyConvertCacheEntry(f *os.File, metadata *convertCacheEntry) error {
+	if metadata.Digest.Validate() != nil || metadata.DiffID.Validate() != nil {
+		return fmt.Errorf("invalid digests %q and %q", metadata.Digest, metadata.DiffID)
+	}
+	blobDigester := metadata.Digest.Algorithm().Digester()
+	diffIDDigester := metadata.DiffID.Algorithm().Digester()
+	r := io.TeeReader(io.NewSectionReader(f, 0, 1<<63-1), blobDigester.Hash())
+	decoder, err := zstd.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer decoder.Close()
+	if _, err := io.Copy(diffIDDigester.Hash(), decoder); err != nil {
+		return fmt.Errorf("decompressing: %w", err)
+	}
+	// Hash whatever the decoder did not need to read.
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return err
+	}
+	if got := blobDigester.Digest(); got != metadata.Digest {
+		return fmt.Errorf("blob digest %s, expected %s", got, metadata.Digest)
+	}
+	if got := diffIDDigester.Digest(); got != metadata.DiffID {
+		return fmt.Errorf("uncompressed digest %s, expected %s", got, metadata.DiffID)
+	}
+	return nil
+}
+
+func (c convertCache) storeEntry(blobDigest digest.Digest, file *seekableFile, diffID digest.Digest, annotations map[string]string) error {
+	blobDigester := digest.Canonical.Digester()
+	if _, err := io.Copy(blobDigester.Hash(), io.NewSectionReader(file.file, 0, 1<<63-1)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(string(c), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(string(c), convertCacheTmp)
+	if err != nil {
+		return err
+	}
+	// Once renamed, tmp does not exist anymore.
+	defer os.RemoveAll(tmp)
+
+	// The file can be an O_TMPFILE file, link it through /proc.
+	src := fmt.Sprintf("/proc/self/fd/%d", file.file.Fd())
+	if err := unix.Linkat(unix.AT_FDCWD, src, unix.AT_FDCWD, filepath.Join(tmp, convertCacheBlob), unix.AT_SYMLINK_FOLLOW); err != nil {
+		return fmt.Errorf("link converted blob: %w", err)
+	}
+	data, err := json.Marshal(convertCacheEntry{Digest: blobDigester.Digest(), DiffID: diffID, Annotations: annotations})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, convertCacheMetadata), data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.entry(blobDigest)); err != nil {
+		// Another pull added the same blob meanwhile.
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// prune removes the entries, and the leftovers of interrupted stores, that
+// were not used for maxAge.
+func (c convertCache) prune(maxAge time.Duration, logger Logger) {
+	entries, err := os.ReadDir(string(c))
+	if err != nil {
+		logger.Debugf("Could not prune the cache of converted blobs: %v", err)
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+		path := filepath.Join(string(c), e.Name())
+		if err := os.RemoveAll(path); err != nil {
+			logger.Debugf("Could not prune %s from the cache of converted blobs: %v", path, err)
+		} else if !strings.HasPrefix(e.Name(), convertCacheTmp) {
+			logger.Debugf("Pruned %s from the cache of converted blobs", e.Name())
+		}
+	}
+}
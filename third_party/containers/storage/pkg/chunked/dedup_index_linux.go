@@ -0,0 +1,49 @@
+package chunked
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/containers/storage/pkg/chunked/internal"
+)
+
+// dedupLocations returns the locations $OFFSET@$PATH of the files and chunks
+// in the lookaside cache, by digest, as they are added to the dedup index.
+func (m *metadata) dedupLocations() map[string][]byte {
+	locations := make(map[string][]byte)
+	nElements := len(m.tags) / m.tagLen
+	for n := 0; n < nElements; n++ {
+		tag := m.tags[n*m.tagLen : (n+1)*m.tagLen]
+		digest := string(tag[:m.digestLen])
+		parts := strings.Split(string(tag[m.digestLen:]), "@")
+		off, _ := strconv.ParseInt(parts[0], 10, 64)
+		len, _ := strconv.ParseInt(parts[1], 10, 64)
+		locations[digest] = m.vdata[off : off+len]
+	}
+	return locations
+}
+
+// dedupDigests returns the digests of the files and chunks that are looked up
+// in the other layers when the entries are deduplicated.
+func dedupDigests(entries []internal.FileMetadata, useHardLinks bool) []string {
+	var digests []string
+	for i := range entries {
+		e := &entries[i]
+		if e.Type != internal.TypeReg {
+			continue
+		}
+		if useHardLinks {
+			if d, err := calculateHardLinkFingerprint(e); err == nil {
+				digests = append(digests, d)
+			}
+		} else if e.Digest != "" {
+			digests = append(digests, e.Digest)
+		}
+		for _, chunk := range e.Chunks {
+			if chunk.ChunkDigest != "" {
+				digests = append(digests, chunk.ChunkDigest)
+			}
+		}
+	}
+	return digests
+}
@@ -0,0 +1,301 @@
+package chunked
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/containers/storage/pkg/chunked/internal"
+	"github.com/klauspost/compress/zstd"
+	digest "github.com/opencontainers/go-digest"
+	"golang.org/x/sys/unix"
+)
+
+// DedupSource is a content-addressed store the differ can copy the files of
+// a layer from instead of fetching them.
+type DedupSource interface {
+	// Lookup returns the path of the file with digest d in the store, or
+	// an empty string if the store does not have it.  The differ checks
+	// the size and the digest of the file before it uses its content.
+	Lookup(d digest.Digest) (string, error)
+}
+
+// dedupSourceOpener is implemented by the dedup sources that do not store
+// the files as they are, and must extract them before they can be used.
+type dedupSourceOpener interface {
+	// open returns an anonymous file created in the directory dirfd with
+	// the content of the file with digest d, or nil if the source does
+	// not have it.
+	open(d digest.Digest, dirfd int) (*os.File, error)
+}
+
+// DedupSourceFactory creates the DedupSource stored at path.
+type DedupSourceFactory func(path string) (DedupSource, error)
+
+var (
+	dedupSourceFactoriesLock sync.Mutex
+	dedupSourceFactories     = map[string]DedupSourceFactory{
+		"ostree": func(path string) (DedupSource, error) { return ostreeRepo(path), nil },
+		"dir":    func(path string) (DedupSource, error) { return digestDir(path), nil },
+		"oci":    func(path string) (DedupSource, error) { return &ociLayout{path: path}, nil },
+	}
+)
+
+// RegisterDedupSource makes the stores of the given kind usable in the
+// dedup_sources pull option, which lists kind=path pairs separated by colons.
+// The kinds "ostree", "dir" and "oci" are built in.
+func RegisterDedupSource(kind string, factory DedupSourceFactory) error {
+	if kind == "" || strings.ContainsAny(kind, "=:") {
+		return fmt.Errorf("invalid dedup source kind %q", kind)
+	}
+	dedupSourceFactoriesLock.Lock()
+	defer dedupSourceFactoriesLock.Unlock()
+	if _, ok := dedupSourceFactories[kind]; ok {
+		return fmt.Errorf("dedup source kind %q is already registered", kind)
+	}
+	dedupSourceFactories[kind] = factory
+	return nil
+}
+
+// parseDedupSources returns the sources configured by the ostree_repos and
+// dedup_sources pull options, in this order.
+func parseDedupSources(ostreeRepos, sources string) ([]DedupSource, error) {
+	var r []DedupSource
+	for _, repo := range strings.Split(ostreeRepos, ":") {
+		if repo != "" {
+			r = append(r, ostreeRepo(repo))
+		}
+	}
+	dedupSourceFactoriesLock.Lock()
+	defer dedupSourceFactoriesLock.Unlock()
+	for _, source := range strings.Split(sources, ":") {
+		if source == "" {
+			continue
+		}
+		kind, path, ok := strings.Cut(source, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid dedup source %q: must be kind=path", source)
+		}
+		factory, ok := dedupSourceFactories[kind]
+		if !ok {
+			kinds := make([]string, 0, len(dedupSourceFactories))
+			for k := range dedupSourceFactories {
+				kinds = append(kinds, k)
+			}
+			sort.Strings(kinds)
+			return nil, fmt.Errorf("invalid dedup source %q: unknown kind %q, must be one of %s", source, kind, strings.Join(kinds, ", "))
+		}
+		s, err := factory(path)
+		if err != nil {
+			return nil, fmt.Errorf("dedup source %q: %w", source, err)
+		}
+		r = append(r, s)
+	}
+	return r, nil
+}
+
+// dedupSourceName returns the name source is counted under in the pull
+// statistics: the kind=path it is configured with for the built-in kinds.
+func dedupSourceName(source DedupSource) string {
+	if s, ok := source.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", source)
+}
+
+// ostreeRepo is an OSTree repository, whose objects hold the payload of
+// the files by their digest.
+type ostreeRepo string
+
+func (repo ostreeRepo) String() string {
+	return "ostree=" + string(repo)
+}
+
+func (repo ostreeRepo) Lookup(d digest.Digest) (string, error) {
+	payloadLink := d.Encoded() + ".payload-link"
+	if len(payloadLink) < 2 {
+		return "", nil
+	}
+	return filepath.Join(string(repo), "objects", payloadLink[:2], payloadLink[2:]), nil
+}
+
+// digestDir is a directory with the files stored by their digest as
+// DifferOutputFormatFlat does, e.g. a blob cache shared over NFS.
+type digestDir string
+
+func (dir digestDir) String() string {
+	return "dir=" + string(dir)
+}
+
+func (dir digestDir) Lookup(d digest.Digest) (string, error) {
+	encoded := d.Encoded()
+	if len(encoded) < 2 {
+		return "", nil
+	}
+	return filepath.Join(string(dir), encoded[:2], encoded[2:]), nil
+}
+
+// ociLayout is an OCI image layout.  The files are extracted from its
+// zstd:chunked layers, each one from its own range of the layer blob, so
+// the layers in other formats are not used.
+type ociLayout struct {
+	path string
+
+	once  sync.Once
+	files map[digest.Digest]ociLayoutFile
+	err   error
+}
+
+// ociLayoutFile is the range of a layer blob holding the compressed content
+// of a file.
+type ociLayoutFile struct {
+	blob   string
+	offset int64
+	length int64
+}
+
+// ociDescriptor and ociManifest hold the fields of the OCI descriptors,
+// image indexes and image manifests used to find the layers.
+type ociDescriptor struct {
+	Digest      digest.Digest     `json:"digest"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociManifest struct {
+	Manifests []ociDescriptor `json:"manifests,omitempty"`
+	Layers    []ociDescriptor `json:"layers,omitempty"`
+}
+
+// ociLayoutMaxDepth is the maximum nesting of the image indexes.
+const ociLayoutMaxDepth = 8
+
+func (l *ociLayout) String() string {
+	return "oci=" + l.path
+}
+
+// Lookup always returns an empty string, the files are read with open.
+func (l *ociLayout) Lookup(d digest.Digest) (string, error) {
+	return "", nil
+}
+
+func (l *ociLayout) open(d digest.Digest, dirfd int) (*os.File, error) {
+	l.once.Do(func() {
+		l.files = make(map[digest.Digest]ociLayoutFile)
+		l.err = l.indexManifest(filepath.Join(l.path, "index.json"), 0)
+	})
+	if l.err != nil {
+		return nil, l.err
+	}
+	file, ok := l.files[d]
+	if !ok {
+		return nil, nil
+	}
+
+	blob, err := os.Open(file.blob)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+	decoder, err := zstd.NewReader(io.NewSectionReader(blob, file.offset, file.length))
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+
+	fd, err := unix.Openat(dirfd, ".", unix.O_TMPFILE|unix.O_RDWR|unix.O_CLOEXEC, 0o600)
+	if err != nil {
+		return nil, &os.PathError{Op: "openat", Path: ".", Err: err}
+	}
+	f := os.NewFile(uintptr(fd), "fd")
+	if _, err := io.Copy(f, decoder); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// blobPath returns the path of the blob with digest d in the layout.
+func (l *ociLayout) blobPath(d digest.Digest) (string, error) {
+	if err := d.Validate(); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.path, "blobs", d.Algorithm().String(), d.Encoded()), nil
+}
+
+// indexManifest adds the files of the zstd:chunked layers referenced by the
+// image index or image manifest at path to l.files.
+func (l *ociLayout) indexManifest(path string, depth int) error {
+	if depth > ociLayoutMaxDepth {
+		return fmt.Errorf("%s: image indexes nested too deeply", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var manifest ociManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for _, desc := range manifest.Manifests {
+		p, err := l.blobPath(desc.Digest)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := l.indexManifest(p, depth+1); err != nil {
+			return err
+		}
+	}
+	for _, desc := range manifest.Layers {
+		if desc.Annotations[internal.ManifestChecksumKey] == "" {
+			continue
+		}
+		p, err := l.blobPath(desc.Digest)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := l.indexLayer(p, desc.Annotations); err != nil {
+			return fmt.Errorf("reading the TOC of %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// indexLayer adds the regular files of the zstd:chunked layer blob at path
+// to l.files.
+func (l *ociLayout) indexLayer(path string, annotations map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	manifest, _, _, err := readZstdChunkedManifest(&seekableFile{file: f}, st.Size(), annotations)
+	if err != nil {
+		return err
+	}
+	toc, err := unmarshalToc(manifest)
+	if err != nil {
+		return err
+	}
+	for _, e := range toc.Entries {
+		if e.Type != internal.TypeReg || e.Size == 0 || e.EndOffset <= e.Offset {
+			continue
+		}
+		d, err := digest.Parse(e.Digest)
+		if err != nil {
+			continue
+		}
+		if _, ok := l.files[d]; !ok {
+			l.files[d] = ociLayoutFile{blob: path, offset: e.Offset, length: e.EndOffset - e.Offset}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package chunked
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// fsVeritySignaturesAnnotation is the layer annotation holding the fs-verity
+// signatures of the files of the layer, as a JSON object mapping the digest
+// of each file to the base64 encoded PKCS#7 signature of its verity digest.
+const fsVeritySignaturesAnnotation = "io.github.containers.fsverity.signatures"
+
+// fsVeritySignatures are the sources of the signatures attached to the files
+// when fs-verity is enabled on them.  The signatures are checked by the
+// kernel, so they do not need to be trusted.
+type fsVeritySignatures struct {
+	// annotation holds the signatures from the layer annotation.
+	annotation map[digest.Digest][]byte
+	// dir is the fsverity_signatures directory, holding the signature of
+	// every file as $ALGORITHM/$ENCODED, e.g. sha256/0123...
+	dir string
+}
+
+// newFsVeritySignatures returns the sources of signatures of a layer, or nil
+// if there is none.
+func newFsVeritySignatures(annotations map[string]string, dir string) (*fsVeritySignatures, error) {
+	value, found := annotations[fsVeritySignaturesAnnotation]
+	if !found && dir == "" {
+		return nil, nil //nolint: nilnil
+	}
+	s := &fsVeritySignatures{dir: dir}
+	if found {
+		if err := json.Unmarshal([]byte(value), &s.annotation); err != nil {
+			return nil, fmt.Errorf("parse annotation %s: %w", fsVeritySignaturesAnnotation, err)
+		}
+	}
+	return s, nil
+}
+
+// lookup returns the signature of the file with the given digest, or nil if
+// there is none.
+func (s *fsVeritySignatures) lookup(fileDigest string) ([]byte, error) {
+	d, err := digest.Parse(fileDigest)
+	if err != nil {
+		return nil, nil
+	}
+	if signature, found := s.annotation[d]; found {
+		return signature, nil
+	}
+	if s.dir == "" {
+		return nil, nil
+	}
+	signature, err := os.ReadFile(filepath.Join(s.dir, d.Algorithm().String(), d.Encoded()))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return signature, nil
+}
@@ -0,0 +1,55 @@
+package chunked
+
+import "sync"
+
+// progressTracker holds the progress of ApplyDiff and reports every change
+// of it.  It is updated concurrently by the copy and fetch goroutines.
+type progressTracker struct {
+	lock     sync.Mutex
+	progress Progress
+	report   func(Progress)
+	// wholeBlob is set once the whole blob is fetched instead of the
+	// missing parts, which are then read from the local copy.
+	wholeBlob bool
+}
+
+// update applies f to the progress and reports the result.  The reports are
+// serialized so that they are received in order.  It does nothing on a nil
+// tracker, so that the callers need not check whether progress is reported.
+func (t *progressTracker) update(f func(p *Progress)) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	f(&t.progress)
+	t.report(t.progress)
+}
+
+// fetched reports that n bytes of the missing parts were fetched from the
+// source of the layer, unless they were read from the whole blob.
+func (t *progressTracker) fetched(n int64) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.wholeBlob {
+		return
+	}
+	t.progress.RemainingBytes -= n
+	t.report(t.progress)
+}
+
+// fetchingWholeBlob reports that the whole blob, of the given size, is
+// fetched instead of the missing parts that are left.
+func (t *progressTracker) fetchingWholeBlob(size int64) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.wholeBlob = true
+	t.progress.RemainingBytes = size
+	t.report(t.progress)
+}
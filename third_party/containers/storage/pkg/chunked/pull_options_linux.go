@@ -0,0 +1,125 @@
+package chunked
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// knownPullOptions are the pull options understood by the differ.
+var knownPullOptions = map[string]struct{}{
+	"enable_partial_images":      {},
+	"convert_images":             {},
+	"convert_images_zstd_level":  {},
+	"convert_images_threads":     {},
+	"convert_images_cache":       {},
+	"use_hard_links":             {},
+	"use_reflinks":               {},
+	"ostree_repos":               {},
+	"mode_normalization":         {},
+	"intermediate_dir_mode":      {},
+	"clamp_mtime":                {},
+	"clamp_atime":                {},
+	"whiteout_policy":            {},
+	"whiteout_allow":             {},
+	"disable_dedup":              {},
+	"metadata_delta":             {},
+	"partial_pull_concurrency":   {},
+	"partial_pull_max_bandwidth": {},
+	"partial_pull_retries":       {},
+	"partial_pull_retry_delay":   {},
+	"dedup_sources":              {},
+	"verify_tar_split":           {},
+	"fsverity_signatures":        {},
+	"chunk_provider":             {},
+}
+
+// pullOptionsSummary returns the settings in effect for the differ as
+// "name=value" pairs, with defaults applied to the pull options that are not
+// set.  Only the names of unknown pull options are reported since their
+// values could hold anything, including credentials.
+func (c *chunkedDiffer) pullOptionsSummary() []string {
+	ostreeRepos := c.storeOpts.PullOptions["ostree_repos"]
+	modeNormalization := c.storeOpts.PullOptions["mode_normalization"]
+	if modeNormalization == "" {
+		modeNormalization = "none"
+	}
+	dirMode := defaultIntermediateDirMode
+	if mode, err := parseIntermediateDirMode(c.storeOpts.PullOptions["intermediate_dir_mode"]); err == nil && mode != nil {
+		dirMode = *mode
+	}
+	clampMtime := c.storeOpts.PullOptions["clamp_mtime"]
+	if clampMtime == "" {
+		clampMtime = "none"
+	}
+	whiteoutPolicy := c.storeOpts.PullOptions["whiteout_policy"]
+	if whiteoutPolicy == "" {
+		whiteoutPolicy = "none"
+	}
+	concurrency, err := parsePartialPullConcurrency(c.storeOpts.PullOptions["partial_pull_concurrency"])
+	if err != nil {
+		concurrency = 1
+	}
+	convertLevel, err := parseConvertZstdLevel(c.storeOpts.PullOptions["convert_images_zstd_level"])
+	if err != nil {
+		convertLevel = 1
+	}
+	convertThreads, err := parseConvertThreads(c.storeOpts.PullOptions["convert_images_threads"])
+	if err != nil {
+		convertThreads = 1
+	}
+	maxBandwidth, err := parseMaxBandwidth(c.storeOpts.PullOptions["partial_pull_max_bandwidth"])
+	if err != nil {
+		maxBandwidth = 0
+	}
+	retries, err := parsePartialPullRetries(c.storeOpts.PullOptions["partial_pull_retries"])
+	if err != nil {
+		retries = defaultPartialPullRetries
+	}
+	retryDelay, err := parsePartialPullRetryDelay(c.storeOpts.PullOptions["partial_pull_retry_delay"])
+	if err != nil {
+		retryDelay = defaultPartialPullRetryDelay
+	}
+	summary := []string{
+		fmt.Sprintf("enable_partial_images=%t", parseBooleanPullOption(c.storeOpts, "enable_partial_images", true)),
+		fmt.Sprintf("convert_images=%t", parseBooleanPullOption(c.storeOpts, "convert_images", false)),
+		fmt.Sprintf("convert_images_zstd_level=%d", convertLevel),
+		fmt.Sprintf("convert_images_threads=%d", convertThreads),
+		fmt.Sprintf("convert_images_cache=%t", parseBooleanPullOption(c.storeOpts, "convert_images_cache", false)),
+		fmt.Sprintf("use_hard_links=%t", parseBooleanPullOption(c.storeOpts, "use_hard_links", false)),
+		fmt.Sprintf("use_reflinks=%t", parseBooleanPullOption(c.storeOpts, "use_reflinks", false)),
+		fmt.Sprintf("ostree_repos=%q", ostreeRepos),
+		fmt.Sprintf("dedup_sources=%q", c.storeOpts.PullOptions["dedup_sources"]),
+		fmt.Sprintf("mode_normalization=%s", modeNormalization),
+		fmt.Sprintf("intermediate_dir_mode=%#o", dirMode),
+		fmt.Sprintf("clamp_mtime=%s", clampMtime),
+		fmt.Sprintf("clamp_atime=%t", parseBooleanPullOption(c.storeOpts, "clamp_atime", false)),
+		fmt.Sprintf("whiteout_policy=%s", whiteoutPolicy),
+		fmt.Sprintf("whiteout_allow=%q", c.storeOpts.PullOptions["whiteout_allow"]),
+		fmt.Sprintf("disable_dedup=%t", parseBooleanPullOption(c.storeOpts, "disable_dedup", false)),
+		fmt.Sprintf("metadata_delta=%t", parseBooleanPullOption(c.storeOpts, "metadata_delta", false)),
+		fmt.Sprintf("partial_pull_concurrency=%d", concurrency),
+		fmt.Sprintf("partial_pull_max_bandwidth=%d", maxBandwidth),
+		fmt.Sprintf("partial_pull_retries=%d", retries),
+		fmt.Sprintf("partial_pull_retry_delay=%s", retryDelay),
+		fmt.Sprintf("verify_tar_split=%t", parseBooleanPullOption(c.storeOpts, "verify_tar_split", false)),
+		fmt.Sprintf("fsverity_signatures=%q", c.storeOpts.PullOptions["fsverity_signatures"]),
+		fmt.Sprintf("chunk_provider=%q", c.storeOpts.PullOptions["chunk_provider"]),
+		fmt.Sprintf("convert_to_zstd_chunked=%t", c.convertToZstdChunked),
+		fmt.Sprintf("workers=%d", copyGoRoutines),
+		fmt.Sprintf("copy_buffer_size=%d", len(c.copyBuffer)),
+		fmt.Sprintf("max_missing_chunks=%d", maxNumberMissingChunks),
+	}
+
+	var unknown []string
+	for name := range c.storeOpts.PullOptions {
+		if _, ok := knownPullOptions[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		summary = append(summary, fmt.Sprintf("unknown=%s", strings.Join(unknown, ",")))
+	}
+	return summary
+}
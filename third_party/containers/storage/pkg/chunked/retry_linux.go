@@ -0,0 +1,133 @@
+package chunked
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultPartialPullRetries    = 0
+	defaultPartialPullRetryDelay = time.Second
+)
+
+// parsePartialPullRetries parses the partial_pull_retries pull option, the
+// number of times a failed request of chunks of the layer is retried.  It
+// defaults to 0.
+func parsePartialPullRetries(value string) (int, error) {
+	if value == "" {
+		return defaultPartialPullRetries, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid partial_pull_retries %q: must be a non-negative integer", value)
+	}
+	return n, nil
+}
+
+// parsePartialPullRetryDelay parses the partial_pull_retry_delay pull option,
+// the delay before the first retry of a failed request, doubled before every
+// following retry.  It defaults to one second.
+func parsePartialPullRetryDelay(value string) (time.Duration, error) {
+	if value == "" {
+		return defaultPartialPullRetryDelay, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid partial_pull_retry_delay %q: must be a non-negative duration", value)
+	}
+	return d, nil
+}
+
+// isTransientError returns whether a failed request of chunks may succeed
+// when it is repeated: the connection failed or timed out, or the source
+// reported a temporary condition with ErrTransient.  Other errors, like a
+// denied authorization or a missing blob, are not retried.
+func isTransientError(err error) bool {
+	var transient ErrTransient
+	if errors.As(err, &transient) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	for _, e := range []error{io.ErrUnexpectedEOF, syscall.ECONNRESET, syscall.ECONNREFUSED, syscall.ECONNABORTED, syscall.EPIPE, syscall.ETIMEDOUT} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// getBlobAtWithRetries requests chunks from stream, retrying transient
+// failures up to retries times with an exponential backoff.  Waiting for a
+// retry ends early when the pull is canceled.  ErrBadRequest is not retried,
+// the caller merges the chunks instead.
+func (c *chunkedDiffer) getBlobAtWithRetries(stream ImageSourceSeekable, chunks []ImageSourceChunk, retries int) (chan io.ReadCloser, chan error, error) {
+	delay := c.retryDelay
+	for attempt := 0; ; attempt++ {
+		streams, errs, err := stream.GetBlobAt(chunks)
+		if err == nil {
+			return streams, errs, nil
+		}
+		if attempt >= retries || !isTransientError(err) {
+			return nil, nil, err
+		}
+		c.log().Debugf("Request of %d chunks failed, retrying in %s: %v", len(chunks), delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-c.context().Done():
+			timer.Stop()
+			return nil, nil, fmt.Errorf("%w (retrying after: %v)", c.context().Err(), err)
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// context returns the context of the pull, or the background context for a
+// differ that was not made by GetDiffer.
+func (c *chunkedDiffer) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// wholeBlob downloads the whole blob to a temporary file under dest, once,
+// and returns it.  The missing parts are read from it when the requests of
+// chunks of the blob keep failing.  Those requests were retried already, so
+// the download is not.  The file is closed by ApplyDiff.
+func (c *chunkedDiffer) wholeBlob(dest string) (*seekableFile, error) {
+	c.wholeBlobMutex.Lock()
+	defer c.wholeBlobMutex.Unlock()
+	if c.wholeBlobFile != nil {
+		return c.wholeBlobFile, nil
+	}
+
+	fd, err := unix.Open(dest, unix.O_TMPFILE|unix.O_RDWR|unix.O_CLOEXEC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	// Closing the file releases the file descriptor and deletes the file.
+	f := os.NewFile(uintptr(fd), "blob-file")
+	c.progress.fetchingWholeBlob(c.blobSize)
+	if _, err := c.copyAllBlobToFile(f, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	c.progress.update(func(p *Progress) {
+		p.RemainingBytes = 0
+	})
+	c.wholeBlobFile = &seekableFile{file: f}
+	return c.wholeBlobFile, nil
+}
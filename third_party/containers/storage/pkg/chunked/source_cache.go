@@ -0,0 +1,217 @@
+package chunked
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"io"
+	"sync"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// SourceCache is a bounded LRU cache of blob ranges fetched through the
+// sources wrapped with it.  A single cache can be shared by all the layers
+// pulled in a session so that ranges fetched for one image are not fetched
+// again for a related one.
+type SourceCache struct {
+	maxSize      uint64
+	maxEntrySize uint64
+
+	lock    sync.Mutex
+	size    uint64
+	lru     *list.List
+	entries map[digest.Digest][]*list.Element
+}
+
+type sourceCacheEntry struct {
+	blob  digest.Digest
+	chunk ImageSourceChunk
+	data  []byte
+}
+
+// NewSourceCache returns a cache holding up to maxSize bytes.  Ranges bigger
+// than a quarter of maxSize are never cached.
+func NewSourceCache(maxSize uint64) *SourceCache {
+	return &SourceCache{
+		maxSize:      maxSize,
+		maxEntrySize: maxSize / 4,
+		lru:          list.New(),
+		entries:      make(map[digest.Digest][]*list.Element),
+	}
+}
+
+// Wrap returns an ImageSourceSeekable for the blob with the given digest that
+// serves the requested chunks from the cache when possible and fetches the
+// remaining ones from src.
+func (c *SourceCache) Wrap(src ImageSourceSeekable, blobDigest digest.Digest) ImageSourceSeekable {
+	return &cachedSource{
+		cache:      c,
+		source:     src,
+		blobDigest: blobDigest,
+	}
+}
+
+// get returns the cached data for chunk of blob, if any range containing it
+// is cached
+func (c *SourceCache) get(blob digest.Digest, chunk ImageSourceChunk) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, elem := range c.entries[blob] {
+		entry := elem.Value.(*sourceCacheEntry)
+		if chunk.Offset >= entry.chunk.Offset && chunk.Offset+chunk.Length <= entry.chunk.Offset+entry.chunk.Length {
+			c.lru.MoveToFront(elem)
+			start := chunk.Offset - entry.chunk.Offset
+			return entry.data[start : start+chunk.Length], true
+		}
+	}
+	return nil, false
+}
+
+// add stores data for chunk of blob and evicts the least recently used
+// ranges to stay within the size limit
+func (c *SourceCache) add(blob digest.Digest, chunk ImageSourceChunk, data []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, elem := range c.entries[blob] {
+		if elem.Value.(*sourceCacheEntry).chunk == chunk {
+			c.lru.MoveToFront(elem)
+			return
+		}
+	}
+	for c.size+uint64(len(data)) > c.maxSize && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+	elem := c.lru.PushFront(&sourceCacheEntry{blob: blob, chunk: chunk, data: data})
+	c.entries[blob] = append(c.entries[blob], elem)
+	c.size += uint64(len(data))
+}
+
+func (c *SourceCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*sourceCacheEntry)
+	c.size -= uint64(len(entry.data))
+	elems := c.entries[entry.blob]
+	for i, e := range elems {
+		if e == elem {
+			elems = append(elems[:i], elems[i+1:]...)
+			break
+		}
+	}
+	if len(elems) == 0 {
+		delete(c.entries, entry.blob)
+	} else {
+		c.entries[entry.blob] = elems
+	}
+}
+
+type cachedSource struct {
+	cache      *SourceCache
+	source     ImageSourceSeekable
+	blobDigest digest.Digest
+}
+
+// GetBlobAt follows the contract of the wrapped source: the streams for the
+// chunks are sent in order, an error aborts the transfer and both channels
+// are closed at the end.  Errors returned directly by the wrapped source,
+// like ErrBadRequest, are passed through unchanged.
+func (s *cachedSource) GetBlobAt(chunks []ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	cached := make([][]byte, len(chunks))
+	hits := make([]bool, len(chunks))
+	var missing []ImageSourceChunk
+	for i, chunk := range chunks {
+		if cached[i], hits[i] = s.cache.get(s.blobDigest, chunk); hits[i] {
+			continue
+		}
+		missing = append(missing, chunk)
+	}
+
+	var srcStreams chan io.ReadCloser
+	var srcErrs chan error
+	if len(missing) > 0 {
+		var err error
+		srcStreams, srcErrs, err = s.source.GetBlobAt(missing)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	streams := make(chan io.ReadCloser)
+	errs := make(chan error)
+	go func() {
+		defer close(streams)
+		defer close(errs)
+		defer drainSource(srcStreams, srcErrs)
+
+		for i, chunk := range chunks {
+			if hits[i] {
+				streams <- io.NopCloser(bytes.NewReader(cached[i]))
+				continue
+			}
+
+			part, err := receivePart(srcStreams, srcErrs)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if part == nil {
+				errs <- errors.New("not enough data returned from the server")
+				return
+			}
+			if chunk.Length > s.cache.maxEntrySize {
+				streams <- part
+				continue
+			}
+			data, err := io.ReadAll(part)
+			part.Close()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if uint64(len(data)) != chunk.Length {
+				errs <- errors.New("invalid chunk length returned by the source")
+				return
+			}
+			s.cache.add(s.blobDigest, chunk, data)
+			streams <- io.NopCloser(bytes.NewReader(data))
+		}
+	}()
+	return streams, errs, nil
+}
+
+// receivePart returns the next stream of the source.  It returns a nil stream
+// and a nil error once the source has no more streams.
+func receivePart(streams chan io.ReadCloser, errs chan error) (io.ReadCloser, error) {
+	for {
+		select {
+		case part, ok := <-streams:
+			if !ok {
+				return nil, nil
+			}
+			return part, nil
+		case err, ok := <-errs:
+			if !ok {
+				// a nil channel blocks, so only streams is left
+				errs = nil
+				continue
+			}
+			return nil, err
+		}
+	}
+}
+
+// drainSource consumes whatever the source still sends so that its producer
+// can terminate
+func drainSource(streams chan io.ReadCloser, errs chan error) {
+	if streams == nil {
+		return
+	}
+	go func() {
+		for part := range streams {
+			part.Close()
+		}
+	}()
+	go func() {
+		for range errs { //nolint:revive
+		}
+	}()
+}
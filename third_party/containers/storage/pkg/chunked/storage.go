@@ -0,0 +1,149 @@
+package chunked
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	graphdriver "github.com/containers/storage/drivers"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// ImageSourceChunk is a portion of a blob.
+type ImageSourceChunk struct {
+	Offset uint64
+	Length uint64
+}
+
+// ImageSourceSeekable is an image source that permits to fetch chunks of the entire blob.
+type ImageSourceSeekable interface {
+	// GetBlobAt returns a stream for the specified blob.
+	GetBlobAt([]ImageSourceChunk) (chan io.ReadCloser, chan error, error)
+}
+
+// ErrBadRequest is returned when the request is not valid
+type ErrBadRequest struct { //nolint: errname
+}
+
+func (e ErrBadRequest) Error() string {
+	return "bad request"
+}
+
+// ErrTransient is returned by GetBlobAt when the request failed for a reason
+// that may go away when it is repeated, e.g. the registry is overloaded.
+type ErrTransient struct { //nolint: errname
+	Err error
+}
+
+func (e ErrTransient) Error() string {
+	return e.Err.Error()
+}
+
+func (e ErrTransient) Unwrap() error {
+	return e.Err
+}
+
+// Logger receives the log messages of a differ.  logrus.FieldLogger
+// satisfies it, so a caller can attach the layer it pulls to every message.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx that makes GetDiffer return a differ
+// logging to l instead of the standard logrus logger.
+func WithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// loggerFromContext returns the logger set with WithLogger, or the standard
+// logrus logger.
+func loggerFromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(loggerKey{}).(Logger); ok && l != nil {
+		return l
+	}
+	return logrus.StandardLogger()
+}
+
+// StatsReporter receives the statistics of the partial pull of the layer
+// with digest blobDigest, once the differ applied it.
+type StatsReporter func(blobDigest digest.Digest, stats *graphdriver.PullStats)
+
+type statsReporterKey struct{}
+
+// WithStatsReporter returns a copy of ctx that makes the differs returned by
+// GetDiffer report their statistics to r.
+func WithStatsReporter(ctx context.Context, r StatsReporter) context.Context {
+	return context.WithValue(ctx, statsReporterKey{}, r)
+}
+
+// statsReporterFromContext returns the reporter set with WithStatsReporter,
+// or nil.
+func statsReporterFromContext(ctx context.Context) StatsReporter {
+	r, _ := ctx.Value(statsReporterKey{}).(StatsReporter)
+	return r
+}
+
+// Progress is the progress of the partial pull of a layer.
+type Progress struct {
+	// Files is the number of regular files of the layer with some content.
+	Files int
+	// ReusedFiles is the number of those files copied so far from files
+	// already on the host instead of being fetched.
+	ReusedFiles int
+	// RemainingBytes is the size of the data still to be fetched from
+	// the source of the layer.  It is set once all the files were looked
+	// up on the host.  If the requests of the missing data keep failing,
+	// it is the size of the whole blob until the blob is fetched.
+	RemainingBytes int64
+	// Done is set in the last report, once the layer was pulled.
+	Done bool
+}
+
+// ProgressReporter receives the progress of the partial pull of the layer
+// with digest blobDigest every time it changes.  It is called from the
+// goroutines of the differ, one call at a time, and it must not block.
+type ProgressReporter func(blobDigest digest.Digest, progress Progress)
+
+// ThrottleProgress returns a ProgressReporter that passes the progress of
+// every layer to r at most once per interval, and always passes the last
+// report of a layer.
+func ThrottleProgress(interval time.Duration, r ProgressReporter) ProgressReporter {
+	var lock sync.Mutex
+	last := make(map[digest.Digest]time.Time)
+	return func(blobDigest digest.Digest, progress Progress) {
+		lock.Lock()
+		defer lock.Unlock()
+		now := time.Now()
+		if !progress.Done && now.Sub(last[blobDigest]) < interval {
+			return
+		}
+		if progress.Done {
+			delete(last, blobDigest)
+		} else {
+			last[blobDigest] = now
+		}
+		r(blobDigest, progress)
+	}
+}
+
+type progressReporterKey struct{}
+
+// WithProgressReporter returns a copy of ctx that makes the differs returned
+// by GetDiffer report their progress to r.
+func WithProgressReporter(ctx context.Context, r ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressReporterKey{}, r)
+}
+
+// progressReporterFromContext returns the reporter set with
+// WithProgressReporter, or nil.
+func progressReporterFromContext(ctx context.Context) ProgressReporter {
+	r, _ := ctx.Value(progressReporterKey{}).(ProgressReporter)
+	return r
+}
@@ -0,0 +1,3130 @@
+package chunked
+
+import (
+	archivetar "archive/tar"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	storage "github.com/containers/storage"
+	graphdriver "github.com/containers/storage/drivers"
+	driversCopy "github.com/containers/storage/drivers/copy"
+	"github.com/containers/storage/pkg/archive"
+	"github.com/containers/storage/pkg/chunked/compressor"
+	"github.com/containers/storage/pkg/chunked/internal"
+	"github.com/containers/storage/pkg/fsverity"
+	"github.com/containers/storage/pkg/idtools"
+	"github.com/containers/storage/pkg/system"
+	"github.com/containers/storage/types"
+	securejoin "github.com/cyphar/filepath-securejoin"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+	"github.com/vbatts/tar-split/archive/tar"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	maxNumberMissingChunks  = 1024
+	newFileFlags            = (unix.O_CREAT | unix.O_TRUNC | unix.O_EXCL | unix.O_WRONLY)
+	containersOverrideXattr = "user.containers.override_stat"
+	bigDataKey              = "zstd-chunked-manifest"
+	chunkedData             = "zstd-chunked-data"
+	chunkedLayerDataKey     = "zstd-chunked-layer-data"
+	tocKey                  = "toc"
+	fsVerityDigestsKey      = "fs-verity-digests"
+	fsVerityUnsignedKey     = "fs-verity-unsigned"
+
+	fileTypeZstdChunked = iota
+	fileTypeEstargz
+	fileTypeNoCompression
+	fileTypeHole
+
+	copyGoRoutines = 32
+)
+
+type compressedFileType int
+
+// partDecoder holds the buffers and the decompressors used to store the
+// parts of a layer that are retrieved.  Each goroutine storing parts needs
+// its own.
+type partDecoder struct {
+	copyBuffer []byte
+
+	gzipReader *pgzip.Reader
+	zstdReader *zstd.Decoder
+	rawReader  io.Reader
+}
+
+// close releases the decompressors of d.
+func (d *partDecoder) close() {
+	if d.zstdReader != nil {
+		d.zstdReader.Close()
+	}
+}
+
+type chunkedDiffer struct {
+	stream      ImageSourceSeekable
+	manifest    []byte
+	tarSplit    []byte
+	layersCache *layersCache
+	tocOffset   int64
+	fileType    compressedFileType
+
+	partDecoder
+
+	// tocDigest is the digest of the TOC document when the layer
+	// is partially pulled.
+	tocDigest digest.Digest
+
+	// convertedToZstdChunked is set to true if the layer needs to
+	// be converted to the zstd:chunked format before it can be
+	// handled.
+	convertToZstdChunked bool
+
+	// skipValidation is set to true if the individual files in
+	// the layer are trusted and should not be validated.
+	skipValidation bool
+
+	// blobDigest is the digest of the whole compressed layer.  It is used if
+	// convertToZstdChunked to validate a layer when it is converted since there
+	// is no TOC referenced by the manifest, and to request the chunks from
+	// the chunk provider.
+	blobDigest digest.Digest
+
+	blobSize int64
+
+	storeOpts *types.StoreOptions
+
+	useFsVerity     graphdriver.DifferFsVerity
+	fsVerityDigests map[string]string
+	fsVerityMutex   sync.Mutex
+
+	// fsVeritySignatures, if set, are the signatures attached to the files
+	// when fs-verity is enabled on them.
+	fsVeritySignatures *fsVeritySignatures
+	// fsVerityUnsigned are the files without a signature.  It is
+	// protected by fsVerityMutex.
+	fsVerityUnsigned []string
+
+	// useReflinks is set when the chunks reused from other layers are
+	// cloned into the destination files instead of being copied.
+	useReflinks bool
+
+	// retries is the number of times a failed request of chunks is
+	// retried, the first time after retryDelay.
+	retries    int
+	retryDelay time.Duration
+	// wholeBlobFile is the whole blob, downloaded when the requests of
+	// chunks keep failing.
+	wholeBlobFile  *seekableFile
+	wholeBlobMutex sync.Mutex
+	// ctx is the context of the pull.  Canceling it stops waiting for
+	// the retry of a failed request.
+	ctx context.Context
+
+	// convertCache holds the blobs converted by previous pulls, if
+	// convertToZstdChunked.
+	convertCache convertCache
+
+	// logger receives the log messages of the differ, the standard
+	// logrus logger is used when it is nil.
+	logger Logger
+
+	// reportStats, if set, receives the statistics of ApplyDiff.
+	reportStats func(*graphdriver.PullStats)
+	// progress, if set, reports the progress of ApplyDiff.
+	progress *progressTracker
+	// dedupHits counts the files and chunks reused from each source.
+	// It is updated by the copy goroutines.
+	dedupHits      map[string]int
+	dedupHitsMutex sync.Mutex
+}
+
+// log returns the logger of the differ.
+func (c *chunkedDiffer) log() Logger {
+	if c.logger == nil {
+		return logrus.StandardLogger()
+	}
+	return c.logger
+}
+
+// recordDedupHit counts a file or a chunk reused from source.
+func (c *chunkedDiffer) recordDedupHit(source string) {
+	c.dedupHitsMutex.Lock()
+	defer c.dedupHitsMutex.Unlock()
+	if c.dedupHits == nil {
+		c.dedupHits = make(map[string]int)
+	}
+	c.dedupHits[source]++
+}
+
+var xattrsToIgnore = map[string]interface{}{
+	"security.selinux": true,
+}
+
+// chunkedLayerData is used to store additional information about the layer
+type chunkedLayerData struct {
+	Format graphdriver.DifferOutputFormat `json:"format"`
+}
+
+// fileTimes returns the access and modification times to set on the file
+// described by metadata, honoring the ClampMtime and ClampAtime options.
+func fileTimes(metadata *internal.FileMetadata, options *archive.TarOptions) []unix.Timespec {
+	atime, mtime := metadata.AccessTime, metadata.ModTime
+	if options != nil && options.ClampMtime != nil {
+		mtime = options.ClampMtime
+		if options.ClampAtime {
+			atime = options.ClampMtime
+		}
+	}
+	return []unix.Timespec{timeToTimespec(atime), timeToTimespec(mtime)}
+}
+
+// clampDirTimes sets the times of every directory under dest to the ClampMtime
+// option.  Directories are modified while the layer is extracted, so their
+// times can only be fixed once everything else is in place.
+func clampDirTimes(dest string, options *archive.TarOptions) error {
+	if options == nil || options.ClampMtime == nil {
+		return nil
+	}
+	ts := fileTimes(&internal.FileMetadata{}, options)
+	return filepath.WalkDir(dest, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if err := unix.UtimesNanoAt(unix.AT_FDCWD, path, ts, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			return fmt.Errorf("set times of directory %q: %w", path, err)
+		}
+		return nil
+	})
+}
+
+func timeToTimespec(time *time.Time) (ts unix.Timespec) {
+	if time == nil || time.IsZero() {
+		// Return UTIME_OMIT special value
+		ts.Sec = 0
+		ts.Nsec = ((1 << 30) - 2)
+		return
+	}
+	return unix.NsecToTimespec(time.UnixNano())
+}
+
+func doHardLink(srcFd int, destDirFd int, destBase string) error {
+	doLink := func() error {
+		// Using unix.AT_EMPTY_PATH requires CAP_DAC_READ_SEARCH while this variant that uses
+		// /proc/self/fd doesn't and can be used with rootless.
+		srcPath := fmt.Sprintf("/proc/self/fd/%d", srcFd)
+		return unix.Linkat(unix.AT_FDCWD, srcPath, destDirFd, destBase, unix.AT_SYMLINK_FOLLOW)
+	}
+
+	err := doLink()
+
+	// if the destination exists, unlink it first and try again
+	if err != nil && os.IsExist(err) {
+		unix.Unlinkat(destDirFd, destBase, 0)
+		return doLink()
+	}
+	return err
+}
+
+func copyFileContent(srcFd int, destFile string, dirfd int, mode, dirMode os.FileMode, useHardLinks bool) (*os.File, int64, error) {
+	src := fmt.Sprintf("/proc/self/fd/%d", srcFd)
+	st, err := os.Stat(src)
+	if err != nil {
+		return nil, -1, fmt.Errorf("copy file content for %q: %w", destFile, err)
+	}
+
+	copyWithFileRange, copyWithFileClone := true, true
+
+	if useHardLinks {
+		destDirPath := filepath.Dir(destFile)
+		destBase := filepath.Base(destFile)
+		destDir, err := openFileUnderRoot(destDirPath, dirfd, 0, mode)
+		if err == nil {
+			defer destDir.Close()
+
+			err := doHardLink(srcFd, int(destDir.Fd()), destBase)
+			if err == nil {
+				return nil, st.Size(), nil
+			}
+		}
+	}
+
+	// If the destination file already exists, we shouldn't blow it away
+	dstFile, err := openFileUnderRootWithDirMode(destFile, dirfd, newFileFlags, mode, dirMode)
+	if err != nil {
+		return nil, -1, fmt.Errorf("open file %q under rootfs for copy: %w", destFile, err)
+	}
+
+	err = driversCopy.CopyRegularToFile(src, dstFile, st, &copyWithFileRange, &copyWithFileClone)
+	if err != nil {
+		dstFile.Close()
+		return nil, -1, fmt.Errorf("copy to file %q under rootfs: %w", destFile, err)
+	}
+	return dstFile, st.Size(), nil
+}
+
+type seekableFile struct {
+	file *os.File
+}
+
+func (f *seekableFile) Close() error {
+	return f.file.Close()
+}
+
+func (f *seekableFile) GetBlobAt(chunks []ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	streams := make(chan io.ReadCloser)
+	errs := make(chan error)
+
+	go func() {
+		for _, chunk := range chunks {
+			streams <- io.NopCloser(io.NewSectionReader(f.file, int64(chunk.Offset), int64(chunk.Length)))
+		}
+		close(streams)
+		close(errs)
+	}()
+
+	return streams, errs, nil
+}
+
+// convertTarToZstdChunked converts the tarball in payload to zstd:chunked at
+// the given compression level, compressing with up to threads goroutines.
+func convertTarToZstdChunked(destDirectory string, payload *os.File, level, threads int) (*seekableFile, digest.Digest, map[string]string, error) {
+	diff, err := archive.DecompressStream(payload)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	fd, err := unix.Open(destDirectory, unix.O_TMPFILE|unix.O_RDWR|unix.O_CLOEXEC, 0o600)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	f := os.NewFile(uintptr(fd), destDirectory)
+
+	newAnnotations := make(map[string]string)
+	chunked, err := compressor.ZstdCompressorWithThreads(f, newAnnotations, &level, threads)
+	if err != nil {
+		f.Close()
+		return nil, "", nil, err
+	}
+
+	convertedOutputDigester := digest.Canonical.Digester()
+	if _, err := io.Copy(io.MultiWriter(chunked, convertedOutputDigester.Hash()), diff); err != nil {
+		f.Close()
+		return nil, "", nil, err
+	}
+	if err := chunked.Close(); err != nil {
+		f.Close()
+		return nil, "", nil, err
+	}
+	is := seekableFile{
+		file: f,
+	}
+
+	return &is, convertedOutputDigester.Digest(), newAnnotations, nil
+}
+
+// GetDiffer returns a differ than can be used with ApplyDiffWithDiffer.
+func GetDiffer(ctx context.Context, store storage.Store, blobDigest digest.Digest, blobSize int64, annotations map[string]string, iss ImageSourceSeekable) (graphdriver.Differ, error) {
+	storeOpts, err := types.DefaultStoreOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	if !parseBooleanPullOption(&storeOpts, "enable_partial_images", true) {
+		return nil, errors.New("enable_partial_images not configured")
+	}
+
+	_, hasZstdChunkedTOC := annotations[internal.ManifestChecksumKey]
+	_, hasEstargzTOC := annotations[estargz.TOCJSONDigestAnnotation]
+
+	if hasZstdChunkedTOC && hasEstargzTOC {
+		return nil, errors.New("both zstd:chunked and eStargz TOC found")
+	}
+
+	var differ *chunkedDiffer
+	switch {
+	case hasZstdChunkedTOC:
+		differ, err = makeZstdChunkedDiffer(ctx, store, blobSize, annotations, iss, &storeOpts)
+	case hasEstargzTOC:
+		differ, err = makeEstargzChunkedDiffer(ctx, store, blobSize, annotations, iss, &storeOpts)
+	default:
+		differ, err = makeConvertFromRawDiffer(ctx, store, blobDigest, blobSize, annotations, iss, &storeOpts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	differ.ctx = ctx
+	differ.logger = loggerFromContext(ctx)
+	differ.blobDigest = blobDigest
+	differ.fsVeritySignatures, err = newFsVeritySignatures(annotations, storeOpts.PullOptions["fsverity_signatures"])
+	if err != nil {
+		return nil, err
+	}
+	if report := statsReporterFromContext(ctx); report != nil {
+		differ.reportStats = func(stats *graphdriver.PullStats) {
+			report(blobDigest, stats)
+		}
+	}
+	if report := progressReporterFromContext(ctx); report != nil {
+		differ.progress = &progressTracker{
+			report: func(progress Progress) {
+				report(blobDigest, progress)
+			},
+		}
+	}
+	differ.logger.Debugf("Partial pull of layer with %s: %s", blobDigest, strings.Join(differ.pullOptionsSummary(), " "))
+	return differ, nil
+}
+
+func makeConvertFromRawDiffer(ctx context.Context, store storage.Store, blobDigest digest.Digest, blobSize int64, annotations map[string]string, iss ImageSourceSeekable, storeOpts *types.StoreOptions) (*chunkedDiffer, error) {
+	if !parseBooleanPullOption(storeOpts, "convert_images", false) {
+		return nil, errors.New("convert_images not configured")
+	}
+
+	layersCache, err := getDedupLayersCache(store, storeOpts, loggerFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	var cache convertCache
+	if parseBooleanPullOption(storeOpts, "convert_images_cache", false) {
+		cache = newConvertCache(store.GraphRoot())
+	}
+
+	return &chunkedDiffer{
+		fsVerityDigests:      make(map[string]string),
+		blobDigest:           blobDigest,
+		blobSize:             blobSize,
+		convertCache:         cache,
+		convertToZstdChunked: true,
+		partDecoder:          partDecoder{copyBuffer: makeCopyBuffer()},
+		layersCache:          layersCache,
+		storeOpts:            storeOpts,
+		stream:               iss,
+	}, nil
+}
+
+func makeZstdChunkedDiffer(ctx context.Context, store storage.Store, blobSize int64, annotations map[string]string, iss ImageSourceSeekable, storeOpts *types.StoreOptions) (*chunkedDiffer, error) {
+	manifest, tarSplit, tocOffset, err := readZstdChunkedManifest(iss, blobSize, annotations)
+	if err != nil {
+		return nil, fmt.Errorf("read zstd:chunked manifest: %w", err)
+	}
+	layersCache, err := getDedupLayersCache(store, storeOpts, loggerFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	tocDigest, err := digest.Parse(annotations[internal.ManifestChecksumKey])
+	if err != nil {
+		return nil, fmt.Errorf("parse TOC digest %q: %w", annotations[internal.ManifestChecksumKey], err)
+	}
+
+	return &chunkedDiffer{
+		fsVerityDigests: make(map[string]string),
+		blobSize:        blobSize,
+		tocDigest:       tocDigest,
+		partDecoder:     partDecoder{copyBuffer: makeCopyBuffer()},
+		fileType:        fileTypeZstdChunked,
+		layersCache:     layersCache,
+		manifest:        manifest,
+		storeOpts:       storeOpts,
+		stream:          iss,
+		tarSplit:        tarSplit,
+		tocOffset:       tocOffset,
+	}, nil
+}
+
+func makeEstargzChunkedDiffer(ctx context.Context, store storage.Store, blobSize int64, annotations map[string]string, iss ImageSourceSeekable, storeOpts *types.StoreOptions) (*chunkedDiffer, error) {
+	manifest, tocOffset, err := readEstargzChunkedManifest(iss, blobSize, annotations)
+	if err != nil {
+		return nil, fmt.Errorf("read zstd:chunked manifest: %w", err)
+	}
+	layersCache, err := getDedupLayersCache(store, storeOpts, loggerFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	tocDigest, err := digest.Parse(annotations[estargz.TOCJSONDigestAnnotation])
+	if err != nil {
+		return nil, fmt.Errorf("parse TOC digest %q: %w", annotations[estargz.TOCJSONDigestAnnotation], err)
+	}
+
+	return &chunkedDiffer{
+		fsVerityDigests: make(map[string]string),
+		blobSize:        blobSize,
+		tocDigest:       tocDigest,
+		partDecoder:     partDecoder{copyBuffer: makeCopyBuffer()},
+		fileType:        fileTypeEstargz,
+		layersCache:     layersCache,
+		manifest:        manifest,
+		storeOpts:       storeOpts,
+		stream:          iss,
+		tocOffset:       tocOffset,
+	}, nil
+}
+
+func makeCopyBuffer() []byte {
+	return make([]byte, 2<<20)
+}
+
+// copyFileFromOtherLayer copies a file from another layer
+// file is the file to look for.
+// source is the path to the source layer checkout.
+// name is the path to the file to copy in source.
+// dirfd is an open file descriptor to the destination root directory.
+// dirMode is the mode of the missing parent directories created for the file.
+// useHardLinks defines whether the deduplication can be performed using hard links.
+func copyFileFromOtherLayer(file *internal.FileMetadata, source string, name string, dirfd int, dirMode os.FileMode, useHardLinks bool) (bool, *os.File, int64, error) {
+	srcDirfd, err := unix.Open(source, unix.O_RDONLY, 0)
+	if err != nil {
+		return false, nil, 0, fmt.Errorf("open source file: %w", err)
+	}
+	defer unix.Close(srcDirfd)
+
+	srcFile, err := openFileUnderRoot(name, srcDirfd, unix.O_RDONLY, 0)
+	if err != nil {
+		return false, nil, 0, fmt.Errorf("open source file under target rootfs (%s): %w", name, err)
+	}
+	defer srcFile.Close()
+
+	dstFile, written, err := copyFileContent(int(srcFile.Fd()), file.Name, dirfd, 0, dirMode, useHardLinks)
+	if err != nil {
+		return false, nil, 0, fmt.Errorf("copy content to %q: %w", file.Name, err)
+	}
+	return true, dstFile, written, nil
+}
+
+// canDedupMetadataWithHardLink says whether it is possible to deduplicate file with otherFile.
+// It checks that the two files have the same UID, GID, file mode and xattrs.
+func canDedupMetadataWithHardLink(file *internal.FileMetadata, otherFile *internal.FileMetadata) bool {
+	if file.UID != otherFile.UID {
+		return false
+	}
+	if file.GID != otherFile.GID {
+		return false
+	}
+	if file.Mode != otherFile.Mode {
+		return false
+	}
+	if !reflect.DeepEqual(file.Xattrs, otherFile.Xattrs) {
+		return false
+	}
+	return true
+}
+
+// canDedupFileWithHardLink checks if the specified file can be deduplicated by an
+// open file, given its descriptor and stat data.
+func canDedupFileWithHardLink(file *internal.FileMetadata, fd int, s os.FileInfo) bool {
+	st, ok := s.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+
+	path := fmt.Sprintf("/proc/self/fd/%d", fd)
+
+	listXattrs, err := system.Llistxattr(path)
+	if err != nil {
+		return false
+	}
+
+	xattrs := make(map[string]string)
+	for _, x := range listXattrs {
+		v, err := system.Lgetxattr(path, x)
+		if err != nil {
+			return false
+		}
+
+		if _, found := xattrsToIgnore[x]; found {
+			continue
+		}
+		xattrs[x] = string(v)
+	}
+	// fill only the attributes used by canDedupMetadataWithHardLink.
+	otherFile := internal.FileMetadata{
+		UID:    int(st.Uid),
+		GID:    int(st.Gid),
+		Mode:   int64(st.Mode),
+		Xattrs: xattrs,
+	}
+	return canDedupMetadataWithHardLink(file, &otherFile)
+}
+
+// findFileInDedupSources checks whether the requested file already exist in one of the dedup sources and copies the file content from there if possible.
+// file is the file to look for.
+// sources is a list of dedup sources, e.g. OSTree repos.
+// dirfd is an open fd to the destination checkout.
+// dirMode is the mode of the missing parent directories created for the file.
+// useHardLinks defines whether the deduplication can be performed using hard links.
+// logger receives the reasons a source could not be used.
+// It returns the source the file was copied from, or nil.
+func findFileInDedupSources(file *internal.FileMetadata, sources []DedupSource, dirfd int, dirMode os.FileMode, useHardLinks bool, logger Logger) (DedupSource, *os.File, int64, error) {
+	digest, err := digest.Parse(file.Digest)
+	if err != nil {
+		logger.Debugf("could not parse digest: %v", err)
+		return nil, nil, 0, nil
+	}
+
+	for _, source := range sources {
+		if opener, ok := source.(dedupSourceOpener); ok {
+			// the extracted file is a copy, it cannot be hard linked
+			if useHardLinks {
+				continue
+			}
+			f, err := opener.open(digest, dirfd)
+			if err != nil {
+				logger.Debugf("could not open %s from %v: %v", digest, source, err)
+				continue
+			}
+			if f == nil {
+				continue
+			}
+			defer f.Close()
+			if st, err := f.Stat(); err != nil || st.Size() != file.Size {
+				continue
+			}
+			if err := verifyFileDigest(f, digest); err != nil {
+				logger.Debugf("could not use %s from %v: %v", digest, source, err)
+				continue
+			}
+			dstFile, written, err := copyFileContent(int(f.Fd()), file.Name, dirfd, 0, dirMode, false)
+			if err != nil {
+				logger.Debugf("could not copyFileContent: %v", err)
+				return nil, nil, 0, nil
+			}
+			return source, dstFile, written, nil
+		}
+
+		sourceFile, err := source.Lookup(digest)
+		if err != nil {
+			logger.Debugf("could not look up %s in %v: %v", digest, source, err)
+			continue
+		}
+		if sourceFile == "" {
+			continue
+		}
+		st, err := os.Stat(sourceFile)
+		if err != nil || !st.Mode().IsRegular() {
+			continue
+		}
+		if st.Size() != file.Size {
+			continue
+		}
+		fd, err := unix.Open(sourceFile, unix.O_RDONLY|unix.O_NONBLOCK, 0)
+		if err != nil {
+			logger.Debugf("could not open sourceFile %s: %v", sourceFile, err)
+			return nil, nil, 0, nil
+		}
+		f := os.NewFile(uintptr(fd), "fd")
+		defer f.Close()
+
+		// check if the open file can be deduplicated with hard links
+		if useHardLinks && !canDedupFileWithHardLink(file, fd, st) {
+			continue
+		}
+
+		// the sources are not trusted, the content must match the digest
+		if err := verifyFileDigest(f, digest); err != nil {
+			logger.Debugf("could not use %s from %v: %v", sourceFile, source, err)
+			continue
+		}
+
+		dstFile, written, err := copyFileContent(fd, file.Name, dirfd, 0, dirMode, useHardLinks)
+		if err != nil {
+			logger.Debugf("could not copyFileContent: %v", err)
+			return nil, nil, 0, nil
+		}
+		return source, dstFile, written, nil
+	}
+	// If hard links deduplication was used and it has failed, try again without hard links.
+	if useHardLinks {
+		return findFileInDedupSources(file, sources, dirfd, dirMode, false, logger)
+	}
+
+	return nil, nil, 0, nil
+}
+
+// verifyFileDigest checks that the content of f has digest d.  f is read
+// without moving its offset.
+func verifyFileDigest(f *os.File, d digest.Digest) error {
+	digester := d.Algorithm().Digester()
+	if _, err := io.Copy(digester.Hash(), io.NewSectionReader(f, 0, 1<<63-1)); err != nil {
+		return err
+	}
+	if got := digester.Digest(); got != d {
+		return fmt.Errorf("digest mismatch: expected %s, got %s", d, got)
+	}
+	return nil
+}
+
+// findFileInOtherLayers finds the specified file in other layers.
+// cache is the layers cache to use.
+// file is the file to look for.
+// dirfd is an open file descriptor to the checkout root directory.
+// dirMode is the mode of the missing parent directories created for the file.
+// useHardLinks defines whether the deduplication can be performed using hard links.
+func findFileInOtherLayers(cache *layersCache, file *internal.FileMetadata, dirfd int, dirMode os.FileMode, useHardLinks bool) (bool, *os.File, int64, error) {
+	target, name, err := cache.findFileInOtherLayers(file, useHardLinks)
+	if err != nil || name == "" {
+		return false, nil, 0, err
+	}
+	return copyFileFromOtherLayer(file, target, name, dirfd, dirMode, useHardLinks)
+}
+
+func maybeDoIDRemap(manifest []internal.FileMetadata, options *archive.TarOptions) error {
+	if options.ChownOpts == nil && len(options.UIDMaps) == 0 || len(options.GIDMaps) == 0 {
+		return nil
+	}
+
+	idMappings := idtools.NewIDMappingsFromMaps(options.UIDMaps, options.GIDMaps)
+
+	for i := range manifest {
+		if options.ChownOpts != nil {
+			manifest[i].UID = options.ChownOpts.UID
+			manifest[i].GID = options.ChownOpts.GID
+		} else {
+			pair := idtools.IDPair{
+				UID: manifest[i].UID,
+				GID: manifest[i].GID,
+			}
+			var err error
+			manifest[i].UID, manifest[i].GID, err = idMappings.ToContainer(pair)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func mapToSlice(inputMap map[uint32]struct{}) []uint32 {
+	var out []uint32
+	for value := range inputMap {
+		out = append(out, value)
+	}
+	return out
+}
+
+func collectIDs(entries []internal.FileMetadata) ([]uint32, []uint32) {
+	uids := make(map[uint32]struct{})
+	gids := make(map[uint32]struct{})
+	for _, entry := range entries {
+		uids[uint32(entry.UID)] = struct{}{}
+		gids[uint32(entry.GID)] = struct{}{}
+	}
+	return mapToSlice(uids), mapToSlice(gids)
+}
+
+type originFile struct {
+	Root   string
+	Path   string
+	Offset int64
+}
+
+type missingFileChunk struct {
+	Gap  int64
+	Hole bool
+
+	File *internal.FileMetadata
+
+	CompressedSize   int64
+	UncompressedSize int64
+}
+
+type missingPart struct {
+	Hole        bool
+	SourceChunk *ImageSourceChunk
+	OriginFile  *originFile
+	Chunks      []missingFileChunk
+}
+
+func (o *originFile) OpenFile() (io.ReadCloser, error) {
+	srcDirfd, err := unix.Open(o.Root, unix.O_RDONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open source file: %w", err)
+	}
+	defer unix.Close(srcDirfd)
+
+	srcFile, err := openFileUnderRoot(o.Path, srcDirfd, unix.O_RDONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open source file under target rootfs: %w", err)
+	}
+
+	if _, err := srcFile.Seek(o.Offset, 0); err != nil {
+		srcFile.Close()
+		return nil, err
+	}
+	return srcFile, nil
+}
+
+// setFileAttrs sets the file attributes for file given metadata
+func setFileAttrs(dirfd int, file *os.File, mode os.FileMode, metadata *internal.FileMetadata, options *archive.TarOptions, usePath bool, logger Logger) error {
+	if file == nil || file.Fd() < 0 {
+		return errors.New("invalid file")
+	}
+	fd := int(file.Fd())
+
+	t, err := typeToTarType(metadata.Type)
+	if err != nil {
+		return err
+	}
+
+	// If it is a symlink, force to use the path
+	if t == tar.TypeSymlink {
+		usePath = true
+	}
+
+	baseName := ""
+	if usePath {
+		dirName := filepath.Dir(metadata.Name)
+		if dirName != "" {
+			parentFd, err := openFileUnderRoot(dirName, dirfd, unix.O_PATH|unix.O_DIRECTORY, 0)
+			if err != nil {
+				return err
+			}
+			defer parentFd.Close()
+
+			dirfd = int(parentFd.Fd())
+		}
+		baseName = filepath.Base(metadata.Name)
+	}
+
+	doChown := func() error {
+		if usePath {
+			return unix.Fchownat(dirfd, baseName, metadata.UID, metadata.GID, unix.AT_SYMLINK_NOFOLLOW)
+		}
+		return unix.Fchown(fd, metadata.UID, metadata.GID)
+	}
+
+	doSetXattr := func(k string, v []byte) error {
+		return unix.Fsetxattr(fd, k, v, 0)
+	}
+
+	doUtimes := func() error {
+		ts := fileTimes(metadata, options)
+		if usePath {
+			return unix.UtimesNanoAt(dirfd, baseName, ts, unix.AT_SYMLINK_NOFOLLOW)
+		}
+		return unix.UtimesNanoAt(unix.AT_FDCWD, fmt.Sprintf("/proc/self/fd/%d", fd), ts, 0)
+	}
+
+	doChmod := func() error {
+		if usePath {
+			return unix.Fchmodat(dirfd, baseName, uint32(mode), unix.AT_SYMLINK_NOFOLLOW)
+		}
+		return unix.Fchmod(fd, uint32(mode))
+	}
+
+	if err := doChown(); err != nil {
+		if !options.IgnoreChownErrors {
+			return fmt.Errorf("chown %q to %d:%d: %w", metadata.Name, metadata.UID, metadata.GID, err)
+		}
+	}
+
+	canIgnore := func(err error) bool {
+		return err == nil || errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.ENOTSUP)
+	}
+
+	for k, v := range metadata.Xattrs {
+		if _, found := xattrsToIgnore[k]; found {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return fmt.Errorf("decode xattr %q: %w", v, err)
+		}
+		if options.InUserNS {
+			k, data = userNSXattr(k, data)
+		}
+		if err := doSetXattr(k, data); !canIgnore(err) {
+			// Like the archive path, ignore the xattrs that cannot be set in a user namespace.
+			if options.InUserNS && errors.Is(err, unix.EPERM) {
+				logger.Debugf("Ignoring xattr %s for %q in the user namespace: %v", k, metadata.Name, err)
+				continue
+			}
+			return fmt.Errorf("set xattr %s=%q for %q: %w", k, data, metadata.Name, err)
+		}
+	}
+
+	if err := doUtimes(); !canIgnore(err) {
+		return fmt.Errorf("set utimes for %q: %w", metadata.Name, err)
+	}
+
+	if normalized := options.ModeNormalization.Normalize(mode); normalized != mode {
+		logger.Debugf("Normalized mode of %q from 0%o to 0%o", metadata.Name, mode, normalized)
+		mode = normalized
+	}
+
+	if err := doChmod(); !canIgnore(err) {
+		return fmt.Errorf("chmod %q: %w", metadata.Name, err)
+	}
+	return nil
+}
+
+func openFileUnderRootFallback(dirfd int, name string, flags uint64, mode os.FileMode) (int, error) {
+	root := fmt.Sprintf("/proc/self/fd/%d", dirfd)
+
+	targetRoot, err := os.Readlink(root)
+	if err != nil {
+		return -1, err
+	}
+
+	hasNoFollow := (flags & unix.O_NOFOLLOW) != 0
+
+	var fd int
+	// If O_NOFOLLOW is specified in the flags, then resolve only the parent directory and use the
+	// last component as the path to openat().
+	if hasNoFollow {
+		dirName := filepath.Dir(name)
+		if dirName != "" {
+			newRoot, err := securejoin.SecureJoin(root, filepath.Dir(name))
+			if err != nil {
+				return -1, err
+			}
+			root = newRoot
+		}
+
+		parentDirfd, err := unix.Open(root, unix.O_PATH, 0)
+		if err != nil {
+			return -1, err
+		}
+		defer unix.Close(parentDirfd)
+
+		fd, err = unix.Openat(parentDirfd, filepath.Base(name), int(flags), uint32(mode))
+		if err != nil {
+			return -1, err
+		}
+	} else {
+		newPath, err := securejoin.SecureJoin(root, name)
+		if err != nil {
+			return -1, err
+		}
+		fd, err = unix.Openat(dirfd, newPath, int(flags), uint32(mode))
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	target, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd))
+	if err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+
+	// Add an additional check to make sure the opened fd is inside the rootfs
+	if !strings.HasPrefix(target, targetRoot) {
+		unix.Close(fd)
+		return -1, fmt.Errorf("while resolving %q.  It resolves outside the root directory", name)
+	}
+
+	return fd, err
+}
+
+func openFileUnderRootOpenat2(dirfd int, name string, flags uint64, mode os.FileMode) (int, error) {
+	how := unix.OpenHow{
+		Flags:   flags,
+		Mode:    uint64(mode & 0o7777),
+		Resolve: unix.RESOLVE_IN_ROOT,
+	}
+	return unix.Openat2(dirfd, name, &how)
+}
+
+// skipOpenat2 is set when openat2 is not supported by the underlying kernel and avoid
+// using it again.
+var skipOpenat2 int32
+
+// openFileUnderRootRaw tries to open a file using openat2 and if it is not supported fallbacks to a
+// userspace lookup.
+func openFileUnderRootRaw(dirfd int, name string, flags uint64, mode os.FileMode) (int, error) {
+	var fd int
+	var err error
+	if atomic.LoadInt32(&skipOpenat2) > 0 {
+		fd, err = openFileUnderRootFallback(dirfd, name, flags, mode)
+	} else {
+		fd, err = openFileUnderRootOpenat2(dirfd, name, flags, mode)
+		// If the function failed with ENOSYS, switch off the support for openat2
+		// and fallback to using safejoin.
+		if err != nil && errors.Is(err, unix.ENOSYS) {
+			atomic.StoreInt32(&skipOpenat2, 1)
+			fd, err = openFileUnderRootFallback(dirfd, name, flags, mode)
+		}
+	}
+	return fd, err
+}
+
+// openFileUnderRoot safely opens a file under the specified root directory using openat2
+// name is the path to open relative to dirfd.
+// dirfd is an open file descriptor to the target checkout directory.
+// flags are the flags to pass to the open syscall.
+// mode specifies the mode to use for newly created files.
+func openFileUnderRoot(name string, dirfd int, flags uint64, mode os.FileMode) (*os.File, error) {
+	return openFileUnderRootWithDirMode(name, dirfd, flags, mode, defaultIntermediateDirMode)
+}
+
+// openFileUnderRootWithDirMode is openFileUnderRoot, with dirMode as the mode of the
+// missing parent directories it creates when flags include O_CREAT.
+func openFileUnderRootWithDirMode(name string, dirfd int, flags uint64, mode, dirMode os.FileMode) (*os.File, error) {
+	fd, err := openFileUnderRootRaw(dirfd, name, flags, mode)
+	if err == nil {
+		return os.NewFile(uintptr(fd), name), nil
+	}
+
+	hasCreate := (flags & unix.O_CREAT) != 0
+	if errors.Is(err, unix.ENOENT) && hasCreate {
+		parent := filepath.Dir(name)
+		if parent != "" {
+			newDirfd, err2 := openOrCreateDirUnderRoot(parent, dirfd, dirMode)
+			if err2 == nil {
+				defer newDirfd.Close()
+				fd, err := openFileUnderRootRaw(int(newDirfd.Fd()), filepath.Base(name), flags, mode)
+				if err == nil {
+					return os.NewFile(uintptr(fd), name), nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("open %q under the rootfs: %w", name, err)
+}
+
+// openOrCreateDirUnderRoot safely opens a directory or create it if it is missing.
+// name is the path to open relative to dirfd.
+// dirfd is an open file descriptor to the target checkout directory.
+// dirMode specifies the mode to use for newly created directories.
+func openOrCreateDirUnderRoot(name string, dirfd int, dirMode os.FileMode) (*os.File, error) {
+	fd, err := openFileUnderRootRaw(dirfd, name, unix.O_DIRECTORY|unix.O_RDONLY, 0)
+	if err == nil {
+		return os.NewFile(uintptr(fd), name), nil
+	}
+
+	if errors.Is(err, unix.ENOENT) {
+		parent := filepath.Dir(name)
+		if parent != "" {
+			pDir, err2 := openOrCreateDirUnderRoot(parent, dirfd, dirMode)
+			if err2 != nil {
+				return nil, err
+			}
+			defer pDir.Close()
+
+			baseName := filepath.Base(name)
+
+			// The directory can be created concurrently by another worker
+			if err2 := unix.Mkdirat(int(pDir.Fd()), baseName, uint32(dirMode)); err2 != nil && !errors.Is(err2, unix.EEXIST) {
+				return nil, err
+			}
+
+			fd, err = openFileUnderRootRaw(int(pDir.Fd()), baseName, unix.O_DIRECTORY|unix.O_RDONLY, 0)
+			if err == nil {
+				return os.NewFile(uintptr(fd), name), nil
+			}
+		}
+	}
+	return nil, err
+}
+
+func (d *partDecoder) prepareCompressedStreamToFile(partCompression compressedFileType, from io.Reader, mf *missingFileChunk) (compressedFileType, error) {
+	switch {
+	case partCompression == fileTypeHole:
+		// The entire part is a hole.  Do not need to read from a file.
+		d.rawReader = nil
+		return fileTypeHole, nil
+	case mf.Hole:
+		// Only the missing chunk in the requested part refers to a hole.
+		// The received data must be discarded.
+		limitReader := io.LimitReader(from, mf.CompressedSize)
+		_, err := io.CopyBuffer(io.Discard, limitReader, d.copyBuffer)
+		return fileTypeHole, err
+	case partCompression == fileTypeZstdChunked:
+		d.rawReader = io.LimitReader(from, mf.CompressedSize)
+		if d.zstdReader == nil {
+			r, err := zstd.NewReader(d.rawReader)
+			if err != nil {
+				return partCompression, err
+			}
+			d.zstdReader = r
+		} else {
+			if err := d.zstdReader.Reset(d.rawReader); err != nil {
+				return partCompression, err
+			}
+		}
+	case partCompression == fileTypeEstargz:
+		d.rawReader = io.LimitReader(from, mf.CompressedSize)
+		if d.gzipReader == nil {
+			r, err := pgzip.NewReader(d.rawReader)
+			if err != nil {
+				return partCompression, err
+			}
+			d.gzipReader = r
+		} else {
+			if err := d.gzipReader.Reset(d.rawReader); err != nil {
+				return partCompression, err
+			}
+		}
+	case partCompression == fileTypeNoCompression:
+		d.rawReader = io.LimitReader(from, mf.UncompressedSize)
+	default:
+		return partCompression, fmt.Errorf("unknown file type %q", partCompression)
+	}
+	return partCompression, nil
+}
+
+// hashHole writes SIZE zeros to the specified hasher
+func hashHole(h hash.Hash, size int64, copyBuffer []byte) error {
+	count := int64(len(copyBuffer))
+	if size < count {
+		count = size
+	}
+	for i := int64(0); i < count; i++ {
+		copyBuffer[i] = 0
+	}
+	for size > 0 {
+		count = int64(len(copyBuffer))
+		if size < count {
+			count = size
+		}
+		if _, err := h.Write(copyBuffer[:count]); err != nil {
+			return err
+		}
+		size -= count
+	}
+	return nil
+}
+
+// appendHole creates a hole with the specified size at the open fd.
+func appendHole(fd int, size int64) error {
+	off, err := unix.Seek(fd, size, unix.SEEK_CUR)
+	if err != nil {
+		return err
+	}
+	// Make sure the file size is changed.  It might be the last hole and no other data written afterwards.
+	if err := unix.Ftruncate(fd, off); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *partDecoder) appendCompressedStreamToFile(compression compressedFileType, destFile *destinationFile, size int64) error {
+	switch compression {
+	case fileTypeZstdChunked:
+		defer d.zstdReader.Reset(nil)
+		if _, err := io.CopyBuffer(destFile.to, io.LimitReader(d.zstdReader, size), d.copyBuffer); err != nil {
+			return err
+		}
+	case fileTypeEstargz:
+		defer d.gzipReader.Close()
+		if _, err := io.CopyBuffer(destFile.to, io.LimitReader(d.gzipReader, size), d.copyBuffer); err != nil {
+			return err
+		}
+	case fileTypeNoCompression:
+		if _, err := io.CopyBuffer(destFile.to, io.LimitReader(d.rawReader, size), d.copyBuffer); err != nil {
+			return err
+		}
+	case fileTypeHole:
+		if err := appendHole(int(destFile.file.Fd()), size); err != nil {
+			return err
+		}
+		if destFile.hash != nil {
+			if err := hashHole(destFile.hash, size, d.copyBuffer); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown file type %q", compression)
+	}
+	return nil
+}
+
+// cloneOriginChunk appends size bytes of part, a chunk of a file of another
+// layer, to destFile with copy_file_range, so that the extents are shared on
+// file systems supporting reflinks.  The chunk is still read to validate
+// destFile, unless its validation is skipped.  If the chunk cannot be cloned,
+// it is copied.
+func (d *partDecoder) cloneOriginChunk(part io.Reader, destFile *destinationFile, size int64) error {
+	src, ok := part.(*os.File)
+	if !ok {
+		return d.appendCompressedStreamToFile(fileTypeNoCompression, destFile, size)
+	}
+	srcOff, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	dstOff, err := destFile.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if err := cloneFileRange(int(src.Fd()), srcOff, int(destFile.file.Fd()), dstOff, size); err != nil {
+		// Drop what was cloned, the copy starts again from dstOff.
+		if err := unix.Ftruncate(int(destFile.file.Fd()), dstOff); err != nil {
+			return err
+		}
+		return d.appendCompressedStreamToFile(fileTypeNoCompression, destFile, size)
+	}
+	if _, err := destFile.file.Seek(dstOff+size, io.SeekStart); err != nil {
+		return err
+	}
+	if destFile.hash != nil {
+		_, err := io.CopyBuffer(destFile.hash, io.LimitReader(d.rawReader, size), d.copyBuffer)
+		return err
+	}
+	// Nothing to validate, skip the chunk instead of reading it.
+	d.rawReader = nil
+	_, err = src.Seek(srcOff+size, io.SeekStart)
+	return err
+}
+
+// cloneFileRange copies size bytes from srcFd at srcOff to dstFd at dstOff
+// with copy_file_range, which shares the extents of the two files when the
+// file system supports reflinks.  The offsets of the files are not changed.
+func cloneFileRange(srcFd int, srcOff int64, dstFd int, dstOff int64, size int64) error {
+	for size > 0 {
+		n, err := unix.CopyFileRange(srcFd, &srcOff, dstFd, &dstOff, int(size), 0)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		size -= int64(n)
+	}
+	return nil
+}
+
+type recordFsVerityFunc func(*internal.FileMetadata, *os.File) error
+
+type destinationFile struct {
+	digester       digest.Digester
+	dirfd          int
+	file           *os.File
+	hash           hash.Hash
+	metadata       *internal.FileMetadata
+	options        *archive.TarOptions
+	skipValidation bool
+	to             io.Writer
+	recordFsVerity recordFsVerityFunc
+	logger         Logger
+}
+
+func openDestinationFile(dirfd int, metadata *internal.FileMetadata, options *archive.TarOptions, skipValidation bool, recordFsVerity recordFsVerityFunc, logger Logger) (*destinationFile, error) {
+	file, err := openFileUnderRootWithDirMode(metadata.Name, dirfd, newFileFlags, 0, intermediateDirMode(options))
+	if err != nil {
+		return nil, err
+	}
+
+	var digester digest.Digester
+	var hash hash.Hash
+	var to io.Writer
+
+	if skipValidation {
+		to = file
+	} else {
+		digester = digest.Canonical.Digester()
+		hash = digester.Hash()
+		to = io.MultiWriter(file, hash)
+	}
+
+	return &destinationFile{
+		file:           file,
+		digester:       digester,
+		hash:           hash,
+		to:             to,
+		metadata:       metadata,
+		options:        options,
+		dirfd:          dirfd,
+		skipValidation: skipValidation,
+		recordFsVerity: recordFsVerity,
+		logger:         logger,
+	}, nil
+}
+
+func (d *destinationFile) Close() (Err error) {
+	defer func() {
+		var roFile *os.File
+		var err error
+
+		if d.recordFsVerity != nil {
+			roFile, err = reopenFileReadOnly(d.file)
+			if err == nil {
+				defer roFile.Close()
+			} else if Err == nil {
+				Err = err
+			}
+		}
+
+		err = d.file.Close()
+		if Err == nil {
+			Err = err
+		}
+
+		if Err == nil && roFile != nil {
+			Err = d.recordFsVerity(d.metadata, roFile)
+		}
+	}()
+
+	if !d.skipValidation {
+		manifestChecksum, err := digest.Parse(d.metadata.Digest)
+		if err != nil {
+			return err
+		}
+		if d.digester.Digest() != manifestChecksum {
+			return fmt.Errorf("checksum mismatch for %q (got %q instead of %q)", d.file.Name(), d.digester.Digest(), manifestChecksum)
+		}
+	}
+
+	return setFileAttrs(d.dirfd, d.file, os.FileMode(d.metadata.Mode), d.metadata, d.options, false, d.logger)
+}
+
+func closeDestinationFiles(files chan *destinationFile, errors chan error) {
+	for f := range files {
+		errors <- f.Close()
+	}
+	close(errors)
+}
+
+func (c *chunkedDiffer) recordFsVerity(file *internal.FileMetadata, roFile *os.File) error {
+	if c.useFsVerity == graphdriver.DifferFsVerityDisabled {
+		return nil
+	}
+	path := file.Name
+
+	var signature []byte
+	if c.fsVeritySignatures != nil {
+		var err error
+		signature, err = c.fsVeritySignatures.lookup(file.Digest)
+		if err != nil {
+			return fmt.Errorf("looking up the fs-verity signature of %q: %w", path, err)
+		}
+		if signature == nil {
+			c.fsVerityMutex.Lock()
+			c.fsVerityUnsigned = append(c.fsVerityUnsigned, path)
+			c.fsVerityMutex.Unlock()
+		}
+	}
+
+	// fsverity.EnableVerityWithSignature doesn't return an error if fs-verity was already
+	// enabled on the file.
+	err := fsverity.EnableVerityWithSignature(path, int(roFile.Fd()), signature)
+	if err != nil {
+		if c.useFsVerity == graphdriver.DifferFsVerityRequired {
+			return err
+		}
+
+		// If it is not required, ignore the error if the filesystem does not support it.
+		if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.ENOTTY) {
+			return nil
+		}
+	}
+	verity, err := fsverity.MeasureVerity(path, int(roFile.Fd()))
+	if err != nil {
+		return err
+	}
+
+	c.fsVerityMutex.Lock()
+	c.fsVerityDigests[path] = verity
+	c.fsVerityMutex.Unlock()
+
+	return nil
+}
+
+func (c *chunkedDiffer) storeMissingFiles(dec *partDecoder, streams chan io.ReadCloser, errs chan error, dest string, dirfd int, missingParts []missingPart, options *archive.TarOptions) (Err error) {
+	var destFile *destinationFile
+
+	filesToClose := make(chan *destinationFile, 3)
+	closeFilesErrors := make(chan error, 2)
+
+	go closeDestinationFiles(filesToClose, closeFilesErrors)
+	defer func() {
+		close(filesToClose)
+		for e := range closeFilesErrors {
+			if e != nil && Err == nil {
+				Err = e
+			}
+		}
+	}()
+
+	for _, missingPart := range missingParts {
+		var part io.ReadCloser
+		partCompression := c.fileType
+		switch {
+		case missingPart.Hole:
+			partCompression = fileTypeHole
+		case missingPart.OriginFile != nil:
+			var err error
+			part, err = missingPart.OriginFile.OpenFile()
+			if err != nil {
+				return err
+			}
+			partCompression = fileTypeNoCompression
+		case missingPart.SourceChunk != nil:
+			select {
+			case p := <-streams:
+				part = p
+			case err := <-errs:
+				if err == nil {
+					return errors.New("not enough data returned from the server")
+				}
+				return err
+			}
+			if part == nil {
+				return errors.New("invalid stream returned")
+			}
+		default:
+			return errors.New("internal error: missing part misses both local and remote data stream")
+		}
+
+		for _, mf := range missingPart.Chunks {
+			if mf.Gap > 0 {
+				limitReader := io.LimitReader(part, mf.Gap)
+				_, err := io.CopyBuffer(io.Discard, limitReader, dec.copyBuffer)
+				if err != nil {
+					Err = err
+					goto exit
+				}
+				continue
+			}
+
+			if mf.File.Name == "" {
+				Err = errors.New("file name empty")
+				goto exit
+			}
+
+			compression, err := dec.prepareCompressedStreamToFile(partCompression, part, &mf)
+			if err != nil {
+				Err = err
+				goto exit
+			}
+
+			// Open the new file if it is different that what is already
+			// opened
+			if destFile == nil || destFile.metadata.Name != mf.File.Name {
+				var err error
+				if destFile != nil {
+				cleanup:
+					for {
+						select {
+						case err = <-closeFilesErrors:
+							if err != nil {
+								Err = err
+								goto exit
+							}
+						default:
+							break cleanup
+						}
+					}
+					filesToClose <- destFile
+				}
+				recordFsVerity := c.recordFsVerity
+				if c.useFsVerity == graphdriver.DifferFsVerityDisabled {
+					recordFsVerity = nil
+				}
+				destFile, err = openDestinationFile(dirfd, mf.File, options, c.skipValidation, recordFsVerity, c.log())
+				if err != nil {
+					Err = err
+					goto exit
+				}
+			}
+
+			if missingPart.OriginFile != nil && c.useReflinks {
+				err = dec.cloneOriginChunk(part, destFile, mf.UncompressedSize)
+			} else {
+				err = dec.appendCompressedStreamToFile(compression, destFile, mf.UncompressedSize)
+			}
+			if err != nil {
+				Err = err
+				goto exit
+			}
+			if dec.rawReader != nil {
+				if _, err := io.CopyBuffer(io.Discard, dec.rawReader, dec.copyBuffer); err != nil {
+					Err = err
+					goto exit
+				}
+			}
+		}
+	exit:
+		if part != nil {
+			part.Close()
+			if Err != nil {
+				break
+			}
+		}
+		// the chunks of a converted layer are read from the local copy of the blob.
+		if missingPart.SourceChunk != nil && missingPart.OriginFile == nil && !missingPart.Hole && !c.convertToZstdChunked {
+			c.progress.fetched(int64(missingPart.SourceChunk.Length))
+		}
+	}
+
+	if destFile != nil {
+		return destFile.Close()
+	}
+
+	return nil
+}
+
+func mergeMissingChunks(missingParts []missingPart, target int) []missingPart {
+	getGap := func(missingParts []missingPart, i int) int {
+		prev := missingParts[i-1].SourceChunk.Offset + missingParts[i-1].SourceChunk.Length
+		return int(missingParts[i].SourceChunk.Offset - prev)
+	}
+	getCost := func(missingParts []missingPart, i int) int {
+		cost := getGap(missingParts, i)
+		if missingParts[i-1].OriginFile != nil {
+			cost += int(missingParts[i-1].SourceChunk.Length)
+		}
+		if missingParts[i].OriginFile != nil {
+			cost += int(missingParts[i].SourceChunk.Length)
+		}
+		return cost
+	}
+
+	// simple case: merge chunks from the same file.
+	newMissingParts := missingParts[0:1]
+	prevIndex := 0
+	for i := 1; i < len(missingParts); i++ {
+		gap := getGap(missingParts, i)
+		if gap == 0 && missingParts[prevIndex].OriginFile == nil &&
+			missingParts[i].OriginFile == nil &&
+			!missingParts[prevIndex].Hole && !missingParts[i].Hole &&
+			len(missingParts[prevIndex].Chunks) == 1 && len(missingParts[i].Chunks) == 1 &&
+			missingParts[prevIndex].Chunks[0].File.Name == missingParts[i].Chunks[0].File.Name {
+			missingParts[prevIndex].SourceChunk.Length += uint64(gap) + missingParts[i].SourceChunk.Length
+			missingParts[prevIndex].Chunks[0].CompressedSize += missingParts[i].Chunks[0].CompressedSize
+			missingParts[prevIndex].Chunks[0].UncompressedSize += missingParts[i].Chunks[0].UncompressedSize
+		} else {
+			newMissingParts = append(newMissingParts, missingParts[i])
+			prevIndex++
+		}
+	}
+	missingParts = newMissingParts
+
+	if len(missingParts) <= target {
+		return missingParts
+	}
+
+	// this implementation doesn't account for duplicates, so it could merge
+	// more than necessary to reach the specified target.  Since target itself
+	// is a heuristic value, it doesn't matter.
+	costs := make([]int, len(missingParts)-1)
+	for i := 1; i < len(missingParts); i++ {
+		costs[i-1] = getCost(missingParts, i)
+	}
+	sort.Ints(costs)
+
+	toShrink := len(missingParts) - target
+	if toShrink >= len(costs) {
+		toShrink = len(costs) - 1
+	}
+	targetValue := costs[toShrink]
+
+	newMissingParts = missingParts[0:1]
+	for i := 1; i < len(missingParts); i++ {
+		if getCost(missingParts, i) > targetValue {
+			newMissingParts = append(newMissingParts, missingParts[i])
+		} else {
+			gap := getGap(missingParts, i)
+			prev := &newMissingParts[len(newMissingParts)-1]
+			prev.SourceChunk.Length += uint64(gap) + missingParts[i].SourceChunk.Length
+			prev.Hole = false
+			prev.OriginFile = nil
+			if gap > 0 {
+				gapFile := missingFileChunk{
+					Gap: int64(gap),
+				}
+				prev.Chunks = append(prev.Chunks, gapFile)
+			}
+			prev.Chunks = append(prev.Chunks, missingParts[i].Chunks...)
+		}
+	}
+	return newMissingParts
+}
+
+// retrieveMissingFiles fetches and stores missingParts.  With a concurrency
+// greater than one, the parts are split in as many groups, each retrieved with
+// its own request and stored by its own goroutine.
+func (c *chunkedDiffer) retrieveMissingFiles(stream ImageSourceSeekable, dest string, dirfd int, missingParts []missingPart, options *archive.TarOptions, concurrency int) error {
+	groups := splitMissingParts(missingParts, concurrency)
+	if len(groups) == 1 {
+		return c.retrieveMissingParts(&c.partDecoder, stream, dest, dirfd, groups[0], options)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(groups))
+	for i, group := range groups {
+		wg.Add(1)
+		go func(i int, group []missingPart) {
+			defer wg.Done()
+			dec := &partDecoder{copyBuffer: makeCopyBuffer()}
+			defer dec.close()
+			errs[i] = c.retrieveMissingParts(dec, stream, dest, dirfd, group, options)
+		}(i, group)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitMissingParts splits missingParts in up to n groups of about the same
+// size.  The parts of a file are never split across groups, since a file is
+// written sequentially.
+func splitMissingParts(missingParts []missingPart, n int) [][]missingPart {
+	partSize := func(p *missingPart) int64 {
+		var size int64
+		for _, mf := range p.Chunks {
+			size += mf.UncompressedSize + mf.Gap
+		}
+		return size
+	}
+	// firstFile and lastFile return the name of the first and last file a
+	// part writes to.
+	firstFile := func(p *missingPart) string {
+		for _, mf := range p.Chunks {
+			if mf.File != nil {
+				return mf.File.Name
+			}
+		}
+		return ""
+	}
+	lastFile := func(p *missingPart) string {
+		for i := len(p.Chunks) - 1; i >= 0; i-- {
+			if p.Chunks[i].File != nil {
+				return p.Chunks[i].File.Name
+			}
+		}
+		return ""
+	}
+
+	if n <= 1 || len(missingParts) <= 1 {
+		return [][]missingPart{missingParts}
+	}
+	var total int64
+	for i := range missingParts {
+		total += partSize(&missingParts[i])
+	}
+	target := total / int64(n)
+
+	var groups [][]missingPart
+	start, size := 0, int64(0)
+	for i := range missingParts {
+		size += partSize(&missingParts[i])
+		if i+1 == len(missingParts) || len(groups)+1 == n || size < target {
+			continue
+		}
+		if last := lastFile(&missingParts[i]); last != "" && last == firstFile(&missingParts[i+1]) {
+			continue
+		}
+		groups = append(groups, missingParts[start:i+1])
+		start, size = i+1, 0
+	}
+	return append(groups, missingParts[start:])
+}
+
+// retrieveMissingParts fetches missingParts with a single multirange request
+// and stores them using dec.
+func (c *chunkedDiffer) retrieveMissingParts(dec *partDecoder, stream ImageSourceSeekable, dest string, dirfd int, missingParts []missingPart, options *archive.TarOptions) error {
+	var chunksToRequest []ImageSourceChunk
+
+	calculateChunksToRequest := func() {
+		chunksToRequest = []ImageSourceChunk{}
+		for _, c := range missingParts {
+			if c.OriginFile == nil && !c.Hole {
+				chunksToRequest = append(chunksToRequest, *c.SourceChunk)
+			}
+		}
+	}
+
+	calculateChunksToRequest()
+
+	// There are some missing files.  Prepare a multirange request for the missing chunks.
+	var streams chan io.ReadCloser
+	var err error
+	var errs chan error
+	downloadedWholeBlob := false
+	for len(chunksToRequest) > 0 {
+		streams, errs, err = c.getBlobAtWithRetries(stream, chunksToRequest, c.retries)
+		if err == nil {
+			break
+		}
+
+		if _, ok := err.(ErrBadRequest); ok {
+			requested := len(missingParts)
+			// If the server cannot handle at least 64 chunks in a single request, just give up.
+			if requested < 64 {
+				return err
+			}
+
+			// Merge more chunks to request
+			missingParts = mergeMissingChunks(missingParts, requested/2)
+			calculateChunksToRequest()
+			continue
+		}
+		// The requests keep failing, read the chunks from the whole blob instead.  A
+		// converted layer is already read from a local file.
+		if !downloadedWholeBlob && !c.convertToZstdChunked {
+			c.log().Debugf("Requests of chunks failed, downloading the whole blob: %v", err)
+			blob, errBlob := c.wholeBlob(dest)
+			if errBlob != nil {
+				return fmt.Errorf("%w (downloading the whole blob: %v)", err, errBlob)
+			}
+			stream = blob
+			downloadedWholeBlob = true
+			continue
+		}
+		return err
+	}
+
+	if err := c.storeMissingFiles(dec, streams, errs, dest, dirfd, missingParts, options); err != nil {
+		return err
+	}
+	return nil
+}
+
+func safeMkdir(dirfd int, mode os.FileMode, name string, metadata *internal.FileMetadata, options *archive.TarOptions, logger Logger) error {
+	parent := filepath.Dir(name)
+	base := filepath.Base(name)
+
+	parentFd := dirfd
+	if parent != "." {
+		parentFile, err := openOrCreateDirUnderRoot(parent, dirfd, intermediateDirMode(options))
+		if err != nil {
+			return err
+		}
+		defer parentFile.Close()
+		parentFd = int(parentFile.Fd())
+	}
+
+	if err := unix.Mkdirat(parentFd, base, uint32(mode)); err != nil {
+		if !os.IsExist(err) {
+			return fmt.Errorf("mkdir %q: %w", name, err)
+		}
+	}
+
+	file, err := openFileUnderRoot(base, parentFd, unix.O_DIRECTORY|unix.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return setFileAttrs(dirfd, file, mode, metadata, options, false, logger)
+}
+
+func safeLink(dirfd int, mode os.FileMode, metadata *internal.FileMetadata, options *archive.TarOptions, logger Logger) error {
+	sourceFile, err := openFileUnderRoot(metadata.Linkname, dirfd, unix.O_PATH|unix.O_RDONLY|unix.O_NOFOLLOW, 0)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	destDir, destBase := filepath.Dir(metadata.Name), filepath.Base(metadata.Name)
+	destDirFd := dirfd
+	if destDir != "." {
+		f, err := openOrCreateDirUnderRoot(destDir, dirfd, intermediateDirMode(options))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		destDirFd = int(f.Fd())
+	}
+
+	err = doHardLink(int(sourceFile.Fd()), destDirFd, destBase)
+	if err != nil {
+		return fmt.Errorf("create hardlink %q pointing to %q: %w", metadata.Name, metadata.Linkname, err)
+	}
+
+	newFile, err := openFileUnderRoot(metadata.Name, dirfd, unix.O_WRONLY|unix.O_NOFOLLOW, 0)
+	if err != nil {
+		// If the target is a symlink, open the file with O_PATH.
+		if errors.Is(err, unix.ELOOP) {
+			newFile, err := openFileUnderRoot(metadata.Name, dirfd, unix.O_PATH|unix.O_NOFOLLOW, 0)
+			if err != nil {
+				return err
+			}
+			defer newFile.Close()
+
+			return setFileAttrs(dirfd, newFile, mode, metadata, options, true, logger)
+		}
+		return err
+	}
+	defer newFile.Close()
+
+	return setFileAttrs(dirfd, newFile, mode, metadata, options, false, logger)
+}
+
+func safeSymlink(dirfd int, mode os.FileMode, metadata *internal.FileMetadata, options *archive.TarOptions) error {
+	destDir, destBase := filepath.Dir(metadata.Name), filepath.Base(metadata.Name)
+	destDirFd := dirfd
+	if destDir != "." {
+		f, err := openOrCreateDirUnderRoot(destDir, dirfd, intermediateDirMode(options))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		destDirFd = int(f.Fd())
+	}
+
+	err := unix.Symlinkat(metadata.Linkname, destDirFd, destBase)
+	if err != nil && errors.Is(err, unix.EEXIST) && options != nil && options.MetadataDelta {
+		// keep a symlink that already points to the right target
+		if target, err2 := readlinkat(destDirFd, destBase); err2 != nil || target != metadata.Linkname {
+			if err2 := removeAt(destDirFd, destBase); err2 != nil {
+				return err2
+			}
+			err = unix.Symlinkat(metadata.Linkname, destDirFd, destBase)
+		} else {
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("create symlink %q pointing to %q: %w", metadata.Name, metadata.Linkname, err)
+	}
+	if options != nil && options.ClampMtime != nil {
+		err := unix.UtimesNanoAt(destDirFd, destBase, fileTimes(metadata, options), unix.AT_SYMLINK_NOFOLLOW)
+		if err != nil && !errors.Is(err, unix.ENOSYS) && !errors.Is(err, unix.ENOTSUP) {
+			return fmt.Errorf("set times of symlink %q: %w", metadata.Name, err)
+		}
+	}
+	return nil
+}
+
+type whiteoutHandler struct {
+	Dirfd   int
+	Root    string
+	DirMode os.FileMode
+}
+
+func (d whiteoutHandler) Setxattr(path, name string, value []byte) error {
+	file, err := openOrCreateDirUnderRoot(path, d.Dirfd, d.DirMode)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := unix.Fsetxattr(int(file.Fd()), name, value, 0); err != nil {
+		return fmt.Errorf("set xattr %s=%q for %q: %w", name, value, path, err)
+	}
+	return nil
+}
+
+func (d whiteoutHandler) Mknod(path string, mode uint32, dev int) error {
+	dir := filepath.Dir(path)
+	base := filepath.Base(path)
+
+	dirfd := d.Dirfd
+	if dir != "" {
+		dir, err := openOrCreateDirUnderRoot(dir, d.Dirfd, d.DirMode)
+		if err != nil {
+			return err
+		}
+		defer dir.Close()
+
+		dirfd = int(dir.Fd())
+	}
+
+	if err := unix.Mknodat(dirfd, base, mode, dev); err != nil {
+		return fmt.Errorf("mknod %q: %w", path, err)
+	}
+
+	return nil
+}
+
+func checkChownErr(err error, name string, uid, gid int) error {
+	if errors.Is(err, syscall.EINVAL) {
+		return fmt.Errorf(`potentially insufficient UIDs or GIDs available in user namespace (requested %d:%d for %s): Check /etc/subuid and /etc/subgid if configured locally and run "podman system migrate": %w`, uid, gid, name, err)
+	}
+	return err
+}
+
+func (d whiteoutHandler) Chown(path string, uid, gid int) error {
+	file, err := openFileUnderRoot(path, d.Dirfd, unix.O_PATH, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := unix.Fchownat(int(file.Fd()), "", uid, gid, unix.AT_EMPTY_PATH); err != nil {
+		var stat unix.Stat_t
+		if unix.Fstat(int(file.Fd()), &stat) == nil {
+			if stat.Uid == uint32(uid) && stat.Gid == uint32(gid) {
+				return nil
+			}
+		}
+		return checkChownErr(err, path, uid, gid)
+	}
+	return nil
+}
+
+type hardLinkToCreate struct {
+	dest     string
+	dirfd    int
+	mode     os.FileMode
+	metadata *internal.FileMetadata
+}
+
+// createHardLinks creates hardLinks with up to workers goroutines.  The
+// targets of the links must already exist, except for links to other links
+// which are created once the link they point to is in place.
+func createHardLinks(hardLinks []hardLinkToCreate, options *archive.TarOptions, workers int, logger Logger) error {
+	pending := hardLinks
+	for len(pending) > 0 {
+		names := make(map[string]struct{}, len(pending))
+		for _, m := range pending {
+			names[m.metadata.Name] = struct{}{}
+		}
+		var ready, later []hardLinkToCreate
+		for _, m := range pending {
+			if _, isLink := names[m.metadata.Linkname]; isLink {
+				later = append(later, m)
+			} else {
+				ready = append(ready, m)
+			}
+		}
+		if len(ready) == 0 {
+			return fmt.Errorf("hard links form a loop, including %q pointing to %q", pending[0].metadata.Name, pending[0].metadata.Linkname)
+		}
+		if err := createHardLinksParallel(ready, options, workers, logger); err != nil {
+			return err
+		}
+		pending = later
+	}
+	return nil
+}
+
+// createHardLinksParallel creates independent hard links with up to workers
+// goroutines and returns the first error encountered.
+func createHardLinksParallel(hardLinks []hardLinkToCreate, options *archive.TarOptions, workers int, logger Logger) error {
+	if workers > len(hardLinks) {
+		workers = len(hardLinks)
+	}
+	if workers <= 1 {
+		for _, m := range hardLinks {
+			if err := safeLink(m.dirfd, m.mode, m.metadata, options, logger); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	jobs := make(chan hardLinkToCreate)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range jobs {
+				if err := safeLink(m.dirfd, m.mode, m.metadata, options, logger); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+					})
+				}
+			}
+		}()
+	}
+	for _, m := range hardLinks {
+		jobs <- m
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+func parseBooleanPullOption(storeOpts *storage.StoreOptions, name string, def bool) bool {
+	if value, ok := storeOpts.PullOptions[name]; ok {
+		return strings.ToLower(value) == "true"
+	}
+	return def
+}
+
+// defaultIntermediateDirMode is the mode of the parent directories created for
+// files whose parents are not listed in the TOC
+const defaultIntermediateDirMode os.FileMode = 0o755
+
+// parseIntermediateDirMode parses the octal mode of the "intermediate_dir_mode"
+// pull option, e.g. "0700" or "0o700".  An empty value returns a nil mode.
+func parseIntermediateDirMode(value string) (*os.FileMode, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	mode, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(value), "0o"), 8, 32)
+	if err != nil || mode&^0o7777 != 0 {
+		return nil, fmt.Errorf("invalid intermediate directory mode %q", value)
+	}
+	dirMode := os.FileMode(mode)
+	return &dirMode, nil
+}
+
+// parsePartialPullConcurrency parses the partial_pull_concurrency pull
+// option, the number of concurrent requests used to retrieve the missing
+// parts of a layer.  It defaults to 1.
+func parsePartialPullConcurrency(value string) (int, error) {
+	if value == "" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid partial_pull_concurrency %q: must be a positive integer", value)
+	}
+	return n, nil
+}
+
+// parseConvertZstdLevel parses the convert_images_zstd_level pull option, the
+// zstd compression level used to convert images.  It defaults to 1.
+func parseConvertZstdLevel(value string) (int, error) {
+	if value == "" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > 22 {
+		return 0, fmt.Errorf("invalid convert_images_zstd_level %q: must be an integer between 1 and 22", value)
+	}
+	return n, nil
+}
+
+// parseConvertThreads parses the convert_images_threads pull option, the
+// number of goroutines compressing a converted image.  It defaults to 1.
+func parseConvertThreads(value string) (int, error) {
+	if value == "" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid convert_images_threads %q: must be a positive integer", value)
+	}
+	return n, nil
+}
+
+// intermediateDirMode returns the mode to use for the parent directories
+// created for entries whose parents are not listed in the TOC.
+func intermediateDirMode(options *archive.TarOptions) os.FileMode {
+	if options == nil || options.IntermediateDirMode == nil {
+		return defaultIntermediateDirMode
+	}
+	return *options.IntermediateDirMode
+}
+
+// auditWhiteouts returns the paths deleted by the whiteouts in entries and
+// checks them against policy.  Whiteouts the policy does not allow are an
+// error, or only logged to logger if the policy is report only.
+func auditWhiteouts(entries []internal.FileMetadata, policy *archive.WhiteoutPolicy, logger Logger) ([]string, error) {
+	var whiteouts, denied []string
+	for _, e := range entries {
+		name := filepath.Clean(e.Name)
+		base, dir := filepath.Base(name), filepath.Dir(name)
+		var target string
+		switch {
+		case base == archive.WhiteoutOpaqueDir:
+			// an opaque directory hides everything below it
+			target = dir
+		case strings.HasPrefix(base, archive.WhiteoutPrefix):
+			target = filepath.Join(dir, strings.TrimPrefix(base, archive.WhiteoutPrefix))
+		default:
+			continue
+		}
+		whiteouts = append(whiteouts, target)
+		if !policy.Allows(target) {
+			denied = append(denied, target)
+		}
+	}
+	if len(whiteouts) > 0 {
+		logger.Debugf("Layer whiteouts: %s", strings.Join(whiteouts, ", "))
+	}
+	if len(denied) == 0 {
+		return whiteouts, nil
+	}
+	if policy.ReportOnly {
+		logger.Warnf("Layer has whiteouts outside of the allowed paths: %s", strings.Join(denied, ", "))
+		return whiteouts, nil
+	}
+	return whiteouts, fmt.Errorf("layer has whiteouts outside of the allowed paths: %s", strings.Join(denied, ", "))
+}
+
+// parseClampMtime parses the "clamp_mtime" pull option, the number of seconds
+// since the Unix epoch, e.g. the value of SOURCE_DATE_EPOCH.  An empty value
+// returns a nil time.
+func parseClampMtime(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	sec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || sec < 0 {
+		return nil, fmt.Errorf("invalid clamp_mtime %q: must be the number of seconds since the epoch", value)
+	}
+	t := time.Unix(sec, 0).UTC()
+	return &t, nil
+}
+
+type findAndCopyFileOptions struct {
+	useHardLinks bool
+	dedupSources []DedupSource
+	options      *archive.TarOptions
+	// disableDedup skips the lookup of files and chunks in the other layers
+	// and in the dedup sources, so that everything is fetched
+	disableDedup bool
+}
+
+func reopenFileReadOnly(f *os.File) (*os.File, error) {
+	path := fmt.Sprintf("/proc/self/fd/%d", f.Fd())
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), f.Name()), nil
+}
+
+// openMatchingFile opens the regular file at metadata.Name under dirfd if
+// its content matches the digest of metadata, so that only its attributes
+// need to be set.  It returns nil if there is no such file, and removes
+// whatever is in the way of the file otherwise.
+func openMatchingFile(dirfd int, metadata *internal.FileMetadata) (*os.File, error) {
+	// O_PATH does not follow a symlink nor opens a special file
+	path, err := openFileUnderRoot(metadata.Name, dirfd, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if errors.Is(err, unix.ENOENT) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer path.Close()
+	st, err := path.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if st.Mode().IsRegular() && st.Size() == metadata.Size {
+		file, err := reopenFileReadOnly(path)
+		if err != nil {
+			return nil, err
+		}
+		matches, err := contentMatches(file, metadata)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if matches {
+			return file, nil
+		}
+		file.Close()
+	}
+	return nil, removeUnderRoot(dirfd, metadata.Name)
+}
+
+// contentMatches checks whether the content of file has the digest of metadata.
+func contentMatches(file *os.File, metadata *internal.FileMetadata) (bool, error) {
+	if metadata.Size == 0 {
+		return true, nil
+	}
+	d, err := digest.Parse(metadata.Digest)
+	if err != nil {
+		return false, nil
+	}
+	verifier := d.Verifier()
+	if _, err := io.Copy(verifier, file); err != nil {
+		return false, err
+	}
+	return verifier.Verified(), nil
+}
+
+// removeUnderRoot removes the file or the empty directory at name under dirfd.
+func removeUnderRoot(dirfd int, name string) error {
+	parent, err := openFileUnderRoot(filepath.Dir(name), dirfd, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer parent.Close()
+	return removeAt(int(parent.Fd()), filepath.Base(name))
+}
+
+// removeAt removes the file or the empty directory name in dirfd.
+func removeAt(dirfd int, name string) error {
+	err := unix.Unlinkat(dirfd, name, 0)
+	if errors.Is(err, unix.EISDIR) {
+		err = unix.Unlinkat(dirfd, name, unix.AT_REMOVEDIR)
+	}
+	if err != nil && !errors.Is(err, unix.ENOENT) {
+		return fmt.Errorf("remove %q: %w", name, err)
+	}
+	return nil
+}
+
+// readlinkat returns the target of the symlink name in dirfd.
+func readlinkat(dirfd int, name string) (string, error) {
+	buf := make([]byte, unix.PathMax)
+	n, err := unix.Readlinkat(dirfd, name, buf)
+	if err != nil {
+		return "", err
+	}
+	return string(buf[:n]), nil
+}
+
+func (c *chunkedDiffer) findAndCopyFile(dirfd int, r *internal.FileMetadata, copyOptions *findAndCopyFileOptions, mode os.FileMode) (bool, error) {
+	finalizeFile := func(dstFile *os.File) error {
+		if dstFile == nil {
+			return nil
+		}
+		err := setFileAttrs(dirfd, dstFile, mode, r, copyOptions.options, false, c.log())
+		if err != nil {
+			dstFile.Close()
+			return err
+		}
+		var roFile *os.File
+		if c.useFsVerity != graphdriver.DifferFsVerityDisabled {
+			roFile, err = reopenFileReadOnly(dstFile)
+		}
+		dstFile.Close()
+		if err != nil {
+			return err
+		}
+		if roFile == nil {
+			return nil
+		}
+
+		defer roFile.Close()
+		return c.recordFsVerity(r, roFile)
+	}
+
+	if copyOptions.options.MetadataDelta {
+		dstFile, err := openMatchingFile(dirfd, r)
+		if err != nil {
+			return false, err
+		}
+		if dstFile != nil {
+			if err := finalizeFile(dstFile); err != nil {
+				return false, err
+			}
+			c.recordDedupHit("checkout")
+			return true, nil
+		}
+	}
+
+	if copyOptions.disableDedup {
+		return false, nil
+	}
+
+	found, dstFile, _, err := findFileInOtherLayers(c.layersCache, r, dirfd, intermediateDirMode(copyOptions.options), copyOptions.useHardLinks)
+	if err != nil {
+		return false, err
+	}
+	if found {
+		if err := finalizeFile(dstFile); err != nil {
+			return false, err
+		}
+		c.recordDedupHit("layers")
+		return true, nil
+	}
+
+	source, dstFile, _, err := findFileInDedupSources(r, copyOptions.dedupSources, dirfd, intermediateDirMode(copyOptions.options), copyOptions.useHardLinks, c.log())
+	if err != nil {
+		return false, err
+	}
+	if source != nil {
+		if err := finalizeFile(dstFile); err != nil {
+			return false, err
+		}
+		c.recordDedupHit(dedupSourceName(source))
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func makeEntriesFlat(mergedEntries []internal.FileMetadata) ([]internal.FileMetadata, error) {
+	var new []internal.FileMetadata
+
+	hashes := make(map[string]string)
+	for i := range mergedEntries {
+		if mergedEntries[i].Type != TypeReg {
+			continue
+		}
+		if mergedEntries[i].Digest == "" {
+			return nil, fmt.Errorf("missing digest for %q", mergedEntries[i].Name)
+		}
+		digest, err := digest.Parse(mergedEntries[i].Digest)
+		if err != nil {
+			return nil, err
+		}
+		d := digest.Encoded()
+
+		if hashes[d] != "" {
+			continue
+		}
+		hashes[d] = d
+
+		mergedEntries[i].Name = fmt.Sprintf("%s/%s", d[0:2], d[2:])
+
+		new = append(new, mergedEntries[i])
+	}
+	return new, nil
+}
+
+// copyAllBlobToFile downloads the whole blob to destination, retrying a
+// failed request up to retries times, and returns its digest.
+func (c *chunkedDiffer) copyAllBlobToFile(destination *os.File, retries int) (digest.Digest, error) {
+	var payload io.ReadCloser
+	var streams chan io.ReadCloser
+	var errs chan error
+	var err error
+
+	chunksToRequest := []ImageSourceChunk{
+		{
+			Offset: 0,
+			Length: uint64(c.blobSize),
+		},
+	}
+
+	streams, errs, err = c.getBlobAtWithRetries(c.stream, chunksToRequest, retries)
+	if err != nil {
+		return "", err
+	}
+	select {
+	case p := <-streams:
+		payload = p
+	case err := <-errs:
+		return "", err
+	}
+	if payload == nil {
+		return "", errors.New("invalid stream returned")
+	}
+
+	originalRawDigester := digest.Canonical.Digester()
+
+	r := io.TeeReader(payload, originalRawDigester.Hash())
+
+	// copy the entire tarball and compute its digest
+	_, err = io.Copy(destination, r)
+
+	return originalRawDigester.Digest(), err
+}
+
+// downloadAndConvert retrieves the whole blob, validates it and converts it to
+// zstd:chunked in an O_TMPFILE file under dest.
+func (c *chunkedDiffer) downloadAndConvert(dest string, level, threads int) (*seekableFile, digest.Digest, map[string]string, error) {
+	fd, err := unix.Open(dest, unix.O_TMPFILE|unix.O_RDWR|unix.O_CLOEXEC, 0o600)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	// Closing the file releases the file descriptor and deletes the file.
+	blobFile := os.NewFile(uintptr(fd), "blob-file")
+	defer blobFile.Close()
+
+	// calculate the checksum before accessing the file.
+	compressedDigest, err := c.copyAllBlobToFile(blobFile, c.retries)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	if compressedDigest != c.blobDigest {
+		return nil, "", nil, fmt.Errorf("invalid digest to convert: expected %q, got %q", c.blobDigest, compressedDigest)
+	}
+
+	if _, err := blobFile.Seek(0, io.SeekStart); err != nil {
+		return nil, "", nil, err
+	}
+
+	return convertTarToZstdChunked(dest, blobFile, level, threads)
+}
+
+func (c *chunkedDiffer) ApplyDiff(dest string, options *archive.TarOptions, differOpts *graphdriver.DifferOptions) (graphdriver.DriverWithDifferOutput, error) {
+	if c.layersCache != nil {
+		defer c.layersCache.release(c.log())
+	}
+	defer c.partDecoder.close()
+
+	c.useFsVerity = differOpts.UseFsVerity
+
+	stats := graphdriver.PullStats{
+		Phases: make(map[string]time.Duration),
+	}
+	phaseStart := time.Now()
+	endPhase := func(phase string) {
+		now := time.Now()
+		stats.Phases[phase] = now.Sub(phaseStart)
+		phaseStart = now
+	}
+
+	// The bandwidth limit applies to every request to the source of the layer, including
+	// the download of a blob to convert.
+	maxBandwidth, err := parseMaxBandwidth(c.storeOpts.PullOptions["partial_pull_max_bandwidth"])
+	if err != nil {
+		return graphdriver.DriverWithDifferOutput{}, err
+	}
+	if maxBandwidth > 0 {
+		c.stream = limitBandwidth(c.stream, bandwidthLimiter(maxBandwidth))
+	}
+	// The chunk provider is tried before the registry, and is not subject to
+	// the bandwidth limit.
+	if socket := c.storeOpts.PullOptions["chunk_provider"]; socket != "" {
+		c.stream = chunkProviderFor(socket).wrap(c.stream, c.blobDigest, c.log())
+	}
+
+	c.retries, err = parsePartialPullRetries(c.storeOpts.PullOptions["partial_pull_retries"])
+	if err != nil {
+		return graphdriver.DriverWithDifferOutput{}, err
+	}
+	c.retryDelay, err = parsePartialPullRetryDelay(c.storeOpts.PullOptions["partial_pull_retry_delay"])
+	if err != nil {
+		return graphdriver.DriverWithDifferOutput{}, err
+	}
+	defer func() {
+		if c.wholeBlobFile != nil {
+			c.wholeBlobFile.Close()
+		}
+	}()
+
+	// In strict mode, the tar stream of the layer is reassembled from its tar-split and the
+	// files written to dest, so that its digest can be checked against the expected DiffID.
+	verifyTarSplit := parseBooleanPullOption(c.storeOpts, "verify_tar_split", false)
+	if verifyTarSplit {
+		if c.fileType == fileTypeEstargz {
+			return graphdriver.DriverWithDifferOutput{}, errors.New("verify_tar_split is not supported with eStargz layers, they have no tar-split")
+		}
+		if differOpts != nil && differOpts.Format != graphdriver.DifferOutputFormatDir {
+			return graphdriver.DriverWithDifferOutput{}, errors.New("verify_tar_split is supported only when the layer is staged as a directory")
+		}
+	}
+
+	// stream to use for reading the zstd:chunked or Estargz file.
+	stream := c.stream
+
+	var uncompressedDigest digest.Digest
+
+	if c.convertToZstdChunked {
+		level, err := parseConvertZstdLevel(c.storeOpts.PullOptions["convert_images_zstd_level"])
+		if err != nil {
+			return graphdriver.DriverWithDifferOutput{}, err
+		}
+		threads, err := parseConvertThreads(c.storeOpts.PullOptions["convert_images_threads"])
+		if err != nil {
+			return graphdriver.DriverWithDifferOutput{}, err
+		}
+
+		// A blob converted by a previous pull is verified by the cache, and its files are
+		// validated like the files of any other layer.
+		fileSource, diffID, annotations, found := c.convertCache.lookup(c.blobDigest, c.log())
+		if !found {
+			fileSource, diffID, annotations, err = c.downloadAndConvert(dest, level, threads)
+			if err != nil {
+				return graphdriver.DriverWithDifferOutput{}, err
+			}
+			// the missing parts are read from the converted file, the whole layer was downloaded.
+			stats.DownloadedBytes = c.blobSize
+			c.convertCache.store(c.blobDigest, fileSource, diffID, annotations, c.log())
+		}
+		// fileSource is a O_TMPFILE file descriptor, so we
+		// need to keep it open until the entire file is processed.
+		defer fileSource.Close()
+
+		manifest, tarSplit, tocOffset, err := readZstdChunkedManifest(fileSource, c.blobSize, annotations)
+		if err != nil {
+			return graphdriver.DriverWithDifferOutput{}, fmt.Errorf("read zstd:chunked manifest: %w", err)
+		}
+
+		// Use the new file for accessing the zstd:chunked file.
+		stream = fileSource
+
+		// fill the chunkedDiffer with the data we just read.
+		c.fileType = fileTypeZstdChunked
+		c.manifest = manifest
+		c.tarSplit = tarSplit
+		c.tocOffset = tocOffset
+
+		// if the file was generated by us, the digest for each file was already computed, no need to validate it again.
+		c.skipValidation = !found
+		// since we retrieved the whole file and it was validated, set the uncompressed digest.
+		uncompressedDigest = diffID
+	}
+
+	lcd := chunkedLayerData{
+		Format: differOpts.Format,
+	}
+
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+	lcdBigData, err := json.Marshal(lcd)
+	if err != nil {
+		return graphdriver.DriverWithDifferOutput{}, err
+	}
+
+	// Generate the manifest
+	toc, err := unmarshalToc(c.manifest)
+	if err != nil {
+		return graphdriver.DriverWithDifferOutput{}, err
+	}
+
+	output := graphdriver.DriverWithDifferOutput{
+		Differ:   c,
+		TarSplit: c.tarSplit,
+		BigData: map[string][]byte{
+			bigDataKey:          c.manifest,
+			chunkedLayerDataKey: lcdBigData,
+		},
+		Artifacts: map[string]interface{}{
+			tocKey: toc,
+		},
+		TOCDigest:          c.tocDigest,
+		UncompressedDigest: uncompressedDigest,
+	}
+
+	// The lookaside cache is stored with the layer, and its files and chunks
+	// are added to the dedup index when the layer is created.
+	if metadata, err := writeCache(c.manifest, differOpts.Format, "", bigDataMap(output.BigData), c.log()); err != nil {
+		c.log().Debugf("Not adding the layer to the dedup index: %v", err)
+	} else {
+		output.DedupLocations = metadata.dedupLocations()
+	}
+
+	// When the hard links deduplication is used, file attributes are ignored because setting them
+	// modifies the source file as well.
+	useHardLinks := parseBooleanPullOption(c.storeOpts, "use_hard_links", false)
+
+	// Reflinks share the extents of the deduplicated content but keep the metadata of each file
+	// independent, so they take precedence over hard links.  copyFileContent already attempts to
+	// clone the files it copies, use_reflinks clones the chunks reused from other layers as well.
+	c.useReflinks = parseBooleanPullOption(c.storeOpts, "use_reflinks", false)
+	if c.useReflinks && useHardLinks {
+		c.log().Debugf("use_reflinks is set, not deduplicating files with hard links")
+		useHardLinks = false
+	}
+
+	// List of OSTree repositories and other stores to use for deduplication
+	dedupSources, err := parseDedupSources(c.storeOpts.PullOptions["ostree_repos"], c.storeOpts.PullOptions["dedup_sources"])
+	if err != nil {
+		return graphdriver.DriverWithDifferOutput{}, err
+	}
+
+	// The mode normalization policy can be set either by the caller or with a pull option.
+	if options.ModeNormalization == nil {
+		policy, err := archive.ParseModeNormalization(c.storeOpts.PullOptions["mode_normalization"])
+		if err != nil {
+			return graphdriver.DriverWithDifferOutput{}, err
+		}
+		if policy != nil {
+			optionsCopy := *options
+			optionsCopy.ModeNormalization = policy
+			options = &optionsCopy
+		}
+	}
+
+	// The mode of implicitly created directories can be set either by the caller or with a pull option.
+	if options.IntermediateDirMode == nil {
+		dirMode, err := parseIntermediateDirMode(c.storeOpts.PullOptions["intermediate_dir_mode"])
+		if err != nil {
+			return graphdriver.DriverWithDifferOutput{}, err
+		}
+		if dirMode != nil {
+			optionsCopy := *options
+			optionsCopy.IntermediateDirMode = dirMode
+			options = &optionsCopy
+		}
+	}
+
+	// The timestamp of extracted files can be set either by the caller or with pull options.
+	if options.ClampMtime == nil {
+		clampMtime, err := parseClampMtime(c.storeOpts.PullOptions["clamp_mtime"])
+		if err != nil {
+			return graphdriver.DriverWithDifferOutput{}, err
+		}
+		if clampMtime != nil {
+			optionsCopy := *options
+			optionsCopy.ClampMtime = clampMtime
+			optionsCopy.ClampAtime = parseBooleanPullOption(c.storeOpts, "clamp_atime", false)
+			options = &optionsCopy
+		}
+	}
+
+	// The whiteout policy can be set either by the caller or with pull options.
+	if options.WhiteoutPolicy == nil {
+		policy, err := archive.ParseWhiteoutPolicy(c.storeOpts.PullOptions["whiteout_policy"], c.storeOpts.PullOptions["whiteout_allow"])
+		if err != nil {
+			return graphdriver.DriverWithDifferOutput{}, err
+		}
+		if policy != nil {
+			optionsCopy := *options
+			optionsCopy.WhiteoutPolicy = policy
+			options = &optionsCopy
+		}
+	}
+
+	// Applying only the metadata delta can be requested either by the caller or with a pull option.
+	if !options.MetadataDelta && parseBooleanPullOption(c.storeOpts, "metadata_delta", false) {
+		optionsCopy := *options
+		optionsCopy.MetadataDelta = true
+		options = &optionsCopy
+	}
+
+	whiteoutConverter := archive.GetWhiteoutConverter(options.WhiteoutFormat, options.WhiteoutData)
+
+	var missingParts []missingPart
+
+	output.UIDs, output.GIDs = collectIDs(toc.Entries)
+
+	mergedEntries, totalSize, err := c.mergeTocEntries(c.fileType, toc.Entries)
+	if err != nil {
+		return output, err
+	}
+
+	output.Size = totalSize
+
+	if err := maybeDoIDRemap(mergedEntries, options); err != nil {
+		return output, err
+	}
+
+	if options.WhiteoutPolicy != nil {
+		if _, err := auditWhiteouts(mergedEntries, options.WhiteoutPolicy, c.log()); err != nil {
+			return output, err
+		}
+	}
+
+	if options.ForceMask != nil {
+		uid, gid, mode, err := archive.GetFileOwner(dest)
+		if err == nil {
+			value := fmt.Sprintf("%d:%d:0%o", uid, gid, mode)
+			if err := unix.Setxattr(dest, containersOverrideXattr, []byte(value), 0); err != nil {
+				return output, err
+			}
+		}
+	}
+
+	dirfd, err := unix.Open(dest, unix.O_RDONLY|unix.O_PATH, 0)
+	if err != nil {
+		return output, fmt.Errorf("cannot open %q: %w", dest, err)
+	}
+	defer unix.Close(dirfd)
+
+	if differOpts != nil && (differOpts.Format == graphdriver.DifferOutputFormatFlat || differOpts.Format == graphdriver.DifferOutputFormatComposefs) {
+		mergedEntries, err = makeEntriesFlat(mergedEntries)
+		if err != nil {
+			return output, err
+		}
+		createdDirs := make(map[string]struct{})
+		for _, e := range mergedEntries {
+			d := e.Name[0:2]
+			if _, found := createdDirs[d]; !found {
+				unix.Mkdirat(dirfd, d, 0o755)
+				createdDirs[d] = struct{}{}
+			}
+		}
+	}
+
+	// hardlinks can point to missing files.  So create them after all files
+	// are retrieved
+	var hardLinks []hardLinkToCreate
+
+	missingPartsSize, totalChunksSize, holesSize := int64(0), int64(0), int64(0)
+
+	copyOptions := findAndCopyFileOptions{
+		useHardLinks: useHardLinks,
+		dedupSources: dedupSources,
+		options:      options,
+		disableDedup: parseBooleanPullOption(c.storeOpts, "disable_dedup", false),
+	}
+	if copyOptions.disableDedup {
+		c.log().Debugf("Deduplication disabled, fetching every file of the layer")
+	} else if err := c.layersCache.lookupDedupIndex(dedupDigests(mergedEntries, useHardLinks)); err != nil {
+		c.log().Debugf("Not using the dedup index: %v", err)
+	}
+
+	concurrency, err := parsePartialPullConcurrency(c.storeOpts.PullOptions["partial_pull_concurrency"])
+	if err != nil {
+		return output, err
+	}
+
+	type copyFileJob struct {
+		njob     int
+		index    int
+		mode     os.FileMode
+		metadata *internal.FileMetadata
+
+		found bool
+		err   error
+	}
+
+	var wg sync.WaitGroup
+
+	copyResults := make([]copyFileJob, len(mergedEntries))
+
+	// the files are counted before the copy goroutines reuse any of them.
+	c.progress.update(func(p *Progress) {
+		for i := range mergedEntries {
+			if mergedEntries[i].Type == TypeReg && mergedEntries[i].Size > 0 {
+				p.Files++
+			}
+		}
+	})
+
+	copyFileJobs := make(chan copyFileJob)
+	defer func() {
+		if copyFileJobs != nil {
+			close(copyFileJobs)
+		}
+		wg.Wait()
+	}()
+
+	for i := 0; i < copyGoRoutines; i++ {
+		wg.Add(1)
+		jobs := copyFileJobs
+
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				found, err := c.findAndCopyFile(dirfd, job.metadata, &copyOptions, job.mode)
+				job.err = err
+				job.found = found
+				if found && err == nil {
+					c.progress.update(func(p *Progress) {
+						p.ReusedFiles++
+					})
+				}
+				copyResults[job.njob] = job
+			}
+		}()
+	}
+
+	endPhase("prepare")
+
+	filesToWaitFor := 0
+	for i, r := range mergedEntries {
+		if options.ForceMask != nil {
+			value := fmt.Sprintf("%d:%d:0%o", r.UID, r.GID, options.ModeNormalization.Normalize(os.FileMode(r.Mode&0o7777)))
+			r.Xattrs[containersOverrideXattr] = base64.StdEncoding.EncodeToString([]byte(value))
+			r.Mode = int64(*options.ForceMask)
+		}
+
+		mode := os.FileMode(r.Mode)
+
+		r.Name = filepath.Clean(r.Name)
+		r.Linkname = filepath.Clean(r.Linkname)
+
+		t, err := typeToTarType(r.Type)
+		if err != nil {
+			return output, err
+		}
+		if whiteoutConverter != nil {
+			hdr := archivetar.Header{
+				Typeflag: t,
+				Name:     r.Name,
+				Linkname: r.Linkname,
+				Size:     r.Size,
+				Mode:     r.Mode,
+				Uid:      r.UID,
+				Gid:      r.GID,
+			}
+			handler := whiteoutHandler{
+				Dirfd:   dirfd,
+				Root:    dest,
+				DirMode: intermediateDirMode(options),
+			}
+			writeFile, err := whiteoutConverter.ConvertReadWithHandler(&hdr, r.Name, &handler)
+			if err != nil {
+				return output, err
+			}
+			if !writeFile {
+				continue
+			}
+		}
+		switch t {
+		case tar.TypeReg:
+			// Create directly empty files.
+			if r.Size == 0 {
+				// Used to have a scope for cleanup.
+				createEmptyFile := func() error {
+					if options.MetadataDelta {
+						file, err := openMatchingFile(dirfd, &r)
+						if err != nil {
+							return err
+						}
+						if file != nil {
+							defer file.Close()
+							return setFileAttrs(dirfd, file, mode, &r, options, false, c.log())
+						}
+					}
+					file, err := openFileUnderRootWithDirMode(r.Name, dirfd, newFileFlags, 0, intermediateDirMode(options))
+					if err != nil {
+						return err
+					}
+					defer file.Close()
+					if err := setFileAttrs(dirfd, file, mode, &r, options, false, c.log()); err != nil {
+						return err
+					}
+					return nil
+				}
+				if err := createEmptyFile(); err != nil {
+					return output, err
+				}
+				continue
+			}
+
+		case tar.TypeDir:
+			if r.Name == "" || r.Name == "." {
+				output.RootDirMode = &mode
+			}
+			if err := safeMkdir(dirfd, mode, r.Name, &r, options, c.log()); err != nil {
+				return output, err
+			}
+			continue
+
+		case tar.TypeLink:
+			dest := dest
+			dirfd := dirfd
+			mode := mode
+			r := r
+			hardLinks = append(hardLinks, hardLinkToCreate{
+				dest:     dest,
+				dirfd:    dirfd,
+				mode:     mode,
+				metadata: &r,
+			})
+			continue
+
+		case tar.TypeSymlink:
+			if err := safeSymlink(dirfd, mode, &r, options); err != nil {
+				return output, err
+			}
+			continue
+
+		case tar.TypeChar:
+		case tar.TypeBlock:
+		case tar.TypeFifo:
+			/* Ignore.  */
+		default:
+			return output, fmt.Errorf("invalid type %q", t)
+		}
+
+		totalChunksSize += r.Size
+
+		if t == tar.TypeReg {
+			index := i
+			njob := filesToWaitFor
+			job := copyFileJob{
+				mode:     mode,
+				metadata: &mergedEntries[index],
+				index:    index,
+				njob:     njob,
+			}
+			copyFileJobs <- job
+			filesToWaitFor++
+		}
+	}
+
+	close(copyFileJobs)
+	copyFileJobs = nil
+
+	wg.Wait()
+
+	for _, res := range copyResults[:filesToWaitFor] {
+		r := &mergedEntries[res.index]
+
+		if res.err != nil {
+			return output, res.err
+		}
+		// the file was already copied to its destination
+		// so nothing left to do.
+		if res.found {
+			continue
+		}
+
+		missingPartsSize += r.Size
+
+		remainingSize := r.Size
+
+		// the file is missing, attempt to find individual chunks.
+		for _, chunk := range r.Chunks {
+			compressedSize := int64(chunk.EndOffset - chunk.Offset)
+			size := remainingSize
+			if chunk.ChunkSize > 0 {
+				size = chunk.ChunkSize
+			}
+			remainingSize = remainingSize - size
+
+			rawChunk := ImageSourceChunk{
+				Offset: uint64(chunk.Offset),
+				Length: uint64(compressedSize),
+			}
+			file := missingFileChunk{
+				File:             &mergedEntries[res.index],
+				CompressedSize:   compressedSize,
+				UncompressedSize: size,
+			}
+			mp := missingPart{
+				SourceChunk: &rawChunk,
+				Chunks: []missingFileChunk{
+					file,
+				},
+			}
+
+			switch chunk.ChunkType {
+			case internal.ChunkTypeData:
+				origin, err := c.findChunk(chunk, &copyOptions)
+				if err != nil {
+					return output, err
+				}
+				if origin != nil {
+					missingPartsSize -= size
+					mp.OriginFile = origin
+				}
+			case internal.ChunkTypeZeros:
+				missingPartsSize -= size
+				holesSize += size
+				mp.Hole = true
+				// Mark all chunks belonging to the missing part as holes
+				for i := range mp.Chunks {
+					mp.Chunks[i].Hole = true
+				}
+			}
+			missingParts = append(missingParts, mp)
+		}
+	}
+	endPhase("dedup")
+
+	// There are some missing files.  Prepare a multirange request for the missing chunks.
+	if len(missingParts) > 0 {
+		missingParts = mergeMissingChunks(missingParts, maxNumberMissingChunks)
+		if !c.convertToZstdChunked {
+			stats.DownloadedBytes = fetchedSize(missingParts)
+			c.progress.update(func(p *Progress) {
+				p.RemainingBytes = stats.DownloadedBytes
+			})
+		}
+		if err := c.retrieveMissingFiles(stream, dest, dirfd, missingParts, options, concurrency); err != nil {
+			return output, err
+		}
+		if c.wholeBlobFile != nil {
+			stats.DownloadedBytes = c.blobSize
+		}
+	}
+	endPhase("fetch")
+
+	if err := createHardLinks(hardLinks, options, copyGoRoutines, c.log()); err != nil {
+		return output, err
+	}
+
+	if err := clampDirTimes(dest, options); err != nil {
+		return output, err
+	}
+
+	// The converted layers were read completely, their digest was computed from the stream.
+	if verifyTarSplit && uncompressedDigest == "" {
+		d, err := reassembledDigest(dirfd, c.tarSplit)
+		if err != nil {
+			return output, err
+		}
+		c.log().Debugf("Layer with TOC %s reassembled with digest %s", c.tocDigest, d)
+		output.TarSplitDigest = d
+		endPhase("verify")
+	}
+
+	if totalChunksSize > 0 {
+		c.log().Debugf("Missing %d bytes out of %d (%.2f %%)", missingPartsSize, totalChunksSize, float32(missingPartsSize*100.0)/float32(totalChunksSize))
+	}
+
+	output.Artifacts[fsVerityDigestsKey] = c.fsVerityDigests
+
+	if differOpts != nil && differOpts.Format == graphdriver.DifferOutputFormatComposefs {
+		image, err := writeComposefsImage(dest, toc, c.fsVerityDigests)
+		if err != nil {
+			return output, err
+		}
+		output.Artifacts[composefsImageKey] = image
+	}
+
+	if len(c.fsVerityUnsigned) > 0 && c.useFsVerity == graphdriver.DifferFsVerityRequired {
+		sort.Strings(c.fsVerityUnsigned)
+		c.log().Warnf("%d files of the layer have no fs-verity signature", len(c.fsVerityUnsigned))
+		c.log().Debugf("Files without an fs-verity signature: %s", strings.Join(c.fsVerityUnsigned, ", "))
+		output.Artifacts[fsVerityUnsignedKey] = c.fsVerityUnsigned
+	}
+
+	c.progress.update(func(p *Progress) {
+		p.Done = true
+	})
+
+	endPhase("finalize")
+	stats.ReusedBytes = totalChunksSize - missingPartsSize - holesSize
+	stats.HoleBytes = holesSize
+	c.dedupHitsMutex.Lock()
+	stats.DedupHits = c.dedupHits
+	c.dedupHitsMutex.Unlock()
+	output.Stats = &stats
+	if c.reportStats != nil {
+		c.reportStats(&stats)
+	}
+
+	return output, nil
+}
+
+// fetchedSize returns the size of the data requested from the source of the
+// layer to retrieve missingParts.
+func fetchedSize(missingParts []missingPart) int64 {
+	var size int64
+	for _, mp := range missingParts {
+		if mp.Hole || mp.OriginFile != nil {
+			continue
+		}
+		size += int64(mp.SourceChunk.Length)
+	}
+	return size
+}
+
+func mustSkipFile(fileType compressedFileType, e internal.FileMetadata) bool {
+	// ignore the metadata files for the estargz format.
+	if fileType != fileTypeEstargz {
+		return false
+	}
+	switch e.Name {
+	// ignore the metadata files for the estargz format.
+	case estargz.PrefetchLandmark, estargz.NoPrefetchLandmark, estargz.TOCTarName:
+		return true
+	}
+	return false
+}
+
+func (c *chunkedDiffer) mergeTocEntries(fileType compressedFileType, entries []internal.FileMetadata) ([]internal.FileMetadata, int64, error) {
+	var totalFilesSize int64
+
+	countNextChunks := func(start int) int {
+		count := 0
+		for _, e := range entries[start:] {
+			if e.Type != TypeChunk {
+				return count
+			}
+			count++
+		}
+		return count
+	}
+
+	size := 0
+	for _, entry := range entries {
+		if mustSkipFile(fileType, entry) {
+			continue
+		}
+		if entry.Type != TypeChunk {
+			size++
+		}
+	}
+
+	mergedEntries := make([]internal.FileMetadata, size)
+	m := 0
+	for i := 0; i < len(entries); i++ {
+		e := entries[i]
+		if mustSkipFile(fileType, e) {
+			continue
+		}
+
+		totalFilesSize += e.Size
+
+		if e.Type == TypeChunk {
+			return nil, -1, fmt.Errorf("chunk type without a regular file")
+		}
+
+		if e.Type == TypeReg {
+			nChunks := countNextChunks(i + 1)
+
+			e.Chunks = make([]*internal.FileMetadata, nChunks+1)
+			for j := 0; j <= nChunks; j++ {
+				// we need a copy here, otherwise we override the
+				// .Size later
+				copy := entries[i+j]
+				e.Chunks[j] = &copy
+				e.EndOffset = entries[i+j].EndOffset
+			}
+			i += nChunks
+		}
+		mergedEntries[m] = e
+		m++
+	}
+	// stargz/estargz doesn't store EndOffset so let's calculate it here
+	lastOffset := c.tocOffset
+	for i := len(mergedEntries) - 1; i >= 0; i-- {
+		if mergedEntries[i].EndOffset == 0 {
+			mergedEntries[i].EndOffset = lastOffset
+		}
+		if mergedEntries[i].Offset != 0 {
+			lastOffset = mergedEntries[i].Offset
+		}
+
+		lastChunkOffset := mergedEntries[i].EndOffset
+		for j := len(mergedEntries[i].Chunks) - 1; j >= 0; j-- {
+			mergedEntries[i].Chunks[j].EndOffset = lastChunkOffset
+			mergedEntries[i].Chunks[j].Size = mergedEntries[i].Chunks[j].EndOffset - mergedEntries[i].Chunks[j].Offset
+			lastChunkOffset = mergedEntries[i].Chunks[j].Offset
+		}
+	}
+	return mergedEntries, totalFilesSize, nil
+}
+
+// validateChunkChecksum checks if the file at $root/$path[offset:chunk.ChunkSize] has the
+// same digest as chunk.ChunkDigest
+// findChunk looks up the data chunk in the other layers, unless deduplication
+// is disabled, and returns where it was found, or nil if it must be fetched.
+func (c *chunkedDiffer) findChunk(chunk *internal.FileMetadata, copyOptions *findAndCopyFileOptions) (*originFile, error) {
+	if copyOptions.disableDedup {
+		return nil, nil
+	}
+	root, path, offset, err := c.layersCache.findChunkInOtherLayers(chunk)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || !validateChunkChecksum(chunk, root, path, offset, c.copyBuffer) {
+		return nil, nil
+	}
+	c.recordDedupHit("layers")
+	return &originFile{
+		Root:   root,
+		Path:   path,
+		Offset: offset,
+	}, nil
+}
+
+func validateChunkChecksum(chunk *internal.FileMetadata, root, path string, offset int64, copyBuffer []byte) bool {
+	parentDirfd, err := unix.Open(root, unix.O_PATH, 0)
+	if err != nil {
+		return false
+	}
+	defer unix.Close(parentDirfd)
+
+	fd, err := openFileUnderRoot(path, parentDirfd, unix.O_RDONLY, 0)
+	if err != nil {
+		return false
+	}
+	defer fd.Close()
+
+	if _, err := unix.Seek(int(fd.Fd()), offset, 0); err != nil {
+		return false
+	}
+
+	r := io.LimitReader(fd, chunk.ChunkSize)
+	digester := digest.Canonical.Digester()
+
+	if _, err := io.CopyBuffer(digester.Hash(), r, copyBuffer); err != nil {
+		return false
+	}
+
+	digest, err := digest.Parse(chunk.ChunkDigest)
+	if err != nil {
+		return false
+	}
+
+	return digester.Digest() == digest
+}
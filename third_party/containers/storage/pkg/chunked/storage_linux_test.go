@@ -0,0 +1,1588 @@
+package chunked
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	storage "github.com/containers/storage"
+	graphdriver "github.com/containers/storage/drivers"
+	"github.com/containers/storage/internal/dedupindex"
+	"github.com/containers/storage/pkg/archive"
+	"github.com/containers/storage/pkg/chunked/compressor"
+	"github.com/containers/storage/pkg/chunked/internal"
+	"github.com/containers/storage/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+	"golang.org/x/time/rate"
+)
+
+// checkoutDir creates the directory a layer is extracted to and returns it
+// with the O_PATH file descriptor the differ extracts files with.
+func checkoutDir(tb testing.TB) (string, int) {
+	tb.Helper()
+	dest := tb.TempDir()
+	dirfd, err := unix.Open(dest, unix.O_RDONLY|unix.O_PATH, 0)
+	require.NoError(tb, err)
+	tb.Cleanup(func() { unix.Close(dirfd) })
+	return dest, dirfd
+}
+
+func composefsTestTOC() (*internal.TOC, string) {
+	d := digest.FromString("content")
+	toc := &internal.TOC{
+		Entries: []internal.FileMetadata{
+			{Type: internal.TypeDir, Name: "usr/", Mode: 0o755},
+			{Type: internal.TypeReg, Name: "usr/file", Mode: 0o644, Size: 7, Digest: d.String()},
+		},
+	}
+	return toc, d.Encoded()[:2] + "/" + d.Encoded()[2:]
+}
+
+func TestWriteComposefsImage(t *testing.T) {
+	// a stand-in for mkcomposefs that copies the dump it is given to the
+	// image, so that its content can be checked
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "mkcomposefs"), []byte("#!/bin/sh\ncat > \"$3\"\n"), 0o755))
+	t.Setenv("PATH", bin+":"+os.Getenv("PATH"))
+
+	toc, payload := composefsTestTOC()
+	dest := t.TempDir()
+	image, err := writeComposefsImage(dest, toc, map[string]string{payload: "verity-digest"})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dest, composefsImageName), image)
+
+	dump, err := os.ReadFile(image)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(dump)), "\n")
+	require.Len(t, lines, 3)
+	// the file refers to its flat path and carries its fs-verity digest
+	assert.True(t, strings.HasPrefix(lines[2], "/usr/file 7 100644 "), lines[2])
+	assert.True(t, strings.HasSuffix(lines[2], " "+payload+" - verity-digest"), lines[2])
+
+	// the image of a layer is written once
+	_, err = writeComposefsImage(dest, toc, nil)
+	assert.Error(t, err)
+}
+
+func TestWriteComposefsImageErofs(t *testing.T) {
+	if _, err := exec.LookPath("mkcomposefs"); err != nil {
+		t.Skip("mkcomposefs is not installed")
+	}
+	toc, payload := composefsTestTOC()
+	image, err := writeComposefsImage(t.TempDir(), toc, map[string]string{payload: "1234"})
+	require.NoError(t, err)
+
+	// the composefs header is followed by the EROFS superblock at 1024
+	data, err := os.ReadFile(image)
+	require.NoError(t, err)
+	require.Greater(t, len(data), 1028)
+	assert.Equal(t, []byte{0xe2, 0xe1, 0xf5, 0xe0}, data[1024:1028])
+}
+
+func TestPrepareMetadataComposefs(t *testing.T) {
+	d := digest.FromString("content")
+	manifest := []byte(`{"version":1,"entries":[{"type":"reg","name":"usr/file","size":7,"digest":"` + d.String() + `"}]}`)
+
+	entries, err := prepareMetadata(manifest, graphdriver.DifferOutputFormatComposefs, logrus.StandardLogger())
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, d.Encoded()[:2]+"/"+d.Encoded()[2:], entries[0].Name)
+}
+
+// mapSource is a dedup source holding the files listed in a map
+type mapSource map[digest.Digest]string
+
+func (m mapSource) Lookup(d digest.Digest) (string, error) {
+	return m[d], nil
+}
+
+func TestParseDedupSources(t *testing.T) {
+	sources, err := parseDedupSources("", "")
+	require.NoError(t, err)
+	assert.Empty(t, sources)
+
+	sources, err = parseDedupSources("/ostree/repo", "dir=/mnt/blobs:ostree=/sysroot/ostree/repo:oci=/var/lib/layout")
+	require.NoError(t, err)
+	assert.Equal(t, []DedupSource{ostreeRepo("/ostree/repo"), digestDir("/mnt/blobs"), ostreeRepo("/sysroot/ostree/repo"), &ociLayout{path: "/var/lib/layout"}}, sources)
+
+	for _, value := range []string{"/mnt/blobs", "dir=", "casync=/var/lib/casync"} {
+		_, err = parseDedupSources("", value)
+		assert.Error(t, err, value)
+	}
+
+	require.NoError(t, RegisterDedupSource("test-map", func(path string) (DedupSource, error) {
+		return mapSource{}, nil
+	}))
+	sources, err = parseDedupSources("", "test-map=/anywhere")
+	require.NoError(t, err)
+	assert.Equal(t, []DedupSource{mapSource{}}, sources)
+
+	assert.Error(t, RegisterDedupSource("test-map", nil), "already registered")
+	assert.Error(t, RegisterDedupSource("a=b", nil))
+}
+
+func TestDedupSourcesLookup(t *testing.T) {
+	d := digest.FromString("content")
+	e := d.Encoded()
+	for _, tc := range []struct {
+		source DedupSource
+		path   string
+	}{
+		{ostreeRepo("/repo"), "/repo/objects/" + e[:2] + "/" + e[2:] + ".payload-link"},
+		{digestDir("/blobs"), "/blobs/" + e[:2] + "/" + e[2:]},
+	} {
+		path, err := tc.source.Lookup(d)
+		require.NoError(t, err)
+		assert.Equal(t, tc.path, path)
+	}
+}
+
+func TestFindFileInDedupSources(t *testing.T) {
+	content := []byte("deduplicated content")
+	d := digest.FromBytes(content)
+	blob := filepath.Join(t.TempDir(), "blob")
+	require.NoError(t, os.WriteFile(blob, content, 0o644))
+
+	dest, dirfd := checkoutDir(t)
+
+	file := &internal.FileMetadata{
+		Type:   internal.TypeReg,
+		Name:   "file",
+		Size:   int64(len(content)),
+		Digest: d.String(),
+	}
+	// the first source does not have the file
+	sources := []DedupSource{digestDir(t.TempDir()), mapSource{d: blob}}
+	source, dstFile, written, err := findFileInDedupSources(file, sources, dirfd, defaultIntermediateDirMode, false, logrus.StandardLogger())
+	require.NoError(t, err)
+	require.Equal(t, sources[1], source)
+	dstFile.Close()
+	assert.Equal(t, int64(len(content)), written)
+	copied, err := os.ReadFile(filepath.Join(dest, "file"))
+	require.NoError(t, err)
+	assert.Equal(t, content, copied)
+
+	// a file of another size is not used
+	file.Name, file.Size = "other", 1
+	source, _, _, err = findFileInDedupSources(file, sources, dirfd, defaultIntermediateDirMode, false, logrus.StandardLogger())
+	require.NoError(t, err)
+	assert.Nil(t, source)
+
+	// a file of the same size with other content is not used, with or
+	// without hard links
+	tampered := []byte("deduplicated CONTENT")
+	require.NoError(t, os.WriteFile(blob, tampered, 0o644))
+	file.Name, file.Size = "tampered", int64(len(content))
+	for _, useHardLinks := range []bool{false, true} {
+		source, _, _, err = findFileInDedupSources(file, sources, dirfd, defaultIntermediateDirMode, useHardLinks, logrus.StandardLogger())
+		require.NoError(t, err)
+		assert.Nil(t, source)
+	}
+	_, err = os.Lstat(filepath.Join(dest, "tampered"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// writeOCIBlob stores data in the OCI layout at dir and returns its digest.
+func writeOCIBlob(t *testing.T, dir string, data []byte) digest.Digest {
+	d := digest.FromBytes(data)
+	blobs := filepath.Join(dir, "blobs", d.Algorithm().String())
+	require.NoError(t, os.MkdirAll(blobs, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(blobs, d.Encoded()), data, 0o644))
+	return d
+}
+
+func TestFindFileInOCILayout(t *testing.T) {
+	files := map[string][]byte{
+		"small": []byte("deduplicated content"),
+		"large": bytes.Repeat([]byte("0123456789abcdef"), 64<<10),
+	}
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	for _, name := range []string{"small", "large"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(files[name]))}))
+		_, err := tw.Write(files[name])
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	var blob bytes.Buffer
+	annotations := make(map[string]string)
+	w, err := compressor.ZstdCompressor(&blob, annotations, nil)
+	require.NoError(t, err)
+	_, err = w.Write(layer.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// the image manifest is referenced by a nested image index
+	layout := t.TempDir()
+	manifest, err := json.Marshal(ociManifest{Layers: []ociDescriptor{
+		{Digest: writeOCIBlob(t, layout, []byte("not zstd:chunked"))},
+		{Digest: writeOCIBlob(t, layout, blob.Bytes()), Annotations: annotations},
+	}})
+	require.NoError(t, err)
+	index, err := json.Marshal(ociManifest{Manifests: []ociDescriptor{{Digest: writeOCIBlob(t, layout, manifest)}}})
+	require.NoError(t, err)
+	index, err = json.Marshal(ociManifest{Manifests: []ociDescriptor{{Digest: writeOCIBlob(t, layout, index)}}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(layout, "index.json"), index, 0o644))
+
+	sources, err := parseDedupSources("", "oci="+layout)
+	require.NoError(t, err)
+
+	dest, dirfd := checkoutDir(t)
+
+	for name, content := range files {
+		file := &internal.FileMetadata{
+			Type:   internal.TypeReg,
+			Name:   name,
+			Size:   int64(len(content)),
+			Digest: digest.FromBytes(content).String(),
+		}
+		// the files are extracted, so they are copied even when hard
+		// links are requested
+		source, dstFile, written, err := findFileInDedupSources(file, sources, dirfd, defaultIntermediateDirMode, true, logrus.StandardLogger())
+		require.NoError(t, err)
+		require.Equal(t, sources[0], source, name)
+		dstFile.Close()
+		assert.Equal(t, int64(len(content)), written)
+		copied, err := os.ReadFile(filepath.Join(dest, name))
+		require.NoError(t, err)
+		assert.Equal(t, content, copied, name)
+	}
+
+	// a file that is not in the layout is not found
+	missing := []byte("missing")
+	file := &internal.FileMetadata{Type: internal.TypeReg, Name: "missing", Size: int64(len(missing)), Digest: digest.FromBytes(missing).String()}
+	source, _, _, err := findFileInDedupSources(file, sources, dirfd, defaultIntermediateDirMode, false, logrus.StandardLogger())
+	require.NoError(t, err)
+	assert.Nil(t, source)
+
+	// a layout without an index is not used
+	sources, err = parseDedupSources("", "oci="+t.TempDir())
+	require.NoError(t, err)
+	file.Name, file.Size, file.Digest = "small", int64(len(files["small"])), digest.FromBytes(files["small"]).String()
+	source, _, _, err = findFileInDedupSources(file, sources, dirfd, defaultIntermediateDirMode, false, logrus.StandardLogger())
+	require.NoError(t, err)
+	assert.Nil(t, source)
+}
+
+func TestDisableDedup(t *testing.T) {
+	content := []byte("deduplicated content")
+	d := digest.FromBytes(content)
+
+	// an OSTree repository holding the file
+	repo := t.TempDir()
+	payloadLink := d.Encoded() + ".payload-link"
+	objects := filepath.Join(repo, "objects", payloadLink[:2])
+	require.NoError(t, os.MkdirAll(objects, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(objects, payloadLink[2:]), content, 0o644))
+
+	c := &chunkedDiffer{
+		layersCache: &layersCache{},
+		useFsVerity: graphdriver.DifferFsVerityDisabled,
+	}
+
+	for _, disableDedup := range []bool{false, true} {
+		dest, dirfd := checkoutDir(t)
+
+		file := &internal.FileMetadata{
+			Type:   internal.TypeReg,
+			Name:   "file",
+			Size:   int64(len(content)),
+			Digest: d.String(),
+			Mode:   0o644,
+			UID:    os.Getuid(),
+			GID:    os.Getgid(),
+		}
+		copyOptions := &findAndCopyFileOptions{
+			dedupSources: []DedupSource{ostreeRepo(repo)},
+			options:      &archive.TarOptions{IgnoreChownErrors: true},
+			disableDedup: disableDedup,
+		}
+		found, err := c.findAndCopyFile(dirfd, file, copyOptions, 0o644)
+		require.NoError(t, err)
+		if disableDedup {
+			// the file is left to be fetched and nothing was written
+			assert.False(t, found)
+			assert.NoFileExists(t, filepath.Join(dest, "file"))
+		} else {
+			assert.True(t, found)
+			assert.FileExists(t, filepath.Join(dest, "file"))
+		}
+	}
+}
+
+func TestDisableDedupChunks(t *testing.T) {
+	content := []byte("deduplicated chunk")
+	chunk := &internal.FileMetadata{
+		Type:        internal.TypeChunk,
+		Name:        "file",
+		ChunkSize:   int64(len(content)),
+		ChunkDigest: digest.FromBytes(content).String(),
+	}
+
+	// a layer in the dedup index holding the chunk
+	target := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(target, "file"), content, 0o644))
+	c := &chunkedDiffer{
+		layersCache: &layersCache{
+			indexedLayers: map[string]struct{}{"layer1": {}},
+			indexed: map[string]*dedupindex.Location{
+				chunk.ChunkDigest: {Layer: "layer1", Target: target, Path: "file"},
+			},
+		},
+		partDecoder: partDecoder{copyBuffer: makeCopyBuffer()},
+	}
+	origin, err := c.findChunk(chunk, &findAndCopyFileOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &originFile{Root: target, Path: "file"}, origin)
+
+	// with deduplication disabled, the differ has no layers cache and the
+	// chunk is not looked up in the other layers
+	storeOpts := &storage.StoreOptions{PullOptions: map[string]string{"disable_dedup": "true"}}
+	layersCache, err := getDedupLayersCache(nil, storeOpts, nil)
+	require.NoError(t, err)
+	assert.Nil(t, layersCache)
+	c.layersCache = layersCache
+	origin, err = c.findChunk(chunk, &findAndCopyFileOptions{disableDedup: true})
+	require.NoError(t, err)
+	assert.Nil(t, origin)
+}
+
+// prepareHardLinks creates n files in a new directory and returns the hard
+// links to create for each of them, all in the same missing directory
+func prepareHardLinks(tb testing.TB, n int) (string, []hardLinkToCreate) {
+	tb.Helper()
+	dest, dirfd := checkoutDir(tb)
+
+	links := make([]hardLinkToCreate, 0, n)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("file%d", i)
+		require.NoError(tb, os.WriteFile(filepath.Join(dest, name), []byte(name), 0o644))
+		links = append(links, hardLinkToCreate{
+			dest:  dest,
+			dirfd: dirfd,
+			mode:  0o644,
+			metadata: &internal.FileMetadata{
+				Type:     internal.TypeLink,
+				Name:     filepath.Join("links", name),
+				Linkname: name,
+				Mode:     0o644,
+				UID:      os.Getuid(),
+				GID:      os.Getgid(),
+			},
+		})
+	}
+	return dest, links
+}
+
+func inode(t *testing.T, path string) uint64 {
+	t.Helper()
+	st, err := os.Lstat(path)
+	require.NoError(t, err)
+	return st.Sys().(*syscall.Stat_t).Ino
+}
+
+func TestCreateHardLinks(t *testing.T) {
+	dest, links := prepareHardLinks(t, 200)
+	dirfd := links[0].dirfd
+	options := &archive.TarOptions{IgnoreChownErrors: true}
+
+	// a link to a link and a link to a symlink
+	require.NoError(t, os.Symlink("file0", filepath.Join(dest, "symlink")))
+	chained := []hardLinkToCreate{
+		{dest: dest, dirfd: dirfd, mode: 0o644, metadata: &internal.FileMetadata{
+			Type: internal.TypeLink, Name: "chain/second", Linkname: "chain/first", UID: os.Getuid(), GID: os.Getgid(),
+		}},
+		{dest: dest, dirfd: dirfd, mode: 0o644, metadata: &internal.FileMetadata{
+			Type: internal.TypeLink, Name: "chain/first", Linkname: "file1", UID: os.Getuid(), GID: os.Getgid(),
+		}},
+		{dest: dest, dirfd: dirfd, mode: 0o777, metadata: &internal.FileMetadata{
+			Type: internal.TypeLink, Name: "symlink-link", Linkname: "symlink", UID: os.Getuid(), GID: os.Getgid(),
+		}},
+	}
+	require.NoError(t, createHardLinks(append(chained, links...), options, copyGoRoutines, logrus.StandardLogger()))
+
+	for _, m := range links {
+		assert.Equal(t, inode(t, filepath.Join(dest, m.metadata.Linkname)), inode(t, filepath.Join(dest, m.metadata.Name)))
+	}
+	assert.Equal(t, inode(t, filepath.Join(dest, "file1")), inode(t, filepath.Join(dest, "chain/second")))
+	assert.Equal(t, inode(t, filepath.Join(dest, "symlink")), inode(t, filepath.Join(dest, "symlink-link")))
+}
+
+func TestCreateHardLinksErrors(t *testing.T) {
+	dest, links := prepareHardLinks(t, 50)
+	require.NoError(t, os.Remove(filepath.Join(dest, "file7")))
+	err := createHardLinks(links, &archive.TarOptions{IgnoreChownErrors: true}, copyGoRoutines, logrus.StandardLogger())
+	assert.ErrorContains(t, err, "file7")
+
+	loop := []hardLinkToCreate{
+		{dirfd: links[0].dirfd, metadata: &internal.FileMetadata{Name: "a", Linkname: "b"}},
+		{dirfd: links[0].dirfd, metadata: &internal.FileMetadata{Name: "b", Linkname: "a"}},
+	}
+	assert.ErrorContains(t, createHardLinks(loop, &archive.TarOptions{}, copyGoRoutines, logrus.StandardLogger()), "loop")
+}
+
+func BenchmarkCreateHardLinks(b *testing.B) {
+	for _, workers := range []int{1, copyGoRoutines} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			options := &archive.TarOptions{IgnoreChownErrors: true}
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				_, links := prepareHardLinks(b, 5000)
+				b.StartTimer()
+				if err := createHardLinks(links, options, workers, logrus.StandardLogger()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCloneOriginChunk(t *testing.T) {
+	srcPath := filepath.Join(t.TempDir(), "origin")
+	require.NoError(t, os.WriteFile(srcPath, []byte("0123456789"), 0o644))
+
+	for _, skipValidation := range []bool{false, true} {
+		dest, dirfd := checkoutDir(t)
+
+		src, err := os.Open(srcPath)
+		require.NoError(t, err)
+		defer src.Close()
+		_, err = src.Seek(2, io.SeekStart)
+		require.NoError(t, err)
+
+		metadata := &internal.FileMetadata{
+			Type:   internal.TypeReg,
+			Name:   "file",
+			Size:   5,
+			Digest: digest.FromString("23456").String(),
+			Mode:   0o644,
+			UID:    os.Getuid(),
+			GID:    os.Getgid(),
+		}
+		destFile, err := openDestinationFile(dirfd, metadata, &archive.TarOptions{}, skipValidation, nil, logrus.StandardLogger())
+		require.NoError(t, err)
+
+		dec := partDecoder{copyBuffer: makeCopyBuffer(), rawReader: io.LimitReader(src, 5)}
+		require.NoError(t, dec.cloneOriginChunk(src, destFile, 5))
+		// the destination file is validated when it is closed
+		require.NoError(t, destFile.Close())
+
+		content, err := os.ReadFile(filepath.Join(dest, "file"))
+		require.NoError(t, err)
+		assert.Equal(t, "23456", string(content))
+
+		// the origin file is past the chunk
+		off, err := src.Seek(0, io.SeekCurrent)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), off)
+	}
+}
+
+func TestCloneFileRange(t *testing.T) {
+	dir := t.TempDir()
+	src, err := os.Create(filepath.Join(dir, "src"))
+	require.NoError(t, err)
+	defer src.Close()
+	_, err = src.WriteString("0123456789")
+	require.NoError(t, err)
+
+	dst, err := os.Create(filepath.Join(dir, "dst"))
+	require.NoError(t, err)
+	defer dst.Close()
+	_, err = dst.WriteString("ab")
+	require.NoError(t, err)
+
+	require.NoError(t, cloneFileRange(int(src.Fd()), 4, int(dst.Fd()), 2, 3))
+	content, err := os.ReadFile(dst.Name())
+	require.NoError(t, err)
+	assert.Equal(t, "ab456", string(content))
+
+	// reading past the end of the source fails
+	assert.Error(t, cloneFileRange(int(src.Fd()), 8, int(dst.Fd()), 5, 5))
+}
+
+func statT(t *testing.T, path string) *syscall.Stat_t {
+	t.Helper()
+	st, err := os.Lstat(path)
+	require.NoError(t, err)
+	return st.Sys().(*syscall.Stat_t)
+}
+
+func TestMetadataDelta(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing the ownership of files requires root")
+	}
+
+	dest, dirfd := checkoutDir(t)
+
+	// the existing checkout, owned by root
+	content := []byte("unchanged content")
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "same"), content, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "changed"), []byte("old content"), 0o644))
+	require.NoError(t, os.Symlink("same", filepath.Join(dest, "link")))
+	sameIno := statT(t, filepath.Join(dest, "same")).Ino
+	linkIno := statT(t, filepath.Join(dest, "link")).Ino
+
+	// the layer only changes the ownership
+	const uid, gid = 1000, 1000
+	c := &chunkedDiffer{
+		layersCache: &layersCache{},
+		useFsVerity: graphdriver.DifferFsVerityDisabled,
+	}
+	copyOptions := &findAndCopyFileOptions{
+		options:      &archive.TarOptions{MetadataDelta: true},
+		disableDedup: true,
+	}
+	file := func(name string, content []byte) *internal.FileMetadata {
+		return &internal.FileMetadata{
+			Type:   internal.TypeReg,
+			Name:   name,
+			Size:   int64(len(content)),
+			Digest: digest.FromBytes(content).String(),
+			Mode:   0o600,
+			UID:    uid,
+			GID:    gid,
+		}
+	}
+
+	found, err := c.findAndCopyFile(dirfd, file("same", content), copyOptions, 0o600)
+	require.NoError(t, err)
+	assert.True(t, found)
+	st := statT(t, filepath.Join(dest, "same"))
+	assert.Equal(t, sameIno, st.Ino, "the content was rewritten")
+	assert.Equal(t, uint32(uid), st.Uid)
+	assert.Equal(t, uint32(gid), st.Gid)
+	assert.Equal(t, uint32(0o600), st.Mode&0o7777)
+	data, err := os.ReadFile(filepath.Join(dest, "same"))
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+
+	// a file with a different content is removed so that it is fetched
+	found, err = c.findAndCopyFile(dirfd, file("changed", []byte("new content")), copyOptions, 0o600)
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.NoFileExists(t, filepath.Join(dest, "changed"))
+
+	link := &internal.FileMetadata{
+		Type:     internal.TypeSymlink,
+		Name:     "link",
+		Linkname: "same",
+		UID:      uid,
+		GID:      gid,
+	}
+	require.NoError(t, safeSymlink(dirfd, 0o777, link, copyOptions.options))
+	assert.Equal(t, linkIno, statT(t, filepath.Join(dest, "link")).Ino)
+
+	link.Linkname = "changed"
+	require.NoError(t, safeSymlink(dirfd, 0o777, link, copyOptions.options))
+	target, err := os.Readlink(filepath.Join(dest, "link"))
+	require.NoError(t, err)
+	assert.Equal(t, "changed", target)
+
+	// without the option, the existing files are in the way
+	assert.Error(t, safeSymlink(dirfd, 0o777, link, &archive.TarOptions{}))
+}
+
+func assertDirMode(t *testing.T, path string, expected os.FileMode) {
+	t.Helper()
+	st, err := os.Stat(path)
+	require.NoError(t, err)
+	require.True(t, st.IsDir(), path)
+	assert.Equal(t, expected, st.Mode().Perm(), path)
+}
+
+func TestIntermediateDirMode(t *testing.T) {
+	oldUmask := unix.Umask(0)
+	defer unix.Umask(oldUmask)
+
+	dirMode := os.FileMode(0o700)
+	options := &archive.TarOptions{
+		IgnoreChownErrors:   true,
+		IntermediateDirMode: &dirMode,
+	}
+
+	dest, dirfd := checkoutDir(t)
+
+	// A directory listed in the TOC keeps its own mode, its missing parents
+	// get the configured one.
+	dir := &internal.FileMetadata{
+		Type: internal.TypeDir,
+		Name: "a/b/c",
+		UID:  os.Getuid(),
+		GID:  os.Getgid(),
+	}
+	require.NoError(t, safeMkdir(dirfd, 0o750, dir.Name, dir, options, logrus.StandardLogger()))
+	assertDirMode(t, filepath.Join(dest, "a"), 0o700)
+	assertDirMode(t, filepath.Join(dest, "a/b"), 0o700)
+	assertDirMode(t, filepath.Join(dest, "a/b/c"), 0o750)
+
+	file := &internal.FileMetadata{
+		Type: internal.TypeReg,
+		Name: "x/y/file",
+		UID:  os.Getuid(),
+		GID:  os.Getgid(),
+	}
+	f, err := openDestinationFile(dirfd, file, options, true, nil, logrus.StandardLogger())
+	require.NoError(t, err)
+	require.NoError(t, f.file.Close())
+	assertDirMode(t, filepath.Join(dest, "x"), 0o700)
+	assertDirMode(t, filepath.Join(dest, "x/y"), 0o700)
+
+	// Without a configured mode the parents are created with the default.
+	f, err = openDestinationFile(dirfd, &internal.FileMetadata{Name: "d/file"}, &archive.TarOptions{}, true, nil, logrus.StandardLogger())
+	require.NoError(t, err)
+	require.NoError(t, f.file.Close())
+	assertDirMode(t, filepath.Join(dest, "d"), defaultIntermediateDirMode)
+}
+
+func TestParseIntermediateDirMode(t *testing.T) {
+	mode, err := parseIntermediateDirMode("")
+	require.NoError(t, err)
+	assert.Nil(t, mode)
+
+	for _, value := range []string{"0700", "0o700", "700"} {
+		mode, err = parseIntermediateDirMode(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, os.FileMode(0o700), *mode, value)
+	}
+
+	for _, value := range []string{"0o17777", "rwx", "0o9"} {
+		_, err = parseIntermediateDirMode(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestSetFileAttrsModeNormalization(t *testing.T) {
+	policy, err := archive.ParseModeNormalization("clear-setuid,clear-setgid,clear-world-write")
+	require.NoError(t, err)
+	options := &archive.TarOptions{
+		IgnoreChownErrors: true,
+		ModeNormalization: policy,
+	}
+
+	for _, tc := range []struct {
+		mode     os.FileMode
+		expected os.FileMode
+	}{
+		{0o4755, 0o755},
+		{0o2755, 0o755},
+		{0o777, 0o775},
+		{0o644, 0o644},
+		{0o755, 0o755},
+	} {
+		dir := t.TempDir()
+		f, err := os.Create(filepath.Join(dir, "file"))
+		require.NoError(t, err)
+
+		metadata := &internal.FileMetadata{
+			Type: internal.TypeReg,
+			Name: "file",
+			UID:  os.Getuid(),
+			GID:  os.Getgid(),
+		}
+		l := &capturingLogger{}
+		err = setFileAttrs(-1, f, tc.mode, metadata, options, false, l)
+		f.Close()
+		require.NoError(t, err)
+		assert.Equal(t, tc.mode != tc.expected, l.contains("debug", "Normalized mode of \"file\""), l.lines)
+
+		st, err := os.Stat(filepath.Join(dir, "file"))
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, st.Mode().Perm(), "mode 0%o", tc.mode)
+		assert.Zero(t, st.Mode()&(os.ModeSetuid|os.ModeSetgid), "mode 0%o", tc.mode)
+	}
+}
+
+func TestParseModeNormalization(t *testing.T) {
+	policy, err := archive.ParseModeNormalization("")
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+	assert.Equal(t, os.FileMode(0o4755), policy.Normalize(0o4755))
+
+	policy, err = archive.ParseModeNormalization("0o022")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o4755), policy.Normalize(0o4777))
+
+	_, err = archive.ParseModeNormalization("clear-everything")
+	assert.Error(t, err)
+	_, err = archive.ParseModeNormalization("0o17777")
+	assert.Error(t, err)
+}
+
+// extractWithClamp extracts a small layer into a new directory the way
+// ApplyDiff does, with the per-file times set to the current time, and
+// returns the modification times of everything it created.
+func extractWithClamp(t *testing.T, options *archive.TarOptions) map[string]time.Time {
+	t.Helper()
+	dest, dirfd := checkoutDir(t)
+
+	now := time.Now()
+	entry := func(typ, name string) *internal.FileMetadata {
+		return &internal.FileMetadata{
+			Type:       typ,
+			Name:       name,
+			Mode:       0o755,
+			UID:        os.Getuid(),
+			GID:        os.Getgid(),
+			ModTime:    &now,
+			AccessTime: &now,
+		}
+	}
+
+	dir := entry(internal.TypeDir, "usr")
+	require.NoError(t, safeMkdir(dirfd, 0o755, dir.Name, dir, options, logrus.StandardLogger()))
+
+	// the parent "usr/lib" of the file is not part of the layer
+	file := entry(internal.TypeReg, "usr/lib/file")
+	f, err := openDestinationFile(dirfd, file, options, true, nil, logrus.StandardLogger())
+	require.NoError(t, err)
+	_, err = f.to.Write([]byte("content"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	link := entry(internal.TypeSymlink, "usr/link")
+	link.Linkname = "lib/file"
+	require.NoError(t, safeSymlink(dirfd, 0o777, link, options))
+
+	require.NoError(t, clampDirTimes(dest, options))
+
+	mtimes := make(map[string]time.Time)
+	err = filepath.WalkDir(dest, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dest, path)
+		if err != nil {
+			return err
+		}
+		mtimes[rel] = info.ModTime()
+		return nil
+	})
+	require.NoError(t, err)
+	return mtimes
+}
+
+func TestClampMtime(t *testing.T) {
+	clamp := time.Unix(1700000000, 0)
+	options := &archive.TarOptions{
+		IgnoreChownErrors: true,
+		ClampMtime:        &clamp,
+	}
+
+	first := extractWithClamp(t, options)
+	time.Sleep(10 * time.Millisecond)
+	second := extractWithClamp(t, options)
+
+	assert.Equal(t, first, second)
+	assert.Len(t, first, 5)
+	for path, mtime := range first {
+		assert.True(t, clamp.Equal(mtime), "%s has mtime %s", path, mtime)
+	}
+
+	// without the clamp, the times of the layer are used
+	unclamped := extractWithClamp(t, &archive.TarOptions{IgnoreChownErrors: true})
+	assert.False(t, clamp.Equal(unclamped["usr/lib/file"]))
+}
+
+func TestParseClampMtime(t *testing.T) {
+	mtime, err := parseClampMtime("")
+	require.NoError(t, err)
+	assert.Nil(t, mtime)
+
+	mtime, err = parseClampMtime("1700000000")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1700000000), mtime.Unix())
+
+	for _, value := range []string{"-1", "yesterday", "1.5"} {
+		_, err = parseClampMtime(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestUserNSXattr(t *testing.T) {
+	// cap_net_bind_service=ep for root ID 100000
+	v3 := make([]byte, xattrCapsSize3)
+	binary.LittleEndian.PutUint32(v3[0:], vfsCapRevision3|1)
+	binary.LittleEndian.PutUint32(v3[4:], 1<<10)
+	binary.LittleEndian.PutUint32(v3[20:], 100000)
+
+	name, value := userNSXattr(capabilityXattr, v3)
+	assert.Equal(t, capabilityXattr, name)
+	assert.Len(t, value, xattrCapsSize2)
+	assert.Equal(t, uint32(vfsCapRevision2|1), binary.LittleEndian.Uint32(value[0:]))
+	assert.Equal(t, uint32(1<<10), binary.LittleEndian.Uint32(value[4:]))
+
+	// the revision 2 capabilities are already tied to the namespace root
+	name, value = userNSXattr(capabilityXattr, value)
+	assert.Equal(t, capabilityXattr, name)
+	assert.Len(t, value, xattrCapsSize2)
+
+	name, value = userNSXattr("trusted.overlay.opaque", []byte("y"))
+	assert.Equal(t, "user.containers.trusted.overlay.opaque", name)
+	assert.Equal(t, []byte("y"), value)
+
+	name, value = userNSXattr("user.comment", []byte("value"))
+	assert.Equal(t, "user.comment", name)
+	assert.Equal(t, []byte("value"), value)
+}
+
+func whiteoutTestEntries() []internal.FileMetadata {
+	return []internal.FileMetadata{
+		{Type: internal.TypeDir, Name: "tmp/"},
+		{Type: internal.TypeReg, Name: "tmp/.wh.scratch"},
+		{Type: internal.TypeReg, Name: "var/cache/dnf/.wh..wh..opq"},
+		{Type: internal.TypeReg, Name: "usr/bin/tool"},
+		// the unexpected one
+		{Type: internal.TypeReg, Name: "etc/.wh.passwd"},
+	}
+}
+
+func TestAuditWhiteouts(t *testing.T) {
+	policy, err := archive.ParseWhiteoutPolicy("deny", "/tmp:/var/cache")
+	require.NoError(t, err)
+
+	whiteouts, err := auditWhiteouts(whiteoutTestEntries(), policy, logrus.StandardLogger())
+	assert.ErrorContains(t, err, "etc/passwd")
+	assert.NotContains(t, err.Error(), "tmp/scratch")
+	assert.Equal(t, []string{"tmp/scratch", "var/cache/dnf", "etc/passwd"}, whiteouts)
+
+	// only reported
+	policy.ReportOnly = true
+	whiteouts, err = auditWhiteouts(whiteoutTestEntries(), policy, logrus.StandardLogger())
+	require.NoError(t, err)
+	assert.Len(t, whiteouts, 3)
+
+	// allowed explicitly
+	policy, err = archive.ParseWhiteoutPolicy("deny", "/tmp:/var/cache:/etc/passwd")
+	require.NoError(t, err)
+	_, err = auditWhiteouts(whiteoutTestEntries(), policy, logrus.StandardLogger())
+	require.NoError(t, err)
+
+	// a deny policy without allowed paths rejects every whiteout
+	policy, err = archive.ParseWhiteoutPolicy("deny", "")
+	require.NoError(t, err)
+	_, err = auditWhiteouts(whiteoutTestEntries(), policy, logrus.StandardLogger())
+	assert.ErrorContains(t, err, "tmp/scratch, var/cache/dnf, etc/passwd")
+}
+
+func TestParseWhiteoutPolicy(t *testing.T) {
+	policy, err := archive.ParseWhiteoutPolicy("", "/tmp")
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+	assert.True(t, policy.Allows("etc/passwd"))
+
+	policy, err = archive.ParseWhiteoutPolicy("warn", "/tmp/:var//cache")
+	require.NoError(t, err)
+	assert.Equal(t, &archive.WhiteoutPolicy{Allowed: []string{"tmp", "var/cache"}, ReportOnly: true}, policy)
+	assert.True(t, policy.Allows("/tmp/x"))
+	assert.False(t, policy.Allows("tmpfoo"))
+	assert.False(t, policy.Allows("var"))
+
+	_, err = archive.ParseWhiteoutPolicy("block", "")
+	assert.Error(t, err)
+}
+
+func TestFsVeritySignatures(t *testing.T) {
+	s, err := newFsVeritySignatures(nil, "")
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	_, err = newFsVeritySignatures(map[string]string{fsVeritySignaturesAnnotation: "not json"}, "")
+	assert.Error(t, err)
+
+	fromAnnotation := digest.FromString("signed in the annotation")
+	fromDir := digest.FromString("signed in the directory")
+	annotation, err := json.Marshal(map[digest.Digest][]byte{fromAnnotation: []byte("annotation signature")})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sha256"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sha256", fromDir.Encoded()), []byte("dir signature"), 0o644))
+
+	s, err = newFsVeritySignatures(map[string]string{fsVeritySignaturesAnnotation: string(annotation)}, dir)
+	require.NoError(t, err)
+	signature, err := s.lookup(fromAnnotation.String())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("annotation signature"), signature)
+	signature, err = s.lookup(fromDir.String())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("dir signature"), signature)
+	signature, err = s.lookup(digest.FromString("unsigned").String())
+	require.NoError(t, err)
+	assert.Nil(t, signature)
+	// invalid digests do not escape the directory
+	signature, err = s.lookup("sha256/../../etc/passwd")
+	require.NoError(t, err)
+	assert.Nil(t, signature)
+
+	// the files without a signature are recorded
+	c := &chunkedDiffer{
+		useFsVerity:        graphdriver.DifferFsVerityEnabled,
+		fsVerityDigests:    make(map[string]string),
+		fsVeritySignatures: s,
+	}
+	f, err := os.Open(filepath.Join(dir, "sha256", fromDir.Encoded()))
+	require.NoError(t, err)
+	defer f.Close()
+	_ = c.recordFsVerity(&internal.FileMetadata{Name: "unsigned", Digest: digest.FromString("unsigned").String()}, f)
+	_ = c.recordFsVerity(&internal.FileMetadata{Name: "signed", Digest: fromDir.String()}, f)
+	assert.Equal(t, []string{"unsigned"}, c.fsVerityUnsigned)
+}
+
+type capturingLogger struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (l *capturingLogger) log(level, format string, args ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines = append(l.lines, level+": "+fmt.Sprintf(format, args...))
+}
+
+func (l *capturingLogger) Debugf(format string, args ...interface{}) { l.log("debug", format, args...) }
+func (l *capturingLogger) Infof(format string, args ...interface{})  { l.log("info", format, args...) }
+func (l *capturingLogger) Warnf(format string, args ...interface{})  { l.log("warn", format, args...) }
+func (l *capturingLogger) Errorf(format string, args ...interface{}) { l.log("error", format, args...) }
+
+func (l *capturingLogger) contains(level, substr string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, level+": ") && strings.Contains(line, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	assert.Equal(t, logrus.StandardLogger(), loggerFromContext(context.Background()))
+	assert.Equal(t, logrus.StandardLogger(), loggerFromContext(WithLogger(context.Background(), nil)))
+
+	l := &capturingLogger{}
+	assert.Equal(t, l, loggerFromContext(WithLogger(context.Background(), l)))
+}
+
+func TestDifferLogger(t *testing.T) {
+	l := &capturingLogger{}
+	c := &chunkedDiffer{
+		layersCache: &layersCache{},
+		useFsVerity: graphdriver.DifferFsVerityDisabled,
+		logger:      l,
+	}
+
+	_, dirfd := checkoutDir(t)
+
+	// the dedup lookup in the OSTree repos logs why the file is skipped
+	file := &internal.FileMetadata{
+		Type:   internal.TypeReg,
+		Name:   "file",
+		Digest: "not-a-digest",
+		Mode:   0o644,
+		UID:    os.Getuid(),
+		GID:    os.Getgid(),
+	}
+	copyOptions := &findAndCopyFileOptions{
+		dedupSources: []DedupSource{ostreeRepo(t.TempDir())},
+		options:      &archive.TarOptions{},
+	}
+	found, err := c.findAndCopyFile(dirfd, file, copyOptions, 0o644)
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.True(t, l.contains("debug", "could not parse digest"), l.lines)
+
+	// so does the whiteout audit
+	policy, err := archive.ParseWhiteoutPolicy("warn", "/tmp")
+	require.NoError(t, err)
+	_, err = auditWhiteouts([]internal.FileMetadata{{Type: internal.TypeReg, Name: "etc/.wh.passwd"}}, policy, c.log())
+	require.NoError(t, err)
+	assert.True(t, l.contains("warn", "whiteouts outside of the allowed paths: etc/passwd"), l.lines)
+
+	// a differ without a logger uses the standard logrus logger
+	assert.Equal(t, logrus.StandardLogger(), (&chunkedDiffer{}).log())
+}
+
+// fakeBlob serves the ranges of an uncompressed blob and counts the requests
+type fakeBlob struct {
+	data []byte
+
+	lock     sync.Mutex
+	requests int
+}
+
+func (b *fakeBlob) GetBlobAt(chunks []ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	b.lock.Lock()
+	b.requests++
+	b.lock.Unlock()
+	streams := make(chan io.ReadCloser, len(chunks))
+	for _, c := range chunks {
+		streams <- io.NopCloser(bytes.NewReader(b.data[c.Offset : c.Offset+c.Length]))
+	}
+	close(streams)
+	return streams, make(chan error), nil
+}
+
+// missingFilesLayer returns files with their content in blob and the parts
+// to retrieve them.  The last file is split in two parts.
+func missingFilesLayer(n int) ([]*internal.FileMetadata, *fakeBlob, []missingPart) {
+	var (
+		files []*internal.FileMetadata
+		parts []missingPart
+		blob  bytes.Buffer
+	)
+	addPart := func(file *internal.FileMetadata, content []byte) {
+		chunk := &ImageSourceChunk{Offset: uint64(blob.Len()), Length: uint64(len(content))}
+		blob.Write(content)
+		parts = append(parts, missingPart{
+			SourceChunk: chunk,
+			Chunks: []missingFileChunk{{
+				File:             file,
+				CompressedSize:   int64(len(content)),
+				UncompressedSize: int64(len(content)),
+			}},
+		})
+	}
+	for i := 0; i < n; i++ {
+		content := bytes.Repeat([]byte(fmt.Sprintf("file %d\n", i)), 100)
+		file := &internal.FileMetadata{
+			Type:   internal.TypeReg,
+			Name:   fmt.Sprintf("file%d", i),
+			Size:   int64(len(content)),
+			Digest: digest.FromBytes(content).String(),
+			Mode:   0o644,
+			UID:    os.Getuid(),
+			GID:    os.Getgid(),
+		}
+		files = append(files, file)
+		if i == n-1 {
+			addPart(file, content[:len(content)/2])
+			addPart(file, content[len(content)/2:])
+		} else {
+			addPart(file, content)
+		}
+	}
+	return files, &fakeBlob{data: blob.Bytes()}, parts
+}
+
+func TestRetrieveMissingFilesConcurrency(t *testing.T) {
+	for _, concurrency := range []int{1, 4} {
+		files, blob, parts := missingFilesLayer(8)
+		c := &chunkedDiffer{
+			fileType:    fileTypeNoCompression,
+			partDecoder: partDecoder{copyBuffer: makeCopyBuffer()},
+			useFsVerity: graphdriver.DifferFsVerityDisabled,
+		}
+		dest, dirfd := checkoutDir(t)
+
+		err := c.retrieveMissingFiles(blob, dest, dirfd, parts, &archive.TarOptions{IgnoreChownErrors: true}, concurrency)
+		require.NoError(t, err)
+		assert.Equal(t, concurrency, blob.requests)
+		for _, file := range files {
+			content, err := os.ReadFile(filepath.Join(dest, file.Name))
+			require.NoError(t, err)
+			assert.Equal(t, file.Digest, digest.FromBytes(content).String(), file.Name)
+		}
+	}
+}
+
+func TestSplitMissingParts(t *testing.T) {
+	_, _, parts := missingFilesLayer(4)
+
+	assert.Equal(t, [][]missingPart{parts}, splitMissingParts(parts, 1))
+
+	groups := splitMissingParts(parts, 2)
+	require.Len(t, groups, 2)
+	assert.Len(t, groups[0], 2)
+	assert.Len(t, groups[1], 3)
+
+	// the two parts of the last file stay together
+	groups = splitMissingParts(parts, 5)
+	require.Len(t, groups, 4)
+	assert.Len(t, groups[3], 2)
+	assert.Equal(t, "file3", groups[3][0].Chunks[0].File.Name)
+	assert.Equal(t, "file3", groups[3][1].Chunks[0].File.Name)
+}
+
+func TestParsePartialPullConcurrency(t *testing.T) {
+	n, err := parsePartialPullConcurrency("")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	n, err = parsePartialPullConcurrency("8")
+	require.NoError(t, err)
+	assert.Equal(t, 8, n)
+
+	for _, value := range []string{"0", "-2", "many"} {
+		_, err = parsePartialPullConcurrency(value)
+		assert.Error(t, err, value)
+	}
+}
+
+// flakyBlob fails the requests of chunks matching fails, and serves the
+// other ones from blob.
+type flakyBlob struct {
+	*fakeBlob
+	fails func(chunks []ImageSourceChunk) bool
+	// err is returned by the failing requests, a reset connection if nil.
+	err      error
+	failures int
+}
+
+func (b *flakyBlob) GetBlobAt(chunks []ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	if b.fails(chunks) {
+		b.failures++
+		if b.err != nil {
+			return nil, nil, b.err
+		}
+		return nil, nil, fmt.Errorf("reading response: %w", syscall.ECONNRESET)
+	}
+	return b.fakeBlob.GetBlobAt(chunks)
+}
+
+func TestRetrieveMissingFilesRetries(t *testing.T) {
+	files, blob, parts := missingFilesLayer(4)
+	wholeBlob := func(chunks []ImageSourceChunk) bool {
+		return len(chunks) == 1 && chunks[0].Offset == 0 && chunks[0].Length == uint64(len(blob.data))
+	}
+
+	for _, tc := range []struct {
+		name            string
+		fails           func(chunks []ImageSourceChunk) bool
+		retries         int
+		success         bool
+		failures        int
+		wholeDownloaded bool
+	}{
+		{
+			name: "transient failure",
+			fails: func() func([]ImageSourceChunk) bool {
+				n := 0
+				return func([]ImageSourceChunk) bool { n++; return n <= 2 }
+			}(),
+			retries:  3,
+			success:  true,
+			failures: 2,
+		},
+		{
+			name:            "ranges keep failing",
+			fails:           func(chunks []ImageSourceChunk) bool { return !wholeBlob(chunks) },
+			retries:         2,
+			success:         true,
+			failures:        3,
+			wholeDownloaded: true,
+		},
+		{
+			name:    "everything fails",
+			fails:   func([]ImageSourceChunk) bool { return true },
+			retries: 1,
+			// the download of the whole blob is not retried again
+			failures: 3,
+		},
+	} {
+		source := &flakyBlob{fakeBlob: &fakeBlob{data: blob.data}, fails: tc.fails}
+		c := &chunkedDiffer{
+			fileType:    fileTypeNoCompression,
+			partDecoder: partDecoder{copyBuffer: makeCopyBuffer()},
+			useFsVerity: graphdriver.DifferFsVerityDisabled,
+			stream:      source,
+			blobSize:    int64(len(blob.data)),
+			retries:     tc.retries,
+			retryDelay:  time.Millisecond,
+		}
+		dest, dirfd := checkoutDir(t)
+
+		err := c.retrieveMissingFiles(source, dest, dirfd, parts, &archive.TarOptions{IgnoreChownErrors: true}, 1)
+		assert.Equal(t, tc.failures, source.failures, tc.name)
+		assert.Equal(t, tc.wholeDownloaded, c.wholeBlobFile != nil, tc.name)
+		if c.wholeBlobFile != nil {
+			c.wholeBlobFile.Close()
+		}
+		if !tc.success {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		require.NoError(t, err, tc.name)
+		for _, file := range files {
+			content, err := os.ReadFile(filepath.Join(dest, file.Name))
+			require.NoError(t, err)
+			assert.Equal(t, file.Digest, digest.FromBytes(content).String(), file.Name)
+		}
+	}
+}
+
+func TestParseRetryOptions(t *testing.T) {
+	retries, err := parsePartialPullRetries("")
+	require.NoError(t, err)
+	assert.Equal(t, 0, retries)
+	retries, err = parsePartialPullRetries("3")
+	require.NoError(t, err)
+	assert.Equal(t, 3, retries)
+	retries, err = parsePartialPullRetries("0")
+	require.NoError(t, err)
+	assert.Equal(t, 0, retries)
+	for _, value := range []string{"-1", "many"} {
+		_, err := parsePartialPullRetries(value)
+		assert.Error(t, err, value)
+	}
+
+	delay, err := parsePartialPullRetryDelay("")
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, delay)
+	delay, err = parsePartialPullRetryDelay("250ms")
+	require.NoError(t, err)
+	assert.Equal(t, 250*time.Millisecond, delay)
+	for _, value := range []string{"-1s", "soon"} {
+		_, err := parsePartialPullRetryDelay(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestGetBlobAtWithRetriesTransient(t *testing.T) {
+	chunks := []ImageSourceChunk{{Offset: 0, Length: 1}}
+	for _, tc := range []struct {
+		err      error
+		attempts int
+	}{
+		{err: fmt.Errorf("reading response: %w", syscall.ECONNRESET), attempts: 3},
+		{err: io.ErrUnexpectedEOF, attempts: 3},
+		{err: ErrTransient{Err: errors.New("503 Service Unavailable")}, attempts: 3},
+		{err: errors.New("fetching partial blob: 401 Unauthorized"), attempts: 1},
+		{err: errors.New("fetching partial blob: blob unknown to registry"), attempts: 1},
+		{err: ErrBadRequest{}, attempts: 1},
+	} {
+		source := &flakyBlob{fakeBlob: &fakeBlob{}, fails: func([]ImageSourceChunk) bool { return true }, err: tc.err}
+		c := &chunkedDiffer{retryDelay: time.Millisecond}
+		_, _, err := c.getBlobAtWithRetries(source, chunks, 2)
+		assert.ErrorIs(t, err, tc.err)
+		assert.Equal(t, tc.attempts, source.failures, tc.err.Error())
+	}
+}
+
+func TestGetBlobAtWithRetriesCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	source := &flakyBlob{fakeBlob: &fakeBlob{}, fails: func([]ImageSourceChunk) bool {
+		cancel()
+		return true
+	}}
+	c := &chunkedDiffer{ctx: ctx, retryDelay: time.Hour}
+	_, _, err := c.getBlobAtWithRetries(source, []ImageSourceChunk{{Offset: 0, Length: 1}}, 5)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, source.failures)
+}
+
+func TestParseMaxBandwidth(t *testing.T) {
+	for value, expected := range map[string]int64{
+		"":       0,
+		"0":      0,
+		"1000":   1000,
+		"10MB":   10000000,
+		"1.5 kB": 1500,
+	} {
+		limit, err := parseMaxBandwidth(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, limit, value)
+	}
+	for _, value := range []string{"fast", "-1", "10 apples"} {
+		_, err := parseMaxBandwidth(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestBandwidthLimiterShared(t *testing.T) {
+	assert.Same(t, bandwidthLimiter(12345), bandwidthLimiter(12345))
+	assert.NotSame(t, bandwidthLimiter(12345), bandwidthLimiter(54321))
+}
+
+func TestLimitBandwidth(t *testing.T) {
+	blob := &fakeBlob{data: bytes.Repeat([]byte("0123456789"), 500)}
+	// after the burst of 1000 bytes, the remaining 4000 bytes take 400ms
+	source := limitBandwidth(blob, rate.NewLimiter(10000, 1000))
+
+	start := time.Now()
+	streams, _, err := source.GetBlobAt([]ImageSourceChunk{{Offset: 0, Length: 2500}, {Offset: 2500, Length: 2500}})
+	require.NoError(t, err)
+	var data bytes.Buffer
+	for stream := range streams {
+		_, err := io.Copy(&data, stream)
+		require.NoError(t, err)
+		stream.Close()
+	}
+	assert.Equal(t, blob.data, data.Bytes())
+	assert.GreaterOrEqual(t, time.Since(start), 350*time.Millisecond)
+}
+
+func TestProgressReporterFromContext(t *testing.T) {
+	assert.Nil(t, progressReporterFromContext(context.Background()))
+
+	var reported Progress
+	ctx := WithProgressReporter(context.Background(), func(blobDigest digest.Digest, progress Progress) {
+		reported = progress
+	})
+	r := progressReporterFromContext(ctx)
+	require.NotNil(t, r)
+	r(digest.FromString("layer"), Progress{Files: 3})
+	assert.Equal(t, Progress{Files: 3}, reported)
+}
+
+func TestFetchProgress(t *testing.T) {
+	// a nil tracker ignores the updates
+	var tracker *progressTracker
+	tracker.update(func(p *Progress) { p.Files++ })
+
+	_, blob, parts := missingFilesLayer(4)
+	var reported []Progress
+	c := &chunkedDiffer{
+		fileType:    fileTypeNoCompression,
+		partDecoder: partDecoder{copyBuffer: makeCopyBuffer()},
+		useFsVerity: graphdriver.DifferFsVerityDisabled,
+		progress: &progressTracker{
+			report: func(p Progress) {
+				reported = append(reported, p)
+			},
+		},
+	}
+	c.progress.update(func(p *Progress) { p.RemainingBytes = fetchedSize(parts) })
+
+	dest, dirfd := checkoutDir(t)
+	require.NoError(t, c.retrieveMissingFiles(blob, dest, dirfd, parts, &archive.TarOptions{IgnoreChownErrors: true}, 1))
+
+	// one report for the size to fetch and one for every part fetched
+	require.Len(t, reported, len(parts)+1)
+	assert.Equal(t, int64(len(blob.data)), reported[0].RemainingBytes)
+	for i := 1; i < len(reported); i++ {
+		assert.Equal(t, reported[i-1].RemainingBytes-int64(parts[i-1].SourceChunk.Length), reported[i].RemainingBytes)
+	}
+	assert.Zero(t, reported[len(reported)-1].RemainingBytes)
+}
+
+func TestWholeBlobProgress(t *testing.T) {
+	var reported []Progress
+	tracker := &progressTracker{
+		report: func(p Progress) {
+			reported = append(reported, p)
+		},
+	}
+	tracker.update(func(p *Progress) { p.RemainingBytes = 100 })
+	tracker.fetched(10)
+	// the parts left are read from the whole blob once it is fetched
+	tracker.fetchingWholeBlob(500)
+	tracker.fetched(20)
+	tracker.update(func(p *Progress) { p.RemainingBytes = 0 })
+
+	remaining := make([]int64, 0, len(reported))
+	for _, p := range reported {
+		remaining = append(remaining, p.RemainingBytes)
+	}
+	assert.Equal(t, []int64{100, 90, 500, 0}, remaining)
+}
+
+func TestThrottleProgress(t *testing.T) {
+	layer1, layer2 := digest.FromString("layer1"), digest.FromString("layer2")
+	type report struct {
+		blobDigest digest.Digest
+		progress   Progress
+	}
+	var reported []report
+	r := ThrottleProgress(time.Hour, func(blobDigest digest.Digest, progress Progress) {
+		reported = append(reported, report{blobDigest, progress})
+	})
+
+	r(layer1, Progress{Files: 2})
+	r(layer1, Progress{Files: 2, ReusedFiles: 1})
+	r(layer2, Progress{Files: 3})
+	// the last report of a layer is never dropped
+	r(layer1, Progress{Files: 2, ReusedFiles: 2, Done: true})
+
+	assert.Equal(t, []report{
+		{layer1, Progress{Files: 2}},
+		{layer2, Progress{Files: 3}},
+		{layer1, Progress{Files: 2, ReusedFiles: 2, Done: true}},
+	}, reported)
+}
+
+func TestStatsReporterFromContext(t *testing.T) {
+	assert.Nil(t, statsReporterFromContext(context.Background()))
+
+	var reported digest.Digest
+	ctx := WithStatsReporter(context.Background(), func(blobDigest digest.Digest, stats *graphdriver.PullStats) {
+		reported = blobDigest
+	})
+	r := statsReporterFromContext(ctx)
+	require.NotNil(t, r)
+	r(digest.FromString("layer"), &graphdriver.PullStats{})
+	assert.Equal(t, digest.FromString("layer"), reported)
+}
+
+func TestDedupHits(t *testing.T) {
+	content := []byte("deduplicated content")
+	d := digest.FromBytes(content)
+
+	source := t.TempDir()
+	path := filepath.Join(source, d.Encoded()[:2], d.Encoded()[2:])
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+
+	_, dirfd := checkoutDir(t)
+
+	c := &chunkedDiffer{
+		layersCache: &layersCache{},
+		useFsVerity: graphdriver.DifferFsVerityDisabled,
+	}
+	copyOptions := &findAndCopyFileOptions{
+		dedupSources: []DedupSource{digestDir(source)},
+		options:      &archive.TarOptions{},
+	}
+	for _, name := range []string{"a", "b"} {
+		file := &internal.FileMetadata{
+			Type:   internal.TypeReg,
+			Name:   name,
+			Size:   int64(len(content)),
+			Digest: d.String(),
+			Mode:   0o644,
+			UID:    os.Getuid(),
+			GID:    os.Getgid(),
+		}
+		found, err := c.findAndCopyFile(dirfd, file, copyOptions, 0o644)
+		require.NoError(t, err)
+		assert.True(t, found)
+	}
+	assert.Equal(t, map[string]int{"dir=" + source: 2}, c.dedupHits)
+}
+
+func TestFetchedSize(t *testing.T) {
+	missingParts := []missingPart{
+		{SourceChunk: &ImageSourceChunk{Offset: 0, Length: 10}},
+		{SourceChunk: &ImageSourceChunk{Offset: 10, Length: 20}, Hole: true},
+		{SourceChunk: &ImageSourceChunk{Offset: 30, Length: 30}, OriginFile: &originFile{}},
+		{SourceChunk: &ImageSourceChunk{Offset: 60, Length: 5}},
+	}
+	assert.Equal(t, int64(15), fetchedSize(missingParts))
+	assert.Equal(t, int64(0), fetchedSize(nil))
+}
+
+func TestPullOptionsSummary(t *testing.T) {
+	c := &chunkedDiffer{
+		storeOpts:   &types.StoreOptions{},
+		partDecoder: partDecoder{copyBuffer: makeCopyBuffer()},
+	}
+	assert.Equal(t, []string{
+		"enable_partial_images=true",
+		"convert_images=false",
+		"convert_images_zstd_level=1",
+		"convert_images_threads=1",
+		"convert_images_cache=false",
+		"use_hard_links=false",
+		"use_reflinks=false",
+		`ostree_repos=""`,
+		`dedup_sources=""`,
+		"mode_normalization=none",
+		"intermediate_dir_mode=0755",
+		"clamp_mtime=none",
+		"clamp_atime=false",
+		"whiteout_policy=none",
+		`whiteout_allow=""`,
+		"disable_dedup=false",
+		"metadata_delta=false",
+		"partial_pull_concurrency=1",
+		"partial_pull_max_bandwidth=0",
+		"partial_pull_retries=0",
+		"partial_pull_retry_delay=1s",
+		"verify_tar_split=false",
+		`fsverity_signatures=""`,
+		`chunk_provider=""`,
+		"convert_to_zstd_chunked=false",
+		"workers=32",
+		"copy_buffer_size=2097152",
+		"max_missing_chunks=1024",
+	}, c.pullOptionsSummary())
+
+	c.convertToZstdChunked = true
+	c.storeOpts.PullOptions = map[string]string{
+		"enable_partial_images":      "false",
+		"convert_images":             "true",
+		"convert_images_zstd_level":  "19",
+		"convert_images_threads":     "4",
+		"convert_images_cache":       "false",
+		"use_hard_links":             "TRUE",
+		"use_reflinks":               "true",
+		"ostree_repos":               "/ostree/repo:/sysroot/ostree/repo",
+		"dedup_sources":              "dir=/mnt/blobs",
+		"mode_normalization":         "clear-setuid",
+		"intermediate_dir_mode":      "0o700",
+		"clamp_mtime":                "1700000000",
+		"clamp_atime":                "true",
+		"whiteout_policy":            "deny",
+		"whiteout_allow":             "/tmp:/var/cache",
+		"disable_dedup":              "true",
+		"metadata_delta":             "true",
+		"partial_pull_concurrency":   "8",
+		"partial_pull_max_bandwidth": "10MB",
+		"partial_pull_retries":       "5",
+		"partial_pull_retry_delay":   "500ms",
+		"verify_tar_split":           "true",
+		"fsverity_signatures":        "/etc/containers/fsverity",
+		"chunk_provider":             "/run/chunk-provider.sock",
+		"registry_token":             "hunter2",
+		"another_option":             "secret",
+	}
+	summary := c.pullOptionsSummary()
+	assert.Equal(t, []string{
+		"enable_partial_images=false",
+		"convert_images=true",
+		"convert_images_zstd_level=19",
+		"convert_images_threads=4",
+		"convert_images_cache=false",
+		"use_hard_links=true",
+		"use_reflinks=true",
+		`ostree_repos="/ostree/repo:/sysroot/ostree/repo"`,
+		`dedup_sources="dir=/mnt/blobs"`,
+		"mode_normalization=clear-setuid",
+		"intermediate_dir_mode=0700",
+		"clamp_mtime=1700000000",
+		"clamp_atime=true",
+		"whiteout_policy=deny",
+		`whiteout_allow="/tmp:/var/cache"`,
+		"disable_dedup=true",
+		"metadata_delta=true",
+		"partial_pull_concurrency=8",
+		"partial_pull_max_bandwidth=10000000",
+		"partial_pull_retries=5",
+		"partial_pull_retry_delay=500ms",
+		"verify_tar_split=true",
+		`fsverity_signatures="/etc/containers/fsverity"`,
+		`chunk_provider="/run/chunk-provider.sock"`,
+		"convert_to_zstd_chunked=true",
+		"workers=32",
+		"copy_buffer_size=2097152",
+		"max_missing_chunks=1024",
+		"unknown=another_option,registry_token",
+	}, summary)
+	for _, s := range []string{"hunter2", "secret"} {
+		assert.NotContains(t, strings.Join(summary, " "), s)
+	}
+}
@@ -0,0 +1,44 @@
+package chunked
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+const (
+	capabilityXattr = "security.capability"
+
+	// The layout of security.capability, from linux/capability.h.
+	vfsCapRevisionMask = 0xFF000000
+	vfsCapRevision2    = 0x02000000
+	vfsCapRevision3    = 0x03000000
+	xattrCapsSize2     = 20
+	xattrCapsSize3     = 24
+
+	// userContainersXattrPrefix is the prefix of the xattrs overriding,
+	// in a user namespace, the xattrs that only the host root can set.
+	userContainersXattrPrefix = "user.containers."
+)
+
+// userNSXattr returns the xattr to set for name=value when the layer is
+// applied in a user namespace, where the kernel rejects the xattrs referring
+// to IDs of the host:
+//   - the file capabilities for a given root ID are converted to the
+//     revision 2 format, that the kernel ties to the root of the namespace;
+//   - the trusted.* xattrs, that only the host root can set, are stored as
+//     user.containers.trusted.* instead.
+func userNSXattr(name string, value []byte) (string, []byte) {
+	switch {
+	case name == capabilityXattr:
+		if len(value) == xattrCapsSize3 && binary.LittleEndian.Uint32(value)&vfsCapRevisionMask == vfsCapRevision3 {
+			v2 := make([]byte, xattrCapsSize2)
+			copy(v2, value[:xattrCapsSize2])
+			magic := binary.LittleEndian.Uint32(value)&^vfsCapRevisionMask | vfsCapRevision2
+			binary.LittleEndian.PutUint32(v2, magic)
+			return name, v2
+		}
+	case strings.HasPrefix(name, "trusted."):
+		return userContainersXattrPrefix + name, value
+	}
+	return name, value
+}
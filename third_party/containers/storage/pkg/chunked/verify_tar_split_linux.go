@@ -0,0 +1,37 @@
+package chunked
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/vbatts/tar-split/tar/asm"
+	"github.com/vbatts/tar-split/tar/storage"
+	"golang.org/x/sys/unix"
+)
+
+// dirFileGetter is a tar-split FileGetter reading the files of a layer from
+// the directory where it was staged.
+type dirFileGetter struct {
+	dirfd int
+}
+
+func (g dirFileGetter) Get(name string) (io.ReadCloser, error) {
+	// The names come from the tar-split of the layer, do not let them
+	// escape the layer directory.
+	return openFileUnderRoot(name, g.dirfd, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+}
+
+// reassembledDigest reassembles the tar stream of a layer from its tar-split
+// and the files staged in dirfd, and returns its digest.  The reassembly
+// fails if the content of a file does not match the checksum recorded in the
+// tar-split, i.e. if the TOC of the layer disagrees with its tar stream.
+func reassembledDigest(dirfd int, tarSplit []byte) (digest.Digest, error) {
+	digester := digest.Canonical.Digester()
+	metadata := storage.NewJSONUnpacker(bytes.NewReader(tarSplit))
+	if err := asm.WriteOutputTarStream(dirFileGetter{dirfd: dirfd}, metadata, digester.Hash()); err != nil {
+		return "", fmt.Errorf("reassembling the layer from its tar-split: %w", err)
+	}
+	return digester.Digest(), nil
+}
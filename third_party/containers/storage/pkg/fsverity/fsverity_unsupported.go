@@ -0,0 +1,29 @@
+//go:build !linux
+// +build !linux
+
+package fsverity
+
+import (
+	"fmt"
+)
+
+// EnableVerity enables the verity feature on a file represented by the file descriptor 'fd'.  The file must be opened
+// in read-only mode.
+// The 'description' parameter is a human-readable description of the file.
+func EnableVerity(description string, fd int) error {
+	return fmt.Errorf("fs-verity is not supported on this platform")
+}
+
+// EnableVerityWithSignature enables the verity feature on a file represented by the file descriptor 'fd', attaching
+// the PKCS#7 signature of its verity digest, which the kernel checks against the .fs-verity keyring.  The file must
+// be opened in read-only mode.
+// The 'description' parameter is a human-readable description of the file.
+func EnableVerityWithSignature(description string, fd int, signature []byte) error {
+	return fmt.Errorf("fs-verity is not supported on this platform")
+}
+
+// MeasureVerity measures and returns the verity digest for the file represented by 'fd'.
+// The 'description' parameter is a human-readable description of the file.
+func MeasureVerity(description string, fd int) (string, error) {
+	return "", fmt.Errorf("fs-verity is not supported on this platform")
+}
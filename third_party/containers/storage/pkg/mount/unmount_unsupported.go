@@ -0,0 +1,8 @@
+//go:build windows
+// +build windows
+
+package mount
+
+func unmount(target string, flag int) error {
+	panic("Not implemented")
+}